@@ -0,0 +1,33 @@
+class CreateEmailSubscribers < ActiveRecord::Migration[8.0]
+  def change
+    create_table :email_subscribers, id: :uuid, default: -> { "gen_random_uuid()" } do |t|
+      t.uuid :blog_id, null: false
+      t.string :email, null: false
+      t.string :confirmation_token, null: false
+      t.string :unsubscribe_token, null: false
+      t.datetime :confirmation_sent_at
+      t.datetime :confirmed_at
+      t.datetime :unsubscribed_at
+
+      t.timestamps
+    end
+
+    add_index :email_subscribers, [:blog_id, :email], unique: true
+    add_index :email_subscribers, :confirmation_token, unique: true
+    add_index :email_subscribers, :unsubscribe_token, unique: true
+    add_foreign_key :email_subscribers, :blogs, on_delete: :cascade
+
+    # One row per post that has been sent to subscribers, so a post is only
+    # ever mailed once even if it is unpublished and published again
+    create_table :newsletter_deliveries, id: :uuid, default: -> { "gen_random_uuid()" } do |t|
+      t.uuid :post_id, null: false
+      t.integer :recipients_count, default: 0, null: false
+      t.datetime :sent_at
+
+      t.timestamps
+    end
+
+    add_index :newsletter_deliveries, :post_id, unique: true
+    add_foreign_key :newsletter_deliveries, :posts, on_delete: :cascade
+  end
+end
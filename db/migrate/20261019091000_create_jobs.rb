@@ -0,0 +1,26 @@
+class CreateJobs < ActiveRecord::Migration[8.0]
+  def change
+    create_table :jobs, id: :uuid, default: -> { "gen_random_uuid()" } do |t|
+      t.string :queue, null: false, default: "default"
+      t.string :kind, null: false
+      t.jsonb :payload, null: false, default: {}
+      t.string :status, null: false, default: "pending"
+      t.integer :attempts, null: false, default: 0
+      t.integer :max_attempts, null: false, default: 10
+      t.datetime :run_at, null: false, default: -> { "CURRENT_TIMESTAMP" }
+      t.datetime :locked_at
+      t.string :locked_by
+      t.text :last_error
+      t.datetime :finished_at
+      t.uuid :blog_id
+
+      t.timestamps
+    end
+
+    # Workers poll pending jobs by run_at, so keep that index small
+    add_index :jobs, [:queue, :run_at], where: "status = 'pending'", name: "index_jobs_on_queue_and_run_at_pending"
+    add_index :jobs, [:status, :locked_at]
+    add_index :jobs, [:blog_id, :status]
+    add_foreign_key :jobs, :blogs, on_delete: :cascade
+  end
+end
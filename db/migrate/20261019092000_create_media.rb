@@ -0,0 +1,20 @@
+class CreateMedia < ActiveRecord::Migration[8.0]
+  def change
+    create_table :media, id: :uuid, default: -> { "gen_random_uuid()" } do |t|
+      t.uuid :blog_id, null: false
+      t.uuid :user_id
+      t.string :key, null: false
+      t.string :filename, null: false
+      t.string :content_type, null: false
+      t.bigint :byte_size, null: false
+      t.string :checksum, null: false
+
+      t.timestamps
+    end
+
+    add_index :media, :key, unique: true
+    add_index :media, [:blog_id, :created_at]
+    add_foreign_key :media, :blogs, on_delete: :cascade
+    add_foreign_key :media, :users, on_delete: :nullify
+  end
+end
@@ -0,0 +1,7 @@
+class AddImageFieldsToMedia < ActiveRecord::Migration[8.0]
+  def change
+    add_column :media, :width, :integer
+    add_column :media, :height, :integer
+    add_column :media, :variant_widths, :integer, array: true, null: false, default: []
+  end
+end
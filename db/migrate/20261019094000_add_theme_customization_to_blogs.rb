@@ -0,0 +1,6 @@
+class AddThemeCustomizationToBlogs < ActiveRecord::Migration[8.0]
+  def change
+    add_column :blogs, :theme_overrides, :jsonb, null: false, default: {}
+    add_column :blogs, :custom_css, :text
+  end
+end
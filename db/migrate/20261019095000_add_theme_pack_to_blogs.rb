@@ -0,0 +1,5 @@
+class AddThemePackToBlogs < ActiveRecord::Migration[8.0]
+  def change
+    add_column :blogs, :theme_pack, :string, null: false, default: ""
+  end
+end
@@ -0,0 +1,5 @@
+class AddMarkdownOptionsToBlogs < ActiveRecord::Migration[8.0]
+  def change
+    add_column :blogs, :markdown_options, :jsonb, null: false, default: {}
+  end
+end
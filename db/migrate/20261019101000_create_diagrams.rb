@@ -0,0 +1,12 @@
+class CreateDiagrams < ActiveRecord::Migration[8.0]
+  def change
+    create_table :diagrams, id: :uuid, default: -> { "gen_random_uuid()" } do |t|
+      t.string :content_hash, null: false
+      t.text :svg, null: false
+
+      t.timestamps
+    end
+
+    add_index :diagrams, :content_hash, unique: true
+  end
+end
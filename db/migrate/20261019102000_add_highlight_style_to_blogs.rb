@@ -0,0 +1,5 @@
+class AddHighlightStyleToBlogs < ActiveRecord::Migration[8.0]
+  def change
+    add_column :blogs, :highlight_style, :string, null: false, default: ""
+  end
+end
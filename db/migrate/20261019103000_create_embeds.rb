@@ -0,0 +1,15 @@
+class CreateEmbeds < ActiveRecord::Migration[8.0]
+  def change
+    create_table :embeds, id: :uuid, default: -> { "gen_random_uuid()" } do |t|
+      t.text :url, null: false
+      t.string :provider, null: false
+      t.string :title, null: false, default: ""
+      t.text :html, null: false, default: ""
+      t.datetime :fetched_at, null: false
+
+      t.timestamps
+    end
+
+    add_index :embeds, :url, unique: true
+  end
+end
@@ -0,0 +1,16 @@
+class CreateLinkPreviews < ActiveRecord::Migration[8.0]
+  def change
+    create_table :link_previews, id: :uuid, default: -> { "gen_random_uuid()" } do |t|
+      t.text :url, null: false
+      t.string :title, null: false, default: ""
+      t.text :description, null: false, default: ""
+      t.text :image_url, null: false, default: ""
+      t.string :site_name, null: false, default: ""
+      t.datetime :fetched_at, null: false
+
+      t.timestamps
+    end
+
+    add_index :link_previews, :url, unique: true
+  end
+end
@@ -0,0 +1,26 @@
+class CreateWebmentions < ActiveRecord::Migration[8.0]
+  def change
+    create_table :webmentions, id: :uuid, default: -> { "gen_random_uuid()" } do |t|
+      t.uuid :blog_id, null: false
+      t.uuid :post_id, null: false
+      t.text :source, null: false
+      t.text :target, null: false
+      t.string :status, null: false, default: "unverified"
+      t.string :mention_type, null: false, default: "mention"
+      t.string :author_name, null: false, default: ""
+      t.text :author_url, null: false, default: ""
+      t.text :author_photo, null: false, default: ""
+      t.text :content, null: false, default: ""
+      t.datetime :published_at
+      t.datetime :verified_at
+
+      t.timestamps
+    end
+
+    add_index :webmentions, [:source, :target], unique: true
+    add_index :webmentions, [:blog_id, :status]
+    add_index :webmentions, [:post_id, :status]
+    add_foreign_key :webmentions, :blogs, on_delete: :cascade
+    add_foreign_key :webmentions, :posts, on_delete: :cascade
+  end
+end
@@ -0,0 +1,37 @@
+class CreateActivitypubTables < ActiveRecord::Migration[8.0]
+  def change
+    create_table :activitypub_keys, id: :uuid, default: -> { "gen_random_uuid()" } do |t|
+      t.uuid :blog_id, null: false
+      t.text :public_key_pem, null: false
+      t.text :private_key_pem, null: false
+
+      t.timestamps
+    end
+
+    add_index :activitypub_keys, :blog_id, unique: true
+    add_foreign_key :activitypub_keys, :blogs, on_delete: :cascade
+
+    create_table :activitypub_followers, id: :uuid, default: -> { "gen_random_uuid()" } do |t|
+      t.uuid :blog_id, null: false
+      t.text :actor_id, null: false
+      t.text :inbox, null: false
+      t.text :shared_inbox, null: false, default: ""
+
+      t.timestamps
+    end
+
+    add_index :activitypub_followers, [:blog_id, :actor_id], unique: true
+    add_foreign_key :activitypub_followers, :blogs, on_delete: :cascade
+
+    create_table :activitypub_deliveries, id: :uuid, default: -> { "gen_random_uuid()" } do |t|
+      t.uuid :post_id, null: false
+      t.integer :inboxes_count, null: false, default: 0
+      t.datetime :sent_at
+
+      t.timestamps
+    end
+
+    add_index :activitypub_deliveries, :post_id, unique: true
+    add_foreign_key :activitypub_deliveries, :posts, on_delete: :cascade
+  end
+end
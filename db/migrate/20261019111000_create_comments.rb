@@ -0,0 +1,35 @@
+class CreateComments < ActiveRecord::Migration[8.0]
+  def change
+    add_column :blogs, :comments_enabled, :boolean, null: false, default: false
+
+    create_table :comments, id: :uuid, default: -> { "gen_random_uuid()" } do |t|
+      t.uuid :blog_id, null: false
+      t.uuid :post_id, null: false
+      t.string :author_name, null: false
+      t.string :author_email, null: false, default: ""
+      t.text :author_url, null: false, default: ""
+      t.text :body, null: false
+      t.string :status, null: false, default: "pending"
+      t.string :ip_hash, null: false, default: ""
+
+      t.timestamps
+    end
+
+    add_index :comments, [:post_id, :status]
+    add_index :comments, [:blog_id, :status]
+    add_index :comments, [:blog_id, :ip_hash, :created_at]
+    add_foreign_key :comments, :blogs, on_delete: :cascade
+    add_foreign_key :comments, :posts, on_delete: :cascade
+
+    create_table :comment_bans, id: :uuid, default: -> { "gen_random_uuid()" } do |t|
+      t.uuid :blog_id, null: false
+      t.string :author_email, null: false, default: ""
+      t.string :ip_hash, null: false, default: ""
+
+      t.timestamps
+    end
+
+    add_index :comment_bans, :blog_id
+    add_foreign_key :comment_bans, :blogs, on_delete: :cascade
+  end
+end
@@ -0,0 +1,8 @@
+class AddVisibilityToPosts < ActiveRecord::Migration[8.0]
+  def change
+    add_column :posts, :visibility, :string, null: false, default: "public"
+    add_column :posts, :password_digest, :string
+
+    add_index :posts, [:blog_id, :visibility]
+  end
+end
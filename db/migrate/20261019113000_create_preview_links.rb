@@ -0,0 +1,16 @@
+class CreatePreviewLinks < ActiveRecord::Migration[8.0]
+  def change
+    create_table :preview_links, id: :uuid, default: -> { "gen_random_uuid()" } do |t|
+      t.uuid :blog_id, null: false
+      t.uuid :post_id, null: false
+      t.text :token, null: false
+      t.datetime :expires_at, null: false
+
+      t.timestamps
+    end
+
+    add_index :preview_links, [:post_id, :expires_at]
+    add_foreign_key :preview_links, :blogs, on_delete: :cascade
+    add_foreign_key :preview_links, :posts, on_delete: :cascade
+  end
+end
@@ -0,0 +1,27 @@
+class CreateSeries < ActiveRecord::Migration[8.0]
+  def change
+    create_table :series, id: :uuid, default: -> { "gen_random_uuid()" } do |t|
+      t.uuid :blog_id, null: false
+      t.string :title, null: false
+      t.string :slug, null: false
+
+      t.timestamps
+    end
+
+    add_index :series, [:blog_id, :slug], unique: true
+    add_foreign_key :series, :blogs, on_delete: :cascade
+
+    create_table :series_posts, id: :uuid, default: -> { "gen_random_uuid()" } do |t|
+      t.uuid :series_id, null: false
+      t.uuid :post_id, null: false
+      t.integer :position, null: false
+
+      t.timestamps
+    end
+
+    add_index :series_posts, :post_id, unique: true
+    add_index :series_posts, [:series_id, :position]
+    add_foreign_key :series_posts, :series, on_delete: :cascade
+    add_foreign_key :series_posts, :posts, on_delete: :cascade
+  end
+end
@@ -0,0 +1,5 @@
+class AddPublishedAtIndexToPosts < ActiveRecord::Migration[8.0]
+  def change
+    add_index :posts, [:blog_id, :published_at, :id]
+  end
+end
@@ -0,0 +1,15 @@
+class CreateUnlockAttempts < ActiveRecord::Migration[8.0]
+  def change
+    create_table :unlock_attempts, id: :uuid, default: -> { "gen_random_uuid()" } do |t|
+      t.uuid :blog_id, null: false
+      t.uuid :post_id, null: false
+      t.string :ip_hash, null: false
+      t.datetime :created_at, null: false
+    end
+
+    add_index :unlock_attempts, [:blog_id, :ip_hash, :created_at]
+    add_index :unlock_attempts, [:blog_id, :created_at]
+    add_foreign_key :unlock_attempts, :blogs, on_delete: :cascade
+    add_foreign_key :unlock_attempts, :posts, on_delete: :cascade
+  end
+end
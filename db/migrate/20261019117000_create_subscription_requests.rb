@@ -0,0 +1,13 @@
+class CreateSubscriptionRequests < ActiveRecord::Migration[8.0]
+  def change
+    create_table :subscription_requests, id: :uuid, default: -> { "gen_random_uuid()" } do |t|
+      t.uuid :blog_id, null: false
+      t.string :ip_hash, null: false
+      t.datetime :created_at, null: false
+    end
+
+    add_index :subscription_requests, [:blog_id, :ip_hash, :created_at], name: "index_subscription_requests_on_blog_ip_and_created_at"
+    add_index :subscription_requests, [:blog_id, :created_at]
+    add_foreign_key :subscription_requests, :blogs, on_delete: :cascade
+  end
+end
@@ -10,7 +10,7 @@
 #
 # It's strongly recommended that you check this file into your version control system.
 
-ActiveRecord::Schema[8.0].define(version: 2025_09_23_120106) do
+ActiveRecord::Schema[8.0].define(version: 2026_10_19_117000) do
   # These are extensions that must be enabled in order to support this database
   enable_extension "pg_catalog.plpgsql"
   enable_extension "pgcrypto"
@@ -53,6 +53,34 @@ ActiveRecord::Schema[8.0].define(version: 2025_09_23_120106) do
     t.index ["blob_id", "variation_digest"], name: "index_active_storage_variant_records_uniqueness", unique: true
   end
 
+  create_table "activitypub_deliveries", id: :uuid, default: -> { "gen_random_uuid()" }, force: :cascade do |t|
+    t.uuid "post_id", null: false
+    t.integer "inboxes_count", default: 0, null: false
+    t.datetime "sent_at"
+    t.datetime "created_at", null: false
+    t.datetime "updated_at", null: false
+    t.index ["post_id"], name: "index_activitypub_deliveries_on_post_id", unique: true
+  end
+
+  create_table "activitypub_followers", id: :uuid, default: -> { "gen_random_uuid()" }, force: :cascade do |t|
+    t.uuid "blog_id", null: false
+    t.text "actor_id", null: false
+    t.text "inbox", null: false
+    t.text "shared_inbox", default: "", null: false
+    t.datetime "created_at", null: false
+    t.datetime "updated_at", null: false
+    t.index ["blog_id", "actor_id"], name: "index_activitypub_followers_on_blog_id_and_actor_id", unique: true
+  end
+
+  create_table "activitypub_keys", id: :uuid, default: -> { "gen_random_uuid()" }, force: :cascade do |t|
+    t.uuid "blog_id", null: false
+    t.text "public_key_pem", null: false
+    t.text "private_key_pem", null: false
+    t.datetime "created_at", null: false
+    t.datetime "updated_at", null: false
+    t.index ["blog_id"], name: "index_activitypub_keys_on_blog_id", unique: true
+  end
+
   create_table "blogs", id: :uuid, default: -> { "gen_random_uuid()" }, force: :cascade do |t|
     t.uuid "user_id", null: false
     t.string "subdomain"
@@ -67,6 +95,12 @@ ActiveRecord::Schema[8.0].define(version: 2025_09_23_120106) do
     t.datetime "created_at", null: false
     t.datetime "updated_at", null: false
     t.boolean "primary", default: false, null: false
+    t.jsonb "theme_overrides", default: {}, null: false
+    t.text "custom_css"
+    t.string "theme_pack", default: "", null: false
+    t.jsonb "markdown_options", default: {}, null: false
+    t.string "highlight_style", default: "", null: false
+    t.boolean "comments_enabled", default: false, null: false
     t.index ["custom_domain"], name: "index_blogs_on_custom_domain", unique: true
     t.index ["slug"], name: "index_blogs_on_slug", unique: true
     t.index ["subdomain"], name: "index_blogs_on_subdomain", unique: true
@@ -74,6 +108,65 @@ ActiveRecord::Schema[8.0].define(version: 2025_09_23_120106) do
     t.index ["user_id"], name: "index_blogs_on_user_id"
   end
 
+  create_table "comment_bans", id: :uuid, default: -> { "gen_random_uuid()" }, force: :cascade do |t|
+    t.uuid "blog_id", null: false
+    t.string "author_email", default: "", null: false
+    t.string "ip_hash", default: "", null: false
+    t.datetime "created_at", null: false
+    t.datetime "updated_at", null: false
+    t.index ["blog_id"], name: "index_comment_bans_on_blog_id"
+  end
+
+  create_table "comments", id: :uuid, default: -> { "gen_random_uuid()" }, force: :cascade do |t|
+    t.uuid "blog_id", null: false
+    t.uuid "post_id", null: false
+    t.string "author_name", null: false
+    t.string "author_email", default: "", null: false
+    t.text "author_url", default: "", null: false
+    t.text "body", null: false
+    t.string "status", default: "pending", null: false
+    t.string "ip_hash", default: "", null: false
+    t.datetime "created_at", null: false
+    t.datetime "updated_at", null: false
+    t.index ["blog_id", "ip_hash", "created_at"], name: "index_comments_on_blog_id_and_ip_hash_and_created_at"
+    t.index ["blog_id", "status"], name: "index_comments_on_blog_id_and_status"
+    t.index ["post_id", "status"], name: "index_comments_on_post_id_and_status"
+  end
+
+  create_table "diagrams", id: :uuid, default: -> { "gen_random_uuid()" }, force: :cascade do |t|
+    t.string "content_hash", null: false
+    t.text "svg", null: false
+    t.datetime "created_at", null: false
+    t.datetime "updated_at", null: false
+    t.index ["content_hash"], name: "index_diagrams_on_content_hash", unique: true
+  end
+
+  create_table "email_subscribers", id: :uuid, default: -> { "gen_random_uuid()" }, force: :cascade do |t|
+    t.uuid "blog_id", null: false
+    t.string "email", null: false
+    t.string "confirmation_token", null: false
+    t.string "unsubscribe_token", null: false
+    t.datetime "confirmation_sent_at"
+    t.datetime "confirmed_at"
+    t.datetime "unsubscribed_at"
+    t.datetime "created_at", null: false
+    t.datetime "updated_at", null: false
+    t.index ["blog_id", "email"], name: "index_email_subscribers_on_blog_id_and_email", unique: true
+    t.index ["confirmation_token"], name: "index_email_subscribers_on_confirmation_token", unique: true
+    t.index ["unsubscribe_token"], name: "index_email_subscribers_on_unsubscribe_token", unique: true
+  end
+
+  create_table "embeds", id: :uuid, default: -> { "gen_random_uuid()" }, force: :cascade do |t|
+    t.text "url", null: false
+    t.string "provider", null: false
+    t.string "title", default: "", null: false
+    t.text "html", default: "", null: false
+    t.datetime "fetched_at", null: false
+    t.datetime "created_at", null: false
+    t.datetime "updated_at", null: false
+    t.index ["url"], name: "index_embeds_on_url", unique: true
+  end
+
   create_table "friendly_id_slugs", id: :uuid, default: -> { "gen_random_uuid()" }, force: :cascade do |t|
     t.string "slug", null: false
     t.string "sluggable_type", limit: 50
@@ -86,6 +179,64 @@ ActiveRecord::Schema[8.0].define(version: 2025_09_23_120106) do
     t.index ["sluggable_id", "sluggable_type"], name: "index_friendly_id_slugs_on_sluggable_uuid_and_sluggable_type"
   end
 
+  create_table "jobs", id: :uuid, default: -> { "gen_random_uuid()" }, force: :cascade do |t|
+    t.string "queue", default: "default", null: false
+    t.string "kind", null: false
+    t.jsonb "payload", default: {}, null: false
+    t.string "status", default: "pending", null: false
+    t.integer "attempts", default: 0, null: false
+    t.integer "max_attempts", default: 10, null: false
+    t.datetime "run_at", default: -> { "CURRENT_TIMESTAMP" }, null: false
+    t.datetime "locked_at"
+    t.string "locked_by"
+    t.text "last_error"
+    t.datetime "finished_at"
+    t.uuid "blog_id"
+    t.datetime "created_at", null: false
+    t.datetime "updated_at", null: false
+    t.index ["blog_id", "status"], name: "index_jobs_on_blog_id_and_status"
+    t.index ["queue", "run_at"], name: "index_jobs_on_queue_and_run_at_pending", where: "((status)::text = 'pending'::text)"
+    t.index ["status", "locked_at"], name: "index_jobs_on_status_and_locked_at"
+  end
+
+  create_table "link_previews", id: :uuid, default: -> { "gen_random_uuid()" }, force: :cascade do |t|
+    t.text "url", null: false
+    t.string "title", default: "", null: false
+    t.text "description", default: "", null: false
+    t.text "image_url", default: "", null: false
+    t.string "site_name", default: "", null: false
+    t.datetime "fetched_at", null: false
+    t.datetime "created_at", null: false
+    t.datetime "updated_at", null: false
+    t.index ["url"], name: "index_link_previews_on_url", unique: true
+  end
+
+  create_table "media", id: :uuid, default: -> { "gen_random_uuid()" }, force: :cascade do |t|
+    t.uuid "blog_id", null: false
+    t.uuid "user_id"
+    t.string "key", null: false
+    t.string "filename", null: false
+    t.string "content_type", null: false
+    t.bigint "byte_size", null: false
+    t.string "checksum", null: false
+    t.datetime "created_at", null: false
+    t.datetime "updated_at", null: false
+    t.integer "width"
+    t.integer "height"
+    t.integer "variant_widths", default: [], null: false, array: true
+    t.index ["blog_id", "created_at"], name: "index_media_on_blog_id_and_created_at"
+    t.index ["key"], name: "index_media_on_key", unique: true
+  end
+
+  create_table "newsletter_deliveries", id: :uuid, default: -> { "gen_random_uuid()" }, force: :cascade do |t|
+    t.uuid "post_id", null: false
+    t.integer "recipients_count", default: 0, null: false
+    t.datetime "sent_at"
+    t.datetime "created_at", null: false
+    t.datetime "updated_at", null: false
+    t.index ["post_id"], name: "index_newsletter_deliveries_on_post_id", unique: true
+  end
+
   create_table "posts", id: :uuid, default: -> { "gen_random_uuid()" }, force: :cascade do |t|
     t.string "title"
     t.boolean "published"
@@ -100,13 +251,54 @@ ActiveRecord::Schema[8.0].define(version: 2025_09_23_120106) do
     t.boolean "has_mermaid_diagrams", default: false, null: false
     t.boolean "featured", default: false
     t.uuid "blog_id"
+    t.string "visibility", default: "public", null: false
+    t.string "password_digest"
     t.index ["author_id"], name: "index_posts_on_author_id_pages_only", where: "((type)::text = 'Page'::text)"
     t.index ["author_id"], name: "index_posts_on_author_uuid"
     t.index ["blog_id"], name: "index_posts_on_blog_id"
+    t.index ["blog_id", "published_at", "id"], name: "index_posts_on_blog_id_and_published_at_and_id"
+    t.index ["blog_id", "visibility"], name: "index_posts_on_blog_id_and_visibility"
     t.index ["slug", "blog_id", "author_id"], name: "index_posts_on_slug_blog_id_author_id", unique: true
     t.index ["type"], name: "index_posts_on_type"
   end
 
+  create_table "preview_links", id: :uuid, default: -> { "gen_random_uuid()" }, force: :cascade do |t|
+    t.uuid "blog_id", null: false
+    t.uuid "post_id", null: false
+    t.text "token", null: false
+    t.datetime "expires_at", null: false
+    t.datetime "created_at", null: false
+    t.datetime "updated_at", null: false
+    t.index ["post_id", "expires_at"], name: "index_preview_links_on_post_id_and_expires_at"
+  end
+
+  create_table "series", id: :uuid, default: -> { "gen_random_uuid()" }, force: :cascade do |t|
+    t.uuid "blog_id", null: false
+    t.string "title", null: false
+    t.string "slug", null: false
+    t.datetime "created_at", null: false
+    t.datetime "updated_at", null: false
+    t.index ["blog_id", "slug"], name: "index_series_on_blog_id_and_slug", unique: true
+  end
+
+  create_table "series_posts", id: :uuid, default: -> { "gen_random_uuid()" }, force: :cascade do |t|
+    t.uuid "series_id", null: false
+    t.uuid "post_id", null: false
+    t.integer "position", null: false
+    t.datetime "created_at", null: false
+    t.datetime "updated_at", null: false
+    t.index ["post_id"], name: "index_series_posts_on_post_id", unique: true
+    t.index ["series_id", "position"], name: "index_series_posts_on_series_id_and_position"
+  end
+
+  create_table "subscription_requests", id: :uuid, default: -> { "gen_random_uuid()" }, force: :cascade do |t|
+    t.uuid "blog_id", null: false
+    t.string "ip_hash", null: false
+    t.datetime "created_at", null: false
+    t.index ["blog_id", "created_at"], name: "index_subscription_requests_on_blog_id_and_created_at"
+    t.index ["blog_id", "ip_hash", "created_at"], name: "index_subscription_requests_on_blog_ip_and_created_at"
+  end
+
   create_table "taggings", id: :uuid, default: -> { "gen_random_uuid()" }, force: :cascade do |t|
     t.string "taggable_type"
     t.string "tagger_type"
@@ -134,6 +326,15 @@ ActiveRecord::Schema[8.0].define(version: 2025_09_23_120106) do
     t.index ["slug"], name: "index_tags_on_slug", unique: true
   end
 
+  create_table "unlock_attempts", id: :uuid, default: -> { "gen_random_uuid()" }, force: :cascade do |t|
+    t.uuid "blog_id", null: false
+    t.uuid "post_id", null: false
+    t.string "ip_hash", null: false
+    t.datetime "created_at", null: false
+    t.index ["blog_id", "created_at"], name: "index_unlock_attempts_on_blog_id_and_created_at"
+    t.index ["blog_id", "ip_hash", "created_at"], name: "index_unlock_attempts_on_blog_id_and_ip_hash_and_created_at"
+  end
+
   create_table "user_tokens", id: :uuid, default: -> { "gen_random_uuid()" }, force: :cascade do |t|
     t.string "token", null: false
     t.datetime "expires_at"
@@ -168,11 +369,52 @@ ActiveRecord::Schema[8.0].define(version: 2025_09_23_120106) do
     t.index ["reset_password_token"], name: "index_users_on_reset_password_token", unique: true
   end
 
+  create_table "webmentions", id: :uuid, default: -> { "gen_random_uuid()" }, force: :cascade do |t|
+    t.uuid "blog_id", null: false
+    t.uuid "post_id", null: false
+    t.text "source", null: false
+    t.text "target", null: false
+    t.string "status", default: "unverified", null: false
+    t.string "mention_type", default: "mention", null: false
+    t.string "author_name", default: "", null: false
+    t.text "author_url", default: "", null: false
+    t.text "author_photo", default: "", null: false
+    t.text "content", default: "", null: false
+    t.datetime "published_at"
+    t.datetime "verified_at"
+    t.datetime "created_at", null: false
+    t.datetime "updated_at", null: false
+    t.index ["blog_id", "status"], name: "index_webmentions_on_blog_id_and_status"
+    t.index ["post_id", "status"], name: "index_webmentions_on_post_id_and_status"
+    t.index ["source", "target"], name: "index_webmentions_on_source_and_target", unique: true
+  end
+
   add_foreign_key "active_storage_attachments", "active_storage_blobs", column: "blob_id"
   add_foreign_key "active_storage_variant_records", "active_storage_blobs", column: "blob_id"
+  add_foreign_key "activitypub_deliveries", "posts", on_delete: :cascade
+  add_foreign_key "activitypub_followers", "blogs", on_delete: :cascade
+  add_foreign_key "activitypub_keys", "blogs", on_delete: :cascade
   add_foreign_key "blogs", "users"
+  add_foreign_key "comment_bans", "blogs", on_delete: :cascade
+  add_foreign_key "comments", "blogs", on_delete: :cascade
+  add_foreign_key "comments", "posts", on_delete: :cascade
+  add_foreign_key "email_subscribers", "blogs", on_delete: :cascade
+  add_foreign_key "jobs", "blogs", on_delete: :cascade
+  add_foreign_key "media", "blogs", on_delete: :cascade
+  add_foreign_key "media", "users", on_delete: :nullify
+  add_foreign_key "newsletter_deliveries", "posts", on_delete: :cascade
   add_foreign_key "posts", "blogs"
   add_foreign_key "posts", "users", column: "author_id"
+  add_foreign_key "preview_links", "blogs", on_delete: :cascade
+  add_foreign_key "preview_links", "posts", on_delete: :cascade
+  add_foreign_key "series", "blogs", on_delete: :cascade
+  add_foreign_key "series_posts", "posts", on_delete: :cascade
+  add_foreign_key "series_posts", "series", on_delete: :cascade
+  add_foreign_key "subscription_requests", "blogs", on_delete: :cascade
   add_foreign_key "taggings", "tags"
+  add_foreign_key "unlock_attempts", "blogs", on_delete: :cascade
+  add_foreign_key "unlock_attempts", "posts", on_delete: :cascade
   add_foreign_key "user_tokens", "users"
+  add_foreign_key "webmentions", "blogs", on_delete: :cascade
+  add_foreign_key "webmentions", "posts", on_delete: :cascade
 end
@@ -0,0 +1,366 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"time"
+
+	"github.com/cassiascheffer/willow_camp/internal/access"
+	"github.com/cassiascheffer/willow_camp/internal/activitypub"
+	"github.com/cassiascheffer/willow_camp/internal/archive"
+	"github.com/cassiascheffer/willow_camp/internal/assets"
+	"github.com/cassiascheffer/willow_camp/internal/auth"
+	bloghandlers "github.com/cassiascheffer/willow_camp/internal/blog/handlers"
+	blogmiddleware "github.com/cassiascheffer/willow_camp/internal/blog/middleware"
+	"github.com/cassiascheffer/willow_camp/internal/comments"
+	dashboardhandlers "github.com/cassiascheffer/willow_camp/internal/dashboard/handlers"
+	"github.com/cassiascheffer/willow_camp/internal/diagrams"
+	"github.com/cassiascheffer/willow_camp/internal/embeds"
+	"github.com/cassiascheffer/willow_camp/internal/jobs"
+	"github.com/cassiascheffer/willow_camp/internal/logging"
+	"github.com/cassiascheffer/willow_camp/internal/mailer"
+	"github.com/cassiascheffer/willow_camp/internal/media"
+	"github.com/cassiascheffer/willow_camp/internal/newsletter"
+	"github.com/cassiascheffer/willow_camp/internal/previews"
+	"github.com/cassiascheffer/willow_camp/internal/repository"
+	sharedhandlers "github.com/cassiascheffer/willow_camp/internal/shared/handlers"
+	"github.com/cassiascheffer/willow_camp/internal/storage"
+	"github.com/cassiascheffer/willow_camp/internal/views"
+	"github.com/cassiascheffer/willow_camp/internal/webmentions"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/labstack/echo/v4"
+	echomiddleware "github.com/labstack/echo/v4/middleware"
+)
+
+func main() {
+	// Initialize structured logger
+	logger := logging.NewLogger()
+
+	// Load environment variables
+	dbURL := os.Getenv("DATABASE_URL")
+	if dbURL == "" {
+		log.Fatal("DATABASE_URL environment variable is required")
+	}
+
+	sessionSecret := os.Getenv("SESSION_SECRET")
+	if sessionSecret == "" {
+		sessionSecret = "dev-secret-change-in-production"
+		logger.Warn("Using default SESSION_SECRET", "message", "Set SESSION_SECRET env var in production!")
+	}
+
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "3001"
+	}
+
+	baseDomain := os.Getenv("BASE_DOMAIN")
+	if baseDomain == "" {
+		baseDomain = "localhost:3001"
+		logger.Info("Using default BASE_DOMAIN", "domain", baseDomain, "message", "set BASE_DOMAIN env var for production")
+	}
+
+	// Initialize database connection pool
+	ctx := context.Background()
+	poolConfig, err := pgxpool.ParseConfig(dbURL)
+	if err != nil {
+		log.Fatalf("Unable to parse database URL: %v\n", err)
+	}
+
+	// Configure pool for performance
+	poolConfig.MaxConns = 25
+	poolConfig.MinConns = 5
+	poolConfig.MaxConnLifetime = 5 * time.Minute
+	poolConfig.MaxConnIdleTime = 1 * time.Minute
+
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		log.Fatalf("Unable to connect to database: %v\n", err)
+	}
+	defer pool.Close()
+
+	// Verify database connection
+	if err := pool.Ping(ctx); err != nil {
+		log.Fatalf("Unable to ping database: %v\n", err)
+	}
+	logger.Info("Successfully connected to database")
+
+	// Initialize repositories
+	repos := repository.NewRepositories(pool)
+
+	// Initialize auth
+	authService := auth.New(repos.User, sessionSecret, logger)
+
+	// Parse templates once (re-read from disk on change when GO_ENV=development)
+	viewsConfig := views.ConfigFromEnv()
+	viewsConfig.Logger = logger
+	if viewsConfig.Reload {
+		assets.ReloadFrom(filepath.Join(viewsConfig.Root, "static"))
+	}
+	registry, err := views.Load(viewsConfig)
+	if err != nil {
+		log.Fatalf("Unable to load templates: %v\n", err)
+	}
+
+	// Initialize background jobs (Postgres-backed queue)
+	queue := jobs.New(repos.Job, jobs.ConfigFromEnv(), logger)
+
+	// Initialize email delivery (logs instead of sending when SMTP_HOST is unset)
+	mail := mailer.NewFromEnv(logger)
+	newsletterService := newsletter.New(repos, mail, queue, registry, baseDomain, logger)
+
+	// Initialize uploads (local disk unless STORAGE_BACKEND=s3)
+	store, err := storage.NewFromEnv()
+	if err != nil {
+		log.Fatalf("Unable to initialize storage: %v\n", err)
+	}
+	mediaService := media.New(repos, store, queue, baseDomain)
+
+	// Initialize server-side mermaid diagrams (left to mermaid.js unless MERMAID_CLI is set)
+	diagramsService := diagrams.New(diagrams.NewCLIRenderer(), repos.Diagram, queue)
+	newsletterService.SetDiagrams(diagramsService)
+
+	// Initialize link embeds from oEmbed providers
+	embedRegistry := embeds.NewRegistry(embeds.DefaultProviders...)
+	embedsService := embeds.New(embedRegistry, repos.Embed, queue)
+	newsletterService.SetEmbeds(embedsService)
+
+	// Initialize Open Graph previews for the other bare links
+	previewsService := previews.New(repos.LinkPreview, queue)
+	previewsService.SetSkip(embedRegistry.Handles)
+	newsletterService.SetPreviews(previewsService)
+
+	// Initialize Webmentions, received and sent for posts
+	webmentionsService := webmentions.New(repos, queue, baseDomain)
+
+	// Initialize ActivityPub, so blogs can be followed from the fediverse
+	activityPubService := activitypub.New(repos.ActivityPub, repos, queue, baseDomain)
+
+	// Initialize reader comments, emailed to blog owners for moderation
+	commentsService := comments.New(repos.Comment, repos, mail, queue, registry, baseDomain)
+
+	// Initialize blog export and import
+	archiveService := archive.New(repos, mediaService)
+
+	// Initialize Echo
+	e := echo.New()
+	e.HideBanner = true
+	e.Renderer = registry
+
+	// Middleware
+	e.Use(logging.RequestLogger(logger))
+	e.Use(echomiddleware.Recover())
+	e.Use(echomiddleware.RemoveTrailingSlash())
+	e.Use(echomiddleware.CORS())
+	e.Use(echomiddleware.Gzip())
+	e.Use(echomiddleware.Secure())
+	// Make logger available in context
+	e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set("logger", logger)
+			return next(c)
+		}
+	})
+
+	// Static files and OpenMoji icons, embedded in the binary
+	assets.Register(e)
+
+	// Initialize handlers
+	blogH := bloghandlers.New(repos, authService, baseDomain)
+	dashboardH := dashboardhandlers.New(repos, authService, baseDomain)
+	sharedH := sharedhandlers.New(repos, authService, baseDomain)
+
+	// Set home handler for blog (so BlogIndex can call it when on root domain)
+	blogH.SetHomeHandler(sharedH.HomePage)
+
+	// Email subscriptions
+	blogH.SetNewsletter(newsletterService)
+	dashboardH.SetNewsletter(newsletterService)
+
+	// Uploaded media
+	blogH.SetMedia(mediaService)
+	dashboardH.SetMedia(mediaService)
+
+	// Mermaid diagrams rendered when posts are saved
+	blogH.SetDiagrams(diagramsService)
+	dashboardH.SetDiagrams(diagramsService)
+
+	// Link embeds fetched when posts are saved
+	blogH.SetEmbeds(embedsService)
+	dashboardH.SetEmbeds(embedsService)
+
+	// Link previews fetched when posts are saved
+	blogH.SetPreviews(previewsService)
+	dashboardH.SetPreviews(previewsService)
+
+	// Webmentions received on posts and sent when they are published
+	blogH.SetWebmentions(webmentionsService)
+	dashboardH.SetWebmentions(webmentionsService)
+
+	// ActivityPub actors followed from the fediverse
+	blogH.SetActivityPub(activityPubService)
+	dashboardH.SetActivityPub(activityPubService)
+
+	// Reader comments on posts
+	blogH.SetComments(commentsService)
+
+	// Password and members-only posts, and draft preview links (the access
+	// cookie is HTTPS-only unless COOKIE_SECURE=false)
+	accessService := access.New(sessionSecret, os.Getenv("COOKIE_SECURE") != "false")
+	accessService.SetAttempts(repos.UnlockAttempt)
+	newsletterService.SetAccess(accessService)
+	blogH.SetAccess(accessService)
+	dashboardH.SetAccess(accessService)
+
+	// Blog export and import
+	dashboardH.SetArchive(archiveService)
+	dashboardH.SetViews(registry)
+
+	// Auth routes (no blog middleware needed)
+	e.GET("/login", sharedH.LoginPage)
+	e.POST("/login", sharedH.LoginSubmit)
+	e.POST("/logout", sharedH.Logout)
+	e.GET("/logout", sharedH.Logout)
+
+	// Public pages
+	e.GET("/docs", sharedH.DocsPage)
+	e.GET("/terms", sharedH.TermsPage)
+
+	// Code highlighting stylesheets, linked from blog pages and previews
+	e.GET("/highlight/:file", sharedH.HighlightCSS)
+
+	// Dashboard routes (protected)
+	dashboard := e.Group("/dashboard")
+	dashboard.Use(authService.RequireAuth)
+	dashboard.GET("", dashboardH.Dashboard)
+	dashboard.GET("/", dashboardH.Dashboard)
+	dashboard.POST("/blogs", dashboardH.CreateBlog)
+	dashboard.GET("/blogs/:subdomain/posts", dashboardH.BlogPosts)
+	dashboard.POST("/blogs/:subdomain/posts/untitled", dashboardH.CreateUntitledPost)
+	dashboard.GET("/blogs/:subdomain/posts/:post_id/edit", dashboardH.EditPost)
+	dashboard.GET("/blogs/:subdomain/posts/:post_id/preview", dashboardH.PreviewPost)
+	dashboard.POST("/blogs/:subdomain/posts/:post_id", dashboardH.UpdatePost)
+	dashboard.PUT("/blogs/:subdomain/posts/:post_id", dashboardH.UpdatePost)
+	dashboard.POST("/blogs/:subdomain/posts/:post_id/delete", dashboardH.DeletePost)
+	dashboard.POST("/blogs/:subdomain/posts/:post_id/preview_links", dashboardH.CreatePreviewLink)
+	dashboard.POST("/blogs/:subdomain/posts/:post_id/preview_links/:link_id/delete", dashboardH.DeletePreviewLink)
+	dashboard.POST("/blogs/:subdomain/uploads", dashboardH.UploadMedia)
+	dashboard.GET("/blogs/:subdomain/settings", dashboardH.BlogSettings)
+	dashboard.POST("/blogs/:subdomain/settings", dashboardH.UpdateBlogSettings)
+	dashboard.POST("/blogs/:subdomain/settings/favicon", dashboardH.UpdateFaviconEmoji)
+	dashboard.POST("/blogs/:subdomain/settings/theme", dashboardH.UpdateThemeSettings)
+	dashboard.POST("/blogs/:subdomain/settings/theme/preview", dashboardH.PreviewTheme)
+	dashboard.POST("/blogs/:subdomain/settings/markdown", dashboardH.UpdateMarkdownSettings)
+	dashboard.POST("/blogs/:subdomain/settings/about", dashboardH.UpdateAboutPage)
+	dashboard.POST("/blogs/:subdomain/settings/about/delete", dashboardH.DeleteAboutPage)
+	dashboard.GET("/blogs/:subdomain/export.zip", dashboardH.ExportBlog)
+	dashboard.POST("/blogs/:subdomain/import", dashboardH.ImportBlog)
+	dashboard.POST("/blogs/:subdomain/delete", dashboardH.DeleteBlog)
+	dashboard.GET("/blogs/:subdomain/tags", dashboardH.DashboardTagsIndex)
+	dashboard.PATCH("/blogs/:subdomain/tags/:tag_id", dashboardH.UpdateTag)
+	dashboard.PUT("/blogs/:subdomain/tags/:tag_id", dashboardH.UpdateTag)
+	dashboard.DELETE("/blogs/:subdomain/tags/:tag_id", dashboardH.DeleteTag)
+	dashboard.GET("/blogs/:subdomain/subscribers", dashboardH.Subscribers)
+	dashboard.GET("/blogs/:subdomain/subscribers.csv", dashboardH.ExportSubscribers)
+	dashboard.GET("/blogs/:subdomain/jobs", dashboardH.FailedJobs)
+	dashboard.POST("/blogs/:subdomain/jobs/:job_id/retry", dashboardH.RetryJob)
+	dashboard.POST("/blogs/:subdomain/jobs/:job_id/delete", dashboardH.DeleteJob)
+	dashboard.GET("/blogs/:subdomain/webmentions", dashboardH.Webmentions)
+	dashboard.POST("/blogs/:subdomain/webmentions/:webmention_id/approve", dashboardH.ApproveWebmention)
+	dashboard.POST("/blogs/:subdomain/webmentions/:webmention_id/reject", dashboardH.RejectWebmention)
+	dashboard.POST("/blogs/:subdomain/webmentions/:webmention_id/delete", dashboardH.DeleteWebmention)
+	dashboard.GET("/blogs/:subdomain/comments", dashboardH.Comments)
+	dashboard.POST("/blogs/:subdomain/comments/:comment_id/approve", dashboardH.ApproveComment)
+	dashboard.POST("/blogs/:subdomain/comments/:comment_id/delete", dashboardH.DeleteComment)
+	dashboard.POST("/blogs/:subdomain/comments/:comment_id/ban", dashboardH.BanComment)
+	dashboard.GET("/security", dashboardH.Security)
+	dashboard.POST("/security/profile", dashboardH.UpdateProfile)
+	dashboard.POST("/security/password", dashboardH.UpdateSecurityPassword)
+	dashboard.GET("/tokens", dashboardH.GetTokens)
+	dashboard.POST("/tokens", dashboardH.CreateToken)
+	dashboard.POST("/tokens/:id/delete", dashboardH.DeleteToken)
+
+	// Public blog routes (with multi-tenant middleware)
+	blog := e.Group("")
+	blog.Use(blogmiddleware.BlogResolver(repos.Blog, baseDomain))
+	blog.GET("/", blogH.BlogIndex)
+	blog.GET("/feed.rss", blogH.RSSFeed)
+	blog.GET("/feed.atom", blogH.AtomFeed)
+	blog.GET("/feed.json", blogH.JSONFeed)
+	blog.GET("/subscribe", blogH.Subscribe)
+	blog.POST("/subscribe", blogH.SubscribeEmail)
+	blog.GET("/subscribe/confirm", blogH.ConfirmSubscription)
+	blog.GET("/unsubscribe", blogH.UnsubscribeForm)
+	blog.POST("/unsubscribe", blogH.Unsubscribe)
+	blog.POST("/members/signin", blogH.RequestMemberLink)
+	blog.GET("/members/signin/confirm", blogH.ConfirmMemberLink)
+	blog.POST("/webmention", blogH.ReceiveWebmention)
+	blog.GET("/.well-known/webfinger", blogH.WebFinger)
+	blog.GET("/actor", blogH.Actor)
+	blog.GET("/outbox", blogH.Outbox)
+	blog.GET("/followers", blogH.Followers)
+	blog.POST("/inbox", blogH.Inbox)
+	blog.GET("/sitemap.xml", blogH.Sitemap)
+	blog.GET("/robots.txt", blogH.RobotsTxt)
+	blog.GET("/theme.css", blogH.ThemeCSS)
+	blog.GET("/media/:key", blogH.MediaShow)
+	blog.GET("/media/:key/:variant", blogH.MediaVariant)
+	blog.GET("/diagrams/:file", blogH.DiagramShow)
+	blog.GET("/preview/:token", blogH.PreviewShow)
+	blog.GET("/tags", blogH.TagsIndex)
+	blog.GET("/tags/:tag_slug", blogH.TagShow)
+	blog.GET("/series/:series_slug", blogH.SeriesShow)
+	blog.GET("/series/:series_slug/feed.atom", blogH.SeriesFeed)
+	blog.GET("/archive", blogH.ArchiveIndex)
+	blog.POST("/:slug/unlock", blogH.UnlockPost)
+	blog.POST("/:slug/comments", blogH.SubmitComment)
+	blog.GET("/:slug/comments.atom", blogH.CommentsFeed)
+	// /:year is served by PostShow, which can't tell it from a slug by route
+	blog.GET("/:year/:month", blogH.MonthArchive)
+	blog.GET("/:slug", blogH.PostShow)
+
+	// Health check
+	e.GET("/health", func(c echo.Context) error {
+		return c.JSON(http.StatusOK, map[string]string{"status": "ok"})
+	})
+
+	// Start job workers once every job kind is registered
+	queue.Start()
+
+	// Start server with graceful shutdown
+	go func() {
+		addr := fmt.Sprintf(":%s", port)
+		logger.Info("Starting server", "address", addr)
+		if err := e.Start(addr); err != nil && err != http.ErrServerClosed {
+			logger.Error("Server failed to start", "error", err)
+			log.Fatalf("Server failed to start: %v", err)
+		}
+	}()
+
+	// Wait for interrupt signal
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt)
+	<-quit
+
+	logger.Info("Shutting down server...")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := e.Shutdown(ctx); err != nil {
+		logger.Error("Server forced to shutdown", "error", err)
+		log.Fatalf("Server forced to shutdown: %v", err)
+	}
+
+	// Let running jobs finish; anything left over is retried after restart
+	jobsCtx, jobsCancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer jobsCancel()
+	if err := queue.Shutdown(jobsCtx); err != nil {
+		logger.Error("Job workers did not drain", "error", err)
+	}
+
+	logger.Info("Server exited")
+}
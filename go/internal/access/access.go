@@ -0,0 +1,257 @@
+// Package access decides who may read protected posts. Readers unlock
+// password posts by entering the password, and sign in as a blog's
+// members, its confirmed email subscribers, through a link emailed to them.
+// Both are remembered in a signed cookie on the blog's host. The package also
+// signs the expiring links that share drafts with reviewers.
+package access
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cassiascheffer/willow_camp/internal/models"
+	"github.com/google/uuid"
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	cookieName   = "willow_camp_access"
+	postPrefix   = "post:"
+	memberPrefix = "member:"
+	memberLink   = "member_link"
+	previewLink  = "preview_link"
+)
+
+// MemberLinkTTL is how long an emailed member sign-in link works
+const MemberLinkTTL = 30 * time.Minute
+
+// Each address may enter UnlockLimit wrong passwords on a blog per UnlockWindow
+const (
+	UnlockLimit  = 10
+	UnlockWindow = 15 * time.Minute
+)
+
+var (
+	// ErrInvalidLink is returned for signed links that were tampered with or
+	// have expired
+	ErrInvalidLink = errors.New("invalid or expired link")
+	// ErrWrongPassword is returned for a password that doesn't open a post
+	ErrWrongPassword = errors.New("wrong password")
+	// ErrTooManyAttempts is returned when an address has entered too many
+	// wrong passwords
+	ErrTooManyAttempts = errors.New("too many password attempts")
+)
+
+// AttemptStore counts the wrong passwords entered for a blog's posts
+type AttemptStore interface {
+	CountRecentByIP(ctx context.Context, blogID uuid.UUID, ipHash string, since time.Time) (int, error)
+	Record(ctx context.Context, blogID, postID uuid.UUID, ipHash string, prune time.Time) error
+}
+
+// Service remembers the posts a reader unlocked and the blogs they're a
+// member of, and signs the links that grant access
+type Service struct {
+	store    *sessions.CookieStore
+	links    *securecookie.SecureCookie
+	attempts AttemptStore
+}
+
+// New creates a new access Service signing with the given secret. Secure
+// cookies are only sent over HTTPS.
+func New(secret string, secure bool) *Service {
+	store := sessions.NewCookieStore([]byte(secret))
+	store.Options = &sessions.Options{
+		Path:     "/",
+		MaxAge:   86400 * 30, // 30 days
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: http.SameSiteLaxMode,
+	}
+
+	// Links get their own key, so a link can never pass for a cookie
+	linkKey := sha256.Sum256([]byte("links|" + secret))
+	links := securecookie.New(linkKey[:], nil)
+	// Links carry their own expiry
+	links.MaxAge(0)
+
+	return &Service{store: store, links: links}
+}
+
+// SetAttempts enables throttling password guesses, counting wrong passwords
+// in store
+func (s *Service) SetAttempts(store AttemptStore) {
+	s.attempts = store
+}
+
+// HashPassword hashes a post password for storing
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// CheckPassword reports whether a password opens a post
+func CheckPassword(post *models.Post, password string) bool {
+	if post.PasswordDigest == nil || *post.PasswordDigest == "" || password == "" {
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(*post.PasswordDigest), []byte(password)) == nil
+}
+
+// TryPassword checks a password for a post entered from an address (see
+// comments.HashIP). Once the address has entered UnlockLimit wrong passwords
+// on the blog within UnlockWindow, every password is refused until the
+// window passes.
+func (s *Service) TryPassword(ctx context.Context, post *models.Post, ipHash, password string) error {
+	if s.attempts != nil {
+		recent, err := s.attempts.CountRecentByIP(ctx, post.BlogID, ipHash, time.Now().Add(-UnlockWindow))
+		if err != nil {
+			return err
+		}
+		if recent >= UnlockLimit {
+			return ErrTooManyAttempts
+		}
+	}
+
+	if CheckPassword(post, password) {
+		return nil
+	}
+	if s.attempts != nil {
+		if err := s.attempts.Record(ctx, post.BlogID, post.ID, ipHash, time.Now().Add(-UnlockWindow)); err != nil {
+			return err
+		}
+	}
+	return ErrWrongPassword
+}
+
+// Unlocked reports whether the reader unlocked a password post. Changing
+// the post's password locks it again.
+func (s *Service) Unlocked(c echo.Context, post *models.Post) bool {
+	if post.PasswordDigest == nil {
+		return false
+	}
+	session, err := s.store.Get(c.Request(), cookieName)
+	if err != nil {
+		return false
+	}
+	value, _ := session.Values[postPrefix+post.ID.String()].(string)
+	return value != "" && value == fingerprint(*post.PasswordDigest)
+}
+
+// Unlock remembers that the reader entered a password post's password
+func (s *Service) Unlock(c echo.Context, post *models.Post) error {
+	if post.PasswordDigest == nil {
+		return errors.New("post has no password")
+	}
+	session, _ := s.store.Get(c.Request(), cookieName)
+	session.Values[postPrefix+post.ID.String()] = fingerprint(*post.PasswordDigest)
+	return session.Save(c.Request(), c.Response())
+}
+
+// MemberID returns the subscriber the reader signed in as on a blog
+func (s *Service) MemberID(c echo.Context, blog *models.Blog) (uuid.UUID, bool) {
+	session, err := s.store.Get(c.Request(), cookieName)
+	if err != nil {
+		return uuid.Nil, false
+	}
+	value, _ := session.Values[memberPrefix+blog.ID.String()].(string)
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// SignInMember remembers the reader as one of a blog's subscribers
+func (s *Service) SignInMember(c echo.Context, blog *models.Blog, subscriberID uuid.UUID) error {
+	session, _ := s.store.Get(c.Request(), cookieName)
+	session.Values[memberPrefix+blog.ID.String()] = subscriberID.String()
+	return session.Save(c.Request(), c.Response())
+}
+
+// signedLink is what a signed link's token holds
+type signedLink struct {
+	Value   string
+	Expires int64
+}
+
+// SignLink signs a value into a token for a link that works until expires.
+// The name scopes the token, so one made for one kind of link can't be used
+// for another.
+func (s *Service) SignLink(name, value string, expires time.Time) (string, error) {
+	return s.links.Encode(name, signedLink{Value: value, Expires: expires.Unix()})
+}
+
+// VerifyLink returns the value signed into a link's token
+func (s *Service) VerifyLink(name, token string) (string, error) {
+	var link signedLink
+	if err := s.links.Decode(name, token, &link); err != nil {
+		return "", ErrInvalidLink
+	}
+	if time.Now().Unix() > link.Expires {
+		return "", ErrInvalidLink
+	}
+	return link.Value, nil
+}
+
+// MemberToken signs a token for a link signing a subscriber in as a member
+// of their blog
+func (s *Service) MemberToken(subscriber *models.EmailSubscriber) (string, error) {
+	value := subscriber.BlogID.String() + "|" + subscriber.ID.String()
+	return s.SignLink(memberLink, value, time.Now().Add(MemberLinkTTL))
+}
+
+// VerifyMemberToken returns the blog and subscriber a member sign-in token
+// was made for
+func (s *Service) VerifyMemberToken(token string) (blogID, subscriberID uuid.UUID, err error) {
+	value, err := s.VerifyLink(memberLink, token)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+	blogPart, subscriberPart, ok := strings.Cut(value, "|")
+	if !ok {
+		return uuid.Nil, uuid.Nil, ErrInvalidLink
+	}
+	if blogID, err = uuid.Parse(blogPart); err != nil {
+		return uuid.Nil, uuid.Nil, ErrInvalidLink
+	}
+	if subscriberID, err = uuid.Parse(subscriberPart); err != nil {
+		return uuid.Nil, uuid.Nil, ErrInvalidLink
+	}
+	return blogID, subscriberID, nil
+}
+
+// PreviewToken signs the token for a preview link's URL
+func (s *Service) PreviewToken(link *models.PreviewLink) (string, error) {
+	return s.SignLink(previewLink, link.ID.String(), link.ExpiresAt)
+}
+
+// VerifyPreviewToken returns the ID of the preview link a token was made for.
+// The link may have been revoked since, so it still has to be looked up.
+func (s *Service) VerifyPreviewToken(token string) (uuid.UUID, error) {
+	value, err := s.VerifyLink(previewLink, token)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return uuid.Nil, ErrInvalidLink
+	}
+	return id, nil
+}
+
+// fingerprint identifies a password digest without putting it in a cookie
+func fingerprint(digest string) string {
+	sum := sha256.Sum256([]byte(digest))
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,465 @@
+// Package activitypub makes each blog an ActivityPub actor that can be
+// followed from Mastodon and the rest of the fediverse. Blogs are found
+// through WebFinger, accept Follow and Undo activities in a signed inbox, and
+// deliver a Create activity for each newly published post to their
+// followers through the job queue. See https://www.w3.org/TR/activitypub/.
+package activitypub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/cassiascheffer/willow_camp/internal/helpers"
+	"github.com/cassiascheffer/willow_camp/internal/jobs"
+	"github.com/cassiascheffer/willow_camp/internal/markdown"
+	"github.com/cassiascheffer/willow_camp/internal/models"
+	"github.com/cassiascheffer/willow_camp/internal/previews"
+	"github.com/cassiascheffer/willow_camp/internal/repository"
+	"github.com/google/uuid"
+)
+
+// Job kinds handled by the service
+const (
+	JobPublish = "activitypub.publish"
+	JobDeliver = "activitypub.deliver"
+)
+
+// ContentType is the media type of ActivityPub documents
+const ContentType = "application/activity+json"
+
+// Public addresses an activity to everyone
+const Public = "https://www.w3.org/ns/activitystreams#Public"
+
+// ActivityStreams is the JSON-LD context of ActivityPub documents
+const ActivityStreams = "https://www.w3.org/ns/activitystreams"
+
+const securityContext = "https://w3id.org/security/v1"
+
+// fetchTimeout bounds each request to another server, redirects included
+const fetchTimeout = 10 * time.Second
+
+// maxDocumentBytes is how much of a remote document is read
+const maxDocumentBytes = 1 << 20
+
+// userAgent identifies requests made for ActivityPub
+const userAgent = "willow.camp ActivityPub"
+
+// ErrUnknownResource is returned for WebFinger lookups of other accounts
+var ErrUnknownResource = errors.New("unknown webfinger resource")
+
+// Store holds blogs' keys and followers
+type Store interface {
+	FindKey(ctx context.Context, blogID uuid.UUID) (*models.ActorKey, error)
+	CreateKey(ctx context.Context, key *models.ActorKey) (*models.ActorKey, error)
+	AddFollower(ctx context.Context, follower *models.Follower) error
+	RemoveFollower(ctx context.Context, blogID uuid.UUID, actorID string) error
+	ListFollowers(ctx context.Context, blogID uuid.UUID) ([]*models.Follower, error)
+	CountFollowers(ctx context.Context, blogID uuid.UUID) (int, error)
+	ClaimPostDelivery(ctx context.Context, postID uuid.UUID, deliveries []*models.Job) (bool, error)
+}
+
+// Service publishes blogs as ActivityPub actors
+type Service struct {
+	store      Store
+	repos      *repository.Repositories
+	queue      *jobs.Queue
+	baseDomain string
+	client     *http.Client
+}
+
+// New creates a new activitypub Service and registers its jobs on the queue
+func New(store Store, repos *repository.Repositories, queue *jobs.Queue, baseDomain string) *Service {
+	s := &Service{
+		store:      store,
+		repos:      repos,
+		queue:      queue,
+		baseDomain: baseDomain,
+		client:     previews.NewClient(fetchTimeout),
+	}
+	if queue != nil {
+		queue.Register(JobPublish, s.runPublish)
+		queue.Register(JobDeliver, s.runDeliver)
+	}
+	return s
+}
+
+// SetClient replaces the HTTP client used to fetch actors and deliver
+// activities. The default one refuses private addresses; a replacement
+// should too outside of tests.
+func (s *Service) SetClient(client *http.Client) {
+	s.client = client
+}
+
+// Username is the name a blog is followed by, as in @username@host
+func Username(blog *models.Blog) string {
+	if blog.Subdomain != nil && *blog.Subdomain != "" {
+		return *blog.Subdomain
+	}
+	return "blog"
+}
+
+// ActorURL returns the ID of a blog's actor
+func (s *Service) ActorURL(blog *models.Blog) string {
+	return helpers.BlogBaseURL(blog, s.baseDomain) + "/actor"
+}
+
+// Handle returns the address a blog is followed by, e.g. @cassia@cassia.willow.camp
+func (s *Service) Handle(blog *models.Blog) string {
+	return "@" + Username(blog) + "@" + s.host(blog)
+}
+
+func (s *Service) host(blog *models.Blog) string {
+	u, err := url.Parse(helpers.BlogBaseURL(blog, s.baseDomain))
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+// Key returns a blog's key pair, generating one the first time
+func (s *Service) Key(ctx context.Context, blog *models.Blog) (*models.ActorKey, error) {
+	key, err := s.store.FindKey(ctx, blog.ID)
+	if !errors.Is(err, repository.ErrActorKeyNotFound) {
+		return key, err
+	}
+	public, private, err := GenerateKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate actor key: %w", err)
+	}
+	return s.store.CreateKey(ctx, &models.ActorKey{BlogID: blog.ID, PublicKeyPEM: public, PrivateKeyPEM: private})
+}
+
+// Link is a link in a WebFinger response
+type Link struct {
+	Rel  string `json:"rel"`
+	Type string `json:"type,omitempty"`
+	Href string `json:"href"`
+}
+
+// JRD is a WebFinger response
+type JRD struct {
+	Subject string   `json:"subject"`
+	Aliases []string `json:"aliases,omitempty"`
+	Links   []Link   `json:"links"`
+}
+
+// WebFinger answers a lookup for a blog by acct: address or actor URL
+func (s *Service) WebFinger(blog *models.Blog, resource string) (*JRD, error) {
+	baseURL := helpers.BlogBaseURL(blog, s.baseDomain)
+	actorURL := s.ActorURL(blog)
+	if resource != actorURL && resource != baseURL {
+		user, host, ok := strings.Cut(strings.TrimPrefix(resource, "acct:"), "@")
+		if !ok || !strings.HasPrefix(resource, "acct:") || !strings.EqualFold(user, Username(blog)) || !strings.EqualFold(host, s.host(blog)) {
+			return nil, ErrUnknownResource
+		}
+	}
+
+	return &JRD{
+		Subject: "acct:" + Username(blog) + "@" + s.host(blog),
+		Aliases: []string{actorURL, baseURL},
+		Links: []Link{
+			{Rel: "self", Type: ContentType, Href: actorURL},
+			{Rel: "http://webfinger.net/rel/profile-page", Type: "text/html", Href: baseURL},
+		},
+	}, nil
+}
+
+// PublicKey is an actor's key for checking its signatures
+type PublicKey struct {
+	ID           string `json:"id"`
+	Owner        string `json:"owner"`
+	PublicKeyPEM string `json:"publicKeyPem"`
+}
+
+// Actor is a blog's actor document
+type Actor struct {
+	Context                   []string  `json:"@context"`
+	ID                        string    `json:"id"`
+	Type                      string    `json:"type"`
+	PreferredUsername         string    `json:"preferredUsername"`
+	Name                      string    `json:"name"`
+	Summary                   string    `json:"summary,omitempty"`
+	URL                       string    `json:"url"`
+	Inbox                     string    `json:"inbox"`
+	Outbox                    string    `json:"outbox"`
+	Followers                 string    `json:"followers"`
+	ManuallyApprovesFollowers bool      `json:"manuallyApprovesFollowers"`
+	Discoverable              bool      `json:"discoverable"`
+	PublicKey                 PublicKey `json:"publicKey"`
+}
+
+// Actor returns a blog's actor document
+func (s *Service) Actor(ctx context.Context, blog *models.Blog) (*Actor, error) {
+	key, err := s.Key(ctx, blog)
+	if err != nil {
+		return nil, err
+	}
+	baseURL := helpers.BlogBaseURL(blog, s.baseDomain)
+	actorURL := s.ActorURL(blog)
+
+	name := Username(blog)
+	if blog.Title != nil && *blog.Title != "" {
+		name = *blog.Title
+	}
+	var summary string
+	if blog.MetaDescription != nil {
+		summary = *blog.MetaDescription
+	}
+
+	return &Actor{
+		Context:           []string{ActivityStreams, securityContext},
+		ID:                actorURL,
+		Type:              "Person",
+		PreferredUsername: Username(blog),
+		Name:              name,
+		Summary:           summary,
+		URL:               baseURL,
+		Inbox:             baseURL + "/inbox",
+		Outbox:            baseURL + "/outbox",
+		Followers:         baseURL + "/followers",
+		Discoverable:      !blog.NoIndex,
+		PublicKey: PublicKey{
+			ID:           actorURL + "#main-key",
+			Owner:        actorURL,
+			PublicKeyPEM: key.PublicKeyPEM,
+		},
+	}, nil
+}
+
+// Object is an ActivityStreams object, here the Article for a post
+type Object struct {
+	Context      string   `json:"@context,omitempty"`
+	ID           string   `json:"id"`
+	Type         string   `json:"type"`
+	AttributedTo string   `json:"attributedTo"`
+	Name         string   `json:"name,omitempty"`
+	Content      string   `json:"content"`
+	URL          string   `json:"url"`
+	Published    string   `json:"published,omitempty"`
+	To           []string `json:"to"`
+	Cc           []string `json:"cc,omitempty"`
+}
+
+// Activity is an ActivityStreams activity such as Create or Accept
+type Activity struct {
+	Context   string      `json:"@context,omitempty"`
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	Actor     string      `json:"actor"`
+	Published string      `json:"published,omitempty"`
+	To        []string    `json:"to,omitempty"`
+	Cc        []string    `json:"cc,omitempty"`
+	Object    interface{} `json:"object"`
+}
+
+// Article returns the Article for a published post, addressed to everyone
+// and the blog's followers
+func (s *Service) Article(blog *models.Blog, post *models.Post) (*Object, error) {
+	if post.Slug == nil {
+		return nil, errors.New("post has no slug")
+	}
+	baseURL := helpers.BlogBaseURL(blog, s.baseDomain)
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid blog URL: %w", err)
+	}
+	postURL := baseURL + "/" + *post.Slug
+
+	var content string
+	if post.BodyMarkdown != nil {
+		rendered, err := markdown.For(blog.MarkdownOptions).Render(*post.BodyMarkdown)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render post: %w", err)
+		}
+		content = helpers.SanitizeHTMLForFeed(string(rendered), base.Scheme, base.Host, 0, "/"+*post.Slug)
+	}
+
+	article := &Object{
+		ID:           postURL,
+		Type:         "Article",
+		AttributedTo: s.ActorURL(blog),
+		Content:      content,
+		URL:          postURL,
+		To:           []string{Public},
+		Cc:           []string{baseURL + "/followers"},
+	}
+	if post.Title != nil {
+		article.Name = *post.Title
+	}
+	if post.PublishedAt != nil {
+		article.Published = post.PublishedAt.UTC().Format(time.RFC3339)
+	}
+	return article, nil
+}
+
+// Create returns the activity announcing a published post
+func (s *Service) Create(blog *models.Blog, post *models.Post) (*Activity, error) {
+	article, err := s.Article(blog, post)
+	if err != nil {
+		return nil, err
+	}
+	return &Activity{
+		ID:        article.ID + "#create",
+		Type:      "Create",
+		Actor:     article.AttributedTo,
+		Published: article.Published,
+		To:        article.To,
+		Cc:        article.Cc,
+		Object:    article,
+	}, nil
+}
+
+// OrderedCollection is an ActivityStreams collection, such as an outbox
+type OrderedCollection struct {
+	Context      string        `json:"@context"`
+	ID           string        `json:"id"`
+	Type         string        `json:"type"`
+	TotalItems   int           `json:"totalItems"`
+	OrderedItems []interface{} `json:"orderedItems,omitempty"`
+}
+
+// Outbox returns a blog's outbox with a Create activity for each of the
+// given posts, newest first. Posts that can't be rendered are left out.
+func (s *Service) Outbox(blog *models.Blog, posts []*models.Post, total int) *OrderedCollection {
+	outbox := &OrderedCollection{
+		Context:      ActivityStreams,
+		ID:           helpers.BlogBaseURL(blog, s.baseDomain) + "/outbox",
+		Type:         "OrderedCollection",
+		TotalItems:   total,
+		OrderedItems: []interface{}{},
+	}
+	for _, post := range posts {
+		if create, err := s.Create(blog, post); err == nil {
+			outbox.OrderedItems = append(outbox.OrderedItems, create)
+		}
+	}
+	return outbox
+}
+
+// Followers returns the size of a blog's followers collection. Who follows
+// a blog isn't listed.
+func (s *Service) Followers(ctx context.Context, blog *models.Blog) (*OrderedCollection, error) {
+	count, err := s.store.CountFollowers(ctx, blog.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &OrderedCollection{
+		Context:    ActivityStreams,
+		ID:         helpers.BlogBaseURL(blog, s.baseDomain) + "/followers",
+		Type:       "OrderedCollection",
+		TotalItems: count,
+	}, nil
+}
+
+type publishPayload struct {
+	PostID uuid.UUID `json:"post_id"`
+}
+
+type deliverPayload struct {
+	BlogID   uuid.UUID       `json:"blog_id"`
+	Inbox    string          `json:"inbox"`
+	Activity json.RawMessage `json:"activity"`
+}
+
+// QueuePost schedules delivering a newly published post to followers
+func (s *Service) QueuePost(ctx context.Context, blog *models.Blog, post *models.Post) error {
+	if s.queue == nil {
+		return nil
+	}
+	_, err := s.queue.Enqueue(ctx, JobPublish, publishPayload{PostID: post.ID}, jobs.ForBlog(blog.ID))
+	return err
+}
+
+// runPublish fans a published post out into one delivery job per inbox,
+// using shared inboxes so each server gets the post once. Each post is
+// announced at most once, even if it is unpublished and published again.
+func (s *Service) runPublish(ctx context.Context, job *models.Job) error {
+	var payload publishPayload
+	if err := job.DecodePayload(&payload); err != nil {
+		return jobs.Permanent(err)
+	}
+
+	post, err := s.repos.Post.FindByID(ctx, payload.PostID)
+	if err != nil {
+		return err
+	}
+	// Only posts anyone can read are sent out; unlisted and protected ones stay on the blog
+	if post.IsPage() || !post.IsPublic() || post.Slug == nil {
+		return nil
+	}
+	blog, err := s.repos.Blog.FindByID(ctx, post.BlogID)
+	if err != nil {
+		return err
+	}
+
+	create, err := s.Create(blog, post)
+	if err != nil {
+		return jobs.Permanent(err)
+	}
+	activity, err := json.Marshal(create)
+	if err != nil {
+		return jobs.Permanent(err)
+	}
+
+	followers, err := s.store.ListFollowers(ctx, blog.ID)
+	if err != nil {
+		return err
+	}
+	var deliveries []*models.Job
+	inboxes := map[string]bool{}
+	for _, follower := range followers {
+		inbox := follower.DeliveryInbox()
+		if inboxes[inbox] {
+			continue
+		}
+		inboxes[inbox] = true
+		payload := deliverPayload{BlogID: blog.ID, Inbox: inbox, Activity: activity}
+		job, err := s.queue.Build(JobDeliver, payload, jobs.ForBlog(blog.ID))
+		if err != nil {
+			return jobs.Permanent(err)
+		}
+		deliveries = append(deliveries, job)
+	}
+
+	// The claim and the delivery jobs are stored together, so a retry after
+	// a failure here reaches every inbox rather than none
+	claimed, err := s.store.ClaimPostDelivery(ctx, post.ID, deliveries)
+	if err != nil {
+		return err
+	}
+	if claimed {
+		s.queue.Notify()
+	}
+	return nil
+}
+
+func (s *Service) enqueueDelivery(ctx context.Context, blog *models.Blog, inbox string, activity []byte) error {
+	payload := deliverPayload{BlogID: blog.ID, Inbox: inbox, Activity: activity}
+	_, err := s.queue.Enqueue(ctx, JobDeliver, payload, jobs.ForBlog(blog.ID))
+	return err
+}
+
+// runDeliver posts an activity to one inbox. Inboxes that refuse it are
+// not retried; ones that are down are, with the queue's backoff.
+func (s *Service) runDeliver(ctx context.Context, job *models.Job) error {
+	var payload deliverPayload
+	if err := job.DecodePayload(&payload); err != nil {
+		return jobs.Permanent(err)
+	}
+	blog, err := s.repos.Blog.FindByID(ctx, payload.BlogID)
+	if err != nil {
+		return err
+	}
+
+	err = s.Deliver(ctx, blog, payload.Inbox, payload.Activity)
+	if errors.Is(err, ErrRejected) || errors.Is(err, previews.ErrBlockedAddress) {
+		return jobs.Permanent(err)
+	}
+	return err
+}
@@ -0,0 +1,221 @@
+package activitypub
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/cassiascheffer/willow_camp/internal/models"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrInvalidActivity is returned for inbox posts that aren't activities
+	// this blog can act on
+	ErrInvalidActivity = errors.New("invalid activity")
+	// ErrRejected is returned when an inbox refuses a delivery for good
+	ErrRejected = errors.New("activity rejected")
+)
+
+// acceptHeader asks servers for their ActivityPub documents
+const acceptHeader = `application/activity+json, application/ld+json; profile="https://www.w3.org/ns/activitystreams"`
+
+// incoming is the part of a received activity that is read
+type incoming struct {
+	ID     string          `json:"id"`
+	Type   string          `json:"type"`
+	Actor  string          `json:"actor"`
+	Object json.RawMessage `json:"object"`
+}
+
+// RemoteActor is the part of another server's actor document that is read
+type RemoteActor struct {
+	ID        string `json:"id"`
+	Inbox     string `json:"inbox"`
+	Endpoints struct {
+		SharedInbox string `json:"sharedInbox"`
+	} `json:"endpoints"`
+	PublicKey PublicKey `json:"publicKey"`
+}
+
+// HandleInbox acts on an activity posted to a blog's inbox. The request must
+// be signed by the activity's actor. Follows are accepted right away and
+// undone follows removed; other activities are ignored.
+func (s *Service) HandleInbox(ctx context.Context, blog *models.Blog, req *http.Request, body []byte) error {
+	var activity incoming
+	if err := json.Unmarshal(body, &activity); err != nil || activity.Type == "" || activity.Actor == "" {
+		return fmt.Errorf("%w: not an activity", ErrInvalidActivity)
+	}
+
+	var actor *RemoteActor
+	_, err := Verify(req, body, func(keyID string) (*rsa.PublicKey, error) {
+		var err error
+		actor, err = s.FetchActor(ctx, blog, activity.Actor)
+		if err != nil {
+			return nil, fmt.Errorf("%w: failed to fetch actor: %v", ErrBadSignature, err)
+		}
+		if actor.PublicKey.ID != keyID {
+			return nil, fmt.Errorf("%w: key %s does not belong to %s", ErrBadSignature, keyID, actor.ID)
+		}
+		return ParsePublicKey(actor.PublicKey.PublicKeyPEM)
+	})
+	if err != nil {
+		return err
+	}
+
+	switch activity.Type {
+	case "Follow":
+		if objectID(activity.Object) != s.ActorURL(blog) {
+			return fmt.Errorf("%w: follow is for another actor", ErrInvalidActivity)
+		}
+		return s.follow(ctx, blog, actor, body)
+	case "Undo":
+		var undone incoming
+		if err := json.Unmarshal(activity.Object, &undone); err != nil {
+			// Only embedded activities can be undone; follows aren't stored by ID
+			return nil
+		}
+		if undone.Type == "Follow" && undone.Actor == actor.ID && objectID(undone.Object) == s.ActorURL(blog) {
+			return s.store.RemoveFollower(ctx, blog.ID, actor.ID)
+		}
+	}
+	return nil
+}
+
+// follow records a new follower and sends them an Accept
+func (s *Service) follow(ctx context.Context, blog *models.Blog, actor *RemoteActor, followActivity []byte) error {
+	if actor.Inbox == "" {
+		return fmt.Errorf("%w: follower has no inbox", ErrInvalidActivity)
+	}
+	follower := &models.Follower{
+		BlogID:      blog.ID,
+		ActorID:     actor.ID,
+		Inbox:       actor.Inbox,
+		SharedInbox: actor.Endpoints.SharedInbox,
+	}
+	if err := s.store.AddFollower(ctx, follower); err != nil {
+		return err
+	}
+
+	accept, err := json.Marshal(Activity{
+		Context: ActivityStreams,
+		ID:      s.ActorURL(blog) + "#accepts/" + uuid.NewString(),
+		Type:    "Accept",
+		Actor:   s.ActorURL(blog),
+		Object:  json.RawMessage(followActivity),
+	})
+	if err != nil {
+		return err
+	}
+	if s.queue == nil {
+		return s.Deliver(ctx, blog, actor.Inbox, accept)
+	}
+	return s.enqueueDelivery(ctx, blog, actor.Inbox, accept)
+}
+
+// objectID returns the ID of an activity's object, given inline or as a link
+func objectID(raw json.RawMessage) string {
+	var id string
+	if json.Unmarshal(raw, &id) == nil {
+		return id
+	}
+	var object struct {
+		ID string `json:"id"`
+	}
+	if json.Unmarshal(raw, &object) == nil {
+		return object.ID
+	}
+	return ""
+}
+
+// FetchActor fetches another server's actor document, signed by the blog for
+// servers that only answer signed requests
+func (s *Service) FetchActor(ctx context.Context, blog *models.Blog, id string) (*RemoteActor, error) {
+	u, err := url.Parse(id)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("actor %q is not an http(s) URL", id)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, id, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", acceptHeader)
+	req.Header.Set("User-Agent", userAgent)
+	if err := s.sign(ctx, blog, req, nil); err != nil {
+		return nil, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("actor returned %s", resp.Status)
+	}
+
+	var actor RemoteActor
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxDocumentBytes)).Decode(&actor); err != nil {
+		return nil, fmt.Errorf("failed to read actor: %w", err)
+	}
+	// The document must be the actor that was asked for, not one it redirected to
+	if actor.ID != id {
+		return nil, fmt.Errorf("actor document has ID %q, expected %q", actor.ID, id)
+	}
+	return &actor, nil
+}
+
+// Deliver posts a signed activity to an inbox
+func (s *Service) Deliver(ctx context.Context, blog *models.Blog, inbox string, activity []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, inbox, bytes.NewReader(activity))
+	if err != nil {
+		return fmt.Errorf("%w: invalid inbox %q", ErrRejected, inbox)
+	}
+	req.Header.Set("Content-Type", ContentType)
+	req.Header.Set("Accept", acceptHeader)
+	req.Header.Set("User-Agent", userAgent)
+	if err := s.sign(ctx, blog, req, activity); err != nil {
+		return err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxDocumentBytes))
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode <= 299:
+		return nil
+	case resp.StatusCode >= 400 && resp.StatusCode <= 499 &&
+		resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests:
+		return fmt.Errorf("%w: inbox returned %s", ErrRejected, resp.Status)
+	default:
+		return fmt.Errorf("inbox returned %s", resp.Status)
+	}
+}
+
+// sign signs a request with the blog's key
+func (s *Service) sign(ctx context.Context, blog *models.Blog, req *http.Request, body []byte) error {
+	key, err := s.Key(ctx, blog)
+	if err != nil {
+		return err
+	}
+	private, err := ParsePrivateKey(key.PrivateKeyPEM)
+	if err != nil {
+		return fmt.Errorf("failed to read actor key: %w", err)
+	}
+	return Sign(req, s.ActorURL(blog)+"#main-key", private, body)
+}
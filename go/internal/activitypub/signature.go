@@ -0,0 +1,216 @@
+package activitypub
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrBadSignature is returned for requests without a valid HTTP signature
+var ErrBadSignature = errors.New("invalid http signature")
+
+// maxClockSkew is how far a signed request's Date may be from now
+const maxClockSkew = 12 * time.Hour
+
+// keyBits is the size of the RSA keys blogs sign with
+const keyBits = 2048
+
+// GenerateKey creates an RSA key pair, PEM encoded as PKIX and PKCS #8
+func GenerateKey() (publicPEM, privatePEM string, err error) {
+	key, err := rsa.GenerateKey(rand.Reader, keyBits)
+	if err != nil {
+		return "", "", err
+	}
+	public, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return "", "", err
+	}
+	private, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return "", "", err
+	}
+	publicPEM = string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: public}))
+	privatePEM = string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: private}))
+	return publicPEM, privatePEM, nil
+}
+
+// ParsePublicKey reads a PEM encoded RSA public key, as PKIX or PKCS #1
+func ParsePublicKey(data string) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, errors.New("no PEM data in public key")
+	}
+	if block.Type == "RSA PUBLIC KEY" {
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("public key is not an RSA key")
+	}
+	return rsaKey, nil
+}
+
+// ParsePrivateKey reads a PEM encoded RSA private key, as PKCS #8 or PKCS #1
+func ParsePrivateKey(data string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, errors.New("no PEM data in private key")
+	}
+	if block.Type == "RSA PRIVATE KEY" {
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("private key is not an RSA key")
+	}
+	return rsaKey, nil
+}
+
+// Sign adds an HTTP signature to req, the rsa-sha256 Cavage draft that
+// Mastodon and most of the fediverse use. Requests with a body also get a
+// Digest header, which the signature covers.
+func Sign(req *http.Request, keyID string, key *rsa.PrivateKey, body []byte) error {
+	if req.Header.Get("Date") == "" {
+		req.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	}
+	headers := []string{"(request-target)", "host", "date"}
+	if body != nil {
+		req.Header.Set("Digest", digest(body))
+		headers = append(headers, "digest")
+	}
+
+	hash := sha256.Sum256([]byte(signingString(req, headers)))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Signature", fmt.Sprintf(`keyId="%s",algorithm="rsa-sha256",headers="%s",signature="%s"`,
+		keyID, strings.Join(headers, " "), base64.StdEncoding.EncodeToString(signature)))
+	return nil
+}
+
+// Verify checks req's HTTP signature against the key lookup returns for the
+// signature's key ID, and returns that key ID. The signature must cover the
+// request target, host and a recent date, and the digest of any body.
+func Verify(req *http.Request, body []byte, lookup func(keyID string) (*rsa.PublicKey, error)) (string, error) {
+	params := signatureParams(req.Header.Get("Signature"))
+	keyID, signature := params["keyId"], params["signature"]
+	if keyID == "" || signature == "" {
+		return "", fmt.Errorf("%w: missing key ID or signature", ErrBadSignature)
+	}
+	if algorithm := params["algorithm"]; algorithm != "" && algorithm != "rsa-sha256" && algorithm != "hs2019" {
+		return "", fmt.Errorf("%w: unsupported algorithm %q", ErrBadSignature, algorithm)
+	}
+
+	headers := strings.Fields(strings.ToLower(params["headers"]))
+	if len(headers) == 0 {
+		headers = []string{"date"}
+	}
+	required := []string{"(request-target)", "host", "date"}
+	if len(body) > 0 {
+		required = append(required, "digest")
+	}
+	for _, name := range required {
+		if !contains(headers, name) {
+			return "", fmt.Errorf("%w: %s is not signed", ErrBadSignature, name)
+		}
+	}
+
+	date, err := http.ParseTime(req.Header.Get("Date"))
+	if err != nil || time.Since(date).Abs() > maxClockSkew {
+		return "", fmt.Errorf("%w: date is missing or too far from now", ErrBadSignature)
+	}
+	if len(body) > 0 && !digestMatches(req.Header.Get("Digest"), body) {
+		return "", fmt.Errorf("%w: digest does not match the body", ErrBadSignature)
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return "", fmt.Errorf("%w: signature is not base64", ErrBadSignature)
+	}
+	key, err := lookup(keyID)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256([]byte(signingString(req, headers)))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], decoded); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrBadSignature, err)
+	}
+	return keyID, nil
+}
+
+// signingString builds the text a signature covers from the named headers
+func signingString(req *http.Request, headers []string) string {
+	lines := make([]string, len(headers))
+	for i, name := range headers {
+		var value string
+		switch name {
+		case "(request-target)":
+			value = strings.ToLower(req.Method) + " " + req.URL.RequestURI()
+		case "host":
+			value = req.Host
+			if value == "" {
+				value = req.URL.Host
+			}
+		default:
+			value = strings.Join(req.Header.Values(name), ", ")
+		}
+		lines[i] = name + ": " + value
+	}
+	return strings.Join(lines, "\n")
+}
+
+// signatureParams splits a Signature header into its key="value" parameters
+func signatureParams(header string) map[string]string {
+	params := map[string]string{}
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if ok {
+			params[key] = strings.Trim(value, `"`)
+		}
+	}
+	return params
+}
+
+func digest(body []byte) string {
+	sum := sha256.Sum256(body)
+	return "SHA-256=" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// digestMatches reports whether a Digest header has the body's SHA-256
+func digestMatches(header string, body []byte) bool {
+	want := digest(body)
+	for _, d := range strings.Split(header, ",") {
+		d = strings.TrimSpace(d)
+		if algorithm, _, ok := strings.Cut(d, "="); ok && strings.EqualFold(algorithm, "SHA-256") {
+			return "SHA-256"+d[len(algorithm):] == want
+		}
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
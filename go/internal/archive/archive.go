@@ -0,0 +1,439 @@
+// Package archive exports a blog as a zip of front-matter markdown files and
+// imports the same archive, or a zipped Jekyll or Hugo site, back into a blog.
+package archive
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cassiascheffer/willow_camp/internal/media"
+	"github.com/cassiascheffer/willow_camp/internal/models"
+	"github.com/cassiascheffer/willow_camp/internal/repository"
+)
+
+// Format identifies the layout of an imported archive
+type Format string
+
+const (
+	FormatWillow Format = "willow.camp"
+	FormatJekyll Format = "Jekyll"
+	FormatHugo   Format = "Hugo"
+)
+
+// Version is written to blog.json so future importers can tell old exports apart
+const Version = 1
+
+// DefaultMaxImportBytes is the archive size limit when IMPORT_MAX_BYTES is unset
+const DefaultMaxImportBytes = 200 << 20
+
+// maxDocumentBytes caps a single markdown file; anything larger is skipped
+const maxDocumentBytes = 2 << 20
+
+var (
+	ErrInvalidArchive = errors.New("file is not a zip archive")
+	ErrUnknownFormat  = errors.New("archive is not a willow.camp export, Jekyll site or Hugo site")
+)
+
+// Settings is the blog.json written at the root of an export
+type Settings struct {
+	Version            int       `json:"version"`
+	ExportedAt         time.Time `json:"exported_at"`
+	Title              string    `json:"title"`
+	Subdomain          string    `json:"subdomain"`
+	CustomDomain       string    `json:"custom_domain,omitempty"`
+	MetaDescription    string    `json:"meta_description,omitempty"`
+	FaviconEmoji       string    `json:"favicon_emoji,omitempty"`
+	Theme              string    `json:"theme,omitempty"`
+	PostFooterMarkdown string    `json:"post_footer_markdown,omitempty"`
+	NoIndex            bool      `json:"no_index"`
+	// ThemeOverrides is nil when the blog keeps its theme's defaults
+	ThemeOverrides *models.ThemeOverrides `json:"theme_overrides,omitempty"`
+	CustomCSS      string                 `json:"custom_css,omitempty"`
+	ThemePack      string                 `json:"theme_pack,omitempty"`
+	// MarkdownOptions is nil when no markdown extensions are turned on
+	MarkdownOptions *models.MarkdownOptions `json:"markdown_options,omitempty"`
+	HighlightStyle  string                  `json:"highlight_style,omitempty"`
+	CommentsEnabled bool                    `json:"comments_enabled,omitempty"`
+}
+
+// TagEntry is one line of tags.json
+type TagEntry struct {
+	Name  string `json:"name"`
+	Slug  string `json:"slug"`
+	Posts int    `json:"posts"`
+}
+
+// MediaEntry is one line of media.json, describing a file under media/
+type MediaEntry struct {
+	Key         string `json:"key"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	ByteSize    int64  `json:"byte_size"`
+}
+
+// Service exports and imports blog archives
+type Service struct {
+	repos          *repository.Repositories
+	media          *media.Service
+	maxImportBytes int64
+}
+
+// New creates a new archive Service. media may be nil, in which case
+// uploads are neither exported nor imported. The import size limit comes
+// from IMPORT_MAX_BYTES.
+func New(repos *repository.Repositories, mediaService *media.Service) *Service {
+	maxImportBytes := int64(DefaultMaxImportBytes)
+	if n, err := strconv.ParseInt(os.Getenv("IMPORT_MAX_BYTES"), 10, 64); err == nil && n > 0 {
+		maxImportBytes = n
+	}
+	return &Service{repos: repos, media: mediaService, maxImportBytes: maxImportBytes}
+}
+
+// MaxImportBytes returns the largest archive accepted for import
+func (s *Service) MaxImportBytes() int64 {
+	return s.maxImportBytes
+}
+
+// Bundle is a parsed archive, ready to be imported
+type Bundle struct {
+	Format    Format
+	Settings  *Settings
+	Documents []*Document
+	Warnings  []string
+
+	tagSlugs map[string]string
+	media    []MediaEntry
+	assets   map[string]*zip.File
+}
+
+// assetExtensions are the files that are considered for upload when importing
+// a static site; the media service still sniffs and validates their contents
+var assetExtensions = map[string]bool{
+	".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".webp": true, ".pdf": true,
+}
+
+var jekyllPostName = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2})-(.+)$`)
+
+// Parse reads a zip archive and works out which documents and files it holds
+func Parse(r io.ReaderAt, size int64) (*Bundle, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, ErrInvalidArchive
+	}
+
+	files := map[string]*zip.File{}
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		name := path.Clean(strings.ReplaceAll(f.Name, "\\", "/"))
+		if name == "." || strings.HasPrefix(name, "/") || strings.HasPrefix(name, "../") || ignoredPath(name) {
+			continue
+		}
+		files[name] = f
+	}
+	files = stripWrapperDir(files)
+
+	b := &Bundle{assets: map[string]*zip.File{}, tagSlugs: map[string]string{}}
+	switch {
+	case files["blog.json"] != nil:
+		b.Format = FormatWillow
+	case hasPrefix(files, "_posts/") || files["_config.yml"] != nil:
+		b.Format = FormatJekyll
+	case hasPrefix(files, "content/"):
+		b.Format = FormatHugo
+	default:
+		return nil, ErrUnknownFormat
+	}
+
+	if b.Format == FormatWillow {
+		if err := b.readWillowMetadata(files); err != nil {
+			return nil, err
+		}
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		f := files[name]
+		ext := strings.ToLower(path.Ext(name))
+
+		if ext == ".md" || ext == ".markdown" {
+			kind, ok := b.documentKind(name)
+			if !ok {
+				continue
+			}
+			if f.UncompressedSize64 > maxDocumentBytes {
+				b.warn("%s: skipped because it is larger than %d MB", name, maxDocumentBytes>>20)
+				continue
+			}
+			doc, err := b.readDocument(f, name, kind)
+			if err != nil {
+				b.warn("%s: skipped (%v)", name, err)
+				continue
+			}
+			b.Documents = append(b.Documents, doc)
+			continue
+		}
+
+		if b.Format == FormatWillow {
+			if strings.HasPrefix(name, "media/") {
+				b.assets[name] = f
+			}
+		} else if assetExtensions[ext] {
+			b.assets[name] = f
+		}
+	}
+
+	return b, nil
+}
+
+// readWillowMetadata loads blog.json, tags.json and media.json from an export
+func (b *Bundle) readWillowMetadata(files map[string]*zip.File) error {
+	var settings Settings
+	if err := readJSON(files["blog.json"], &settings); err != nil {
+		return fmt.Errorf("invalid blog.json: %w", err)
+	}
+	b.Settings = &settings
+
+	if f := files["tags.json"]; f != nil {
+		var tags []TagEntry
+		if err := readJSON(f, &tags); err != nil {
+			b.warn("tags.json: ignored (%v)", err)
+		}
+		for _, t := range tags {
+			b.tagSlugs[t.Name] = t.Slug
+		}
+	}
+
+	if f := files["media.json"]; f != nil {
+		if err := readJSON(f, &b.media); err != nil {
+			b.warn("media.json: ignored (%v)", err)
+		}
+	}
+	return nil
+}
+
+// documentKind decides whether a markdown file is a post or a page for the
+// bundle's format, or should be left out entirely
+func (b *Bundle) documentKind(name string) (docKind, bool) {
+	dir, file := path.Split(name)
+	base := strings.ToLower(file)
+
+	switch b.Format {
+	case FormatWillow:
+		switch dir {
+		case "posts/":
+			return kindPost, true
+		case "pages/":
+			return kindPage, true
+		}
+	case FormatJekyll:
+		switch {
+		case strings.HasPrefix(name, "_posts/"):
+			return kindPost, true
+		case strings.HasPrefix(name, "_drafts/"):
+			return kindDraft, true
+		case dir == "" && base != "readme.md" && base != "index.md" && base != "index.markdown" && !strings.HasPrefix(base, "404"):
+			return kindPage, true
+		}
+	case FormatHugo:
+		if !strings.HasPrefix(name, "content/") || strings.HasPrefix(base, "_index.") {
+			return 0, false
+		}
+		if dir == "content/" {
+			return kindPage, true
+		}
+		return kindPost, true
+	}
+	return 0, false
+}
+
+type docKind int
+
+const (
+	kindPost docKind = iota + 1
+	kindDraft
+	kindPage
+)
+
+// readDocument parses a markdown file into a Document using the conventions of the bundle's format
+func (b *Bundle) readDocument(f *zip.File, name string, kind docKind) (*Document, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	content, err := io.ReadAll(io.LimitReader(rc, maxDocumentBytes+1))
+	if err != nil {
+		return nil, err
+	}
+
+	meta, body, err := splitFrontMatter(string(content))
+	if err != nil {
+		return nil, err
+	}
+
+	// Jekyll root pages are only pages when they have front matter
+	if b.Format == FormatJekyll && kind == kindPage && len(meta) == 0 && !strings.HasPrefix(string(content), "---") {
+		return nil, errors.New("no front matter")
+	}
+
+	doc := &Document{
+		Path:        name,
+		Title:       metaString(meta, "title"),
+		Slug:        metaString(meta, "slug"),
+		Description: metaString(meta, "description", "summary", "excerpt"),
+		Body:        body,
+		Tags:        mergeTags(metaList(meta, "tags"), metaList(meta, "categories")),
+		PublishedAt: metaTime(meta, "date", "publishDate", "published_at"),
+		Page:        kind == kindPage,
+	}
+
+	fileSlug := strings.TrimSuffix(path.Base(name), path.Ext(name))
+	if fileSlug == "index" {
+		// Hugo page bundles are named after their directory
+		fileSlug = path.Base(path.Dir(name))
+	}
+
+	switch b.Format {
+	case FormatWillow:
+		doc.Published, _ = metaBool(meta, "published")
+		doc.Featured, _ = metaBool(meta, "featured")
+		if visibility := metaString(meta, "visibility"); visibility != "" {
+			if slices.Contains(models.Visibilities, visibility) {
+				doc.Visibility = visibility
+			} else {
+				b.warn("%s: unknown visibility %q, imported as public", name, visibility)
+			}
+		}
+		doc.Series = metaString(meta, "series")
+		doc.SeriesPart, _ = strconv.Atoi(metaString(meta, "series_part"))
+		// Passwords aren't exported, so password posts come in as drafts
+		// until they're given a new one
+		if doc.Visibility == models.VisibilityPassword && doc.Published {
+			doc.Published = false
+			b.warn("%s: password posts are imported as drafts; set a new password to publish", name)
+		}
+	case FormatJekyll:
+		if m := jekyllPostName.FindStringSubmatch(fileSlug); m != nil {
+			fileSlug = m[2]
+			if doc.PublishedAt == nil {
+				if t, ok := parseDate(m[1]); ok {
+					doc.PublishedAt = &t
+				}
+			}
+		}
+		published, ok := metaBool(meta, "published")
+		doc.Published = kind != kindDraft && (published || !ok)
+		if strings.Contains(body, "{%") {
+			b.warn("%s: Liquid tags are kept as plain text", name)
+		}
+	case FormatHugo:
+		draft, _ := metaBool(meta, "draft")
+		doc.Published = !draft
+		if strings.Contains(body, "{{<") || strings.Contains(body, "{{%") {
+			b.warn("%s: Hugo shortcodes are kept as plain text", name)
+		}
+	}
+
+	if doc.Slug == "" {
+		doc.Slug = fileSlug
+	}
+	if doc.Title == "" {
+		doc.Title = strings.ReplaceAll(doc.Slug, "-", " ")
+	}
+
+	return doc, nil
+}
+
+func (b *Bundle) warn(format string, args ...interface{}) {
+	b.Warnings = append(b.Warnings, fmt.Sprintf(format, args...))
+}
+
+// mergeTags combines tag lists, dropping case-insensitive duplicates
+func mergeTags(lists ...[]string) []string {
+	seen := map[string]bool{}
+	var tags []string
+	for _, list := range lists {
+		for _, tag := range list {
+			key := strings.ToLower(tag)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// ignoredPath skips metadata that archivers and operating systems add to zips
+func ignoredPath(name string) bool {
+	for _, part := range strings.Split(name, "/") {
+		if part == "__MACOSX" || part == ".git" || part == "node_modules" || part == "public" || part == "_site" || strings.HasPrefix(part, "._") || part == ".DS_Store" {
+			return true
+		}
+	}
+	return false
+}
+
+// stripWrapperDir removes a single directory that wraps every file, as
+// happens when a site folder is zipped from its parent
+func stripWrapperDir(files map[string]*zip.File) map[string]*zip.File {
+	var prefix string
+	for name := range files {
+		dir, _, ok := strings.Cut(name, "/")
+		if !ok {
+			return files
+		}
+		if prefix == "" {
+			prefix = dir
+		} else if dir != prefix {
+			return files
+		}
+	}
+	if prefix == "" {
+		return files
+	}
+
+	stripped := make(map[string]*zip.File, len(files))
+	for name, f := range files {
+		stripped[strings.TrimPrefix(name, prefix+"/")] = f
+	}
+	return stripped
+}
+
+func hasPrefix(files map[string]*zip.File, prefix string) bool {
+	for name := range files {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func readJSON(f *zip.File, v interface{}) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return json.NewDecoder(io.LimitReader(rc, maxDocumentBytes)).Decode(v)
+}
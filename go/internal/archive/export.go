@@ -0,0 +1,212 @@
+package archive
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/cassiascheffer/willow_camp/internal/models"
+	"github.com/google/uuid"
+)
+
+// exportPageSize is how many posts are loaded per query while exporting
+const exportPageSize = 100
+
+// Export streams a zip of the blog to w:
+//
+//	blog.json       blog settings
+//	tags.json       tags used by the blog's posts
+//	media.json      uploaded files listed by key
+//	posts/<slug>.md posts with YAML front matter
+//	pages/<slug>.md pages with YAML front matter
+//	media/<key>     uploaded files
+func (s *Service) Export(ctx context.Context, blog *models.Blog, w io.Writer) error {
+	zw := zip.NewWriter(w)
+
+	settings := Settings{
+		Version:            Version,
+		ExportedAt:         time.Now().UTC(),
+		Title:              deref(blog.Title),
+		Subdomain:          deref(blog.Subdomain),
+		CustomDomain:       deref(blog.CustomDomain),
+		MetaDescription:    deref(blog.MetaDescription),
+		FaviconEmoji:       deref(blog.FaviconEmoji),
+		Theme:              blog.Theme,
+		PostFooterMarkdown: deref(blog.PostFooterMarkdown),
+		NoIndex:            blog.NoIndex,
+		CustomCSS:          deref(blog.CustomCSS),
+		ThemePack:          blog.ThemePack,
+		HighlightStyle:     blog.HighlightStyle,
+		CommentsEnabled:    blog.CommentsEnabled,
+	}
+	if !blog.ThemeOverrides.IsZero() {
+		settings.ThemeOverrides = &blog.ThemeOverrides
+	}
+	if blog.MarkdownOptions != (models.MarkdownOptions{}) {
+		settings.MarkdownOptions = &blog.MarkdownOptions
+	}
+	if err := writeJSON(zw, "blog.json", settings); err != nil {
+		return err
+	}
+
+	tags := map[string]*TagEntry{}
+	written := map[string]bool{}
+	for offset := 0; ; offset += exportPageSize {
+		posts, err := s.repos.Post.ListAll(ctx, blog.ID, exportPageSize, offset)
+		if err != nil {
+			return err
+		}
+
+		ids := make([]uuid.UUID, len(posts))
+		for i, post := range posts {
+			ids[i] = post.ID
+		}
+		tagsByPost, err := s.repos.Tag.FindTagsForPosts(ctx, ids)
+		if err != nil {
+			return err
+		}
+		seriesByPost, err := s.repos.Series.FindForPosts(ctx, ids)
+		if err != nil {
+			return err
+		}
+
+		for _, post := range posts {
+			doc := &Document{
+				Title:       deref(post.Title),
+				Slug:        deref(post.Slug),
+				Description: deref(post.MetaDescription),
+				Body:        deref(post.BodyMarkdown),
+				Published:   post.IsPublished(),
+				PublishedAt: post.PublishedAt,
+				Featured:    post.Featured,
+				Page:        post.IsPage(),
+			}
+			// Public is the default, so it's left out of the front matter
+			if post.Visibility != models.VisibilityPublic {
+				doc.Visibility = post.Visibility
+			}
+			if series := seriesByPost[post.ID]; series != nil {
+				doc.Series = series.Title
+				doc.SeriesPart = series.Position
+			}
+			for _, tag := range tagsByPost[post.ID] {
+				doc.Tags = append(doc.Tags, tag.Name)
+				if tags[tag.Name] == nil {
+					tags[tag.Name] = &TagEntry{Name: tag.Name, Slug: deref(tag.Slug)}
+				}
+				tags[tag.Name].Posts++
+			}
+
+			dir := "posts/"
+			if doc.Page {
+				dir = "pages/"
+			}
+			name := doc.Slug
+			if name == "" {
+				name = post.ID.String()
+			}
+			if written[dir+name] {
+				name += "-" + post.ID.String()[:8]
+			}
+			written[dir+name] = true
+
+			content, err := MarshalDocument(doc)
+			if err != nil {
+				return err
+			}
+			if err := writeFile(zw, dir+name+".md", post.UpdatedAt, content); err != nil {
+				return err
+			}
+		}
+
+		if len(posts) < exportPageSize {
+			break
+		}
+	}
+
+	tagList := make([]*TagEntry, 0, len(tags))
+	for _, tag := range tags {
+		tagList = append(tagList, tag)
+	}
+	sort.Slice(tagList, func(i, j int) bool { return tagList[i].Name < tagList[j].Name })
+	if err := writeJSON(zw, "tags.json", tagList); err != nil {
+		return err
+	}
+
+	if s.media != nil {
+		if err := s.exportMedia(ctx, zw, blog); err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+// exportMedia copies the blog's uploaded originals into media/. Resized
+// variants are left out; they are regenerated on import.
+func (s *Service) exportMedia(ctx context.Context, zw *zip.Writer, blog *models.Blog) error {
+	files, err := s.repos.Media.ListForBlog(ctx, blog.ID)
+	if err != nil {
+		return err
+	}
+
+	entries := make([]MediaEntry, 0, len(files))
+	for _, m := range files {
+		entries = append(entries, MediaEntry{
+			Key:         m.Key,
+			Filename:    m.Filename,
+			ContentType: m.ContentType,
+			ByteSize:    m.ByteSize,
+		})
+	}
+	if err := writeJSON(zw, "media.json", entries); err != nil {
+		return err
+	}
+
+	for _, m := range files {
+		rc, err := s.media.Open(ctx, m)
+		if err != nil {
+			return fmt.Errorf("failed to open media %s: %w", m.Key, err)
+		}
+		// Files are already compressed, so they are stored as-is
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: "media/" + m.Key, Method: zip.Store, Modified: m.CreatedAt})
+		if err == nil {
+			_, err = io.Copy(fw, rc)
+		}
+		rc.Close()
+		if err != nil {
+			return fmt.Errorf("failed to export media %s: %w", m.Key, err)
+		}
+	}
+	return nil
+}
+
+func writeJSON(zw *zip.Writer, name string, v interface{}) error {
+	content, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", name, err)
+	}
+	return writeFile(zw, name, time.Now(), append(content, '\n'))
+}
+
+func writeFile(zw *zip.Writer, name string, modified time.Time, content []byte) error {
+	fw, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+	if err != nil {
+		return fmt.Errorf("failed to add %s: %w", name, err)
+	}
+	if _, err := fw.Write(content); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
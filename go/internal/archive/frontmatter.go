@@ -0,0 +1,275 @@
+package archive
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Document is a post or page read from, or written to, a markdown file with front matter
+type Document struct {
+	Path        string
+	Title       string
+	Slug        string
+	Description string
+	Body        string
+	Tags        []string
+	Published   bool
+	PublishedAt *time.Time
+	Featured    bool
+	Page        bool
+	// Visibility is who can read the post; empty means public
+	Visibility string
+	// Series is the title of the series the post is part of, and SeriesPart
+	// its position there
+	Series     string
+	SeriesPart int
+}
+
+// frontMatter is the YAML header written on exported documents
+type frontMatter struct {
+	Title       string     `yaml:"title"`
+	Slug        string     `yaml:"slug"`
+	Date        *time.Time `yaml:"date,omitempty"`
+	Published   bool       `yaml:"published"`
+	Featured    bool       `yaml:"featured,omitempty"`
+	Visibility  string     `yaml:"visibility,omitempty"`
+	Series      string     `yaml:"series,omitempty"`
+	SeriesPart  int        `yaml:"series_part,omitempty"`
+	Description string     `yaml:"description,omitempty"`
+	Tags        []string   `yaml:"tags,omitempty"`
+}
+
+// MarshalDocument renders a document as markdown with a YAML front matter block
+func MarshalDocument(doc *Document) ([]byte, error) {
+	fm := frontMatter{
+		Title:       doc.Title,
+		Slug:        doc.Slug,
+		Date:        doc.PublishedAt,
+		Published:   doc.Published,
+		Featured:    doc.Featured,
+		Visibility:  doc.Visibility,
+		Series:      doc.Series,
+		SeriesPart:  doc.SeriesPart,
+		Description: doc.Description,
+		Tags:        doc.Tags,
+	}
+	if fm.Date != nil {
+		utc := fm.Date.UTC()
+		fm.Date = &utc
+	}
+
+	header, err := yaml.Marshal(&fm)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode front matter: %w", err)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("---\n")
+	buf.Write(header)
+	buf.WriteString("---\n\n")
+	buf.WriteString(doc.Body)
+	if doc.Body != "" && !strings.HasSuffix(doc.Body, "\n") {
+		buf.WriteString("\n")
+	}
+	return buf.Bytes(), nil
+}
+
+// splitFrontMatter separates the metadata block from the body. YAML (---) and
+// Hugo's TOML (+++) headers are recognised; files without one have no metadata.
+func splitFrontMatter(content string) (map[string]interface{}, string, error) {
+	content = strings.TrimPrefix(content, "\ufeff")
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+
+	var delim string
+	switch {
+	case strings.HasPrefix(content, "---\n"):
+		delim = "---"
+	case strings.HasPrefix(content, "+++\n"):
+		delim = "+++"
+	default:
+		return map[string]interface{}{}, content, nil
+	}
+
+	rest := content[len(delim)+1:]
+	var header, body string
+	if strings.HasPrefix(rest, delim+"\n") || rest == delim {
+		body = strings.TrimPrefix(rest, delim)
+	} else {
+		end := strings.Index(rest, "\n"+delim+"\n")
+		if end < 0 {
+			if !strings.HasSuffix(rest, "\n"+delim) {
+				return nil, "", errors.New("front matter is not closed")
+			}
+			end = len(rest) - len(delim) - 1
+		}
+		header = rest[:end]
+		body = rest[end+1+len(delim):]
+	}
+	body = strings.TrimLeft(body, "\n")
+
+	meta := map[string]interface{}{}
+	if delim == "+++" {
+		var err error
+		if meta, err = parseTOML(header); err != nil {
+			return nil, "", err
+		}
+		return meta, body, nil
+	}
+	if err := yaml.Unmarshal([]byte(header), &meta); err != nil {
+		return nil, "", fmt.Errorf("invalid front matter: %w", err)
+	}
+	if meta == nil {
+		meta = map[string]interface{}{}
+	}
+	return meta, body, nil
+}
+
+// parseTOML reads the flat subset of TOML that Hugo front matter uses:
+// strings, booleans, numbers, dates and arrays of strings. Tables are skipped.
+func parseTOML(header string) (map[string]interface{}, error) {
+	meta := map[string]interface{}{}
+	inTable := false
+	for i, line := range strings.Split(header, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			inTable = true
+			continue
+		}
+		if inTable {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid front matter on line %d", i+1)
+		}
+		key = strings.Trim(strings.TrimSpace(key), `"'`)
+		meta[key] = parseTOMLValue(strings.TrimSpace(value))
+	}
+	return meta, nil
+}
+
+func parseTOMLValue(value string) interface{} {
+	switch {
+	case strings.HasPrefix(value, "["):
+		inner := strings.TrimSuffix(strings.TrimPrefix(value, "["), "]")
+		items := []interface{}{}
+		for _, item := range strings.Split(inner, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, parseTOMLValue(item))
+			}
+		}
+		return items
+	case strings.HasPrefix(value, `"`):
+		if s, err := strconv.Unquote(value); err == nil {
+			return s
+		}
+		return strings.Trim(value, `"`)
+	case strings.HasPrefix(value, "'"):
+		return strings.Trim(value, "'")
+	case value == "true":
+		return true
+	case value == "false":
+		return false
+	}
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return int(n)
+	}
+	return value
+}
+
+// metaString returns the first non-empty string value among keys
+func metaString(meta map[string]interface{}, keys ...string) string {
+	for _, key := range keys {
+		switch v := meta[key].(type) {
+		case string:
+			if s := strings.TrimSpace(v); s != "" {
+				return s
+			}
+		case int, float64:
+			return fmt.Sprint(v)
+		}
+	}
+	return ""
+}
+
+// metaBool returns a boolean value and whether the key was present
+func metaBool(meta map[string]interface{}, key string) (bool, bool) {
+	switch v := meta[key].(type) {
+	case bool:
+		return v, true
+	case string:
+		b, err := strconv.ParseBool(strings.TrimSpace(v))
+		return b, err == nil
+	}
+	return false, false
+}
+
+// metaTime returns the first parseable date among keys
+func metaTime(meta map[string]interface{}, keys ...string) *time.Time {
+	for _, key := range keys {
+		switch v := meta[key].(type) {
+		case time.Time:
+			return &v
+		case string:
+			if t, ok := parseDate(v); ok {
+				return &t
+			}
+		}
+	}
+	return nil
+}
+
+// metaList returns list values, also accepting Jekyll's space- or comma-separated strings
+func metaList(meta map[string]interface{}, key string) []string {
+	var items []string
+	switch v := meta[key].(type) {
+	case []interface{}:
+		for _, item := range v {
+			if s := strings.TrimSpace(fmt.Sprint(item)); s != "" {
+				items = append(items, s)
+			}
+		}
+	case string:
+		sep := " "
+		if strings.Contains(v, ",") {
+			sep = ","
+		}
+		for _, item := range strings.Split(v, sep) {
+			if s := strings.TrimSpace(item); s != "" {
+				items = append(items, s)
+			}
+		}
+	}
+	return items
+}
+
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05 -07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04 -0700",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// parseDate accepts the date formats Jekyll and Hugo sites commonly use
+func parseDate(s string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,457 @@
+package archive
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cassiascheffer/willow_camp/internal/helpers"
+	"github.com/cassiascheffer/willow_camp/internal/media"
+	"github.com/cassiascheffer/willow_camp/internal/models"
+	"github.com/cassiascheffer/willow_camp/internal/theme"
+	"github.com/google/uuid"
+	"github.com/gosimple/slug"
+)
+
+// Options controls how a bundle is imported
+type Options struct {
+	// DryRun reports what would be imported without writing anything
+	DryRun bool
+	// ImportSettings copies title, description, theme and similar settings from blog.json
+	ImportSettings bool
+}
+
+// Report describes the outcome of an import, or what one would do when DryRun is set
+type Report struct {
+	DryRun   bool
+	Format   Format
+	Items    []ReportItem
+	Media    int
+	Settings []string
+	Warnings []string
+}
+
+// ReportItem is one imported post or page
+type ReportItem struct {
+	Path          string
+	Title         string
+	Slug          string
+	RequestedSlug string
+	Page          bool
+	Published     bool
+	Tags          []string
+}
+
+// Renamed reports whether the slug had to change to avoid an existing post
+func (i ReportItem) Renamed() bool {
+	return i.Slug != i.RequestedSlug
+}
+
+// PostCount returns how many posts are in the report
+func (r *Report) PostCount() int {
+	n := 0
+	for _, item := range r.Items {
+		if !item.Page {
+			n++
+		}
+	}
+	return n
+}
+
+// PageCount returns how many pages are in the report
+func (r *Report) PageCount() int {
+	return len(r.Items) - r.PostCount()
+}
+
+var (
+	// mediaURLPattern finds links to uploaded files, with or without a host
+	// and including resized variants, so exported links can be repointed
+	mediaURLPattern = regexp.MustCompile(`(?:https?://[^\s/()"'<>]+)?/media/([0-9a-f]{32}\.[a-z]+)(?:/\d+w\.(?:jpg|png))?`)
+
+	// linkPattern finds markdown link and image targets and HTML src/href attributes
+	linkPattern = regexp.MustCompile(`(\]\(\s*<?|src=["']|href=["'])([^)\s"'<>]+)`)
+)
+
+// Import creates the bundle's documents as posts and pages on the blog,
+// uploading referenced files and giving clashing slugs a numeric suffix
+func (s *Service) Import(ctx context.Context, blog *models.Blog, userID uuid.UUID, bundle *Bundle, opts Options) (*Report, error) {
+	report := &Report{
+		DryRun:   opts.DryRun,
+		Format:   bundle.Format,
+		Warnings: append([]string(nil), bundle.Warnings...),
+	}
+
+	if opts.ImportSettings && bundle.Settings != nil {
+		updated := *blog
+		report.Settings = applySettings(&updated, bundle.Settings)
+		if len(report.Settings) > 0 && !opts.DryRun {
+			if err := s.repos.Blog.Update(ctx, &updated); err != nil {
+				return report, err
+			}
+			*blog = updated
+		}
+	}
+
+	im := &importer{
+		Service:  s,
+		ctx:      ctx,
+		blog:     blog,
+		userID:   userID,
+		bundle:   bundle,
+		report:   report,
+		uploaded: map[string]string{},
+	}
+
+	if s.media == nil && len(bundle.assets) > 0 {
+		report.Warnings = append(report.Warnings, "Uploads are not available, so files in the archive were not imported")
+	} else if bundle.Format == FormatWillow {
+		// Exports carry every upload, referenced or not
+		names := make([]string, 0, len(bundle.assets))
+		for name := range bundle.assets {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if _, err := im.upload(name); err != nil {
+				return report, err
+			}
+		}
+	}
+
+	reserved := map[string]bool{}
+	for _, doc := range bundle.Documents {
+		body, err := im.rewriteLinks(doc)
+		if err != nil {
+			return report, err
+		}
+
+		requested := slug.Make(doc.Slug)
+		if requested == "" {
+			requested = slug.Make(doc.Title)
+		}
+		if requested == "" {
+			requested = "untitled"
+		}
+		postSlug, err := s.uniqueSlug(ctx, blog.ID, userID, requested, reserved)
+		if err != nil {
+			return report, err
+		}
+		reserved[postSlug] = true
+
+		item := ReportItem{
+			Path:          doc.Path,
+			Title:         doc.Title,
+			Slug:          postSlug,
+			RequestedSlug: requested,
+			Page:          doc.Page,
+			Published:     doc.Published,
+			Tags:          doc.Tags,
+		}
+
+		if !opts.DryRun {
+			if err := im.createPost(doc, postSlug, body); err != nil {
+				return report, fmt.Errorf("%s: %w", doc.Path, err)
+			}
+		}
+		report.Items = append(report.Items, item)
+	}
+
+	return report, nil
+}
+
+// importer holds the state of a single import run
+type importer struct {
+	*Service
+	ctx    context.Context
+	blog   *models.Blog
+	userID uuid.UUID
+	bundle *Bundle
+	report *Report
+	// uploaded maps archive paths to the URL the file is now served from
+	uploaded map[string]string
+}
+
+// createPost saves a document, its tags and its place in a series
+func (im *importer) createPost(doc *Document, postSlug, body string) error {
+	title := doc.Title
+	published := doc.Published
+	post := &models.Post{
+		BlogID:             im.blog.ID,
+		AuthorID:           im.userID,
+		Title:              &title,
+		Slug:               &postSlug,
+		BodyMarkdown:       &body,
+		Published:          &published,
+		PublishedAt:        doc.PublishedAt,
+		HasMermaidDiagrams: strings.Contains(body, "```mermaid"),
+		Featured:           doc.Featured,
+		Visibility:         doc.Visibility,
+	}
+	if doc.Description != "" {
+		description := doc.Description
+		post.MetaDescription = &description
+	}
+	if doc.Page {
+		pageType := "Page"
+		post.Type = &pageType
+	}
+	if published && post.PublishedAt == nil {
+		now := time.Now()
+		post.PublishedAt = &now
+	}
+
+	if err := im.repos.Post.Create(im.ctx, post); err != nil {
+		return err
+	}
+
+	for _, name := range doc.Tags {
+		tagSlug := im.bundle.tagSlugs[name]
+		if tagSlug == "" {
+			tagSlug = slug.Make(name)
+		}
+		if tagSlug == "" {
+			continue
+		}
+		tag, err := im.repos.Tag.FindOrCreateByName(im.ctx, name, tagSlug)
+		if err != nil {
+			return err
+		}
+		if err := im.repos.Tag.CreateTagging(im.ctx, post.ID, tag.ID); err != nil {
+			return err
+		}
+	}
+
+	if seriesSlug := slug.Make(doc.Series); seriesSlug != "" {
+		series, err := im.repos.Series.FindOrCreateByTitle(im.ctx, im.blog.ID, doc.Series, seriesSlug)
+		if err != nil {
+			return err
+		}
+		if err := im.repos.Series.SetPostSeries(im.ctx, post.ID, series.ID, max(doc.SeriesPart, 0)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rewriteLinks points links at files in the archive to their uploaded copies
+func (im *importer) rewriteLinks(doc *Document) (string, error) {
+	var firstErr error
+
+	if im.bundle.Format == FormatWillow {
+		body := mediaURLPattern.ReplaceAllStringFunc(doc.Body, func(match string) string {
+			key := mediaURLPattern.FindStringSubmatch(match)[1]
+			if url, ok := im.uploaded["media/"+key]; ok {
+				return url
+			}
+			return match
+		})
+		return body, nil
+	}
+
+	if im.media == nil {
+		return doc.Body, nil
+	}
+
+	body := linkPattern.ReplaceAllStringFunc(doc.Body, func(match string) string {
+		parts := linkPattern.FindStringSubmatch(match)
+		name, ok := im.bundle.resolveAsset(doc.Path, parts[2])
+		if !ok || firstErr != nil {
+			return match
+		}
+		url, err := im.upload(name)
+		if err != nil {
+			firstErr = err
+			return match
+		}
+		if url == "" {
+			return match
+		}
+		return parts[1] + url
+	})
+	return body, firstErr
+}
+
+// upload stores a file from the archive once and returns its URL. Files the
+// media service rejects are reported as warnings and return an empty URL.
+// During a dry run nothing is stored and a placeholder URL is returned.
+func (im *importer) upload(name string) (string, error) {
+	if url, ok := im.uploaded[name]; ok {
+		return url, nil
+	}
+
+	f := im.bundle.assets[name]
+	filename := path.Base(name)
+	for _, entry := range im.bundle.media {
+		if "media/"+entry.Key == name && entry.Filename != "" {
+			filename = entry.Filename
+		}
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	defer rc.Close()
+
+	var url string
+	if im.report.DryRun {
+		err = checkAsset(f, rc, im.media.MaxBytes())
+		url = "/media/" + path.Base(name)
+	} else {
+		var m *models.Media
+		m, err = im.media.Upload(im.ctx, im.blog, &im.userID, filename, rc)
+		if err == nil {
+			url = im.media.URL(im.blog, m)
+		}
+	}
+
+	switch {
+	case errors.Is(err, media.ErrTooLarge), errors.Is(err, media.ErrUnsupportedType), errors.Is(err, media.ErrEmpty):
+		im.report.Warnings = append(im.report.Warnings, fmt.Sprintf("%s: not uploaded (%v)", name, err))
+		url = ""
+	case err != nil:
+		return "", err
+	default:
+		im.report.Media++
+	}
+
+	im.uploaded[name] = url
+	return url, nil
+}
+
+// checkAsset applies the media service's size and type checks without storing anything
+func checkAsset(f *zip.File, r io.Reader, maxBytes int64) error {
+	if f.UncompressedSize64 == 0 {
+		return media.ErrEmpty
+	}
+	if f.UncompressedSize64 > uint64(maxBytes) {
+		return media.ErrTooLarge
+	}
+	head := make([]byte, 512)
+	n, err := io.ReadFull(r, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("failed to read %s: %w", f.Name, err)
+	}
+	_, _, err = media.DetectType(head[:n])
+	return err
+}
+
+// resolveAsset maps a link in a static site document to a file in the archive.
+// Absolute links are relative to the site root (or Hugo's static/ folder);
+// relative links are relative to the document, as in Hugo page bundles.
+func (b *Bundle) resolveAsset(docPath, target string) (string, bool) {
+	if strings.Contains(target, "://") || strings.HasPrefix(target, "//") || strings.HasPrefix(target, "#") ||
+		strings.HasPrefix(target, "mailto:") || strings.HasPrefix(target, "data:") {
+		return "", false
+	}
+	for _, prefix := range []string{"{{ site.baseurl }}", "{{site.baseurl}}", "{{ .Site.BaseURL }}"} {
+		target = strings.TrimPrefix(target, prefix)
+	}
+	if i := strings.IndexAny(target, "?#"); i >= 0 {
+		target = target[:i]
+	}
+	if target == "" {
+		return "", false
+	}
+
+	var candidates []string
+	if strings.HasPrefix(target, "/") {
+		root := strings.TrimPrefix(path.Clean(target), "/")
+		candidates = []string{root, "static/" + root}
+	} else {
+		candidates = []string{path.Join(path.Dir(docPath), target), path.Clean(target)}
+	}
+
+	for _, name := range candidates {
+		if b.assets[name] != nil {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// uniqueSlug picks a slug that no existing post or earlier imported document uses
+func (s *Service) uniqueSlug(ctx context.Context, blogID, authorID uuid.UUID, base string, reserved map[string]bool) (string, error) {
+	maxNum, err := s.repos.Post.FindMaxSlugNumber(ctx, blogID, authorID, base, nil)
+	if err != nil {
+		return "", err
+	}
+	// Years are date archives, so they take a suffix like taken slugs
+	if _, isYear := helpers.ParseArchiveYear(base); maxNum == -1 && !reserved[base] && !isYear {
+		return base, nil
+	}
+
+	n := maxNum + 1
+	if n < 1 {
+		n = 1
+	}
+	for reserved[fmt.Sprintf("%s-%d", base, n)] {
+		n++
+	}
+	return fmt.Sprintf("%s-%d", base, n), nil
+}
+
+// applySettings copies imported settings onto blog and returns the names of
+// the fields that changed. The subdomain and custom domain are never copied.
+func applySettings(blog *models.Blog, settings *Settings) []string {
+	var changed []string
+	setString := func(label string, field **string, value string) {
+		if value == "" || deref(*field) == value {
+			return
+		}
+		v := value
+		*field = &v
+		changed = append(changed, label)
+	}
+
+	setString("Title", &blog.Title, settings.Title)
+	setString("Meta description", &blog.MetaDescription, settings.MetaDescription)
+	setString("Favicon", &blog.FaviconEmoji, settings.FaviconEmoji)
+	setString("Post footer", &blog.PostFooterMarkdown, settings.PostFooterMarkdown)
+
+	if settings.Theme != "" && settings.Theme != blog.Theme {
+		for _, name := range helpers.AllThemes() {
+			if name == settings.Theme {
+				blog.Theme = settings.Theme
+				changed = append(changed, "Theme")
+				break
+			}
+		}
+	}
+	if settings.NoIndex != blog.NoIndex {
+		blog.NoIndex = settings.NoIndex
+		changed = append(changed, "Search engine indexing")
+	}
+	if o := settings.ThemeOverrides; o != nil && *o != blog.ThemeOverrides && theme.Validate(*o) == nil {
+		blog.ThemeOverrides = *o
+		changed = append(changed, "Appearance")
+	}
+	// A pack that isn't installed here renders with the default templates
+	if settings.ThemePack != "" && settings.ThemePack != blog.ThemePack {
+		blog.ThemePack = settings.ThemePack
+		changed = append(changed, "Layout")
+	}
+	if o := settings.MarkdownOptions; o != nil && *o != blog.MarkdownOptions {
+		blog.MarkdownOptions = *o
+		changed = append(changed, "Markdown")
+	}
+	if settings.HighlightStyle != "" && settings.HighlightStyle != blog.HighlightStyle && theme.ValidateHighlight(settings.HighlightStyle) == nil {
+		blog.HighlightStyle = settings.HighlightStyle
+		changed = append(changed, "Code highlighting")
+	}
+	if settings.CommentsEnabled && !blog.CommentsEnabled {
+		blog.CommentsEnabled = true
+		changed = append(changed, "Comments")
+	}
+	if len(settings.CustomCSS) <= theme.MaxCustomCSSBytes {
+		setString("Custom CSS", &blog.CustomCSS, theme.SanitizeCSS(settings.CustomCSS))
+	}
+	return changed
+}
@@ -0,0 +1,221 @@
+// Package assets serves the files embedded from go/static under /static with
+// content-hashed URLs, e.g. /static/dist/main.3f9a0c1d2e.css. Hashed URLs
+// never change content, so browsers may cache them forever. Templates get
+// them from the asset function.
+package assets
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cassiascheffer/willow_camp/internal/helpers"
+	"github.com/cassiascheffer/willow_camp/static"
+	"github.com/labstack/echo/v4"
+)
+
+// Prefix is the URL path every asset is served under
+const Prefix = "/static/"
+
+// hashLength is how many hex characters of the SHA-256 go into a URL
+const hashLength = 10
+
+// DefaultEmoji is used when a blog has no favicon emoji or OpenMoji has no icon for it
+const DefaultEmoji = "🏕️"
+
+// Cache lifetimes for hashed and plain URLs
+const (
+	immutableCache = "public, max-age=31536000, immutable"
+	plainCache     = "public, max-age=3600"
+)
+
+// openmojiDirs are also served from the site root, where the frontend
+// builds icon URLs at runtime from an emoji's hexcode
+var openmojiDirs = []string{
+	"openmoji-32x32-ico",
+	"openmoji-svg-color",
+	"openmoji-apple-touch-icon-180x180",
+}
+
+// hashedName splits "dist/main.3f9a0c1d2e.css" into name, hash and extension
+var hashedName = regexp.MustCompile(`^(.+)\.([0-9a-f]{10})(\.[^./]+)?$`)
+
+type fileHash struct {
+	modified time.Time
+	size     int64
+	hash     string
+}
+
+// store hashes files lazily and remembers the result. Embedded files never
+// change; files read from disk are re-hashed when their size or time changes.
+type store struct {
+	mu     sync.RWMutex
+	fsys   fs.FS
+	reload bool
+	hashes map[string]fileHash
+}
+
+var files = &store{fsys: static.FS, hashes: map[string]fileHash{}}
+
+// ReloadFrom serves the files in dir on disk instead of the embedded copy,
+// so frontend rebuilds from `npm run dev` show up without a restart
+func ReloadFrom(dir string) {
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return
+	}
+	files.mu.Lock()
+	files.fsys = os.DirFS(dir)
+	files.reload = true
+	files.hashes = map[string]fileHash{}
+	files.mu.Unlock()
+}
+
+// URL returns the fingerprinted URL of a file under static/, e.g.
+// URL("dist/main.css"). A missing file gets its plain URL so the page
+// still renders.
+func URL(name string) string {
+	name = strings.TrimPrefix(name, "/")
+	hash, err := files.hash(name)
+	if err != nil {
+		return Prefix + name
+	}
+	ext := path.Ext(name)
+	return Prefix + strings.TrimSuffix(name, ext) + "." + hash + ext
+}
+
+// Favicon holds the URLs of an emoji's OpenMoji icons
+type Favicon struct {
+	ICO            string
+	SVG            string
+	AppleTouchIcon string
+}
+
+// EmojiFavicon returns the fingerprinted icon URLs for emoji, falling back
+// to DefaultEmoji when it is blank or OpenMoji has no icon for it
+func EmojiFavicon(emoji string) Favicon {
+	code := helpers.EmojiToOpenmojiFilename(emoji)
+	if _, err := files.hash("openmoji-svg-color/" + code + ".svg"); err != nil {
+		code = helpers.EmojiToOpenmojiFilename(DefaultEmoji)
+	}
+	return Favicon{
+		ICO:            URL("openmoji-32x32-ico/" + code + ".ico"),
+		SVG:            URL("openmoji-svg-color/" + code + ".svg"),
+		AppleTouchIcon: URL("openmoji-apple-touch-icon-180x180/" + code + ".png"),
+	}
+}
+
+// Register adds the asset routes: /static/* plus the OpenMoji paths at the
+// site root that the emoji pickers request
+func Register(e *echo.Echo) {
+	e.GET(Prefix+"*", func(c echo.Context) error {
+		return serve(c, c.Param("*"))
+	})
+	for _, dir := range openmojiDirs {
+		dir := dir
+		e.GET("/"+dir+"/*", func(c echo.Context) error {
+			return serve(c, dir+"/"+c.Param("*"))
+		})
+	}
+	e.GET("/openmoji-map.json", func(c echo.Context) error {
+		return serve(c, "openmoji-map.json")
+	})
+}
+
+// serve writes a file, resolving hashed names to the file they fingerprint.
+// A hash from an older build still gets the current file, but without the
+// immutable cache header.
+func serve(c echo.Context, name string) error {
+	name, err := url.PathUnescape(name)
+	if err != nil || !fs.ValidPath(name) || path.Ext(name) == ".go" {
+		return echo.NewHTTPError(http.StatusNotFound, "File not found")
+	}
+
+	cacheControl := plainCache
+	hash, err := files.hash(name)
+	if err != nil {
+		m := hashedName.FindStringSubmatch(name)
+		if m == nil {
+			return echo.NewHTTPError(http.StatusNotFound, "File not found")
+		}
+		name = m[1] + m[3]
+		hash, err = files.hash(name)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusNotFound, "File not found")
+		}
+		if hash == m[2] {
+			cacheControl = immutableCache
+		}
+	}
+
+	data, modified, err := files.read(name)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "File not found")
+	}
+
+	header := c.Response().Header()
+	header.Set("Cache-Control", cacheControl)
+	header.Set("ETag", `"`+hash+`"`)
+	http.ServeContent(c.Response(), c.Request(), path.Base(name), modified, bytes.NewReader(data))
+	return nil
+}
+
+// hash returns the short content hash of name
+func (s *store) hash(name string) (string, error) {
+	if !fs.ValidPath(name) || path.Ext(name) == ".go" {
+		return "", fs.ErrNotExist
+	}
+
+	s.mu.RLock()
+	fsys, reload := s.fsys, s.reload
+	cached, ok := s.hashes[name]
+	s.mu.RUnlock()
+	if ok && !reload {
+		return cached.hash, nil
+	}
+
+	info, err := fs.Stat(fsys, name)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return "", fs.ErrNotExist
+	}
+	if ok && cached.size == info.Size() && cached.modified.Equal(info.ModTime()) {
+		return cached.hash, nil
+	}
+
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])[:hashLength]
+
+	s.mu.Lock()
+	s.hashes[name] = fileHash{modified: info.ModTime(), size: info.Size(), hash: hash}
+	s.mu.Unlock()
+	return hash, nil
+}
+
+// read returns a file's contents and modification time (zero when embedded)
+func (s *store) read(name string) ([]byte, time.Time, error) {
+	s.mu.RLock()
+	fsys := s.fsys
+	s.mu.RUnlock()
+
+	info, err := fs.Stat(fsys, name)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	data, err := fs.ReadFile(fsys, name)
+	return data, info.ModTime(), err
+}
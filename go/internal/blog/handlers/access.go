@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/cassiascheffer/willow_camp/internal/access"
+	"github.com/cassiascheffer/willow_camp/internal/blog/middleware"
+	"github.com/cassiascheffer/willow_camp/internal/comments"
+	"github.com/cassiascheffer/willow_camp/internal/models"
+	"github.com/cassiascheffer/willow_camp/internal/newsletter"
+	"github.com/labstack/echo/v4"
+)
+
+// canRead reports whether the reader may read a post. Password posts need
+// their password entered, and members-only posts need the reader signed in
+// as one of the blog's subscribers.
+func (h *Handlers) canRead(c echo.Context, blog *models.Blog, post *models.Post) bool {
+	switch post.Visibility {
+	case models.VisibilityPassword:
+		return h.access != nil && h.access.Unlocked(c, post)
+	case models.VisibilityMembers:
+		return h.isMember(c, blog)
+	}
+	return true
+}
+
+// isMember reports whether the reader signed in as one of the blog's
+// subscribers, who is still subscribed
+func (h *Handlers) isMember(c echo.Context, blog *models.Blog) bool {
+	if h.access == nil {
+		return false
+	}
+	id, ok := h.access.MemberID(c, blog)
+	if !ok {
+		return false
+	}
+	subscriber, err := h.repos.Subscriber.FindByID(c.Request().Context(), id)
+	if err != nil {
+		return false
+	}
+	return subscriber.BlogID == blog.ID && subscriber.IsActive()
+}
+
+// renderLocked shows the password prompt or member sign-in form in place of
+// a post the reader can't read yet
+func (h *Handlers) renderLocked(c echo.Context, status int, blog *models.Blog, post *models.Post, message string) error {
+	title := "Post"
+	if post.Title != nil {
+		title = *post.Title
+	}
+
+	data := map[string]interface{}{
+		"Blog":         blog,
+		"Title":        title,
+		"Post":         post,
+		"Message":      message,
+		"EmailEnabled": h.newsletter != nil,
+	}
+
+	// What's shown depends on the reader's cookie
+	c.Response().Header().Set("Cache-Control", "private, no-store")
+	return h.renderTemplateWithStatus(c, status, "post_locked.html", data)
+}
+
+// UnlockPost checks the password for a password post and lets the reader in
+func (h *Handlers) UnlockPost(c echo.Context) error {
+	logger := getLogger(c)
+	blog := middleware.GetBlog(c)
+	if blog == nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Blog not found in context")
+	}
+
+	post, err := h.repos.Post.FindBySlug(c.Request().Context(), blog.ID, c.Param("slug"))
+	if err != nil || !post.IsPublished() || post.Visibility != models.VisibilityPassword || post.Slug == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Post not found")
+	}
+	if h.access == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Protected posts are not available")
+	}
+
+	ipHash := comments.HashIP(blog.ID, c.RealIP())
+	err = h.access.TryPassword(c.Request().Context(), post, ipHash, c.FormValue("password"))
+	switch {
+	case errors.Is(err, access.ErrTooManyAttempts):
+		return h.renderLocked(c, http.StatusTooManyRequests, blog, post, "Too many wrong passwords. Please wait a few minutes and try again.")
+	case errors.Is(err, access.ErrWrongPassword):
+		return h.renderLocked(c, http.StatusUnauthorized, blog, post, "That password isn't right. Please try again.")
+	case err != nil:
+		logger.Error("Failed to check post password", "blog_id", blog.ID, "post_id", post.ID, "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to unlock post")
+	}
+
+	if err := h.access.Unlock(c, post); err != nil {
+		logger.Error("Failed to unlock post", "blog_id", blog.ID, "post_id", post.ID, "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to unlock post")
+	}
+	return c.Redirect(http.StatusSeeOther, "/"+url.PathEscape(*post.Slug))
+}
+
+// RequestMemberLink emails a subscriber a link to sign in and read
+// members-only posts
+func (h *Handlers) RequestMemberLink(c echo.Context) error {
+	logger := getLogger(c)
+	blog := middleware.GetBlog(c)
+	if blog == nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Blog not found in context")
+	}
+	if h.newsletter == nil || h.access == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Members are not available")
+	}
+
+	err := h.newsletter.RequestMemberLink(c.Request().Context(), blog, c.FormValue("email"), c.FormValue("slug"))
+	if errors.Is(err, newsletter.ErrInvalidEmail) {
+		return h.renderStatusPage(c, http.StatusUnprocessableEntity, "Check your email address",
+			"That doesn't look like a valid email address. Please go back and try again.")
+	}
+	if err != nil {
+		logger.Error("Failed to request member link", "blog_id", blog.ID, "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to send sign-in link")
+	}
+
+	// Same response whether or not the address subscribes, so the form
+	// can't be used to find out who reads the blog
+	return h.renderStatusPage(c, http.StatusOK, "Check your inbox",
+		"If that address is subscribed to "+getTitle(blog)+", we sent it a link to sign in.")
+}
+
+// ConfirmMemberLink signs a subscriber in from the emailed link and takes
+// them back to the post they wanted to read
+func (h *Handlers) ConfirmMemberLink(c echo.Context) error {
+	logger := getLogger(c)
+	blog := middleware.GetBlog(c)
+	if blog == nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Blog not found in context")
+	}
+	if h.access == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Members are not available")
+	}
+
+	expired := func() error {
+		return h.renderStatusPage(c, http.StatusNotFound, "Link expired",
+			"This sign-in link is no longer valid. You can ask for a new one from the post you wanted to read.")
+	}
+
+	blogID, subscriberID, err := h.access.VerifyMemberToken(c.QueryParam("token"))
+	if err != nil || blogID != blog.ID {
+		return expired()
+	}
+	subscriber, err := h.repos.Subscriber.FindByID(c.Request().Context(), subscriberID)
+	if err != nil || subscriber.BlogID != blog.ID || !subscriber.IsActive() {
+		return expired()
+	}
+
+	if err := h.access.SignInMember(c, blog, subscriber.ID); err != nil {
+		logger.Error("Failed to sign in member", "blog_id", blog.ID, "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to sign in")
+	}
+
+	target := "/"
+	if slug := c.QueryParam("slug"); slug != "" {
+		target = "/" + url.PathEscape(slug)
+	}
+	return c.Redirect(http.StatusSeeOther, target)
+}
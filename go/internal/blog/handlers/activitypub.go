@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/cassiascheffer/willow_camp/internal/activitypub"
+	"github.com/cassiascheffer/willow_camp/internal/blog/middleware"
+	"github.com/cassiascheffer/willow_camp/internal/models"
+	"github.com/labstack/echo/v4"
+)
+
+// maxInboxBytes is the largest activity accepted in the inbox
+const maxInboxBytes = 1 << 20
+
+// WebFinger resolves acct:username@host to the blog's actor
+func (h *Handlers) WebFinger(c echo.Context) error {
+	blog, err := h.activityPubBlog(c)
+	if err != nil {
+		return err
+	}
+	resource := c.QueryParam("resource")
+	if resource == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "resource is required")
+	}
+
+	jrd, err := h.activitypub.WebFinger(blog, resource)
+	if errors.Is(err, activitypub.ErrUnknownResource) {
+		return echo.NewHTTPError(http.StatusNotFound, "Unknown resource")
+	}
+	if err != nil {
+		return err
+	}
+	return activityJSON(c, "application/jrd+json", jrd)
+}
+
+// Actor serves the blog's ActivityPub actor
+func (h *Handlers) Actor(c echo.Context) error {
+	blog, err := h.activityPubBlog(c)
+	if err != nil {
+		return err
+	}
+	actor, err := h.activitypub.Actor(c.Request().Context(), blog)
+	if err != nil {
+		getLogger(c).Error("Failed to build actor", "blog_id", blog.ID, "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load actor")
+	}
+	return activityJSON(c, activitypub.ContentType, actor)
+}
+
+// Outbox serves the blog's latest posts as Create activities
+func (h *Handlers) Outbox(c echo.Context) error {
+	blog, err := h.activityPubBlog(c)
+	if err != nil {
+		return err
+	}
+	ctx := c.Request().Context()
+	posts, err := h.repos.Post.ListPublic(ctx, blog.ID, 20, 0)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load posts")
+	}
+	total, err := h.repos.Post.CountPublic(ctx, blog.ID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to count posts")
+	}
+	return activityJSON(c, activitypub.ContentType, h.activitypub.Outbox(blog, posts, total))
+}
+
+// Followers serves how many accounts follow the blog
+func (h *Handlers) Followers(c echo.Context) error {
+	blog, err := h.activityPubBlog(c)
+	if err != nil {
+		return err
+	}
+	followers, err := h.activitypub.Followers(c.Request().Context(), blog)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load followers")
+	}
+	return activityJSON(c, activitypub.ContentType, followers)
+}
+
+// Inbox accepts signed activities from other servers
+func (h *Handlers) Inbox(c echo.Context) error {
+	logger := getLogger(c)
+	blog, err := h.activityPubBlog(c)
+	if err != nil {
+		return err
+	}
+	body, err := io.ReadAll(io.LimitReader(c.Request().Body, maxInboxBytes))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Failed to read activity")
+	}
+
+	err = h.activitypub.HandleInbox(c.Request().Context(), blog, c.Request(), body)
+	switch {
+	case errors.Is(err, activitypub.ErrBadSignature):
+		logger.Warn("Rejected unsigned activity", "blog_id", blog.ID, "error", err)
+		return c.String(http.StatusUnauthorized, err.Error())
+	case errors.Is(err, activitypub.ErrInvalidActivity):
+		return c.String(http.StatusBadRequest, err.Error())
+	case err != nil:
+		logger.Error("Failed to handle activity", "blog_id", blog.ID, "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to handle activity")
+	}
+	return c.NoContent(http.StatusAccepted)
+}
+
+// activityPubBlog returns the blog in context, or a 404 when ActivityPub
+// isn't set up
+func (h *Handlers) activityPubBlog(c echo.Context) (*models.Blog, error) {
+	blog := middleware.GetBlog(c)
+	if blog == nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Blog not found in context")
+	}
+	if h.activitypub == nil {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "ActivityPub is not available")
+	}
+	return blog, nil
+}
+
+// wantsActivity reports whether the request asks for an ActivityPub document
+// rather than a page, as servers do when looking up a post by its URL
+func wantsActivity(c echo.Context) bool {
+	accept := c.Request().Header.Get("Accept")
+	return strings.Contains(accept, activitypub.ContentType) ||
+		(strings.Contains(accept, "application/ld+json") && strings.Contains(accept, "activitystreams"))
+}
+
+// activityJSON writes v as JSON with an ActivityPub or WebFinger media type
+func activityJSON(c echo.Context, contentType string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.Blob(http.StatusOK, contentType+"; charset=utf-8", data)
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cassiascheffer/willow_camp/internal/blog/middleware"
+	"github.com/cassiascheffer/willow_camp/internal/helpers"
+	"github.com/cassiascheffer/willow_camp/internal/models"
+	"github.com/labstack/echo/v4"
+)
+
+// ArchiveIndex lists the months the blog published posts in, by year
+func (h *Handlers) ArchiveIndex(c echo.Context) error {
+	blog := middleware.GetBlog(c)
+	if blog == nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Blog not found in context")
+	}
+
+	months, err := h.repos.Post.CountPublishedByMonth(c.Request().Context(), blog.ID)
+	if err != nil {
+		getLogger(c).Error("Failed to count posts by month", "blog_id", blog.ID, "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load archive")
+	}
+
+	data := map[string]interface{}{
+		"Blog":  blog,
+		"Title": "Archive - " + getTitle(blog),
+		"Years": models.GroupArchiveMonths(months),
+	}
+
+	return h.renderTemplate(c, "archive_index.html", data)
+}
+
+// MonthArchive shows the posts published in a month, at /:year/:month
+func (h *Handlers) MonthArchive(c echo.Context) error {
+	blog := middleware.GetBlog(c)
+	if blog == nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Blog not found in context")
+	}
+
+	year, ok := helpers.ParseArchiveYear(c.Param("year"))
+	if !ok {
+		return echo.NewHTTPError(http.StatusNotFound, "Page not found")
+	}
+	month, ok := helpers.ParseArchiveMonth(c.Param("month"))
+	if !ok {
+		return echo.NewHTTPError(http.StatusNotFound, "Page not found")
+	}
+
+	return h.renderArchive(c, blog, year, month)
+}
+
+// renderArchive shows the posts published in a year, or in one of its months
+// when month isn't 0. /:year shares its route with posts, so PostShow sends
+// years here.
+func (h *Handlers) renderArchive(c echo.Context, blog *models.Blog, year int, month time.Month) error {
+	logger := getLogger(c)
+
+	// Get page number from query param
+	page := 1
+	if pageStr := c.QueryParam("page"); pageStr != "" {
+		if p, err := strconv.Atoi(pageStr); err == nil && p > 0 {
+			page = p
+		}
+	}
+
+	// The month counts give the year's months and how many posts to page
+	// through, without counting again
+	months, err := h.repos.Post.CountPublishedByMonth(c.Request().Context(), blog.ID)
+	if err != nil {
+		logger.Error("Failed to count posts by month", "blog_id", blog.ID, "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load archive")
+	}
+	var yearMonths []models.ArchiveMonth
+	totalPosts := 0
+	for _, m := range months {
+		if m.Year != year {
+			continue
+		}
+		yearMonths = append(yearMonths, m)
+		if month == 0 || m.Month == month {
+			totalPosts += m.Count
+		}
+	}
+	if totalPosts == 0 {
+		return echo.NewHTTPError(http.StatusNotFound, "No posts from then")
+	}
+	totalPages := (totalPosts + postsPerPage - 1) / postsPerPage
+
+	heading := strconv.Itoa(year)
+	from := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(1, 0, 0)
+	if month != 0 {
+		heading = month.String() + " " + heading
+		from = time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+		to = from.AddDate(0, 1, 0)
+	}
+
+	posts, err := h.repos.Post.ListPublishedBetween(c.Request().Context(), blog.ID, from, to, postsPerPage, (page-1)*postsPerPage)
+	if err != nil {
+		logger.Error("Failed to load posts for archive", "blog_id", blog.ID, "year", year, "month", int(month), "page", page, "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load posts")
+	}
+
+	// Load tags for every listed post in one query
+	h.loadPostTags(c, posts)
+	countWords(blog, posts)
+
+	data := map[string]interface{}{
+		"Blog":        blog,
+		"Title":       "Posts from " + heading + " - " + getTitle(blog),
+		"Posts":       posts,
+		"Year":        year,
+		"Month":       month,
+		"Months":      yearMonths,
+		"CurrentPage": page,
+		"TotalPages":  totalPages,
+	}
+
+	return h.renderTemplate(c, "archive_show.html", data)
+}
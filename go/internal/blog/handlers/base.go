@@ -1,26 +1,52 @@
 package handlers
 
 import (
-	"html/template"
 	"net/http"
+	"strings"
 
+	"github.com/cassiascheffer/willow_camp/internal/access"
+	"github.com/cassiascheffer/willow_camp/internal/activitypub"
+	"github.com/cassiascheffer/willow_camp/internal/assets"
 	"github.com/cassiascheffer/willow_camp/internal/auth"
 	"github.com/cassiascheffer/willow_camp/internal/blog/middleware"
-	"github.com/cassiascheffer/willow_camp/internal/helpers"
+	"github.com/cassiascheffer/willow_camp/internal/comments"
+	"github.com/cassiascheffer/willow_camp/internal/diagrams"
+	"github.com/cassiascheffer/willow_camp/internal/embeds"
 	"github.com/cassiascheffer/willow_camp/internal/logging"
+	"github.com/cassiascheffer/willow_camp/internal/markdown"
+	"github.com/cassiascheffer/willow_camp/internal/media"
 	"github.com/cassiascheffer/willow_camp/internal/models"
+	"github.com/cassiascheffer/willow_camp/internal/newsletter"
+	"github.com/cassiascheffer/willow_camp/internal/postbody"
+	"github.com/cassiascheffer/willow_camp/internal/previews"
 	"github.com/cassiascheffer/willow_camp/internal/repository"
+	"github.com/cassiascheffer/willow_camp/internal/theme"
+	"github.com/cassiascheffer/willow_camp/internal/views"
+	"github.com/cassiascheffer/willow_camp/internal/webmentions"
+	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 )
 
 const postsPerPage = 50
 
+// relatedPostsLimit is how many related posts are shown under a post
+const relatedPostsLimit = 3
+
 // Handlers holds blog handler dependencies
 type Handlers struct {
 	repos       *repository.Repositories
 	auth        *auth.Auth
 	baseDomain  string
 	homeHandler func(c echo.Context) error
+	newsletter  *newsletter.Service
+	media       *media.Service
+	diagrams    *diagrams.Service
+	embeds      *embeds.Service
+	previews    *previews.Service
+	webmentions *webmentions.Service
+	activitypub *activitypub.Service
+	comments    *comments.Service
+	access      *access.Service
 }
 
 // New creates a new blog Handlers instance
@@ -37,6 +63,58 @@ func (h *Handlers) SetHomeHandler(handler func(c echo.Context) error) {
 	h.homeHandler = handler
 }
 
+// SetNewsletter sets the email newsletter service used by the subscribe pages
+func (h *Handlers) SetNewsletter(service *newsletter.Service) {
+	h.newsletter = service
+}
+
+// SetMedia sets the media service used to serve uploaded files
+func (h *Handlers) SetMedia(service *media.Service) {
+	h.media = service
+}
+
+// SetDiagrams sets the service holding server-rendered mermaid diagrams
+func (h *Handlers) SetDiagrams(service *diagrams.Service) {
+	h.diagrams = service
+}
+
+// SetEmbeds sets the service holding cached link embeds
+func (h *Handlers) SetEmbeds(service *embeds.Service) {
+	h.embeds = service
+}
+
+// SetPreviews sets the service holding cached link previews
+func (h *Handlers) SetPreviews(service *previews.Service) {
+	h.previews = service
+}
+
+// postBody returns the services post markdown is rendered with
+func (h *Handlers) postBody() postbody.Services {
+	return postbody.Services{Media: h.media, Diagrams: h.diagrams, Embeds: h.embeds, Previews: h.previews}
+}
+
+// SetWebmentions sets the service that accepts Webmentions for posts
+func (h *Handlers) SetWebmentions(service *webmentions.Service) {
+	h.webmentions = service
+}
+
+// SetActivityPub sets the service that lets the blog be followed from the
+// fediverse
+func (h *Handlers) SetActivityPub(service *activitypub.Service) {
+	h.activitypub = service
+}
+
+// SetComments sets the service that takes readers' comments on posts
+func (h *Handlers) SetComments(service *comments.Service) {
+	h.comments = service
+}
+
+// SetAccess sets the service remembering which protected posts a reader may
+// read
+func (h *Handlers) SetAccess(service *access.Service) {
+	h.access = service
+}
+
 // getLogger retrieves the logger from the Echo context
 func getLogger(c echo.Context) *logging.Logger {
 	if logger, ok := c.Get("logger").(*logging.Logger); ok {
@@ -60,6 +138,54 @@ func getTitle(blog *models.Blog) string {
 	return "willow.camp"
 }
 
+// loadPostTags fills in Tags on every post with a single query.
+// Tags are decoration, so a failure is logged rather than returned.
+func (h *Handlers) loadPostTags(c echo.Context, posts []*models.Post) {
+	if len(posts) == 0 {
+		return
+	}
+
+	ids := make([]uuid.UUID, len(posts))
+	for i, post := range posts {
+		ids[i] = post.ID
+	}
+
+	tagsByPost, err := h.repos.Tag.FindTagsForPosts(c.Request().Context(), ids)
+	if err != nil {
+		getLogger(c).Warn("Failed to load tags for posts", "error", err)
+		return
+	}
+
+	for _, post := range posts {
+		post.Tags = tagsByPost[post.ID]
+	}
+}
+
+// countWords fills in WordCount and ReadingTime on every post
+func countWords(blog *models.Blog, posts []*models.Post) {
+	md := markdown.For(blog.MarkdownOptions)
+	for _, post := range posts {
+		if post.BodyMarkdown == nil || *post.BodyMarkdown == "" {
+			continue
+		}
+		doc := md.Analyze(*post.BodyMarkdown)
+		post.WordCount = doc.WordCount
+		post.ReadingTime = doc.ReadingTime
+	}
+}
+
+// absoluteURL resolves a link from a post against the blog's base URL, e.g.
+// for an og:image. It returns "" for relative paths it can't place.
+func absoluteURL(baseURL, target string) string {
+	switch {
+	case strings.HasPrefix(target, "https://"), strings.HasPrefix(target, "http://"):
+		return target
+	case strings.HasPrefix(target, "/") && !strings.HasPrefix(target, "//"):
+		return baseURL + target
+	}
+	return ""
+}
+
 // enrichTemplateData adds layout requirements to template data
 func (h *Handlers) enrichTemplateData(c echo.Context, blog *models.Blog, data map[string]interface{}) map[string]interface{} {
 	// Ensure we have all required fields for the layout
@@ -70,12 +196,18 @@ func (h *Handlers) enrichTemplateData(c echo.Context, blog *models.Blog, data ma
 	// Blog title for display
 	data["BlogTitle"] = getTitle(blog)
 
-	// OpenMoji favicon filename
-	emojiFilename := "1F3D5" // Default camping emoji
-	if blog.FaviconEmoji != nil && *blog.FaviconEmoji != "" {
-		emojiFilename = helpers.EmojiToOpenmojiFilename(*blog.FaviconEmoji)
+	// OpenMoji favicon URLs
+	emoji := ""
+	if blog.FaviconEmoji != nil {
+		emoji = *blog.FaviconEmoji
 	}
-	data["EmojiFilename"] = emojiFilename
+	data["Favicon"] = assets.EmojiFavicon(emoji)
+
+	// Theme customizations, linked so browsers can cache them
+	data["ThemeStylesheet"] = theme.URL(blog)
+
+	// Code highlighting colors for the blog's style or theme
+	data["HighlightStylesheet"] = theme.HighlightURL(blog)
 
 	// Fetch published pages for navigation
 	pages, err := h.repos.Post.ListPublishedPages(c.Request().Context(), blog.ID)
@@ -114,6 +246,11 @@ func (h *Handlers) enrichTemplateData(c echo.Context, blog *models.Blog, data ma
 
 // renderTemplate renders a blog template with layout
 func (h *Handlers) renderTemplate(c echo.Context, templateName string, data interface{}) error {
+	return h.renderTemplateWithStatus(c, http.StatusOK, templateName, data)
+}
+
+// renderTemplateWithStatus renders a blog template with layout and the given status code
+func (h *Handlers) renderTemplateWithStatus(c echo.Context, status int, templateName string, data interface{}) error {
 	logger := getLogger(c)
 
 	// Convert data to map and enrich with layout requirements
@@ -123,34 +260,15 @@ func (h *Handlers) renderTemplate(c echo.Context, templateName string, data inte
 		dataMap = map[string]interface{}{"Data": data}
 	}
 
+	name := views.BlogTemplate("", templateName)
 	if blog != nil {
 		dataMap = h.enrichTemplateData(c, blog, dataMap)
+		name = views.BlogTemplate(blog.ThemePack, templateName)
 	}
 
-	// Create template with helper functions
-	tmpl := template.New("layout.html").Funcs(template.FuncMap{
-		"add": func(a, b int) int { return a + b },
-		"sub": func(a, b int) int { return a - b },
-		"heroicon": func(name string, class string) template.HTML {
-			return helpers.Icon("24/outline/"+name, class)
-		},
-		"heroiconMini": func(name string, class string) template.HTML {
-			return helpers.Icon("20/solid/"+name, class)
-		},
-	})
-
-	// Parse layout and content templates
-	tmpl, err := tmpl.ParseFiles(
-		"internal/blog/templates/layout.html",
-		"internal/blog/templates/"+templateName,
-	)
-	if err != nil {
-		logger.Error("Failed to parse template", "template", templateName, "error", err)
+	if err := c.Render(status, name, dataMap); err != nil {
+		logger.Error("Failed to render template", "template", templateName, "error", err)
 		return echo.NewHTTPError(http.StatusInternalServerError, "Template error: "+err.Error())
 	}
-
-	c.Response().Header().Set("Content-Type", "text/html; charset=utf-8")
-	c.Response().WriteHeader(http.StatusOK)
-
-	return tmpl.Execute(c.Response().Writer, dataMap)
+	return nil
 }
@@ -1,13 +1,18 @@
 package handlers
 
 import (
+	"errors"
 	"html/template"
 	"net/http"
 	"strconv"
 
+	"github.com/cassiascheffer/willow_camp/internal/activitypub"
 	"github.com/cassiascheffer/willow_camp/internal/blog/middleware"
+	"github.com/cassiascheffer/willow_camp/internal/comments"
+	"github.com/cassiascheffer/willow_camp/internal/helpers"
 	"github.com/cassiascheffer/willow_camp/internal/markdown"
 	"github.com/cassiascheffer/willow_camp/internal/models"
+	"github.com/cassiascheffer/willow_camp/internal/repository"
 	"github.com/labstack/echo/v4"
 )
 
@@ -61,6 +66,11 @@ func (h *Handlers) BlogIndex(c echo.Context) error {
 
 	totalPages := (totalPosts + postsPerPage - 1) / postsPerPage
 
+	// Load tags for every listed post in one query
+	h.loadPostTags(c, posts)
+	countWords(blog, featuredPosts)
+	countWords(blog, posts)
+
 	// Prepare template data
 	data := map[string]interface{}{
 		"Blog":          blog,
@@ -87,6 +97,15 @@ func (h *Handlers) PostShow(c echo.Context) error {
 
 	// Fetch post by slug
 	post, err := h.repos.Post.FindBySlug(c.Request().Context(), blog.ID, slug)
+
+	// Years without a published post of their own are date archives. New
+	// posts never get a year as their slug, but older ones keep theirs.
+	if errors.Is(err, repository.ErrPostNotFound) || (err == nil && !post.IsPublished()) {
+		if year, ok := helpers.ParseArchiveYear(slug); ok {
+			return h.renderArchive(c, blog, year, 0)
+		}
+	}
+
 	if err != nil {
 		logger.Warn("Post not found by slug", "blog_id", blog.ID, "slug", slug, "error", err)
 		return echo.NewHTTPError(http.StatusNotFound, "Post not found")
@@ -97,21 +116,73 @@ func (h *Handlers) PostShow(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusNotFound, "Post not found")
 	}
 
-	// Render markdown content
+	// Only public posts are for search engines to find
+	if !post.IsPublic() {
+		c.Response().Header().Set("X-Robots-Tag", "noindex")
+	}
+
+	// Protected posts show a password prompt or member sign-in instead
+	if !h.canRead(c, blog, post) {
+		return h.renderLocked(c, http.StatusOK, blog, post, "")
+	}
+	if post.IsProtected() {
+		c.Response().Header().Set("Cache-Control", "private, no-store")
+	}
+
+	// Fediverse servers look posts up by URL to show them. Both the page and
+	// the JSON vary by Accept, so shared caches keep them apart.
+	if h.activitypub != nil && !post.IsPage() && post.IsPublic() {
+		c.Response().Header().Add("Vary", "Accept")
+		if wantsActivity(c) {
+			article, err := h.activitypub.Article(blog, post)
+			if err != nil {
+				logger.Error("Failed to build article", "blog_id", blog.ID, "post_id", post.ID, "error", err)
+				return echo.NewHTTPError(http.StatusInternalServerError, "Failed to render post")
+			}
+			article.Context = activitypub.ActivityStreams
+			return activityJSON(c, activitypub.ContentType, article)
+		}
+	}
+
+	data, err := h.postPageData(c, blog, post)
+	if err != nil {
+		return err
+	}
+
+	// Advertise where other sites can send Webmentions for this post
+	if h.webmentions != nil {
+		c.Response().Header().Set("Link", `</webmention>; rel="webmention"`)
+	}
+
+	return h.renderTemplate(c, "post_show.html", data)
+}
+
+// postPageData loads and renders everything post_show.html shows for a post
+func (h *Handlers) postPageData(c echo.Context, blog *models.Blog, post *models.Post) (map[string]interface{}, error) {
+	logger := getLogger(c)
+
+	// Render markdown content, collecting the table of contents and word count
 	var renderedContent template.HTML
+	var toc markdown.TOC
+	var ogImage string
 	if post.BodyMarkdown != nil && *post.BodyMarkdown != "" {
-		rendered, err := markdown.Render(*post.BodyMarkdown)
+		doc, err := h.postBody().Render(c.Request().Context(), blog, getLogger(c), *post.BodyMarkdown)
 		if err != nil {
-			logger.Error("Failed to render post markdown", "blog_id", blog.ID, "post_id", post.ID, "slug", slug, "error", err)
-			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to render markdown")
+			logger.Error("Failed to render post markdown", "blog_id", blog.ID, "post_id", post.ID, "error", err)
+			return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to render markdown")
 		}
-		renderedContent = rendered
+		renderedContent = doc.HTML
+		toc = doc.TOC
+		post.WordCount = doc.WordCount
+		post.ReadingTime = doc.ReadingTime
+		// The first image becomes the social card
+		ogImage = absoluteURL(getProtocol(c)+"://"+c.Request().Host, doc.FirstImage)
 	}
 
 	// Render post footer if present
 	var postFooter template.HTML
 	if blog.PostFooterMarkdown != nil && *blog.PostFooterMarkdown != "" {
-		rendered, err := markdown.Render(*blog.PostFooterMarkdown)
+		rendered, err := markdown.For(blog.MarkdownOptions).Render(*blog.PostFooterMarkdown)
 		if err != nil {
 			// Don't fail the whole page if footer fails
 			logger.Warn("Failed to render post footer markdown", "blog_id", blog.ID, "error", err)
@@ -136,6 +207,55 @@ func (h *Handlers) PostShow(c echo.Context) error {
 		authorName = *author.Name
 	}
 
+	// Load approved Webmentions shown under the post
+	mentions, err := h.repos.Webmention.ListApprovedForPost(c.Request().Context(), post.ID)
+	if err != nil {
+		// Don't fail if mentions fail to load
+		logger.Warn("Failed to load webmentions for post", "blog_id", blog.ID, "post_id", post.ID, "error", err)
+		mentions = nil
+	}
+
+	// Load approved comments when the post takes them
+	var postComments []*models.Comment
+	commentsEnabled := h.comments != nil && comments.Open(blog, post)
+	if commentsEnabled {
+		postComments, err = h.repos.Comment.ListApprovedForPost(c.Request().Context(), post.ID)
+		if err != nil {
+			// Don't fail if comments fail to load
+			logger.Warn("Failed to load comments for post", "blog_id", blog.ID, "post_id", post.ID, "error", err)
+			postComments = nil
+		}
+	}
+
+	// Place the post among the other parts of its series
+	var seriesPart *models.SeriesPart
+	series, err := h.repos.Series.FindForPost(c.Request().Context(), post.ID)
+	if err == nil {
+		parts, err := h.repos.Post.ListInSeries(c.Request().Context(), series.ID, post.ID)
+		if err != nil {
+			// Don't fail if the series fails to load
+			logger.Warn("Failed to load series parts", "blog_id", blog.ID, "post_id", post.ID, "error", err)
+		} else {
+			seriesPart = models.SeriesPartFor(series, parts, post.ID)
+		}
+	} else if !errors.Is(err, repository.ErrSeriesNotFound) {
+		logger.Warn("Failed to load series for post", "blog_id", blog.ID, "post_id", post.ID, "error", err)
+	}
+
+	// Load the posts around this one and those sharing its tags
+	previousPost, nextPost, err := h.repos.Post.FindAdjacentPublished(c.Request().Context(), post)
+	if err != nil {
+		// Don't fail if the neighbours fail to load
+		logger.Warn("Failed to load adjacent posts", "blog_id", blog.ID, "post_id", post.ID, "error", err)
+	}
+	relatedPosts, err := h.repos.Post.ListRelated(c.Request().Context(), post, relatedPostsLimit)
+	if err != nil {
+		// Don't fail if related posts fail to load
+		logger.Warn("Failed to load related posts", "blog_id", blog.ID, "post_id", post.ID, "error", err)
+		relatedPosts = nil
+	}
+	countWords(blog, relatedPosts)
+
 	// Prepare template data
 	title := "Post"
 	if post.Title != nil {
@@ -174,16 +294,25 @@ func (h *Handlers) PostShow(c echo.Context) error {
 		"Title":                title,
 		"Post":                 post,
 		"RenderedContent":      renderedContent,
+		"TOC":                  toc,
 		"PostFooter":           postFooter,
 		"Tags":                 tags,
 		"AuthorName":           authorName,
+		"Mentions":             mentions,
+		"Comments":             postComments,
+		"CommentsEnabled":      commentsEnabled,
+		"SeriesPart":           seriesPart,
+		"PreviousPost":         previousPost,
+		"NextPost":             nextPost,
+		"RelatedPosts":         relatedPosts,
 		"OGType":               ogType,
 		"OGDescription":        ogDescription,
+		"OGImage":              ogImage,
 		"MetaDescription":      metaDescription,
 		"ArticlePublishedTime": articlePublishedTime,
 		"ArticleAuthor":        authorName,
 		"ArticleTags":          articleTags,
 	}
 
-	return h.renderTemplate(c, "post_show.html", data)
+	return data, nil
 }
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/cassiascheffer/willow_camp/internal/blog/middleware"
+	"github.com/cassiascheffer/willow_camp/internal/comments"
+	"github.com/cassiascheffer/willow_camp/internal/helpers"
+	"github.com/labstack/echo/v4"
+)
+
+// SubmitComment takes a reader's comment on a post and holds it for the
+// blog owner to approve
+func (h *Handlers) SubmitComment(c echo.Context) error {
+	logger := getLogger(c)
+	blog := middleware.GetBlog(c)
+	if blog == nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Blog not found in context")
+	}
+	if h.comments == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Comments are not available")
+	}
+
+	post, err := h.repos.Post.FindBySlug(c.Request().Context(), blog.ID, c.Param("slug"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Post not found")
+	}
+	if !h.canRead(c, blog, post) {
+		return echo.NewHTTPError(http.StatusForbidden, "This post is protected")
+	}
+
+	_, err = h.comments.Submit(c.Request().Context(), blog, post, comments.Submission{
+		Name:     c.FormValue("name"),
+		Email:    c.FormValue("email"),
+		URL:      c.FormValue("url"),
+		Body:     c.FormValue("body"),
+		Honeypot: c.FormValue("website"),
+		IP:       c.RealIP(),
+	})
+	switch {
+	case errors.Is(err, comments.ErrCommentsClosed):
+		return echo.NewHTTPError(http.StatusNotFound, "Comments are closed")
+	case errors.Is(err, comments.ErrInvalidComment):
+		return h.renderStatusPage(c, http.StatusUnprocessableEntity, "Check your comment",
+			"Comments need your name and some text, and a website has to be an http(s) link. Please go back and try again.")
+	case errors.Is(err, comments.ErrRateLimited):
+		return h.renderStatusPage(c, http.StatusTooManyRequests, "Slow down",
+			"You've left several comments in the last few minutes. Please wait a little before commenting again.")
+	case err != nil:
+		logger.Error("Failed to submit comment", "blog_id", blog.ID, "post_id", post.ID, "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to submit comment")
+	}
+
+	// Dropped spam gets the same response, so bots can't tell
+	return h.renderStatusPage(c, http.StatusOK, "Thanks for your comment",
+		"It will show under the post once the author approves it.")
+}
+
+// CommentsFeed serves an Atom feed of a post's approved comments
+func (h *Handlers) CommentsFeed(c echo.Context) error {
+	blog := middleware.GetBlog(c)
+	if blog == nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Blog not found in context")
+	}
+
+	post, err := h.repos.Post.FindBySlug(c.Request().Context(), blog.ID, c.Param("slug"))
+	// Feed readers can't unlock protected posts, so their comments stay on the post
+	if err != nil || !comments.Open(blog, post) || post.Slug == nil || post.IsProtected() {
+		return echo.NewHTTPError(http.StatusNotFound, "Post not found")
+	}
+
+	list, err := h.repos.Comment.ListApprovedForPost(c.Request().Context(), post.ID)
+	if err != nil {
+		getLogger(c).Error("Failed to load comments", "blog_id", blog.ID, "post_id", post.ID, "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load comments")
+	}
+
+	postURL := getProtocol(c) + "://" + c.Request().Host + "/" + *post.Slug
+	title := stringOrDefault(post.Title, *post.Slug)
+
+	// Newest first, like the blog's own feeds
+	entries := make([]AtomEntry, 0, len(list))
+	for i := len(list) - 1; i >= 0; i-- {
+		comment := list[i]
+		entries = append(entries, AtomEntry{
+			ID:      postURL + "#comment-" + comment.ID.String(),
+			Title:   "Comment by " + comment.AuthorName,
+			Link:    &AtomLink{Href: postURL + "#comment-" + comment.ID.String(), Rel: "alternate", Type: "text/html"},
+			Updated: comment.CreatedAt.Format(time.RFC3339),
+			Author:  &AtomAuthor{Name: comment.AuthorName},
+			Content: &AtomContent{Type: "html", Content: string(helpers.RenderCommentHTML(comment.Body))},
+		})
+	}
+
+	updated := time.Now().Format(time.RFC3339)
+	if len(entries) > 0 {
+		updated = entries[0].Updated
+	}
+
+	feed := AtomFeed{
+		Xmlns:   "http://www.w3.org/2005/Atom",
+		Media:   mediaNamespace,
+		ID:      postURL + "#comments",
+		Title:   "Comments on " + title,
+		Updated: updated,
+		Links: []AtomLink{
+			{Href: postURL + "/comments.atom", Rel: "self", Type: "application/atom+xml"},
+			{Href: postURL, Rel: "alternate", Type: "text/html"},
+		},
+		Subtitle: "Comments on " + title + " from " + getTitle(blog),
+		Entries:  entries,
+	}
+
+	c.Response().Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	c.Response().WriteHeader(http.StatusOK)
+
+	encoder := xml.NewEncoder(c.Response().Writer)
+	encoder.Indent("", "  ")
+	c.Response().Writer.Write([]byte(xml.Header))
+
+	return encoder.Encode(feed)
+}
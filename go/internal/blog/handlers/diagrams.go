@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// diagramFile matches the file names diagrams are served under, <sha256>.svg
+var diagramFile = regexp.MustCompile(`^[0-9a-f]{64}\.svg$`)
+
+// DiagramShow serves a rendered mermaid diagram as an image, for feeds and
+// email where the inline SVG can't be used. Diagrams are named by the hash of
+// their source, so they never change.
+func (h *Handlers) DiagramShow(c echo.Context) error {
+	file := c.Param("file")
+	if h.diagrams == nil || !diagramFile.MatchString(file) {
+		return echo.NewHTTPError(http.StatusNotFound, "Diagram not found")
+	}
+	hash := strings.TrimSuffix(file, ".svg")
+
+	header := c.Response().Header()
+	etag := `"` + hash + `"`
+	header.Set("ETag", etag)
+	header.Set("Cache-Control", "public, max-age=31536000, immutable")
+	if c.Request().Header.Get("If-None-Match") == etag {
+		return c.NoContent(http.StatusNotModified)
+	}
+
+	svg, ok, err := h.diagrams.SVG(c.Request().Context(), hash)
+	if err != nil {
+		getLogger(c).Error("Failed to load diagram", "hash", hash, "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load diagram")
+	}
+	if !ok {
+		header.Del("Cache-Control")
+		return echo.NewHTTPError(http.StatusNotFound, "Diagram not found")
+	}
+
+	// Opened directly, the SVG must not be able to run anything on the blog's origin
+	header.Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; img-src data:; sandbox")
+	header.Set("X-Content-Type-Options", "nosniff")
+	return c.Blob(http.StatusOK, "image/svg+xml", []byte(svg))
+}
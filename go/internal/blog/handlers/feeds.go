@@ -17,6 +17,7 @@ type RSS struct {
 	XMLName xml.Name `xml:"rss"`
 	Version string   `xml:"version,attr"`
 	Atom    string   `xml:"xmlns:atom,attr"`
+	Media   string   `xml:"xmlns:media,attr"`
 	Channel *Channel `xml:"channel"`
 }
 
@@ -36,17 +37,28 @@ type AtomLink struct {
 }
 
 type RSSItem struct {
-	Title       string `xml:"title"`
-	Link        string `xml:"link"`
-	Description string `xml:"description"`
-	PubDate     string `xml:"pubDate"`
-	GUID        string `xml:"guid"`
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	Description string   `xml:"description"`
+	PubDate     string   `xml:"pubDate"`
+	GUID        string   `xml:"guid"`
+	Categories  []string `xml:"category"`
+	Thumbnail   *MediaThumbnail `xml:"media:thumbnail"`
 }
 
+// MediaThumbnail is a Media RSS thumbnail, the post's first image
+type MediaThumbnail struct {
+	URL string `xml:"url,attr"`
+}
+
+// mediaNamespace is the Media RSS namespace used for thumbnails
+const mediaNamespace = "http://search.yahoo.com/mrss/"
+
 // Atom feed structures
 type AtomFeed struct {
 	XMLName  xml.Name     `xml:"feed"`
 	Xmlns    string       `xml:"xmlns,attr"`
+	Media    string       `xml:"xmlns:media,attr"`
 	ID       string       `xml:"id"`
 	Title    string       `xml:"title"`
 	Updated  string       `xml:"updated"`
@@ -61,12 +73,20 @@ type AtomAuthor struct {
 }
 
 type AtomEntry struct {
-	ID      string      `xml:"id"`
-	Title   string      `xml:"title"`
-	Link    *AtomLink   `xml:"link"`
-	Updated string      `xml:"updated"`
-	Summary *AtomText   `xml:"summary"`
-	Content *AtomContent `xml:"content"`
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Link       *AtomLink      `xml:"link"`
+	Updated    string         `xml:"updated"`
+	Author     *AtomAuthor    `xml:"author"`
+	Summary    *AtomText      `xml:"summary"`
+	Content    *AtomContent   `xml:"content"`
+	Categories []AtomCategory `xml:"category"`
+	Thumbnail  *MediaThumbnail `xml:"media:thumbnail"`
+}
+
+type AtomCategory struct {
+	Term  string `xml:"term,attr"`
+	Label string `xml:"label,attr,omitempty"`
 }
 
 type AtomText struct {
@@ -98,6 +118,16 @@ type JSONFeedItem struct {
 	DatePublished string           `json:"date_published"`
 	DateModified  string           `json:"date_modified"`
 	Author        *JSONFeedAuthor  `json:"author"`
+	Tags          []string         `json:"tags,omitempty"`
+	Image         string           `json:"image,omitempty"`
+	WillowCamp    *JSONFeedExtension `json:"_willow_camp,omitempty"`
+}
+
+// JSONFeedExtension adds reading details to items. JSON Feed reserves keys
+// starting with an underscore for extensions.
+type JSONFeedExtension struct {
+	WordCount   int `json:"word_count"`
+	ReadingTime int `json:"reading_time"`
 }
 
 type JSONFeedAuthor struct {
@@ -117,12 +147,16 @@ func (h *Handlers) RSSFeed(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load user")
 	}
 
-	// Get recent published posts (limit 20)
-	posts, err := h.repos.Post.ListPublished(c.Request().Context(), blog.ID, 20, 0)
+	// Get recent public posts (limit 20)
+	posts, err := h.repos.Post.ListPublic(c.Request().Context(), blog.ID, 20, 0)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load posts")
 	}
 
+	// Load tags for every post in one query
+	h.loadPostTags(c, posts)
+	resolvers := h.feedResolvers(c, posts)
+
 	// Get URL components from request
 	protocol := getProtocol(c)
 	host := c.Request().Host
@@ -139,13 +173,13 @@ func (h *Handlers) RSSFeed(c echo.Context) error {
 		}
 
 		// Render markdown to HTML
-		bodyHTML, err := markdown.RenderString(*post.BodyMarkdown)
+		doc, err := markdown.For(blog.MarkdownOptions).RenderDocument(*post.BodyMarkdown, resolvers)
 		if err != nil {
-			bodyHTML = ""
+			doc = &markdown.Document{}
 		}
 
 		// Sanitize HTML for feed
-		sanitizedHTML := helpers.SanitizeHTMLForFeed(bodyHTML, protocol, host, port, "/"+*post.Slug)
+		sanitizedHTML := helpers.SanitizeHTMLForFeed(string(doc.HTML), protocol, host, port, "/"+*post.Slug)
 
 		item := RSSItem{
 			Title:       *post.Title,
@@ -153,6 +187,12 @@ func (h *Handlers) RSSFeed(c echo.Context) error {
 			Description: sanitizedHTML,
 			GUID:        baseURL + "/" + *post.Slug,
 		}
+		for _, tag := range post.Tags {
+			item.Categories = append(item.Categories, tag.Name)
+		}
+		if image := absoluteURL(baseURL, doc.FirstImage); image != "" {
+			item.Thumbnail = &MediaThumbnail{URL: image}
+		}
 
 		if post.PublishedAt != nil {
 			item.PubDate = post.PublishedAt.Format(time.RFC1123Z)
@@ -166,6 +206,7 @@ func (h *Handlers) RSSFeed(c echo.Context) error {
 	rss := RSS{
 		Version: "2.0",
 		Atom:    "http://www.w3.org/2005/Atom",
+		Media:   mediaNamespace,
 		Channel: &Channel{
 			Title:       getTitle(blog),
 			Link:        baseURL,
@@ -206,12 +247,65 @@ func (h *Handlers) AtomFeed(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load user")
 	}
 
-	// Get recent published posts (limit 20)
-	posts, err := h.repos.Post.ListPublished(c.Request().Context(), blog.ID, 20, 0)
+	// Get recent public posts (limit 20)
+	posts, err := h.repos.Post.ListPublic(c.Request().Context(), blog.ID, 20, 0)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load posts")
 	}
 
+	entries := h.atomEntries(c, blog, user, posts)
+	baseURL := getProtocol(c) + "://" + c.Request().Host
+
+	// Get updated timestamp from first post
+	updated := time.Now().Format(time.RFC3339)
+	if len(posts) > 0 && posts[0].PublishedAt != nil {
+		updated = posts[0].PublishedAt.Format(time.RFC3339)
+	}
+
+	// Build Atom feed
+	userName := getUserName(user)
+	feed := AtomFeed{
+		Xmlns:    "http://www.w3.org/2005/Atom",
+		Media:    mediaNamespace,
+		ID:       baseURL,
+		Title:    getTitle(blog),
+		Updated:  updated,
+		Author:   &AtomAuthor{Name: userName},
+		Links: []AtomLink{
+			{
+				Href: baseURL + "/feed.atom",
+				Rel:  "self",
+				Type: "application/atom+xml",
+			},
+			{
+				Href: baseURL,
+				Rel:  "alternate",
+				Type: "text/html",
+			},
+		},
+		Subtitle: "Latest posts from " + userName,
+		Entries:  entries,
+	}
+
+	// Set content type and encode XML
+	c.Response().Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	c.Response().WriteHeader(http.StatusOK)
+
+	encoder := xml.NewEncoder(c.Response().Writer)
+	encoder.Indent("", "  ")
+
+	// Write XML declaration
+	c.Response().Writer.Write([]byte(xml.Header))
+
+	return encoder.Encode(feed)
+}
+
+// atomEntries renders posts as the entries of an Atom feed
+func (h *Handlers) atomEntries(c echo.Context, blog *models.Blog, user *models.User, posts []*models.Post) []AtomEntry {
+	// Load tags for every post in one query
+	h.loadPostTags(c, posts)
+	resolvers := h.feedResolvers(c, posts)
+
 	// Get URL components from request
 	protocol := getProtocol(c)
 	host := c.Request().Host
@@ -228,13 +322,13 @@ func (h *Handlers) AtomFeed(c echo.Context) error {
 		}
 
 		// Render markdown to HTML
-		bodyHTML, err := markdown.RenderString(*post.BodyMarkdown)
+		doc, err := markdown.For(blog.MarkdownOptions).RenderDocument(*post.BodyMarkdown, resolvers)
 		if err != nil {
-			bodyHTML = ""
+			doc = &markdown.Document{}
 		}
 
 		// Sanitize HTML for feed
-		sanitizedHTML := helpers.SanitizeHTMLForFeed(bodyHTML, protocol, host, port, "/"+*post.Slug)
+		sanitizedHTML := helpers.SanitizeHTMLForFeed(string(doc.HTML), protocol, host, port, "/"+*post.Slug)
 
 		// Build summary from meta description or title + author
 		userName := getUserName(user)
@@ -262,6 +356,12 @@ func (h *Handlers) AtomFeed(c echo.Context) error {
 				Content: sanitizedHTML,
 			},
 		}
+		for _, tag := range post.Tags {
+			entry.Categories = append(entry.Categories, AtomCategory{Term: stringOrDefault(tag.Slug, tag.Name), Label: tag.Name})
+		}
+		if image := absoluteURL(baseURL, doc.FirstImage); image != "" {
+			entry.Thumbnail = &MediaThumbnail{URL: image}
+		}
 
 		if post.PublishedAt != nil {
 			entry.Updated = post.PublishedAt.Format(time.RFC3339)
@@ -270,47 +370,7 @@ func (h *Handlers) AtomFeed(c echo.Context) error {
 		entries = append(entries, entry)
 	}
 
-	// Get updated timestamp from first post
-	updated := time.Now().Format(time.RFC3339)
-	if len(posts) > 0 && posts[0].PublishedAt != nil {
-		updated = posts[0].PublishedAt.Format(time.RFC3339)
-	}
-
-	// Build Atom feed
-	userName := getUserName(user)
-	feed := AtomFeed{
-		Xmlns:    "http://www.w3.org/2005/Atom",
-		ID:       baseURL,
-		Title:    getTitle(blog),
-		Updated:  updated,
-		Author:   &AtomAuthor{Name: userName},
-		Links: []AtomLink{
-			{
-				Href: baseURL + "/feed.atom",
-				Rel:  "self",
-				Type: "application/atom+xml",
-			},
-			{
-				Href: baseURL,
-				Rel:  "alternate",
-				Type: "text/html",
-			},
-		},
-		Subtitle: "Latest posts from " + userName,
-		Entries:  entries,
-	}
-
-	// Set content type and encode XML
-	c.Response().Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
-	c.Response().WriteHeader(http.StatusOK)
-
-	encoder := xml.NewEncoder(c.Response().Writer)
-	encoder.Indent("", "  ")
-
-	// Write XML declaration
-	c.Response().Writer.Write([]byte(xml.Header))
-
-	return encoder.Encode(feed)
+	return entries
 }
 
 // JSONFeed generates JSON Feed 1.1 for the blog
@@ -326,12 +386,16 @@ func (h *Handlers) JSONFeed(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load user")
 	}
 
-	// Get recent published posts (limit 20)
-	posts, err := h.repos.Post.ListPublished(c.Request().Context(), blog.ID, 20, 0)
+	// Get recent public posts (limit 20)
+	posts, err := h.repos.Post.ListPublic(c.Request().Context(), blog.ID, 20, 0)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load posts")
 	}
 
+	// Load tags for every post in one query
+	h.loadPostTags(c, posts)
+	resolvers := h.feedResolvers(c, posts)
+
 	// Get URL components from request
 	protocol := getProtocol(c)
 	host := c.Request().Host
@@ -348,13 +412,13 @@ func (h *Handlers) JSONFeed(c echo.Context) error {
 		}
 
 		// Render markdown to HTML
-		bodyHTML, err := markdown.RenderString(*post.BodyMarkdown)
+		doc, err := markdown.For(blog.MarkdownOptions).RenderDocument(*post.BodyMarkdown, resolvers)
 		if err != nil {
-			bodyHTML = ""
+			doc = &markdown.Document{}
 		}
 
 		// Sanitize HTML for feed
-		sanitizedHTML := helpers.SanitizeHTMLForFeed(bodyHTML, protocol, host, port, "/"+*post.Slug)
+		sanitizedHTML := helpers.SanitizeHTMLForFeed(string(doc.HTML), protocol, host, port, "/"+*post.Slug)
 
 		// Build content_text from meta description or title + author
 		userName := getUserName(user)
@@ -372,6 +436,11 @@ func (h *Handlers) JSONFeed(c echo.Context) error {
 			ContentHTML: sanitizedHTML,
 			ContentText: contentText,
 			Author:      &JSONFeedAuthor{Name: userName},
+			Image:       absoluteURL(baseURL, doc.FirstImage),
+			WillowCamp:  &JSONFeedExtension{WordCount: doc.WordCount, ReadingTime: doc.ReadingTime},
+		}
+		for _, tag := range post.Tags {
+			item.Tags = append(item.Tags, tag.Name)
 		}
 
 		if post.PublishedAt != nil {
@@ -411,11 +480,12 @@ func (h *Handlers) Subscribe(c echo.Context) error {
 	baseURL := protocol + "://" + host
 
 	data := map[string]interface{}{
-		"Blog":        blog,
-		"Title":       getTitle(blog) + " - Subscribe",
-		"RSSFeedURL":  baseURL + "/feed.rss",
-		"AtomFeedURL": baseURL + "/feed.atom",
-		"JSONFeedURL": baseURL + "/feed.json",
+		"Blog":         blog,
+		"Title":        getTitle(blog) + " - Subscribe",
+		"RSSFeedURL":   baseURL + "/feed.rss",
+		"AtomFeedURL":  baseURL + "/feed.atom",
+		"JSONFeedURL":  baseURL + "/feed.json",
+		"EmailEnabled": h.newsletter != nil,
 	}
 
 	return h.renderTemplate(c, "subscribe.html", data)
@@ -423,6 +493,23 @@ func (h *Handlers) Subscribe(c echo.Context) error {
 
 // Helper functions
 
+// postBodies returns the markdown of posts, e.g. to load their diagrams at once
+func postBodies(posts []*models.Post) []string {
+	bodies := make([]string, 0, len(posts))
+	for _, post := range posts {
+		if post.BodyMarkdown != nil {
+			bodies = append(bodies, *post.BodyMarkdown)
+		}
+	}
+	return bodies
+}
+
+// feedResolvers loads the diagrams, embeds and link previews of every post in
+// a feed at once
+func (h *Handlers) feedResolvers(c echo.Context, posts []*models.Post) markdown.Resolvers {
+	return h.postBody().Resolvers(c.Request().Context(), getLogger(c), postBodies(posts)...)
+}
+
 func getProtocol(c echo.Context) string {
 	if c.Request().TLS != nil {
 		return "https"
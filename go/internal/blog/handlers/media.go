@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/cassiascheffer/willow_camp/internal/blog/middleware"
+	"github.com/cassiascheffer/willow_camp/internal/media"
+	"github.com/cassiascheffer/willow_camp/internal/models"
+	"github.com/cassiascheffer/willow_camp/internal/repository"
+	"github.com/cassiascheffer/willow_camp/internal/storage"
+	"github.com/labstack/echo/v4"
+)
+
+// MediaShow serves an uploaded file from the blog's host
+func (h *Handlers) MediaShow(c echo.Context) error {
+	m, err := h.findMedia(c)
+	if err != nil {
+		return err
+	}
+
+	return h.serveMedia(c, m, `"`+m.Checksum+`"`, m.ContentType, m.ByteSize, func(ctx context.Context) (io.ReadCloser, error) {
+		return h.media.Open(ctx, m)
+	})
+}
+
+// MediaVariant serves a resized copy of an uploaded image, e.g. /media/<key>/480w.jpg
+func (h *Handlers) MediaVariant(c echo.Context) error {
+	m, err := h.findMedia(c)
+	if err != nil {
+		return err
+	}
+
+	// Variant names look like 480w.jpg
+	variant := c.Param("variant")
+	widthStr, _, _ := strings.Cut(variant, "w")
+	width, err := strconv.Atoi(widthStr)
+	if err != nil || media.VariantName(m, width) != variant || !media.HasVariant(m, width) {
+		return echo.NewHTTPError(http.StatusNotFound, "File not found")
+	}
+
+	return h.serveMedia(c, m, `"`+m.Checksum+"-"+widthStr+`"`, media.VariantContentType(m), 0, func(ctx context.Context) (io.ReadCloser, error) {
+		return h.media.OpenVariant(ctx, m, width)
+	})
+}
+
+// findMedia loads the blog's media named by the :key param
+func (h *Handlers) findMedia(c echo.Context) (*models.Media, error) {
+	blog := middleware.GetBlog(c)
+	if blog == nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Blog not found in context")
+	}
+	if h.media == nil {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "File not found")
+	}
+
+	m, err := h.repos.Media.FindByKey(c.Request().Context(), blog.ID, c.Param("key"))
+	if errors.Is(err, repository.ErrMediaNotFound) {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "File not found")
+	}
+	if err != nil {
+		getLogger(c).Error("Failed to load media", "blog_id", blog.ID, "error", err)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to load file")
+	}
+	return m, nil
+}
+
+// serveMedia streams a stored file with long-lived caching. Size is sent as
+// Content-Length when known. Keys are random and files never change, so the
+// checksum makes a stable ETag.
+func (h *Handlers) serveMedia(c echo.Context, m *models.Media, etag, contentType string, size int64, open func(ctx context.Context) (io.ReadCloser, error)) error {
+	header := c.Response().Header()
+	header.Set("ETag", etag)
+	header.Set("Cache-Control", "public, max-age=31536000, immutable")
+	if c.Request().Header.Get("If-None-Match") == etag {
+		return c.NoContent(http.StatusNotModified)
+	}
+
+	file, err := open(c.Request().Context())
+	if errors.Is(err, storage.ErrNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, "File not found")
+	}
+	if err != nil {
+		getLogger(c).Error("Failed to open media", "blog_id", m.BlogID, "media_id", m.ID, "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load file")
+	}
+	defer file.Close()
+
+	header.Set("Content-Type", contentType)
+	header.Set("X-Content-Type-Options", "nosniff")
+	if size > 0 {
+		header.Set("Content-Length", strconv.FormatInt(size, 10))
+	}
+	if !strings.HasPrefix(contentType, "image/") {
+		header.Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": m.Filename}))
+	}
+	c.Response().WriteHeader(http.StatusOK)
+
+	if _, err := io.Copy(c.Response(), file); err != nil {
+		getLogger(c).Warn("Failed to stream media", "media_id", m.ID, "error", err)
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/cassiascheffer/willow_camp/internal/blog/middleware"
+	"github.com/cassiascheffer/willow_camp/internal/comments"
+	"github.com/cassiascheffer/willow_camp/internal/newsletter"
+	"github.com/labstack/echo/v4"
+)
+
+// SubscribeEmail starts a double opt-in email subscription
+func (h *Handlers) SubscribeEmail(c echo.Context) error {
+	logger := getLogger(c)
+	blog := middleware.GetBlog(c)
+	if blog == nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Blog not found in context")
+	}
+	if h.newsletter == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Email subscriptions are not available")
+	}
+
+	ipHash := comments.HashIP(blog.ID, c.RealIP())
+	err := h.newsletter.Subscribe(c.Request().Context(), blog, c.FormValue("email"), ipHash)
+	switch {
+	case errors.Is(err, newsletter.ErrInvalidEmail):
+		return h.renderStatusPage(c, http.StatusUnprocessableEntity, "Check your email address",
+			"That doesn't look like a valid email address. Please go back and try again.")
+	case errors.Is(err, newsletter.ErrRateLimited):
+		return h.renderStatusPage(c, http.StatusTooManyRequests, "Slow down",
+			"You've asked to subscribe several times in the last hour. Please wait a little before trying again.")
+	case err != nil:
+		logger.Error("Failed to start email subscription", "blog_id", blog.ID, "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to subscribe")
+	}
+
+	// Same response whether or not the address was already subscribed or
+	// recently sent an email, so the form can't be used to find out who
+	// reads the blog
+	return h.renderStatusPage(c, http.StatusOK, "Check your inbox",
+		"We sent you an email with a link to confirm your subscription.")
+}
+
+// ConfirmSubscription completes double opt-in from the emailed link
+func (h *Handlers) ConfirmSubscription(c echo.Context) error {
+	logger := getLogger(c)
+	blog := middleware.GetBlog(c)
+	if blog == nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Blog not found in context")
+	}
+	if h.newsletter == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Email subscriptions are not available")
+	}
+
+	token := c.QueryParam("token")
+	if token == "" {
+		return echo.NewHTTPError(http.StatusNotFound, "Subscription not found")
+	}
+
+	if _, err := h.newsletter.Confirm(c.Request().Context(), blog, token); err != nil {
+		logger.Warn("Failed to confirm subscription", "blog_id", blog.ID, "error", err)
+		return h.renderStatusPage(c, http.StatusNotFound, "Link expired",
+			"This confirmation link is no longer valid. You can subscribe again from the subscribe page.")
+	}
+
+	return h.renderStatusPage(c, http.StatusOK, "You're subscribed",
+		"Thanks for confirming! New posts will arrive in your inbox.")
+}
+
+// UnsubscribeForm shows a confirmation button for the unsubscribe link in emails.
+// Unsubscribing itself needs a POST so link scanners can't unsubscribe readers.
+func (h *Handlers) UnsubscribeForm(c echo.Context) error {
+	blog := middleware.GetBlog(c)
+	if blog == nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Blog not found in context")
+	}
+
+	data := map[string]interface{}{
+		"Blog":  blog,
+		"Title": "Unsubscribe - " + getTitle(blog),
+		"Token": c.QueryParam("token"),
+	}
+
+	return h.renderTemplate(c, "unsubscribe.html", data)
+}
+
+// Unsubscribe handles both the form on the unsubscribe page and
+// RFC 8058 one-click POSTs sent by mail clients
+func (h *Handlers) Unsubscribe(c echo.Context) error {
+	logger := getLogger(c)
+	blog := middleware.GetBlog(c)
+	if blog == nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Blog not found in context")
+	}
+	if h.newsletter == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Email subscriptions are not available")
+	}
+
+	token := c.QueryParam("token")
+	if token == "" {
+		token = c.FormValue("token")
+	}
+
+	if _, err := h.newsletter.Unsubscribe(c.Request().Context(), blog, token); err != nil {
+		logger.Warn("Failed to unsubscribe", "blog_id", blog.ID, "error", err)
+		return h.renderStatusPage(c, http.StatusNotFound, "Subscription not found",
+			"We couldn't find a subscription for this link. You may already be unsubscribed.")
+	}
+
+	// Mail clients doing one-click unsubscribe don't need a page
+	if c.FormValue("List-Unsubscribe") == "One-Click" {
+		return c.NoContent(http.StatusOK)
+	}
+
+	return h.renderStatusPage(c, http.StatusOK, "You're unsubscribed",
+		"You won't receive any more emails from "+getTitle(blog)+".")
+}
+
+// renderStatusPage renders a short status page for subscription and comment
+// forms
+func (h *Handlers) renderStatusPage(c echo.Context, status int, heading, message string) error {
+	blog := middleware.GetBlog(c)
+
+	data := map[string]interface{}{
+		"Blog":    blog,
+		"Title":   heading + " - " + getTitle(blog),
+		"Heading": heading,
+		"Message": message,
+	}
+
+	return h.renderTemplateWithStatus(c, status, "subscribe_status.html", data)
+}
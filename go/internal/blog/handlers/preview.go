@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/cassiascheffer/willow_camp/internal/blog/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+// PreviewShow shows a post, usually a draft, to a reviewer holding one of its
+// preview links. Links are signed with their expiry and looked up too, so
+// revoking one stops it working at once.
+func (h *Handlers) PreviewShow(c echo.Context) error {
+	blog := middleware.GetBlog(c)
+	if blog == nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Blog not found in context")
+	}
+	if h.access == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Preview not found")
+	}
+
+	// Previews are for the people they were shared with, not search engines,
+	// and the token shouldn't leak to sites the post links to
+	header := c.Response().Header()
+	header.Set("X-Robots-Tag", "noindex, nofollow")
+	header.Set("Cache-Control", "private, no-store")
+	header.Set("Referrer-Policy", "no-referrer")
+
+	expired := func() error {
+		return h.renderStatusPage(c, http.StatusNotFound, "Preview link expired",
+			"This preview link has expired or was revoked. Ask the author for a new one.")
+	}
+
+	linkID, err := h.access.VerifyPreviewToken(c.Param("token"))
+	if err != nil {
+		return expired()
+	}
+	link, err := h.repos.PreviewLink.FindByID(c.Request().Context(), linkID)
+	if err != nil || link.BlogID != blog.ID || link.IsExpired() {
+		return expired()
+	}
+	post, err := h.repos.Post.FindByID(c.Request().Context(), link.PostID)
+	if err != nil || post.BlogID != blog.ID {
+		return expired()
+	}
+
+	data, err := h.postPageData(c, blog, post)
+	if err != nil {
+		return err
+	}
+	// Reviewers read the post as it will be, without the conversation under it
+	data["Title"] = "Preview: " + data["Title"].(string)
+	data["CommentsEnabled"] = false
+	data["Comments"] = nil
+	data["Mentions"] = nil
+
+	return h.renderTemplate(c, "post_show.html", data)
+}
@@ -45,10 +45,15 @@ func (h *Handlers) Sitemap(c echo.Context) error {
 			ChangeFreq: "weekly",
 			Priority:   "0.8",
 		},
+		{
+			Loc:        baseURL + "/archive",
+			ChangeFreq: "weekly",
+			Priority:   "0.8",
+		},
 	}
 
-	// Add all published posts
-	posts, err := h.repos.Post.ListPublished(c.Request().Context(), blog.ID, 1000, 0)
+	// Add all public posts; unlisted and protected ones stay out of search results
+	posts, err := h.repos.Post.ListPublic(c.Request().Context(), blog.ID, 1000, 0)
 	if err == nil {
 		for _, post := range posts {
 			if post.Slug == nil {
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"net/http"
+	"time"
+
+	"github.com/cassiascheffer/willow_camp/internal/blog/middleware"
+	"github.com/cassiascheffer/willow_camp/internal/models"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// SeriesShow lists the parts of a series in order
+func (h *Handlers) SeriesShow(c echo.Context) error {
+	blog := middleware.GetBlog(c)
+	if blog == nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Blog not found in context")
+	}
+
+	series, posts, err := h.seriesParts(c, blog)
+	if err != nil {
+		return err
+	}
+	h.loadPostTags(c, posts)
+	countWords(blog, posts)
+
+	data := map[string]interface{}{
+		"Blog":   blog,
+		"Title":  series.Title + " - " + getTitle(blog),
+		"Series": series,
+		"Posts":  posts,
+	}
+
+	return h.renderTemplate(c, "series_show.html", data)
+}
+
+// SeriesFeed serves an Atom feed of a series' public parts, so readers can
+// follow along as new parts come out
+func (h *Handlers) SeriesFeed(c echo.Context) error {
+	blog := middleware.GetBlog(c)
+	if blog == nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Blog not found in context")
+	}
+
+	user, err := h.repos.User.FindByID(c.Request().Context(), blog.UserID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load user")
+	}
+
+	series, parts, err := h.seriesParts(c, blog)
+	if err != nil {
+		return err
+	}
+
+	// Feed readers can't unlock protected parts, and the newest part goes first
+	posts := make([]*models.Post, 0, len(parts))
+	for i := len(parts) - 1; i >= 0; i-- {
+		if parts[i].IsPublic() {
+			posts = append(posts, parts[i])
+		}
+	}
+
+	seriesURL := getProtocol(c) + "://" + c.Request().Host + "/series/" + series.Slug
+	entries := h.atomEntries(c, blog, user, posts)
+
+	// Parts may be published out of order, so the feed changed when the
+	// latest one was
+	var updated time.Time
+	for _, post := range posts {
+		if post.PublishedAt != nil && post.PublishedAt.After(updated) {
+			updated = *post.PublishedAt
+		}
+	}
+	if updated.IsZero() {
+		updated = time.Now()
+	}
+
+	feed := AtomFeed{
+		Xmlns:   "http://www.w3.org/2005/Atom",
+		Media:   mediaNamespace,
+		ID:      seriesURL,
+		Title:   series.Title + " - " + getTitle(blog),
+		Updated: updated.Format(time.RFC3339),
+		Author:  &AtomAuthor{Name: getUserName(user)},
+		Links: []AtomLink{
+			{Href: seriesURL + "/feed.atom", Rel: "self", Type: "application/atom+xml"},
+			{Href: seriesURL, Rel: "alternate", Type: "text/html"},
+		},
+		Subtitle: "Parts of " + series.Title + " from " + getTitle(blog),
+		Entries:  entries,
+	}
+
+	c.Response().Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	c.Response().WriteHeader(http.StatusOK)
+
+	encoder := xml.NewEncoder(c.Response().Writer)
+	encoder.Indent("", "  ")
+	c.Response().Writer.Write([]byte(xml.Header))
+
+	return encoder.Encode(feed)
+}
+
+// seriesParts loads the series in the URL and its published parts
+func (h *Handlers) seriesParts(c echo.Context, blog *models.Blog) (*models.Series, []*models.Post, error) {
+	series, err := h.repos.Series.FindBySlug(c.Request().Context(), blog.ID, c.Param("series_slug"))
+	if err != nil {
+		return nil, nil, echo.NewHTTPError(http.StatusNotFound, "Series not found")
+	}
+
+	posts, err := h.repos.Post.ListInSeries(c.Request().Context(), series.ID, uuid.Nil)
+	if err != nil {
+		getLogger(c).Error("Failed to load posts for series", "blog_id", blog.ID, "series_id", series.ID, "error", err)
+		return nil, nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to load posts")
+	}
+	return series, posts, nil
+}
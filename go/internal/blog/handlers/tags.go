@@ -1,11 +1,12 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/cassiascheffer/willow_camp/internal/blog/middleware"
-	"github.com/cassiascheffer/willow_camp/internal/models"
+	"github.com/cassiascheffer/willow_camp/internal/repository"
 	"github.com/labstack/echo/v4"
 )
 
@@ -51,54 +52,40 @@ func (h *Handlers) TagShow(c echo.Context) error {
 	// Calculate offset
 	offset := (page - 1) * postsPerPage
 
-	// For now, we'll fetch all posts and filter by tag in memory
-	// TODO: Optimize with a proper query
-	allPosts, err := h.repos.Post.ListPublished(c.Request().Context(), blog.ID, 10000, 0)
+	logger := getLogger(c)
+
+	tag, err := h.repos.Tag.FindBySlug(c.Request().Context(), tagSlug)
+	if errors.Is(err, repository.ErrTagNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, "Tag not found")
+	}
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load posts")
+		logger.Error("Failed to find tag", "blog_id", blog.ID, "tag_slug", tagSlug, "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load tag")
 	}
+	tagName := tag.Name
 
-	// Load tags for each post and filter
-	var filteredPosts []*models.Post
-	var tagName string
-	for _, post := range allPosts {
-		tags, err := h.repos.Tag.FindTagsForPost(c.Request().Context(), post.ID)
-		if err != nil {
-			continue
-		}
-
-		// Check if this post has the requested tag
-		for _, tag := range tags {
-			if tag.Slug != nil && *tag.Slug == tagSlug {
-				post.Tags = tags
-				filteredPosts = append(filteredPosts, post)
-				if tagName == "" {
-					tagName = tag.Name
-				}
-				break
-			}
-		}
+	posts, err := h.repos.Post.ListPublishedByTagSlug(c.Request().Context(), blog.ID, tagSlug, postsPerPage, offset)
+	if err != nil {
+		logger.Error("Failed to load posts for tag", "blog_id", blog.ID, "tag_slug", tagSlug, "page", page, "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load posts")
 	}
 
-	// Apply pagination to filtered results
-	totalPosts := len(filteredPosts)
+	// Get total count for pagination
+	totalPosts, err := h.repos.Post.CountPublishedByTagSlug(c.Request().Context(), blog.ID, tagSlug)
+	if err != nil {
+		logger.Error("Failed to count posts for tag", "blog_id", blog.ID, "tag_slug", tagSlug, "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to count posts")
+	}
 	totalPages := (totalPosts + postsPerPage - 1) / postsPerPage
 
-	// Slice the filtered posts for the current page
-	start := offset
-	end := offset + postsPerPage
-	if start > totalPosts {
-		start = totalPosts
-	}
-	if end > totalPosts {
-		end = totalPosts
-	}
-	paginatedPosts := filteredPosts[start:end]
+	// Load tags for every listed post in one query
+	h.loadPostTags(c, posts)
+	countWords(blog, posts)
 
 	data := map[string]interface{}{
 		"Blog":        blog,
 		"Title":       "Posts tagged \"" + tagName + "\" - " + getTitle(blog),
-		"Posts":       paginatedPosts,
+		"Posts":       posts,
 		"TagName":     tagName,
 		"CurrentPage": page,
 		"TotalPages":  totalPages,
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/cassiascheffer/willow_camp/internal/blog/middleware"
+	"github.com/cassiascheffer/willow_camp/internal/theme"
+	"github.com/labstack/echo/v4"
+)
+
+// ThemeCSS serves the blog's theme overrides and custom CSS. The layout links
+// it as /theme.css?v=<hash>, so a matching version is cached as immutable.
+func (h *Handlers) ThemeCSS(c echo.Context) error {
+	blog := middleware.GetBlog(c)
+	if blog == nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Blog not found in context")
+	}
+
+	css := theme.Stylesheet(blog)
+	version := theme.Version(css)
+	etag := `"` + version + `"`
+
+	header := c.Response().Header()
+	header.Set("ETag", etag)
+	if c.QueryParam("v") == version {
+		header.Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		header.Set("Cache-Control", "public, max-age=300")
+	}
+	if c.Request().Header.Get("If-None-Match") == etag {
+		return c.NoContent(http.StatusNotModified)
+	}
+
+	return c.Blob(http.StatusOK, "text/css; charset=utf-8", []byte(css))
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/cassiascheffer/willow_camp/internal/blog/middleware"
+	"github.com/cassiascheffer/willow_camp/internal/webmentions"
+	"github.com/labstack/echo/v4"
+)
+
+// ReceiveWebmention accepts a Webmention for one of the blog's posts. The
+// source is fetched and checked later, so this only validates the URLs.
+func (h *Handlers) ReceiveWebmention(c echo.Context) error {
+	logger := getLogger(c)
+	blog := middleware.GetBlog(c)
+	if blog == nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Blog not found in context")
+	}
+	if h.webmentions == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Webmentions are not available")
+	}
+
+	_, err := h.webmentions.Receive(c.Request().Context(), blog, c.FormValue("source"), c.FormValue("target"))
+	if errors.Is(err, webmentions.ErrInvalidMention) {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	if err != nil {
+		logger.Error("Failed to receive webmention", "blog_id", blog.ID, "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to receive webmention")
+	}
+
+	return c.String(http.StatusAccepted, "Webmention accepted; it will be verified shortly.")
+}
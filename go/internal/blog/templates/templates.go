@@ -0,0 +1,10 @@
+// Package templates embeds the blog HTML templates so the binary does not
+// depend on its working directory.
+package templates
+
+import "embed"
+
+// FS holds the blog templates
+//
+//go:embed *.html
+var FS embed.FS
@@ -0,0 +1,292 @@
+// Package comments lets readers comment on the posts of blogs that turn
+// comments on. New comments wait for the blog owner's approval before they
+// are shown, and the owner is emailed about each one. A honeypot field, a
+// per-address rate limit and bans keep spam out.
+package comments
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/cassiascheffer/willow_camp/internal/helpers"
+	"github.com/cassiascheffer/willow_camp/internal/jobs"
+	"github.com/cassiascheffer/willow_camp/internal/mailer"
+	"github.com/cassiascheffer/willow_camp/internal/models"
+	"github.com/cassiascheffer/willow_camp/internal/repository"
+	"github.com/cassiascheffer/willow_camp/internal/views"
+	"github.com/google/uuid"
+)
+
+// JobNotify emails a blog's owner about a new comment
+const JobNotify = "comments.notify"
+
+// Each address may leave RateLimit comments on a blog per RateWindow
+const (
+	RateLimit  = 5
+	RateWindow = 10 * time.Minute
+)
+
+// Limits on what a comment may hold
+const (
+	maxNameLength = 100
+	maxURLLength  = 500
+	maxBodyLength = 5000
+)
+
+var (
+	// ErrCommentsClosed is returned for comments on posts that don't take them
+	ErrCommentsClosed = errors.New("comments are closed")
+	// ErrInvalidComment is returned for comments missing a name or text, or
+	// with an address or website that can't be used
+	ErrInvalidComment = errors.New("invalid comment")
+	// ErrRateLimited is returned when an address has commented too often
+	ErrRateLimited = errors.New("too many comments")
+)
+
+// Store holds comments and the commenters blogs ban
+type Store interface {
+	Create(ctx context.Context, comment *models.Comment) error
+	CountRecentByIP(ctx context.Context, blogID uuid.UUID, ipHash string, since time.Time) (int, error)
+	IsBanned(ctx context.Context, blogID uuid.UUID, email, ipHash string) (bool, error)
+}
+
+// Submission is a comment as a reader submitted it
+type Submission struct {
+	Name  string
+	Email string
+	URL   string
+	Body  string
+	// Honeypot is a form field hidden from people, so anything in it was
+	// filled in by a bot
+	Honeypot string
+	IP       string
+}
+
+// Service takes comments from readers and tells blog owners about them
+type Service struct {
+	store      Store
+	repos      *repository.Repositories
+	mailer     mailer.Mailer
+	queue      *jobs.Queue
+	views      *views.Registry
+	baseDomain string
+	from       string
+}
+
+// New creates a new comments Service and registers its jobs on the queue
+func New(store Store, repos *repository.Repositories, m mailer.Mailer, queue *jobs.Queue, registry *views.Registry, baseDomain string) *Service {
+	s := &Service{
+		store:      store,
+		repos:      repos,
+		mailer:     m,
+		queue:      queue,
+		views:      registry,
+		baseDomain: baseDomain,
+		from:       mailer.DefaultFrom(baseDomain),
+	}
+	if queue != nil {
+		queue.Register(JobNotify, s.runNotify)
+	}
+	return s
+}
+
+// Open reports whether a post takes comments
+func Open(blog *models.Blog, post *models.Post) bool {
+	return blog.CommentsEnabled && post.IsPublished() && !post.IsPage()
+}
+
+// HashIP identifies a reader's address on one blog without storing it
+func HashIP(blogID uuid.UUID, ip string) string {
+	sum := sha256.Sum256([]byte(blogID.String() + "|" + ip))
+	return hex.EncodeToString(sum[:])
+}
+
+type notifyPayload struct {
+	CommentID uuid.UUID `json:"comment_id"`
+}
+
+// Submit stores a reader's comment on a post for moderation and queues
+// emailing the blog owner. Comments from bots and banned commenters are
+// dropped without an error, and nil is returned for them, so they can't
+// tell they were caught.
+func (s *Service) Submit(ctx context.Context, blog *models.Blog, post *models.Post, sub Submission) (*models.Comment, error) {
+	if !Open(blog, post) {
+		return nil, ErrCommentsClosed
+	}
+	if sub.Honeypot != "" {
+		return nil, nil
+	}
+
+	comment, err := newComment(blog, post, sub)
+	if err != nil {
+		return nil, err
+	}
+
+	banned, err := s.store.IsBanned(ctx, blog.ID, comment.AuthorEmail, comment.IPHash)
+	if err != nil {
+		return nil, err
+	}
+	if banned {
+		return nil, nil
+	}
+	recent, err := s.store.CountRecentByIP(ctx, blog.ID, comment.IPHash, time.Now().Add(-RateWindow))
+	if err != nil {
+		return nil, err
+	}
+	if recent >= RateLimit {
+		return nil, ErrRateLimited
+	}
+
+	if err := s.store.Create(ctx, comment); err != nil {
+		return nil, err
+	}
+	if s.queue != nil {
+		_, err = s.queue.Enqueue(ctx, JobNotify, notifyPayload{CommentID: comment.ID}, jobs.ForBlog(blog.ID), jobs.MaxAttempts(3))
+	}
+	return comment, err
+}
+
+// newComment checks a submission and builds the comment it makes
+func newComment(blog *models.Blog, post *models.Post, sub Submission) (*models.Comment, error) {
+	name := strings.TrimSpace(sub.Name)
+	body := strings.TrimSpace(sub.Body)
+	if name == "" || utf8.RuneCountInString(name) > maxNameLength {
+		return nil, fmt.Errorf("%w: name must be 1 to %d characters", ErrInvalidComment, maxNameLength)
+	}
+	if body == "" || utf8.RuneCountInString(body) > maxBodyLength {
+		return nil, fmt.Errorf("%w: comment must be 1 to %d characters", ErrInvalidComment, maxBodyLength)
+	}
+
+	var email string
+	if sub.Email = strings.TrimSpace(sub.Email); sub.Email != "" {
+		addr, err := mail.ParseAddress(sub.Email)
+		if err != nil {
+			return nil, fmt.Errorf("%w: email address is not valid", ErrInvalidComment)
+		}
+		email = strings.ToLower(addr.Address)
+	}
+
+	website := strings.TrimSpace(sub.URL)
+	if website != "" {
+		u, err := url.Parse(website)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || len(website) > maxURLLength {
+			return nil, fmt.Errorf("%w: website must be an http(s) URL", ErrInvalidComment)
+		}
+	}
+
+	return &models.Comment{
+		BlogID:      blog.ID,
+		PostID:      post.ID,
+		AuthorName:  name,
+		AuthorEmail: email,
+		AuthorURL:   website,
+		Body:        body,
+		Status:      models.CommentPending,
+		IPHash:      HashIP(blog.ID, sub.IP),
+	}, nil
+}
+
+// runNotify emails a blog's owner about a comment waiting for moderation
+func (s *Service) runNotify(ctx context.Context, job *models.Job) error {
+	var payload notifyPayload
+	if err := job.DecodePayload(&payload); err != nil {
+		return jobs.Permanent(err)
+	}
+
+	comment, err := s.repos.Comment.FindByID(ctx, payload.CommentID)
+	if errors.Is(err, repository.ErrCommentNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if comment.IsApproved() {
+		return nil
+	}
+	blog, err := s.repos.Blog.FindByID(ctx, comment.BlogID)
+	if err != nil {
+		return err
+	}
+	owner, err := s.repos.User.FindByID(ctx, blog.UserID)
+	if err != nil {
+		return err
+	}
+	post, err := s.repos.Post.FindByID(ctx, comment.PostID)
+	if err != nil {
+		return err
+	}
+
+	msg, err := s.notifyMessage(blog, post, comment, owner.Email)
+	if err != nil {
+		return jobs.Permanent(err)
+	}
+	return s.mailer.Send(ctx, msg)
+}
+
+// notifyMessage builds the email telling a blog's owner about a comment
+func (s *Service) notifyMessage(blog *models.Blog, post *models.Post, comment *models.Comment, to string) (*mailer.Message, error) {
+	if post.Slug == nil {
+		return nil, fmt.Errorf("post has no slug")
+	}
+	postURL := helpers.BlogBaseURL(blog, s.baseDomain) + "/" + *post.Slug
+	postTitle := *post.Slug
+	if post.Title != nil && *post.Title != "" {
+		postTitle = *post.Title
+	}
+	moderateURL := s.moderateURL(blog)
+
+	var buf bytes.Buffer
+	err := s.views.Execute(&buf, "email/comment.html", map[string]interface{}{
+		"BlogTitle":   blogTitle(blog),
+		"PostTitle":   postTitle,
+		"PostURL":     postURL,
+		"AuthorName":  comment.AuthorName,
+		"AuthorURL":   comment.AuthorURL,
+		"Body":        helpers.RenderCommentHTML(comment.Body),
+		"ModerateURL": moderateURL,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to render email template: %w", err)
+	}
+
+	return &mailer.Message{
+		From:    s.from,
+		To:      to,
+		Subject: "New comment on " + postTitle,
+		TextBody: comment.AuthorName + " commented on " + postTitle + ":\n\n" + comment.Body +
+			"\n\nApprove or delete it: " + moderateURL + "\n",
+		HTMLBody: buf.String(),
+	}, nil
+}
+
+// moderateURL links to the dashboard page listing a blog's comments
+func (s *Service) moderateURL(blog *models.Blog) string {
+	protocol := "https://"
+	if strings.Contains(s.baseDomain, "localhost") {
+		protocol = "http://"
+	}
+	if blog.Subdomain == nil {
+		return protocol + s.baseDomain + "/dashboard"
+	}
+	return protocol + s.baseDomain + "/dashboard/blogs/" + *blog.Subdomain + "/comments"
+}
+
+// blogTitle returns the blog title for display in emails
+func blogTitle(blog *models.Blog) string {
+	if blog.Title != nil && *blog.Title != "" {
+		return *blog.Title
+	}
+	if blog.Subdomain != nil && *blog.Subdomain != "" {
+		return *blog.Subdomain
+	}
+	return "willow.camp"
+}
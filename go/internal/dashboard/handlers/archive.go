@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/cassiascheffer/willow_camp/internal/archive"
+	"github.com/cassiascheffer/willow_camp/internal/auth"
+	"github.com/labstack/echo/v4"
+)
+
+// ExportBlog downloads the blog's posts, pages, settings and uploads as a zip
+func (h *Handlers) ExportBlog(c echo.Context) error {
+	user := auth.GetUser(c)
+	if user == nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	// Get blog by subdomain and verify ownership
+	blog, err := h.getBlogBySubdomainParam(c, user)
+	if err != nil {
+		return err
+	}
+
+	if h.archive == nil {
+		return echo.NewHTTPError(http.StatusServiceUnavailable, "Export is not available")
+	}
+
+	filename := getTitle(blog)
+	if blog.Subdomain != nil && *blog.Subdomain != "" {
+		filename = *blog.Subdomain
+	}
+	filename += "-" + time.Now().Format("2006-01-02") + ".zip"
+
+	c.Response().Header().Set("Content-Type", "application/zip")
+	c.Response().Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Response().WriteHeader(http.StatusOK)
+
+	// The zip is streamed, so a failure part way through can only be logged
+	if err := h.archive.Export(c.Request().Context(), blog, c.Response()); err != nil {
+		getLogger(c).Error("Failed to export blog", "blog_id", blog.ID, "error", err)
+	}
+	return nil
+}
+
+// ImportBlog imports posts and pages from an export, Jekyll site or Hugo site.
+// With dry_run set it only reports what would be imported.
+func (h *Handlers) ImportBlog(c echo.Context) error {
+	user := auth.GetUser(c)
+	if user == nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	// Get blog by subdomain and verify ownership
+	blog, err := h.getBlogBySubdomainParam(c, user)
+	if err != nil {
+		return err
+	}
+
+	if h.archive == nil {
+		return echo.NewHTTPError(http.StatusServiceUnavailable, "Import is not available")
+	}
+
+	req := c.Request()
+	req.Body = http.MaxBytesReader(c.Response(), req.Body, h.archive.MaxImportBytes()+multipartOverhead)
+
+	// Get user's blogs for dropdown
+	blogs, err := h.repos.Blog.FindByUserID(req.Context(), user.ID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load blogs")
+	}
+	sortBlogsByTitle(blogs)
+	user.Blogs = blogs
+
+	data, err := h.prepareDashboardData(user, blog, "Import - "+getTitle(blog))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to prepare data")
+	}
+
+	type importTemplateData struct {
+		*dashboardTemplateData
+		Report *archive.Report
+		Error  string
+	}
+	page := &importTemplateData{dashboardTemplateData: data}
+
+	fileHeader, err := c.FormFile("archive")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			page.Error = "The archive is too large to import."
+		} else {
+			page.Error = "Choose a zip file to import."
+		}
+		return renderDashboardTemplate(c, "import_report.html", page)
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Failed to read archive")
+	}
+	defer file.Close()
+
+	bundle, err := archive.Parse(file, fileHeader.Size)
+	if err != nil {
+		if errors.Is(err, archive.ErrInvalidArchive) || errors.Is(err, archive.ErrUnknownFormat) {
+			page.Error = "The file could not be imported: " + err.Error() + "."
+		} else {
+			page.Error = "The archive could not be read: " + err.Error()
+		}
+		return renderDashboardTemplate(c, "import_report.html", page)
+	}
+
+	opts := archive.Options{
+		DryRun:         c.FormValue("dry_run") != "",
+		ImportSettings: c.FormValue("import_settings") == "on",
+	}
+	page.Report, err = h.archive.Import(req.Context(), blog, user.ID, bundle, opts)
+	if err != nil {
+		getLogger(c).Error("Failed to import blog", "blog_id", blog.ID, "dry_run", opts.DryRun, "error", err)
+		page.Error = "The import stopped part way through. Everything listed below was imported before the error."
+		if opts.DryRun {
+			page.Error = "The archive could not be checked. Please try again."
+		}
+	}
+
+	return renderDashboardTemplate(c, "import_report.html", page)
+}
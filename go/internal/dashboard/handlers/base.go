@@ -1,27 +1,45 @@
 package handlers
 
 import (
-	"encoding/json"
-	"html/template"
 	"net/http"
 	"sort"
 	"strings"
-	"time"
 
+	"github.com/cassiascheffer/willow_camp/internal/access"
+	"github.com/cassiascheffer/willow_camp/internal/activitypub"
+	"github.com/cassiascheffer/willow_camp/internal/archive"
+	"github.com/cassiascheffer/willow_camp/internal/assets"
 	"github.com/cassiascheffer/willow_camp/internal/auth"
-	"github.com/cassiascheffer/willow_camp/internal/helpers"
+	"github.com/cassiascheffer/willow_camp/internal/diagrams"
+	"github.com/cassiascheffer/willow_camp/internal/embeds"
 	"github.com/cassiascheffer/willow_camp/internal/logging"
+	"github.com/cassiascheffer/willow_camp/internal/media"
 	"github.com/cassiascheffer/willow_camp/internal/models"
+	"github.com/cassiascheffer/willow_camp/internal/newsletter"
+	"github.com/cassiascheffer/willow_camp/internal/postbody"
+	"github.com/cassiascheffer/willow_camp/internal/previews"
 	"github.com/cassiascheffer/willow_camp/internal/repository"
+	"github.com/cassiascheffer/willow_camp/internal/views"
+	"github.com/cassiascheffer/willow_camp/internal/webmentions"
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 )
 
 // Handlers holds dashboard handler dependencies
 type Handlers struct {
-	repos      *repository.Repositories
-	auth       *auth.Auth
-	baseDomain string
+	repos       *repository.Repositories
+	auth        *auth.Auth
+	baseDomain  string
+	newsletter  *newsletter.Service
+	media       *media.Service
+	archive     *archive.Service
+	views       *views.Registry
+	diagrams    *diagrams.Service
+	embeds      *embeds.Service
+	previews    *previews.Service
+	webmentions *webmentions.Service
+	activitypub *activitypub.Service
+	access      *access.Service
 }
 
 // New creates a new dashboard Handlers instance
@@ -33,6 +51,63 @@ func New(repos *repository.Repositories, authService *auth.Auth, baseDomain stri
 	}
 }
 
+// SetNewsletter sets the email newsletter service used to mail newly published posts
+func (h *Handlers) SetNewsletter(service *newsletter.Service) {
+	h.newsletter = service
+}
+
+// SetArchive sets the service used for blog export and import
+func (h *Handlers) SetArchive(service *archive.Service) {
+	h.archive = service
+}
+
+// SetViews sets the template registry, which lists the theme packs blogs can pick
+func (h *Handlers) SetViews(registry *views.Registry) {
+	h.views = registry
+}
+
+// SetMedia sets the media service used for editor uploads
+func (h *Handlers) SetMedia(service *media.Service) {
+	h.media = service
+}
+
+// SetDiagrams sets the service that renders mermaid diagrams when posts are saved
+func (h *Handlers) SetDiagrams(service *diagrams.Service) {
+	h.diagrams = service
+}
+
+// SetEmbeds sets the service that fetches link embeds when posts are saved
+func (h *Handlers) SetEmbeds(service *embeds.Service) {
+	h.embeds = service
+}
+
+// SetPreviews sets the service that fetches link previews when posts are saved
+func (h *Handlers) SetPreviews(service *previews.Service) {
+	h.previews = service
+}
+
+// postBody returns the services post markdown is rendered with
+func (h *Handlers) postBody() postbody.Services {
+	return postbody.Services{Media: h.media, Diagrams: h.diagrams, Embeds: h.embeds, Previews: h.previews}
+}
+
+// SetWebmentions sets the service that sends Webmentions when posts are published
+func (h *Handlers) SetWebmentions(service *webmentions.Service) {
+	h.webmentions = service
+}
+
+// SetActivityPub sets the service that delivers published posts to the
+// blog's fediverse followers
+func (h *Handlers) SetActivityPub(service *activitypub.Service) {
+	h.activitypub = service
+}
+
+// SetAccess sets the service signing the preview links shared from the post
+// form
+func (h *Handlers) SetAccess(service *access.Service) {
+	h.access = service
+}
+
 // getLogger retrieves the logger from the Echo context
 func getLogger(c echo.Context) *logging.Logger {
 	if logger, ok := c.Get("logger").(*logging.Logger); ok {
@@ -118,7 +193,7 @@ type dashboardTemplateData struct {
 	ActiveTab       string
 	NavTitle        string
 	NavPath         string
-	EmojiFilename   string
+	Favicon         assets.Favicon
 	IsEdit          bool
 	TagsString      string
 	AllTags         []string // All tag names for the blog (for choices.js)
@@ -152,125 +227,28 @@ func (h *Handlers) prepareDashboardData(user *models.User, blog *models.Blog, ti
 			data.NavPath = "/dashboard"
 		}
 
-		// Set emoji filename for favicon
-		emoji := "🏕️" // Default camping emoji
+		// Set favicon URLs
+		emoji := assets.DefaultEmoji
 		if blog.FaviconEmoji != nil && *blog.FaviconEmoji != "" {
 			emoji = *blog.FaviconEmoji
 		}
-		data.EmojiFilename = helpers.EmojiToOpenmojiFilename(emoji)
+		data.Favicon = assets.EmojiFavicon(emoji)
 	} else {
 		data.NavTitle = "willow.camp"
 		data.NavPath = "/dashboard"
-		data.EmojiFilename = helpers.EmojiToOpenmojiFilename("🏕️")
+		data.Favicon = assets.EmojiFavicon(assets.DefaultEmoji)
 	}
 
 	return data, nil
 }
 
-// templateFuncs returns custom template functions for dashboard templates
-func templateFuncs() template.FuncMap {
-	return template.FuncMap{
-		"heroicon": func(name string, class string) template.HTML {
-			return helpers.Icon("24/outline/"+name, class)
-		},
-		"heroiconMini": func(name string, class string) template.HTML {
-			return helpers.Icon("20/solid/"+name, class)
-		},
-		"blogURL": func(subdomain string, baseDomain string) string {
-			// Use http:// for localhost, https:// for everything else
-			protocol := "https://"
-			if strings.Contains(baseDomain, "localhost") {
-				protocol = "http://"
-			}
-			return protocol + subdomain + "." + baseDomain + "/"
-		},
-		"postURL": func(subdomain string, slug string, baseDomain string) string {
-			// Use http:// for localhost, https:// for everything else
-			protocol := "https://"
-			if strings.Contains(baseDomain, "localhost") {
-				protocol = "http://"
-			}
-			return protocol + subdomain + "." + baseDomain + "/" + slug
-		},
-		"hasText": func(s *string) bool {
-			return s != nil && *s != ""
-		},
-		"allThemes": func() []string {
-			return helpers.AllThemes()
-		},
-		"deref": func(ptr interface{}) interface{} {
-			if ptr == nil {
-				return nil
-			}
-			switch v := ptr.(type) {
-			case *string:
-				if v == nil {
-					return ""
-				}
-				return *v
-			case *bool:
-				if v == nil {
-					return false
-				}
-				return *v
-			case *int:
-				if v == nil {
-					return 0
-				}
-				return *v
-			default:
-				return ptr
-			}
-		},
-		"formatDateTime": func(t *time.Time) string {
-			if t == nil {
-				return ""
-			}
-			// Format as "2006-01-02T15:04" for datetime-local input
-			return t.Format("2006-01-02T15:04")
-		},
-		"formatDate": func(t interface{}) string {
-			switch v := t.(type) {
-			case time.Time:
-				return v.Format("Jan 02, 2006")
-			case *time.Time:
-				if v == nil {
-					return ""
-				}
-				return v.Format("Jan 02, 2006")
-			default:
-				return ""
-			}
-		},
-		"toJSON": func(v interface{}) (template.JS, error) {
-			bytes, err := json.Marshal(v)
-			if err != nil {
-				return "", err
-			}
-			return template.JS(bytes), nil
-		},
-	}
-}
-
 // renderDashboardTemplate renders a dashboard template with layout
 func renderDashboardTemplate(c echo.Context, templateName string, data interface{}) error {
 	logger := getLogger(c)
 
-	// Dashboard template rendering with custom functions
-	tmpl := template.New("layout.html").Funcs(templateFuncs())
-
-	// Parse dashboard layout and content templates
-	tmpl, err := tmpl.ParseFiles(
-		"internal/dashboard/templates/layout.html",
-		"internal/dashboard/templates/"+templateName,
-	)
-	if err != nil {
-		logger.Error("Failed to parse dashboard template", "template", templateName, "error", err)
+	if err := c.Render(http.StatusOK, "dashboard/"+templateName, data); err != nil {
+		logger.Error("Failed to render dashboard template", "template", templateName, "error", err)
 		return echo.NewHTTPError(http.StatusInternalServerError, "Template error: "+err.Error())
 	}
-
-	c.Response().Header().Set("Content-Type", "text/html; charset=utf-8")
-	c.Response().WriteHeader(http.StatusOK)
-
-	return tmpl.Execute(c.Response().Writer, data)
+	return nil
 }
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/cassiascheffer/willow_camp/internal/auth"
+	"github.com/cassiascheffer/willow_camp/internal/models"
+	"github.com/cassiascheffer/willow_camp/internal/repository"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// commentsLimit caps how many comments are listed at once
+const commentsLimit = 200
+
+// Comments shows the moderation queue: the blog's comments, with the ones
+// waiting for approval first
+func (h *Handlers) Comments(c echo.Context) error {
+	user := auth.GetUser(c)
+	if user == nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	// Get blog by subdomain and verify ownership
+	blog, err := h.getBlogBySubdomainParam(c, user)
+	if err != nil {
+		return err
+	}
+
+	// Get user's blogs for dropdown
+	blogs, err := h.repos.Blog.FindByUserID(c.Request().Context(), user.ID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load blogs")
+	}
+	sortBlogsByTitle(blogs)
+	user.Blogs = blogs
+
+	comments, err := h.repos.Comment.ListForBlog(c.Request().Context(), blog.ID, commentsLimit)
+	if err != nil {
+		getLogger(c).Error("Failed to load comments", "blog_id", blog.ID, "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load comments")
+	}
+
+	pending := 0
+	for _, comment := range comments {
+		if comment.Status == models.CommentPending {
+			pending++
+		}
+	}
+
+	data, err := h.prepareDashboardData(user, blog, "Comments - "+getTitle(blog))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to prepare data")
+	}
+	data.ActiveTab = "comments"
+
+	type commentsTemplateData struct {
+		*dashboardTemplateData
+		Comments     []*models.Comment
+		PendingCount int
+	}
+
+	return renderDashboardTemplate(c, "comments.html", &commentsTemplateData{
+		dashboardTemplateData: data,
+		Comments:              comments,
+		PendingCount:          pending,
+	})
+}
+
+// ApproveComment shows a comment under its post
+func (h *Handlers) ApproveComment(c echo.Context) error {
+	return h.updateComment(c, h.repos.Comment.Approve)
+}
+
+// DeleteComment discards a comment
+func (h *Handlers) DeleteComment(c echo.Context) error {
+	return h.updateComment(c, h.repos.Comment.DeleteForBlog)
+}
+
+// BanComment discards a comment and stops its author commenting again
+func (h *Handlers) BanComment(c echo.Context) error {
+	return h.updateComment(c, h.repos.Comment.Ban)
+}
+
+// updateComment applies an action to one of the blog's comments and returns to the list
+func (h *Handlers) updateComment(c echo.Context, action func(ctx context.Context, blogID, id uuid.UUID) error) error {
+	user := auth.GetUser(c)
+	if user == nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	// Get blog by subdomain and verify ownership
+	blog, err := h.getBlogBySubdomainParam(c, user)
+	if err != nil {
+		return err
+	}
+
+	commentID, err := parseUUID(c.Param("comment_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid comment ID")
+	}
+
+	// Scoped to the blog, so another blog's comments can't be touched
+	if err := action(c.Request().Context(), blog.ID, commentID); err != nil {
+		if errors.Is(err, repository.ErrCommentNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Comment not found")
+		}
+		getLogger(c).Error("Failed to update comment", "blog_id", blog.ID, "comment_id", commentID, "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update comment")
+	}
+
+	return c.Redirect(http.StatusFound, "/dashboard/blogs/"+c.Param("subdomain")+"/comments")
+}
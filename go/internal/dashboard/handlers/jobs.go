@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/cassiascheffer/willow_camp/internal/auth"
+	"github.com/cassiascheffer/willow_camp/internal/models"
+	"github.com/cassiascheffer/willow_camp/internal/repository"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// failedJobsLimit caps how many dead jobs are listed at once
+const failedJobsLimit = 100
+
+// FailedJobs shows background jobs for a blog that ran out of retries
+func (h *Handlers) FailedJobs(c echo.Context) error {
+	user := auth.GetUser(c)
+	if user == nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	// Get blog by subdomain and verify ownership
+	blog, err := h.getBlogBySubdomainParam(c, user)
+	if err != nil {
+		return err
+	}
+
+	// Get user's blogs for dropdown
+	blogs, err := h.repos.Blog.FindByUserID(c.Request().Context(), user.ID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load blogs")
+	}
+	sortBlogsByTitle(blogs)
+	user.Blogs = blogs
+
+	failed, err := h.repos.Job.ListDeadForBlog(c.Request().Context(), blog.ID, failedJobsLimit)
+	if err != nil {
+		getLogger(c).Error("Failed to load failed jobs", "blog_id", blog.ID, "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load jobs")
+	}
+
+	data, err := h.prepareDashboardData(user, blog, "Failed jobs - "+getTitle(blog))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to prepare data")
+	}
+	data.ActiveTab = "jobs"
+
+	type jobsTemplateData struct {
+		*dashboardTemplateData
+		Jobs []*models.Job
+	}
+
+	return renderDashboardTemplate(c, "jobs.html", &jobsTemplateData{
+		dashboardTemplateData: data,
+		Jobs:                  failed,
+	})
+}
+
+// RetryJob puts a failed job back in the queue
+func (h *Handlers) RetryJob(c echo.Context) error {
+	return h.updateFailedJob(c, h.repos.Job.RetryDead)
+}
+
+// DeleteJob discards a failed job
+func (h *Handlers) DeleteJob(c echo.Context) error {
+	return h.updateFailedJob(c, h.repos.Job.DeleteDead)
+}
+
+// updateFailedJob applies an action to one of the blog's dead jobs and returns to the list
+func (h *Handlers) updateFailedJob(c echo.Context, action func(ctx context.Context, blogID, id uuid.UUID) error) error {
+	user := auth.GetUser(c)
+	if user == nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	// Get blog by subdomain and verify ownership
+	blog, err := h.getBlogBySubdomainParam(c, user)
+	if err != nil {
+		return err
+	}
+
+	jobID, err := parseUUID(c.Param("job_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid job ID")
+	}
+
+	// Scoped to the blog, so another blog's jobs can't be touched
+	if err := action(c.Request().Context(), blog.ID, jobID); err != nil {
+		if errors.Is(err, repository.ErrJobNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Job not found")
+		}
+		getLogger(c).Error("Failed to update job", "blog_id", blog.ID, "job_id", jobID, "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update job")
+	}
+
+	return c.Redirect(http.StatusFound, "/dashboard/blogs/"+c.Param("subdomain")+"/jobs")
+}
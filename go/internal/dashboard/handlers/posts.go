@@ -2,16 +2,24 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"html/template"
 	"net/http"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/cassiascheffer/willow_camp/internal/access"
+	"github.com/cassiascheffer/willow_camp/internal/assets"
 	"github.com/cassiascheffer/willow_camp/internal/auth"
 	"github.com/cassiascheffer/willow_camp/internal/helpers"
 	"github.com/cassiascheffer/willow_camp/internal/markdown"
 	"github.com/cassiascheffer/willow_camp/internal/models"
+	"github.com/cassiascheffer/willow_camp/internal/repository"
+	"github.com/cassiascheffer/willow_camp/internal/theme"
+	"github.com/cassiascheffer/willow_camp/internal/views"
 	"github.com/google/uuid"
 	"github.com/gosimple/slug"
 	"github.com/labstack/echo/v4"
@@ -111,6 +119,21 @@ func (h *Handlers) EditPost(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load all tags")
 	}
 
+	// Load the post's series and the blog's others to pick from
+	var seriesTitle string
+	var seriesPosition int
+	series, err := h.repos.Series.FindForPost(c.Request().Context(), postID)
+	if err == nil {
+		seriesTitle = series.Title
+		seriesPosition = series.Position
+	} else if !errors.Is(err, repository.ErrSeriesNotFound) {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load series")
+	}
+	allSeries, err := h.repos.Series.ListForBlog(c.Request().Context(), blog.ID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load series")
+	}
+
 	// Prepare dashboard data with navigation
 	title := "Edit Post"
 	if blog.Title != nil && *blog.Title != "" {
@@ -126,7 +149,23 @@ func (h *Handlers) EditPost(c echo.Context) error {
 	data.AllTags = allTags
 	data.ActiveTab = "posts"
 
-	return renderDashboardTemplate(c, "post_form.html", data)
+	type postFormTemplateData struct {
+		*dashboardTemplateData
+		PreviewLinks    []previewLinkView
+		PreviewLinkDays []int
+		SeriesTitle     string
+		SeriesPosition  int
+		AllSeries       []*models.Series
+	}
+
+	return renderDashboardTemplate(c, "post_form.html", &postFormTemplateData{
+		dashboardTemplateData: data,
+		PreviewLinks:          h.previewLinkViews(c, blog, post),
+		PreviewLinkDays:       previewLinkDays,
+		SeriesTitle:           seriesTitle,
+		SeriesPosition:        seriesPosition,
+		AllSeries:             allSeries,
+	})
 }
 
 // PreviewPost shows a preview of the post using the blog layout
@@ -155,21 +194,25 @@ func (h *Handlers) PreviewPost(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusNotFound, "Post not found")
 	}
 
-	// Render markdown content
+	// Render markdown content, collecting the table of contents and word count
 	var renderedContent template.HTML
+	var toc markdown.TOC
 	if post.BodyMarkdown != nil && *post.BodyMarkdown != "" {
-		rendered, err := markdown.Render(*post.BodyMarkdown)
+		doc, err := h.postBody().Render(c.Request().Context(), blog, getLogger(c), *post.BodyMarkdown)
 		if err != nil {
 			logger.Error("Failed to render post markdown", "blog_id", blog.ID, "post_id", post.ID, "error", err)
 			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to render markdown")
 		}
-		renderedContent = rendered
+		renderedContent = doc.HTML
+		toc = doc.TOC
+		post.WordCount = doc.WordCount
+		post.ReadingTime = doc.ReadingTime
 	}
 
 	// Render post footer if present
 	var postFooter template.HTML
 	if blog.PostFooterMarkdown != nil && *blog.PostFooterMarkdown != "" {
-		rendered, err := markdown.Render(*blog.PostFooterMarkdown)
+		rendered, err := markdown.For(blog.MarkdownOptions).Render(*blog.PostFooterMarkdown)
 		if err != nil {
 			// Don't fail the whole page if footer fails
 			logger.Warn("Failed to render post footer markdown", "blog_id", blog.ID, "error", err)
@@ -232,6 +275,7 @@ func (h *Handlers) PreviewPost(c echo.Context) error {
 		"Title":                title,
 		"Post":                 post,
 		"RenderedContent":      renderedContent,
+		"TOC":                  toc,
 		"PostFooter":           postFooter,
 		"Tags":                 tags,
 		"AuthorName":           authorName,
@@ -276,7 +320,7 @@ func (h *Handlers) UpdatePost(c echo.Context) error {
 	// Check if this is a JSON request
 	isJSON := c.Request().Header.Get("Content-Type") == "application/json"
 
-	var title, bodyMarkdown, metaDescription, tagsInput string
+	var title, bodyMarkdown, metaDescription, tagsInput, visibility, password, seriesTitle, seriesPosition string
 	var published bool
 
 	if isJSON {
@@ -287,6 +331,10 @@ func (h *Handlers) UpdatePost(c echo.Context) error {
 			MetaDescription string `json:"meta_description"`
 			Published       string `json:"published"`
 			Tags            string `json:"tags"`
+			Visibility      string `json:"visibility"`
+			Password        string `json:"post_password"`
+			Series          string `json:"series"`
+			SeriesPosition  string `json:"series_position"`
 		}
 		if err := c.Bind(&req); err != nil {
 			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
@@ -296,6 +344,10 @@ func (h *Handlers) UpdatePost(c echo.Context) error {
 		metaDescription = req.MetaDescription
 		published = req.Published == "true"
 		tagsInput = req.Tags
+		visibility = req.Visibility
+		password = req.Password
+		seriesTitle = req.Series
+		seriesPosition = req.SeriesPosition
 	} else {
 		// Get form data
 		title = c.FormValue("title")
@@ -303,6 +355,20 @@ func (h *Handlers) UpdatePost(c echo.Context) error {
 		metaDescription = c.FormValue("meta_description")
 		published = c.FormValue("published") == "true"
 		tagsInput = c.FormValue("tags")
+		visibility = c.FormValue("visibility")
+		password = c.FormValue("post_password")
+		seriesTitle = c.FormValue("series")
+		seriesPosition = c.FormValue("series_position")
+	}
+
+	// Changing who can read the post, or its password
+	wasPublic := post.IsPublic()
+	wasEmailed := post.IsEmailed()
+	if err := applyVisibility(post, visibility, password); err != nil {
+		if isJSON {
+			return c.JSON(http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
+		}
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, err.Error())
 	}
 
 	// Update slug if title changed
@@ -347,6 +413,41 @@ func (h *Handlers) UpdatePost(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update tags")
 	}
 
+	// Handle series
+	if err := h.updatePostSeries(c.Request().Context(), blog.ID, post.ID, seriesTitle, seriesPosition); err != nil {
+		getLogger(c).Error("Failed to update post series", "blog_id", blog.ID, "post_id", post.ID, "error", err)
+		if isJSON {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update series"})
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update series")
+	}
+
+	// Render new mermaid diagrams in the background; mermaid.js draws them until then
+	h.enqueueDiagrams(c, blog, bodyMarkdown)
+	h.enqueueEmbeds(c, blog, bodyMarkdown)
+	h.enqueuePreviews(c, blog, bodyMarkdown)
+
+	// Email posts to subscribers once they're published for them
+	if post.IsEmailed() && !wasEmailed && h.newsletter != nil {
+		if err := h.newsletter.QueuePost(c.Request().Context(), blog, post); err != nil {
+			getLogger(c).Error("Failed to queue post for subscribers", "blog_id", blog.ID, "post_id", post.ID, "error", err)
+		}
+	}
+
+	// Notify the pages a newly public post links to
+	if post.IsPublic() && !wasPublic && h.webmentions != nil {
+		if err := h.webmentions.QueuePost(c.Request().Context(), blog, post); err != nil {
+			getLogger(c).Error("Failed to queue webmentions", "blog_id", blog.ID, "post_id", post.ID, "error", err)
+		}
+	}
+
+	// Deliver newly public posts to fediverse followers
+	if post.IsPublic() && !wasPublic && h.activitypub != nil {
+		if err := h.activitypub.QueuePost(c.Request().Context(), blog, post); err != nil {
+			getLogger(c).Error("Failed to queue post for followers", "blog_id", blog.ID, "post_id", post.ID, "error", err)
+		}
+	}
+
 	// Return JSON response for AJAX requests
 	if isJSON {
 		publishedAt := ""
@@ -368,6 +469,38 @@ func (h *Handlers) UpdatePost(c echo.Context) error {
 	return echo.NewHTTPError(http.StatusInternalServerError, "Blog subdomain not found")
 }
 
+// applyVisibility sets who can read a post. A password post keeps its
+// password when none is given; other visibilities drop it. An empty
+// visibility leaves the post as it is.
+func applyVisibility(post *models.Post, visibility, password string) error {
+	if visibility == "" {
+		return nil
+	}
+	if !slices.Contains(models.Visibilities, visibility) {
+		return fmt.Errorf("unknown visibility %q", visibility)
+	}
+	post.Visibility = visibility
+
+	if visibility != models.VisibilityPassword {
+		post.PasswordDigest = nil
+		return nil
+	}
+	// The editor autosaves the password field with every change, so it's
+	// only hashed again when it's a new password
+	if password == "" || access.CheckPassword(post, password) {
+		if post.PasswordDigest == nil {
+			return fmt.Errorf("password posts need a password")
+		}
+		return nil
+	}
+	digest, err := access.HashPassword(password)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+	post.PasswordDigest = &digest
+	return nil
+}
+
 // DeletePost handles post deletion
 func (h *Handlers) DeletePost(c echo.Context) error {
 	user := auth.GetUser(c)
@@ -395,6 +528,11 @@ func (h *Handlers) DeletePost(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to delete post")
 	}
 
+	// The post may have been the last part of a series
+	if err := h.repos.Series.DeleteEmpty(c.Request().Context(), blog.ID); err != nil {
+		getLogger(c).Warn("Failed to delete empty series", "blog_id", blog.ID, "error", err)
+	}
+
 	if blog.Subdomain != nil {
 		return c.Redirect(http.StatusFound, "/dashboard/blogs/"+*blog.Subdomain+"/posts")
 	}
@@ -408,6 +546,39 @@ func stringPtr(s string) *string {
 	return &s
 }
 
+// enqueueDiagrams schedules server-side rendering of the diagrams in a post.
+// Failing to queue only costs the reader the mermaid.js fallback.
+func (h *Handlers) enqueueDiagrams(c echo.Context, blog *models.Blog, body string) {
+	if h.diagrams == nil {
+		return
+	}
+	if err := h.diagrams.Enqueue(c.Request().Context(), blog.ID, body); err != nil {
+		getLogger(c).Error("Failed to queue diagram rendering", "blog_id", blog.ID, "error", err)
+	}
+}
+
+// enqueueEmbeds schedules fetching the embeds linked in a post. Failing to
+// queue only costs the reader a link card in place of the embed.
+func (h *Handlers) enqueueEmbeds(c echo.Context, blog *models.Blog, body string) {
+	if h.embeds == nil {
+		return
+	}
+	if err := h.embeds.Enqueue(c.Request().Context(), blog.ID, body); err != nil {
+		getLogger(c).Error("Failed to queue embed fetching", "blog_id", blog.ID, "error", err)
+	}
+}
+
+// enqueuePreviews schedules fetching the previews of the bare links in a
+// post. Failing to queue only leaves them as plain links.
+func (h *Handlers) enqueuePreviews(c echo.Context, blog *models.Blog, body string) {
+	if h.previews == nil {
+		return
+	}
+	if err := h.previews.Enqueue(c.Request().Context(), blog.ID, body); err != nil {
+		getLogger(c).Error("Failed to queue link preview fetching", "blog_id", blog.ID, "error", err)
+	}
+}
+
 // detectMermaidDiagrams checks if markdown contains mermaid diagrams
 func detectMermaidDiagrams(markdown string) bool {
 	return strings.Contains(markdown, "```mermaid")
@@ -424,6 +595,34 @@ func joinStrings(strs []string, sep string) string {
 	return result
 }
 
+// updatePostSeries puts a post in the series with the given title, creating
+// the series if it's new, or takes it out of its series when the title is
+// empty. Without a position the post keeps its place, or goes last.
+func (h *Handlers) updatePostSeries(ctx context.Context, blogID, postID uuid.UUID, seriesTitle, positionInput string) error {
+	seriesTitle = strings.TrimSpace(seriesTitle)
+	seriesSlug := slug.Make(seriesTitle)
+	if seriesSlug == "" {
+		if err := h.repos.Series.RemovePost(ctx, postID); err != nil {
+			return err
+		}
+	} else {
+		position, err := strconv.Atoi(strings.TrimSpace(positionInput))
+		if err != nil || position < 0 {
+			position = 0
+		}
+		series, err := h.repos.Series.FindOrCreateByTitle(ctx, blogID, seriesTitle, seriesSlug)
+		if err != nil {
+			return err
+		}
+		if err := h.repos.Series.SetPostSeries(ctx, postID, series.ID, position); err != nil {
+			return err
+		}
+	}
+
+	// Moving the post may have left its old series empty
+	return h.repos.Series.DeleteEmpty(ctx, blogID)
+}
+
 // updatePostTags handles tag creation and association for a post
 func (h *Handlers) updatePostTags(ctx context.Context, postID uuid.UUID, tagsInput string) error {
 	// Delete existing taggings
@@ -469,6 +668,12 @@ func (h *Handlers) generateUniqueSlug(ctx context.Context, blogID, authorID uuid
 		return "", err
 	}
 
+	// A year is the blog's date archive for that year, so new posts don't
+	// take one as their slug on its own
+	if _, isYear := helpers.ParseArchiveYear(baseSlug); isYear && maxNum == -1 {
+		maxNum = 0
+	}
+
 	// maxNum == -1: no matching slugs exist, use base slug
 	// maxNum == 0: base slug exists but no numbered versions, use baseSlug-1
 	// maxNum > 0: numbered versions exist, use baseSlug-(maxNum+1)
@@ -486,26 +691,11 @@ func renderBlogTemplate(c echo.Context, blog *models.Blog, templateName string,
 	// Enrich template data with blog layout requirements
 	data = enrichBlogTemplateData(c, blog, data)
 
-	// Create template with helper functions
-	tmpl := template.New("layout.html").Funcs(template.FuncMap{
-		"add": func(a, b int) int { return a + b },
-		"sub": func(a, b int) int { return a - b },
-	})
-
-	// Parse blog layout and content templates
-	tmpl, err := tmpl.ParseFiles(
-		"internal/blog/templates/layout.html",
-		"internal/blog/templates/"+templateName,
-	)
-	if err != nil {
-		logger.Error("Failed to parse blog template", "template", templateName, "error", err)
+	if err := c.Render(http.StatusOK, views.BlogTemplate(blog.ThemePack, templateName), data); err != nil {
+		logger.Error("Failed to render blog template", "template", templateName, "error", err)
 		return echo.NewHTTPError(http.StatusInternalServerError, "Template error: "+err.Error())
 	}
-
-	c.Response().Header().Set("Content-Type", "text/html; charset=utf-8")
-	c.Response().WriteHeader(http.StatusOK)
-
-	return tmpl.Execute(c.Response().Writer, data)
+	return nil
 }
 
 // enrichBlogTemplateData adds layout requirements to template data
@@ -519,12 +709,20 @@ func enrichBlogTemplateData(c echo.Context, blog *models.Blog, data map[string]i
 	// Blog title for display
 	data["BlogTitle"] = getBlogTitle(blog)
 
-	// OpenMoji favicon filename
-	emojiFilename := "1F3D5" // Default camping emoji
-	if blog.FaviconEmoji != nil && *blog.FaviconEmoji != "" {
-		emojiFilename = helpers.EmojiToOpenmojiFilename(*blog.FaviconEmoji)
+	// OpenMoji favicon URLs
+	emoji := ""
+	if blog.FaviconEmoji != nil {
+		emoji = *blog.FaviconEmoji
+	}
+	data["Favicon"] = assets.EmojiFavicon(emoji)
+
+	// Theme customizations are inlined; /theme.css is only served on the blog's host
+	if _, exists := data["ThemeCSS"]; !exists {
+		data["ThemeCSS"] = template.CSS(theme.Stylesheet(blog))
 	}
-	data["EmojiFilename"] = emojiFilename
+
+	// Code highlighting stylesheets are served on every host
+	data["HighlightStylesheet"] = theme.HighlightURL(blog)
 
 	// Note: Pages for navigation are not included in preview for simplicity
 	// The preview handler can add them if needed
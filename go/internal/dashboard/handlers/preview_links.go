@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/cassiascheffer/willow_camp/internal/auth"
+	"github.com/cassiascheffer/willow_camp/internal/helpers"
+	"github.com/cassiascheffer/willow_camp/internal/models"
+	"github.com/cassiascheffer/willow_camp/internal/repository"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// previewLinkDays lists how many days a new preview link can work for
+var previewLinkDays = []int{1, 7, 30}
+
+// previewLinkView is a preview link listed on the post form
+type previewLinkView struct {
+	*models.PreviewLink
+	URL string
+}
+
+// previewLinkViews loads a post's preview links for the post form
+func (h *Handlers) previewLinkViews(c echo.Context, blog *models.Blog, post *models.Post) []previewLinkView {
+	if h.access == nil {
+		return nil
+	}
+	links, err := h.repos.PreviewLink.ListActiveForPost(c.Request().Context(), post.ID)
+	if err != nil {
+		// Don't fail the editor if links fail to load
+		getLogger(c).Warn("Failed to load preview links", "blog_id", blog.ID, "post_id", post.ID, "error", err)
+		return nil
+	}
+	list := make([]previewLinkView, len(links))
+	for i, link := range links {
+		list[i] = previewLinkView{PreviewLink: link, URL: h.previewLinkURL(blog, link)}
+	}
+	return list
+}
+
+// previewLinkURL is where a preview link opens, on the blog's own host
+func (h *Handlers) previewLinkURL(blog *models.Blog, link *models.PreviewLink) string {
+	return helpers.BlogBaseURL(blog, h.baseDomain) + "/preview/" + link.Token
+}
+
+// CreatePreviewLink makes a signed link for reviewers without an account to
+// read a post before it's published
+func (h *Handlers) CreatePreviewLink(c echo.Context) error {
+	blog, post, err := h.previewLinkPost(c)
+	if err != nil {
+		return err
+	}
+	if h.access == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Preview links are not available")
+	}
+
+	days, err := strconv.Atoi(c.FormValue("days"))
+	if err != nil || !slices.Contains(previewLinkDays, days) {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, "Invalid preview link length")
+	}
+
+	link := &models.PreviewLink{
+		ID:        uuid.New(),
+		BlogID:    blog.ID,
+		PostID:    post.ID,
+		ExpiresAt: time.Now().Add(time.Duration(days) * 24 * time.Hour).Truncate(time.Second),
+	}
+	link.Token, err = h.access.PreviewToken(link)
+	if err != nil {
+		getLogger(c).Error("Failed to sign preview link", "blog_id", blog.ID, "post_id", post.ID, "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create preview link")
+	}
+	if err := h.repos.PreviewLink.Create(c.Request().Context(), link); err != nil {
+		getLogger(c).Error("Failed to create preview link", "blog_id", blog.ID, "post_id", post.ID, "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create preview link")
+	}
+
+	return c.Redirect(http.StatusFound, editPostPath(c)+"#preview-links")
+}
+
+// DeletePreviewLink revokes a preview link, so it stops working at once
+func (h *Handlers) DeletePreviewLink(c echo.Context) error {
+	blog, post, err := h.previewLinkPost(c)
+	if err != nil {
+		return err
+	}
+
+	linkID, err := parseUUID(c.Param("link_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid preview link ID")
+	}
+
+	// Scoped to the post, so another post's links can't be revoked
+	if err := h.repos.PreviewLink.DeleteForPost(c.Request().Context(), post.ID, linkID); err != nil {
+		if errors.Is(err, repository.ErrPreviewLinkNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Preview link not found")
+		}
+		getLogger(c).Error("Failed to revoke preview link", "blog_id", blog.ID, "link_id", linkID, "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to revoke preview link")
+	}
+
+	return c.Redirect(http.StatusFound, editPostPath(c)+"#preview-links")
+}
+
+// previewLinkPost loads the blog and post from the URL for the preview link
+// actions, checking the user owns them
+func (h *Handlers) previewLinkPost(c echo.Context) (*models.Blog, *models.Post, error) {
+	user := auth.GetUser(c)
+	if user == nil {
+		return nil, nil, echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	// Get blog by subdomain and verify ownership
+	blog, err := h.getBlogBySubdomainParam(c, user)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	postID, err := parseUUID(c.Param("post_id"))
+	if err != nil {
+		return nil, nil, echo.NewHTTPError(http.StatusBadRequest, "Invalid post ID")
+	}
+	post, err := h.repos.Post.FindByID(c.Request().Context(), postID)
+	if err != nil || post.BlogID != blog.ID {
+		return nil, nil, echo.NewHTTPError(http.StatusNotFound, "Post not found")
+	}
+	return blog, post, nil
+}
+
+// editPostPath is the post form for the post in the URL
+func editPostPath(c echo.Context) string {
+	return "/dashboard/blogs/" + c.Param("subdomain") + "/posts/" + c.Param("post_id") + "/edit"
+}
@@ -77,7 +77,7 @@ func (h *Handlers) Security(c echo.Context) error {
 		"NavTitle":       dashData.NavTitle,
 		"NavPath":        dashData.NavPath,
 		"BaseDomain":     dashData.BaseDomain,
-		"EmojiFilename":  dashData.EmojiFilename,
+		"Favicon":        dashData.Favicon,
 		"LastViewedBlog": lastViewedBlog,
 		"SuccessMessage": successMsg,
 		"ErrorMessage":   errorMsg,
@@ -5,6 +5,8 @@ import (
 	"net/http"
 
 	"github.com/cassiascheffer/willow_camp/internal/auth"
+	"github.com/cassiascheffer/willow_camp/internal/models"
+	"github.com/cassiascheffer/willow_camp/internal/theme"
 	"github.com/labstack/echo/v4"
 )
 
@@ -45,14 +47,19 @@ func (h *Handlers) BlogSettings(c echo.Context) error {
 
 	// Convert to map and add AboutPage
 	data := map[string]interface{}{
-		"Title":         dashData.Title,
-		"User":          dashData.User,
-		"Blog":          dashData.Blog,
-		"NavTitle":      dashData.NavTitle,
-		"NavPath":       dashData.NavPath,
-		"BaseDomain":    dashData.BaseDomain,
-		"EmojiFilename": dashData.EmojiFilename,
-		"AboutPage":     aboutPage,
+		"Title":           dashData.Title,
+		"User":            dashData.User,
+		"Blog":            dashData.Blog,
+		"NavTitle":        dashData.NavTitle,
+		"NavPath":         dashData.NavPath,
+		"BaseDomain":      dashData.BaseDomain,
+		"Favicon":         dashData.Favicon,
+		"AboutPage":       aboutPage,
+		"ThemeFonts":      theme.Fonts,
+		"ThemeWidths":     theme.Widths,
+		"HighlightStyles": theme.HighlightStyles,
+		"MaxCustomCSS":    theme.MaxCustomCSSBytes,
+		"ThemePacks":      h.themePacks(),
 	}
 
 	return renderDashboardTemplate(c, "blog_settings.html", data)
@@ -81,6 +88,7 @@ func (h *Handlers) UpdateBlogSettings(c echo.Context) error {
 	blog.MetaDescription = stringPtr(c.FormValue("meta_description"))
 	blog.FaviconEmoji = stringPtr(c.FormValue("favicon_emoji"))
 	blog.NoIndex = c.FormValue("no_index") == "on"
+	blog.CommentsEnabled = c.FormValue("comments_enabled") == "on"
 
 	if err := h.repos.Blog.Update(c.Request().Context(), blog); err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update blog settings")
@@ -93,6 +101,36 @@ func (h *Handlers) UpdateBlogSettings(c echo.Context) error {
 	return echo.NewHTTPError(http.StatusInternalServerError, "Blog subdomain not found")
 }
 
+// UpdateMarkdownSettings saves which markdown extensions the blog's posts use
+func (h *Handlers) UpdateMarkdownSettings(c echo.Context) error {
+	user := auth.GetUser(c)
+	if user == nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	// Get blog by subdomain and verify ownership
+	blog, err := h.getBlogBySubdomainParam(c, user)
+	if err != nil {
+		return err
+	}
+
+	blog.MarkdownOptions = models.MarkdownOptions{
+		Footnotes:       c.FormValue("footnotes") == "on",
+		DefinitionLists: c.FormValue("definition_lists") == "on",
+		Typographer:     c.FormValue("typographer") == "on",
+		Admonitions:     c.FormValue("admonitions") == "on",
+		Math:            c.FormValue("math") == "on",
+		RawHTML:         c.FormValue("raw_html") == "on",
+	}
+
+	if err := h.repos.Blog.Update(c.Request().Context(), blog); err != nil {
+		getLogger(c).Error("Failed to update markdown options", "blog_id", blog.ID, "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update markdown settings")
+	}
+
+	return c.Redirect(http.StatusFound, "/dashboard/blogs/"+*blog.Subdomain+"/settings#markdown")
+}
+
 // UpdateFaviconEmoji handles AJAX favicon emoji updates
 func (h *Handlers) UpdateFaviconEmoji(c echo.Context) error {
 	user := auth.GetUser(c)
@@ -155,6 +193,9 @@ func (h *Handlers) UpdateAboutPage(c echo.Context) error {
 	if err := h.repos.Post.Update(c.Request().Context(), aboutPage); err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update about page")
 	}
+	h.enqueueDiagrams(c, blog, c.FormValue("body_markdown"))
+	h.enqueueEmbeds(c, blog, c.FormValue("body_markdown"))
+	h.enqueuePreviews(c, blog, c.FormValue("body_markdown"))
 
 	if blog.Subdomain != nil {
 		return c.Redirect(http.StatusFound, "/dashboard/blogs/"+*blog.Subdomain+"/settings")
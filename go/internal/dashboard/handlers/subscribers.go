@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"net/http"
+	"time"
+
+	"github.com/cassiascheffer/willow_camp/internal/auth"
+	"github.com/cassiascheffer/willow_camp/internal/models"
+	"github.com/labstack/echo/v4"
+)
+
+// Subscribers shows the email subscribers for a blog
+func (h *Handlers) Subscribers(c echo.Context) error {
+	user := auth.GetUser(c)
+	if user == nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	// Get blog by subdomain and verify ownership
+	blog, err := h.getBlogBySubdomainParam(c, user)
+	if err != nil {
+		return err
+	}
+
+	// Get user's blogs for dropdown
+	blogs, err := h.repos.Blog.FindByUserID(c.Request().Context(), user.ID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load blogs")
+	}
+	sortBlogsByTitle(blogs)
+	user.Blogs = blogs
+
+	subscribers, err := h.repos.Subscriber.ListForBlog(c.Request().Context(), blog.ID)
+	if err != nil {
+		getLogger(c).Error("Failed to load subscribers", "blog_id", blog.ID, "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load subscribers")
+	}
+
+	activeCount := 0
+	for _, s := range subscribers {
+		if s.IsActive() {
+			activeCount++
+		}
+	}
+
+	data, err := h.prepareDashboardData(user, blog, "Subscribers - "+getTitle(blog))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to prepare data")
+	}
+	data.ActiveTab = "subscribers"
+
+	type subscribersTemplateData struct {
+		*dashboardTemplateData
+		Subscribers []*models.EmailSubscriber
+		ActiveCount int
+	}
+
+	return renderDashboardTemplate(c, "subscribers.html", &subscribersTemplateData{
+		dashboardTemplateData: data,
+		Subscribers:           subscribers,
+		ActiveCount:           activeCount,
+	})
+}
+
+// ExportSubscribers downloads the blog's email subscribers as CSV
+func (h *Handlers) ExportSubscribers(c echo.Context) error {
+	user := auth.GetUser(c)
+	if user == nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	// Get blog by subdomain and verify ownership
+	blog, err := h.getBlogBySubdomainParam(c, user)
+	if err != nil {
+		return err
+	}
+
+	subscribers, err := h.repos.Subscriber.ListForBlog(c.Request().Context(), blog.ID)
+	if err != nil {
+		getLogger(c).Error("Failed to export subscribers", "blog_id", blog.ID, "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load subscribers")
+	}
+
+	filename := "subscribers-" + time.Now().Format("2006-01-02") + ".csv"
+	c.Response().Header().Set("Content-Type", "text/csv; charset=utf-8")
+	c.Response().Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Response().WriteHeader(http.StatusOK)
+
+	w := csv.NewWriter(c.Response().Writer)
+	w.Write([]string{"email", "status", "subscribed_at", "confirmed_at", "unsubscribed_at"})
+	for _, s := range subscribers {
+		w.Write([]string{
+			s.Email,
+			s.Status(),
+			s.CreatedAt.UTC().Format(time.RFC3339),
+			formatOptionalTime(s.ConfirmedAt),
+			formatOptionalTime(s.UnsubscribedAt),
+		})
+	}
+	w.Flush()
+
+	return w.Error()
+}
+
+// formatOptionalTime formats a nullable timestamp for CSV export
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/cassiascheffer/willow_camp/internal/auth"
+	"github.com/cassiascheffer/willow_camp/internal/models"
+	"github.com/cassiascheffer/willow_camp/internal/theme"
+	"github.com/cassiascheffer/willow_camp/internal/views"
+	"github.com/labstack/echo/v4"
+)
+
+// themePreviewPosts is how many recent posts the theme preview lists
+const themePreviewPosts = 5
+
+// UpdateThemeSettings saves the blog's theme overrides and custom CSS
+func (h *Handlers) UpdateThemeSettings(c echo.Context) error {
+	user := auth.GetUser(c)
+	if user == nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	// Get blog by subdomain and verify ownership
+	blog, err := h.getBlogBySubdomainParam(c, user)
+	if err != nil {
+		return err
+	}
+
+	if err := h.applyThemeForm(c, blog); err != nil {
+		return err
+	}
+
+	if err := h.repos.Blog.Update(c.Request().Context(), blog); err != nil {
+		getLogger(c).Error("Failed to update theme", "blog_id", blog.ID, "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update theme")
+	}
+
+	return c.Redirect(http.StatusFound, "/dashboard/blogs/"+*blog.Subdomain+"/settings#appearance")
+}
+
+// PreviewTheme renders the blog's home page with the submitted, unsaved
+// theme settings. The settings form posts here into the preview iframe.
+func (h *Handlers) PreviewTheme(c echo.Context) error {
+	logger := getLogger(c)
+	user := auth.GetUser(c)
+	if user == nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	// Get blog by subdomain and verify ownership
+	blog, err := h.getBlogBySubdomainParam(c, user)
+	if err != nil {
+		return err
+	}
+
+	// Work on a copy so nothing unsaved can leak into later use of blog
+	preview := *blog
+	if err := h.applyThemeForm(c, &preview); err != nil {
+		return err
+	}
+
+	posts, err := h.repos.Post.ListPublished(c.Request().Context(), blog.ID, themePreviewPosts, 0)
+	if err != nil {
+		logger.Warn("Failed to load posts for theme preview", "blog_id", blog.ID, "error", err)
+		posts = []*models.Post{}
+	}
+
+	data := map[string]interface{}{
+		"Blog":          &preview,
+		"Title":         getBlogTitle(&preview) + " | Posts",
+		"FeaturedPosts": []*models.Post{},
+		"Posts":         posts,
+		"CurrentPage":   1,
+		"TotalPages":    1,
+		"ThemeCSS":      template.CSS(theme.Stylesheet(&preview)),
+	}
+
+	return renderBlogTemplate(c, &preview, "index.html", data)
+}
+
+// themePacks lists the theme packs offered in the settings form
+func (h *Handlers) themePacks() []views.Pack {
+	if h.views == nil {
+		return nil
+	}
+	return h.views.Packs()
+}
+
+// applyThemeForm copies the theme fields and highlight style from the form
+// onto blog, sanitizing the custom CSS
+func (h *Handlers) applyThemeForm(c echo.Context, blog *models.Blog) error {
+	pack := c.FormValue("theme_pack")
+	if pack != "" && (h.views == nil || !h.views.HasPack(pack)) {
+		return echo.NewHTTPError(http.StatusBadRequest, "Unknown layout: "+pack)
+	}
+
+	overrides := models.ThemeOverrides{
+		PrimaryColor: strings.TrimSpace(c.FormValue("primary_color")),
+		AccentColor:  strings.TrimSpace(c.FormValue("accent_color")),
+		BodyFont:     c.FormValue("body_font"),
+		HeadingFont:  c.FormValue("heading_font"),
+		ContentWidth: c.FormValue("content_width"),
+	}
+	if err := theme.Validate(overrides); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	highlightStyle := c.FormValue("highlight_style")
+	if err := theme.ValidateHighlight(highlightStyle); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	customCSS := c.FormValue("custom_css")
+	if len(customCSS) > theme.MaxCustomCSSBytes {
+		return echo.NewHTTPError(http.StatusBadRequest, theme.ErrCustomCSSTooLarge.Error())
+	}
+
+	blog.ThemePack = pack
+	blog.ThemeOverrides = overrides
+	blog.HighlightStyle = highlightStyle
+	blog.CustomCSS = nil
+	if sanitized := theme.SanitizeCSS(customCSS); sanitized != "" {
+		blog.CustomCSS = &sanitized
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/cassiascheffer/willow_camp/internal/auth"
+	"github.com/cassiascheffer/willow_camp/internal/media"
+	"github.com/labstack/echo/v4"
+)
+
+// multipartOverhead allows for form boundaries and headers on top of the file itself
+const multipartOverhead = 1 << 20
+
+// UploadMedia stores a file from the post editor and returns markdown to embed it
+func (h *Handlers) UploadMedia(c echo.Context) error {
+	user := auth.GetUser(c)
+	if user == nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	}
+
+	// Get blog by subdomain and verify ownership
+	blog, err := h.getBlogBySubdomainParam(c, user)
+	if err != nil {
+		return err
+	}
+
+	if h.media == nil {
+		return c.JSON(http.StatusServiceUnavailable, map[string]string{"error": "Uploads are not available"})
+	}
+
+	req := c.Request()
+	req.Body = http.MaxBytesReader(c.Response(), req.Body, h.media.MaxBytes()+multipartOverhead)
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return c.JSON(http.StatusRequestEntityTooLarge, map[string]string{"error": "File is too large"})
+		}
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "No file uploaded"})
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Failed to read file"})
+	}
+	defer file.Close()
+
+	m, err := h.media.Upload(req.Context(), blog, &user.ID, fileHeader.Filename, file)
+	switch {
+	case errors.Is(err, media.ErrTooLarge):
+		return c.JSON(http.StatusRequestEntityTooLarge, map[string]string{"error": "File is too large"})
+	case errors.Is(err, media.ErrUnsupportedType):
+		return c.JSON(http.StatusUnsupportedMediaType, map[string]string{"error": "Only JPEG, PNG, GIF, WebP and PDF files can be uploaded"})
+	case errors.Is(err, media.ErrEmpty):
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "File is empty"})
+	case err != nil:
+		getLogger(c).Error("Failed to upload media", "blog_id", blog.ID, "user_id", user.ID, "error", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to upload file"})
+	}
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"url":          h.media.URL(blog, m),
+		"markdown":     h.media.Markdown(blog, m),
+		"filename":     m.Filename,
+		"content_type": m.ContentType,
+		"byte_size":    m.ByteSize,
+	})
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/cassiascheffer/willow_camp/internal/auth"
+	"github.com/cassiascheffer/willow_camp/internal/models"
+	"github.com/cassiascheffer/willow_camp/internal/repository"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// webmentionsLimit caps how many mentions are listed at once
+const webmentionsLimit = 200
+
+// Webmentions shows the blog's verified Webmentions, with the ones waiting
+// for approval first
+func (h *Handlers) Webmentions(c echo.Context) error {
+	user := auth.GetUser(c)
+	if user == nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	// Get blog by subdomain and verify ownership
+	blog, err := h.getBlogBySubdomainParam(c, user)
+	if err != nil {
+		return err
+	}
+
+	// Get user's blogs for dropdown
+	blogs, err := h.repos.Blog.FindByUserID(c.Request().Context(), user.ID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load blogs")
+	}
+	sortBlogsByTitle(blogs)
+	user.Blogs = blogs
+
+	mentions, err := h.repos.Webmention.ListForBlog(c.Request().Context(), blog.ID, webmentionsLimit)
+	if err != nil {
+		getLogger(c).Error("Failed to load webmentions", "blog_id", blog.ID, "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load webmentions")
+	}
+
+	pending := 0
+	for _, mention := range mentions {
+		if mention.Status == models.WebmentionPending {
+			pending++
+		}
+	}
+
+	data, err := h.prepareDashboardData(user, blog, "Webmentions - "+getTitle(blog))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to prepare data")
+	}
+	data.ActiveTab = "webmentions"
+
+	type webmentionsTemplateData struct {
+		*dashboardTemplateData
+		Mentions     []*models.Webmention
+		PendingCount int
+	}
+
+	return renderDashboardTemplate(c, "webmentions.html", &webmentionsTemplateData{
+		dashboardTemplateData: data,
+		Mentions:              mentions,
+		PendingCount:          pending,
+	})
+}
+
+// ApproveWebmention shows a mention under its post
+func (h *Handlers) ApproveWebmention(c echo.Context) error {
+	return h.updateWebmention(c, func(ctx context.Context, blogID, id uuid.UUID) error {
+		return h.repos.Webmention.SetStatus(ctx, blogID, id, models.WebmentionApproved)
+	})
+}
+
+// RejectWebmention hides a mention, and keeps it hidden if it is sent again
+func (h *Handlers) RejectWebmention(c echo.Context) error {
+	return h.updateWebmention(c, func(ctx context.Context, blogID, id uuid.UUID) error {
+		return h.repos.Webmention.SetStatus(ctx, blogID, id, models.WebmentionRejected)
+	})
+}
+
+// DeleteWebmention discards a mention
+func (h *Handlers) DeleteWebmention(c echo.Context) error {
+	return h.updateWebmention(c, h.repos.Webmention.DeleteForBlog)
+}
+
+// updateWebmention applies an action to one of the blog's mentions and returns to the list
+func (h *Handlers) updateWebmention(c echo.Context, action func(ctx context.Context, blogID, id uuid.UUID) error) error {
+	user := auth.GetUser(c)
+	if user == nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	// Get blog by subdomain and verify ownership
+	blog, err := h.getBlogBySubdomainParam(c, user)
+	if err != nil {
+		return err
+	}
+
+	mentionID, err := parseUUID(c.Param("webmention_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid webmention ID")
+	}
+
+	// Scoped to the blog, so another blog's mentions can't be touched
+	if err := action(c.Request().Context(), blog.ID, mentionID); err != nil {
+		if errors.Is(err, repository.ErrWebmentionNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Webmention not found")
+		}
+		getLogger(c).Error("Failed to update webmention", "blog_id", blog.ID, "webmention_id", mentionID, "error", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update webmention")
+	}
+
+	return c.Redirect(http.StatusFound, "/dashboard/blogs/"+c.Param("subdomain")+"/webmentions")
+}
@@ -0,0 +1,206 @@
+// Package diagrams renders Mermaid diagrams in posts to SVG when posts are
+// saved, and caches them by the hash of their source so every page, feed and
+// email shows the same drawing without running mermaid.js.
+package diagrams
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/cassiascheffer/willow_camp/internal/jobs"
+	"github.com/cassiascheffer/willow_camp/internal/markdown"
+	"github.com/cassiascheffer/willow_camp/internal/models"
+	"github.com/google/uuid"
+)
+
+// JobRender is the job kind that renders a post's new diagrams
+const JobRender = "diagrams.render"
+
+// MaxSVGBytes is the largest rendered diagram that is stored
+const MaxSVGBytes = 1 << 20
+
+// renderTimeout bounds rendering a single diagram
+const renderTimeout = 30 * time.Second
+
+var ErrInvalidSVG = errors.New("renderer returned an unsafe or invalid SVG")
+
+// Renderer turns Mermaid source into an SVG document
+type Renderer interface {
+	Render(ctx context.Context, source string) ([]byte, error)
+}
+
+// Store keeps rendered SVGs by content hash. The diagram repository
+// implements it.
+type Store interface {
+	FindByHashes(ctx context.Context, hashes []string) (map[string]string, error)
+	Create(ctx context.Context, hash, svg string) error
+}
+
+// Service renders diagrams in the background and looks them up for rendering
+type Service struct {
+	renderer Renderer
+	store    Store
+	queue    *jobs.Queue
+}
+
+// New creates a new diagrams Service and registers its job on the queue. With
+// a nil renderer nothing new is rendered, but cached diagrams still display.
+func New(renderer Renderer, store Store, queue *jobs.Queue) *Service {
+	s := &Service{renderer: renderer, store: store, queue: queue}
+	if queue != nil {
+		queue.Register(JobRender, s.runRender)
+	}
+	return s
+}
+
+type renderPayload struct {
+	Sources []string `json:"sources"`
+}
+
+// Enqueue schedules rendering for the diagrams in source that aren't cached
+// yet. Until the job runs they are drawn by mermaid.js in the browser.
+func (s *Service) Enqueue(ctx context.Context, blogID uuid.UUID, source string) error {
+	if s.renderer == nil || s.queue == nil {
+		return nil
+	}
+	missing, err := s.missing(ctx, markdown.DiagramSources(source))
+	if err != nil || len(missing) == 0 {
+		return err
+	}
+	_, err = s.queue.Enqueue(ctx, JobRender, renderPayload{Sources: missing}, jobs.ForBlog(blogID), jobs.MaxAttempts(3))
+	return err
+}
+
+// Prepare renders and stores the diagrams in source that aren't cached yet
+func (s *Service) Prepare(ctx context.Context, source string) error {
+	if s.renderer == nil {
+		return nil
+	}
+	missing, err := s.missing(ctx, markdown.DiagramSources(source))
+	if err != nil {
+		return err
+	}
+	return s.render(ctx, missing)
+}
+
+func (s *Service) runRender(ctx context.Context, job *models.Job) error {
+	var payload renderPayload
+	if err := job.DecodePayload(&payload); err != nil {
+		return jobs.Permanent(err)
+	}
+	if s.renderer == nil {
+		return nil
+	}
+	// Another job may have rendered some of them since this one was queued
+	missing, err := s.missing(ctx, payload.Sources)
+	if err != nil {
+		return err
+	}
+	return s.render(ctx, missing)
+}
+
+// render renders each diagram, carrying on past failures so one broken
+// diagram doesn't hold back the rest of the post
+func (s *Service) render(ctx context.Context, sources []string) error {
+	var errs []error
+	for _, source := range sources {
+		renderCtx, cancel := context.WithTimeout(ctx, renderTimeout)
+		svg, err := s.renderer.Render(renderCtx, source)
+		cancel()
+		if err == nil {
+			err = ValidateSVG(svg)
+		}
+		if err == nil {
+			err = s.store.Create(ctx, markdown.DiagramHash(source), string(svg))
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("diagram %.12s: %w", markdown.DiagramHash(source), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// missing returns the distinct sources without a cached SVG
+func (s *Service) missing(ctx context.Context, sources []string) ([]string, error) {
+	if len(sources) == 0 {
+		return nil, nil
+	}
+	found, err := s.store.FindByHashes(ctx, hashes(sources))
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	var missing []string
+	for _, source := range sources {
+		hash := markdown.DiagramHash(source)
+		if _, ok := found[hash]; !ok && !seen[hash] {
+			seen[hash] = true
+			missing = append(missing, source)
+		}
+	}
+	return missing, nil
+}
+
+// Resolver loads the cached SVGs for the diagrams in the given markdown with
+// a single query and returns a resolver for markdown.RenderDocument
+func (s *Service) Resolver(ctx context.Context, sources ...string) (markdown.DiagramResolver, error) {
+	var diagrams []string
+	for _, source := range sources {
+		diagrams = append(diagrams, markdown.DiagramSources(source)...)
+	}
+	if len(diagrams) == 0 {
+		return nil, nil
+	}
+
+	found, err := s.store.FindByHashes(ctx, hashes(diagrams))
+	if err != nil {
+		return nil, err
+	}
+	return func(source string) (string, bool) {
+		svg, ok := found[markdown.DiagramHash(source)]
+		return svg, ok
+	}, nil
+}
+
+// SVG returns a cached diagram by hash, for serving it as an image
+func (s *Service) SVG(ctx context.Context, hash string) (string, bool, error) {
+	found, err := s.store.FindByHashes(ctx, []string{hash})
+	if err != nil {
+		return "", false, err
+	}
+	svg, ok := found[hash]
+	return svg, ok, nil
+}
+
+func hashes(sources []string) []string {
+	result := make([]string, len(sources))
+	for i, source := range sources {
+		result[i] = markdown.DiagramHash(source)
+	}
+	return result
+}
+
+var (
+	svgStart = regexp.MustCompile(`^(<\?xml[^>]*\?>\s*)?<svg[\s>]`)
+	// svgUnsafe finds markup that can run script when the SVG is inlined in a
+	// page or opened from the blog's origin
+	svgUnsafe = regexp.MustCompile(`(?i)<\s*(script|iframe|object|embed)|\son[a-z]+\s*=|javascript:|<!ENTITY`)
+)
+
+// ValidateSVG rejects renderer output that isn't a single SVG document or
+// that could carry script. Mermaid escapes labels, so this only fails if
+// the renderer is misconfigured or compromised.
+func ValidateSVG(svg []byte) error {
+	if len(svg) == 0 || len(svg) > MaxSVGBytes {
+		return ErrInvalidSVG
+	}
+	trimmed := strings.TrimSpace(string(svg))
+	if !svgStart.MatchString(trimmed) || !strings.HasSuffix(trimmed, "</svg>") || svgUnsafe.MatchString(trimmed) {
+		return ErrInvalidSVG
+	}
+	return nil
+}
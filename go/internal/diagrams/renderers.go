@@ -0,0 +1,87 @@
+package diagrams
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// CLIRenderer renders with the Mermaid CLI (mmdc), which drives a headless
+// browser. Set MERMAID_CLI to its path to enable server-side rendering.
+type CLIRenderer struct {
+	// Command is the mmdc executable
+	Command string
+	// Args are extra arguments, e.g. a --puppeteerConfigFile
+	Args []string
+}
+
+// NewCLIRenderer returns a renderer for MERMAID_CLI, or nil when it's unset.
+// MERMAID_CLI_ARGS adds arguments, e.g. "--puppeteerConfigFile /etc/mmdc.json".
+func NewCLIRenderer() Renderer {
+	command := os.Getenv("MERMAID_CLI")
+	if command == "" {
+		return nil
+	}
+	return &CLIRenderer{Command: command, Args: strings.Fields(os.Getenv("MERMAID_CLI_ARGS"))}
+}
+
+// Render writes source to a temporary file and runs mmdc on it
+func (r *CLIRenderer) Render(ctx context.Context, source string) ([]byte, error) {
+	dir, err := os.MkdirTemp("", "mermaid-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, "diagram.mmd")
+	output := filepath.Join(dir, "diagram.svg")
+	if err := os.WriteFile(input, []byte(source), 0o600); err != nil {
+		return nil, err
+	}
+
+	args := append([]string{"--input", input, "--output", output, "--backgroundColor", "transparent", "--quiet"}, r.Args...)
+	cmd := exec.CommandContext(ctx, r.Command, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("mmdc failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return os.ReadFile(output)
+}
+
+// StubRenderer draws every diagram as a box labeled with its first line. It
+// stands in for the CLI in tests and counts how often it's called.
+type StubRenderer struct {
+	// Err, when set, is returned instead of rendering
+	Err error
+
+	mu    sync.Mutex
+	calls int
+}
+
+func (r *StubRenderer) Render(ctx context.Context, source string) ([]byte, error) {
+	r.mu.Lock()
+	r.calls++
+	r.mu.Unlock()
+	if r.Err != nil {
+		return nil, r.Err
+	}
+
+	label, _, _ := strings.Cut(strings.TrimSpace(source), "\n")
+	return []byte(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 200 40" role="img">` +
+		`<rect width="200" height="40" fill="none" stroke="currentColor"/>` +
+		`<text x="100" y="25" text-anchor="middle">` + html.EscapeString(label) + `</text></svg>`), nil
+}
+
+// Calls returns how many diagrams the stub has rendered
+func (r *StubRenderer) Calls() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.calls
+}
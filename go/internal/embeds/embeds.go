@@ -0,0 +1,250 @@
+// Package embeds turns links to videos, toots, pens and the like into rich
+// embeds. A registry of oEmbed providers says which links can be embedded;
+// their responses are fetched when posts are saved and cached by URL, so
+// pages render without calling out to the providers.
+package embeds
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/cassiascheffer/willow_camp/internal/jobs"
+	"github.com/cassiascheffer/willow_camp/internal/markdown"
+	"github.com/cassiascheffer/willow_camp/internal/models"
+	"github.com/google/uuid"
+)
+
+// JobFetch is the job kind that fetches a post's new or stale embeds
+const JobFetch = "embeds.fetch"
+
+// CacheTTL is how long a cached embed is used before it is fetched again
+const CacheTTL = 7 * 24 * time.Hour
+
+// fetchTimeout bounds a single oEmbed request
+const fetchTimeout = 10 * time.Second
+
+// maxResponseBytes is the largest oEmbed response read
+const maxResponseBytes = 1 << 20
+
+// maxWidth is asked of providers, matching the widest content column
+const maxWidth = 1024
+
+var ErrUnknownProvider = errors.New("no oEmbed provider for this URL")
+
+// Store caches embeds by URL. The embed repository implements it.
+type Store interface {
+	FindByURLs(ctx context.Context, urls []string) (map[string]*models.Embed, error)
+	Upsert(ctx context.Context, embed *models.Embed) error
+}
+
+// Service fetches embeds in the background and looks them up for rendering
+type Service struct {
+	registry *Registry
+	store    Store
+	queue    *jobs.Queue
+	client   *http.Client
+}
+
+// New creates a new embeds Service and registers its job on the queue
+func New(registry *Registry, store Store, queue *jobs.Queue) *Service {
+	s := &Service{
+		registry: registry,
+		store:    store,
+		queue:    queue,
+		client:   &http.Client{Timeout: fetchTimeout},
+	}
+	if queue != nil {
+		queue.Register(JobFetch, s.runFetch)
+	}
+	return s
+}
+
+// SetClient replaces the HTTP client used to call providers
+func (s *Service) SetClient(client *http.Client) {
+	s.client = client
+}
+
+type fetchPayload struct {
+	URLs []string `json:"urls"`
+}
+
+// Enqueue schedules fetching the embeds in source that aren't cached or have
+// gone stale. Until the job runs they are shown as link cards.
+func (s *Service) Enqueue(ctx context.Context, blogID uuid.UUID, source string) error {
+	if s.queue == nil {
+		return nil
+	}
+	due, err := s.due(ctx, markdown.EmbedURLs(source))
+	if err != nil || len(due) == 0 {
+		return err
+	}
+	_, err = s.queue.Enqueue(ctx, JobFetch, fetchPayload{URLs: due}, jobs.ForBlog(blogID), jobs.MaxAttempts(3))
+	return err
+}
+
+// Prepare fetches and caches the embeds in source that aren't cached or have
+// gone stale
+func (s *Service) Prepare(ctx context.Context, source string) error {
+	due, err := s.due(ctx, markdown.EmbedURLs(source))
+	if err != nil {
+		return err
+	}
+	return s.fetchAll(ctx, due)
+}
+
+func (s *Service) runFetch(ctx context.Context, job *models.Job) error {
+	var payload fetchPayload
+	if err := job.DecodePayload(&payload); err != nil {
+		return jobs.Permanent(err)
+	}
+	// Another job may have fetched some of them since this one was queued
+	due, err := s.due(ctx, payload.URLs)
+	if err != nil {
+		return err
+	}
+	return s.fetchAll(ctx, due)
+}
+
+// fetchAll fetches each embed, carrying on past failures so one provider
+// being down doesn't hold back the rest of the post
+func (s *Service) fetchAll(ctx context.Context, urls []string) error {
+	var errs []error
+	for _, link := range urls {
+		embed, err := s.Fetch(ctx, link)
+		if err == nil {
+			err = s.store.Upsert(ctx, embed)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("embed %s: %w", link, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// due returns the distinct URLs with an oEmbed provider that aren't cached
+// or were fetched more than CacheTTL ago
+func (s *Service) due(ctx context.Context, urls []string) ([]string, error) {
+	var candidates []string
+	seen := map[string]bool{}
+	for _, link := range urls {
+		if p := s.registry.Match(link); p != nil && p.Endpoint != "" && !seen[link] {
+			seen[link] = true
+			candidates = append(candidates, link)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	cached, err := s.store.FindByURLs(ctx, candidates)
+	if err != nil {
+		return nil, err
+	}
+	var due []string
+	for _, link := range candidates {
+		if e, ok := cached[link]; !ok || time.Since(e.FetchedAt) > CacheTTL {
+			due = append(due, link)
+		}
+	}
+	return due, nil
+}
+
+// oEmbedResponse holds the fields of an oEmbed response that are used
+type oEmbedResponse struct {
+	Type  string `json:"type"`
+	Title string `json:"title"`
+	HTML  string `json:"html"`
+	// URL is the image of a photo response
+	URL string `json:"url"`
+}
+
+// Fetch asks link's provider for its embed. The provider's HTML is
+// sanitized; photos become an image, and links become a card with the title.
+func (s *Service) Fetch(ctx context.Context, link string) (*models.Embed, error) {
+	provider := s.registry.Match(link)
+	if provider == nil || provider.Endpoint == "" {
+		return nil, ErrUnknownProvider
+	}
+
+	endpoint, err := url.Parse(provider.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	query := endpoint.Query()
+	query.Set("url", link)
+	query.Set("format", "json")
+	query.Set("maxwidth", fmt.Sprint(maxWidth))
+	endpoint.RawQuery = query.Encode()
+
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s oEmbed returned %s", provider.Name, resp.Status)
+	}
+
+	var data oEmbedResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseBytes)).Decode(&data); err != nil {
+		return nil, fmt.Errorf("failed to decode %s oEmbed response: %w", provider.Name, err)
+	}
+
+	embed := &models.Embed{URL: link, Provider: provider.Name, Title: data.Title, FetchedAt: time.Now()}
+	switch data.Type {
+	case "video", "rich":
+		embed.HTML = provider.Sanitize(data.HTML)
+	case "photo":
+		embed.HTML = provider.Sanitize(`<img src="` + html.EscapeString(data.URL) + `" alt="` + html.EscapeString(data.Title) + `">`)
+	}
+	return embed, nil
+}
+
+// Resolver loads the cached embeds for the links in the given markdown with
+// a single query and returns a resolver for markdown.RenderDocument. Links
+// to a provider that haven't been fetched are shown as link cards.
+func (s *Service) Resolver(ctx context.Context, sources ...string) (markdown.EmbedResolver, error) {
+	var urls []string
+	for _, source := range sources {
+		for _, link := range markdown.EmbedURLs(source) {
+			if s.registry.Match(link) != nil {
+				urls = append(urls, link)
+			}
+		}
+	}
+	if len(urls) == 0 {
+		return nil, nil
+	}
+
+	cached, err := s.store.FindByURLs(ctx, urls)
+	if err != nil {
+		return nil, err
+	}
+	return func(link string) (markdown.Embed, bool) {
+		provider := s.registry.Match(link)
+		if provider == nil {
+			return markdown.Embed{}, false
+		}
+		embed := markdown.Embed{URL: link, Provider: provider.Name}
+		if e, ok := cached[link]; ok {
+			embed.HTML = e.HTML
+			embed.Title = e.Title
+		}
+		return embed, true
+	}, nil
+}
@@ -0,0 +1,136 @@
+package embeds
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/cassiascheffer/willow_camp/internal/helpers"
+	"github.com/microcosm-cc/bluemonday"
+)
+
+// Provider is a site whose links can be embedded
+type Provider struct {
+	Name string
+	// Schemes are the provider's URL patterns, with * matching anything, as
+	// in the oEmbed spec, e.g. https://vimeo.com/*
+	Schemes []string
+	// Endpoint is the provider's oEmbed API. Providers without one are shown
+	// as link cards.
+	Endpoint string
+	// Frames are the URL prefixes the provider's embed HTML may load in an
+	// iframe. Its HTML is sanitized like raw HTML in posts otherwise.
+	Frames []string
+
+	patterns []*regexp.Regexp
+	policy   *bluemonday.Policy
+}
+
+// Matches reports whether link is one of the provider's URLs
+func (p *Provider) Matches(link string) bool {
+	for _, pattern := range p.patterns {
+		if pattern.MatchString(link) {
+			return true
+		}
+	}
+	return false
+}
+
+// Sanitize cleans the HTML the provider returned
+func (p *Provider) Sanitize(html string) string {
+	return strings.TrimSpace(p.policy.Sanitize(html))
+}
+
+// Registry holds the providers links are matched against, in order
+type Registry struct {
+	providers []*Provider
+}
+
+// NewRegistry compiles the providers' schemes and HTML policies
+func NewRegistry(providers ...Provider) *Registry {
+	r := &Registry{}
+	for _, p := range providers {
+		provider := p
+		for _, scheme := range provider.Schemes {
+			pattern := strings.ReplaceAll(regexp.QuoteMeta(scheme), `\*`, `[^\s]*`)
+			provider.patterns = append(provider.patterns, regexp.MustCompile(`^`+pattern+`$`))
+		}
+		provider.policy = helpers.PostPolicy(provider.Frames...)
+		r.providers = append(r.providers, &provider)
+	}
+	return r
+}
+
+// Match returns the first provider for link, or nil
+func (r *Registry) Match(link string) *Provider {
+	for _, p := range r.providers {
+		if p.Matches(link) {
+			return p
+		}
+	}
+	return nil
+}
+
+// Handles reports whether a provider embeds link, or shows it as a card
+func (r *Registry) Handles(link string) bool {
+	return r.Match(link) != nil
+}
+
+// mastodon is a Mastodon server, whose toots embed from the server itself
+func mastodon(host string) Provider {
+	return Provider{
+		Name:     "Mastodon",
+		Schemes:  []string{"https://" + host + "/@*/*"},
+		Endpoint: "https://" + host + "/api/oembed",
+		Frames:   []string{"https://" + host + "/@"},
+	}
+}
+
+// DefaultProviders are the sites posts can embed. Toots embed from the
+// larger Mastodon servers; those from other servers are shown as link cards
+// when written as a shortcode.
+var DefaultProviders = []Provider{
+	{
+		Name:     "YouTube",
+		Schemes:  []string{"https://www.youtube.com/watch?*", "https://youtube.com/watch?*", "https://youtu.be/*", "https://www.youtube.com/shorts/*"},
+		Endpoint: "https://www.youtube.com/oembed",
+		Frames:   []string{"https://www.youtube.com/embed/", "https://www.youtube-nocookie.com/embed/"},
+	},
+	{
+		Name:     "Vimeo",
+		Schemes:  []string{"https://vimeo.com/*"},
+		Endpoint: "https://vimeo.com/api/oembed.json",
+		Frames:   []string{"https://player.vimeo.com/video/"},
+	},
+	mastodon("mastodon.social"),
+	mastodon("hachyderm.io"),
+	mastodon("fosstodon.org"),
+	{
+		Name:     "CodePen",
+		Schemes:  []string{"https://codepen.io/*/pen/*"},
+		Endpoint: "https://codepen.io/api/oembed",
+		Frames:   []string{"https://codepen.io/"},
+	},
+	{
+		Name:     "CodeSandbox",
+		Schemes:  []string{"https://codesandbox.io/s/*", "https://codesandbox.io/p/*"},
+		Endpoint: "https://codesandbox.io/oembed",
+		Frames:   []string{"https://codesandbox.io/embed/"},
+	},
+	{
+		Name:     "Spotify",
+		Schemes:  []string{"https://open.spotify.com/*"},
+		Endpoint: "https://open.spotify.com/oembed",
+		Frames:   []string{"https://open.spotify.com/embed/"},
+	},
+	{
+		Name:     "SoundCloud",
+		Schemes:  []string{"https://soundcloud.com/*"},
+		Endpoint: "https://soundcloud.com/oembed",
+		Frames:   []string{"https://w.soundcloud.com/player/"},
+	},
+	// Gists have no oEmbed API and only embed with a script
+	{
+		Name:    "GitHub Gist",
+		Schemes: []string{"https://gist.github.com/*"},
+	},
+}
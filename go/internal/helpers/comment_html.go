@@ -0,0 +1,49 @@
+package helpers
+
+import (
+	"bytes"
+	"html/template"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/renderer/html"
+)
+
+// commentMarkdown renders the markdown readers write in comments. Raw HTML
+// is escaped rather than passed through.
+var commentMarkdown = goldmark.New(
+	goldmark.WithExtensions(extension.Linkify, extension.Strikethrough),
+	goldmark.WithRendererOptions(html.WithHardWraps()),
+)
+
+// commentPolicy is built once; bluemonday policies are safe for concurrent use
+var commentPolicy = CommentPolicy()
+
+// CommentPolicy allows the text formatting, quotes, code and links a reader
+// needs in a comment. Headings, images and tables are dropped, and links
+// are marked nofollow ugc.
+func CommentPolicy() *bluemonday.Policy {
+	policy := bluemonday.NewPolicy()
+	policy.AllowElements("p", "br", "em", "strong", "del", "code", "pre", "blockquote", "ul", "ol", "li")
+	policy.AllowStandardURLs()
+	policy.AllowAttrs("href").OnElements("a")
+	policy.RequireNoFollowOnLinks(true)
+	policy.AddTargetBlankToFullyQualifiedLinks(false)
+	policy.AllowRelativeURLs(false)
+	policy.AddSpaceWhenStrippingTag(true)
+	return policy
+}
+
+// RenderCommentHTML renders a comment's markdown as safe HTML
+func RenderCommentHTML(body string) template.HTML {
+	var buf bytes.Buffer
+	if err := commentMarkdown.Convert([]byte(body), &buf); err != nil {
+		return template.HTML("<p>" + template.HTMLEscapeString(body) + "</p>")
+	}
+	// The policy only knows nofollow; the rel it adds is the only one left
+	// after sanitizing, so ugc can be added to it here
+	sanitized := commentPolicy.Sanitize(buf.String())
+	return template.HTML(strings.ReplaceAll(sanitized, `rel="nofollow"`, `rel="nofollow ugc"`))
+}
@@ -3,15 +3,16 @@ package helpers
 import (
 	"fmt"
 	"html/template"
-	"os"
+	"io/fs"
 	"strings"
+
+	"github.com/cassiascheffer/willow_camp/internal/icons"
 )
 
-// Icon loads an SVG icon from the icons directory and applies the given class
+// Icon loads an SVG icon from the embedded icons and applies the given class
 func Icon(path string, class string) template.HTML {
 	// Read the SVG file
-	svgPath := fmt.Sprintf("internal/icons/%s.svg", path)
-	content, err := os.ReadFile(svgPath)
+	content, err := fs.ReadFile(icons.FS, path+".svg")
 	if err != nil {
 		// Return empty string if icon not found
 		return template.HTML("")
@@ -0,0 +1,77 @@
+package helpers
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+)
+
+// EmbedSources are the URL prefixes iframes in posts may load. Each is a
+// provider's embed player, so an iframe can't frame an arbitrary page.
+var EmbedSources = []string{
+	"https://www.youtube.com/embed/",
+	"https://www.youtube-nocookie.com/embed/",
+	"https://player.vimeo.com/video/",
+	"https://open.spotify.com/embed/",
+	"https://w.soundcloud.com/player/",
+	"https://bandcamp.com/EmbeddedPlayer/",
+	"https://embed.music.apple.com/",
+	"https://codepen.io/",
+	"https://www.google.com/maps/embed",
+	"https://www.openstreetmap.org/export/embed.html",
+}
+
+// postPolicy is built once; bluemonday policies are safe for concurrent use
+var postPolicy = PostPolicy(EmbedSources...)
+
+// SanitizePostHTML cleans raw HTML written in a post on a blog that allows
+// it. It is applied to each piece of raw HTML as the post renders, so the
+// markup markdown generates, like diagrams and math, is left alone.
+func SanitizePostHTML(htmlContent string) string {
+	return postPolicy.Sanitize(htmlContent)
+}
+
+// PostPolicy allows the formatting, media and layout elements an author
+// might reach for when markdown isn't enough, and nothing that runs script.
+// Iframes may only load URLs starting with one of frames.
+func PostPolicy(frames ...string) *bluemonday.Policy {
+	// UGCPolicy covers text formatting, lists, tables, images and links
+	// with safe URL schemes. Links stay followable since authors own the blog.
+	policy := bluemonday.UGCPolicy()
+	policy.RequireNoFollowOnLinks(false)
+
+	policy.AllowElements("kbd", "samp", "var", "figure", "figcaption", "u", "s")
+	policy.AllowAttrs("class").Matching(regexp.MustCompile(`^[\w\- ]+$`)).Globally()
+
+	// Audio and video from https sources
+	https := regexp.MustCompile(`^https://[^\s"'<>]+$`)
+	policy.AllowElements("video", "audio")
+	policy.AllowAttrs("src", "poster").Matching(https).OnElements("video", "audio", "source")
+	policy.AllowAttrs("type").Matching(regexp.MustCompile(`^(audio|video)/[\w.+-]+$`)).OnElements("source")
+	policy.AllowAttrs("controls", "loop", "muted", "playsinline").Matching(regexp.MustCompile(`^$|^[a-z]+$`)).OnElements("video", "audio")
+	policy.AllowAttrs("preload").Matching(regexp.MustCompile(`^(none|metadata|auto)$`)).OnElements("video", "audio")
+	policy.AllowAttrs("width", "height").Matching(regexp.MustCompile(`^\d{1,4}%?$`)).OnElements("video", "iframe")
+
+	// Iframes only from known embed players
+	if len(frames) == 0 {
+		return policy
+	}
+	policy.AllowAttrs("src").Matching(urlPrefixes(frames)).OnElements("iframe")
+	policy.AllowAttrs("title").OnElements("iframe")
+	policy.AllowAttrs("allowfullscreen").Matching(regexp.MustCompile(`^$|^(true|allowfullscreen)$`)).OnElements("iframe")
+	policy.AllowAttrs("allow").Matching(regexp.MustCompile(`^((accelerometer|autoplay|clipboard-write|encrypted-media|fullscreen|gyroscope|picture-in-picture|web-share)[; ]*)+$`)).OnElements("iframe")
+	policy.AllowAttrs("loading").Matching(regexp.MustCompile(`^(lazy|eager)$`)).OnElements("iframe")
+	policy.AllowAttrs("referrerpolicy").Matching(regexp.MustCompile(`^[a-z-]+$`)).OnElements("iframe")
+	policy.AllowAttrs("frameborder").Matching(regexp.MustCompile(`^\d$`)).OnElements("iframe")
+	return policy
+}
+
+// urlPrefixes matches a URL that starts with one of prefixes
+func urlPrefixes(prefixes []string) *regexp.Regexp {
+	quoted := make([]string, len(prefixes))
+	for i, prefix := range prefixes {
+		quoted[i] = regexp.QuoteMeta(prefix)
+	}
+	return regexp.MustCompile(`^(?:` + strings.Join(quoted, "|") + `)[^\s"'<>]*$`)
+}
@@ -2,6 +2,7 @@ package helpers
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
 
 	"github.com/microcosm-cc/bluemonday"
@@ -16,12 +17,23 @@ func SanitizeHTMLForFeed(htmlContent, protocol, host string, port int, path stri
 
 	// Allow safe tags
 	policy.AllowElements("a", "b", "strong", "i", "em", "p", "h1", "h2", "h3", "h4", "h5", "h6",
-		"ul", "ol", "li", "blockquote", "pre", "code", "img")
+		"ul", "ol", "li", "blockquote", "pre", "code", "img", "sup", "dl", "dt", "dd")
 
 	// Allow specific attributes
 	policy.AllowAttrs("href").OnElements("a")
 	policy.AllowAttrs("src", "alt", "title").OnElements("img")
 
+	// Allow MathML from posts with math enabled, which needs no script to display
+	policy.AllowElements(mathMLElements...)
+	policy.AllowAttrs("xmlns").Matching(regexp.MustCompile(`^http://www\.w3\.org/1998/Math/MathML$`)).OnElements("math")
+	policy.AllowAttrs("display").Matching(regexp.MustCompile(`^(block|inline)$`)).OnElements("math")
+	policy.AllowAttrs("encoding").Matching(regexp.MustCompile(`^application/x-tex$`)).OnElements("annotation")
+	policy.AllowAttrs(mathMLAttrs...).Matching(mathMLValue).OnElements(mathMLElements...)
+
+	// Inline SVG and iframes don't survive sanitizing, so diagrams link to
+	// their image and embeds to what they embed instead
+	htmlContent = linkFigures(htmlContent, feedBaseURL(protocol, host, port))
+
 	// Sanitize the content
 	sanitized := policy.Sanitize(htmlContent)
 
@@ -31,6 +43,18 @@ func SanitizeHTMLForFeed(htmlContent, protocol, host string, port int, path stri
 	return sanitized
 }
 
+// mathMLElements are the presentation MathML elements markdown math renders
+var mathMLElements = []string{"math", "semantics", "annotation", "mrow", "mi", "mn", "mo", "mtext",
+	"mspace", "msub", "msup", "msubsup", "munder", "mover", "munderover", "mfrac", "msqrt", "mroot",
+	"mtable", "mtr", "mtd"}
+
+// mathMLAttrs are the layout attributes markdown math uses, all with short
+// keyword or length values
+var mathMLAttrs = []string{"mathvariant", "stretchy", "fence", "largeop", "movablelimits", "accent",
+	"linethickness", "columnalign", "width", "minsize", "maxsize"}
+
+var mathMLValue = regexp.MustCompile(`^[a-z0-9.\- ]+$`)
+
 // convertAnchorLinks converts relative anchor links (#section) to absolute URLs
 func convertAnchorLinks(htmlContent, protocol, host string, port int, path string) string {
 	// Parse the HTML
@@ -40,10 +64,7 @@ func convertAnchorLinks(htmlContent, protocol, host string, port int, path strin
 	}
 
 	// Build base URL
-	baseURL := fmt.Sprintf("%s%s", protocol, host)
-	if port != 80 && port != 443 && port != 0 {
-		baseURL = fmt.Sprintf("%s:%d", baseURL, port)
-	}
+	baseURL := feedBaseURL(protocol, host, port)
 
 	// Recursively find and update anchor links
 	var f func(*html.Node)
@@ -77,3 +98,77 @@ func convertAnchorLinks(htmlContent, protocol, host string, port int, path strin
 
 	return result
 }
+
+// feedBaseURL joins the parts of the blog's URL. protocol may be given as
+// "https" or "https://".
+func feedBaseURL(protocol, host string, port int) string {
+	if !strings.HasSuffix(protocol, "://") {
+		protocol += "://"
+	}
+	baseURL := fmt.Sprintf("%s%s", protocol, host)
+	if port != 80 && port != 443 && port != 0 {
+		baseURL = fmt.Sprintf("%s:%d", baseURL, port)
+	}
+	return baseURL
+}
+
+// diagramHash matches the data-diagram attribute of a rendered diagram
+var diagramHash = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// linkFigures replaces the inline SVG of rendered diagrams with an image of
+// the same diagram served from the blog, and embeds with a link to the page
+// they embed
+func linkFigures(htmlContent, baseURL string) string {
+	if !strings.Contains(htmlContent, "data-diagram") && !strings.Contains(htmlContent, "data-embed-url") {
+		return htmlContent
+	}
+	doc, err := html.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		return htmlContent
+	}
+
+	replace := func(n *html.Node, child *html.Node) {
+		for n.FirstChild != nil {
+			n.RemoveChild(n.FirstChild)
+		}
+		n.AppendChild(child)
+	}
+
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "figure" {
+			for _, attr := range n.Attr {
+				switch {
+				case attr.Key == "data-diagram" && diagramHash.MatchString(attr.Val):
+					replace(n, &html.Node{
+						Type: html.ElementNode,
+						Data: "img",
+						Attr: []html.Attribute{
+							{Key: "src", Val: baseURL + "/diagrams/" + attr.Val + ".svg"},
+							{Key: "alt", Val: "Diagram"},
+						},
+					})
+					return
+				case attr.Key == "data-embed-url":
+					link := &html.Node{Type: html.ElementNode, Data: "a", Attr: []html.Attribute{{Key: "href", Val: attr.Val}}}
+					link.AppendChild(&html.Node{Type: html.TextNode, Data: attr.Val})
+					para := &html.Node{Type: html.ElementNode, Data: "p"}
+					para.AppendChild(link)
+					replace(n, para)
+					return
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(doc)
+
+	var buf strings.Builder
+	if err := html.Render(&buf, doc); err != nil {
+		return htmlContent
+	}
+	result := strings.TrimPrefix(buf.String(), "<html><head></head><body>")
+	return strings.TrimSuffix(result, "</body></html>")
+}
@@ -0,0 +1,53 @@
+package helpers
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cassiascheffer/willow_camp/internal/models"
+)
+
+// BlogBaseURL returns the public base URL of a blog (without trailing slash).
+// Used when building links outside a request, e.g. in emails.
+// Custom domains win over subdomains; localhost uses http://, everything else https://
+func BlogBaseURL(blog *models.Blog, baseDomain string) string {
+	protocol := "https://"
+	if strings.Contains(baseDomain, "localhost") {
+		protocol = "http://"
+	}
+
+	if blog.CustomDomain != nil && *blog.CustomDomain != "" {
+		return protocol + *blog.CustomDomain
+	}
+	if blog.Subdomain != nil && *blog.Subdomain != "" {
+		return protocol + *blog.Subdomain + "." + baseDomain
+	}
+	return protocol + baseDomain
+}
+
+// ParseArchiveYear returns the year in a four-digit path segment like "2024".
+// Blogs serve /:year as a date archive, so post slugs can't be years.
+func ParseArchiveYear(segment string) (int, bool) {
+	if len(segment) != 4 || strings.Trim(segment, "0123456789") != "" {
+		return 0, false
+	}
+	year, err := strconv.Atoi(segment)
+	if err != nil || year < 1 {
+		return 0, false
+	}
+	return year, true
+}
+
+// ParseArchiveMonth returns the month in a two-digit path segment like "05",
+// as in /:year/:month
+func ParseArchiveMonth(segment string) (time.Month, bool) {
+	if len(segment) != 2 || strings.Trim(segment, "0123456789") != "" {
+		return 0, false
+	}
+	month, err := strconv.Atoi(segment)
+	if err != nil || month < 1 || month > 12 {
+		return 0, false
+	}
+	return time.Month(month), true
+}
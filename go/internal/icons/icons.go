@@ -0,0 +1,9 @@
+// Package icons embeds the Heroicons SVGs used by templates.
+package icons
+
+import "embed"
+
+// FS holds the icons, laid out as <size>/<style>/<name>.svg
+//
+//go:embed 20 24
+var FS embed.FS
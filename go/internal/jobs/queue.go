@@ -0,0 +1,350 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"runtime/debug"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cassiascheffer/willow_camp/internal/logging"
+	"github.com/cassiascheffer/willow_camp/internal/models"
+	"github.com/cassiascheffer/willow_camp/internal/repository"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultQueue       = "default"
+	defaultMaxAttempts = 10
+	minBackoff         = 15 * time.Second
+	maxBackoff         = time.Hour
+	// finishedRetention is how long completed jobs are kept before pruning
+	finishedRetention = 7 * 24 * time.Hour
+	// outcomeTimeout bounds recording how a job ended, which gets its own
+	// context so a handler that timed out can still be retried
+	outcomeTimeout = 10 * time.Second
+)
+
+// Handler runs a single job. Returning an error schedules a retry with backoff
+// until the job runs out of attempts; wrap the error with Permanent to skip retries.
+type Handler func(ctx context.Context, job *models.Job) error
+
+// Config controls the worker pool
+type Config struct {
+	Queue        string
+	Workers      int
+	PollInterval time.Duration
+	// JobTimeout bounds a single handler run
+	JobTimeout time.Duration
+	// StaleAfter is how long a job may stay locked before it's assumed abandoned
+	StaleAfter time.Duration
+}
+
+// ConfigFromEnv reads JOB_WORKERS and JOB_POLL_INTERVAL, falling back to defaults
+func ConfigFromEnv() Config {
+	config := Config{
+		Queue:        defaultQueue,
+		Workers:      4,
+		PollInterval: 2 * time.Second,
+		JobTimeout:   10 * time.Minute,
+		StaleAfter:   30 * time.Minute,
+	}
+
+	if n, err := strconv.Atoi(os.Getenv("JOB_WORKERS")); err == nil && n >= 0 {
+		config.Workers = n
+	}
+	if d, err := time.ParseDuration(os.Getenv("JOB_POLL_INTERVAL")); err == nil && d > 0 {
+		config.PollInterval = d
+	}
+
+	return config
+}
+
+// Store holds the jobs. *repository.JobRepository is the Postgres one.
+type Store interface {
+	Enqueue(ctx context.Context, job *models.Job) error
+	ClaimNext(ctx context.Context, queue, workerID string) (*models.Job, error)
+	MarkDone(ctx context.Context, id uuid.UUID) error
+	Reschedule(ctx context.Context, id uuid.UUID, runAt time.Time, lastError string) error
+	MarkDead(ctx context.Context, id uuid.UUID, lastError string) error
+	ReleaseStale(ctx context.Context, staleAfter time.Duration) (int64, error)
+	DeleteFinishedBefore(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+// Queue is a durable job queue backed by the jobs table
+type Queue struct {
+	repo     Store
+	config   Config
+	logger   *logging.Logger
+	workerID string
+
+	mu       sync.RWMutex
+	handlers map[string]Handler
+
+	wake   chan struct{}
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New creates a new Queue. Call Register for every job kind, then Start.
+func New(repo Store, config Config, logger *logging.Logger) *Queue {
+	if config.Queue == "" {
+		config.Queue = defaultQueue
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = 2 * time.Second
+	}
+	if config.JobTimeout <= 0 {
+		config.JobTimeout = 10 * time.Minute
+	}
+	if config.StaleAfter <= 0 {
+		config.StaleAfter = 30 * time.Minute
+	}
+
+	hostname, _ := os.Hostname()
+
+	return &Queue{
+		repo:     repo,
+		config:   config,
+		logger:   logger,
+		workerID: fmt.Sprintf("%s:%d", hostname, os.Getpid()),
+		handlers: map[string]Handler{},
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// Register sets the handler for a job kind
+func (q *Queue) Register(kind string, handler Handler) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.handlers[kind] = handler
+}
+
+// Option customizes an enqueued job
+type Option func(*models.Job)
+
+// RunAt schedules the job for a specific time
+func RunAt(t time.Time) Option {
+	return func(j *models.Job) { j.RunAt = t }
+}
+
+// Delay schedules the job to run after d
+func Delay(d time.Duration) Option {
+	return func(j *models.Job) { j.RunAt = time.Now().Add(d) }
+}
+
+// MaxAttempts overrides how many times the job may run before it is dead-lettered
+func MaxAttempts(n int) Option {
+	return func(j *models.Job) { j.MaxAttempts = n }
+}
+
+// ForBlog associates the job with a blog so failures show up in that blog's dashboard
+func ForBlog(blogID uuid.UUID) Option {
+	return func(j *models.Job) { j.BlogID = &blogID }
+}
+
+// Enqueue stores a job to be run by a worker. The payload is encoded as JSON.
+func (q *Queue) Enqueue(ctx context.Context, kind string, payload interface{}, opts ...Option) (*models.Job, error) {
+	job, err := q.Build(kind, payload, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := q.repo.Enqueue(ctx, job); err != nil {
+		return nil, err
+	}
+
+	q.Notify()
+	return job, nil
+}
+
+// Build returns a job for this queue without storing it, for callers that
+// insert jobs in their own transaction. Call Notify once they're committed.
+func (q *Queue) Build(kind string, payload interface{}, opts ...Option) (*models.Job, error) {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode job payload: %w", err)
+	}
+
+	job := &models.Job{
+		Queue:       q.config.Queue,
+		Kind:        kind,
+		Payload:     raw,
+		MaxAttempts: defaultMaxAttempts,
+		RunAt:       time.Now(),
+	}
+	for _, opt := range opts {
+		opt(job)
+	}
+	return job, nil
+}
+
+// Notify nudges an idle local worker so new jobs don't wait for the next poll
+func (q *Queue) Notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start launches the worker pool and maintenance loop
+func (q *Queue) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	q.cancel = cancel
+
+	for i := 0; i < q.config.Workers; i++ {
+		q.wg.Add(1)
+		go q.work(ctx, fmt.Sprintf("%s#%d", q.workerID, i))
+	}
+
+	q.wg.Add(1)
+	go q.maintain(ctx)
+
+	q.logger.Info("Job workers started", "queue", q.config.Queue, "workers", q.config.Workers)
+}
+
+// Shutdown stops claiming new jobs and waits for running jobs to finish.
+// Jobs still running when ctx expires are left locked and picked up again once stale.
+func (q *Queue) Shutdown(ctx context.Context) error {
+	if q.cancel == nil {
+		return nil
+	}
+	q.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		q.logger.Info("Job workers drained")
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("job workers did not drain: %w", ctx.Err())
+	}
+}
+
+// work claims and runs jobs until ctx is cancelled
+func (q *Queue) work(ctx context.Context, workerID string) {
+	defer q.wg.Done()
+
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		job, err := q.repo.ClaimNext(ctx, q.config.Queue, workerID)
+		if err != nil {
+			if !errors.Is(err, repository.ErrJobNotFound) && ctx.Err() == nil {
+				q.logger.Error("Failed to claim job", "worker", workerID, "error", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-q.wake:
+			case <-time.After(q.config.PollInterval):
+			}
+			continue
+		}
+
+		q.run(job)
+	}
+}
+
+// run executes a claimed job and records the outcome. It deliberately does not use
+// the worker context so that shutdown lets in-flight jobs finish.
+func (q *Queue) run(job *models.Job) {
+	q.mu.RLock()
+	handler, ok := q.handlers[job.Kind]
+	q.mu.RUnlock()
+
+	var err error
+	if !ok {
+		err = Permanent(fmt.Errorf("no handler registered for job kind %q", job.Kind))
+	} else {
+		jobCtx, cancel := context.WithTimeout(context.Background(), q.config.JobTimeout)
+		err = safeCall(jobCtx, handler, job)
+		cancel()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), outcomeTimeout)
+	defer cancel()
+
+	if err == nil {
+		if err := q.repo.MarkDone(ctx, job.ID); err != nil {
+			q.logger.Error("Failed to mark job done", "job_id", job.ID, "kind", job.Kind, "error", err)
+		}
+		return
+	}
+
+	if IsPermanent(err) || job.Attempts >= job.MaxAttempts {
+		q.logger.Error("Job failed permanently", "job_id", job.ID, "kind", job.Kind, "attempts", job.Attempts, "error", err)
+		if err := q.repo.MarkDead(ctx, job.ID, err.Error()); err != nil {
+			q.logger.Error("Failed to dead-letter job", "job_id", job.ID, "error", err)
+		}
+		return
+	}
+
+	retryAt := time.Now().Add(Backoff(job.Attempts))
+	q.logger.Warn("Job failed, will retry", "job_id", job.ID, "kind", job.Kind, "attempts", job.Attempts, "retry_at", retryAt.Format(time.RFC3339), "error", err)
+	if err := q.repo.Reschedule(ctx, job.ID, retryAt, err.Error()); err != nil {
+		q.logger.Error("Failed to reschedule job", "job_id", job.ID, "error", err)
+	}
+}
+
+// maintain periodically recovers abandoned jobs and prunes finished ones
+func (q *Queue) maintain(ctx context.Context) {
+	defer q.wg.Done()
+
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if n, err := q.repo.ReleaseStale(ctx, q.config.StaleAfter); err != nil {
+				q.logger.Error("Failed to release stale jobs", "error", err)
+			} else if n > 0 {
+				q.logger.Warn("Released stale jobs", "count", n)
+			}
+			if _, err := q.repo.DeleteFinishedBefore(ctx, time.Now().Add(-finishedRetention)); err != nil {
+				q.logger.Error("Failed to prune finished jobs", "error", err)
+			}
+		}
+	}
+}
+
+// safeCall runs a handler, turning panics into errors so one bad job can't kill a worker
+func safeCall(ctx context.Context, handler Handler, job *models.Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v\n%s", r, debug.Stack())
+		}
+	}()
+	return handler(ctx, job)
+}
+
+// Backoff returns the delay before retrying a job that has failed attempt times.
+// Exponential from 15s, capped at an hour, with up to 10% jitter so retries spread out.
+func Backoff(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	delay := maxBackoff
+	if attempt < 20 {
+		delay = minBackoff << (attempt - 1)
+		if delay > maxBackoff {
+			delay = maxBackoff
+		}
+	}
+	return delay + time.Duration(rand.Int64N(int64(delay/10)+1))
+}
@@ -0,0 +1,27 @@
+package mailer
+
+import (
+	"context"
+
+	"github.com/cassiascheffer/willow_camp/internal/logging"
+)
+
+// LogMailer logs messages instead of sending them (development fallback)
+type LogMailer struct {
+	logger *logging.Logger
+}
+
+// NewLogMailer creates a new LogMailer
+func NewLogMailer(logger *logging.Logger) *LogMailer {
+	return &LogMailer{logger: logger}
+}
+
+// Send logs the message envelope and text body
+func (m *LogMailer) Send(ctx context.Context, msg *Message) error {
+	m.logger.Info("Email (not sent, SMTP_HOST unset)",
+		"to", msg.To,
+		"subject", msg.Subject,
+		"body", msg.TextBody,
+	)
+	return nil
+}
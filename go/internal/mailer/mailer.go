@@ -0,0 +1,141 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net/textproto"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cassiascheffer/willow_camp/internal/logging"
+)
+
+// Message is a single outgoing email
+type Message struct {
+	From     string
+	To       string
+	Subject  string
+	TextBody string
+	HTMLBody string
+	// Headers holds extra headers such as List-Unsubscribe
+	Headers map[string]string
+}
+
+// Mailer sends email messages. Implementations must be safe for concurrent use.
+type Mailer interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// NewFromEnv builds a mailer from SMTP_* environment variables.
+// Falls back to a LogMailer when SMTP_HOST is not set so development works without a mail server.
+func NewFromEnv(logger *logging.Logger) Mailer {
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		logger.Info("SMTP_HOST not set, emails will be logged instead of sent")
+		return NewLogMailer(logger)
+	}
+
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "587"
+	}
+
+	return NewSMTPMailer(SMTPConfig{
+		Host:     host,
+		Port:     port,
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+	})
+}
+
+// DefaultFrom returns the sender address from MAIL_FROM, or a no-reply address on the base domain
+func DefaultFrom(baseDomain string) string {
+	if from := os.Getenv("MAIL_FROM"); from != "" {
+		return from
+	}
+	host := baseDomain
+	if idx := strings.Index(host, ":"); idx != -1 {
+		host = host[:idx]
+	}
+	return "willow.camp <no-reply@" + host + ">"
+}
+
+// Bytes renders the message as an RFC 5322 message with a multipart/alternative body
+func (m *Message) Bytes() ([]byte, error) {
+	var buf bytes.Buffer
+
+	boundary, err := randomBoundary()
+	if err != nil {
+		return nil, err
+	}
+
+	headers := map[string]string{
+		"From":         m.From,
+		"To":           m.To,
+		"Subject":      mime.QEncoding.Encode("utf-8", m.Subject),
+		"Date":         time.Now().Format(time.RFC1123Z),
+		"MIME-Version": "1.0",
+		"Content-Type": `multipart/alternative; boundary="` + boundary + `"`,
+	}
+	for k, v := range m.Headers {
+		headers[textproto.CanonicalMIMEHeaderKey(k)] = v
+	}
+
+	// Write headers in a stable order so messages are easy to test
+	keys := make([]string, 0, len(headers))
+	for k := range headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if strings.ContainsAny(headers[k], "\r\n") {
+			return nil, fmt.Errorf("invalid header value for %s", k)
+		}
+		fmt.Fprintf(&buf, "%s: %s\r\n", k, headers[k])
+	}
+	buf.WriteString("\r\n")
+
+	if err := writePart(&buf, boundary, "text/plain", m.TextBody); err != nil {
+		return nil, err
+	}
+	if m.HTMLBody != "" {
+		if err := writePart(&buf, boundary, "text/html", m.HTMLBody); err != nil {
+			return nil, err
+		}
+	}
+	fmt.Fprintf(&buf, "--%s--\r\n", boundary)
+
+	return buf.Bytes(), nil
+}
+
+// writePart writes a quoted-printable encoded MIME part
+func writePart(buf *bytes.Buffer, boundary, contentType, body string) error {
+	fmt.Fprintf(buf, "--%s\r\n", boundary)
+	fmt.Fprintf(buf, "Content-Type: %s; charset=utf-8\r\n", contentType)
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+	w := quotedprintable.NewWriter(buf)
+	if _, err := w.Write([]byte(body)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	buf.WriteString("\r\n")
+	return nil
+}
+
+func randomBoundary() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate boundary: %w", err)
+	}
+	return "willow-" + hex.EncodeToString(b), nil
+}
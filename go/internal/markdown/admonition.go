@@ -0,0 +1,118 @@
+package markdown
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// KindAdmonition is the node kind of an Admonition
+var KindAdmonition = ast.NewNodeKind("Admonition")
+
+// Admonition is a callout such as a note or warning, written as a
+// blockquote whose first line is a marker like [!NOTE], as on GitHub
+type Admonition struct {
+	ast.BaseBlock
+	// AdmonitionType is the lowercase marker, e.g. "note" or "warning"
+	AdmonitionType string
+}
+
+func (n *Admonition) Kind() ast.NodeKind {
+	return KindAdmonition
+}
+
+func (n *Admonition) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"AdmonitionType": n.AdmonitionType}, nil)
+}
+
+// admonitionMarker matches the first line of an admonition blockquote
+var admonitionMarker = regexp.MustCompile(`^\[!(?i:(NOTE|TIP|IMPORTANT|WARNING|CAUTION))\]$`)
+
+// admonitions is a goldmark extension that turns marked blockquotes into
+// Admonition nodes
+type admonitions struct{}
+
+func (admonitions) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(parser.WithASTTransformers(
+		util.Prioritized(admonitionTransformer{}, 400),
+	))
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(
+		util.Prioritized(admonitionRenderer{}, 500),
+	))
+}
+
+type admonitionTransformer struct{}
+
+func (admonitionTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	source := reader.Source()
+
+	// Collect first, since replacing nodes during the walk would derail it
+	var quotes []*ast.Blockquote
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if quote, ok := n.(*ast.Blockquote); ok && entering {
+			quotes = append(quotes, quote)
+		}
+		return ast.WalkContinue, nil
+	})
+
+	for _, quote := range quotes {
+		para, ok := quote.FirstChild().(*ast.Paragraph)
+		if !ok || para.Lines().Len() == 0 {
+			continue
+		}
+		first := para.Lines().At(0)
+		m := admonitionMarker.FindSubmatch(bytes.TrimSpace(first.Value(source)))
+		if m == nil {
+			continue
+		}
+
+		// Drop the marker line's inline nodes, keeping any text after it
+		for child := para.FirstChild(); child != nil; {
+			t, ok := child.(*ast.Text)
+			if !ok || t.Segment.Stop > first.Stop {
+				break
+			}
+			next := child.NextSibling()
+			para.RemoveChild(para, child)
+			child = next
+			if t.SoftLineBreak() || t.HardLineBreak() {
+				break
+			}
+		}
+		if para.ChildCount() == 0 {
+			quote.RemoveChild(quote, para)
+		}
+
+		admonition := &Admonition{AdmonitionType: strings.ToLower(string(m[1]))}
+		for child := quote.FirstChild(); child != nil; {
+			next := child.NextSibling()
+			admonition.AppendChild(admonition, child)
+			child = next
+		}
+		quote.Parent().ReplaceChild(quote.Parent(), quote, admonition)
+	}
+}
+
+type admonitionRenderer struct{}
+
+func (admonitionRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindAdmonition, renderAdmonition)
+}
+
+func renderAdmonition(w util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+	kind := n.(*Admonition).AdmonitionType
+	if !entering {
+		_, _ = w.WriteString("</div>\n")
+		return ast.WalkContinue, nil
+	}
+	_, _ = w.WriteString(`<div class="markdown-alert markdown-alert-` + kind + `">` + "\n")
+	_, _ = w.WriteString(`<p class="markdown-alert-title">` + strings.ToUpper(kind[:1]) + kind[1:] + "</p>\n")
+	return ast.WalkContinue, nil
+}
@@ -0,0 +1,124 @@
+package markdown
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// DiagramLanguage is the info string of fenced code blocks rendered as diagrams
+const DiagramLanguage = "mermaid"
+
+// DiagramResolver returns the SVG rendered for a diagram's source. It returns
+// false for diagrams that haven't been rendered, which are left as code
+// blocks for mermaid.js to draw in the browser.
+type DiagramResolver func(source string) (svg string, ok bool)
+
+var diagramResolverKey = parser.NewContextKey()
+
+// DiagramHash identifies a diagram by its source, for caching rendered SVGs
+func DiagramHash(source string) string {
+	sum := sha256.Sum256([]byte(source))
+	return hex.EncodeToString(sum[:])
+}
+
+// DiagramSources returns the source of every diagram in markdown, in order
+func DiagramSources(source string) []string {
+	src := []byte(source)
+	var sources []string
+	ast.Walk(md.Parser().Parse(text.NewReader(src)), func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if block, ok := n.(*ast.FencedCodeBlock); ok && entering && isDiagram(block, src) {
+			sources = append(sources, string(block.Lines().Value(src)))
+		}
+		return ast.WalkContinue, nil
+	})
+	return sources
+}
+
+func isDiagram(block *ast.FencedCodeBlock, src []byte) bool {
+	return string(block.Language(src)) == DiagramLanguage
+}
+
+// KindDiagram is the node kind of a Diagram
+var KindDiagram = ast.NewNodeKind("Diagram")
+
+// Diagram is a diagram code block replaced by its rendered SVG
+type Diagram struct {
+	ast.BaseBlock
+	// Hash is the DiagramHash of the source
+	Hash string
+	SVG  string
+}
+
+func (n *Diagram) Kind() ast.NodeKind {
+	return KindDiagram
+}
+
+func (n *Diagram) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Hash": n.Hash}, nil)
+}
+
+// diagrams is a goldmark extension that swaps diagram code blocks for the
+// SVG the resolver has for them
+type diagrams struct{}
+
+func (diagrams) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(parser.WithASTTransformers(
+		util.Prioritized(diagramTransformer{}, 500),
+	))
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(
+		util.Prioritized(diagramRenderer{}, 500),
+	))
+}
+
+type diagramTransformer struct{}
+
+func (diagramTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	resolve, ok := pc.Get(diagramResolverKey).(DiagramResolver)
+	if !ok || resolve == nil {
+		return
+	}
+
+	src := reader.Source()
+	var blocks []*ast.FencedCodeBlock
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if block, ok := n.(*ast.FencedCodeBlock); ok && entering && isDiagram(block, src) {
+			blocks = append(blocks, block)
+		}
+		return ast.WalkContinue, nil
+	})
+
+	for _, block := range blocks {
+		source := string(block.Lines().Value(src))
+		svg, ok := resolve(source)
+		if !ok {
+			continue
+		}
+		block.Parent().ReplaceChild(block.Parent(), block, &Diagram{Hash: DiagramHash(source), SVG: svg})
+	}
+}
+
+type diagramRenderer struct{}
+
+func (diagramRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindDiagram, renderDiagram)
+}
+
+// renderDiagram writes the SVG inline. data-diagram lets feeds swap it for an
+// image of the same diagram.
+func renderDiagram(w util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	diagram := n.(*Diagram)
+	_, _ = w.WriteString(`<figure class="mermaid-diagram" data-diagram="` + diagram.Hash + `">`)
+	_, _ = w.WriteString(diagram.SVG)
+	_, _ = w.WriteString("</figure>\n")
+	return ast.WalkSkipChildren, nil
+}
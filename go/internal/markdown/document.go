@@ -0,0 +1,181 @@
+package markdown
+
+import (
+	"bytes"
+	"html/template"
+	"strings"
+
+	"github.com/cassiascheffer/willow_camp/internal/models"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+)
+
+// WordsPerMinute is the reading speed used to estimate reading time
+const WordsPerMinute = 200
+
+// Document is rendered markdown along with what was learned while rendering it
+type Document struct {
+	HTML template.HTML
+	// TOC is the heading tree, linking to the ids WithAutoHeadingID assigns
+	TOC TOC
+	// WordCount counts the words of prose, leaving out code blocks
+	WordCount int
+	// ReadingTime is the estimated minutes to read, at least 1 for any text
+	ReadingTime int
+	// FirstImage is the src of the first image, e.g. for social cards
+	FirstImage string
+}
+
+// Heading is one entry of a table of contents
+type Heading struct {
+	Level    int
+	ID       string
+	Text     string
+	Children TOC
+}
+
+// TOC is a list of headings, each holding the lower-level headings under it
+type TOC []*Heading
+
+// Len counts every heading in the tree
+func (t TOC) Len() int {
+	n := len(t)
+	for _, h := range t {
+		n += h.Children.Len()
+	}
+	return n
+}
+
+// Resolvers look up what a post refers to while it renders. Any of them may
+// be nil.
+type Resolvers struct {
+	// Images makes uploaded images responsive
+	Images ImageResolver
+	// Diagrams draws diagrams as inline SVG
+	Diagrams DiagramResolver
+	// Embeds turns URLs into embeds and link cards
+	Embeds EmbedResolver
+	// Previews turns the URLs no embed handles into preview cards
+	Previews PreviewResolver
+}
+
+// RenderDocument converts markdown to HTML like RenderWithImages, also
+// collecting its table of contents, word count and first image
+func RenderDocument(source string, resolvers Resolvers) (*Document, error) {
+	return For(models.MarkdownOptions{}).RenderDocument(source, resolvers)
+}
+
+// Analyze collects the table of contents, word count and first image without
+// rendering, for lists of posts
+func Analyze(source string) *Document {
+	return For(models.MarkdownOptions{}).Analyze(source)
+}
+
+// RenderDocument is the package-level RenderDocument with this renderer's options
+func (r *Renderer) RenderDocument(source string, resolvers Resolvers) (*Document, error) {
+	ctx := parser.NewContext()
+	if resolvers.Images != nil {
+		ctx.Set(imageResolverKey, resolvers.Images)
+	}
+	if resolvers.Diagrams != nil {
+		ctx.Set(diagramResolverKey, resolvers.Diagrams)
+	}
+	if resolvers.Embeds != nil {
+		ctx.Set(embedResolverKey, resolvers.Embeds)
+	}
+	if resolvers.Previews != nil {
+		ctx.Set(previewResolverKey, resolvers.Previews)
+	}
+
+	src := []byte(source)
+	root := r.md.Parser().Parse(text.NewReader(src), parser.WithContext(ctx))
+	doc := analyze(root, src)
+
+	var buf bytes.Buffer
+	if err := r.md.Renderer().Render(&buf, src, root); err != nil {
+		return nil, err
+	}
+	doc.HTML = template.HTML(buf.String())
+	return doc, nil
+}
+
+// Analyze is the package-level Analyze with this renderer's options
+func (r *Renderer) Analyze(source string) *Document {
+	src := []byte(source)
+	return analyze(r.md.Parser().Parse(text.NewReader(src)), src)
+}
+
+func analyze(root ast.Node, src []byte) *Document {
+	doc := &Document{}
+	var words strings.Builder
+	// open holds the most recent heading at each depth of the tree
+	var open []*Heading
+
+	ast.Walk(root, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch node := n.(type) {
+		case *ast.FencedCodeBlock, *ast.CodeBlock:
+			return ast.WalkSkipChildren, nil
+		case *ast.Heading:
+			heading := &Heading{Level: node.Level, Text: plainText(node, src)}
+			if id, ok := node.AttributeString("id"); ok {
+				if b, ok := id.([]byte); ok {
+					heading.ID = string(b)
+				}
+			}
+			for len(open) > 0 && open[len(open)-1].Level >= heading.Level {
+				open = open[:len(open)-1]
+			}
+			if len(open) == 0 {
+				doc.TOC = append(doc.TOC, heading)
+			} else {
+				parent := open[len(open)-1]
+				parent.Children = append(parent.Children, heading)
+			}
+			open = append(open, heading)
+		case *ast.Image:
+			if doc.FirstImage == "" {
+				doc.FirstImage = string(node.Destination)
+			}
+			// Alt text isn't read as part of the prose
+			return ast.WalkSkipChildren, nil
+		case *ast.Text:
+			words.Write(node.Segment.Value(src))
+			words.WriteByte(' ')
+		case *ast.String:
+			words.Write(node.Value)
+			words.WriteByte(' ')
+		}
+		return ast.WalkContinue, nil
+	})
+
+	doc.WordCount = len(strings.Fields(words.String()))
+	if doc.WordCount > 0 {
+		doc.ReadingTime = (doc.WordCount + WordsPerMinute - 1) / WordsPerMinute
+	}
+	return doc
+}
+
+// plainText joins the text inside a node, dropping any inline markup
+func plainText(n ast.Node, src []byte) string {
+	var b strings.Builder
+	ast.Walk(n, func(child ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch node := child.(type) {
+		case *ast.Text:
+			b.Write(node.Segment.Value(src))
+			if node.SoftLineBreak() {
+				b.WriteByte(' ')
+			}
+		case *ast.String:
+			b.Write(node.Value)
+		}
+		return ast.WalkContinue, nil
+	})
+	return strings.TrimSpace(b.String())
+}
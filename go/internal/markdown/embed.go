@@ -0,0 +1,181 @@
+package markdown
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// Embed is what an EmbedResolver knows about a URL. HTML is the provider's
+// sanitized embed; without it the URL is shown as a link card.
+type Embed struct {
+	URL      string
+	HTML     string
+	Title    string
+	Provider string
+}
+
+// EmbedResolver returns the embed for a URL written on its own line or in an
+// {{< embed url >}} shortcode. It returns false for URLs no provider handles:
+// those stay plain links on their own line and become link cards in a
+// shortcode.
+type EmbedResolver func(url string) (Embed, bool)
+
+var embedResolverKey = parser.NewContextKey()
+
+var (
+	// embedShortcode matches {{< embed https://example.com/video >}}
+	embedShortcode = regexp.MustCompile(`^\{\{<\s*embed\s+(\S+)\s*>\}\}$`)
+	bareURL        = regexp.MustCompile(`^https?://\S+$`)
+)
+
+// embedURL returns the URL a paragraph embeds, if it is a single line holding
+// only a URL or an embed shortcode
+func embedURL(para *ast.Paragraph, src []byte) (link string, shortcode bool, ok bool) {
+	if para.Lines().Len() != 1 {
+		return "", false, false
+	}
+	segment := para.Lines().At(0)
+	line := strings.TrimSpace(string(segment.Value(src)))
+	if m := embedShortcode.FindStringSubmatch(line); m != nil {
+		link, shortcode = m[1], true
+	} else if bareURL.MatchString(line) {
+		link = line
+	} else {
+		return "", false, false
+	}
+
+	u, err := url.Parse(link)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", false, false
+	}
+	return link, shortcode, true
+}
+
+// EmbedURLs returns the URL of every embed candidate in markdown, in order
+func EmbedURLs(source string) []string {
+	src := []byte(source)
+	var urls []string
+	for n := md.Parser().Parse(text.NewReader(src)).FirstChild(); n != nil; n = n.NextSibling() {
+		switch n := n.(type) {
+		case *ast.Paragraph:
+			if link, _, ok := embedURL(n, src); ok {
+				urls = append(urls, link)
+			}
+		case *EmbedBlock:
+			// Shortcodes are replaced while parsing, even without a resolver
+			urls = append(urls, n.Embed.URL)
+		}
+	}
+	return urls
+}
+
+// KindEmbedBlock is the node kind of an EmbedBlock
+var KindEmbedBlock = ast.NewNodeKind("EmbedBlock")
+
+// EmbedBlock is a paragraph replaced by an embed or a link card
+type EmbedBlock struct {
+	ast.BaseBlock
+	Embed Embed
+}
+
+func (n *EmbedBlock) Kind() ast.NodeKind {
+	return KindEmbedBlock
+}
+
+func (n *EmbedBlock) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"URL": n.Embed.URL}, nil)
+}
+
+// embeds is a goldmark extension that turns top-level paragraphs holding
+// just a URL or an embed shortcode into embeds
+type embeds struct{}
+
+func (embeds) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(parser.WithASTTransformers(
+		util.Prioritized(embedTransformer{}, 500),
+	))
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(
+		util.Prioritized(embedRenderer{}, 500),
+	))
+}
+
+type embedTransformer struct{}
+
+func (embedTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	resolve, _ := pc.Get(embedResolverKey).(EmbedResolver)
+	src := reader.Source()
+
+	for n := doc.FirstChild(); n != nil; {
+		next := n.NextSibling()
+		para, ok := n.(*ast.Paragraph)
+		if !ok {
+			n = next
+			continue
+		}
+		link, shortcode, ok := embedURL(para, src)
+		if !ok {
+			n = next
+			continue
+		}
+
+		embed := Embed{URL: link}
+		found := false
+		if resolve != nil {
+			if e, ok := resolve(link); ok {
+				embed, found = e, true
+				embed.URL = link
+			}
+		}
+		// Bare URLs nobody embeds stay the links they always were
+		if found || shortcode {
+			doc.ReplaceChild(doc, para, &EmbedBlock{Embed: embed})
+		}
+		n = next
+	}
+}
+
+type embedRenderer struct{}
+
+func (embedRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindEmbedBlock, renderEmbed)
+}
+
+// renderEmbed writes the provider's HTML, or a link card. data-embed-url lets
+// feeds swap an embed for a link.
+func renderEmbed(w util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	embed := n.(*EmbedBlock).Embed
+	link := html.EscapeString(embed.URL)
+
+	if embed.HTML != "" {
+		_, _ = w.WriteString(`<figure class="embed" data-embed-url="` + link + `">`)
+		_, _ = w.WriteString(embed.HTML)
+		_, _ = w.WriteString("</figure>\n")
+		return ast.WalkSkipChildren, nil
+	}
+
+	title := embed.Title
+	if title == "" {
+		title = embed.URL
+	}
+	site := embed.Provider
+	if u, err := url.Parse(embed.URL); err == nil && site == "" {
+		site = u.Host
+	}
+	_, _ = w.WriteString(`<p class="embed-card"><a href="` + link + `">`)
+	_, _ = w.WriteString(`<span class="embed-card-title">` + html.EscapeString(title) + `</span>`)
+	_, _ = w.WriteString(`<span class="embed-card-site">` + html.EscapeString(site) + `</span>`)
+	_, _ = w.WriteString("</a></p>\n")
+	return ast.WalkSkipChildren, nil
+}
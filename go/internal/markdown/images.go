@@ -0,0 +1,93 @@
+package markdown
+
+import (
+	"bytes"
+	"html/template"
+	"strconv"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// ImageInfo describes a locally hosted image so it can be rendered responsively
+type ImageInfo struct {
+	Width  int
+	Height int
+	// Srcset lists resized copies, e.g. "/media/a.jpg/480w.jpg 480w, /media/a.jpg 1200w"
+	Srcset string
+}
+
+// ImageResolver looks up an image by its src. It returns false for images
+// that aren't the blog's own media, which are rendered unchanged.
+type ImageResolver func(src string) (ImageInfo, bool)
+
+// imageSizes matches the blog layout, which caps content at max-w-3xl (768px) minus padding
+const imageSizes = "(max-width: 768px) 100vw, 704px"
+
+var imageResolverKey = parser.NewContextKey()
+
+// RenderWithImages converts markdown to HTML, adding srcset, dimensions and lazy
+// loading to images the resolver recognizes
+func RenderWithImages(source string, resolve ImageResolver) (template.HTML, error) {
+	ctx := parser.NewContext()
+	if resolve != nil {
+		ctx.Set(imageResolverKey, resolve)
+	}
+
+	var buf bytes.Buffer
+	if err := md.Convert([]byte(source), &buf, parser.WithContext(ctx)); err != nil {
+		return "", err
+	}
+	return template.HTML(buf.String()), nil
+}
+
+// responsiveImages is a goldmark extension that annotates images with
+// responsive attributes. The default image renderer writes them out.
+type responsiveImages struct{}
+
+func (responsiveImages) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(parser.WithASTTransformers(
+		util.Prioritized(responsiveImageTransformer{}, 500),
+	))
+}
+
+type responsiveImageTransformer struct{}
+
+func (responsiveImageTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	resolve, ok := pc.Get(imageResolverKey).(ImageResolver)
+	if !ok || resolve == nil {
+		return
+	}
+
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		img, ok := n.(*ast.Image)
+		if !ok {
+			return ast.WalkContinue, nil
+		}
+
+		info, ok := resolve(string(img.Destination))
+		if !ok {
+			return ast.WalkContinue, nil
+		}
+
+		if info.Width > 0 && info.Height > 0 {
+			// Dimensions let the browser reserve space and avoid layout shift
+			img.SetAttributeString("width", []byte(strconv.Itoa(info.Width)))
+			img.SetAttributeString("height", []byte(strconv.Itoa(info.Height)))
+		}
+		if info.Srcset != "" {
+			img.SetAttributeString("srcset", []byte(info.Srcset))
+			img.SetAttributeString("sizes", []byte(imageSizes))
+		}
+		img.SetAttributeString("loading", []byte("lazy"))
+		img.SetAttributeString("decoding", []byte("async"))
+
+		return ast.WalkContinue, nil
+	})
+}
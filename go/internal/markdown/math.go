@@ -0,0 +1,212 @@
+package markdown
+
+import (
+	"bytes"
+	"html"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+var (
+	// KindMath is the node kind of inline math
+	KindMath = ast.NewNodeKind("Math")
+	// KindMathBlock is the node kind of display math on its own lines
+	KindMathBlock = ast.NewNodeKind("MathBlock")
+)
+
+// Math is $inline$ math, or $$display$$ math within a paragraph
+type Math struct {
+	ast.BaseInline
+	TeX     []byte
+	Display bool
+}
+
+func (n *Math) Kind() ast.NodeKind {
+	return KindMath
+}
+
+func (n *Math) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"TeX": string(n.TeX)}, nil)
+}
+
+// MathBlock is display math between lines of $$
+type MathBlock struct {
+	ast.BaseBlock
+	TeX []byte
+	// closed is set once the closing $$ has been read
+	closed bool
+}
+
+func (n *MathBlock) Kind() ast.NodeKind {
+	return KindMathBlock
+}
+
+func (n *MathBlock) IsRaw() bool {
+	return true
+}
+
+func (n *MathBlock) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"TeX": string(n.TeX)}, nil)
+}
+
+// math is a goldmark extension rendering LaTeX math as MathML, so readers
+// need no script to see it
+type math struct{}
+
+func (math) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithBlockParsers(util.Prioritized(mathBlockParser{}, 150)),
+		parser.WithInlineParsers(util.Prioritized(mathInlineParser{}, 150)),
+	)
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(
+		util.Prioritized(mathRenderer{}, 500),
+	))
+}
+
+var mathDelimiter = []byte("$$")
+
+type mathBlockParser struct{}
+
+func (mathBlockParser) Trigger() []byte {
+	return []byte{'$'}
+}
+
+func (mathBlockParser) Open(parent ast.Node, reader text.Reader, pc parser.Context) (ast.Node, parser.State) {
+	line, _ := reader.PeekLine()
+	pos := pc.BlockOffset()
+	if pos < 0 || !bytes.HasPrefix(line[pos:], mathDelimiter) {
+		return nil, parser.NoChildren
+	}
+	rest := bytes.TrimSpace(line[pos+len(mathDelimiter):])
+	node := &MathBlock{}
+	if len(rest) > 0 {
+		// Only $$...$$ alone on a line is a block; otherwise it's inline math
+		// at the start of a paragraph
+		tex, ok := bytes.CutSuffix(rest, mathDelimiter)
+		if !ok || len(tex) == 0 || bytes.Contains(tex, mathDelimiter) {
+			return nil, parser.NoChildren
+		}
+		node.TeX = append(node.TeX, tex...)
+		node.closed = true
+	}
+	reader.AdvanceToEOL()
+	return node, parser.NoChildren
+}
+
+func (mathBlockParser) Continue(node ast.Node, reader text.Reader, pc parser.Context) parser.State {
+	block := node.(*MathBlock)
+	if block.closed {
+		return parser.Close
+	}
+	line, _ := reader.PeekLine()
+	content := bytes.TrimSpace(line)
+	tex, closing := bytes.CutSuffix(content, mathDelimiter)
+	block.TeX = append(block.TeX, tex...)
+	if !closing {
+		block.TeX = append(block.TeX, '\n')
+	}
+	reader.AdvanceToEOL()
+	if closing {
+		return parser.Close
+	}
+	return parser.Continue | parser.NoChildren
+}
+
+func (mathBlockParser) Close(node ast.Node, reader text.Reader, pc parser.Context) {}
+
+func (mathBlockParser) CanInterruptParagraph() bool {
+	return false
+}
+
+func (mathBlockParser) CanAcceptIndentedLine() bool {
+	return false
+}
+
+type mathInlineParser struct{}
+
+func (mathInlineParser) Trigger() []byte {
+	return []byte{'$'}
+}
+
+// Parse reads $...$ or $$...$$ on one line. Like Pandoc, a single $ must be
+// followed by a non-space and the closing $ preceded by one and not followed
+// by a digit, so prices such as $5 and $10 stay text.
+func (mathInlineParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, _ := block.PeekLine()
+	delim := 1
+	if bytes.HasPrefix(line, mathDelimiter) {
+		delim = 2
+	}
+	body := line[delim:]
+	if len(body) == 0 || isSpace(body[0]) {
+		return nil
+	}
+
+	for i := 0; i < len(body); i++ {
+		switch body[i] {
+		case '\\':
+			i++
+			continue
+		case '$':
+		default:
+			continue
+		}
+		if i == 0 {
+			return nil
+		}
+		if delim == 2 {
+			if i+1 >= len(body) || body[i+1] != '$' {
+				continue
+			}
+		} else if isSpace(body[i-1]) || i+1 < len(body) && body[i+1] >= '0' && body[i+1] <= '9' {
+			continue
+		}
+		block.Advance(delim + i + delim)
+		return &Math{TeX: append([]byte(nil), body[:i]...), Display: delim == 2}
+	}
+	return nil
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r'
+}
+
+type mathRenderer struct{}
+
+func (mathRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindMath, renderMath)
+	reg.Register(KindMathBlock, renderMath)
+}
+
+func renderMath(w util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	var tex []byte
+	display := true
+	switch node := n.(type) {
+	case *Math:
+		tex, display = node.TeX, node.Display
+	case *MathBlock:
+		tex = node.TeX
+	}
+
+	mathML, err := TeXToMathML(string(tex), display)
+	if err != nil {
+		// Show the source rather than lose it; the title says what went wrong
+		_, _ = w.WriteString(`<code class="math-error" title="` + html.EscapeString(err.Error()) + `">`)
+		_, _ = w.WriteString(html.EscapeString(string(tex)))
+		_, _ = w.WriteString("</code>")
+	} else {
+		_, _ = w.WriteString(mathML)
+	}
+	if _, ok := n.(*MathBlock); ok {
+		_ = w.WriteByte('\n')
+	}
+	return ast.WalkSkipChildren, nil
+}
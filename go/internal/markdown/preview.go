@@ -0,0 +1,136 @@
+package markdown
+
+import (
+	"html"
+	"net/url"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// LinkPreview is what a page says about itself in its Open Graph metadata
+type LinkPreview struct {
+	URL         string
+	Title       string
+	Description string
+	// Image is an absolute http(s) URL, or empty
+	Image string
+	Site  string
+}
+
+// PreviewResolver returns the preview for a URL written on its own line that
+// no embed handles. It returns false for URLs without one, which stay plain
+// links.
+type PreviewResolver func(url string) (LinkPreview, bool)
+
+var previewResolverKey = parser.NewContextKey()
+
+// PreviewURLs returns the URL of every bare link on its own line in
+// markdown, in order. Some of them may be embedded instead.
+func PreviewURLs(source string) []string {
+	src := []byte(source)
+	var urls []string
+	for n := md.Parser().Parse(text.NewReader(src)).FirstChild(); n != nil; n = n.NextSibling() {
+		if para, ok := n.(*ast.Paragraph); ok {
+			if link, shortcode, ok := embedURL(para, src); ok && !shortcode {
+				urls = append(urls, link)
+			}
+		}
+	}
+	return urls
+}
+
+// KindLinkPreviewBlock is the node kind of a LinkPreviewBlock
+var KindLinkPreviewBlock = ast.NewNodeKind("LinkPreviewBlock")
+
+// LinkPreviewBlock is a paragraph holding a bare link, replaced by a preview
+// card
+type LinkPreviewBlock struct {
+	ast.BaseBlock
+	Preview LinkPreview
+}
+
+func (n *LinkPreviewBlock) Kind() ast.NodeKind {
+	return KindLinkPreviewBlock
+}
+
+func (n *LinkPreviewBlock) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"URL": n.Preview.URL}, nil)
+}
+
+// linkPreviews is a goldmark extension that turns top-level paragraphs
+// holding just a URL into preview cards. It runs after embeds, so it only
+// sees the links no provider embedded.
+type linkPreviews struct{}
+
+func (linkPreviews) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(parser.WithASTTransformers(
+		util.Prioritized(previewTransformer{}, 510),
+	))
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(
+		util.Prioritized(previewRenderer{}, 500),
+	))
+}
+
+type previewTransformer struct{}
+
+func (previewTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	resolve, _ := pc.Get(previewResolverKey).(PreviewResolver)
+	if resolve == nil {
+		return
+	}
+	src := reader.Source()
+
+	for n := doc.FirstChild(); n != nil; {
+		next := n.NextSibling()
+		if para, ok := n.(*ast.Paragraph); ok {
+			if link, shortcode, ok := embedURL(para, src); ok && !shortcode {
+				if preview, ok := resolve(link); ok {
+					preview.URL = link
+					doc.ReplaceChild(doc, para, &LinkPreviewBlock{Preview: preview})
+				}
+			}
+		}
+		n = next
+	}
+}
+
+type previewRenderer struct{}
+
+func (previewRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindLinkPreviewBlock, renderLinkPreview)
+}
+
+// renderLinkPreview writes a card linking to the page. Its parts are spaced
+// so it still reads as a link where styles and spans are dropped, like feeds.
+func renderLinkPreview(w util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	preview := n.(*LinkPreviewBlock).Preview
+
+	title := preview.Title
+	if title == "" {
+		title = preview.URL
+	}
+	site := preview.Site
+	if u, err := url.Parse(preview.URL); err == nil && site == "" {
+		site = u.Host
+	}
+
+	_, _ = w.WriteString(`<p class="link-preview"><a href="` + html.EscapeString(preview.URL) + `">`)
+	if preview.Image != "" {
+		_, _ = w.WriteString(`<img class="link-preview-image" src="` + html.EscapeString(preview.Image) + `" alt="" loading="lazy"> `)
+	}
+	_, _ = w.WriteString(`<span class="link-preview-title">` + html.EscapeString(title) + `</span> `)
+	if preview.Description != "" {
+		_, _ = w.WriteString(`<span class="link-preview-description">` + html.EscapeString(preview.Description) + `</span> `)
+	}
+	_, _ = w.WriteString(`<span class="link-preview-site">` + html.EscapeString(site) + `</span>`)
+	_, _ = w.WriteString("</a></p>\n")
+	return ast.WalkSkipChildren, nil
+}
@@ -0,0 +1,67 @@
+package markdown
+
+import (
+	"bytes"
+
+	"github.com/cassiascheffer/willow_camp/internal/helpers"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/util"
+)
+
+// rawHTML is a goldmark extension that renders raw HTML in posts through
+// helpers.SanitizePostHTML instead of omitting it. It replaces goldmark's
+// renderers for HTML blocks and inline tags, so everything else markdown
+// generates is untouched.
+type rawHTML struct{}
+
+func (rawHTML) Extend(m goldmark.Markdown) {
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(
+		// Ahead of goldmark's own HTML renderer at 1000
+		util.Prioritized(rawHTMLRenderer{}, 100),
+	))
+}
+
+type rawHTMLRenderer struct{}
+
+func (rawHTMLRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindHTMLBlock, renderHTMLBlock)
+	reg.Register(ast.KindRawHTML, renderRawHTML)
+}
+
+// renderHTMLBlock sanitizes a whole block of HTML at once, e.g. a <div> or
+// an <iframe> on its own lines
+func renderHTMLBlock(w util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	block := n.(*ast.HTMLBlock)
+	var buf bytes.Buffer
+	lines := block.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		line := lines.At(i)
+		buf.Write(line.Value(source))
+	}
+	if block.HasClosure() {
+		buf.Write(block.ClosureLine.Value(source))
+	}
+	_, _ = w.WriteString(helpers.SanitizePostHTML(buf.String()))
+	return ast.WalkSkipChildren, nil
+}
+
+// renderRawHTML sanitizes a single inline tag. Text between an opening and
+// closing tag is markdown and already escaped.
+func renderRawHTML(w util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkSkipChildren, nil
+	}
+	raw := n.(*ast.RawHTML)
+	var buf bytes.Buffer
+	for i := 0; i < raw.Segments.Len(); i++ {
+		segment := raw.Segments.At(i)
+		buf.Write(segment.Value(source))
+	}
+	_, _ = w.WriteString(helpers.SanitizePostHTML(buf.String()))
+	return ast.WalkSkipChildren, nil
+}
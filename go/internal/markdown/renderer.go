@@ -3,7 +3,10 @@ package markdown
 import (
 	"bytes"
 	"html/template"
+	"sync"
 
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/cassiascheffer/willow_camp/internal/models"
 	"github.com/yuin/goldmark"
 	highlighting "github.com/yuin/goldmark-highlighting/v2"
 	"github.com/yuin/goldmark/extension"
@@ -11,20 +14,54 @@ import (
 	"github.com/yuin/goldmark/renderer/html"
 )
 
+// md renders with no optional extensions, the default for every blog
 var md goldmark.Markdown
 
 func init() {
-	md = goldmark.New(
-		goldmark.WithExtensions(
-			extension.GFM,        // GitHub Flavored Markdown
-			extension.Table,      // Tables
-			extension.Strikethrough,
-			extension.Linkify,    // Auto-link URLs
-			extension.TaskList,   // Task lists
-			highlighting.NewHighlighting(
-				highlighting.WithStyle("monokai"),
-			),
+	md = newMarkdown(models.MarkdownOptions{})
+	renderers[models.MarkdownOptions{}] = &Renderer{md: md}
+}
+
+// newMarkdown builds a goldmark instance with the base extensions plus the
+// optional ones turned on in opts
+func newMarkdown(opts models.MarkdownOptions) goldmark.Markdown {
+	extensions := []goldmark.Extender{
+		extension.GFM,        // GitHub Flavored Markdown
+		extension.Table,      // Tables
+		extension.Strikethrough,
+		extension.Linkify,    // Auto-link URLs
+		extension.TaskList,   // Task lists
+		highlighting.NewHighlighting(
+			// Classes instead of inline colors, so each blog's highlight
+			// stylesheet picks the colors. Fences take {linenos=true hl_lines=[2,3]}.
+			highlighting.WithFormatOptions(chromahtml.WithClasses(true)),
 		),
+		responsiveImages{}, // srcset and lazy loading for uploaded images
+		diagrams{},         // server-rendered mermaid diagrams
+		embeds{},           // URLs on their own line and {{< embed >}} shortcodes
+		linkPreviews{},     // preview cards for the other URLs on their own line
+	}
+	if opts.Footnotes {
+		extensions = append(extensions, extension.Footnote)
+	}
+	if opts.DefinitionLists {
+		extensions = append(extensions, extension.DefinitionList)
+	}
+	if opts.Typographer {
+		extensions = append(extensions, extension.Typographer)
+	}
+	if opts.Admonitions {
+		extensions = append(extensions, admonitions{})
+	}
+	if opts.Math {
+		extensions = append(extensions, math{})
+	}
+	if opts.RawHTML {
+		extensions = append(extensions, rawHTML{})
+	}
+
+	return goldmark.New(
+		goldmark.WithExtensions(extensions...),
 		goldmark.WithParserOptions(
 			parser.WithAutoHeadingID(), // Auto-generate heading IDs
 		),
@@ -35,20 +72,53 @@ func init() {
 	)
 }
 
+// Renderer converts markdown with a blog's choice of optional extensions
+type Renderer struct {
+	md goldmark.Markdown
+}
+
+var (
+	renderersMu sync.Mutex
+	renderers   = map[models.MarkdownOptions]*Renderer{}
+)
+
+// For returns the renderer for a blog's markdown options. Renderers are built
+// once per combination of options and shared.
+func For(opts models.MarkdownOptions) *Renderer {
+	renderersMu.Lock()
+	defer renderersMu.Unlock()
+	r, ok := renderers[opts]
+	if !ok {
+		r = &Renderer{md: newMarkdown(opts)}
+		renderers[opts] = r
+	}
+	return r
+}
+
 // Render converts markdown to HTML
-func Render(source string) (template.HTML, error) {
+func (r *Renderer) Render(source string) (template.HTML, error) {
 	var buf bytes.Buffer
-	if err := md.Convert([]byte(source), &buf); err != nil {
+	if err := r.md.Convert([]byte(source), &buf); err != nil {
 		return "", err
 	}
 	return template.HTML(buf.String()), nil
 }
 
 // RenderString is a convenience function that returns a string
-func RenderString(source string) (string, error) {
+func (r *Renderer) RenderString(source string) (string, error) {
 	var buf bytes.Buffer
-	if err := md.Convert([]byte(source), &buf); err != nil {
+	if err := r.md.Convert([]byte(source), &buf); err != nil {
 		return "", err
 	}
 	return buf.String(), nil
 }
+
+// Render converts markdown to HTML with the default options
+func Render(source string) (template.HTML, error) {
+	return For(models.MarkdownOptions{}).Render(source)
+}
+
+// RenderString is a convenience function that returns a string
+func RenderString(source string) (string, error) {
+	return For(models.MarkdownOptions{}).RenderString(source)
+}
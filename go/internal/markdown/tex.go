@@ -0,0 +1,637 @@
+package markdown
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+	"unicode"
+)
+
+// TeXToMathML converts a LaTeX math expression to MathML, which browsers
+// render without any script. It supports the common subset of LaTeX math:
+// scripts, fractions, roots, Greek letters and symbols, functions, accents,
+// font commands, \left...\right and matrix-like environments. Anything else
+// is an error, so the caller can show the source instead.
+func TeXToMathML(tex string, display bool) (string, error) {
+	p := &texParser{src: []rune(tex), display: display}
+	row, err := p.parseRow(func(r rune) bool { return false })
+	if err != nil {
+		return "", err
+	}
+	if p.pos < len(p.src) {
+		return "", fmt.Errorf("unexpected %q", string(p.src[p.pos]))
+	}
+
+	var b strings.Builder
+	b.WriteString(`<math xmlns="http://www.w3.org/1998/Math/MathML"`)
+	if display {
+		b.WriteString(` display="block"`)
+	}
+	b.WriteString(`><semantics>`)
+	b.WriteString(mrow(row))
+	b.WriteString(`<annotation encoding="application/x-tex">`)
+	b.WriteString(html.EscapeString(strings.TrimSpace(tex)))
+	b.WriteString(`</annotation></semantics></math>`)
+	return b.String(), nil
+}
+
+// maxTeXDepth bounds nesting so a pathological expression can't exhaust the stack
+const maxTeXDepth = 64
+
+var (
+	errTeXTooDeep   = errors.New("expression is nested too deeply")
+	errTeXUnclosed  = errors.New("missing closing brace")
+	errTeXArgument  = errors.New("missing argument")
+	errTeXDelimiter = errors.New("missing or unknown delimiter")
+)
+
+// texSymbol is what a command such as \alpha or \leq renders as
+type texSymbol struct {
+	tag  string // mi or mo
+	text string
+	// attrs are extra attributes, e.g. for upright capital Greek letters
+	attrs string
+	// limits are placed under and over the symbol in display math
+	limits bool
+}
+
+var texSymbols = map[string]texSymbol{}
+
+func init() {
+	add := func(tag, attrs string, limits bool, pairs ...string) {
+		for i := 0; i < len(pairs); i += 2 {
+			texSymbols[pairs[i]] = texSymbol{tag: tag, text: pairs[i+1], attrs: attrs, limits: limits}
+		}
+	}
+	add("mi", "", false,
+		"alpha", "α", "beta", "β", "gamma", "γ", "delta", "δ", "epsilon", "ϵ", "varepsilon", "ε",
+		"zeta", "ζ", "eta", "η", "theta", "θ", "vartheta", "ϑ", "iota", "ι", "kappa", "κ",
+		"lambda", "λ", "mu", "μ", "nu", "ν", "xi", "ξ", "omicron", "ο", "pi", "π", "varpi", "ϖ",
+		"rho", "ρ", "varrho", "ϱ", "sigma", "σ", "varsigma", "ς", "tau", "τ", "upsilon", "υ",
+		"phi", "ϕ", "varphi", "φ", "chi", "χ", "psi", "ψ", "omega", "ω",
+		"infty", "∞", "partial", "∂", "nabla", "∇", "hbar", "ℏ", "ell", "ℓ", "Re", "ℜ", "Im", "ℑ",
+		"aleph", "ℵ", "emptyset", "∅", "varnothing", "∅", "imath", "ı", "jmath", "ȷ", "wp", "℘")
+	add("mi", ` mathvariant="normal"`, false,
+		"Gamma", "Γ", "Delta", "Δ", "Theta", "Θ", "Lambda", "Λ", "Xi", "Ξ", "Pi", "Π",
+		"Sigma", "Σ", "Upsilon", "Υ", "Phi", "Φ", "Psi", "Ψ", "Omega", "Ω")
+	add("mo", "", false,
+		"pm", "±", "mp", "∓", "times", "×", "div", "÷", "cdot", "⋅", "ast", "∗", "star", "⋆",
+		"circ", "∘", "bullet", "∙", "oplus", "⊕", "ominus", "⊖", "otimes", "⊗", "odot", "⊙",
+		"cup", "∪", "cap", "∩", "setminus", "∖", "wedge", "∧", "land", "∧", "vee", "∨", "lor", "∨",
+		"neg", "¬", "lnot", "¬", "leq", "≤", "le", "≤", "geq", "≥", "ge", "≥", "neq", "≠", "ne", "≠",
+		"ll", "≪", "gg", "≫", "approx", "≈", "equiv", "≡", "sim", "∼", "simeq", "≃", "cong", "≅",
+		"propto", "∝", "in", "∈", "notin", "∉", "ni", "∋", "subset", "⊂", "subseteq", "⊆",
+		"supset", "⊃", "supseteq", "⊇", "mid", "∣", "parallel", "∥", "perp", "⊥",
+		"forall", "∀", "exists", "∃", "nexists", "∄", "to", "→", "rightarrow", "→",
+		"leftarrow", "←", "gets", "←", "leftrightarrow", "↔", "Rightarrow", "⇒", "Leftarrow", "⇐",
+		"Leftrightarrow", "⇔", "implies", "⟹", "impliedby", "⟸", "iff", "⟺", "mapsto", "↦",
+		"uparrow", "↑", "downarrow", "↓", "ldots", "…", "dots", "…", "cdots", "⋯", "vdots", "⋮",
+		"ddots", "⋱", "angle", "∠", "triangle", "△", "prime", "′", "colon", ":",
+		"langle", "⟨", "rangle", "⟩", "lfloor", "⌊", "rfloor", "⌋", "lceil", "⌈", "rceil", "⌉",
+		"lvert", "|", "rvert", "|", "vert", "|", "lVert", "‖", "rVert", "‖", "Vert", "‖",
+		"|", "‖", "{", "{", "}", "}", "backslash", "\\", "%", "%", "$", "$", "#", "#", "&", "&", "_", "_")
+	add("mo", ` largeop="true"`, false,
+		"int", "∫", "iint", "∬", "iiint", "∭", "oint", "∮")
+	add("mo", ` largeop="true" movablelimits="true"`, true,
+		"sum", "∑", "prod", "∏", "coprod", "∐", "bigcup", "⋃", "bigcap", "⋂",
+		"bigoplus", "⨁", "bigotimes", "⨂", "bigvee", "⋁", "bigwedge", "⋀")
+	for _, name := range []string{"sin", "cos", "tan", "cot", "sec", "csc", "arcsin", "arccos",
+		"arctan", "sinh", "cosh", "tanh", "log", "ln", "lg", "exp", "dim", "ker", "deg", "arg", "hom"} {
+		texSymbols[name] = texSymbol{tag: "mi", text: name}
+	}
+	for _, name := range []string{"lim", "max", "min", "sup", "inf", "det", "gcd", "Pr"} {
+		texSymbols[name] = texSymbol{tag: "mi", text: name, limits: true}
+	}
+	texSymbols["limsup"] = texSymbol{tag: "mi", text: "lim sup", limits: true}
+	texSymbols["liminf"] = texSymbol{tag: "mi", text: "lim inf", limits: true}
+}
+
+// texSpaces are the widths of the spacing commands
+var texSpaces = map[string]string{
+	",": "0.1667em", ":": "0.2222em", ">": "0.2222em", ";": "0.2778em", " ": "0.25em",
+	"quad": "1em", "qquad": "2em", "!": "-0.1667em",
+}
+
+// texAccents are drawn over (or, for \underline, under) their argument
+var texAccents = map[string]string{
+	"hat": "^", "widehat": "^", "bar": "¯", "overline": "¯", "vec": "→", "tilde": "~",
+	"widetilde": "~", "dot": "˙", "ddot": "¨", "check": "ˇ", "breve": "˘", "underline": "_",
+}
+
+// texVariants are the font commands and the mathvariant they select
+var texVariants = map[string]string{
+	"mathrm": "normal", "mathbf": "bold", "mathit": "italic", "mathbb": "double-struck",
+	"mathcal": "script", "mathfrak": "fraktur", "mathsf": "sans-serif", "mathtt": "monospace",
+	"boldsymbol": "bold-italic",
+}
+
+// texMatrices are the matrix environments and their surrounding delimiters
+var texMatrices = map[string][2]string{
+	"matrix": {"", ""}, "smallmatrix": {"", ""}, "pmatrix": {"(", ")"}, "bmatrix": {"[", "]"},
+	"Bmatrix": {"{", "}"}, "vmatrix": {"|", "|"}, "Vmatrix": {"‖", "‖"}, "cases": {"{", ""},
+	"aligned": {"", ""}, "align": {"", ""}, "align*": {"", ""}, "gathered": {"", ""},
+	"array": {"", ""},
+}
+
+var texBigSizes = map[string]string{
+	"big": "1.2em", "bigl": "1.2em", "bigr": "1.2em", "Big": "1.8em", "Bigl": "1.8em", "Bigr": "1.8em",
+	"bigg": "2.4em", "biggl": "2.4em", "biggr": "2.4em", "Bigg": "3em", "Biggl": "3em", "Biggr": "3em",
+}
+
+type texParser struct {
+	src     []rune
+	pos     int
+	display bool
+	depth   int
+	// variant is the mathvariant set by an enclosing font command
+	variant string
+}
+
+func (p *texParser) peek() rune {
+	if p.pos < len(p.src) {
+		return p.src[p.pos]
+	}
+	return 0
+}
+
+func (p *texParser) skipSpace() {
+	for p.pos < len(p.src) && unicode.IsSpace(p.src[p.pos]) {
+		p.pos++
+	}
+}
+
+// parseRow parses atoms until the end of input, a closing brace or a rune
+// that stop accepts, and returns their MathML
+func (p *texParser) parseRow(stop func(r rune) bool) ([]string, error) {
+	var row []string
+	for {
+		p.skipSpace()
+		r := p.peek()
+		if r == 0 || r == '}' || stop(r) {
+			return row, nil
+		}
+		if r == '\\' && p.atCommand("right", "end", "middle") {
+			return row, nil
+		}
+		if r == '\\' && p.atCommand("\\") {
+			return row, nil
+		}
+		atom, err := p.parseScripted()
+		if err != nil {
+			return nil, err
+		}
+		if atom != "" {
+			row = append(row, atom)
+		}
+	}
+}
+
+// atCommand reports whether the input continues with one of the commands
+func (p *texParser) atCommand(names ...string) bool {
+	for _, name := range names {
+		cmd := []rune("\\" + name)
+		if p.pos+len(cmd) > len(p.src) || string(p.src[p.pos:p.pos+len(cmd)]) != string(cmd) {
+			continue
+		}
+		// \right must not match \rightarrow
+		next := p.pos + len(cmd)
+		if unicode.IsLetter(cmd[len(cmd)-1]) && next < len(p.src) && unicode.IsLetter(p.src[next]) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// parseScripted parses an atom followed by any ^ and _ scripts
+func (p *texParser) parseScripted() (string, error) {
+	base, limits, err := p.parseAtom()
+	if err != nil {
+		return "", err
+	}
+
+	var sub, sup string
+	for {
+		p.skipSpace()
+		switch p.peek() {
+		case '^', '_':
+			op := p.peek()
+			p.pos++
+			script, err := p.parseArgument()
+			if err != nil {
+				return "", err
+			}
+			if op == '^' {
+				sup = script
+			} else {
+				sub = script
+			}
+			continue
+		case '\'':
+			p.pos++
+			sup += "<mo>′</mo>"
+			continue
+		}
+		break
+	}
+	if base == "" && (sub != "" || sup != "") {
+		base = "<mrow></mrow>"
+	}
+
+	under, over, both := "msub", "msup", "msubsup"
+	if limits && p.display {
+		under, over, both = "munder", "mover", "munderover"
+	}
+	switch {
+	case sub != "" && sup != "":
+		return "<" + both + ">" + base + sub + mrow([]string{sup}) + "</" + both + ">", nil
+	case sub != "":
+		return "<" + under + ">" + base + sub + "</" + under + ">", nil
+	case sup != "":
+		return "<" + over + ">" + base + mrow([]string{sup}) + "</" + over + ">", nil
+	}
+	return base, nil
+}
+
+// parseArgument parses a braced group or a single atom, as taken by commands
+// and scripts
+func (p *texParser) parseArgument() (string, error) {
+	p.skipSpace()
+	switch p.peek() {
+	case 0, '}', '^', '_', '&':
+		return "", errTeXArgument
+	case '{':
+		return p.parseGroup()
+	}
+	atom, _, err := p.parseAtom()
+	return atom, err
+}
+
+// parseGroup parses {...} into a single mrow
+func (p *texParser) parseGroup() (string, error) {
+	p.pos++ // {
+	row, err := p.parseRow(func(r rune) bool { return false })
+	if err != nil {
+		return "", err
+	}
+	if p.peek() != '}' {
+		return "", errTeXUnclosed
+	}
+	p.pos++
+	return mrow(row), nil
+}
+
+// parseAtom parses one symbol, number, group or command. limits reports
+// whether scripts go under and over it in display math.
+func (p *texParser) parseAtom() (atom string, limits bool, err error) {
+	// Every nested construct passes through here
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxTeXDepth {
+		return "", false, errTeXTooDeep
+	}
+
+	r := p.peek()
+	switch {
+	case r == '{':
+		atom, err = p.parseGroup()
+		return atom, false, err
+	case r == '\\':
+		return p.parseCommand()
+	case unicode.IsDigit(r) || r == '.' && p.pos+1 < len(p.src) && unicode.IsDigit(p.src[p.pos+1]):
+		start := p.pos
+		for p.pos < len(p.src) && (unicode.IsDigit(p.src[p.pos]) || p.src[p.pos] == '.') {
+			p.pos++
+		}
+		return element("mn", "", string(p.src[start:p.pos])), false, nil
+	case unicode.IsLetter(r):
+		p.pos++
+		return p.identifier(string(r)), false, nil
+	case r == '-':
+		p.pos++
+		return element("mo", "", "−"), false, nil
+	case r == '&' || r == '#' || r == '$' || r == '%':
+		return "", false, fmt.Errorf("unexpected %q", string(r))
+	}
+	p.pos++
+	return element("mo", "", string(r)), false, nil
+}
+
+// identifier renders a letter in the current font
+func (p *texParser) identifier(text string) string {
+	if p.variant != "" {
+		return element("mi", ` mathvariant="`+p.variant+`"`, text)
+	}
+	return element("mi", "", text)
+}
+
+// commandName reads the name after a backslash: a run of letters or a single
+// other character
+func (p *texParser) commandName() string {
+	p.pos++ // backslash
+	start := p.pos
+	for p.pos < len(p.src) && unicode.IsLetter(p.src[p.pos]) && p.src[p.pos] < unicode.MaxASCII {
+		p.pos++
+	}
+	if p.pos == start && p.pos < len(p.src) {
+		p.pos++
+	}
+	name := string(p.src[start:p.pos])
+	if name == "*" {
+		return name
+	}
+	// align* and friends
+	if p.peek() == '*' && name != "" && unicode.IsLetter([]rune(name)[0]) {
+		if _, ok := texMatrices[name+"*"]; ok {
+			p.pos++
+			return name + "*"
+		}
+	}
+	return name
+}
+
+func (p *texParser) parseCommand() (string, bool, error) {
+	name := p.commandName()
+	if symbol, ok := texSymbols[name]; ok {
+		return element(symbol.tag, symbol.attrs, symbol.text), symbol.limits, nil
+	}
+	if width, ok := texSpaces[name]; ok {
+		return `<mspace width="` + width + `"></mspace>`, false, nil
+	}
+	if accent, ok := texAccents[name]; ok {
+		arg, err := p.parseArgument()
+		if err != nil {
+			return "", false, err
+		}
+		if name == "underline" {
+			return "<munder>" + arg + `<mo stretchy="true">` + accent + "</mo></munder>", false, nil
+		}
+		return `<mover accent="true">` + arg + `<mo stretchy="true">` + accent + "</mo></mover>", false, nil
+	}
+	if variant, ok := texVariants[name]; ok {
+		saved := p.variant
+		p.variant = variant
+		arg, err := p.parseArgument()
+		p.variant = saved
+		return arg, false, err
+	}
+	if size, ok := texBigSizes[name]; ok {
+		delim, err := p.delimiter()
+		if err != nil {
+			return "", false, err
+		}
+		return `<mo minsize="` + size + `" maxsize="` + size + `">` + html.EscapeString(delim) + "</mo>", false, nil
+	}
+
+	switch name {
+	case "frac", "dfrac", "tfrac", "cfrac":
+		num, err := p.parseArgument()
+		if err != nil {
+			return "", false, err
+		}
+		den, err := p.parseArgument()
+		if err != nil {
+			return "", false, err
+		}
+		return "<mfrac>" + num + den + "</mfrac>", false, nil
+	case "binom":
+		top, err := p.parseArgument()
+		if err != nil {
+			return "", false, err
+		}
+		bottom, err := p.parseArgument()
+		if err != nil {
+			return "", false, err
+		}
+		return `<mrow><mo>(</mo><mfrac linethickness="0">` + top + bottom + `</mfrac><mo>)</mo></mrow>`, false, nil
+	case "sqrt":
+		p.skipSpace()
+		var index string
+		if p.peek() == '[' {
+			p.pos++
+			row, err := p.parseRow(func(r rune) bool { return r == ']' })
+			if err != nil {
+				return "", false, err
+			}
+			if p.peek() != ']' {
+				return "", false, errors.New("missing ] after root index")
+			}
+			p.pos++
+			index = mrow(row)
+		}
+		arg, err := p.parseArgument()
+		if err != nil {
+			return "", false, err
+		}
+		if index != "" {
+			return "<mroot>" + arg + index + "</mroot>", false, nil
+		}
+		return "<msqrt>" + arg + "</msqrt>", false, nil
+	case "text", "textrm", "textit", "textbf", "mbox":
+		text, err := p.rawGroup()
+		if err != nil {
+			return "", false, err
+		}
+		return element("mtext", "", text), false, nil
+	case "operatorname":
+		text, err := p.rawGroup()
+		if err != nil {
+			return "", false, err
+		}
+		return element("mi", "", text), false, nil
+	case "not":
+		p.skipSpace()
+		next, _, err := p.parseAtom()
+		if err != nil {
+			return "", false, err
+		}
+		// Overlay a long solidus on the following symbol
+		if i := strings.Index(next, "</"); i > 0 {
+			return next[:i] + "̸" + next[i:], false, nil
+		}
+		return next, false, nil
+	case "left":
+		return p.parseFenced()
+	case "begin":
+		return p.parseEnvironment()
+	case "displaystyle", "textstyle", "limits", "nolimits":
+		return "", false, nil
+	}
+	return "", false, fmt.Errorf("unsupported command \\%s", name)
+}
+
+// rawGroup reads the text of a {...} argument without parsing it as math
+func (p *texParser) rawGroup() (string, error) {
+	p.skipSpace()
+	if p.peek() != '{' {
+		return "", errTeXArgument
+	}
+	p.pos++
+	start, depth := p.pos, 1
+	for ; p.pos < len(p.src); p.pos++ {
+		switch p.src[p.pos] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		}
+		if depth == 0 {
+			text := string(p.src[start:p.pos])
+			p.pos++
+			return text, nil
+		}
+	}
+	return "", errTeXUnclosed
+}
+
+// delimiter reads the delimiter after \left, \right, \middle or \big
+func (p *texParser) delimiter() (string, error) {
+	p.skipSpace()
+	r := p.peek()
+	switch {
+	case r == 0:
+		return "", errTeXDelimiter
+	case r == '.':
+		p.pos++
+		return "", nil
+	case r == '\\':
+		name := p.commandName()
+		if symbol, ok := texSymbols[name]; ok && symbol.tag == "mo" {
+			return symbol.text, nil
+		}
+		return "", errTeXDelimiter
+	case strings.ContainsRune("()[]|/<>", r):
+		p.pos++
+		switch r {
+		case '<':
+			return "⟨", nil
+		case '>':
+			return "⟩", nil
+		}
+		return string(r), nil
+	}
+	return "", errTeXDelimiter
+}
+
+// parseFenced parses \left( ... \right) into a row with stretchy fences
+func (p *texParser) parseFenced() (string, bool, error) {
+	open, err := p.delimiter()
+	if err != nil {
+		return "", false, err
+	}
+	parts := []string{fence(open)}
+	for {
+		row, err := p.parseRow(func(r rune) bool { return false })
+		if err != nil {
+			return "", false, err
+		}
+		parts = append(parts, row...)
+		switch {
+		case p.atCommand("middle"):
+			p.commandName()
+			middle, err := p.delimiter()
+			if err != nil {
+				return "", false, err
+			}
+			parts = append(parts, fence(middle))
+			continue
+		case p.atCommand("right"):
+			p.commandName()
+			closing, err := p.delimiter()
+			if err != nil {
+				return "", false, err
+			}
+			parts = append(parts, fence(closing))
+			return "<mrow>" + strings.Join(parts, "") + "</mrow>", false, nil
+		}
+		return "", false, errors.New(`\left without \right`)
+	}
+}
+
+// parseEnvironment parses \begin{name} ... \end{name} into a table
+func (p *texParser) parseEnvironment() (string, bool, error) {
+	name, err := p.rawGroup()
+	if err != nil {
+		return "", false, err
+	}
+	fences, ok := texMatrices[name]
+	if !ok {
+		return "", false, fmt.Errorf("unsupported environment %s", name)
+	}
+	if name == "array" {
+		// Column alignment isn't supported; skip the spec
+		if _, err := p.rawGroup(); err != nil {
+			return "", false, err
+		}
+	}
+
+	var rows []string
+	var cells []string
+	for {
+		cell, err := p.parseRow(func(r rune) bool { return r == '&' })
+		if err != nil {
+			return "", false, err
+		}
+		cells = append(cells, "<mtd>"+mrow(cell)+"</mtd>")
+		switch {
+		case p.peek() == '&':
+			p.pos++
+			continue
+		case p.atCommand("\\"):
+			p.pos += 2
+			rows = append(rows, "<mtr>"+strings.Join(cells, "")+"</mtr>")
+			cells = nil
+			continue
+		case p.atCommand("end"):
+			p.commandName()
+			end, err := p.rawGroup()
+			if err != nil {
+				return "", false, err
+			}
+			if end != name {
+				return "", false, fmt.Errorf(`\begin{%s} ended by \end{%s}`, name, end)
+			}
+		default:
+			return "", false, fmt.Errorf(`missing \end{%s}`, name)
+		}
+		break
+	}
+	// A trailing \\ leaves an empty last row
+	if len(cells) > 1 || len(cells) == 1 && cells[0] != "<mtd><mrow></mrow></mtd>" {
+		rows = append(rows, "<mtr>"+strings.Join(cells, "")+"</mtr>")
+	}
+
+	attrs := ""
+	switch name {
+	case "cases":
+		attrs = ` columnalign="left"`
+	case "aligned", "align", "align*":
+		attrs = ` columnalign="right left"`
+	}
+	table := "<mtable" + attrs + ">" + strings.Join(rows, "") + "</mtable>"
+	if fences[0] == "" && fences[1] == "" {
+		return table, false, nil
+	}
+	return "<mrow>" + fence(fences[0]) + table + fence(fences[1]) + "</mrow>", false, nil
+}
+
+// fence renders a stretchy delimiter; "" is an empty placeholder
+func fence(delim string) string {
+	if delim == "" {
+		return ""
+	}
+	return `<mo fence="true" stretchy="true">` + html.EscapeString(delim) + "</mo>"
+}
+
+func element(tag, attrs, text string) string {
+	return "<" + tag + attrs + ">" + html.EscapeString(text) + "</" + tag + ">"
+}
+
+// mrow wraps several elements so they act as one
+func mrow(row []string) string {
+	if len(row) == 1 {
+		return row[0]
+	}
+	return "<mrow>" + strings.Join(row, "") + "</mrow>"
+}
@@ -0,0 +1,292 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+
+	// Register decoders used by image.Decode
+	_ "image/gif"
+
+	xdraw "golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// variantWidths are the resized copies generated for responsive images.
+// Only widths smaller than the original are generated.
+var variantWidths = []int{480, 960, 1600}
+
+const (
+	variantJPEGQuality  = 82
+	reencodeJPEGQuality = 90
+)
+
+// hasVariants reports whether resized copies are generated for a content type.
+// GIFs are left alone so animations keep working.
+func hasVariants(contentType string) bool {
+	switch contentType {
+	case "image/jpeg", "image/png", "image/webp":
+		return true
+	}
+	return false
+}
+
+// variantExt returns the extension variants are encoded with: PNG keeps
+// transparency, everything else becomes JPEG
+func variantExt(contentType string) string {
+	if contentType == "image/png" {
+		return ".png"
+	}
+	return ".jpg"
+}
+
+// StripMetadata removes EXIF and other embedded metadata (camera details, GPS
+// location) from an image. JPEGs rotated by an EXIF orientation tag are
+// re-encoded upright, since dropping the tag would otherwise display them sideways.
+func StripMetadata(contentType string, data []byte) ([]byte, error) {
+	switch contentType {
+	case "image/jpeg":
+		if orientation := jpegOrientation(data); orientation > 1 && orientation <= 8 {
+			img, err := jpeg.Decode(bytes.NewReader(data))
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode image: %w", err)
+			}
+			var buf bytes.Buffer
+			if err := jpeg.Encode(&buf, applyOrientation(img, orientation), &jpeg.Options{Quality: reencodeJPEGQuality}); err != nil {
+				return nil, fmt.Errorf("failed to encode image: %w", err)
+			}
+			return buf.Bytes(), nil
+		}
+		return stripJPEGSegments(data)
+	case "image/png":
+		return stripPNGChunks(data)
+	case "image/webp":
+		return stripWebPChunks(data)
+	}
+	return data, nil
+}
+
+// stripJPEGSegments drops APP1 (EXIF, XMP), APP13 (IPTC) and comment segments,
+// keeping APP0 (JFIF), APP2 (ICC colour profile) and APP14 (Adobe colour transform)
+func stripJPEGSegments(data []byte) ([]byte, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, errors.New("invalid JPEG")
+	}
+
+	out := make([]byte, 0, len(data))
+	out = append(out, 0xFF, 0xD8)
+	i := 2
+	for i+4 <= len(data) {
+		if data[i] != 0xFF {
+			return nil, errors.New("invalid JPEG segment")
+		}
+		marker := data[i+1]
+		// Start of scan: the rest is image data
+		if marker == 0xDA {
+			return append(out, data[i:]...), nil
+		}
+		length := int(binary.BigEndian.Uint16(data[i+2 : i+4]))
+		end := i + 2 + length
+		if length < 2 || end > len(data) {
+			return nil, errors.New("truncated JPEG segment")
+		}
+		switch marker {
+		case 0xE1, 0xED, 0xFE:
+			// metadata, dropped
+		default:
+			out = append(out, data[i:end]...)
+		}
+		i = end
+	}
+	return nil, errors.New("JPEG has no image data")
+}
+
+// jpegOrientation returns the EXIF orientation tag (1-8), or 0 if there is none
+func jpegOrientation(data []byte) int {
+	i := 2
+	for i+4 <= len(data) && data[i] == 0xFF {
+		marker := data[i+1]
+		if marker == 0xDA {
+			return 0
+		}
+		length := int(binary.BigEndian.Uint16(data[i+2 : i+4]))
+		end := i + 2 + length
+		if length < 2 || end > len(data) {
+			return 0
+		}
+		segment := data[i+4 : end]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return exifOrientation(segment[6:])
+		}
+		i = end
+	}
+	return 0
+}
+
+// exifOrientation reads tag 0x0112 from the first IFD of a TIFF structure
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 0
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+
+	ifd := int(order.Uint32(tiff[4:8]))
+	if ifd+2 > len(tiff) {
+		return 0
+	}
+	count := int(order.Uint16(tiff[ifd : ifd+2]))
+	for n := 0; n < count; n++ {
+		entry := ifd + 2 + n*12
+		if entry+12 > len(tiff) {
+			return 0
+		}
+		if order.Uint16(tiff[entry:entry+2]) == 0x0112 {
+			return int(order.Uint16(tiff[entry+8 : entry+10]))
+		}
+	}
+	return 0
+}
+
+// applyOrientation rotates and flips an image so it displays upright without EXIF
+func applyOrientation(src image.Image, orientation int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	// Orientations 5-8 swap width and height
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // flip horizontal
+				dx, dy = w-1-x, y
+			case 3: // rotate 180
+				dx, dy = w-1-x, h-1-y
+			case 4: // flip vertical
+				dx, dy = x, h-1-y
+			case 5: // transpose
+				dx, dy = y, x
+			case 6: // rotate 90 clockwise
+				dx, dy = h-1-y, x
+			case 7: // transverse
+				dx, dy = h-1-y, w-1-x
+			case 8: // rotate 90 counter-clockwise
+				dx, dy = y, w-1-x
+			default:
+				dx, dy = x, y
+			}
+			dst.Set(dx, dy, src.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return dst
+}
+
+// stripPNGChunks drops text, time and EXIF chunks
+func stripPNGChunks(data []byte) ([]byte, error) {
+	const signature = "\x89PNG\r\n\x1a\n"
+	if !bytes.HasPrefix(data, []byte(signature)) {
+		return nil, errors.New("invalid PNG")
+	}
+
+	out := make([]byte, 0, len(data))
+	out = append(out, signature...)
+	i := len(signature)
+	for i+12 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[i : i+4]))
+		end := i + 12 + length
+		if length < 0 || end > len(data) {
+			return nil, errors.New("truncated PNG chunk")
+		}
+		switch string(data[i+4 : i+8]) {
+		case "eXIf", "tEXt", "zTXt", "iTXt", "tIME":
+			// metadata, dropped
+		default:
+			out = append(out, data[i:end]...)
+		}
+		i = end
+	}
+	return out, nil
+}
+
+// stripWebPChunks drops EXIF and XMP chunks and clears their flags in the VP8X header
+func stripWebPChunks(data []byte) ([]byte, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, errors.New("invalid WebP")
+	}
+
+	out := make([]byte, 12, len(data))
+	copy(out, data[:12])
+	i := 12
+	for i+8 <= len(data) {
+		fourcc := string(data[i : i+4])
+		size := int(binary.LittleEndian.Uint32(data[i+4 : i+8]))
+		end := i + 8 + size + size%2
+		if end > len(data) {
+			return nil, errors.New("truncated WebP chunk")
+		}
+		switch fourcc {
+		case "EXIF", "XMP ":
+			// metadata, dropped
+		case "VP8X":
+			chunk := append([]byte(nil), data[i:end]...)
+			if len(chunk) > 8 {
+				chunk[8] &^= 0x08 | 0x04 // EXIF and XMP present flags
+			}
+			out = append(out, chunk...)
+		default:
+			out = append(out, data[i:end]...)
+		}
+		i = end
+	}
+	binary.LittleEndian.PutUint32(out[4:8], uint32(len(out)-8))
+	return out, nil
+}
+
+// resizeToWidth scales an image down to width, keeping its aspect ratio
+func resizeToWidth(src image.Image, width int) image.Image {
+	b := src.Bounds()
+	height := b.Dy() * width / b.Dx()
+	if height < 1 {
+		height = 1
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	xdraw.CatmullRom.Scale(dst, dst.Bounds(), src, b, xdraw.Src, nil)
+	return dst
+}
+
+// encodeVariant encodes a resized image for storage. JPEG has no alpha
+// channel, so transparent areas are flattened onto white.
+func encodeVariant(img image.Image, ext string) ([]byte, error) {
+	var buf bytes.Buffer
+	if ext == ".png" {
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	flat := image.NewRGBA(img.Bounds())
+	draw.Draw(flat, flat.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), img, img.Bounds().Min, draw.Over)
+	if err := jpeg.Encode(&buf, flat, &jpeg.Options{Quality: variantJPEGQuality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
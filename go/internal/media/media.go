@@ -0,0 +1,356 @@
+// Package media validates uploads, stores them through a storage backend and
+// builds the URLs they are served from on the blog's own host.
+package media
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/cassiascheffer/willow_camp/internal/helpers"
+	"github.com/cassiascheffer/willow_camp/internal/jobs"
+	"github.com/cassiascheffer/willow_camp/internal/models"
+	"github.com/cassiascheffer/willow_camp/internal/repository"
+	"github.com/cassiascheffer/willow_camp/internal/storage"
+	"github.com/google/uuid"
+)
+
+// DefaultMaxBytes is the upload size limit when UPLOAD_MAX_BYTES is unset
+const DefaultMaxBytes = 10 << 20
+
+// MaxPixels caps an image's width times height. Decoding takes about four
+// bytes a pixel, so a small, highly compressible file with huge dimensions
+// would otherwise exhaust memory.
+const MaxPixels = 40_000_000
+
+var (
+	ErrTooLarge        = errors.New("file is too large")
+	ErrUnsupportedType = errors.New("file type is not supported")
+	ErrEmpty           = errors.New("file is empty")
+)
+
+// allowedTypes maps sniffed content types to the extension files are stored with.
+// SVG is deliberately missing: it can carry scripts and is served from the blog's origin.
+var allowedTypes = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"application/pdf": ".pdf",
+}
+
+// JobGenerateVariants is the job kind that resizes an uploaded image
+const JobGenerateVariants = "media.generate_variants"
+
+// Service handles uploaded media for blogs
+type Service struct {
+	repos      *repository.Repositories
+	store      storage.Storage
+	queue      *jobs.Queue
+	baseDomain string
+	maxBytes   int64
+}
+
+// New creates a new media Service and registers its jobs on the queue.
+// The size limit comes from UPLOAD_MAX_BYTES.
+func New(repos *repository.Repositories, store storage.Storage, queue *jobs.Queue, baseDomain string) *Service {
+	maxBytes := int64(DefaultMaxBytes)
+	if n, err := strconv.ParseInt(os.Getenv("UPLOAD_MAX_BYTES"), 10, 64); err == nil && n > 0 {
+		maxBytes = n
+	}
+
+	s := &Service{
+		repos:      repos,
+		store:      store,
+		queue:      queue,
+		baseDomain: baseDomain,
+		maxBytes:   maxBytes,
+	}
+
+	queue.Register(JobGenerateVariants, s.runGenerateVariants)
+
+	return s
+}
+
+// MaxBytes returns the largest accepted upload
+func (s *Service) MaxBytes() int64 {
+	return s.maxBytes
+}
+
+// DetectType sniffs the content type from the first bytes of a file and returns
+// it with the extension to store it under. The client-supplied type is never trusted.
+func DetectType(head []byte) (contentType, ext string, err error) {
+	contentType = http.DetectContentType(head)
+	if i := strings.Index(contentType, ";"); i >= 0 {
+		contentType = contentType[:i]
+	}
+	ext, ok := allowedTypes[contentType]
+	if !ok {
+		return "", "", ErrUnsupportedType
+	}
+	return contentType, ext, nil
+}
+
+// Upload validates and stores a file for a blog
+func (s *Service) Upload(ctx context.Context, blog *models.Blog, userID *uuid.UUID, filename string, r io.Reader) (*models.Media, error) {
+	// Read one byte past the limit so oversize files are detected without trusting Content-Length
+	data, err := io.ReadAll(io.LimitReader(r, s.maxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read upload: %w", err)
+	}
+	if len(data) == 0 {
+		return nil, ErrEmpty
+	}
+	if int64(len(data)) > s.maxBytes {
+		return nil, ErrTooLarge
+	}
+
+	contentType, ext, err := DetectType(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var width, height *int
+	if strings.HasPrefix(contentType, "image/") {
+		// Checked before StripMetadata, which decodes rotated JPEGs
+		if err := checkDimensions(data); err != nil {
+			return nil, err
+		}
+		if data, err = StripMetadata(contentType, data); err != nil {
+			return nil, ErrUnsupportedType
+		}
+		config, _, err := image.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			return nil, ErrUnsupportedType
+		}
+		width, height = &config.Width, &config.Height
+	}
+
+	token, err := randomKey()
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+
+	m := &models.Media{
+		BlogID:      blog.ID,
+		UserID:      userID,
+		Key:         token + ext,
+		Filename:    cleanFilename(filename, ext),
+		ContentType: contentType,
+		ByteSize:    int64(len(data)),
+		Checksum:    hex.EncodeToString(sum[:]),
+		Width:       width,
+		Height:      height,
+	}
+
+	if err := s.store.Put(ctx, StorageKey(m), bytes.NewReader(data), m.ByteSize, contentType); err != nil {
+		return nil, err
+	}
+	if err := s.repos.Media.Create(ctx, m); err != nil {
+		// Don't leave orphaned files behind
+		_ = s.store.Delete(ctx, StorageKey(m))
+		return nil, err
+	}
+
+	if hasVariants(m.ContentType) {
+		// The original is usable without variants, so a failed enqueue isn't fatal
+		_, _ = s.queue.Enqueue(ctx, JobGenerateVariants, variantsPayload{MediaID: m.ID}, jobs.ForBlog(blog.ID))
+	}
+
+	return m, nil
+}
+
+// checkDimensions reads an image's dimensions from its header and rejects
+// images over MaxPixels before anything decodes them
+func checkDimensions(data []byte) error {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return ErrUnsupportedType
+	}
+	if int64(config.Width)*int64(config.Height) > MaxPixels {
+		return ErrTooLarge
+	}
+	return nil
+}
+
+type variantsPayload struct {
+	MediaID uuid.UUID `json:"media_id"`
+}
+
+// runGenerateVariants stores resized copies of an image next to the original
+func (s *Service) runGenerateVariants(ctx context.Context, job *models.Job) error {
+	var payload variantsPayload
+	if err := job.DecodePayload(&payload); err != nil {
+		return jobs.Permanent(err)
+	}
+
+	m, err := s.repos.Media.FindByID(ctx, payload.MediaID)
+	if errors.Is(err, repository.ErrMediaNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	r, err := s.store.Open(ctx, StorageKey(m))
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(r)
+	r.Close()
+	if err != nil {
+		return err
+	}
+	// Files stored before the pixel cap was checked on upload may exceed it
+	if err := checkDimensions(data); err != nil {
+		return jobs.Permanent(err)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return jobs.Permanent(fmt.Errorf("failed to decode image: %w", err))
+	}
+
+	ext := variantExt(m.ContentType)
+	contentType := "image/jpeg"
+	if ext == ".png" {
+		contentType = "image/png"
+	}
+
+	widths := []int{}
+	for _, width := range variantWidths {
+		if width >= img.Bounds().Dx() {
+			break
+		}
+		data, err := encodeVariant(resizeToWidth(img, width), ext)
+		if err != nil {
+			return jobs.Permanent(fmt.Errorf("failed to encode variant: %w", err))
+		}
+		if err := s.store.Put(ctx, VariantStorageKey(m, width), bytes.NewReader(data), int64(len(data)), contentType); err != nil {
+			return err
+		}
+		widths = append(widths, width)
+	}
+
+	return s.repos.Media.SetVariants(ctx, m.ID, widths)
+}
+
+// Open streams a stored file
+func (s *Service) Open(ctx context.Context, m *models.Media) (io.ReadCloser, error) {
+	return s.store.Open(ctx, StorageKey(m))
+}
+
+// OpenVariant streams a resized copy of an image
+func (s *Service) OpenVariant(ctx context.Context, m *models.Media, width int) (io.ReadCloser, error) {
+	if !HasVariant(m, width) {
+		return nil, storage.ErrNotFound
+	}
+	return s.store.Open(ctx, VariantStorageKey(m, width))
+}
+
+// Delete removes a file and its variants from storage and the database
+func (s *Service) Delete(ctx context.Context, m *models.Media) error {
+	for _, width := range m.VariantWidths {
+		if err := s.store.Delete(ctx, VariantStorageKey(m, width)); err != nil {
+			return err
+		}
+	}
+	if err := s.store.Delete(ctx, StorageKey(m)); err != nil {
+		return err
+	}
+	return s.repos.Media.Delete(ctx, m.ID)
+}
+
+// URL returns the public URL of a file on the blog's host
+func (s *Service) URL(blog *models.Blog, m *models.Media) string {
+	return helpers.BlogBaseURL(blog, s.baseDomain) + Path(m)
+}
+
+// Markdown returns a snippet that embeds the file in a post
+func (s *Service) Markdown(blog *models.Blog, m *models.Media) string {
+	label := strings.NewReplacer("[", "", "]", "", "\n", " ").Replace(m.Filename)
+	if m.IsImage() {
+		label = strings.TrimSuffix(label, path.Ext(label))
+		return "![" + label + "](" + s.URL(blog, m) + ")"
+	}
+	return "[" + label + "](" + s.URL(blog, m) + ")"
+}
+
+// Path returns the path a file is served from on the blog's host
+func Path(m *models.Media) string {
+	return "/media/" + m.Key
+}
+
+// VariantPath returns the path a resized copy is served from, e.g. /media/<key>/480w.jpg
+func VariantPath(m *models.Media, width int) string {
+	return Path(m) + "/" + VariantName(m, width)
+}
+
+// VariantName returns the last path segment of a variant URL
+func VariantName(m *models.Media, width int) string {
+	return strconv.Itoa(width) + "w" + variantExt(m.ContentType)
+}
+
+// VariantContentType returns the content type variants of m are encoded as
+func VariantContentType(m *models.Media) string {
+	if variantExt(m.ContentType) == ".png" {
+		return "image/png"
+	}
+	return "image/jpeg"
+}
+
+// HasVariant reports whether a resized copy of the given width exists
+func HasVariant(m *models.Media, width int) bool {
+	for _, w := range m.VariantWidths {
+		if w == width {
+			return true
+		}
+	}
+	return false
+}
+
+// StorageKey returns where a file lives in the storage backend
+func StorageKey(m *models.Media) string {
+	return "blogs/" + m.BlogID.String() + "/" + m.Key
+}
+
+// VariantStorageKey returns where a resized copy lives in the storage backend
+func VariantStorageKey(m *models.Media, width int) string {
+	return "blogs/" + m.BlogID.String() + "/variants/" + m.Key + "/" + VariantName(m, width)
+}
+
+// randomKey returns an unguessable 32-character hex key
+func randomKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate media key: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// cleanFilename keeps the base of the original filename for display, falling back to a generic name
+func cleanFilename(filename, ext string) string {
+	name := strings.TrimSpace(path.Base(strings.ReplaceAll(filename, "\\", "/")))
+	if name == "" || name == "." || name == "/" || !utf8.ValidString(name) {
+		return "upload" + ext
+	}
+	if len(name) > 255 {
+		name = name[:255]
+		for !utf8.ValidString(name) {
+			name = name[:len(name)-1]
+		}
+	}
+	return name
+}
@@ -0,0 +1,75 @@
+package media
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/cassiascheffer/willow_camp/internal/markdown"
+	"github.com/cassiascheffer/willow_camp/internal/models"
+)
+
+// mediaRefPattern finds references to uploaded files in markdown source
+var mediaRefPattern = regexp.MustCompile(`/media/([0-9a-f]{32}\.[a-z]+)`)
+
+// ImageResolver loads the blog's media referenced in markdown source with a single
+// query and returns a resolver for markdown.RenderWithImages. Images on other
+// hosts, or that aren't this blog's uploads, are left alone.
+func (s *Service) ImageResolver(ctx context.Context, blog *models.Blog, source string) (markdown.ImageResolver, error) {
+	matches := mediaRefPattern.FindAllStringSubmatch(source, -1)
+	if len(matches) == 0 {
+		return nil, nil
+	}
+
+	seen := map[string]bool{}
+	keys := []string{}
+	for _, match := range matches {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			keys = append(keys, match[1])
+		}
+	}
+
+	found, err := s.repos.Media.FindByKeys(ctx, blog.ID, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	hosts := map[string]bool{}
+	if blog.Subdomain != nil && *blog.Subdomain != "" {
+		hosts[*blog.Subdomain+"."+s.baseDomain] = true
+	}
+	if blog.CustomDomain != nil && *blog.CustomDomain != "" {
+		hosts[*blog.CustomDomain] = true
+	}
+
+	return func(src string) (markdown.ImageInfo, bool) {
+		u, err := url.Parse(src)
+		if err != nil || (u.Host != "" && !hosts[u.Host]) || u.RawQuery != "" || u.Fragment != "" {
+			return markdown.ImageInfo{}, false
+		}
+		key, ok := strings.CutPrefix(u.Path, "/media/")
+		if !ok {
+			return markdown.ImageInfo{}, false
+		}
+		m := found[key]
+		if m == nil || !m.IsImage() || m.Width == nil || m.Height == nil {
+			return markdown.ImageInfo{}, false
+		}
+
+		info := markdown.ImageInfo{Width: *m.Width, Height: *m.Height}
+		if len(m.VariantWidths) > 0 {
+			// Keep variant URLs in the same form (relative or absolute) as the original
+			prefix := strings.TrimSuffix(src, u.Path)
+			candidates := make([]string, 0, len(m.VariantWidths)+1)
+			for _, width := range m.VariantWidths {
+				candidates = append(candidates, prefix+VariantPath(m, width)+" "+strconv.Itoa(width)+"w")
+			}
+			candidates = append(candidates, src+" "+strconv.Itoa(*m.Width)+"w")
+			info.Srcset = strings.Join(candidates, ", ")
+		}
+		return info, true
+	}, nil
+}
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"encoding/json"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -46,10 +48,48 @@ type Blog struct {
 	PostFooterMarkdown  *string    `db:"post_footer_markdown" json:"post_footer_markdown"`
 	NoIndex             bool       `db:"no_index" json:"no_index"`
 	Primary             bool       `db:"primary" json:"primary"`
+	ThemeOverrides      ThemeOverrides `db:"theme_overrides" json:"theme_overrides"`
+	CustomCSS           *string    `db:"custom_css" json:"custom_css"`
+	ThemePack           string     `db:"theme_pack" json:"theme_pack"`
+	MarkdownOptions     MarkdownOptions `db:"markdown_options" json:"markdown_options"`
+	HighlightStyle      string     `db:"highlight_style" json:"highlight_style"`
+	CommentsEnabled     bool       `db:"comments_enabled" json:"comments_enabled"`
 	CreatedAt           time.Time  `db:"created_at" json:"created_at"`
 	UpdatedAt           time.Time  `db:"updated_at" json:"updated_at"`
 }
 
+// ThemeOverrides customizes a blog's DaisyUI theme. Empty fields keep the
+// theme's own values.
+type ThemeOverrides struct {
+	PrimaryColor string `json:"primary_color,omitempty"`
+	AccentColor  string `json:"accent_color,omitempty"`
+	BodyFont     string `json:"body_font,omitempty"`
+	HeadingFont  string `json:"heading_font,omitempty"`
+	ContentWidth string `json:"content_width,omitempty"`
+}
+
+// IsZero returns true if nothing is overridden
+func (o ThemeOverrides) IsZero() bool {
+	return o == ThemeOverrides{}
+}
+
+// MarkdownOptions turns on markdown extensions for a blog. They are off by
+// default so enabling one never changes how existing posts render unasked.
+type MarkdownOptions struct {
+	// Footnotes renders [^1] references and their definitions
+	Footnotes bool `json:"footnotes,omitempty"`
+	// DefinitionLists renders "Term\n: Definition" as <dl>
+	DefinitionLists bool `json:"definition_lists,omitempty"`
+	// Typographer turns quotes, dashes and ellipses into their typographic forms
+	Typographer bool `json:"typographer,omitempty"`
+	// Admonitions renders > [!NOTE] style blockquotes as callouts
+	Admonitions bool `json:"admonitions,omitempty"`
+	// Math renders $inline$ and $$display$$ LaTeX as MathML
+	Math bool `json:"math,omitempty"`
+	// RawHTML renders HTML written in posts, sanitized, instead of omitting it
+	RawHTML bool `json:"raw_html,omitempty"`
+}
+
 // Post represents a blog post or page (Single Table Inheritance)
 type Post struct {
 	ID                 uuid.UUID  `db:"id" json:"id"`
@@ -64,6 +104,8 @@ type Post struct {
 	Type               *string    `db:"type" json:"type"`
 	HasMermaidDiagrams bool       `db:"has_mermaid_diagrams" json:"has_mermaid_diagrams"`
 	Featured           bool       `db:"featured" json:"featured"`
+	Visibility         string     `db:"visibility" json:"visibility"`
+	PasswordDigest     *string    `db:"password_digest" json:"-"`
 	CreatedAt          time.Time  `db:"created_at" json:"created_at"`
 	UpdatedAt          time.Time  `db:"updated_at" json:"updated_at"`
 
@@ -71,6 +113,10 @@ type Post struct {
 	Author *User  `json:"author,omitempty"`
 	Blog   *Blog  `json:"blog,omitempty"`
 	Tags   []Tag  `json:"tags,omitempty"`
+
+	// Not from database - counted from BodyMarkdown by the handlers
+	WordCount   int `json:"word_count,omitempty"`
+	ReadingTime int `json:"reading_time,omitempty"` // minutes
 }
 
 // IsPage returns true if this is a Page (vs a Post)
@@ -83,6 +129,36 @@ func (p *Post) IsPublished() bool {
 	return p.Published != nil && *p.Published
 }
 
+// Post visibilities. Public posts are listed everywhere. Unlisted posts are
+// left out of the index, feeds and sitemap but open to anyone with the link.
+// Password and members posts are listed but only readable after entering
+// the post's password, or signing in as one of the blog's email subscribers.
+const (
+	VisibilityPublic   = "public"
+	VisibilityUnlisted = "unlisted"
+	VisibilityPassword = "password"
+	VisibilityMembers  = "members"
+)
+
+// Visibilities lists the post visibilities in the order they are offered
+var Visibilities = []string{VisibilityPublic, VisibilityUnlisted, VisibilityPassword, VisibilityMembers}
+
+// IsPublic returns true if the post is published for everyone to find
+func (p *Post) IsPublic() bool {
+	return p.IsPublished() && (p.Visibility == "" || p.Visibility == VisibilityPublic)
+}
+
+// IsEmailed returns true if the post goes out to the blog's email
+// subscribers. They are its members, so members posts are emailed too.
+func (p *Post) IsEmailed() bool {
+	return p.IsPublic() || (p.IsPublished() && p.Visibility == VisibilityMembers)
+}
+
+// IsProtected returns true if reading the post needs a password or membership
+func (p *Post) IsProtected() bool {
+	return p.Visibility == VisibilityPassword || p.Visibility == VisibilityMembers
+}
+
 // Tag represents a tag for categorizing posts
 type Tag struct {
 	ID            uuid.UUID `db:"id" json:"id"`
@@ -128,3 +204,322 @@ type UserToken struct {
 	CreatedAt time.Time  `db:"created_at" json:"created_at"`
 	UpdatedAt time.Time  `db:"updated_at" json:"updated_at"`
 }
+
+// EmailSubscriber represents a reader who subscribed to a blog by email
+type EmailSubscriber struct {
+	ID                 uuid.UUID  `db:"id" json:"id"`
+	BlogID             uuid.UUID  `db:"blog_id" json:"blog_id"`
+	Email              string     `db:"email" json:"email"`
+	ConfirmationToken  string     `db:"confirmation_token" json:"-"`
+	UnsubscribeToken   string     `db:"unsubscribe_token" json:"-"`
+	ConfirmationSentAt *time.Time `db:"confirmation_sent_at" json:"confirmation_sent_at"`
+	ConfirmedAt        *time.Time `db:"confirmed_at" json:"confirmed_at"`
+	UnsubscribedAt     *time.Time `db:"unsubscribed_at" json:"unsubscribed_at"`
+	CreatedAt          time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt          time.Time  `db:"updated_at" json:"updated_at"`
+}
+
+// IsConfirmed returns true if the subscriber completed double opt-in
+func (s *EmailSubscriber) IsConfirmed() bool {
+	return s.ConfirmedAt != nil
+}
+
+// IsActive returns true if the subscriber should receive emails
+func (s *EmailSubscriber) IsActive() bool {
+	return s.ConfirmedAt != nil && s.UnsubscribedAt == nil
+}
+
+// Status returns a short human-readable subscription state
+func (s *EmailSubscriber) Status() string {
+	switch {
+	case s.UnsubscribedAt != nil:
+		return "unsubscribed"
+	case s.ConfirmedAt != nil:
+		return "confirmed"
+	default:
+		return "pending"
+	}
+}
+
+// Job statuses
+const (
+	JobStatusPending = "pending"
+	JobStatusRunning = "running"
+	JobStatusDone    = "done"
+	JobStatusDead    = "dead" // exhausted retries, kept for inspection
+)
+
+// Job represents a unit of background work in the Postgres-backed queue
+type Job struct {
+	ID          uuid.UUID       `db:"id" json:"id"`
+	Queue       string          `db:"queue" json:"queue"`
+	Kind        string          `db:"kind" json:"kind"`
+	Payload     json.RawMessage `db:"payload" json:"payload"`
+	Status      string          `db:"status" json:"status"`
+	Attempts    int             `db:"attempts" json:"attempts"`
+	MaxAttempts int             `db:"max_attempts" json:"max_attempts"`
+	RunAt       time.Time       `db:"run_at" json:"run_at"`
+	LockedAt    *time.Time      `db:"locked_at" json:"locked_at"`
+	LockedBy    *string         `db:"locked_by" json:"locked_by"`
+	LastError   *string         `db:"last_error" json:"last_error"`
+	FinishedAt  *time.Time      `db:"finished_at" json:"finished_at"`
+	BlogID      *uuid.UUID      `db:"blog_id" json:"blog_id"`
+	CreatedAt   time.Time       `db:"created_at" json:"created_at"`
+	UpdatedAt   time.Time       `db:"updated_at" json:"updated_at"`
+}
+
+// DecodePayload unmarshals the job payload into v
+func (j *Job) DecodePayload(v interface{}) error {
+	return json.Unmarshal(j.Payload, v)
+}
+
+// Media represents an uploaded file stored outside the database
+type Media struct {
+	ID          uuid.UUID  `db:"id" json:"id"`
+	BlogID      uuid.UUID  `db:"blog_id" json:"blog_id"`
+	UserID      *uuid.UUID `db:"user_id" json:"user_id"`
+	Key         string     `db:"key" json:"key"`
+	Filename    string     `db:"filename" json:"filename"`
+	ContentType string     `db:"content_type" json:"content_type"`
+	ByteSize    int64      `db:"byte_size" json:"byte_size"`
+	Checksum    string     `db:"checksum" json:"checksum"`
+	// Width and Height are set for images
+	Width  *int `db:"width" json:"width"`
+	Height *int `db:"height" json:"height"`
+	// VariantWidths lists the resized copies generated for responsive images
+	VariantWidths []int     `db:"variant_widths" json:"variant_widths"`
+	CreatedAt     time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt     time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// IsImage returns true if the media is an image
+func (m *Media) IsImage() bool {
+	return strings.HasPrefix(m.ContentType, "image/")
+}
+
+// Embed is a provider's cached oEmbed response for a URL linked in a post
+type Embed struct {
+	ID       uuid.UUID `db:"id" json:"id"`
+	URL      string    `db:"url" json:"url"`
+	Provider string    `db:"provider" json:"provider"`
+	Title    string    `db:"title" json:"title"`
+	// HTML is the sanitized embed, or empty to show a link card
+	HTML      string    `db:"html" json:"html"`
+	FetchedAt time.Time `db:"fetched_at" json:"fetched_at"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// LinkPreview is the Open Graph metadata fetched for a URL linked in a post
+type LinkPreview struct {
+	ID          uuid.UUID `db:"id" json:"id"`
+	URL         string    `db:"url" json:"url"`
+	Title       string    `db:"title" json:"title"`
+	Description string    `db:"description" json:"description"`
+	ImageURL    string    `db:"image_url" json:"image_url"`
+	SiteName    string    `db:"site_name" json:"site_name"`
+	FetchedAt   time.Time `db:"fetched_at" json:"fetched_at"`
+	CreatedAt   time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt   time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// Webmention statuses. Received mentions are verified in the background,
+// then wait for the blog owner to approve them before they are shown.
+const (
+	WebmentionUnverified = "unverified"
+	WebmentionPending    = "pending"
+	WebmentionApproved   = "approved"
+	WebmentionRejected   = "rejected"
+)
+
+// Webmention is a page elsewhere that links to one of a blog's posts
+type Webmention struct {
+	ID     uuid.UUID `db:"id" json:"id"`
+	BlogID uuid.UUID `db:"blog_id" json:"blog_id"`
+	PostID uuid.UUID `db:"post_id" json:"post_id"`
+	Source string    `db:"source" json:"source"`
+	Target string    `db:"target" json:"target"`
+	Status string    `db:"status" json:"status"`
+	// MentionType is "reply", "like", "repost", "bookmark" or "mention"
+	MentionType string `db:"mention_type" json:"mention_type"`
+	// Author and content come from the source's h-entry microformats
+	AuthorName  string     `db:"author_name" json:"author_name"`
+	AuthorURL   string     `db:"author_url" json:"author_url"`
+	AuthorPhoto string     `db:"author_photo" json:"author_photo"`
+	Content     string     `db:"content" json:"content"`
+	PublishedAt *time.Time `db:"published_at" json:"published_at"`
+	VerifiedAt  *time.Time `db:"verified_at" json:"verified_at"`
+	CreatedAt   time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt   time.Time  `db:"updated_at" json:"updated_at"`
+}
+
+// Verb describes what the author did with the post, e.g. "replied"
+func (w *Webmention) Verb() string {
+	switch w.MentionType {
+	case "reply":
+		return "replied"
+	case "like":
+		return "liked this"
+	case "repost":
+		return "reposted this"
+	case "bookmark":
+		return "bookmarked this"
+	default:
+		return "mentioned this"
+	}
+}
+
+// ActorKey is the RSA key pair a blog signs its ActivityPub requests with
+type ActorKey struct {
+	ID            uuid.UUID `db:"id" json:"id"`
+	BlogID        uuid.UUID `db:"blog_id" json:"blog_id"`
+	PublicKeyPEM  string    `db:"public_key_pem" json:"public_key_pem"`
+	PrivateKeyPEM string    `db:"private_key_pem" json:"-"`
+	CreatedAt     time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt     time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// Follower is a fediverse account following a blog through ActivityPub
+type Follower struct {
+	ID      uuid.UUID `db:"id" json:"id"`
+	BlogID  uuid.UUID `db:"blog_id" json:"blog_id"`
+	ActorID string    `db:"actor_id" json:"actor_id"`
+	Inbox   string    `db:"inbox" json:"inbox"`
+	// SharedInbox is the follower's server-wide inbox, if it has one
+	SharedInbox string    `db:"shared_inbox" json:"shared_inbox"`
+	CreatedAt   time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt   time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// DeliveryInbox returns the inbox posts are delivered to, preferring the
+// shared one so each server gets a post once
+func (f *Follower) DeliveryInbox() string {
+	if f.SharedInbox != "" {
+		return f.SharedInbox
+	}
+	return f.Inbox
+}
+
+// Comment statuses. New comments wait for the blog owner to approve them
+// before they are shown.
+const (
+	CommentPending  = "pending"
+	CommentApproved = "approved"
+)
+
+// Comment is a reader's comment on a post
+type Comment struct {
+	ID          uuid.UUID `db:"id" json:"id"`
+	BlogID      uuid.UUID `db:"blog_id" json:"blog_id"`
+	PostID      uuid.UUID `db:"post_id" json:"post_id"`
+	AuthorName  string    `db:"author_name" json:"author_name"`
+	AuthorEmail string    `db:"author_email" json:"-"`
+	AuthorURL   string    `db:"author_url" json:"author_url"`
+	// Body is the markdown the reader wrote
+	Body   string `db:"body" json:"body"`
+	Status string `db:"status" json:"status"`
+	// IPHash identifies the commenter for rate limits and bans without
+	// storing their address
+	IPHash    string    `db:"ip_hash" json:"-"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+
+	// Post the comment is on, loaded for the moderation queue
+	PostTitle string `db:"-" json:"-"`
+	PostSlug  string `db:"-" json:"-"`
+}
+
+// IsApproved reports whether the comment is shown under its post
+func (c *Comment) IsApproved() bool {
+	return c.Status == CommentApproved
+}
+
+// PreviewLink is an expiring link letting reviewers without an account read
+// a post, usually a draft, on the blog's own host
+type PreviewLink struct {
+	ID     uuid.UUID `db:"id" json:"id"`
+	BlogID uuid.UUID `db:"blog_id" json:"blog_id"`
+	PostID uuid.UUID `db:"post_id" json:"post_id"`
+	// Token is the signed token in the link's URL
+	Token     string    `db:"token" json:"-"`
+	ExpiresAt time.Time `db:"expires_at" json:"expires_at"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// IsExpired reports whether the link no longer works
+func (l *PreviewLink) IsExpired() bool {
+	return !time.Now().Before(l.ExpiresAt)
+}
+
+// Series is an ordered run of posts on a blog, like a multi-part tutorial
+type Series struct {
+	ID        uuid.UUID `db:"id" json:"id"`
+	BlogID    uuid.UUID `db:"blog_id" json:"blog_id"`
+	Title     string    `db:"title" json:"title"`
+	Slug      string    `db:"slug" json:"slug"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+
+	// Position of the post the series was loaded for
+	Position int `db:"-" json:"-"`
+}
+
+// SeriesPart places a post in its series, for the "Part N of M" navigation
+// on post pages
+type SeriesPart struct {
+	Series   *Series
+	Part     int
+	Total    int
+	Previous *Post
+	Next     *Post
+}
+
+// SeriesPartFor finds a post among its series' parts, which are in order.
+// It returns nil when the post isn't one of them.
+func SeriesPartFor(series *Series, parts []*Post, postID uuid.UUID) *SeriesPart {
+	for i, post := range parts {
+		if post.ID != postID {
+			continue
+		}
+		part := &SeriesPart{Series: series, Part: i + 1, Total: len(parts)}
+		if i > 0 {
+			part.Previous = parts[i-1]
+		}
+		if i < len(parts)-1 {
+			part.Next = parts[i+1]
+		}
+		return part
+	}
+	return nil
+}
+
+// ArchiveMonth is a month of a blog's date archive and how many posts were
+// published in it
+type ArchiveMonth struct {
+	Year  int
+	Month time.Month
+	Count int
+}
+
+// ArchiveYear is a year of a blog's date archive with its months, newest
+// first
+type ArchiveYear struct {
+	Year   int
+	Count  int
+	Months []ArchiveMonth
+}
+
+// GroupArchiveMonths groups archive months, newest first, into their years
+func GroupArchiveMonths(months []ArchiveMonth) []ArchiveYear {
+	var years []ArchiveYear
+	for _, month := range months {
+		if len(years) == 0 || years[len(years)-1].Year != month.Year {
+			years = append(years, ArchiveYear{Year: month.Year})
+		}
+		year := &years[len(years)-1]
+		year.Count += month.Count
+		year.Months = append(year.Months, month)
+	}
+	return years
+}
@@ -0,0 +1,532 @@
+package newsletter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/cassiascheffer/willow_camp/internal/access"
+	"github.com/cassiascheffer/willow_camp/internal/diagrams"
+	"github.com/cassiascheffer/willow_camp/internal/embeds"
+	"github.com/cassiascheffer/willow_camp/internal/helpers"
+	"github.com/cassiascheffer/willow_camp/internal/jobs"
+	"github.com/cassiascheffer/willow_camp/internal/logging"
+	"github.com/cassiascheffer/willow_camp/internal/mailer"
+	"github.com/cassiascheffer/willow_camp/internal/markdown"
+	"github.com/cassiascheffer/willow_camp/internal/models"
+	"github.com/cassiascheffer/willow_camp/internal/previews"
+	"github.com/cassiascheffer/willow_camp/internal/repository"
+	"github.com/cassiascheffer/willow_camp/internal/views"
+	"github.com/google/uuid"
+)
+
+// Job kinds handled by the newsletter
+const (
+	JobSendConfirmation = "newsletter.send_confirmation"
+	JobSendPost         = "newsletter.send_post"
+	JobDeliverPost      = "newsletter.deliver_post"
+	JobSendMemberLink   = "newsletter.send_member_link"
+)
+
+// A confirmation email is sent to an address at most once per
+// ConfirmationCooldown, and its link works for ConfirmationTTL
+const (
+	ConfirmationCooldown = time.Hour
+	ConfirmationTTL      = 7 * 24 * time.Hour
+)
+
+// Each address may ask to subscribe to a blog SubscribeLimit times per SubscribeWindow
+const (
+	SubscribeLimit  = 5
+	SubscribeWindow = time.Hour
+)
+
+var (
+	ErrInvalidEmail = errors.New("invalid email address")
+	// ErrRateLimited is returned when an address has asked to subscribe too often
+	ErrRateLimited = errors.New("too many subscribe requests")
+	// ErrConfirmationExpired is returned for confirmation links older than
+	// ConfirmationTTL
+	ErrConfirmationExpired = errors.New("confirmation link expired")
+)
+
+// Service handles double opt-in email subscriptions and post delivery
+type Service struct {
+	repos      *repository.Repositories
+	mailer     mailer.Mailer
+	queue      *jobs.Queue
+	baseDomain string
+	from       string
+	views      *views.Registry
+	logger     *logging.Logger
+	diagrams   *diagrams.Service
+	embeds     *embeds.Service
+	previews   *previews.Service
+	access     *access.Service
+}
+
+// New creates a new newsletter Service and registers its jobs on the queue
+func New(repos *repository.Repositories, m mailer.Mailer, queue *jobs.Queue, registry *views.Registry, baseDomain string, logger *logging.Logger) *Service {
+	s := &Service{
+		repos:      repos,
+		mailer:     m,
+		queue:      queue,
+		baseDomain: baseDomain,
+		from:       mailer.DefaultFrom(baseDomain),
+		views:      registry,
+		logger:     logger,
+	}
+
+	queue.Register(JobSendConfirmation, s.runSendConfirmation)
+	queue.Register(JobSendPost, s.runSendPost)
+	queue.Register(JobDeliverPost, s.runDeliverPost)
+	queue.Register(JobSendMemberLink, s.runSendMemberLink)
+
+	return s
+}
+
+// SetDiagrams sets the service holding server-rendered mermaid diagrams, so
+// emails show them as images
+func (s *Service) SetDiagrams(service *diagrams.Service) {
+	s.diagrams = service
+}
+
+// SetEmbeds sets the service holding cached link embeds, so emails link to
+// what posts embed
+func (s *Service) SetEmbeds(service *embeds.Service) {
+	s.embeds = service
+}
+
+// SetPreviews sets the service holding cached link previews, so emails show
+// them as cards
+func (s *Service) SetPreviews(service *previews.Service) {
+	s.previews = service
+}
+
+// SetAccess sets the service signing the links that sign subscribers in as
+// members to read members-only posts
+func (s *Service) SetAccess(service *access.Service) {
+	s.access = service
+}
+
+type confirmationPayload struct {
+	SubscriberID uuid.UUID `json:"subscriber_id"`
+}
+
+type postPayload struct {
+	PostID uuid.UUID `json:"post_id"`
+}
+
+type deliveryPayload struct {
+	PostID       uuid.UUID `json:"post_id"`
+	SubscriberID uuid.UUID `json:"subscriber_id"`
+}
+
+type memberLinkPayload struct {
+	SubscriberID uuid.UUID `json:"subscriber_id"`
+	Slug         string    `json:"slug"`
+}
+
+// Subscribe starts double opt-in for an email address by queueing a confirmation email.
+// Subscribers who are already confirmed are left alone and no email is sent,
+// and so are addresses sent one in the last ConfirmationCooldown. Requests
+// are counted per reader's address (see comments.HashIP), so the form can't
+// be used to flood other people's inboxes.
+func (s *Service) Subscribe(ctx context.Context, blog *models.Blog, email, ipHash string) error {
+	addr, err := mail.ParseAddress(strings.TrimSpace(email))
+	if err != nil {
+		return ErrInvalidEmail
+	}
+	email = strings.ToLower(addr.Address)
+
+	since := time.Now().Add(-SubscribeWindow)
+	recent, err := s.repos.SubscriptionRequest.CountRecentByIP(ctx, blog.ID, ipHash, since)
+	if err != nil {
+		return err
+	}
+	if recent >= SubscribeLimit {
+		return ErrRateLimited
+	}
+	if err := s.repos.SubscriptionRequest.Record(ctx, blog.ID, ipHash, since); err != nil {
+		return err
+	}
+
+	subscriber, err := s.repos.Subscriber.FindOrCreatePending(ctx, blog.ID, email)
+	if err != nil {
+		return err
+	}
+	if subscriber.IsActive() {
+		return nil
+	}
+
+	claimed, err := s.repos.Subscriber.ClaimConfirmation(ctx, subscriber.ID, time.Now().Add(-ConfirmationCooldown))
+	if err != nil || !claimed {
+		return err
+	}
+	_, err = s.queue.Enqueue(ctx, JobSendConfirmation, confirmationPayload{SubscriberID: subscriber.ID}, jobs.ForBlog(blog.ID))
+	return err
+}
+
+// runSendConfirmation emails the double opt-in link to a pending subscriber
+func (s *Service) runSendConfirmation(ctx context.Context, job *models.Job) error {
+	var payload confirmationPayload
+	if err := job.DecodePayload(&payload); err != nil {
+		return jobs.Permanent(err)
+	}
+
+	subscriber, err := s.repos.Subscriber.FindByID(ctx, payload.SubscriberID)
+	if errors.Is(err, repository.ErrSubscriberNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if subscriber.IsActive() {
+		return nil
+	}
+
+	blog, err := s.repos.Blog.FindByID(ctx, subscriber.BlogID)
+	if err != nil {
+		return err
+	}
+
+	baseURL := helpers.BlogBaseURL(blog, s.baseDomain)
+	confirmURL := baseURL + "/subscribe/confirm?token=" + subscriber.ConfirmationToken
+	title := blogTitle(blog)
+	days := int(ConfirmationTTL.Hours() / 24)
+
+	html, err := s.renderEmail("confirm.html", map[string]interface{}{
+		"BlogTitle":  title,
+		"BlogURL":    baseURL,
+		"ConfirmURL": confirmURL,
+		"Days":       days,
+	})
+	if err != nil {
+		return err
+	}
+
+	msg := &mailer.Message{
+		From:    s.from,
+		To:      subscriber.Email,
+		Subject: "Confirm your subscription to " + title,
+		TextBody: fmt.Sprintf("Please confirm that you want to receive new posts from %s by email. The link works for %d days:\n\n%s\n\n"+
+			"If you didn't ask for this, you can ignore this email.\n", title, days, confirmURL),
+		HTMLBody: html,
+	}
+	if err := s.mailer.Send(ctx, msg); err != nil {
+		return err
+	}
+
+	return s.repos.Subscriber.MarkConfirmationSent(ctx, subscriber.ID)
+}
+
+// Confirm completes double opt-in for the subscriber holding the token.
+// Links from confirmation emails sent over ConfirmationTTL ago have expired.
+func (s *Service) Confirm(ctx context.Context, blog *models.Blog, token string) (*models.EmailSubscriber, error) {
+	subscriber, err := s.repos.Subscriber.FindByConfirmationToken(ctx, blog.ID, token)
+	if err != nil {
+		return nil, err
+	}
+	if subscriber.IsActive() {
+		return subscriber, nil
+	}
+	if subscriber.ConfirmationSentAt == nil || time.Since(*subscriber.ConfirmationSentAt) > ConfirmationTTL {
+		return nil, ErrConfirmationExpired
+	}
+	if err := s.repos.Subscriber.Confirm(ctx, subscriber.ID); err != nil {
+		return nil, err
+	}
+	return subscriber, nil
+}
+
+// Unsubscribe stops emails to the subscriber holding the token
+func (s *Service) Unsubscribe(ctx context.Context, blog *models.Blog, token string) (*models.EmailSubscriber, error) {
+	subscriber, err := s.repos.Subscriber.FindByUnsubscribeToken(ctx, blog.ID, token)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repos.Subscriber.Unsubscribe(ctx, subscriber.ID); err != nil {
+		return nil, err
+	}
+	return subscriber, nil
+}
+
+// RequestMemberLink queues emailing a sign-in link to a blog's subscriber, so
+// they can read the members-only post with the given slug. Addresses that
+// aren't active subscribers get nothing and no error, so the form can't be
+// used to find out who subscribes.
+func (s *Service) RequestMemberLink(ctx context.Context, blog *models.Blog, email, slug string) error {
+	addr, err := mail.ParseAddress(strings.TrimSpace(email))
+	if err != nil {
+		return ErrInvalidEmail
+	}
+
+	subscriber, err := s.repos.Subscriber.FindByEmail(ctx, blog.ID, strings.ToLower(addr.Address))
+	if errors.Is(err, repository.ErrSubscriberNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !subscriber.IsActive() {
+		return nil
+	}
+
+	payload := memberLinkPayload{SubscriberID: subscriber.ID, Slug: slug}
+	_, err = s.queue.Enqueue(ctx, JobSendMemberLink, payload, jobs.ForBlog(blog.ID), jobs.MaxAttempts(3))
+	return err
+}
+
+// runSendMemberLink emails a subscriber a link signing them in as a member
+func (s *Service) runSendMemberLink(ctx context.Context, job *models.Job) error {
+	var payload memberLinkPayload
+	if err := job.DecodePayload(&payload); err != nil {
+		return jobs.Permanent(err)
+	}
+	if s.access == nil {
+		return jobs.Permanent(errors.New("member links are not available"))
+	}
+
+	subscriber, err := s.repos.Subscriber.FindByID(ctx, payload.SubscriberID)
+	if errors.Is(err, repository.ErrSubscriberNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !subscriber.IsActive() {
+		return nil
+	}
+
+	blog, err := s.repos.Blog.FindByID(ctx, subscriber.BlogID)
+	if err != nil {
+		return err
+	}
+
+	// The link is signed when it's sent, so it expires counting from then
+	token, err := s.access.MemberToken(subscriber)
+	if err != nil {
+		return jobs.Permanent(err)
+	}
+	query := url.Values{"token": {token}}
+	if payload.Slug != "" {
+		query.Set("slug", payload.Slug)
+	}
+	baseURL := helpers.BlogBaseURL(blog, s.baseDomain)
+	signInURL := baseURL + "/members/signin/confirm?" + query.Encode()
+	title := blogTitle(blog)
+	minutes := int(access.MemberLinkTTL.Minutes())
+
+	html, err := s.renderEmail("member_link.html", map[string]interface{}{
+		"BlogTitle": title,
+		"BlogURL":   baseURL,
+		"SignInURL": signInURL,
+		"Minutes":   minutes,
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(ctx, &mailer.Message{
+		From:    s.from,
+		To:      subscriber.Email,
+		Subject: "Your sign-in link for " + title,
+		TextBody: fmt.Sprintf("Use this link to sign in and read members-only posts on %s. It works for %d minutes:\n\n%s\n\n"+
+			"If you didn't ask for this, you can ignore this email.\n", title, minutes, signInURL),
+		HTMLBody: html,
+	})
+}
+
+// QueuePost schedules a newly published post to be emailed to subscribers
+func (s *Service) QueuePost(ctx context.Context, blog *models.Blog, post *models.Post) error {
+	_, err := s.queue.Enqueue(ctx, JobSendPost, postPayload{PostID: post.ID}, jobs.ForBlog(blog.ID))
+	return err
+}
+
+// runSendPost fans a published post out into one delivery job per active subscriber,
+// so a failing address is retried on its own without resending to everyone else.
+// Each post is sent at most once, even if it is unpublished and published again.
+func (s *Service) runSendPost(ctx context.Context, job *models.Job) error {
+	var payload postPayload
+	if err := job.DecodePayload(&payload); err != nil {
+		return jobs.Permanent(err)
+	}
+
+	post, err := s.repos.Post.FindByID(ctx, payload.PostID)
+	if err != nil {
+		return err
+	}
+	// Unlisted and password posts are only for readers who have the link
+	if post.IsPage() || !post.IsEmailed() || post.Slug == nil {
+		return nil
+	}
+
+	subscribers, err := s.repos.Subscriber.ListActiveForBlog(ctx, post.BlogID)
+	if err != nil {
+		return err
+	}
+
+	deliveries := make([]*models.Job, 0, len(subscribers))
+	for _, subscriber := range subscribers {
+		payload := deliveryPayload{PostID: post.ID, SubscriberID: subscriber.ID}
+		job, err := s.queue.Build(JobDeliverPost, payload, jobs.ForBlog(post.BlogID))
+		if err != nil {
+			return jobs.Permanent(err)
+		}
+		deliveries = append(deliveries, job)
+	}
+
+	// The claim and the delivery jobs are stored together, so a retry after
+	// a failure here sends to everyone rather than no one
+	claimed, err := s.repos.Subscriber.ClaimPostDelivery(ctx, post.ID, deliveries)
+	if err != nil {
+		return err
+	}
+	if claimed {
+		s.queue.Notify()
+	}
+	return nil
+}
+
+// runDeliverPost emails a published post to a single subscriber
+func (s *Service) runDeliverPost(ctx context.Context, job *models.Job) error {
+	var payload deliveryPayload
+	if err := job.DecodePayload(&payload); err != nil {
+		return jobs.Permanent(err)
+	}
+
+	subscriber, err := s.repos.Subscriber.FindByID(ctx, payload.SubscriberID)
+	if errors.Is(err, repository.ErrSubscriberNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	// Respect unsubscribes that happened while the job was waiting
+	if !subscriber.IsActive() {
+		return nil
+	}
+
+	post, err := s.repos.Post.FindByID(ctx, payload.PostID)
+	if err != nil {
+		return err
+	}
+	blog, err := s.repos.Blog.FindByID(ctx, post.BlogID)
+	if err != nil {
+		return err
+	}
+
+	var resolvers markdown.Resolvers
+	if s.diagrams != nil && post.BodyMarkdown != nil {
+		if resolvers.Diagrams, err = s.diagrams.Resolver(ctx, *post.BodyMarkdown); err != nil {
+			return err
+		}
+	}
+	if s.embeds != nil && post.BodyMarkdown != nil {
+		if resolvers.Embeds, err = s.embeds.Resolver(ctx, *post.BodyMarkdown); err != nil {
+			return err
+		}
+	}
+	if s.previews != nil && post.BodyMarkdown != nil {
+		if resolvers.Previews, err = s.previews.Resolver(ctx, *post.BodyMarkdown); err != nil {
+			return err
+		}
+	}
+
+	msg, err := s.postMessage(blog, post, subscriber, resolvers)
+	if err != nil {
+		return jobs.Permanent(err)
+	}
+	return s.mailer.Send(ctx, msg)
+}
+
+// postMessage builds the email for a post addressed to one subscriber.
+// Diagrams the resolvers know are linked as images, and embeds as links.
+func (s *Service) postMessage(blog *models.Blog, post *models.Post, subscriber *models.EmailSubscriber, resolvers markdown.Resolvers) (*mailer.Message, error) {
+	if post.Slug == nil {
+		return nil, fmt.Errorf("post has no slug")
+	}
+
+	baseURL := helpers.BlogBaseURL(blog, s.baseDomain)
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid blog URL: %w", err)
+	}
+	postURL := baseURL + "/" + *post.Slug
+	title := blogTitle(blog)
+	postTitle := "New post"
+	if post.Title != nil && *post.Title != "" {
+		postTitle = *post.Title
+	}
+
+	var bodyHTML string
+	if post.BodyMarkdown != nil {
+		doc, err := markdown.For(blog.MarkdownOptions).RenderDocument(*post.BodyMarkdown, resolvers)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render post: %w", err)
+		}
+		bodyHTML = helpers.SanitizeHTMLForFeed(string(doc.HTML), base.Scheme+"://", base.Host, 0, "/"+*post.Slug)
+	}
+
+	unsubscribeURL := baseURL + "/unsubscribe?token=" + subscriber.UnsubscribeToken
+
+	html, err := s.renderEmail("post.html", map[string]interface{}{
+		"BlogTitle":      title,
+		"BlogURL":        baseURL,
+		"PostTitle":      postTitle,
+		"PostURL":        postURL,
+		"Body":           template.HTML(bodyHTML),
+		"UnsubscribeURL": unsubscribeURL,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	summary := postTitle
+	if post.MetaDescription != nil && *post.MetaDescription != "" {
+		summary = *post.MetaDescription
+	}
+
+	return &mailer.Message{
+		From:     s.from,
+		To:       subscriber.Email,
+		Subject:  postTitle + " | " + title,
+		TextBody: summary + "\n\nRead it on " + title + ":\n" + postURL + "\n\n--\nUnsubscribe: " + unsubscribeURL + "\n",
+		HTMLBody: html,
+		Headers: map[string]string{
+			// RFC 8058 one-click unsubscribe
+			"List-Unsubscribe":      "<" + unsubscribeURL + ">",
+			"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+			"List-Id":               title + " <" + base.Hostname() + ">",
+		},
+	}, nil
+}
+
+// renderEmail renders an HTML email template
+func (s *Service) renderEmail(templateName string, data map[string]interface{}) (string, error) {
+	var buf bytes.Buffer
+	if err := s.views.Execute(&buf, "email/"+templateName, data); err != nil {
+		return "", fmt.Errorf("failed to render email template: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// blogTitle returns the blog title for display in emails
+func blogTitle(blog *models.Blog) string {
+	if blog.Title != nil && *blog.Title != "" {
+		return *blog.Title
+	}
+	if blog.Subdomain != nil && *blog.Subdomain != "" {
+		return *blog.Subdomain
+	}
+	if blog.CustomDomain != nil && *blog.CustomDomain != "" {
+		return *blog.CustomDomain
+	}
+	return "willow.camp"
+}
@@ -0,0 +1,10 @@
+// Package templates embeds the email templates so the binary does not
+// depend on its working directory.
+package templates
+
+import "embed"
+
+// FS holds the email templates
+//
+//go:embed *.html
+var FS embed.FS
@@ -0,0 +1,67 @@
+// Package postbody renders post markdown with what the blog stores for it:
+// uploaded images, server-rendered diagrams, link embeds and link previews.
+// Public pages, feeds and the dashboard preview share it, so a post looks
+// the same everywhere it's shown.
+package postbody
+
+import (
+	"context"
+
+	"github.com/cassiascheffer/willow_camp/internal/diagrams"
+	"github.com/cassiascheffer/willow_camp/internal/embeds"
+	"github.com/cassiascheffer/willow_camp/internal/logging"
+	"github.com/cassiascheffer/willow_camp/internal/markdown"
+	"github.com/cassiascheffer/willow_camp/internal/media"
+	"github.com/cassiascheffer/willow_camp/internal/models"
+	"github.com/cassiascheffer/willow_camp/internal/previews"
+)
+
+// Services hold what post markdown can refer to. Any of them may be nil,
+// and the markdown then renders without it.
+type Services struct {
+	Media    *media.Service
+	Diagrams *diagrams.Service
+	Embeds   *embeds.Service
+	Previews *previews.Service
+}
+
+// Render renders a post's markdown with the blog's options, making its
+// uploaded images responsive and drawing its diagrams, embeds and link
+// previews
+func (s Services) Render(ctx context.Context, blog *models.Blog, logger *logging.Logger, source string) (*markdown.Document, error) {
+	resolvers := s.Resolvers(ctx, logger, source)
+	if s.Media != nil {
+		resolve, err := s.Media.ImageResolver(ctx, blog, source)
+		if err != nil {
+			// Images still render, just without srcset and dimensions
+			logger.Warn("Failed to load media for markdown", "blog_id", blog.ID, "error", err)
+		}
+		resolvers.Images = resolve
+	}
+	return markdown.For(blog.MarkdownOptions).RenderDocument(source, resolvers)
+}
+
+// Resolvers loads the diagrams, embeds and link previews in the given
+// markdown at once, e.g. for every post in a feed. Any that fail to load are
+// logged and left out: diagrams are then left for mermaid.js to draw, links
+// to providers are shown as link cards and bare links stay plain links.
+func (s Services) Resolvers(ctx context.Context, logger *logging.Logger, sources ...string) markdown.Resolvers {
+	var resolvers markdown.Resolvers
+	var err error
+	if s.Diagrams != nil {
+		if resolvers.Diagrams, err = s.Diagrams.Resolver(ctx, sources...); err != nil {
+			logger.Warn("Failed to load diagrams for markdown", "error", err)
+		}
+	}
+	if s.Embeds != nil {
+		if resolvers.Embeds, err = s.Embeds.Resolver(ctx, sources...); err != nil {
+			logger.Warn("Failed to load embeds for markdown", "error", err)
+		}
+	}
+	if s.Previews != nil {
+		if resolvers.Previews, err = s.Previews.Resolver(ctx, sources...); err != nil {
+			logger.Warn("Failed to load link previews for markdown", "error", err)
+		}
+	}
+	return resolvers
+}
@@ -0,0 +1,95 @@
+package previews
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// ErrBlockedAddress is returned for links that resolve to an address on a
+// private network, which must never be fetched on a writer's behalf
+var ErrBlockedAddress = errors.New("address is not publicly routable")
+
+// maxRedirects is how many redirects a preview fetch follows
+const maxRedirects = 5
+
+// blockedPrefixes are ranges that aren't reachable from the internet and
+// aren't covered by netip's IsPrivate, IsLoopback and friends
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // "this" network
+	netip.MustParsePrefix("100.64.0.0/10"),   // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF protocol assignments
+	netip.MustParsePrefix("192.0.2.0/24"),    // documentation
+	netip.MustParsePrefix("198.18.0.0/15"),   // benchmarking
+	netip.MustParsePrefix("198.51.100.0/24"), // documentation
+	netip.MustParsePrefix("203.0.113.0/24"),  // documentation
+	netip.MustParsePrefix("240.0.0.0/4"),     // reserved, and broadcast
+	netip.MustParsePrefix("64:ff9b::/96"),    // NAT64, which can reach private IPv4
+	netip.MustParsePrefix("2001:db8::/32"),   // documentation
+}
+
+// Blocked reports whether addr is loopback, private, link-local (including
+// cloud metadata endpoints), multicast or otherwise not publicly routable
+func Blocked(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() || addr.IsUnspecified() || addr.IsLoopback() || addr.IsPrivate() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() ||
+		addr.IsMulticast() {
+		return true
+	}
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// guardConnection refuses to connect to blocked addresses. It runs after DNS
+// resolution for every connection, including redirects, so a hostname that
+// resolves to a private address, or starts to, is caught too.
+func guardConnection(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if Blocked(addr) {
+		return fmt.Errorf("%w: %s", ErrBlockedAddress, addr)
+	}
+	return nil
+}
+
+// NewClient returns an HTTP client for fetching pages writers link to. It
+// only connects to public addresses, ignores proxy settings that would hide
+// the destination, and follows a few redirects to http and https URLs.
+func NewClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout, Control: guardConnection}
+	transport := &http.Transport{
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   timeout,
+		ResponseHeaderTimeout: timeout,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       90 * time.Second,
+	}
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("refusing to follow redirect to %s", req.URL.Scheme)
+			}
+			return nil
+		},
+	}
+}
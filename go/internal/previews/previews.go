@@ -0,0 +1,325 @@
+// Package previews turns bare links in posts into preview cards. The Open
+// Graph metadata of each linked page is fetched when a post is saved and
+// cached by URL, so pages render without calling out to other sites.
+package previews
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/cassiascheffer/willow_camp/internal/jobs"
+	"github.com/cassiascheffer/willow_camp/internal/markdown"
+	"github.com/cassiascheffer/willow_camp/internal/models"
+	"github.com/google/uuid"
+	"golang.org/x/net/html"
+)
+
+// JobFetch is the job kind that fetches a post's new or stale link previews
+const JobFetch = "previews.fetch"
+
+// CacheTTL is how long a cached preview is used before it is fetched again
+const CacheTTL = 7 * 24 * time.Hour
+
+// fetchTimeout bounds fetching a single page, redirects included
+const fetchTimeout = 10 * time.Second
+
+// maxPageBytes is how much of a page is read looking for its metadata,
+// which belongs in the head
+const maxPageBytes = 512 << 10
+
+const (
+	maxTitleLength       = 200
+	maxDescriptionLength = 400
+)
+
+// ErrNoPreview is returned for pages that answered but can't have a
+// preview, such as errors and links to files
+var ErrNoPreview = errors.New("page has no preview")
+
+// Store caches previews by URL. The link preview repository implements it.
+type Store interface {
+	FindByURLs(ctx context.Context, urls []string) (map[string]*models.LinkPreview, error)
+	Upsert(ctx context.Context, preview *models.LinkPreview) error
+}
+
+// Service fetches link previews in the background and looks them up for
+// rendering
+type Service struct {
+	store  Store
+	queue  *jobs.Queue
+	client *http.Client
+	skip   func(link string) bool
+}
+
+// New creates a new previews Service and registers its job on the queue
+func New(store Store, queue *jobs.Queue) *Service {
+	s := &Service{
+		store:  store,
+		queue:  queue,
+		client: NewClient(fetchTimeout),
+	}
+	if queue != nil {
+		queue.Register(JobFetch, s.runFetch)
+	}
+	return s
+}
+
+// SetClient replaces the HTTP client used to fetch pages. The default one
+// refuses private addresses; a replacement should too outside of tests.
+func (s *Service) SetClient(client *http.Client) {
+	s.client = client
+}
+
+// SetSkip sets which links are handled elsewhere, like the ones an embed
+// provider handles, and never need a preview
+func (s *Service) SetSkip(skip func(link string) bool) {
+	s.skip = skip
+}
+
+type fetchPayload struct {
+	URLs []string `json:"urls"`
+}
+
+// Enqueue schedules fetching the previews of the bare links in source that
+// aren't cached or have gone stale. Until the job runs they stay plain links.
+func (s *Service) Enqueue(ctx context.Context, blogID uuid.UUID, source string) error {
+	if s.queue == nil {
+		return nil
+	}
+	due, err := s.due(ctx, s.links(source))
+	if err != nil || len(due) == 0 {
+		return err
+	}
+	_, err = s.queue.Enqueue(ctx, JobFetch, fetchPayload{URLs: due}, jobs.ForBlog(blogID), jobs.MaxAttempts(3))
+	return err
+}
+
+// Prepare fetches and caches the previews of the bare links in source that
+// aren't cached or have gone stale
+func (s *Service) Prepare(ctx context.Context, source string) error {
+	due, err := s.due(ctx, s.links(source))
+	if err != nil {
+		return err
+	}
+	return s.fetchAll(ctx, due)
+}
+
+func (s *Service) runFetch(ctx context.Context, job *models.Job) error {
+	var payload fetchPayload
+	if err := job.DecodePayload(&payload); err != nil {
+		return jobs.Permanent(err)
+	}
+	// Another job may have fetched some of them since this one was queued
+	due, err := s.due(ctx, payload.URLs)
+	if err != nil {
+		return err
+	}
+	return s.fetchAll(ctx, due)
+}
+
+// fetchAll fetches each preview, carrying on past failures so one site being
+// down doesn't hold back the rest of the post. Pages that can't have a
+// preview are cached empty, so they aren't fetched again on every save.
+func (s *Service) fetchAll(ctx context.Context, urls []string) error {
+	var errs []error
+	for _, link := range urls {
+		preview, err := s.Fetch(ctx, link)
+		if errors.Is(err, ErrNoPreview) || errors.Is(err, ErrBlockedAddress) {
+			preview, err = &models.LinkPreview{URL: link, FetchedAt: time.Now()}, nil
+		}
+		if err == nil {
+			err = s.store.Upsert(ctx, preview)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("link preview %s: %w", link, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// links returns the distinct bare links in source that aren't skipped
+func (s *Service) links(sources ...string) []string {
+	var links []string
+	seen := map[string]bool{}
+	for _, source := range sources {
+		for _, link := range markdown.PreviewURLs(source) {
+			if seen[link] || (s.skip != nil && s.skip(link)) {
+				continue
+			}
+			seen[link] = true
+			links = append(links, link)
+		}
+	}
+	return links
+}
+
+// due returns the URLs that aren't cached or were fetched more than CacheTTL
+// ago
+func (s *Service) due(ctx context.Context, urls []string) ([]string, error) {
+	if len(urls) == 0 {
+		return nil, nil
+	}
+	cached, err := s.store.FindByURLs(ctx, urls)
+	if err != nil {
+		return nil, err
+	}
+	var due []string
+	for _, link := range urls {
+		if p, ok := cached[link]; !ok || time.Since(p.FetchedAt) > CacheTTL {
+			due = append(due, link)
+		}
+	}
+	return due, nil
+}
+
+// Fetch reads the Open Graph metadata of the page at link, falling back to
+// its title and meta description
+func (s *Service) Fetch(ctx context.Context, link string) (*models.LinkPreview, error) {
+	u, err := url.Parse(link)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("%w: not an http(s) URL", ErrNoPreview)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	req.Header.Set("User-Agent", "willow.camp link preview")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: returned %s", ErrNoPreview, resp.Status)
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return nil, fmt.Errorf("%w: content type %q", ErrNoPreview, mediaType)
+	}
+
+	meta := readMeta(io.LimitReader(resp.Body, maxPageBytes))
+	preview := &models.LinkPreview{
+		URL:         link,
+		Title:       clip(first(meta["og:title"], meta["twitter:title"], meta["title"]), maxTitleLength),
+		Description: clip(first(meta["og:description"], meta["twitter:description"], meta["description"]), maxDescriptionLength),
+		SiteName:    clip(meta["og:site_name"], maxTitleLength),
+		FetchedAt:   time.Now(),
+	}
+	// Relative images are relative to where any redirects ended up
+	if image := first(meta["og:image"], meta["og:image:url"], meta["twitter:image"]); image != "" {
+		if ref, err := resp.Request.URL.Parse(image); err == nil && (ref.Scheme == "http" || ref.Scheme == "https") {
+			preview.ImageURL = ref.String()
+		}
+	}
+	return preview, nil
+}
+
+// readMeta collects the page's title and the content of its meta tags,
+// keyed by property or name, stopping where the head ends
+func readMeta(r io.Reader) map[string]string {
+	meta := map[string]string{}
+	z := html.NewTokenizer(r)
+	inTitle := false
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return meta
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			switch string(name) {
+			case "body":
+				return meta
+			case "title":
+				inTitle = true
+			case "meta":
+				var key, content string
+				for hasAttr {
+					var k, v []byte
+					k, v, hasAttr = z.TagAttr()
+					switch string(k) {
+					case "property", "name":
+						key = strings.ToLower(strings.TrimSpace(string(v)))
+					case "content":
+						content = string(v)
+					}
+				}
+				if key != "" && meta[key] == "" {
+					meta[key] = content
+				}
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			switch string(name) {
+			case "head":
+				return meta
+			case "title":
+				inTitle = false
+			}
+		case html.TextToken:
+			if inTitle && meta["title"] == "" {
+				meta["title"] = string(z.Text())
+			}
+		}
+	}
+}
+
+// first returns the first value that isn't blank
+func first(values ...string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// clip collapses whitespace and shortens s to at most n characters
+func clip(s string, n int) string {
+	s = strings.Join(strings.Fields(strings.ToValidUTF8(s, "")), " ")
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	runes := []rune(s)
+	return strings.TrimSpace(string(runes[:n-1])) + "…"
+}
+
+// Resolver loads the cached previews for the bare links in the given
+// markdown with a single query and returns a resolver for
+// markdown.RenderDocument. Links without a preview stay plain links.
+func (s *Service) Resolver(ctx context.Context, sources ...string) (markdown.PreviewResolver, error) {
+	urls := s.links(sources...)
+	if len(urls) == 0 {
+		return nil, nil
+	}
+
+	cached, err := s.store.FindByURLs(ctx, urls)
+	if err != nil {
+		return nil, err
+	}
+	return func(link string) (markdown.LinkPreview, bool) {
+		p, ok := cached[link]
+		if !ok || p.Title == "" {
+			return markdown.LinkPreview{}, false
+		}
+		return markdown.LinkPreview{
+			URL:         link,
+			Title:       p.Title,
+			Description: p.Description,
+			Image:       p.ImageURL,
+			Site:        p.SiteName,
+		}, true
+	}, nil
+}
@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/cassiascheffer/willow_camp/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrActorKeyNotFound = errors.New("actor key not found")
+
+// ActivityPubRepository stores blogs' ActivityPub keys and followers
+type ActivityPubRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewActivityPubRepository(pool *pgxpool.Pool) *ActivityPubRepository {
+	return &ActivityPubRepository{pool: pool}
+}
+
+const actorKeyColumns = `id, blog_id, public_key_pem, private_key_pem, created_at, updated_at`
+
+const followerColumns = `id, blog_id, actor_id, inbox, shared_inbox, created_at, updated_at`
+
+// FindKey returns a blog's key pair
+func (r *ActivityPubRepository) FindKey(ctx context.Context, blogID uuid.UUID) (*models.ActorKey, error) {
+	key, err := scanActorKey(r.pool.QueryRow(ctx, `SELECT `+actorKeyColumns+` FROM activitypub_keys WHERE blog_id = $1`, blogID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrActorKeyNotFound
+		}
+		return nil, fmt.Errorf("failed to find actor key: %w", err)
+	}
+	return key, nil
+}
+
+// CreateKey stores a blog's key pair. If another request stored one first,
+// that one is returned instead so a blog only ever has one key.
+func (r *ActivityPubRepository) CreateKey(ctx context.Context, key *models.ActorKey) (*models.ActorKey, error) {
+	query := `
+		INSERT INTO activitypub_keys (blog_id, public_key_pem, private_key_pem, created_at, updated_at)
+		VALUES ($1, $2, $3, NOW(), NOW())
+		ON CONFLICT (blog_id) DO UPDATE SET blog_id = EXCLUDED.blog_id
+		RETURNING ` + actorKeyColumns
+	stored, err := scanActorKey(r.pool.QueryRow(ctx, query, key.BlogID, key.PublicKeyPEM, key.PrivateKeyPEM))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create actor key: %w", err)
+	}
+	return stored, nil
+}
+
+// AddFollower records a follower, updating the inboxes of one who follows again
+func (r *ActivityPubRepository) AddFollower(ctx context.Context, follower *models.Follower) error {
+	query := `
+		INSERT INTO activitypub_followers (blog_id, actor_id, inbox, shared_inbox, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, NOW(), NOW())
+		ON CONFLICT (blog_id, actor_id) DO UPDATE
+		SET inbox = EXCLUDED.inbox, shared_inbox = EXCLUDED.shared_inbox, updated_at = NOW()
+		RETURNING id, created_at, updated_at
+	`
+	err := r.pool.QueryRow(ctx, query, follower.BlogID, follower.ActorID, follower.Inbox, follower.SharedInbox).
+		Scan(&follower.ID, &follower.CreatedAt, &follower.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to add follower: %w", err)
+	}
+	return nil
+}
+
+// RemoveFollower removes a follower who unfollowed the blog
+func (r *ActivityPubRepository) RemoveFollower(ctx context.Context, blogID uuid.UUID, actorID string) error {
+	_, err := r.pool.Exec(ctx, `DELETE FROM activitypub_followers WHERE blog_id = $1 AND actor_id = $2`, blogID, actorID)
+	if err != nil {
+		return fmt.Errorf("failed to remove follower: %w", err)
+	}
+	return nil
+}
+
+// ListFollowers returns all of a blog's followers, oldest first
+func (r *ActivityPubRepository) ListFollowers(ctx context.Context, blogID uuid.UUID) ([]*models.Follower, error) {
+	query := `SELECT ` + followerColumns + ` FROM activitypub_followers WHERE blog_id = $1 ORDER BY created_at`
+	rows, err := r.pool.Query(ctx, query, blogID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list followers: %w", err)
+	}
+	defer rows.Close()
+
+	var followers []*models.Follower
+	for rows.Next() {
+		var f models.Follower
+		if err := rows.Scan(&f.ID, &f.BlogID, &f.ActorID, &f.Inbox, &f.SharedInbox, &f.CreatedAt, &f.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan follower: %w", err)
+		}
+		followers = append(followers, &f)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating followers: %w", err)
+	}
+	return followers, nil
+}
+
+// CountFollowers returns how many followers a blog has
+func (r *ActivityPubRepository) CountFollowers(ctx context.Context, blogID uuid.UUID) (int, error) {
+	var count int
+	err := r.pool.QueryRow(ctx, `SELECT COUNT(*) FROM activitypub_followers WHERE blog_id = $1`, blogID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count followers: %w", err)
+	}
+	return count, nil
+}
+
+// ClaimPostDelivery records that a post is delivered to followers and
+// enqueues its delivery jobs, one per inbox, in one transaction. Returns
+// false without enqueueing anything if the post has already been claimed, so
+// each post is announced at most once.
+func (r *ActivityPubRepository) ClaimPostDelivery(ctx context.Context, postID uuid.UUID, deliveries []*models.Job) (bool, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		INSERT INTO activitypub_deliveries (post_id, inboxes_count, sent_at, created_at, updated_at)
+		VALUES ($1, $2, NOW(), NOW(), NOW())
+		ON CONFLICT (post_id) DO NOTHING
+	`
+	result, err := tx.Exec(ctx, query, postID, len(deliveries))
+	if err != nil {
+		return false, fmt.Errorf("failed to claim post delivery: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return false, nil
+	}
+
+	for _, job := range deliveries {
+		if err := insertJob(ctx, tx, job); err != nil {
+			return false, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return true, nil
+}
+
+func scanActorKey(row pgx.Row) (*models.ActorKey, error) {
+	var k models.ActorKey
+	if err := row.Scan(&k.ID, &k.BlogID, &k.PublicKeyPEM, &k.PrivateKeyPEM, &k.CreatedAt, &k.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
@@ -26,7 +26,7 @@ func (r *BlogRepository) FindByDomain(ctx context.Context, domain string) (*mode
 	query := `
 		SELECT id, user_id, subdomain, title, slug, meta_description, favicon_emoji,
 		       custom_domain, theme, post_footer_markdown, no_index, "primary",
-		       created_at, updated_at
+		       theme_overrides, custom_css, theme_pack, markdown_options, highlight_style, comments_enabled, created_at, updated_at
 		FROM blogs
 		WHERE subdomain = $1 OR custom_domain = $1
 		LIMIT 1
@@ -37,7 +37,7 @@ func (r *BlogRepository) FindByDomain(ctx context.Context, domain string) (*mode
 		&blog.ID, &blog.UserID, &blog.Subdomain, &blog.Title, &blog.Slug,
 		&blog.MetaDescription, &blog.FaviconEmoji, &blog.CustomDomain, &blog.Theme,
 		&blog.PostFooterMarkdown, &blog.NoIndex, &blog.Primary,
-		&blog.CreatedAt, &blog.UpdatedAt,
+		&blog.ThemeOverrides, &blog.CustomCSS, &blog.ThemePack, &blog.MarkdownOptions, &blog.HighlightStyle, &blog.CommentsEnabled, &blog.CreatedAt, &blog.UpdatedAt,
 	)
 
 	if err != nil {
@@ -55,7 +55,7 @@ func (r *BlogRepository) FindBySubdomain(ctx context.Context, subdomain string)
 	query := `
 		SELECT id, user_id, subdomain, title, slug, meta_description, favicon_emoji,
 		       custom_domain, theme, post_footer_markdown, no_index, "primary",
-		       created_at, updated_at
+		       theme_overrides, custom_css, theme_pack, markdown_options, highlight_style, comments_enabled, created_at, updated_at
 		FROM blogs
 		WHERE subdomain = $1
 		LIMIT 1
@@ -66,7 +66,7 @@ func (r *BlogRepository) FindBySubdomain(ctx context.Context, subdomain string)
 		&blog.ID, &blog.UserID, &blog.Subdomain, &blog.Title, &blog.Slug,
 		&blog.MetaDescription, &blog.FaviconEmoji, &blog.CustomDomain, &blog.Theme,
 		&blog.PostFooterMarkdown, &blog.NoIndex, &blog.Primary,
-		&blog.CreatedAt, &blog.UpdatedAt,
+		&blog.ThemeOverrides, &blog.CustomCSS, &blog.ThemePack, &blog.MarkdownOptions, &blog.HighlightStyle, &blog.CommentsEnabled, &blog.CreatedAt, &blog.UpdatedAt,
 	)
 
 	if err != nil {
@@ -84,7 +84,7 @@ func (r *BlogRepository) FindByID(ctx context.Context, id uuid.UUID) (*models.Bl
 	query := `
 		SELECT id, user_id, subdomain, title, slug, meta_description, favicon_emoji,
 		       custom_domain, theme, post_footer_markdown, no_index, "primary",
-		       created_at, updated_at
+		       theme_overrides, custom_css, theme_pack, markdown_options, highlight_style, comments_enabled, created_at, updated_at
 		FROM blogs
 		WHERE id = $1
 	`
@@ -94,7 +94,7 @@ func (r *BlogRepository) FindByID(ctx context.Context, id uuid.UUID) (*models.Bl
 		&blog.ID, &blog.UserID, &blog.Subdomain, &blog.Title, &blog.Slug,
 		&blog.MetaDescription, &blog.FaviconEmoji, &blog.CustomDomain, &blog.Theme,
 		&blog.PostFooterMarkdown, &blog.NoIndex, &blog.Primary,
-		&blog.CreatedAt, &blog.UpdatedAt,
+		&blog.ThemeOverrides, &blog.CustomCSS, &blog.ThemePack, &blog.MarkdownOptions, &blog.HighlightStyle, &blog.CommentsEnabled, &blog.CreatedAt, &blog.UpdatedAt,
 	)
 
 	if err != nil {
@@ -112,7 +112,7 @@ func (r *BlogRepository) FindByUserID(ctx context.Context, userID uuid.UUID) ([]
 	query := `
 		SELECT id, user_id, subdomain, title, slug, meta_description, favicon_emoji,
 		       custom_domain, theme, post_footer_markdown, no_index, "primary",
-		       created_at, updated_at
+		       theme_overrides, custom_css, theme_pack, markdown_options, highlight_style, comments_enabled, created_at, updated_at
 		FROM blogs
 		WHERE user_id = $1
 		ORDER BY "primary" DESC, created_at ASC
@@ -131,7 +131,7 @@ func (r *BlogRepository) FindByUserID(ctx context.Context, userID uuid.UUID) ([]
 			&blog.ID, &blog.UserID, &blog.Subdomain, &blog.Title, &blog.Slug,
 			&blog.MetaDescription, &blog.FaviconEmoji, &blog.CustomDomain, &blog.Theme,
 			&blog.PostFooterMarkdown, &blog.NoIndex, &blog.Primary,
-			&blog.CreatedAt, &blog.UpdatedAt,
+			&blog.ThemeOverrides, &blog.CustomCSS, &blog.ThemePack, &blog.MarkdownOptions, &blog.HighlightStyle, &blog.CommentsEnabled, &blog.CreatedAt, &blog.UpdatedAt,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan blog: %w", err)
@@ -153,7 +153,7 @@ func (r *BlogRepository) Create(ctx context.Context, userID uuid.UUID, subdomain
 		VALUES ($1, $2, $3, NOW(), NOW())
 		RETURNING id, user_id, subdomain, title, slug, meta_description, favicon_emoji,
 		          custom_domain, theme, post_footer_markdown, no_index, "primary",
-		          created_at, updated_at
+		          theme_overrides, custom_css, theme_pack, markdown_options, highlight_style, comments_enabled, created_at, updated_at
 	`
 
 	var blog models.Blog
@@ -161,7 +161,7 @@ func (r *BlogRepository) Create(ctx context.Context, userID uuid.UUID, subdomain
 		&blog.ID, &blog.UserID, &blog.Subdomain, &blog.Title, &blog.Slug,
 		&blog.MetaDescription, &blog.FaviconEmoji, &blog.CustomDomain, &blog.Theme,
 		&blog.PostFooterMarkdown, &blog.NoIndex, &blog.Primary,
-		&blog.CreatedAt, &blog.UpdatedAt,
+		&blog.ThemeOverrides, &blog.CustomCSS, &blog.ThemePack, &blog.MarkdownOptions, &blog.HighlightStyle, &blog.CommentsEnabled, &blog.CreatedAt, &blog.UpdatedAt,
 	)
 
 	if err != nil {
@@ -177,14 +177,17 @@ func (r *BlogRepository) Update(ctx context.Context, blog *models.Blog) error {
 		UPDATE blogs
 		SET subdomain = $2, title = $3, slug = $4, meta_description = $5,
 		    favicon_emoji = $6, custom_domain = $7, theme = $8,
-		    post_footer_markdown = $9, no_index = $10, updated_at = NOW()
+		    post_footer_markdown = $9, no_index = $10, theme_overrides = $11,
+		    custom_css = $12, theme_pack = $13, markdown_options = $14, highlight_style = $15,
+		    comments_enabled = $16, updated_at = NOW()
 		WHERE id = $1
 	`
 
 	_, err := r.pool.Exec(ctx, query,
 		blog.ID, blog.Subdomain, blog.Title, blog.Slug, blog.MetaDescription,
 		blog.FaviconEmoji, blog.CustomDomain, blog.Theme, blog.PostFooterMarkdown,
-		blog.NoIndex,
+		blog.NoIndex, blog.ThemeOverrides, blog.CustomCSS, blog.ThemePack,
+		blog.MarkdownOptions, blog.HighlightStyle, blog.CommentsEnabled,
 	)
 
 	if err != nil {
@@ -0,0 +1,226 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cassiascheffer/willow_camp/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrCommentNotFound = errors.New("comment not found")
+
+// CommentRepository stores readers' comments and the commenters blogs ban
+type CommentRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewCommentRepository(pool *pgxpool.Pool) *CommentRepository {
+	return &CommentRepository{pool: pool}
+}
+
+const commentColumns = `
+	c.id, c.blog_id, c.post_id, c.author_name, c.author_email, c.author_url,
+	c.body, c.status, c.ip_hash, c.created_at, c.updated_at
+`
+
+// Create stores a new comment, waiting for moderation
+func (r *CommentRepository) Create(ctx context.Context, comment *models.Comment) error {
+	query := `
+		INSERT INTO comments (blog_id, post_id, author_name, author_email, author_url, body, status, ip_hash, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, 'pending', $7, NOW(), NOW())
+		RETURNING id, status, created_at, updated_at
+	`
+	err := r.pool.QueryRow(ctx, query, comment.BlogID, comment.PostID, comment.AuthorName, comment.AuthorEmail,
+		comment.AuthorURL, comment.Body, comment.IPHash).Scan(&comment.ID, &comment.Status, &comment.CreatedAt, &comment.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create comment: %w", err)
+	}
+	return nil
+}
+
+// FindByID returns a comment by ID
+func (r *CommentRepository) FindByID(ctx context.Context, id uuid.UUID) (*models.Comment, error) {
+	comment, err := scanComment(r.pool.QueryRow(ctx, `SELECT `+commentColumns+` FROM comments c WHERE c.id = $1`, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrCommentNotFound
+		}
+		return nil, fmt.Errorf("failed to find comment: %w", err)
+	}
+	return comment, nil
+}
+
+// CountRecentByIP counts the comments left on a blog from an address since
+// the given time
+func (r *CommentRepository) CountRecentByIP(ctx context.Context, blogID uuid.UUID, ipHash string, since time.Time) (int, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM comments WHERE blog_id = $1 AND ip_hash = $2 AND created_at >= $3`
+	if err := r.pool.QueryRow(ctx, query, blogID, ipHash, since).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count comments: %w", err)
+	}
+	return count, nil
+}
+
+// IsBanned reports whether a blog has banned the commenter's email address
+// or network address
+func (r *CommentRepository) IsBanned(ctx context.Context, blogID uuid.UUID, email, ipHash string) (bool, error) {
+	var banned bool
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM comment_bans
+			WHERE blog_id = $1 AND ((author_email <> '' AND author_email = $2) OR (ip_hash <> '' AND ip_hash = $3))
+		)
+	`
+	if err := r.pool.QueryRow(ctx, query, blogID, email, ipHash).Scan(&banned); err != nil {
+		return false, fmt.Errorf("failed to check comment bans: %w", err)
+	}
+	return banned, nil
+}
+
+// ListForBlog returns a blog's comments with the posts they're on, the ones
+// waiting for moderation first, then newest first
+func (r *CommentRepository) ListForBlog(ctx context.Context, blogID uuid.UUID, limit int) ([]*models.Comment, error) {
+	query := `
+		SELECT ` + commentColumns + `, COALESCE(p.title, ''), COALESCE(p.slug, '')
+		FROM comments c
+		JOIN posts p ON p.id = c.post_id
+		WHERE c.blog_id = $1
+		ORDER BY c.status = 'pending' DESC, c.created_at DESC
+		LIMIT $2
+	`
+	rows, err := r.pool.Query(ctx, query, blogID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list comments: %w", err)
+	}
+	defer rows.Close()
+
+	var comments []*models.Comment
+	for rows.Next() {
+		var c models.Comment
+		err := rows.Scan(
+			&c.ID, &c.BlogID, &c.PostID, &c.AuthorName, &c.AuthorEmail, &c.AuthorURL,
+			&c.Body, &c.Status, &c.IPHash, &c.CreatedAt, &c.UpdatedAt, &c.PostTitle, &c.PostSlug,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan comment: %w", err)
+		}
+		comments = append(comments, &c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating comments: %w", err)
+	}
+	return comments, nil
+}
+
+// ListApprovedForPost returns the comments shown under a post, oldest first
+func (r *CommentRepository) ListApprovedForPost(ctx context.Context, postID uuid.UUID) ([]*models.Comment, error) {
+	query := `
+		SELECT ` + commentColumns + `
+		FROM comments c
+		WHERE c.post_id = $1 AND c.status = 'approved'
+		ORDER BY c.created_at
+	`
+	rows, err := r.pool.Query(ctx, query, postID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list comments: %w", err)
+	}
+	defer rows.Close()
+
+	var comments []*models.Comment
+	for rows.Next() {
+		comment, err := scanComment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan comment: %w", err)
+		}
+		comments = append(comments, comment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating comments: %w", err)
+	}
+	return comments, nil
+}
+
+// Approve shows one of a blog's comments under its post
+func (r *CommentRepository) Approve(ctx context.Context, blogID, id uuid.UUID) error {
+	query := `
+		UPDATE comments
+		SET status = 'approved', updated_at = NOW()
+		WHERE id = $1 AND blog_id = $2
+	`
+	result, err := r.pool.Exec(ctx, query, id, blogID)
+	if err != nil {
+		return fmt.Errorf("failed to approve comment: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrCommentNotFound
+	}
+	return nil
+}
+
+// DeleteForBlog removes one of a blog's comments
+func (r *CommentRepository) DeleteForBlog(ctx context.Context, blogID, id uuid.UUID) error {
+	result, err := r.pool.Exec(ctx, `DELETE FROM comments WHERE id = $1 AND blog_id = $2`, id, blogID)
+	if err != nil {
+		return fmt.Errorf("failed to delete comment: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrCommentNotFound
+	}
+	return nil
+}
+
+// Ban stops the author of one of a blog's comments from commenting again,
+// by email and network address, and removes their comments still waiting
+// for moderation along with the one banned for
+func (r *CommentRepository) Ban(ctx context.Context, blogID, id uuid.UUID) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var email, ipHash string
+	err = tx.QueryRow(ctx, `SELECT author_email, ip_hash FROM comments WHERE id = $1 AND blog_id = $2`, id, blogID).Scan(&email, &ipHash)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrCommentNotFound
+		}
+		return fmt.Errorf("failed to find comment: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO comment_bans (blog_id, author_email, ip_hash, created_at, updated_at)
+		VALUES ($1, $2, $3, NOW(), NOW())
+	`, blogID, email, ipHash)
+	if err != nil {
+		return fmt.Errorf("failed to ban commenter: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		DELETE FROM comments
+		WHERE blog_id = $1 AND (id = $2 OR (status = 'pending' AND (
+			(author_email <> '' AND author_email = $3) OR (ip_hash <> '' AND ip_hash = $4))))
+	`, blogID, id, email, ipHash)
+	if err != nil {
+		return fmt.Errorf("failed to delete banned comments: %w", err)
+	}
+
+	return tx.Commit(ctx)
+}
+
+func scanComment(row pgx.Row) (*models.Comment, error) {
+	var c models.Comment
+	err := row.Scan(
+		&c.ID, &c.BlogID, &c.PostID, &c.AuthorName, &c.AuthorEmail, &c.AuthorURL,
+		&c.Body, &c.Status, &c.IPHash, &c.CreatedAt, &c.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// DiagramRepository stores rendered diagram SVGs by the hash of their source
+type DiagramRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewDiagramRepository(pool *pgxpool.Pool) *DiagramRepository {
+	return &DiagramRepository{pool: pool}
+}
+
+// FindByHashes loads the SVGs for many content hashes in one query, keyed by hash
+func (r *DiagramRepository) FindByHashes(ctx context.Context, hashes []string) (map[string]string, error) {
+	result := make(map[string]string, len(hashes))
+	if len(hashes) == 0 {
+		return result, nil
+	}
+
+	rows, err := r.pool.Query(ctx, `SELECT content_hash, svg FROM diagrams WHERE content_hash = ANY($1)`, hashes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query diagrams: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var hash, svg string
+		if err := rows.Scan(&hash, &svg); err != nil {
+			return nil, fmt.Errorf("failed to scan diagram: %w", err)
+		}
+		result[hash] = svg
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating diagrams: %w", err)
+	}
+	return result, nil
+}
+
+// Create stores a rendered diagram. Rendering is deterministic, so a diagram
+// that is already stored is left as it is.
+func (r *DiagramRepository) Create(ctx context.Context, hash, svg string) error {
+	query := `
+		INSERT INTO diagrams (content_hash, svg, created_at, updated_at)
+		VALUES ($1, $2, NOW(), NOW())
+		ON CONFLICT (content_hash) DO NOTHING
+	`
+	if _, err := r.pool.Exec(ctx, query, hash, svg); err != nil {
+		return fmt.Errorf("failed to create diagram: %w", err)
+	}
+	return nil
+}
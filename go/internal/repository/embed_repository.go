@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cassiascheffer/willow_camp/internal/models"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// EmbedRepository caches oEmbed responses by URL
+type EmbedRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewEmbedRepository(pool *pgxpool.Pool) *EmbedRepository {
+	return &EmbedRepository{pool: pool}
+}
+
+// FindByURLs loads the cached embeds for many URLs in one query, keyed by URL
+func (r *EmbedRepository) FindByURLs(ctx context.Context, urls []string) (map[string]*models.Embed, error) {
+	result := make(map[string]*models.Embed, len(urls))
+	if len(urls) == 0 {
+		return result, nil
+	}
+
+	query := `
+		SELECT id, url, provider, title, html, fetched_at, created_at, updated_at
+		FROM embeds
+		WHERE url = ANY($1)
+	`
+	rows, err := r.pool.Query(ctx, query, urls)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query embeds: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var e models.Embed
+		if err := rows.Scan(&e.ID, &e.URL, &e.Provider, &e.Title, &e.HTML, &e.FetchedAt, &e.CreatedAt, &e.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan embed: %w", err)
+		}
+		result[e.URL] = &e
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating embeds: %w", err)
+	}
+	return result, nil
+}
+
+// Upsert stores a freshly fetched embed, replacing the cached one for its URL
+func (r *EmbedRepository) Upsert(ctx context.Context, embed *models.Embed) error {
+	query := `
+		INSERT INTO embeds (url, provider, title, html, fetched_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, NOW(), NOW())
+		ON CONFLICT (url) DO UPDATE
+		SET provider = EXCLUDED.provider, title = EXCLUDED.title, html = EXCLUDED.html,
+		    fetched_at = EXCLUDED.fetched_at, updated_at = NOW()
+	`
+	if _, err := r.pool.Exec(ctx, query, embed.URL, embed.Provider, embed.Title, embed.HTML, embed.FetchedAt); err != nil {
+		return fmt.Errorf("failed to store embed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cassiascheffer/willow_camp/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrJobNotFound = errors.New("job not found")
+
+type JobRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewJobRepository(pool *pgxpool.Pool) *JobRepository {
+	return &JobRepository{pool: pool}
+}
+
+const jobColumns = `
+	id, queue, kind, payload, status, attempts, max_attempts, run_at,
+	locked_at, locked_by, last_error, finished_at, blog_id, created_at, updated_at
+`
+
+// Enqueue inserts a new pending job
+func (r *JobRepository) Enqueue(ctx context.Context, job *models.Job) error {
+	return insertJob(ctx, r.pool, job)
+}
+
+// rowQuerier is a pool or a transaction
+type rowQuerier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// insertJob inserts a pending job, so other repositories can enqueue jobs in
+// their own transactions
+func insertJob(ctx context.Context, db rowQuerier, job *models.Job) error {
+	if job.Payload == nil {
+		job.Payload = json.RawMessage("{}")
+	}
+
+	query := `
+		INSERT INTO jobs (queue, kind, payload, status, max_attempts, run_at, blog_id, created_at, updated_at)
+		VALUES ($1, $2, $3, 'pending', $4, $5, $6, NOW(), NOW())
+		RETURNING ` + jobColumns
+
+	row := db.QueryRow(ctx, query, job.Queue, job.Kind, job.Payload, job.MaxAttempts, job.RunAt, job.BlogID)
+	saved, err := scanJob(row)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue job: %w", err)
+	}
+	*job = *saved
+	return nil
+}
+
+// ClaimNext locks the next runnable job on a queue for a worker.
+// FOR UPDATE SKIP LOCKED lets many workers poll concurrently without
+// blocking each other or claiming the same job twice.
+func (r *JobRepository) ClaimNext(ctx context.Context, queue, workerID string) (*models.Job, error) {
+	query := `
+		UPDATE jobs
+		SET status = 'running', attempts = attempts + 1, locked_at = NOW(), locked_by = $2, updated_at = NOW()
+		WHERE id = (
+			SELECT id FROM jobs
+			WHERE queue = $1 AND status = 'pending' AND run_at <= NOW()
+			ORDER BY run_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + jobColumns
+
+	job, err := scanJob(r.pool.QueryRow(ctx, query, queue, workerID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrJobNotFound
+		}
+		return nil, fmt.Errorf("failed to claim job: %w", err)
+	}
+	return job, nil
+}
+
+// MarkDone records a successful run
+func (r *JobRepository) MarkDone(ctx context.Context, id uuid.UUID) error {
+	query := `
+		UPDATE jobs
+		SET status = 'done', finished_at = NOW(), locked_at = NULL, locked_by = NULL, last_error = NULL, updated_at = NOW()
+		WHERE id = $1
+	`
+	if _, err := r.pool.Exec(ctx, query, id); err != nil {
+		return fmt.Errorf("failed to mark job done: %w", err)
+	}
+	return nil
+}
+
+// Reschedule puts a failed job back in the queue to retry at runAt
+func (r *JobRepository) Reschedule(ctx context.Context, id uuid.UUID, runAt time.Time, lastError string) error {
+	query := `
+		UPDATE jobs
+		SET status = 'pending', run_at = $2, last_error = $3, locked_at = NULL, locked_by = NULL, updated_at = NOW()
+		WHERE id = $1
+	`
+	if _, err := r.pool.Exec(ctx, query, id, runAt, lastError); err != nil {
+		return fmt.Errorf("failed to reschedule job: %w", err)
+	}
+	return nil
+}
+
+// MarkDead moves a job that exhausted its retries to the dead-letter state
+func (r *JobRepository) MarkDead(ctx context.Context, id uuid.UUID, lastError string) error {
+	query := `
+		UPDATE jobs
+		SET status = 'dead', last_error = $2, finished_at = NOW(), locked_at = NULL, locked_by = NULL, updated_at = NOW()
+		WHERE id = $1
+	`
+	if _, err := r.pool.Exec(ctx, query, id, lastError); err != nil {
+		return fmt.Errorf("failed to mark job dead: %w", err)
+	}
+	return nil
+}
+
+// ReleaseStale returns jobs locked longer than staleAfter to the queue.
+// This recovers work from workers that crashed mid-job.
+func (r *JobRepository) ReleaseStale(ctx context.Context, staleAfter time.Duration) (int64, error) {
+	query := `
+		UPDATE jobs
+		SET status = 'pending', locked_at = NULL, locked_by = NULL, updated_at = NOW()
+		WHERE status = 'running' AND locked_at < $1
+	`
+	result, err := r.pool.Exec(ctx, query, time.Now().Add(-staleAfter))
+	if err != nil {
+		return 0, fmt.Errorf("failed to release stale jobs: %w", err)
+	}
+	return result.RowsAffected(), nil
+}
+
+// ListDeadForBlog lists dead-lettered jobs for a blog, newest first
+func (r *JobRepository) ListDeadForBlog(ctx context.Context, blogID uuid.UUID, limit int) ([]*models.Job, error) {
+	query := `
+		SELECT ` + jobColumns + `
+		FROM jobs
+		WHERE blog_id = $1 AND status = 'dead'
+		ORDER BY finished_at DESC NULLS LAST
+		LIMIT $2
+	`
+
+	rows, err := r.pool.Query(ctx, query, blogID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query dead jobs: %w", err)
+	}
+	defer rows.Close()
+
+	jobs := []*models.Job{}
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan job: %w", err)
+		}
+		jobs = append(jobs, job)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating jobs: %w", err)
+	}
+
+	return jobs, nil
+}
+
+// RetryDead resets a dead job for a blog so it runs again with a fresh set of attempts
+func (r *JobRepository) RetryDead(ctx context.Context, blogID, id uuid.UUID) error {
+	query := `
+		UPDATE jobs
+		SET status = 'pending', attempts = 0, run_at = NOW(), finished_at = NULL, updated_at = NOW()
+		WHERE id = $1 AND blog_id = $2 AND status = 'dead'
+	`
+	result, err := r.pool.Exec(ctx, query, id, blogID)
+	if err != nil {
+		return fmt.Errorf("failed to retry job: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrJobNotFound
+	}
+	return nil
+}
+
+// DeleteDead removes a dead job for a blog
+func (r *JobRepository) DeleteDead(ctx context.Context, blogID, id uuid.UUID) error {
+	result, err := r.pool.Exec(ctx, `DELETE FROM jobs WHERE id = $1 AND blog_id = $2 AND status = 'dead'`, id, blogID)
+	if err != nil {
+		return fmt.Errorf("failed to delete job: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrJobNotFound
+	}
+	return nil
+}
+
+// DeleteFinishedBefore prunes completed jobs older than the cutoff
+func (r *JobRepository) DeleteFinishedBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	result, err := r.pool.Exec(ctx, `DELETE FROM jobs WHERE status = 'done' AND finished_at < $1`, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune jobs: %w", err)
+	}
+	return result.RowsAffected(), nil
+}
+
+func scanJob(row pgx.Row) (*models.Job, error) {
+	var job models.Job
+	err := row.Scan(
+		&job.ID, &job.Queue, &job.Kind, &job.Payload, &job.Status, &job.Attempts,
+		&job.MaxAttempts, &job.RunAt, &job.LockedAt, &job.LockedBy, &job.LastError,
+		&job.FinishedAt, &job.BlogID, &job.CreatedAt, &job.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
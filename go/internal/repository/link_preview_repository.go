@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cassiascheffer/willow_camp/internal/models"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// LinkPreviewRepository caches the Open Graph metadata of linked pages by URL
+type LinkPreviewRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewLinkPreviewRepository(pool *pgxpool.Pool) *LinkPreviewRepository {
+	return &LinkPreviewRepository{pool: pool}
+}
+
+// FindByURLs loads the cached previews for many URLs in one query, keyed by URL
+func (r *LinkPreviewRepository) FindByURLs(ctx context.Context, urls []string) (map[string]*models.LinkPreview, error) {
+	result := make(map[string]*models.LinkPreview, len(urls))
+	if len(urls) == 0 {
+		return result, nil
+	}
+
+	query := `
+		SELECT id, url, title, description, image_url, site_name, fetched_at, created_at, updated_at
+		FROM link_previews
+		WHERE url = ANY($1)
+	`
+	rows, err := r.pool.Query(ctx, query, urls)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query link previews: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p models.LinkPreview
+		if err := rows.Scan(&p.ID, &p.URL, &p.Title, &p.Description, &p.ImageURL, &p.SiteName, &p.FetchedAt, &p.CreatedAt, &p.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan link preview: %w", err)
+		}
+		result[p.URL] = &p
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating link previews: %w", err)
+	}
+	return result, nil
+}
+
+// Upsert stores a freshly fetched preview, replacing the cached one for its URL
+func (r *LinkPreviewRepository) Upsert(ctx context.Context, preview *models.LinkPreview) error {
+	query := `
+		INSERT INTO link_previews (url, title, description, image_url, site_name, fetched_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW())
+		ON CONFLICT (url) DO UPDATE
+		SET title = EXCLUDED.title, description = EXCLUDED.description, image_url = EXCLUDED.image_url,
+		    site_name = EXCLUDED.site_name, fetched_at = EXCLUDED.fetched_at, updated_at = NOW()
+	`
+	if _, err := r.pool.Exec(ctx, query, preview.URL, preview.Title, preview.Description, preview.ImageURL, preview.SiteName, preview.FetchedAt); err != nil {
+		return fmt.Errorf("failed to store link preview: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/cassiascheffer/willow_camp/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrMediaNotFound = errors.New("media not found")
+
+type MediaRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewMediaRepository(pool *pgxpool.Pool) *MediaRepository {
+	return &MediaRepository{pool: pool}
+}
+
+const mediaColumns = `
+	id, blog_id, user_id, key, filename, content_type, byte_size, checksum,
+	width, height, variant_widths, created_at, updated_at
+`
+
+// Create records an uploaded file
+func (r *MediaRepository) Create(ctx context.Context, media *models.Media) error {
+	query := `
+		INSERT INTO media (blog_id, user_id, key, filename, content_type, byte_size, checksum, width, height, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NOW(), NOW())
+		RETURNING id, variant_widths, created_at, updated_at
+	`
+
+	err := r.pool.QueryRow(ctx, query,
+		media.BlogID, media.UserID, media.Key, media.Filename,
+		media.ContentType, media.ByteSize, media.Checksum, media.Width, media.Height,
+	).Scan(&media.ID, &media.VariantWidths, &media.CreatedAt, &media.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create media: %w", err)
+	}
+	return nil
+}
+
+// FindByID finds media by ID
+func (r *MediaRepository) FindByID(ctx context.Context, id uuid.UUID) (*models.Media, error) {
+	query := `SELECT ` + mediaColumns + ` FROM media WHERE id = $1`
+	return r.scanOne(r.pool.QueryRow(ctx, query, id))
+}
+
+// FindByKey finds a blog's media by its public key
+func (r *MediaRepository) FindByKey(ctx context.Context, blogID uuid.UUID, key string) (*models.Media, error) {
+	query := `SELECT ` + mediaColumns + ` FROM media WHERE blog_id = $1 AND key = $2`
+	return r.scanOne(r.pool.QueryRow(ctx, query, blogID, key))
+}
+
+// FindByKeys loads a blog's media for many keys in one query, keyed by media key
+func (r *MediaRepository) FindByKeys(ctx context.Context, blogID uuid.UUID, keys []string) (map[string]*models.Media, error) {
+	result := make(map[string]*models.Media, len(keys))
+	if len(keys) == 0 {
+		return result, nil
+	}
+
+	query := `SELECT ` + mediaColumns + ` FROM media WHERE blog_id = $1 AND key = ANY($2)`
+	rows, err := r.pool.Query(ctx, query, blogID, keys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query media: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		m, err := r.scanOne(rows)
+		if err != nil {
+			return nil, err
+		}
+		result[m.Key] = m
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating media: %w", err)
+	}
+
+	return result, nil
+}
+
+// SetVariants records which resized copies exist for an image
+func (r *MediaRepository) SetVariants(ctx context.Context, id uuid.UUID, widths []int) error {
+	query := `UPDATE media SET variant_widths = $2, updated_at = NOW() WHERE id = $1`
+	if _, err := r.pool.Exec(ctx, query, id, widths); err != nil {
+		return fmt.Errorf("failed to set media variants: %w", err)
+	}
+	return nil
+}
+
+// ListForBlog lists a blog's media, newest first
+func (r *MediaRepository) ListForBlog(ctx context.Context, blogID uuid.UUID) ([]*models.Media, error) {
+	query := `SELECT ` + mediaColumns + ` FROM media WHERE blog_id = $1 ORDER BY created_at DESC`
+
+	rows, err := r.pool.Query(ctx, query, blogID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query media: %w", err)
+	}
+	defer rows.Close()
+
+	media := []*models.Media{}
+	for rows.Next() {
+		m, err := r.scanOne(rows)
+		if err != nil {
+			return nil, err
+		}
+		media = append(media, m)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating media: %w", err)
+	}
+
+	return media, nil
+}
+
+// Delete removes a media record
+func (r *MediaRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	if _, err := r.pool.Exec(ctx, `DELETE FROM media WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("failed to delete media: %w", err)
+	}
+	return nil
+}
+
+func (r *MediaRepository) scanOne(row pgx.Row) (*models.Media, error) {
+	var m models.Media
+	err := row.Scan(
+		&m.ID, &m.BlogID, &m.UserID, &m.Key, &m.Filename,
+		&m.ContentType, &m.ByteSize, &m.Checksum,
+		&m.Width, &m.Height, &m.VariantWidths, &m.CreatedAt, &m.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrMediaNotFound
+		}
+		return nil, fmt.Errorf("failed to find media: %w", err)
+	}
+	return &m, nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/cassiascheffer/willow_camp/internal/models"
 	"github.com/google/uuid"
@@ -25,7 +26,7 @@ func NewPostRepository(pool *pgxpool.Pool) *PostRepository {
 func (r *PostRepository) FindBySlug(ctx context.Context, blogID uuid.UUID, slug string) (*models.Post, error) {
 	query := `
 		SELECT id, blog_id, author_id, title, slug, body_markdown, meta_description,
-		       published, published_at, type, has_mermaid_diagrams, featured,
+		       published, published_at, type, has_mermaid_diagrams, featured, visibility, password_digest,
 		       created_at, updated_at
 		FROM posts
 		WHERE blog_id = $1 AND slug = $2
@@ -36,7 +37,7 @@ func (r *PostRepository) FindBySlug(ctx context.Context, blogID uuid.UUID, slug
 	err := r.pool.QueryRow(ctx, query, blogID, slug).Scan(
 		&post.ID, &post.BlogID, &post.AuthorID, &post.Title, &post.Slug,
 		&post.BodyMarkdown, &post.MetaDescription, &post.Published, &post.PublishedAt,
-		&post.Type, &post.HasMermaidDiagrams, &post.Featured,
+		&post.Type, &post.HasMermaidDiagrams, &post.Featured, &post.Visibility, &post.PasswordDigest,
 		&post.CreatedAt, &post.UpdatedAt,
 	)
 
@@ -85,7 +86,7 @@ func (r *PostRepository) FindMaxSlugNumber(ctx context.Context, blogID, authorID
 func (r *PostRepository) FindByID(ctx context.Context, id uuid.UUID) (*models.Post, error) {
 	query := `
 		SELECT id, blog_id, author_id, title, slug, body_markdown, meta_description,
-		       published, published_at, type, has_mermaid_diagrams, featured,
+		       published, published_at, type, has_mermaid_diagrams, featured, visibility, password_digest,
 		       created_at, updated_at
 		FROM posts
 		WHERE id = $1
@@ -95,7 +96,7 @@ func (r *PostRepository) FindByID(ctx context.Context, id uuid.UUID) (*models.Po
 	err := r.pool.QueryRow(ctx, query, id).Scan(
 		&post.ID, &post.BlogID, &post.AuthorID, &post.Title, &post.Slug,
 		&post.BodyMarkdown, &post.MetaDescription, &post.Published, &post.PublishedAt,
-		&post.Type, &post.HasMermaidDiagrams, &post.Featured,
+		&post.Type, &post.HasMermaidDiagrams, &post.Featured, &post.Visibility, &post.PasswordDigest,
 		&post.CreatedAt, &post.UpdatedAt,
 	)
 
@@ -109,14 +110,16 @@ func (r *PostRepository) FindByID(ctx context.Context, id uuid.UUID) (*models.Po
 	return &post, nil
 }
 
-// ListPublished lists published posts for a blog with pagination
+// ListPublished lists published posts for a blog with pagination, leaving out
+// unlisted ones
 func (r *PostRepository) ListPublished(ctx context.Context, blogID uuid.UUID, limit, offset int) ([]*models.Post, error) {
 	query := `
 		SELECT id, blog_id, author_id, title, slug, body_markdown, meta_description,
-		       published, published_at, type, has_mermaid_diagrams, featured,
+		       published, published_at, type, has_mermaid_diagrams, featured, visibility, password_digest,
 		       created_at, updated_at
 		FROM posts
 		WHERE blog_id = $1 AND published = true AND (type IS NULL OR type = 'Post')
+		  AND visibility <> 'unlisted'
 		ORDER BY published_at DESC NULLS LAST, created_at DESC
 		LIMIT $2 OFFSET $3
 	`
@@ -134,10 +137,11 @@ func (r *PostRepository) ListPublished(ctx context.Context, blogID uuid.UUID, li
 func (r *PostRepository) ListFeatured(ctx context.Context, blogID uuid.UUID, limit int) ([]*models.Post, error) {
 	query := `
 		SELECT id, blog_id, author_id, title, slug, body_markdown, meta_description,
-		       published, published_at, type, has_mermaid_diagrams, featured,
+		       published, published_at, type, has_mermaid_diagrams, featured, visibility, password_digest,
 		       created_at, updated_at
 		FROM posts
 		WHERE blog_id = $1 AND published = true AND featured = true AND (type IS NULL OR type = 'Post')
+		  AND visibility <> 'unlisted'
 		ORDER BY published_at DESC NULLS LAST, created_at DESC
 		LIMIT $2
 	`
@@ -155,7 +159,7 @@ func (r *PostRepository) ListFeatured(ctx context.Context, blogID uuid.UUID, lim
 func (r *PostRepository) ListAll(ctx context.Context, blogID uuid.UUID, limit, offset int) ([]*models.Post, error) {
 	query := `
 		SELECT id, blog_id, author_id, title, slug, body_markdown, meta_description,
-		       published, published_at, type, has_mermaid_diagrams, featured,
+		       published, published_at, type, has_mermaid_diagrams, featured, visibility, password_digest,
 		       created_at, updated_at
 		FROM posts
 		WHERE blog_id = $1
@@ -172,12 +176,13 @@ func (r *PostRepository) ListAll(ctx context.Context, blogID uuid.UUID, limit, o
 	return r.scanPosts(rows)
 }
 
-// CountPublished counts published posts for a blog
+// CountPublished counts the published posts ListPublished lists
 func (r *PostRepository) CountPublished(ctx context.Context, blogID uuid.UUID) (int, error) {
 	query := `
 		SELECT COUNT(*)
 		FROM posts
 		WHERE blog_id = $1 AND published = true AND (type IS NULL OR type = 'Post')
+		  AND visibility <> 'unlisted'
 	`
 
 	var count int
@@ -189,11 +194,268 @@ func (r *PostRepository) CountPublished(ctx context.Context, blogID uuid.UUID) (
 	return count, nil
 }
 
+// ListPublic lists published posts anyone can read, for feeds, the sitemap
+// and other places a post's content leaves the blog
+func (r *PostRepository) ListPublic(ctx context.Context, blogID uuid.UUID, limit, offset int) ([]*models.Post, error) {
+	query := `
+		SELECT id, blog_id, author_id, title, slug, body_markdown, meta_description,
+		       published, published_at, type, has_mermaid_diagrams, featured, visibility, password_digest,
+		       created_at, updated_at
+		FROM posts
+		WHERE blog_id = $1 AND published = true AND (type IS NULL OR type = 'Post')
+		  AND visibility = 'public'
+		ORDER BY published_at DESC NULLS LAST, created_at DESC
+		LIMIT $2 OFFSET $3
+	`
+
+	rows, err := r.pool.Query(ctx, query, blogID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query posts: %w", err)
+	}
+	defer rows.Close()
+
+	return r.scanPosts(rows)
+}
+
+// CountPublic counts the published posts anyone can read
+func (r *PostRepository) CountPublic(ctx context.Context, blogID uuid.UUID) (int, error) {
+	query := `
+		SELECT COUNT(*)
+		FROM posts
+		WHERE blog_id = $1 AND published = true AND (type IS NULL OR type = 'Post')
+		  AND visibility = 'public'
+	`
+
+	var count int
+	err := r.pool.QueryRow(ctx, query, blogID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count posts: %w", err)
+	}
+
+	return count, nil
+}
+
+// ListPublishedByTagSlug lists published posts for a blog that carry the given tag
+func (r *PostRepository) ListPublishedByTagSlug(ctx context.Context, blogID uuid.UUID, tagSlug string, limit, offset int) ([]*models.Post, error) {
+	query := `
+		SELECT p.id, p.blog_id, p.author_id, p.title, p.slug, p.body_markdown, p.meta_description,
+		       p.published, p.published_at, p.type, p.has_mermaid_diagrams, p.featured, p.visibility, p.password_digest,
+		       p.created_at, p.updated_at
+		FROM posts p
+		WHERE p.blog_id = $1 AND p.published = true AND (p.type IS NULL OR p.type = 'Post')
+		  AND p.visibility <> 'unlisted'
+		  AND EXISTS (
+		      SELECT 1
+		      FROM taggings tg
+		      INNER JOIN tags t ON t.id = tg.tag_id
+		      WHERE tg.taggable_id = p.id AND tg.taggable_type = 'Post' AND t.slug = $2
+		  )
+		ORDER BY p.published_at DESC NULLS LAST, p.created_at DESC
+		LIMIT $3 OFFSET $4
+	`
+
+	rows, err := r.pool.Query(ctx, query, blogID, tagSlug, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query posts by tag: %w", err)
+	}
+	defer rows.Close()
+
+	return r.scanPosts(rows)
+}
+
+// CountPublishedByTagSlug counts published posts for a blog that carry the given tag
+func (r *PostRepository) CountPublishedByTagSlug(ctx context.Context, blogID uuid.UUID, tagSlug string) (int, error) {
+	query := `
+		SELECT COUNT(*)
+		FROM posts p
+		WHERE p.blog_id = $1 AND p.published = true AND (p.type IS NULL OR p.type = 'Post')
+		  AND p.visibility <> 'unlisted'
+		  AND EXISTS (
+		      SELECT 1
+		      FROM taggings tg
+		      INNER JOIN tags t ON t.id = tg.tag_id
+		      WHERE tg.taggable_id = p.id AND tg.taggable_type = 'Post' AND t.slug = $2
+		  )
+	`
+
+	var count int
+	err := r.pool.QueryRow(ctx, query, blogID, tagSlug).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count posts by tag: %w", err)
+	}
+
+	return count, nil
+}
+
+// ListInSeries lists a series' published posts in order, leaving out unlisted
+// ones. The including post is listed whatever its state, so a draft or
+// unlisted part still finds its place when it's shown; pass uuid.Nil for none.
+func (r *PostRepository) ListInSeries(ctx context.Context, seriesID, including uuid.UUID) ([]*models.Post, error) {
+	query := `
+		SELECT p.id, p.blog_id, p.author_id, p.title, p.slug, p.body_markdown, p.meta_description,
+		       p.published, p.published_at, p.type, p.has_mermaid_diagrams, p.featured, p.visibility, p.password_digest,
+		       p.created_at, p.updated_at
+		FROM posts p
+		INNER JOIN series_posts sp ON sp.post_id = p.id
+		WHERE sp.series_id = $1
+		  AND (p.id = $2 OR (p.published = true AND p.visibility <> 'unlisted'))
+		ORDER BY sp.position ASC, p.published_at ASC NULLS LAST, p.created_at ASC
+	`
+
+	rows, err := r.pool.Query(ctx, query, seriesID, including)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query posts in series: %w", err)
+	}
+	defer rows.Close()
+
+	return r.scanPosts(rows)
+}
+
+// FindAdjacentPublished returns the published posts just before and after a
+// post by publish date, leaving out unlisted ones. Either is nil at the ends,
+// and both are nil for posts that were never published.
+func (r *PostRepository) FindAdjacentPublished(ctx context.Context, post *models.Post) (previous, next *models.Post, err error) {
+	if post.PublishedAt == nil {
+		return nil, nil, nil
+	}
+	if previous, err = r.findAdjacentPublished(ctx, post, "<", "DESC"); err != nil {
+		return nil, nil, err
+	}
+	if next, err = r.findAdjacentPublished(ctx, post, ">", "ASC"); err != nil {
+		return nil, nil, err
+	}
+	return previous, next, nil
+}
+
+// findAdjacentPublished finds the nearest published post on one side of a
+// post. Posts published at the same moment are ordered by ID.
+func (r *PostRepository) findAdjacentPublished(ctx context.Context, post *models.Post, comparison, direction string) (*models.Post, error) {
+	query := `
+		SELECT id, blog_id, author_id, title, slug, body_markdown, meta_description,
+		       published, published_at, type, has_mermaid_diagrams, featured, visibility, password_digest,
+		       created_at, updated_at
+		FROM posts
+		WHERE blog_id = $1 AND published = true AND (type IS NULL OR type = 'Post')
+		  AND visibility <> 'unlisted'
+		  AND published_at IS NOT NULL
+		  AND (published_at, id) ` + comparison + ` ($2, $3)
+		ORDER BY published_at ` + direction + `, id ` + direction + `
+		LIMIT 1
+	`
+
+	rows, err := r.pool.Query(ctx, query, post.BlogID, *post.PublishedAt, post.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query adjacent post: %w", err)
+	}
+	defer rows.Close()
+
+	posts, err := r.scanPosts(rows)
+	if err != nil || len(posts) == 0 {
+		return nil, err
+	}
+	return posts[0], nil
+}
+
+// ListRelated lists a blog's published posts sharing tags with a post, those
+// sharing the most first, leaving out unlisted ones. The taggings are joined
+// in one query however many tags the post has.
+func (r *PostRepository) ListRelated(ctx context.Context, post *models.Post, limit int) ([]*models.Post, error) {
+	query := `
+		SELECT p.id, p.blog_id, p.author_id, p.title, p.slug, p.body_markdown, p.meta_description,
+		       p.published, p.published_at, p.type, p.has_mermaid_diagrams, p.featured, p.visibility, p.password_digest,
+		       p.created_at, p.updated_at
+		FROM posts p
+		INNER JOIN (
+		    SELECT other.taggable_id AS post_id, COUNT(*) AS shared_tags
+		    FROM taggings mine
+		    INNER JOIN taggings other
+		        ON other.tag_id = mine.tag_id
+		       AND other.taggable_type = 'Post'
+		       AND other.taggable_id <> mine.taggable_id
+		    WHERE mine.taggable_id = $2 AND mine.taggable_type = 'Post'
+		    GROUP BY other.taggable_id
+		) related ON related.post_id = p.id
+		WHERE p.blog_id = $1 AND p.published = true AND (p.type IS NULL OR p.type = 'Post')
+		  AND p.visibility <> 'unlisted'
+		ORDER BY related.shared_tags DESC, p.published_at DESC NULLS LAST, p.created_at DESC
+		LIMIT $3
+	`
+
+	rows, err := r.pool.Query(ctx, query, post.BlogID, post.ID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query related posts: %w", err)
+	}
+	defer rows.Close()
+
+	return r.scanPosts(rows)
+}
+
+// CountPublishedByMonth counts a blog's published posts per month of
+// publishing, newest month first, leaving out unlisted ones. One aggregate
+// query covers the whole archive.
+func (r *PostRepository) CountPublishedByMonth(ctx context.Context, blogID uuid.UUID) ([]models.ArchiveMonth, error) {
+	query := `
+		SELECT date_trunc('month', published_at) AS month, COUNT(*)
+		FROM posts
+		WHERE blog_id = $1 AND published = true AND (type IS NULL OR type = 'Post')
+		  AND visibility <> 'unlisted'
+		  AND published_at IS NOT NULL
+		GROUP BY month
+		ORDER BY month DESC
+	`
+
+	rows, err := r.pool.Query(ctx, query, blogID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count posts by month: %w", err)
+	}
+	defer rows.Close()
+
+	var months []models.ArchiveMonth
+	for rows.Next() {
+		var month time.Time
+		var count int
+		if err := rows.Scan(&month, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan month: %w", err)
+		}
+		months = append(months, models.ArchiveMonth{Year: month.Year(), Month: month.Month(), Count: count})
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating months: %w", err)
+	}
+
+	return months, nil
+}
+
+// ListPublishedBetween lists a blog's published posts from one time up to,
+// but not including, another, newest first, leaving out unlisted ones
+func (r *PostRepository) ListPublishedBetween(ctx context.Context, blogID uuid.UUID, from, to time.Time, limit, offset int) ([]*models.Post, error) {
+	query := `
+		SELECT id, blog_id, author_id, title, slug, body_markdown, meta_description,
+		       published, published_at, type, has_mermaid_diagrams, featured, visibility, password_digest,
+		       created_at, updated_at
+		FROM posts
+		WHERE blog_id = $1 AND published = true AND (type IS NULL OR type = 'Post')
+		  AND visibility <> 'unlisted'
+		  AND published_at >= $2 AND published_at < $3
+		ORDER BY published_at DESC, created_at DESC
+		LIMIT $4 OFFSET $5
+	`
+
+	rows, err := r.pool.Query(ctx, query, blogID, from, to, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query posts by date: %w", err)
+	}
+	defer rows.Close()
+
+	return r.scanPosts(rows)
+}
+
 // ListPublishedPages lists published pages for a blog
 func (r *PostRepository) ListPublishedPages(ctx context.Context, blogID uuid.UUID) ([]*models.Post, error) {
 	query := `
 		SELECT id, blog_id, author_id, title, slug, body_markdown, meta_description,
-		       published, published_at, type, has_mermaid_diagrams, featured,
+		       published, published_at, type, has_mermaid_diagrams, featured, visibility, password_digest,
 		       created_at, updated_at
 		FROM posts
 		WHERE blog_id = $1 AND published = true AND type = 'Page'
@@ -214,7 +476,7 @@ func (r *PostRepository) FindOrCreateAboutPage(ctx context.Context, blogID, auth
 	// Try to find existing About page
 	query := `
 		SELECT id, blog_id, author_id, title, slug, body_markdown, meta_description,
-		       published, published_at, type, has_mermaid_diagrams, featured,
+		       published, published_at, type, has_mermaid_diagrams, featured, visibility, password_digest,
 		       created_at, updated_at
 		FROM posts
 		WHERE blog_id = $1 AND slug = 'about' AND type = 'Page'
@@ -225,7 +487,7 @@ func (r *PostRepository) FindOrCreateAboutPage(ctx context.Context, blogID, auth
 	err := r.pool.QueryRow(ctx, query, blogID).Scan(
 		&page.ID, &page.BlogID, &page.AuthorID, &page.Title, &page.Slug,
 		&page.BodyMarkdown, &page.MetaDescription, &page.Published, &page.PublishedAt,
-		&page.Type, &page.HasMermaidDiagrams, &page.Featured,
+		&page.Type, &page.HasMermaidDiagrams, &page.Featured, &page.Visibility, &page.PasswordDigest,
 		&page.CreatedAt, &page.UpdatedAt,
 	)
 
@@ -247,20 +509,21 @@ func (r *PostRepository) FindOrCreateAboutPage(ctx context.Context, blogID, auth
 		Slug:         stringPtr("about"),
 		Type:         stringPtr("Page"),
 		Published:    &published,
+		Visibility:   models.VisibilityPublic,
 		BodyMarkdown: stringPtr(""),
 	}
 
 	createQuery := `
 		INSERT INTO posts (id, blog_id, author_id, title, slug, body_markdown, meta_description,
-		                   published, published_at, type, has_mermaid_diagrams, featured,
+		                   published, published_at, type, has_mermaid_diagrams, featured, visibility, password_digest,
 		                   created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, NOW(), NOW())
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, NOW(), NOW())
 	`
 
 	_, err = r.pool.Exec(ctx, createQuery,
 		page.ID, page.BlogID, page.AuthorID, page.Title, page.Slug, page.BodyMarkdown,
 		page.MetaDescription, page.Published, page.PublishedAt, page.Type,
-		page.HasMermaidDiagrams, page.Featured,
+		page.HasMermaidDiagrams, page.Featured, page.Visibility, page.PasswordDigest,
 	)
 
 	if err != nil {
@@ -282,19 +545,22 @@ func stringPtr(s string) *string {
 func (r *PostRepository) Create(ctx context.Context, post *models.Post) error {
 	query := `
 		INSERT INTO posts (id, blog_id, author_id, title, slug, body_markdown, meta_description,
-		                   published, published_at, type, has_mermaid_diagrams, featured,
+		                   published, published_at, type, has_mermaid_diagrams, featured, visibility, password_digest,
 		                   created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, NOW(), NOW())
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, NOW(), NOW())
 	`
 
 	if post.ID == uuid.Nil {
 		post.ID = uuid.New()
 	}
+	if post.Visibility == "" {
+		post.Visibility = models.VisibilityPublic
+	}
 
 	_, err := r.pool.Exec(ctx, query,
 		post.ID, post.BlogID, post.AuthorID, post.Title, post.Slug, post.BodyMarkdown,
 		post.MetaDescription, post.Published, post.PublishedAt, post.Type,
-		post.HasMermaidDiagrams, post.Featured,
+		post.HasMermaidDiagrams, post.Featured, post.Visibility, post.PasswordDigest,
 	)
 
 	if err != nil {
@@ -310,13 +576,14 @@ func (r *PostRepository) Update(ctx context.Context, post *models.Post) error {
 		UPDATE posts
 		SET title = $2, slug = $3, body_markdown = $4, meta_description = $5,
 		    published = $6, published_at = $7, type = $8, has_mermaid_diagrams = $9,
-		    featured = $10, updated_at = NOW()
+		    featured = $10, visibility = $11, password_digest = $12, updated_at = NOW()
 		WHERE id = $1
 	`
 
 	_, err := r.pool.Exec(ctx, query,
 		post.ID, post.Title, post.Slug, post.BodyMarkdown, post.MetaDescription,
 		post.Published, post.PublishedAt, post.Type, post.HasMermaidDiagrams, post.Featured,
+		post.Visibility, post.PasswordDigest,
 	)
 
 	if err != nil {
@@ -347,7 +614,7 @@ func (r *PostRepository) scanPosts(rows pgx.Rows) ([]*models.Post, error) {
 		err := rows.Scan(
 			&post.ID, &post.BlogID, &post.AuthorID, &post.Title, &post.Slug,
 			&post.BodyMarkdown, &post.MetaDescription, &post.Published, &post.PublishedAt,
-			&post.Type, &post.HasMermaidDiagrams, &post.Featured,
+			&post.Type, &post.HasMermaidDiagrams, &post.Featured, &post.Visibility, &post.PasswordDigest,
 			&post.CreatedAt, &post.UpdatedAt,
 		)
 		if err != nil {
@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/cassiascheffer/willow_camp/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrPreviewLinkNotFound = errors.New("preview link not found")
+
+// PreviewLinkRepository stores the links that let reviewers read posts
+// before they're published
+type PreviewLinkRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewPreviewLinkRepository(pool *pgxpool.Pool) *PreviewLinkRepository {
+	return &PreviewLinkRepository{pool: pool}
+}
+
+const previewLinkColumns = `id, blog_id, post_id, token, expires_at, created_at, updated_at`
+
+// Create stores a preview link. The ID is set by the caller, because the
+// link's token is signed with it.
+func (r *PreviewLinkRepository) Create(ctx context.Context, link *models.PreviewLink) error {
+	query := `
+		INSERT INTO preview_links (id, blog_id, post_id, token, expires_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, NOW(), NOW())
+		RETURNING created_at, updated_at
+	`
+	err := r.pool.QueryRow(ctx, query, link.ID, link.BlogID, link.PostID, link.Token, link.ExpiresAt).
+		Scan(&link.CreatedAt, &link.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create preview link: %w", err)
+	}
+	return nil
+}
+
+// FindByID returns a preview link by ID
+func (r *PreviewLinkRepository) FindByID(ctx context.Context, id uuid.UUID) (*models.PreviewLink, error) {
+	link, err := scanPreviewLink(r.pool.QueryRow(ctx, `SELECT `+previewLinkColumns+` FROM preview_links WHERE id = $1`, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrPreviewLinkNotFound
+		}
+		return nil, fmt.Errorf("failed to find preview link: %w", err)
+	}
+	return link, nil
+}
+
+// ListActiveForPost returns a post's preview links that haven't expired,
+// newest first
+func (r *PreviewLinkRepository) ListActiveForPost(ctx context.Context, postID uuid.UUID) ([]*models.PreviewLink, error) {
+	query := `
+		SELECT ` + previewLinkColumns + `
+		FROM preview_links
+		WHERE post_id = $1 AND expires_at > NOW()
+		ORDER BY created_at DESC
+	`
+	rows, err := r.pool.Query(ctx, query, postID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list preview links: %w", err)
+	}
+	defer rows.Close()
+
+	var links []*models.PreviewLink
+	for rows.Next() {
+		link, err := scanPreviewLink(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan preview link: %w", err)
+		}
+		links = append(links, link)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating preview links: %w", err)
+	}
+	return links, nil
+}
+
+// DeleteForPost revokes one of a post's preview links
+func (r *PreviewLinkRepository) DeleteForPost(ctx context.Context, postID, id uuid.UUID) error {
+	result, err := r.pool.Exec(ctx, `DELETE FROM preview_links WHERE id = $1 AND post_id = $2`, id, postID)
+	if err != nil {
+		return fmt.Errorf("failed to delete preview link: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrPreviewLinkNotFound
+	}
+	return nil
+}
+
+func scanPreviewLink(row pgx.Row) (*models.PreviewLink, error) {
+	var l models.PreviewLink
+	err := row.Scan(&l.ID, &l.BlogID, &l.PostID, &l.Token, &l.ExpiresAt, &l.CreatedAt, &l.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
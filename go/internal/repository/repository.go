@@ -6,20 +6,46 @@ import (
 
 // Repositories holds all repository instances
 type Repositories struct {
-	Blog  *BlogRepository
-	Post  *PostRepository
-	User  *UserRepository
-	Tag   *TagRepository
-	Token *TokenRepository
+	Blog                *BlogRepository
+	Post                *PostRepository
+	User                *UserRepository
+	Tag                 *TagRepository
+	Token               *TokenRepository
+	Subscriber          *SubscriberRepository
+	Job                 *JobRepository
+	Media               *MediaRepository
+	Diagram             *DiagramRepository
+	Embed               *EmbedRepository
+	LinkPreview         *LinkPreviewRepository
+	Webmention          *WebmentionRepository
+	ActivityPub         *ActivityPubRepository
+	Comment             *CommentRepository
+	PreviewLink         *PreviewLinkRepository
+	Series              *SeriesRepository
+	UnlockAttempt       *UnlockAttemptRepository
+	SubscriptionRequest *SubscriptionRequestRepository
 }
 
 // NewRepositories creates a new Repositories instance
 func NewRepositories(pool *pgxpool.Pool) *Repositories {
 	return &Repositories{
-		Blog:  NewBlogRepository(pool),
-		Post:  NewPostRepository(pool),
-		User:  NewUserRepository(pool),
-		Tag:   NewTagRepository(pool),
-		Token: NewTokenRepository(pool),
+		Blog:                NewBlogRepository(pool),
+		Post:                NewPostRepository(pool),
+		User:                NewUserRepository(pool),
+		Tag:                 NewTagRepository(pool),
+		Token:               NewTokenRepository(pool),
+		Subscriber:          NewSubscriberRepository(pool),
+		Job:                 NewJobRepository(pool),
+		Media:               NewMediaRepository(pool),
+		Diagram:             NewDiagramRepository(pool),
+		Embed:               NewEmbedRepository(pool),
+		LinkPreview:         NewLinkPreviewRepository(pool),
+		Webmention:          NewWebmentionRepository(pool),
+		ActivityPub:         NewActivityPubRepository(pool),
+		Comment:             NewCommentRepository(pool),
+		PreviewLink:         NewPreviewLinkRepository(pool),
+		Series:              NewSeriesRepository(pool),
+		UnlockAttempt:       NewUnlockAttemptRepository(pool),
+		SubscriptionRequest: NewSubscriptionRequestRepository(pool),
 	}
 }
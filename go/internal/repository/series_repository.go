@@ -0,0 +1,186 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/cassiascheffer/willow_camp/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrSeriesNotFound = errors.New("series not found")
+
+// SeriesRepository stores a blog's series and the order of their posts
+type SeriesRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewSeriesRepository(pool *pgxpool.Pool) *SeriesRepository {
+	return &SeriesRepository{pool: pool}
+}
+
+const seriesColumns = `s.id, s.blog_id, s.title, s.slug, s.created_at, s.updated_at`
+
+// FindBySlug returns one of a blog's series by slug
+func (r *SeriesRepository) FindBySlug(ctx context.Context, blogID uuid.UUID, slug string) (*models.Series, error) {
+	query := `SELECT ` + seriesColumns + ` FROM series s WHERE s.blog_id = $1 AND s.slug = $2`
+	series, err := scanSeries(r.pool.QueryRow(ctx, query, blogID, slug))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrSeriesNotFound
+		}
+		return nil, fmt.Errorf("failed to find series: %w", err)
+	}
+	return series, nil
+}
+
+// FindForPost returns the series a post is part of, with the post's position
+func (r *SeriesRepository) FindForPost(ctx context.Context, postID uuid.UUID) (*models.Series, error) {
+	query := `
+		SELECT ` + seriesColumns + `, sp.position
+		FROM series s
+		INNER JOIN series_posts sp ON sp.series_id = s.id
+		WHERE sp.post_id = $1
+	`
+	var s models.Series
+	err := r.pool.QueryRow(ctx, query, postID).Scan(
+		&s.ID, &s.BlogID, &s.Title, &s.Slug, &s.CreatedAt, &s.UpdatedAt, &s.Position,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrSeriesNotFound
+		}
+		return nil, fmt.Errorf("failed to find series for post: %w", err)
+	}
+	return &s, nil
+}
+
+// FindForPosts returns the series of many posts at once, keyed by post ID,
+// with each post's position
+func (r *SeriesRepository) FindForPosts(ctx context.Context, postIDs []uuid.UUID) (map[uuid.UUID]*models.Series, error) {
+	seriesByPost := make(map[uuid.UUID]*models.Series, len(postIDs))
+	if len(postIDs) == 0 {
+		return seriesByPost, nil
+	}
+
+	query := `
+		SELECT sp.post_id, ` + seriesColumns + `, sp.position
+		FROM series s
+		INNER JOIN series_posts sp ON sp.series_id = s.id
+		WHERE sp.post_id = ANY($1)
+	`
+	rows, err := r.pool.Query(ctx, query, postIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query series: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var postID uuid.UUID
+		var s models.Series
+		err := rows.Scan(&postID, &s.ID, &s.BlogID, &s.Title, &s.Slug, &s.CreatedAt, &s.UpdatedAt, &s.Position)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan series: %w", err)
+		}
+		seriesByPost[postID] = &s
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating series: %w", err)
+	}
+	return seriesByPost, nil
+}
+
+// ListForBlog returns a blog's series by title
+func (r *SeriesRepository) ListForBlog(ctx context.Context, blogID uuid.UUID) ([]*models.Series, error) {
+	rows, err := r.pool.Query(ctx, `SELECT `+seriesColumns+` FROM series s WHERE s.blog_id = $1 ORDER BY s.title`, blogID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list series: %w", err)
+	}
+	defer rows.Close()
+
+	var list []*models.Series
+	for rows.Next() {
+		series, err := scanSeries(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan series: %w", err)
+		}
+		list = append(list, series)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating series: %w", err)
+	}
+	return list, nil
+}
+
+// FindOrCreateByTitle returns the blog's series with the title's slug,
+// creating it if there's none
+func (r *SeriesRepository) FindOrCreateByTitle(ctx context.Context, blogID uuid.UUID, title, slug string) (*models.Series, error) {
+	// The no-op update makes RETURNING give back the existing row too
+	query := `
+		INSERT INTO series AS s (id, blog_id, title, slug, created_at, updated_at)
+		VALUES (gen_random_uuid(), $1, $2, $3, NOW(), NOW())
+		ON CONFLICT (blog_id, slug) DO UPDATE SET updated_at = s.updated_at
+		RETURNING ` + seriesColumns
+	series, err := scanSeries(r.pool.QueryRow(ctx, query, blogID, title, slug))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find or create series: %w", err)
+	}
+	return series, nil
+}
+
+// SetPostSeries makes a post part of a series at the given position, moving
+// it out of any other series. A position of 0 keeps the post where it is in
+// the series, or adds it at the end.
+func (r *SeriesRepository) SetPostSeries(ctx context.Context, postID, seriesID uuid.UUID, position int) error {
+	query := `
+		INSERT INTO series_posts AS sp (id, series_id, post_id, position, created_at, updated_at)
+		VALUES (
+			gen_random_uuid(), $1, $2,
+			CASE WHEN $3::int > 0 THEN $3::int
+			     ELSE (SELECT COALESCE(MAX(position), 0) + 1 FROM series_posts WHERE series_id = $1) END,
+			NOW(), NOW()
+		)
+		ON CONFLICT (post_id) DO UPDATE SET
+			position = CASE WHEN $3::int > 0 OR sp.series_id <> EXCLUDED.series_id THEN EXCLUDED.position
+			                ELSE sp.position END,
+			series_id = EXCLUDED.series_id,
+			updated_at = NOW()
+	`
+	if _, err := r.pool.Exec(ctx, query, seriesID, postID, position); err != nil {
+		return fmt.Errorf("failed to set post series: %w", err)
+	}
+	return nil
+}
+
+// RemovePost takes a post out of its series, if it's in one
+func (r *SeriesRepository) RemovePost(ctx context.Context, postID uuid.UUID) error {
+	if _, err := r.pool.Exec(ctx, `DELETE FROM series_posts WHERE post_id = $1`, postID); err != nil {
+		return fmt.Errorf("failed to remove post from series: %w", err)
+	}
+	return nil
+}
+
+// DeleteEmpty deletes a blog's series that no longer have any posts
+func (r *SeriesRepository) DeleteEmpty(ctx context.Context, blogID uuid.UUID) error {
+	query := `
+		DELETE FROM series s
+		WHERE s.blog_id = $1
+		  AND NOT EXISTS (SELECT 1 FROM series_posts sp WHERE sp.series_id = s.id)
+	`
+	if _, err := r.pool.Exec(ctx, query, blogID); err != nil {
+		return fmt.Errorf("failed to delete empty series: %w", err)
+	}
+	return nil
+}
+
+func scanSeries(row pgx.Row) (*models.Series, error) {
+	var s models.Series
+	err := row.Scan(&s.ID, &s.BlogID, &s.Title, &s.Slug, &s.CreatedAt, &s.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
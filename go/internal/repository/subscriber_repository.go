@@ -0,0 +1,251 @@
+package repository
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cassiascheffer/willow_camp/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrSubscriberNotFound = errors.New("subscriber not found")
+
+type SubscriberRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewSubscriberRepository(pool *pgxpool.Pool) *SubscriberRepository {
+	return &SubscriberRepository{pool: pool}
+}
+
+const subscriberColumns = `
+	id, blog_id, email, confirmation_token, unsubscribe_token,
+	confirmation_sent_at, confirmed_at, unsubscribed_at, created_at, updated_at
+`
+
+// FindOrCreatePending returns the subscriber for an email address, creating a
+// pending (unconfirmed) one if needed. Existing subscribers who unsubscribed
+// are reset to pending with fresh tokens so they go through double opt-in again.
+func (r *SubscriberRepository) FindOrCreatePending(ctx context.Context, blogID uuid.UUID, email string) (*models.EmailSubscriber, error) {
+	confirmationToken, err := generateSubscriberToken()
+	if err != nil {
+		return nil, err
+	}
+	unsubscribeToken, err := generateSubscriberToken()
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		INSERT INTO email_subscribers (blog_id, email, confirmation_token, unsubscribe_token, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, NOW(), NOW())
+		ON CONFLICT (blog_id, email) DO UPDATE
+		SET confirmation_token = CASE WHEN email_subscribers.unsubscribed_at IS NOT NULL
+		                              THEN EXCLUDED.confirmation_token
+		                              ELSE email_subscribers.confirmation_token END,
+		    unsubscribe_token = CASE WHEN email_subscribers.unsubscribed_at IS NOT NULL
+		                             THEN EXCLUDED.unsubscribe_token
+		                             ELSE email_subscribers.unsubscribe_token END,
+		    confirmed_at = CASE WHEN email_subscribers.unsubscribed_at IS NOT NULL
+		                        THEN NULL
+		                        ELSE email_subscribers.confirmed_at END,
+		    unsubscribed_at = NULL,
+		    updated_at = NOW()
+		RETURNING ` + subscriberColumns
+
+	return r.scanOne(r.pool.QueryRow(ctx, query, blogID, email, confirmationToken, unsubscribeToken))
+}
+
+// FindByID finds a subscriber by ID
+func (r *SubscriberRepository) FindByID(ctx context.Context, id uuid.UUID) (*models.EmailSubscriber, error) {
+	query := `SELECT ` + subscriberColumns + ` FROM email_subscribers WHERE id = $1`
+	return r.scanOne(r.pool.QueryRow(ctx, query, id))
+}
+
+// FindByEmail finds a subscriber within a blog by email address
+func (r *SubscriberRepository) FindByEmail(ctx context.Context, blogID uuid.UUID, email string) (*models.EmailSubscriber, error) {
+	query := `SELECT ` + subscriberColumns + ` FROM email_subscribers WHERE blog_id = $1 AND email = $2`
+	return r.scanOne(r.pool.QueryRow(ctx, query, blogID, email))
+}
+
+// FindByConfirmationToken finds a subscriber within a blog by confirmation token
+func (r *SubscriberRepository) FindByConfirmationToken(ctx context.Context, blogID uuid.UUID, token string) (*models.EmailSubscriber, error) {
+	query := `SELECT ` + subscriberColumns + ` FROM email_subscribers WHERE blog_id = $1 AND confirmation_token = $2`
+	return r.scanOne(r.pool.QueryRow(ctx, query, blogID, token))
+}
+
+// FindByUnsubscribeToken finds a subscriber within a blog by unsubscribe token
+func (r *SubscriberRepository) FindByUnsubscribeToken(ctx context.Context, blogID uuid.UUID, token string) (*models.EmailSubscriber, error) {
+	query := `SELECT ` + subscriberColumns + ` FROM email_subscribers WHERE blog_id = $1 AND unsubscribe_token = $2`
+	return r.scanOne(r.pool.QueryRow(ctx, query, blogID, token))
+}
+
+// MarkConfirmationSent records when the opt-in email was sent
+func (r *SubscriberRepository) MarkConfirmationSent(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE email_subscribers SET confirmation_sent_at = NOW(), updated_at = NOW() WHERE id = $1`
+	if _, err := r.pool.Exec(ctx, query, id); err != nil {
+		return fmt.Errorf("failed to mark confirmation sent: %w", err)
+	}
+	return nil
+}
+
+// ClaimConfirmation records that an opt-in email is being sent, unless one
+// was already sent after the given time. It returns false when one was, so
+// repeated requests don't send another.
+func (r *SubscriberRepository) ClaimConfirmation(ctx context.Context, id uuid.UUID, after time.Time) (bool, error) {
+	query := `
+		UPDATE email_subscribers
+		SET confirmation_sent_at = NOW(), updated_at = NOW()
+		WHERE id = $1 AND (confirmation_sent_at IS NULL OR confirmation_sent_at <= $2)
+	`
+	tag, err := r.pool.Exec(ctx, query, id, after)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim confirmation: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// Confirm completes double opt-in for a subscriber
+func (r *SubscriberRepository) Confirm(ctx context.Context, id uuid.UUID) error {
+	query := `
+		UPDATE email_subscribers
+		SET confirmed_at = COALESCE(confirmed_at, NOW()), unsubscribed_at = NULL, updated_at = NOW()
+		WHERE id = $1
+	`
+	if _, err := r.pool.Exec(ctx, query, id); err != nil {
+		return fmt.Errorf("failed to confirm subscriber: %w", err)
+	}
+	return nil
+}
+
+// Unsubscribe stops all future emails to a subscriber
+func (r *SubscriberRepository) Unsubscribe(ctx context.Context, id uuid.UUID) error {
+	query := `
+		UPDATE email_subscribers
+		SET unsubscribed_at = COALESCE(unsubscribed_at, NOW()), updated_at = NOW()
+		WHERE id = $1
+	`
+	if _, err := r.pool.Exec(ctx, query, id); err != nil {
+		return fmt.Errorf("failed to unsubscribe: %w", err)
+	}
+	return nil
+}
+
+// ListForBlog lists every subscriber for a blog, newest first
+func (r *SubscriberRepository) ListForBlog(ctx context.Context, blogID uuid.UUID) ([]*models.EmailSubscriber, error) {
+	query := `SELECT ` + subscriberColumns + ` FROM email_subscribers WHERE blog_id = $1 ORDER BY created_at DESC`
+
+	rows, err := r.pool.Query(ctx, query, blogID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query subscribers: %w", err)
+	}
+	defer rows.Close()
+
+	return r.scanSubscribers(rows)
+}
+
+// ListActiveForBlog lists confirmed subscribers who have not unsubscribed
+func (r *SubscriberRepository) ListActiveForBlog(ctx context.Context, blogID uuid.UUID) ([]*models.EmailSubscriber, error) {
+	query := `
+		SELECT ` + subscriberColumns + `
+		FROM email_subscribers
+		WHERE blog_id = $1 AND confirmed_at IS NOT NULL AND unsubscribed_at IS NULL
+		ORDER BY created_at ASC
+	`
+
+	rows, err := r.pool.Query(ctx, query, blogID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query active subscribers: %w", err)
+	}
+	defer rows.Close()
+
+	return r.scanSubscribers(rows)
+}
+
+// ClaimPostDelivery records that a post is sent to subscribers and enqueues
+// its delivery jobs, one per subscriber. Both happen in one transaction, so a
+// failed enqueue leaves the post unclaimed for the retry. Returns false
+// without enqueueing anything if the post has already been claimed, so each
+// post is mailed at most once.
+func (r *SubscriberRepository) ClaimPostDelivery(ctx context.Context, postID uuid.UUID, deliveries []*models.Job) (bool, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		INSERT INTO newsletter_deliveries (post_id, recipients_count, sent_at, created_at, updated_at)
+		VALUES ($1, $2, NOW(), NOW(), NOW())
+		ON CONFLICT (post_id) DO NOTHING
+	`
+	result, err := tx.Exec(ctx, query, postID, len(deliveries))
+	if err != nil {
+		return false, fmt.Errorf("failed to claim post delivery: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return false, nil
+	}
+
+	for _, job := range deliveries {
+		if err := insertJob(ctx, tx, job); err != nil {
+			return false, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return true, nil
+}
+
+func (r *SubscriberRepository) scanOne(row pgx.Row) (*models.EmailSubscriber, error) {
+	var s models.EmailSubscriber
+	err := row.Scan(
+		&s.ID, &s.BlogID, &s.Email, &s.ConfirmationToken, &s.UnsubscribeToken,
+		&s.ConfirmationSentAt, &s.ConfirmedAt, &s.UnsubscribedAt, &s.CreatedAt, &s.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrSubscriberNotFound
+		}
+		return nil, fmt.Errorf("failed to find subscriber: %w", err)
+	}
+	return &s, nil
+}
+
+func (r *SubscriberRepository) scanSubscribers(rows pgx.Rows) ([]*models.EmailSubscriber, error) {
+	subscribers := []*models.EmailSubscriber{}
+	for rows.Next() {
+		var s models.EmailSubscriber
+		err := rows.Scan(
+			&s.ID, &s.BlogID, &s.Email, &s.ConfirmationToken, &s.UnsubscribeToken,
+			&s.ConfirmationSentAt, &s.ConfirmedAt, &s.UnsubscribedAt, &s.CreatedAt, &s.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan subscriber: %w", err)
+		}
+		subscribers = append(subscribers, &s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating subscribers: %w", err)
+	}
+
+	return subscribers, nil
+}
+
+// generateSubscriberToken returns a random 64-character hex token (32 bytes)
+func generateSubscriberToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
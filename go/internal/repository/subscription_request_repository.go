@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// SubscriptionRequestRepository stores the requests to subscribe to a blog,
+// so the subscribe form can't be used to flood other people's inboxes
+type SubscriptionRequestRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewSubscriptionRequestRepository(pool *pgxpool.Pool) *SubscriptionRequestRepository {
+	return &SubscriptionRequestRepository{pool: pool}
+}
+
+// CountRecentByIP counts the requests to subscribe to a blog made from an
+// address since the given time
+func (r *SubscriptionRequestRepository) CountRecentByIP(ctx context.Context, blogID uuid.UUID, ipHash string, since time.Time) (int, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM subscription_requests WHERE blog_id = $1 AND ip_hash = $2 AND created_at >= $3`
+	if err := r.pool.QueryRow(ctx, query, blogID, ipHash, since).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count subscription requests: %w", err)
+	}
+	return count, nil
+}
+
+// Record stores a request to subscribe to a blog from an address. The
+// blog's requests from before prune no longer count, so they're deleted.
+func (r *SubscriptionRequestRepository) Record(ctx context.Context, blogID uuid.UUID, ipHash string, prune time.Time) error {
+	query := `
+		WITH pruned AS (
+			DELETE FROM subscription_requests WHERE blog_id = $1 AND created_at < $3
+		)
+		INSERT INTO subscription_requests (blog_id, ip_hash, created_at)
+		VALUES ($1, $2, NOW())
+	`
+	if _, err := r.pool.Exec(ctx, query, blogID, ipHash, prune); err != nil {
+		return fmt.Errorf("failed to record subscription request: %w", err)
+	}
+	return nil
+}
@@ -2,13 +2,17 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/cassiascheffer/willow_camp/internal/models"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+var ErrTagNotFound = errors.New("tag not found")
+
 type TagRepository struct {
 	pool *pgxpool.Pool
 }
@@ -53,6 +57,48 @@ func (r *TagRepository) FindTagsForPost(ctx context.Context, postID uuid.UUID) (
 	return tags, nil
 }
 
+// FindTagsForPosts retrieves the tags for several posts in one query, keyed by post ID.
+// Posts without tags are absent from the map.
+func (r *TagRepository) FindTagsForPosts(ctx context.Context, postIDs []uuid.UUID) (map[uuid.UUID][]models.Tag, error) {
+	tagsByPost := make(map[uuid.UUID][]models.Tag, len(postIDs))
+	if len(postIDs) == 0 {
+		return tagsByPost, nil
+	}
+
+	query := `
+		SELECT tg.taggable_id, t.id, t.name, t.slug, t.taggings_count, t.created_at, t.updated_at
+		FROM tags t
+		INNER JOIN taggings tg ON t.id = tg.tag_id
+		WHERE tg.taggable_id = ANY($1) AND tg.taggable_type = 'Post'
+		ORDER BY t.name
+	`
+
+	rows, err := r.pool.Query(ctx, query, postIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tags: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var postID uuid.UUID
+		var tag models.Tag
+		err := rows.Scan(
+			&postID, &tag.ID, &tag.Name, &tag.Slug, &tag.TaggingsCount,
+			&tag.CreatedAt, &tag.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		tagsByPost[postID] = append(tagsByPost[postID], tag)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating tags: %w", err)
+	}
+
+	return tagsByPost, nil
+}
+
 // ListAllForBlog lists all tags used in a blog (including drafts)
 func (r *TagRepository) ListAllForBlog(ctx context.Context, blogID uuid.UUID) ([]string, error) {
 	query := `
@@ -238,6 +284,23 @@ func (r *TagRepository) FindByID(ctx context.Context, tagID uuid.UUID) (*models.
 	return &tag, nil
 }
 
+// FindBySlug retrieves a tag by its slug
+func (r *TagRepository) FindBySlug(ctx context.Context, slug string) (*models.Tag, error) {
+	query := `SELECT id, name, slug, taggings_count, created_at, updated_at FROM tags WHERE slug = $1`
+	var tag models.Tag
+	err := r.pool.QueryRow(ctx, query, slug).Scan(
+		&tag.ID, &tag.Name, &tag.Slug, &tag.TaggingsCount,
+		&tag.CreatedAt, &tag.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrTagNotFound
+		}
+		return nil, fmt.Errorf("failed to find tag: %w", err)
+	}
+	return &tag, nil
+}
+
 // UpdateTag updates a tag's name
 func (r *TagRepository) UpdateTag(ctx context.Context, tagID uuid.UUID, name string, slug string) error {
 	query := `UPDATE tags SET name = $1, slug = $2, updated_at = NOW() WHERE id = $3`
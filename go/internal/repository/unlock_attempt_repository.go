@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// UnlockAttemptRepository stores the wrong passwords readers enter for
+// password posts, so guessing can be throttled
+type UnlockAttemptRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewUnlockAttemptRepository(pool *pgxpool.Pool) *UnlockAttemptRepository {
+	return &UnlockAttemptRepository{pool: pool}
+}
+
+// CountRecentByIP counts the wrong passwords entered on a blog from an
+// address since the given time
+func (r *UnlockAttemptRepository) CountRecentByIP(ctx context.Context, blogID uuid.UUID, ipHash string, since time.Time) (int, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM unlock_attempts WHERE blog_id = $1 AND ip_hash = $2 AND created_at >= $3`
+	if err := r.pool.QueryRow(ctx, query, blogID, ipHash, since).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count unlock attempts: %w", err)
+	}
+	return count, nil
+}
+
+// Record stores a wrong password entered for a post from an address. The
+// blog's attempts from before prune no longer count, so they're deleted.
+func (r *UnlockAttemptRepository) Record(ctx context.Context, blogID, postID uuid.UUID, ipHash string, prune time.Time) error {
+	query := `
+		WITH pruned AS (
+			DELETE FROM unlock_attempts WHERE blog_id = $1 AND created_at < $4
+		)
+		INSERT INTO unlock_attempts (blog_id, post_id, ip_hash, created_at)
+		VALUES ($1, $2, $3, NOW())
+	`
+	if _, err := r.pool.Exec(ctx, query, blogID, postID, ipHash, prune); err != nil {
+		return fmt.Errorf("failed to record unlock attempt: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/cassiascheffer/willow_camp/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrWebmentionNotFound = errors.New("webmention not found")
+
+// WebmentionRepository stores the Webmentions blogs receive
+type WebmentionRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewWebmentionRepository(pool *pgxpool.Pool) *WebmentionRepository {
+	return &WebmentionRepository{pool: pool}
+}
+
+const webmentionColumns = `
+	id, blog_id, post_id, source, target, status, mention_type, author_name, author_url,
+	author_photo, content, published_at, verified_at, created_at, updated_at
+`
+
+// Receive records a mention of source linking to target. A source sending
+// the same mention again, e.g. after an edit, updates the existing one and
+// keeps its moderation status.
+func (r *WebmentionRepository) Receive(ctx context.Context, blogID, postID uuid.UUID, source, target string) (*models.Webmention, error) {
+	query := `
+		INSERT INTO webmentions (blog_id, post_id, source, target, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, NOW(), NOW())
+		ON CONFLICT (source, target) DO UPDATE
+		SET updated_at = NOW()
+		RETURNING ` + webmentionColumns
+	mention, err := scanWebmention(r.pool.QueryRow(ctx, query, blogID, postID, source, target))
+	if err != nil {
+		return nil, fmt.Errorf("failed to store webmention: %w", err)
+	}
+	return mention, nil
+}
+
+// FindByID returns a webmention by ID
+func (r *WebmentionRepository) FindByID(ctx context.Context, id uuid.UUID) (*models.Webmention, error) {
+	mention, err := scanWebmention(r.pool.QueryRow(ctx, `SELECT `+webmentionColumns+` FROM webmentions WHERE id = $1`, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrWebmentionNotFound
+		}
+		return nil, fmt.Errorf("failed to find webmention: %w", err)
+	}
+	return mention, nil
+}
+
+// MarkVerified saves what was read from a verified source. New mentions move
+// on to the moderation queue; moderated ones keep their status.
+func (r *WebmentionRepository) MarkVerified(ctx context.Context, mention *models.Webmention) error {
+	query := `
+		UPDATE webmentions
+		SET mention_type = $2, author_name = $3, author_url = $4, author_photo = $5, content = $6,
+		    published_at = $7, verified_at = NOW(), updated_at = NOW(),
+		    status = CASE WHEN status = 'unverified' THEN 'pending' ELSE status END
+		WHERE id = $1
+	`
+	_, err := r.pool.Exec(ctx, query, mention.ID, mention.MentionType, mention.AuthorName, mention.AuthorURL,
+		mention.AuthorPhoto, mention.Content, mention.PublishedAt)
+	if err != nil {
+		return fmt.Errorf("failed to verify webmention: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a webmention whose source no longer links to its target
+func (r *WebmentionRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	if _, err := r.pool.Exec(ctx, `DELETE FROM webmentions WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("failed to delete webmention: %w", err)
+	}
+	return nil
+}
+
+// ListForBlog returns a blog's verified mentions, the ones waiting for
+// moderation first, then newest first
+func (r *WebmentionRepository) ListForBlog(ctx context.Context, blogID uuid.UUID, limit int) ([]*models.Webmention, error) {
+	query := `
+		SELECT ` + webmentionColumns + `
+		FROM webmentions
+		WHERE blog_id = $1 AND status <> 'unverified'
+		ORDER BY status = 'pending' DESC, created_at DESC
+		LIMIT $2
+	`
+	rows, err := r.pool.Query(ctx, query, blogID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webmentions: %w", err)
+	}
+	return scanWebmentions(rows)
+}
+
+// ListApprovedForPost returns the mentions shown under a post, oldest first
+func (r *WebmentionRepository) ListApprovedForPost(ctx context.Context, postID uuid.UUID) ([]*models.Webmention, error) {
+	query := `
+		SELECT ` + webmentionColumns + `
+		FROM webmentions
+		WHERE post_id = $1 AND status = 'approved'
+		ORDER BY COALESCE(published_at, created_at)
+	`
+	rows, err := r.pool.Query(ctx, query, postID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webmentions: %w", err)
+	}
+	return scanWebmentions(rows)
+}
+
+// SetStatus approves or rejects one of a blog's verified mentions
+func (r *WebmentionRepository) SetStatus(ctx context.Context, blogID, id uuid.UUID, status string) error {
+	query := `
+		UPDATE webmentions
+		SET status = $3, updated_at = NOW()
+		WHERE id = $1 AND blog_id = $2 AND status <> 'unverified'
+	`
+	result, err := r.pool.Exec(ctx, query, id, blogID, status)
+	if err != nil {
+		return fmt.Errorf("failed to update webmention: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrWebmentionNotFound
+	}
+	return nil
+}
+
+// DeleteForBlog removes one of a blog's mentions
+func (r *WebmentionRepository) DeleteForBlog(ctx context.Context, blogID, id uuid.UUID) error {
+	result, err := r.pool.Exec(ctx, `DELETE FROM webmentions WHERE id = $1 AND blog_id = $2`, id, blogID)
+	if err != nil {
+		return fmt.Errorf("failed to delete webmention: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrWebmentionNotFound
+	}
+	return nil
+}
+
+func scanWebmention(row pgx.Row) (*models.Webmention, error) {
+	var w models.Webmention
+	err := row.Scan(
+		&w.ID, &w.BlogID, &w.PostID, &w.Source, &w.Target, &w.Status, &w.MentionType, &w.AuthorName,
+		&w.AuthorURL, &w.AuthorPhoto, &w.Content, &w.PublishedAt, &w.VerifiedAt, &w.CreatedAt, &w.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+func scanWebmentions(rows pgx.Rows) ([]*models.Webmention, error) {
+	defer rows.Close()
+	var mentions []*models.Webmention
+	for rows.Next() {
+		mention, err := scanWebmention(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webmention: %w", err)
+		}
+		mentions = append(mentions, mention)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating webmentions: %w", err)
+	}
+	return mentions, nil
+}
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/cassiascheffer/willow_camp/internal/theme"
+	"github.com/labstack/echo/v4"
+)
+
+// HighlightCSS serves the stylesheet for a code highlighting style, e.g.
+// /highlight/github.css. Blogs link it with ?v=<hash>, so a matching version
+// is cached as immutable. It is served on every host so previews in the
+// dashboard can use it too.
+func (h *Handlers) HighlightCSS(c echo.Context) error {
+	style, ok := strings.CutSuffix(c.Param("file"), ".css")
+	if !ok {
+		return echo.NewHTTPError(http.StatusNotFound, "Stylesheet not found")
+	}
+	css, ok := theme.HighlightCSS(style)
+	if !ok {
+		return echo.NewHTTPError(http.StatusNotFound, "Stylesheet not found")
+	}
+
+	version := theme.Version(css)
+	etag := `"` + version + `"`
+
+	header := c.Response().Header()
+	header.Set("ETag", etag)
+	if c.QueryParam("v") == version {
+		header.Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		header.Set("Cache-Control", "public, max-age=300")
+	}
+	if c.Request().Header.Get("If-None-Match") == etag {
+		return c.NoContent(http.StatusNotModified)
+	}
+
+	return c.Blob(http.StatusOK, "text/css; charset=utf-8", []byte(css))
+}
@@ -0,0 +1,10 @@
+// Package templates embeds the shared HTML templates (home, docs, terms and
+// login) so the binary does not depend on its working directory.
+package templates
+
+import "embed"
+
+// FS holds the shared templates
+//
+//go:embed *.html
+var FS embed.FS
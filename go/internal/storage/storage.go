@@ -0,0 +1,60 @@
+// Package storage abstracts where uploaded files live, so the same code can
+// write to local disk in development and an S3-compatible bucket in production.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+var ErrNotFound = errors.New("object not found")
+
+// Storage stores opaque objects by key. Keys use forward slashes and never start with one.
+type Storage interface {
+	// Put writes an object, replacing any existing object with the same key
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Open returns a reader for an object, or ErrNotFound
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes an object. Deleting a missing object is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// NewFromEnv builds the storage backend selected by STORAGE_BACKEND ("local" or "s3")
+func NewFromEnv() (Storage, error) {
+	switch backend := strings.ToLower(os.Getenv("STORAGE_BACKEND")); backend {
+	case "", "local":
+		dir := os.Getenv("UPLOADS_DIR")
+		if dir == "" {
+			dir = "storage/uploads"
+		}
+		return NewLocal(dir)
+	case "s3":
+		return NewS3(S3Config{
+			Endpoint:        os.Getenv("S3_ENDPOINT"),
+			Region:          os.Getenv("S3_REGION"),
+			Bucket:          os.Getenv("S3_BUCKET"),
+			AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+			UseSSL:          os.Getenv("S3_USE_SSL") != "false",
+		})
+	default:
+		return nil, fmt.Errorf("unknown STORAGE_BACKEND %q", backend)
+	}
+}
+
+// validKey rejects keys that could escape the storage root
+func validKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return fmt.Errorf("invalid storage key %q", key)
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return fmt.Errorf("invalid storage key %q", key)
+		}
+	}
+	return nil
+}
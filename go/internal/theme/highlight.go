@@ -0,0 +1,116 @@
+package theme
+
+import (
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/cassiascheffer/willow_camp/internal/models"
+)
+
+// Default highlight styles for light and dark DaisyUI themes
+const (
+	DefaultLightHighlight = "github"
+	DefaultDarkHighlight  = "monokai"
+)
+
+var ErrUnknownHighlight = errors.New("unknown code highlighting style")
+
+// HighlightStyles are the chroma styles a blog can pick for code blocks
+var HighlightStyles = []Option{
+	{Key: "github", Label: "GitHub"},
+	{Key: "xcode", Label: "Xcode"},
+	{Key: "solarized-light", Label: "Solarized Light"},
+	{Key: "monokailight", Label: "Monokai Light"},
+	{Key: "friendly", Label: "Friendly"},
+	{Key: "tango", Label: "Tango"},
+	{Key: "monokai", Label: "Monokai"},
+	{Key: "dracula", Label: "Dracula"},
+	{Key: "nord", Label: "Nord"},
+	{Key: "solarized-dark", Label: "Solarized Dark"},
+	{Key: "gruvbox", Label: "Gruvbox"},
+	{Key: "doom-one", Label: "Doom One"},
+}
+
+// darkThemes are the DaisyUI themes with a dark background
+var darkThemes = map[string]bool{
+	"dark": true, "abyss": true, "black": true, "business": true, "coffee": true,
+	"dim": true, "dracula": true, "forest": true, "halloween": true, "luxury": true,
+	"night": true, "sunset": true, "synthwave": true,
+}
+
+// ValidateHighlight checks that style is blank or a known highlight style
+func ValidateHighlight(style string) error {
+	if _, ok := lookup(HighlightStyles, style); style != "" && !ok {
+		return ErrUnknownHighlight
+	}
+	return nil
+}
+
+// Highlight returns the blog's code highlighting style: its own choice, or a
+// light or dark default that suits its DaisyUI theme
+func Highlight(blog *models.Blog) string {
+	if _, ok := lookup(HighlightStyles, blog.HighlightStyle); ok {
+		return blog.HighlightStyle
+	}
+	if darkThemes[blog.Theme] {
+		return DefaultDarkHighlight
+	}
+	return DefaultLightHighlight
+}
+
+var (
+	highlightMu  sync.Mutex
+	highlightCSS = map[string]string{}
+)
+
+// HighlightCSS returns the stylesheet for the classes the markdown renderer
+// puts on highlighted code, or false for an unknown style. Stylesheets are
+// generated once per style.
+func HighlightCSS(style string) (string, bool) {
+	if _, ok := lookup(HighlightStyles, style); !ok {
+		return "", false
+	}
+
+	highlightMu.Lock()
+	defer highlightMu.Unlock()
+	if css, ok := highlightCSS[style]; ok {
+		return css, true
+	}
+
+	chromaStyle := styles.Get(style)
+	var b strings.Builder
+	formatter := html.New(html.WithClasses(true))
+	if err := formatter.WriteCSS(&b, chromaStyle); err != nil {
+		return "", false
+	}
+
+	var css strings.Builder
+	for _, line := range strings.SplitAfter(b.String(), "\n") {
+		// .bg is only used by standalone chroma pages and is too generic a
+		// class name to style on a blog
+		if !strings.HasPrefix(line, "/* Background */") {
+			css.WriteString(line)
+		}
+	}
+	// Styles that leave plain text uncolored would otherwise get the
+	// typography plugin's light code color on their light background
+	if text := chromaStyle.Get(chroma.Text); text.Colour.IsSet() {
+		css.WriteString(".chroma { color: " + text.Colour.String() + "; }\n")
+	} else if bg := chromaStyle.Get(chroma.Background); bg.Background.IsSet() {
+		css.WriteString(".chroma { color: " + contentColor(bg.Background.String()) + "; }\n")
+	}
+
+	highlightCSS[style] = css.String()
+	return highlightCSS[style], true
+}
+
+// HighlightURL returns the versioned URL of the blog's highlight stylesheet
+func HighlightURL(blog *models.Blog) string {
+	style := Highlight(blog)
+	css, _ := HighlightCSS(style)
+	return "/highlight/" + style + ".css?v=" + Version(css)
+}
@@ -0,0 +1,79 @@
+package theme
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	cssComment = regexp.MustCompile(`(?s)/\*.*?\*/`)
+	// cssEscape matches a backslash escape such as \72 or \000072
+	cssEscape = regexp.MustCompile(`\\([0-9a-fA-F]{1,6})[ \t\n\f\r]?`)
+	cssImport = regexp.MustCompile(`(?i)@import[^;]*;?`)
+	cssURL    = regexp.MustCompile(`(?i)url\(\s*(?:"([^"]*)"|'([^']*)'|([^)'"]*?))\s*\)`)
+	// cssBehavior matches old IE and Firefox properties that run script
+	cssBehavior   = regexp.MustCompile(`(?i)(?:behavior|-moz-binding)\s*:[^;}]*;?`)
+	cssExpression = regexp.MustCompile(`(?i)expression\s*\(`)
+	// cssLoader matches url( left over after the checked ones are set aside,
+	// e.g. an unterminated url(, and image-set(, whose strings are also URLs
+	cssLoader      = regexp.MustCompile(`(?i)(?:-webkit-)?image-set\(|url\(`)
+	cssPlaceholder = regexp.MustCompile("\x00([0-9]+)\x00")
+)
+
+// SanitizeCSS makes custom CSS safe to serve and to inline in a <style>
+// element. It removes comments, @import rules, script-running properties and
+// expression(), and any url() that isn't https, same-site or a data: image.
+// Escapes that spell letters are decoded first so they can't hide keywords.
+func SanitizeCSS(css string) string {
+	css = cssComment.ReplaceAllString(css, "")
+	css = cssEscape.ReplaceAllStringFunc(css, func(escape string) string {
+		code, err := strconv.ParseUint(cssEscape.FindStringSubmatch(escape)[1], 16, 32)
+		if err == nil && (code >= 'a' && code <= 'z' || code >= 'A' && code <= 'Z') {
+			return string(rune(code))
+		}
+		return escape
+	})
+	css = cssImport.ReplaceAllString(css, "")
+	css = cssBehavior.ReplaceAllString(css, "")
+	css = cssExpression.ReplaceAllString(css, "(")
+	css = strings.ReplaceAll(css, "\x00", "")
+
+	// Allowed url()s are set aside so anything still loading a resource
+	// afterwards can be broken without touching them
+	var kept []string
+	css = cssURL.ReplaceAllStringFunc(css, func(match string) string {
+		m := cssURL.FindStringSubmatch(match)
+		if !allowedURL(m[1] + m[2] + m[3]) {
+			return "none"
+		}
+		kept = append(kept, match)
+		return "\x00" + strconv.Itoa(len(kept)-1) + "\x00"
+	})
+	css = cssLoader.ReplaceAllString(css, "none(")
+	css = cssPlaceholder.ReplaceAllStringFunc(css, func(placeholder string) string {
+		i, _ := strconv.Atoi(cssPlaceholder.FindStringSubmatch(placeholder)[1])
+		return kept[i]
+	})
+	// A literal "<" could close the <style> element in previews; \3c is the
+	// same character to CSS
+	css = strings.ReplaceAll(css, "<", `\3c `)
+	return strings.TrimSpace(css)
+}
+
+// allowedURL reports whether a url() target can't run script or leak readers
+// to plain-http hosts
+func allowedURL(target string) bool {
+	lower := strings.ToLower(strings.TrimSpace(target))
+	switch {
+	case strings.HasPrefix(lower, "https://"):
+		return true
+	case strings.HasPrefix(lower, "data:image/"):
+		return true
+	case strings.HasPrefix(lower, "/") && !strings.HasPrefix(lower, "//"):
+		return true
+	case strings.HasPrefix(lower, "#"):
+		return true
+	}
+	return false
+}
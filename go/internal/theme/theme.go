@@ -0,0 +1,164 @@
+// Package theme builds the per-blog stylesheet served at /theme.css: a
+// blog's overrides on top of its DaisyUI preset, followed by its sanitized
+// custom CSS.
+package theme
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/cassiascheffer/willow_camp/internal/models"
+)
+
+// MaxCustomCSSBytes is the largest custom stylesheet a blog may save
+const MaxCustomCSSBytes = 20000
+
+var (
+	ErrInvalidColor      = errors.New("colors must be hex values like #1d4ed8")
+	ErrUnknownFont       = errors.New("unknown font")
+	ErrUnknownWidth      = errors.New("unknown content width")
+	ErrCustomCSSTooLarge = fmt.Errorf("custom CSS must be at most %d bytes", MaxCustomCSSBytes)
+	hexColorPattern      = regexp.MustCompile(`^#(?:[0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+)
+
+// Option is a choice offered in the settings form
+type Option struct {
+	Key   string
+	Label string
+	Value string
+}
+
+// Fonts are the font stacks a blog can pick. They only use fonts installed
+// on the reader's device, so no font files are downloaded.
+var Fonts = []Option{
+	{Key: "mono", Label: "Monospace", Value: `ui-monospace, SFMono-Regular, Menlo, Consolas, "Liberation Mono", monospace`},
+	{Key: "sans", Label: "Sans-serif", Value: `ui-sans-serif, system-ui, -apple-system, "Segoe UI", Roboto, "Helvetica Neue", Arial, sans-serif`},
+	{Key: "serif", Label: "Serif", Value: `ui-serif, Charter, "Bitstream Charter", "Sitka Text", Cambria, Georgia, serif`},
+	{Key: "humanist", Label: "Humanist", Value: `Seravek, "Gill Sans Nova", Ubuntu, Calibri, "DejaVu Sans", source-sans-pro, sans-serif`},
+	{Key: "rounded", Label: "Rounded", Value: `ui-rounded, "Hiragino Maru Gothic ProN", Quicksand, Comfortaa, Manjari, "Arial Rounded MT", Calibri, sans-serif`},
+}
+
+// Widths are the content widths a blog can pick. The layout's default is 48rem.
+var Widths = []Option{
+	{Key: "narrow", Label: "Narrow", Value: "40rem"},
+	{Key: "wide", Label: "Wide", Value: "64rem"},
+	{Key: "full", Label: "Full width", Value: "100%"},
+}
+
+func lookup(options []Option, key string) (Option, bool) {
+	for _, o := range options {
+		if o.Key == key {
+			return o, true
+		}
+	}
+	return Option{}, false
+}
+
+// Validate checks that every override is a known option or a hex color
+func Validate(o models.ThemeOverrides) error {
+	for _, color := range []string{o.PrimaryColor, o.AccentColor} {
+		if color != "" && !hexColorPattern.MatchString(color) {
+			return ErrInvalidColor
+		}
+	}
+	for _, font := range []string{o.BodyFont, o.HeadingFont} {
+		if _, ok := lookup(Fonts, font); font != "" && !ok {
+			return fmt.Errorf("%w: %s", ErrUnknownFont, font)
+		}
+	}
+	if _, ok := lookup(Widths, o.ContentWidth); o.ContentWidth != "" && !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownWidth, o.ContentWidth)
+	}
+	return nil
+}
+
+// Stylesheet returns the CSS for the blog's overrides and custom CSS, or ""
+// if the blog customizes nothing. Invalid overrides are skipped.
+func Stylesheet(blog *models.Blog) string {
+	var b strings.Builder
+	o := blog.ThemeOverrides
+
+	var vars []string
+	if hexColorPattern.MatchString(o.PrimaryColor) {
+		vars = append(vars, "--color-primary: "+o.PrimaryColor, "--color-primary-content: "+contentColor(o.PrimaryColor))
+	}
+	if hexColorPattern.MatchString(o.AccentColor) {
+		vars = append(vars, "--color-accent: "+o.AccentColor, "--color-accent-content: "+contentColor(o.AccentColor))
+	}
+	if len(vars) > 0 {
+		// :root[data-theme] outranks DaisyUI's [data-theme=name] selectors
+		b.WriteString(":root[data-theme] {\n")
+		for _, v := range vars {
+			b.WriteString("  " + v + ";\n")
+		}
+		b.WriteString("}\n")
+	}
+	if font, ok := lookup(Fonts, o.BodyFont); ok {
+		b.WriteString("body {\n  font-family: " + font.Value + ";\n}\n")
+	}
+	if font, ok := lookup(Fonts, o.HeadingFont); ok {
+		b.WriteString("h1, h2, h3, h4, h5, h6 {\n  font-family: " + font.Value + ";\n}\n")
+	}
+	if width, ok := lookup(Widths, o.ContentWidth); ok {
+		b.WriteString("#main-content {\n  max-width: " + width.Value + ";\n}\n")
+	}
+
+	if blog.CustomCSS != nil && strings.TrimSpace(*blog.CustomCSS) != "" {
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString("/* Custom CSS */\n")
+		b.WriteString(SanitizeCSS(*blog.CustomCSS))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// Version is a short hash of a stylesheet, used to bust caches when it changes
+func Version(css string) string {
+	sum := sha256.Sum256([]byte(css))
+	return hex.EncodeToString(sum[:])[:10]
+}
+
+// URL returns the versioned /theme.css URL for the blog, or "" if the blog
+// customizes nothing
+func URL(blog *models.Blog) string {
+	css := Stylesheet(blog)
+	if css == "" {
+		return ""
+	}
+	return "/theme.css?v=" + Version(css)
+}
+
+// contentColor picks black or white text, whichever contrasts more with the
+// hex background color
+func contentColor(color string) string {
+	digits := strings.TrimPrefix(color, "#")
+	if len(digits) == 3 {
+		digits = string([]byte{digits[0], digits[0], digits[1], digits[1], digits[2], digits[2]})
+	}
+	var channels [3]float64
+	for i := range channels {
+		v, _ := strconv.ParseUint(digits[i*2:i*2+2], 16, 8)
+		c := float64(v) / 255
+		// sRGB to linear light, as in the WCAG relative luminance formula
+		if c <= 0.03928 {
+			c /= 12.92
+		} else {
+			c = math.Pow((c+0.055)/1.055, 2.4)
+		}
+		channels[i] = c
+	}
+	luminance := 0.2126*channels[0] + 0.7152*channels[1] + 0.0722*channels[2]
+	// Contrast with white is 1.05/(L+0.05); with black it is (L+0.05)/0.05
+	if 1.05/(luminance+0.05) >= (luminance+0.05)/0.05 {
+		return "#ffffff"
+	}
+	return "#000000"
+}
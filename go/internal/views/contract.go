@@ -0,0 +1,204 @@
+package views
+
+import (
+	"html/template"
+	"sort"
+	"time"
+
+	"github.com/cassiascheffer/willow_camp/internal/assets"
+	"github.com/cassiascheffer/willow_camp/internal/markdown"
+	"github.com/cassiascheffer/willow_camp/internal/models"
+	"github.com/google/uuid"
+)
+
+// BlogContractVersion is the version of the blog data contract below. Theme
+// packs declare the version they were written against in theme.json and are
+// rejected when it doesn't match.
+const BlogContractVersion = 1
+
+// The blog data contract lists the keys the blog handlers put in the data map
+// of each blog page. Blog templates, including theme packs, may only use these
+// keys; packs are rendered against sample data at load time to check.
+//
+// Every page gets the layout keys:
+//
+//	Blog                 *models.Blog     the blog being rendered
+//	Title                string           the page <title>
+//	BlogTitle            string           the blog's display name
+//	Favicon              assets.Favicon   OpenMoji favicon URLs
+//	Pages                []*models.Post   published pages, for navigation
+//	MetaDescription      string           optional meta description
+//	OGTitle              string           Open Graph title
+//	OGDescription        string           Open Graph description
+//	OGType               string           "website" or "article"
+//	OGImage              string           optional Open Graph image URL
+//	CurrentURL           string           absolute URL of the page
+//	ThemeStylesheet      string           optional versioned /theme.css URL
+//	HighlightStylesheet  string           optional versioned /highlight/<style>.css URL
+//	ThemeCSS             template.CSS     optional inline theme CSS (previews)
+//	ArticlePublishedTime string           optional, post pages only
+//	ArticleAuthor        string           optional, post pages only
+//	ArticleTags          []string         optional, post pages only
+//
+// Optional keys may be missing at render time, so templates should only use
+// them inside {{if}} or {{with}}. Each page adds its own keys:
+//
+//	archive_index.html    Years []models.ArchiveYear (newest first, each with its months)
+//	archive_show.html     Posts []*models.Post; Year int; Month time.Month (0 for a
+//	                      whole year); Months []models.ArchiveMonth (the year's);
+//	                      CurrentPage, TotalPages int
+//	index.html            FeaturedPosts, Posts []*models.Post; CurrentPage, TotalPages int
+//	post_show.html        Post *models.Post; RenderedContent, PostFooter template.HTML;
+//	                      TOC markdown.TOC; Tags []models.Tag; AuthorName string;
+//	                      Mentions []*models.Webmention (approved, oldest first);
+//	                      CommentsEnabled bool; Comments []*models.Comment
+//	                      (approved, oldest first; render Body with commentHTML);
+//	                      SeriesPart *models.SeriesPart (nil outside a series);
+//	                      PreviousPost, NextPost *models.Post (the published posts
+//	                      before and after it, nil at either end); RelatedPosts
+//	                      []*models.Post (sharing the most tags first)
+//	post_locked.html      Post *models.Post (without its content); Message string
+//	                      (optional error); EmailEnabled bool. Shown in place of
+//	                      post_show.html for password and members-only posts
+//	series_show.html      Series *models.Series; Posts []*models.Post (the parts, in order)
+//	tag_show.html         Posts []*models.Post; TagName string; CurrentPage, TotalPages int
+//	tags_index.html       Tags []models.Tag
+//	subscribe.html        RSSFeedURL, AtomFeedURL, JSONFeedURL string; EmailEnabled bool
+//	subscribe_status.html Heading, Message string
+//	unsubscribe.html      Token string
+//
+// Listed posts and Post on post pages have WordCount and ReadingTime set.
+var blogPageKeys = map[string][]string{
+	"archive_index.html":    {"Years"},
+	"archive_show.html":     {"Posts", "Year", "Month", "Months", "CurrentPage", "TotalPages"},
+	"index.html":            {"FeaturedPosts", "Posts", "CurrentPage", "TotalPages"},
+	"post_show.html":        {"Post", "RenderedContent", "TOC", "PostFooter", "Tags", "AuthorName", "Mentions", "Comments", "CommentsEnabled", "SeriesPart", "PreviousPost", "NextPost", "RelatedPosts"},
+	"post_locked.html":      {"Post", "Message", "EmailEnabled"},
+	"series_show.html":      {"Series", "Posts"},
+	"tag_show.html":         {"Posts", "TagName", "CurrentPage", "TotalPages"},
+	"tags_index.html":       {"Tags"},
+	"subscribe.html":        {"RSSFeedURL", "AtomFeedURL", "JSONFeedURL", "EmailEnabled"},
+	"subscribe_status.html": {"Heading", "Message"},
+	"unsubscribe.html":      {"Token"},
+}
+
+// BlogPages lists the pages covered by the blog data contract
+func BlogPages() []string {
+	pages := make([]string, 0, len(blogPageKeys))
+	for page := range blogPageKeys {
+		pages = append(pages, page)
+	}
+	sort.Strings(pages)
+	return pages
+}
+
+// SampleBlogData returns a data map for the blog page with every contract key
+// set, including the optional ones
+func SampleBlogData(page string) map[string]interface{} {
+	str := func(s string) *string { return &s }
+	yes := true
+	published := time.Date(2026, time.January, 2, 15, 4, 5, 0, time.UTC)
+	tags := []models.Tag{{ID: uuid.New(), Name: "Camping", Slug: str("camping"), TaggingsCount: 2}}
+	post := &models.Post{
+		ID:              uuid.New(),
+		Title:           str("Sample post"),
+		Slug:            str("sample-post"),
+		MetaDescription: str("A sample post"),
+		Published:       &yes,
+		PublishedAt:     &published,
+		Type:            str("Post"),
+		Tags:            tags,
+		WordCount:       420,
+		ReadingTime:     3,
+	}
+	mentions := []*models.Webmention{{
+		ID:          uuid.New(),
+		Source:      "https://elsewhere.example/replies/1",
+		Status:      models.WebmentionApproved,
+		MentionType: "reply",
+		AuthorName:  "A Reader",
+		AuthorURL:   "https://elsewhere.example",
+		AuthorPhoto: "https://elsewhere.example/photo.jpg",
+		Content:     "Great trip report!",
+		PublishedAt: &published,
+	}}
+	comments := []*models.Comment{{
+		ID:         uuid.New(),
+		AuthorName: "Another Reader",
+		AuthorURL:  "https://reader.example",
+		Body:       "Which trail was this? *Looks lovely.*",
+		Status:     models.CommentApproved,
+		CreatedAt:  published,
+	}}
+	months := []models.ArchiveMonth{{Year: 2026, Month: time.February, Count: 1}, {Year: 2026, Month: time.January, Count: 2}}
+	series := &models.Series{ID: uuid.New(), Title: "Sample series", Slug: "sample-series"}
+	otherPost := &models.Post{ID: uuid.New(), Title: str("Another sample post"), Slug: str("another-sample-post"), Published: &yes, PublishedAt: &published}
+	blog := &models.Blog{
+		ID:              uuid.New(),
+		Subdomain:       str("sample"),
+		Title:           str("Sample blog"),
+		MetaDescription: str("A sample blog"),
+		FaviconEmoji:    str(assets.DefaultEmoji),
+		Theme:           "light",
+	}
+
+	data := map[string]interface{}{
+		"Blog":                 blog,
+		"Title":                "Sample post | Sample blog",
+		"BlogTitle":            "Sample blog",
+		"Favicon":              assets.EmojiFavicon(assets.DefaultEmoji),
+		"Pages":                []*models.Post{{ID: uuid.New(), Title: str("About"), Slug: str("about"), Type: str("Page")}},
+		"MetaDescription":      "A sample blog",
+		"OGTitle":              "Sample post",
+		"OGDescription":        "A sample post",
+		"OGType":               "article",
+		"OGImage":              "https://sample.willow.camp/media/image.png",
+		"CurrentURL":           "https://sample.willow.camp/sample-post",
+		"ThemeStylesheet":      "/theme.css?v=0123456789",
+		"HighlightStylesheet":  "/highlight/github.css?v=0123456789",
+		"ThemeCSS":             template.CSS("body { color: inherit; }"),
+		"ArticlePublishedTime": published.Format(time.RFC3339),
+		"ArticleAuthor":        "Sample Author",
+		"ArticleTags":          []string{"Camping"},
+	}
+
+	sample := map[string]interface{}{
+		"FeaturedPosts":   []*models.Post{post},
+		"Posts":           []*models.Post{post},
+		"CurrentPage":     2,
+		"TotalPages":      3,
+		"Post":            post,
+		"RenderedContent": template.HTML(`<h2 id="setup">Setup</h2><h3 id="gear">Gear</h3><h2 id="trail">Trail</h2>`),
+		"TOC": markdown.TOC{
+			{Level: 2, ID: "setup", Text: "Setup", Children: markdown.TOC{{Level: 3, ID: "gear", Text: "Gear"}}},
+			{Level: 2, ID: "trail", Text: "Trail"},
+		},
+		"PostFooter":      template.HTML("<p>Thanks for reading</p>"),
+		"Tags":            tags,
+		"AuthorName":      "Sample Author",
+		"Mentions":        mentions,
+		"Comments":        comments,
+		"CommentsEnabled": true,
+		"SeriesPart":      &models.SeriesPart{Series: series, Part: 2, Total: 3, Previous: otherPost, Next: otherPost},
+		"Series":          series,
+		"PreviousPost":    otherPost,
+		"NextPost":        otherPost,
+		"RelatedPosts":    []*models.Post{otherPost},
+		"TagName":         "Camping",
+		"Years":           models.GroupArchiveMonths(months),
+		"Year":            2026,
+		"Month":           time.January,
+		"Months":          months,
+		"RSSFeedURL":      "https://sample.willow.camp/feed.rss",
+		"AtomFeedURL":     "https://sample.willow.camp/feed.atom",
+		"JSONFeedURL":     "https://sample.willow.camp/feed.json",
+		"EmailEnabled":    true,
+		"Heading":         "Subscribed",
+		"Message":         "Thanks for subscribing.",
+		"Token":           "sample-token",
+	}
+	for _, key := range blogPageKeys[page] {
+		data[key] = sample[key]
+	}
+	return data
+}
@@ -0,0 +1,101 @@
+package views
+
+import (
+	"encoding/json"
+	"html/template"
+	"strings"
+	"time"
+
+	"github.com/cassiascheffer/willow_camp/internal/assets"
+	"github.com/cassiascheffer/willow_camp/internal/helpers"
+)
+
+// Funcs returns the functions available to every template
+func Funcs() template.FuncMap {
+	return template.FuncMap{
+		"add": func(a, b int) int { return a + b },
+		"sub": func(a, b int) int { return a - b },
+		"heroicon": func(name string, class string) template.HTML {
+			return helpers.Icon("24/outline/"+name, class)
+		},
+		"heroiconMini": func(name string, class string) template.HTML {
+			return helpers.Icon("20/solid/"+name, class)
+		},
+		"blogURL": func(subdomain string, baseDomain string) string {
+			// Use http:// for localhost, https:// for everything else
+			protocol := "https://"
+			if strings.Contains(baseDomain, "localhost") {
+				protocol = "http://"
+			}
+			return protocol + subdomain + "." + baseDomain + "/"
+		},
+		"postURL": func(subdomain string, slug string, baseDomain string) string {
+			// Use http:// for localhost, https:// for everything else
+			protocol := "https://"
+			if strings.Contains(baseDomain, "localhost") {
+				protocol = "http://"
+			}
+			return protocol + subdomain + "." + baseDomain + "/" + slug
+		},
+		"hasText": func(s *string) bool {
+			return s != nil && *s != ""
+		},
+		"allThemes": func() []string {
+			return helpers.AllThemes()
+		},
+		"deref": func(ptr interface{}) interface{} {
+			if ptr == nil {
+				return nil
+			}
+			switch v := ptr.(type) {
+			case *string:
+				if v == nil {
+					return ""
+				}
+				return *v
+			case *bool:
+				if v == nil {
+					return false
+				}
+				return *v
+			case *int:
+				if v == nil {
+					return 0
+				}
+				return *v
+			default:
+				return ptr
+			}
+		},
+		"formatDateTime": func(t *time.Time) string {
+			if t == nil {
+				return ""
+			}
+			// Format as "2006-01-02T15:04" for datetime-local input
+			return t.Format("2006-01-02T15:04")
+		},
+		"formatDate": func(t interface{}) string {
+			switch v := t.(type) {
+			case time.Time:
+				return v.Format("Jan 02, 2006")
+			case *time.Time:
+				if v == nil {
+					return ""
+				}
+				return v.Format("Jan 02, 2006")
+			default:
+				return ""
+			}
+		},
+		"toJSON": func(v interface{}) (template.JS, error) {
+			bytes, err := json.Marshal(v)
+			if err != nil {
+				return "", err
+			}
+			return template.JS(bytes), nil
+		},
+		"asset": assets.URL,
+		// commentHTML renders a reader's comment, which is markdown
+		"commentHTML": helpers.RenderCommentHTML,
+	}
+}
@@ -0,0 +1,192 @@
+package views
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"github.com/cassiascheffer/willow_camp/themes"
+)
+
+// packSetPrefix names the template set of a theme pack, e.g. "blog@paper"
+const packSetPrefix = "blog@"
+
+// packManifest is the file that marks a directory as a theme pack
+const packManifest = "theme.json"
+
+var packNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// Pack describes a theme pack. Name is the pack's directory; the rest comes
+// from its theme.json manifest.
+type Pack struct {
+	Name        string `json:"-"`
+	Label       string `json:"label"`
+	Description string `json:"description"`
+	Author      string `json:"author"`
+	// Contract is the BlogContractVersion the pack was written against
+	Contract int `json:"contract"`
+}
+
+// BlogTemplate returns the registry name of a blog page in the given theme
+// pack, or in the default blog templates when pack is ""
+func BlogTemplate(pack, page string) string {
+	if pack == "" {
+		return "blog/" + page
+	}
+	return packSetPrefix + pack + "/" + page
+}
+
+// Packs lists the theme packs that loaded successfully, sorted by name
+func (r *Registry) Packs() []Pack {
+	packs := make([]Pack, 0, len(r.packs))
+	for _, pack := range r.packs {
+		packs = append(packs, pack)
+	}
+	sort.Slice(packs, func(i, j int) bool { return packs[i].Name < packs[j].Name })
+	return packs
+}
+
+// HasPack reports whether a theme pack with the given name is loaded
+func (r *Registry) HasPack(name string) bool {
+	_, ok := r.packs[name]
+	return ok
+}
+
+// loadPacks adds the bundled theme packs and those in cfg.PacksDir, which
+// replace bundled packs of the same name. Broken packs are logged and skipped
+// so one bad pack can't stop the server from starting.
+func (r *Registry) loadPacks(cfg Config) {
+	bundled, reload := fs.FS(themes.FS), false
+	if cfg.Reload {
+		dir := filepath.Join(cfg.Root, "themes")
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			bundled, reload = os.DirFS(dir), true
+		}
+	}
+	r.loadPacksFrom(bundled, reload)
+	if cfg.PacksDir != "" {
+		r.loadPacksFrom(os.DirFS(cfg.PacksDir), cfg.Reload)
+	}
+}
+
+// loadPacksFrom loads every directory of fsys that has a manifest
+func (r *Registry) loadPacksFrom(fsys fs.FS, reload bool) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		r.logger.Error("Failed to list theme packs", "error", err)
+		return
+	}
+
+	base := r.sets["blog"]
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		name := entry.Name()
+		if _, err := fs.Stat(fsys, path.Join(name, packManifest)); err != nil {
+			continue
+		}
+
+		pack, packFS, err := readPack(fsys, name)
+		if err != nil {
+			r.logger.Error("Skipping invalid theme pack", "pack", name, "error", err)
+			continue
+		}
+		s := &set{
+			spec:   setSpec{name: packSetPrefix + name, layout: base.spec.layout, contract: true},
+			fsys:   overlayFS{top: packFS, base: base.fsys},
+			reload: reload || base.reload,
+		}
+		if err := s.parse(); err != nil {
+			r.logger.Error("Skipping invalid theme pack", "pack", name, "error", err)
+			continue
+		}
+		r.sets[s.spec.name] = s
+		r.packs[name] = pack
+	}
+}
+
+// readPack reads and checks a pack's manifest and the templates it provides
+func readPack(fsys fs.FS, name string) (Pack, fs.FS, error) {
+	var pack Pack
+	if !packNamePattern.MatchString(name) {
+		return pack, nil, errors.New("pack names may only use lowercase letters, digits and hyphens")
+	}
+	packFS, err := fs.Sub(fsys, name)
+	if err != nil {
+		return pack, nil, err
+	}
+
+	manifest, err := fs.ReadFile(packFS, packManifest)
+	if err != nil {
+		return pack, nil, err
+	}
+	if err := json.Unmarshal(manifest, &pack); err != nil {
+		return pack, nil, fmt.Errorf("invalid %s: %w", packManifest, err)
+	}
+	pack.Name = name
+	if pack.Label == "" {
+		pack.Label = name
+	}
+	if pack.Contract != BlogContractVersion {
+		return pack, nil, fmt.Errorf("pack targets data contract %d, expected %d", pack.Contract, BlogContractVersion)
+	}
+
+	files, err := fs.Glob(packFS, "*.html")
+	if err != nil {
+		return pack, nil, err
+	}
+	if len(files) == 0 {
+		return pack, nil, errors.New("pack has no templates")
+	}
+	for _, file := range files {
+		if _, ok := blogPageKeys[file]; !ok && file != "layout.html" {
+			return pack, nil, fmt.Errorf("unknown template %s", file)
+		}
+	}
+	return pack, packFS, nil
+}
+
+// overlayFS serves a pack's templates, falling back to the default blog
+// templates for the pages the pack leaves out
+type overlayFS struct {
+	top  fs.FS
+	base fs.FS
+}
+
+func (o overlayFS) Open(name string) (fs.File, error) {
+	if name != "." {
+		if f, err := o.top.Open(name); err == nil {
+			return f, nil
+		}
+	}
+	return o.base.Open(name)
+}
+
+// ReadDir merges both directories so globbing and modification times see
+// every template
+func (o overlayFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	merged := map[string]fs.DirEntry{}
+	for _, fsys := range []fs.FS{o.base, o.top} {
+		entries, err := fs.ReadDir(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			merged[entry.Name()] = entry
+		}
+	}
+
+	entries := make([]fs.DirEntry, 0, len(merged))
+	for _, entry := range merged {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
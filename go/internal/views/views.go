@@ -0,0 +1,289 @@
+// Package views parses every HTML template once at startup from the embedded
+// template directories and renders them by name, e.g. "blog/post_show.html".
+// In development the templates are read from disk instead and re-parsed
+// whenever a file changes.
+//
+// Blogs can also pick a theme pack, which replaces some or all of the blog
+// templates. Packs are checked against the blog data contract when they are
+// loaded, and a pack that fails to render falls back to the default blog
+// templates.
+package views
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	blogtemplates "github.com/cassiascheffer/willow_camp/internal/blog/templates"
+	dashboardtemplates "github.com/cassiascheffer/willow_camp/internal/dashboard/templates"
+	"github.com/cassiascheffer/willow_camp/internal/logging"
+	newslettertemplates "github.com/cassiascheffer/willow_camp/internal/newsletter/templates"
+	sharedtemplates "github.com/cassiascheffer/willow_camp/internal/shared/templates"
+	"github.com/labstack/echo/v4"
+)
+
+// Config controls where templates are loaded from
+type Config struct {
+	// Reload reads templates from disk and re-parses them when they change
+	Reload bool
+	// Root is the directory the template paths are relative to when reloading
+	Root string
+	// PacksDir is an optional directory of extra theme packs
+	PacksDir string
+	// Logger reports theme packs that are skipped or fall back
+	Logger *logging.Logger
+}
+
+// ConfigFromEnv enables reloading when GO_ENV=development. TEMPLATES_ROOT
+// points at the go/ directory when the server is started from elsewhere, and
+// THEME_PACKS_DIR adds theme packs to the bundled ones.
+func ConfigFromEnv() Config {
+	root := os.Getenv("TEMPLATES_ROOT")
+	if root == "" {
+		root = "."
+	}
+	return Config{
+		Reload:   os.Getenv("GO_ENV") == "development",
+		Root:     root,
+		PacksDir: os.Getenv("THEME_PACKS_DIR"),
+	}
+}
+
+// setSpec describes a group of pages rendered inside one layout
+type setSpec struct {
+	name   string
+	dir    string
+	fsys   fs.FS
+	layout string
+	// pages limits the set to the given files; nil means every non-layout template
+	pages []string
+	// contract renders every page against SampleBlogData when parsing
+	contract bool
+}
+
+var specs = []setSpec{
+	{name: "blog", dir: "internal/blog/templates", fsys: blogtemplates.FS, layout: "layout.html", contract: true},
+	{name: "dashboard", dir: "internal/dashboard/templates", fsys: dashboardtemplates.FS, layout: "layout.html"},
+	{name: "application", dir: "internal/shared/templates", fsys: sharedtemplates.FS, layout: "application_layout.html",
+		pages: []string{"home.html", "docs.html", "terms.html"}},
+	{name: "simple", dir: "internal/shared/templates", fsys: sharedtemplates.FS, layout: "simple_layout.html",
+		pages: []string{"login.html"}},
+	{name: "email", dir: "internal/newsletter/templates", fsys: newslettertemplates.FS, layout: "layout.html"},
+}
+
+// Registry holds every parsed template set
+type Registry struct {
+	sets   map[string]*set
+	packs  map[string]Pack
+	logger *logging.Logger
+}
+
+// Load parses all templates, failing if any of them is invalid. Invalid theme
+// packs are logged and skipped instead.
+func Load(cfg Config) (*Registry, error) {
+	r := &Registry{sets: map[string]*set{}, packs: map[string]Pack{}, logger: cfg.Logger}
+	if r.logger == nil {
+		r.logger = logging.NewLogger()
+	}
+	for _, spec := range specs {
+		s := &set{spec: spec, fsys: spec.fsys}
+		if cfg.Reload {
+			dir := filepath.Join(cfg.Root, spec.dir)
+			if info, err := os.Stat(dir); err == nil && info.IsDir() {
+				s.fsys = os.DirFS(dir)
+				s.reload = true
+			}
+		}
+		if err := s.parse(); err != nil {
+			return nil, err
+		}
+		r.sets[spec.name] = s
+	}
+	r.loadPacks(cfg)
+	return r, nil
+}
+
+// Names lists every renderable template, e.g. "dashboard/jobs.html"
+func (r *Registry) Names() []string {
+	var names []string
+	for name, s := range r.sets {
+		s.mu.RLock()
+		for page := range s.pages {
+			names = append(names, name+"/"+page)
+		}
+		s.mu.RUnlock()
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Execute renders the named page inside its set's layout. Pages of a theme
+// pack, named with BlogTemplate, fall back to the default blog templates if
+// the pack is unknown or fails to render.
+func (r *Registry) Execute(w io.Writer, name string, data interface{}) error {
+	setName, page, ok := strings.Cut(name, "/")
+	if pack, isPack := strings.CutPrefix(setName, packSetPrefix); ok && isPack {
+		return r.executePack(w, pack, page, data)
+	}
+
+	s := r.sets[setName]
+	if !ok || s == nil {
+		return fmt.Errorf("unknown template %q", name)
+	}
+	return s.execute(w, page, data)
+}
+
+// executePack renders a theme pack page into a buffer first, so nothing from
+// a failed render reaches w before falling back
+func (r *Registry) executePack(w io.Writer, pack, page string, data interface{}) error {
+	s := r.sets[packSetPrefix+pack]
+	if s == nil {
+		r.logger.Warn("Unknown theme pack, using the default theme", "pack", pack)
+		return r.Execute(w, "blog/"+page, data)
+	}
+
+	var buf bytes.Buffer
+	if err := s.execute(&buf, page, data); err != nil {
+		r.logger.Error("Theme pack failed to render, using the default theme", "pack", pack, "template", page, "error", err)
+		return r.Execute(w, "blog/"+page, data)
+	}
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// Render implements echo.Renderer so handlers can use c.Render
+func (r *Registry) Render(w io.Writer, name string, data interface{}, c echo.Context) error {
+	return r.Execute(w, name, data)
+}
+
+// set is one layout and the pages rendered inside it
+type set struct {
+	spec   setSpec
+	fsys   fs.FS
+	reload bool
+
+	mu       sync.RWMutex
+	pages    map[string]*template.Template
+	modified time.Time
+}
+
+// parse (re)builds every page of the set
+func (s *set) parse() error {
+	modified, err := latestModTime(s.fsys)
+	if err != nil {
+		return fmt.Errorf("failed to read %s templates: %w", s.spec.name, err)
+	}
+
+	names := s.spec.pages
+	if names == nil {
+		all, err := fs.Glob(s.fsys, "*.html")
+		if err != nil {
+			return fmt.Errorf("failed to list %s templates: %w", s.spec.name, err)
+		}
+		for _, name := range all {
+			if !strings.HasSuffix(name, "layout.html") {
+				names = append(names, name)
+			}
+		}
+	}
+
+	pages := make(map[string]*template.Template, len(names))
+	for _, name := range names {
+		tmpl, err := template.New(s.spec.layout).Funcs(Funcs()).ParseFS(s.fsys, s.spec.layout, name)
+		if err != nil {
+			return fmt.Errorf("failed to parse template %s/%s: %w", s.spec.name, name, err)
+		}
+		if s.spec.contract {
+			if err := checkContract(tmpl, s.spec.layout, name); err != nil {
+				return fmt.Errorf("template %s/%s breaks the blog data contract: %w", s.spec.name, name, err)
+			}
+		}
+		pages[name] = tmpl
+	}
+
+	s.mu.Lock()
+	s.pages = pages
+	s.modified = modified
+	s.mu.Unlock()
+	return nil
+}
+
+// execute renders a page inside the set's layout
+func (s *set) execute(w io.Writer, page string, data interface{}) error {
+	tmpl, err := s.lookup(page)
+	if err != nil {
+		return err
+	}
+	if err := tmpl.ExecuteTemplate(w, s.spec.layout, data); err != nil {
+		return fmt.Errorf("failed to render template %s: %w", path.Join(s.spec.name, page), err)
+	}
+	return nil
+}
+
+// checkContract renders a copy of the page against sample data, failing on
+// any map key that isn't part of the blog data contract
+func checkContract(tmpl *template.Template, layout, page string) error {
+	check, err := tmpl.Clone()
+	if err != nil {
+		return err
+	}
+	return check.Option("missingkey=error").ExecuteTemplate(io.Discard, layout, SampleBlogData(page))
+}
+
+// lookup returns a parsed page, re-parsing first if a file changed on disk
+func (s *set) lookup(page string) (*template.Template, error) {
+	if s.reload {
+		modified, err := latestModTime(s.fsys)
+		if err != nil {
+			return nil, err
+		}
+		s.mu.RLock()
+		stale := modified.After(s.modified)
+		s.mu.RUnlock()
+		if stale {
+			if err := s.parse(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	s.mu.RLock()
+	tmpl := s.pages[page]
+	s.mu.RUnlock()
+	if tmpl == nil {
+		return nil, fmt.Errorf("unknown template %q", path.Join(s.spec.name, page))
+	}
+	return tmpl, nil
+}
+
+// latestModTime returns the newest modification time of the templates in fsys.
+// Embedded files have no modification time, so this is zero for them.
+func latestModTime(fsys fs.FS) (time.Time, error) {
+	var latest time.Time
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return latest, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".html" {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return latest, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
@@ -0,0 +1,189 @@
+package webmentions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// ErrNoEndpoint is returned for pages that don't advertise a Webmention
+// endpoint, which is most of them
+var ErrNoEndpoint = errors.New("no webmention endpoint")
+
+// SendAll sends a Webmention from source to every page on another site that
+// the source's HTML links to and that accepts them. Pages without an
+// endpoint are skipped; failures for the rest are returned together.
+func (s *Service) SendAll(ctx context.Context, source, content string) error {
+	var errs []error
+	for _, target := range Links(source, content) {
+		err := s.Send(ctx, source, target)
+		if err != nil && !errors.Is(err, ErrNoEndpoint) {
+			errs = append(errs, fmt.Errorf("webmention to %s: %w", target, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Links returns the distinct http(s) links in content that point away from
+// source's site, without fragments
+func Links(source, content string) []string {
+	base, err := url.Parse(source)
+	if err != nil {
+		return nil
+	}
+	doc, err := html.Parse(strings.NewReader(content))
+	if err != nil {
+		return nil
+	}
+
+	var links []string
+	seen := map[string]bool{}
+	walk(doc, func(n *html.Node) bool {
+		if n.Data != "a" {
+			return true
+		}
+		ref, err := base.Parse(attrValue(n, "href"))
+		if err != nil || (ref.Scheme != "http" && ref.Scheme != "https") || strings.EqualFold(ref.Host, base.Host) {
+			return true
+		}
+		ref.Fragment = ""
+		if link := ref.String(); !seen[link] {
+			seen[link] = true
+			links = append(links, link)
+		}
+		return true
+	})
+	return links
+}
+
+// Send notifies target's Webmention endpoint that source links to it
+func (s *Service) Send(ctx context.Context, source, target string) error {
+	endpoint, err := s.Discover(ctx, target)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+	form := url.Values{"source": {source}, "target": {target}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxPageBytes))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webmention endpoint returned %s", resp.Status)
+	}
+	return nil
+}
+
+// Discover finds the Webmention endpoint target advertises, first in its
+// Link headers and then in the first <link> or <a> with rel="webmention".
+// The endpoint is resolved against the URL any redirects ended at.
+func (s *Service) Discover(ctx context.Context, target string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", fmt.Errorf("%w: target returned %s", ErrNoEndpoint, resp.Status)
+	}
+
+	href, found := linkHeaderEndpoint(resp.Header.Values("Link"))
+	if !found {
+		mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+		if mediaType == "text/html" || mediaType == "application/xhtml+xml" {
+			href, found = htmlEndpoint(io.LimitReader(resp.Body, maxPageBytes))
+		}
+	}
+	if !found {
+		return "", ErrNoEndpoint
+	}
+
+	// An empty href means the target is its own endpoint
+	endpoint, err := resp.Request.URL.Parse(href)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") {
+		return "", fmt.Errorf("%w: invalid endpoint %q", ErrNoEndpoint, href)
+	}
+	endpoint.Fragment = ""
+	return endpoint.String(), nil
+}
+
+// linkHeaderEndpoint returns the first Link header target with the
+// webmention rel, e.g. Link: <https://example.com/webmention>; rel="webmention"
+func linkHeaderEndpoint(headers []string) (string, bool) {
+	for _, header := range headers {
+		for _, link := range strings.Split(header, ",") {
+			parts := strings.Split(link, ";")
+			ref := strings.TrimSpace(parts[0])
+			if !strings.HasPrefix(ref, "<") || !strings.HasSuffix(ref, ">") {
+				continue
+			}
+			for _, param := range parts[1:] {
+				key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+				if ok && strings.EqualFold(strings.TrimSpace(key), "rel") && hasRel(strings.Trim(value, `"`)) {
+					return strings.Trim(ref, "<>"), true
+				}
+			}
+		}
+	}
+	return "", false
+}
+
+// htmlEndpoint returns the href of the first <link> or <a> element with the
+// webmention rel
+func htmlEndpoint(r io.Reader) (string, bool) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return "", false
+	}
+	var href string
+	found := false
+	walk(doc, func(n *html.Node) bool {
+		if n.Data != "link" && n.Data != "a" {
+			return true
+		}
+		for _, attr := range n.Attr {
+			if attr.Key == "href" && hasRel(attrValue(n, "rel")) {
+				href, found = attr.Val, true
+				return false
+			}
+		}
+		return true
+	})
+	return href, found
+}
+
+func hasRel(rel string) bool {
+	for _, r := range strings.Fields(rel) {
+		if strings.EqualFold(r, "webmention") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,290 @@
+package webmentions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/cassiascheffer/willow_camp/internal/models"
+	"golang.org/x/net/html"
+)
+
+// maxContentLength is how much of a reply's text is kept
+const maxContentLength = 500
+
+var (
+	// ErrGone is returned when a mention's source has been deleted
+	ErrGone = errors.New("webmention source is gone")
+	// ErrNoLink is returned when a mention's source doesn't link to its target
+	ErrNoLink = errors.New("webmention source does not link to the target")
+)
+
+// Verify fetches a mention's source and checks that it links to the target,
+// filling in the author, content and type from the source's h-entry
+func (s *Service) Verify(ctx context.Context, mention *models.Webmention) error {
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, mention.Source, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,text/plain;q=0.5")
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusGone || resp.StatusCode == http.StatusNotFound:
+		return ErrGone
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return fmt.Errorf("webmention source returned %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxPageBytes))
+	if err != nil {
+		return err
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		// Other text counts if it has the target URL in it
+		if strings.HasPrefix(mediaType, "text/") && strings.Contains(string(body), mention.Target) {
+			mention.MentionType = "mention"
+			mention.AuthorName = hostOf(mention.Source)
+			mention.AuthorURL = mention.Source
+			return nil
+		}
+		return ErrNoLink
+	}
+
+	doc, err := html.Parse(strings.NewReader(string(body)))
+	if err != nil {
+		return err
+	}
+	if !linksTo(doc, resp.Request.URL, mention.Target) {
+		return ErrNoLink
+	}
+	readEntry(doc, resp.Request.URL, mention)
+	return nil
+}
+
+// sameURL compares URLs ignoring a trailing slash and fragment
+func sameURL(a, b string) bool {
+	normalize := func(s string) string {
+		if i := strings.Index(s, "#"); i >= 0 {
+			s = s[:i]
+		}
+		return strings.TrimSuffix(s, "/")
+	}
+	return normalize(a) == normalize(b)
+}
+
+// linksTo reports whether the page links to, or embeds, target
+func linksTo(doc *html.Node, base *url.URL, target string) bool {
+	found := false
+	walk(doc, func(n *html.Node) bool {
+		var attr string
+		switch n.Data {
+		case "a", "area", "link":
+			attr = "href"
+		case "img", "video", "audio", "source":
+			attr = "src"
+		default:
+			return true
+		}
+		if ref, err := base.Parse(attrValue(n, attr)); err == nil && sameURL(ref.String(), target) {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// readEntry fills in a mention from the first h-entry on the page: its
+// author h-card, its content, and whether it is a reply, like or repost of
+// the target. Pages without microformats are attributed to their site.
+func readEntry(doc *html.Node, base *url.URL, mention *models.Webmention) {
+	mention.MentionType = "mention"
+	mention.AuthorName = hostOf(mention.Source)
+	mention.AuthorURL = base.Scheme + "://" + base.Host
+	mention.AuthorPhoto = ""
+	mention.Content = ""
+	mention.PublishedAt = nil
+
+	entry := findClass(doc, "h-entry")
+	if entry == nil {
+		return
+	}
+
+	if author := findClass(entry, "p-author", "u-author"); author != nil {
+		readAuthor(author, base, mention)
+	}
+	if content := findClass(entry, "e-content", "p-content", "p-summary"); content != nil {
+		mention.Content = clip(textContent(content), maxContentLength)
+	}
+	if published := findClass(entry, "dt-published"); published != nil {
+		value := attrValue(published, "datetime")
+		if value == "" {
+			value = textContent(published)
+		}
+		if t, err := time.Parse(time.RFC3339, strings.TrimSpace(value)); err == nil {
+			mention.PublishedAt = &t
+		}
+	}
+
+	types := map[string]string{
+		"u-in-reply-to": "reply",
+		"u-like-of":     "like",
+		"u-repost-of":   "repost",
+		"u-bookmark-of": "bookmark",
+	}
+	walk(entry, func(n *html.Node) bool {
+		for class, kind := range types {
+			if hasClass(n, class) {
+				if ref, err := base.Parse(attrValue(n, "href")); err == nil && sameURL(ref.String(), mention.Target) {
+					mention.MentionType = kind
+					return false
+				}
+			}
+		}
+		return true
+	})
+}
+
+// readAuthor reads an author given as an h-card or as a plain link
+func readAuthor(author *html.Node, base *url.URL, mention *models.Webmention) {
+	resolve := func(ref string) string {
+		if ref == "" {
+			return ""
+		}
+		u, err := base.Parse(ref)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return ""
+		}
+		return u.String()
+	}
+
+	name := textContent(author)
+	link := attrValue(author, "href")
+	if hasClass(author, "h-card") {
+		if n := findClass(author, "p-name"); n != nil {
+			name = textContent(n)
+		}
+		if u := findClass(author, "u-url"); u != nil {
+			link = attrValue(u, "href")
+		}
+		if photo := findClass(author, "u-photo"); photo != nil {
+			mention.AuthorPhoto = resolve(attrValue(photo, "src"))
+			if name == "" {
+				name = attrValue(photo, "alt")
+			}
+		}
+	}
+	if name = clip(name, 100); name != "" {
+		mention.AuthorName = name
+	}
+	if link = resolve(link); link != "" {
+		mention.AuthorURL = link
+	}
+}
+
+// walk visits n and its descendants in document order until visit returns
+// false
+func walk(n *html.Node, visit func(*html.Node) bool) bool {
+	if n.Type == html.ElementNode && !visit(n) {
+		return false
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if !walk(c, visit) {
+			return false
+		}
+	}
+	return true
+}
+
+// findClass returns the first element at or below n with any of the classes
+func findClass(n *html.Node, classes ...string) *html.Node {
+	var found *html.Node
+	walk(n, func(el *html.Node) bool {
+		for _, class := range classes {
+			if hasClass(el, class) {
+				found = el
+				return false
+			}
+		}
+		return true
+	})
+	return found
+}
+
+func hasClass(n *html.Node, class string) bool {
+	for _, c := range strings.Fields(attrValue(n, "class")) {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+func attrValue(n *html.Node, key string) string {
+	for _, attr := range n.Attr {
+		if attr.Key == key {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+// blockElements end a line of text
+var blockElements = map[string]bool{
+	"p": true, "div": true, "br": true, "li": true, "blockquote": true, "pre": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+}
+
+// textContent returns the text of n, leaving out scripts and styles
+func textContent(n *html.Node) string {
+	var b strings.Builder
+	var collect func(*html.Node)
+	collect = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+		}
+		if n.Type == html.ElementNode && (n.Data == "script" || n.Data == "style") {
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			collect(c)
+		}
+		if n.Type == html.ElementNode && blockElements[n.Data] {
+			b.WriteByte(' ')
+		}
+	}
+	collect(n)
+	return b.String()
+}
+
+// clip collapses whitespace and shortens s to at most n characters
+func clip(s string, n int) string {
+	s = strings.Join(strings.Fields(strings.ToValidUTF8(s, "")), " ")
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	runes := []rune(s)
+	return strings.TrimSpace(string(runes[:n-1])) + "…"
+}
+
+func hostOf(link string) string {
+	if u, err := url.Parse(link); err == nil {
+		return u.Host
+	}
+	return link
+}
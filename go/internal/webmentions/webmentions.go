@@ -0,0 +1,200 @@
+// Package webmentions lets blogs take part in IndieWeb conversations. Pages
+// that link to a post can notify it with a Webmention, which is verified in
+// the background and waits for the blog owner's approval before it is shown
+// under the post. Publishing a post sends Webmentions to the pages it links
+// to. See https://www.w3.org/TR/webmention/.
+package webmentions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/cassiascheffer/willow_camp/internal/helpers"
+	"github.com/cassiascheffer/willow_camp/internal/jobs"
+	"github.com/cassiascheffer/willow_camp/internal/markdown"
+	"github.com/cassiascheffer/willow_camp/internal/models"
+	"github.com/cassiascheffer/willow_camp/internal/previews"
+	"github.com/cassiascheffer/willow_camp/internal/repository"
+	"github.com/google/uuid"
+)
+
+// Job kinds handled by the service
+const (
+	JobVerify = "webmentions.verify"
+	JobSend   = "webmentions.send"
+)
+
+// fetchTimeout bounds each request to another site, redirects included
+const fetchTimeout = 10 * time.Second
+
+// maxPageBytes is how much of a source page or target page is read
+const maxPageBytes = 1 << 20
+
+// userAgent identifies requests made for Webmentions
+const userAgent = "willow.camp Webmention"
+
+// ErrInvalidMention is returned for Webmentions that can't be accepted, like
+// ones whose target isn't a published post on the blog
+var ErrInvalidMention = errors.New("invalid webmention")
+
+// Service receives, verifies and sends Webmentions
+type Service struct {
+	repos      *repository.Repositories
+	queue      *jobs.Queue
+	baseDomain string
+	client     *http.Client
+}
+
+// New creates a new webmentions Service and registers its jobs on the queue
+func New(repos *repository.Repositories, queue *jobs.Queue, baseDomain string) *Service {
+	s := &Service{
+		repos:      repos,
+		queue:      queue,
+		baseDomain: baseDomain,
+		client:     previews.NewClient(fetchTimeout),
+	}
+	if queue != nil {
+		queue.Register(JobVerify, s.runVerify)
+		queue.Register(JobSend, s.runSend)
+	}
+	return s
+}
+
+// SetClient replaces the HTTP client used to fetch sources and send
+// Webmentions. The default one refuses private addresses; a replacement
+// should too outside of tests.
+func (s *Service) SetClient(client *http.Client) {
+	s.client = client
+}
+
+// TargetSlug checks a received Webmention and returns the slug of the post
+// its target points at. Both URLs must be http(s) and differ, and the target
+// must be on one of the blog's hosts.
+func TargetSlug(blog *models.Blog, baseDomain, source, target string) (string, error) {
+	sourceURL, err := url.Parse(source)
+	if err != nil || (sourceURL.Scheme != "http" && sourceURL.Scheme != "https") || sourceURL.Host == "" {
+		return "", fmt.Errorf("%w: source must be an http(s) URL", ErrInvalidMention)
+	}
+	targetURL, err := url.Parse(target)
+	if err != nil || (targetURL.Scheme != "http" && targetURL.Scheme != "https") || targetURL.Host == "" {
+		return "", fmt.Errorf("%w: target must be an http(s) URL", ErrInvalidMention)
+	}
+	if source == target {
+		return "", fmt.Errorf("%w: source and target are the same", ErrInvalidMention)
+	}
+
+	if !blogHost(blog, baseDomain, targetURL.Host) {
+		return "", fmt.Errorf("%w: target is not on this blog", ErrInvalidMention)
+	}
+	slug := strings.Trim(targetURL.Path, "/")
+	if slug == "" || strings.Contains(slug, "/") {
+		return "", fmt.Errorf("%w: target is not a post", ErrInvalidMention)
+	}
+	return slug, nil
+}
+
+// blogHost reports whether host serves blog, by custom domain or subdomain
+func blogHost(blog *models.Blog, baseDomain, host string) bool {
+	host = strings.ToLower(host)
+	if blog.CustomDomain != nil && *blog.CustomDomain != "" && host == strings.ToLower(*blog.CustomDomain) {
+		return true
+	}
+	return blog.Subdomain != nil && *blog.Subdomain != "" && host == strings.ToLower(*blog.Subdomain+"."+baseDomain)
+}
+
+type verifyPayload struct {
+	WebmentionID uuid.UUID `json:"webmention_id"`
+}
+
+// Receive accepts a Webmention for one of the blog's published posts and
+// queues verifying it. Nothing is fetched while the sender waits.
+func (s *Service) Receive(ctx context.Context, blog *models.Blog, source, target string) (*models.Webmention, error) {
+	slug, err := TargetSlug(blog, s.baseDomain, source, target)
+	if err != nil {
+		return nil, err
+	}
+	post, err := s.repos.Post.FindBySlug(ctx, blog.ID, slug)
+	if err != nil && !errors.Is(err, repository.ErrPostNotFound) {
+		return nil, err
+	}
+	if err != nil || !post.IsPublished() {
+		return nil, fmt.Errorf("%w: target is not a published post", ErrInvalidMention)
+	}
+
+	mention, err := s.repos.Webmention.Receive(ctx, blog.ID, post.ID, source, target)
+	if err != nil || s.queue == nil {
+		return mention, err
+	}
+	_, err = s.queue.Enqueue(ctx, JobVerify, verifyPayload{WebmentionID: mention.ID}, jobs.ForBlog(blog.ID), jobs.MaxAttempts(3))
+	return mention, err
+}
+
+// runVerify checks that a received mention's source links to its target.
+// Mentions whose source is gone or no longer links are deleted.
+func (s *Service) runVerify(ctx context.Context, job *models.Job) error {
+	var payload verifyPayload
+	if err := job.DecodePayload(&payload); err != nil {
+		return jobs.Permanent(err)
+	}
+	mention, err := s.repos.Webmention.FindByID(ctx, payload.WebmentionID)
+	if errors.Is(err, repository.ErrWebmentionNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	err = s.Verify(ctx, mention)
+	if errors.Is(err, ErrNoLink) || errors.Is(err, ErrGone) || errors.Is(err, previews.ErrBlockedAddress) {
+		return s.repos.Webmention.Delete(ctx, mention.ID)
+	}
+	if err != nil {
+		return err
+	}
+	return s.repos.Webmention.MarkVerified(ctx, mention)
+}
+
+type sendPayload struct {
+	PostID uuid.UUID `json:"post_id"`
+}
+
+// QueuePost schedules sending Webmentions for the links in a newly
+// published post
+func (s *Service) QueuePost(ctx context.Context, blog *models.Blog, post *models.Post) error {
+	if s.queue == nil {
+		return nil
+	}
+	_, err := s.queue.Enqueue(ctx, JobSend, sendPayload{PostID: post.ID}, jobs.ForBlog(blog.ID), jobs.MaxAttempts(3))
+	return err
+}
+
+func (s *Service) runSend(ctx context.Context, job *models.Job) error {
+	var payload sendPayload
+	if err := job.DecodePayload(&payload); err != nil {
+		return jobs.Permanent(err)
+	}
+
+	post, err := s.repos.Post.FindByID(ctx, payload.PostID)
+	if err != nil {
+		return err
+	}
+	if post.IsPage() || !post.IsPublic() || post.Slug == nil || post.BodyMarkdown == nil {
+		return nil
+	}
+	blog, err := s.repos.Blog.FindByID(ctx, post.BlogID)
+	if err != nil {
+		return err
+	}
+
+	content, err := markdown.For(blog.MarkdownOptions).Render(*post.BodyMarkdown)
+	if err != nil {
+		return jobs.Permanent(err)
+	}
+	source := helpers.BlogBaseURL(blog, s.baseDomain) + "/" + *post.Slug
+	return s.SendAll(ctx, source, string(content))
+}
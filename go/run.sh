@@ -12,6 +12,16 @@ if [ -z "$PORT" ]; then
     export PORT="3001"
 fi
 
+# Serve templates and static files from disk so Vite rebuilds show up
+if [ -z "$GO_ENV" ]; then
+    export GO_ENV="development"
+fi
+
+# Send the post access cookie over plain HTTP locally
+if [ -z "$COOKIE_SECURE" ]; then
+    export COOKIE_SECURE="false"
+fi
+
 # Cleanup function to kill background processes
 cleanup() {
     echo ""
@@ -30,6 +40,9 @@ echo "DATABASE_URL: $DATABASE_URL"
 echo "PORT: $PORT"
 echo ""
 
+# Copy the OpenMoji icons to embed
+go generate ./static
+
 # Build the Go server
 echo "Building Go server..."
 go build -o bin/server ./cmd/server
@@ -0,0 +1,27 @@
+#!/bin/bash
+set -e
+
+# Script to copy the OpenMoji icons from the Rails app's public/ directory
+# into static/, where they are embedded in the server binary
+# Usage: go generate ./static (or ./scripts/copy-openmoji.sh)
+
+GO_DIR="$(cd "$(dirname "$0")/.." && pwd)"
+PUBLIC_DIR="$GO_DIR/../public"
+STATIC_DIR="$GO_DIR/static"
+
+# Icon sets the favicons and emoji pickers use
+declare -a dirs=(
+    "openmoji-32x32-ico"
+    "openmoji-svg-color"
+    "openmoji-apple-touch-icon-180x180"
+)
+
+echo "Copying OpenMoji icons..."
+
+for dir in "${dirs[@]}"; do
+    rm -rf "$STATIC_DIR/$dir"
+    cp -R "$PUBLIC_DIR/$dir" "$STATIC_DIR/$dir"
+done
+cp "$PUBLIC_DIR/openmoji-map.json" "$STATIC_DIR/openmoji-map.json"
+
+echo "Successfully copied OpenMoji icons to $STATIC_DIR"
@@ -0,0 +1,84 @@
+// Upload images and files into the markdown editor (button, paste or drag and drop)
+export function registerMarkdownUploadComponent(Alpine) {
+  Alpine.data('markdownUpload', (blogId) => ({
+    uploading: 0,
+    blogId: blogId || '',
+
+    // Upload files picked with the file input
+    onPick(event) {
+      this.uploadFiles(event.target.files)
+      event.target.value = ''
+    },
+
+    // Upload images pasted into the textarea
+    onPaste(event) {
+      const files = Array.from(event.clipboardData?.files || [])
+      if (files.length > 0) {
+        event.preventDefault()
+        this.uploadFiles(files)
+      }
+    },
+
+    // Upload files dropped onto the textarea
+    onDrop(event) {
+      const files = Array.from(event.dataTransfer?.files || [])
+      if (files.length > 0) {
+        event.preventDefault()
+        this.uploadFiles(files)
+      }
+    },
+
+    async uploadFiles(files) {
+      for (const file of files) {
+        await this.uploadFile(file)
+      }
+    },
+
+    async uploadFile(file) {
+      const textarea = this.$refs.body
+      const placeholder = `![Uploading ${file.name}…]()`
+      this.insertAtCursor(textarea, placeholder)
+      this.uploading++
+
+      try {
+        const formData = new FormData()
+        formData.append('file', file)
+
+        const response = await fetch(`/dashboard/blogs/${this.blogId}/uploads`, {
+          method: 'POST',
+          body: formData
+        })
+        const data = await response.json().catch(() => ({}))
+
+        if (!response.ok) {
+          throw new Error(data.error || 'Upload failed')
+        }
+
+        textarea.value = textarea.value.replace(placeholder, data.markdown)
+      } catch (error) {
+        console.error('Upload error:', error)
+        textarea.value = textarea.value.replace(placeholder, '')
+        Alpine.store('toasts').show(error.message || 'Upload failed', 'error')
+      } finally {
+        this.uploading--
+        // Let the autosave form know the body changed
+        textarea.dispatchEvent(new Event('change', { bubbles: true }))
+      }
+    },
+
+    // Insert text at the cursor on its own line
+    insertAtCursor(textarea, text) {
+      const start = textarea.selectionStart ?? textarea.value.length
+      const end = textarea.selectionEnd ?? start
+      const before = textarea.value.slice(0, start)
+      const after = textarea.value.slice(end)
+      const prefix = before === '' || before.endsWith('\n') ? '' : '\n'
+      const suffix = after.startsWith('\n') ? '' : '\n'
+
+      textarea.value = before + prefix + text + suffix + after
+      const cursor = (before + prefix + text + suffix).length
+      textarea.setSelectionRange(cursor, cursor)
+      textarea.focus()
+    }
+  }))
+}
@@ -0,0 +1,13 @@
+// Blog appearance form that keeps the preview iframe in sync with unsaved changes
+export function registerThemeCustomizerComponent(Alpine) {
+  Alpine.data('themeCustomizer', () => ({
+    init() {
+      this.refreshPreview()
+    },
+
+    refreshPreview() {
+      // The preview button posts the form into the preview iframe
+      this.$el.requestSubmit(this.$refs.previewButton)
+    }
+  }))
+}
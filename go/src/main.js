@@ -19,6 +19,8 @@ import { registerAutosaveFormComponent } from './components/autosave-form.js'
 import { registerTagChoicesComponent } from './components/tag-choices.js'
 import { registerTagListComponent } from './components/tag-list.js'
 import { registerHomePageComponent } from './components/home-page.js'
+import { registerMarkdownUploadComponent } from './components/markdown-upload.js'
+import { registerThemeCustomizerComponent } from './components/theme-customizer.js'
 
 // Make Alpine available globally
 window.Alpine = Alpine
@@ -38,6 +40,8 @@ registerAutosaveFormComponent(Alpine)
 registerTagChoicesComponent(Alpine)
 registerTagListComponent(Alpine)
 registerHomePageComponent(Alpine)
+registerMarkdownUploadComponent(Alpine)
+registerThemeCustomizerComponent(Alpine)
 
 // Start Alpine
 Alpine.start()
@@ -0,0 +1,12 @@
+// Package static embeds the files in this directory: the OpenMoji icon sets
+// that `go generate` copies from the Rails app's public/ directory and the
+// frontend bundle that `npm run build` writes to dist/. Generate both before
+// building the server so they end up in the binary.
+package static
+
+import "embed"
+
+//go:generate bash ../scripts/copy-openmoji.sh
+
+//go:embed *
+var FS embed.FS
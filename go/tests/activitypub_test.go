@@ -0,0 +1,452 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cassiascheffer/willow_camp/internal/activitypub"
+	"github.com/cassiascheffer/willow_camp/internal/auth"
+	"github.com/cassiascheffer/willow_camp/internal/blog/handlers"
+	blogmiddleware "github.com/cassiascheffer/willow_camp/internal/blog/middleware"
+	"github.com/cassiascheffer/willow_camp/internal/models"
+	"github.com/cassiascheffer/willow_camp/internal/repository"
+	"github.com/cassiascheffer/willow_camp/internal/views"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// memoryActivityPub is an in-memory activitypub.Store
+type memoryActivityPub struct {
+	mu        sync.Mutex
+	keys      map[uuid.UUID]*models.ActorKey
+	followers map[string]*models.Follower
+	claimed   map[uuid.UUID]bool
+}
+
+func newMemoryActivityPub() *memoryActivityPub {
+	return &memoryActivityPub{
+		keys:      map[uuid.UUID]*models.ActorKey{},
+		followers: map[string]*models.Follower{},
+		claimed:   map[uuid.UUID]bool{},
+	}
+}
+
+func (m *memoryActivityPub) FindKey(ctx context.Context, blogID uuid.UUID) (*models.ActorKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if key, ok := m.keys[blogID]; ok {
+		return key, nil
+	}
+	return nil, repository.ErrActorKeyNotFound
+}
+
+func (m *memoryActivityPub) CreateKey(ctx context.Context, key *models.ActorKey) (*models.ActorKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if existing, ok := m.keys[key.BlogID]; ok {
+		return existing, nil
+	}
+	m.keys[key.BlogID] = key
+	return key, nil
+}
+
+func (m *memoryActivityPub) AddFollower(ctx context.Context, follower *models.Follower) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.followers[follower.BlogID.String()+" "+follower.ActorID] = follower
+	return nil
+}
+
+func (m *memoryActivityPub) RemoveFollower(ctx context.Context, blogID uuid.UUID, actorID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.followers, blogID.String()+" "+actorID)
+	return nil
+}
+
+func (m *memoryActivityPub) ListFollowers(ctx context.Context, blogID uuid.UUID) ([]*models.Follower, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var followers []*models.Follower
+	for _, f := range m.followers {
+		if f.BlogID == blogID {
+			followers = append(followers, f)
+		}
+	}
+	return followers, nil
+}
+
+func (m *memoryActivityPub) CountFollowers(ctx context.Context, blogID uuid.UUID) (int, error) {
+	followers, err := m.ListFollowers(ctx, blogID)
+	return len(followers), err
+}
+
+func (m *memoryActivityPub) ClaimPostDelivery(ctx context.Context, postID uuid.UUID, deliveries []*models.Job) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.claimed[postID] {
+		return false, nil
+	}
+	m.claimed[postID] = true
+	return true, nil
+}
+
+func activityPubBlog() *models.Blog {
+	subdomain := "cassia"
+	title := "Cassia's Notes"
+	return &models.Blog{ID: uuid.New(), Subdomain: &subdomain, Title: &title}
+}
+
+func newActivityPubService(store activitypub.Store) *activitypub.Service {
+	service := activitypub.New(store, nil, nil, "willow.camp")
+	service.SetClient(&http.Client{Timeout: 5 * time.Second})
+	return service
+}
+
+// remoteAccount is a fediverse account on a fake server, with an actor
+// document and an inbox that records signed deliveries
+type remoteAccount struct {
+	server   *httptest.Server
+	actorID  string
+	key      *rsa.PrivateKey
+	verifier func(keyID string) (*rsa.PublicKey, error)
+
+	mu        sync.Mutex
+	delivered []map[string]interface{}
+	status    int
+}
+
+func newRemoteAccount(t *testing.T) *remoteAccount {
+	t.Helper()
+	publicPEM, privatePEM, err := activitypub.GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	key, err := activitypub.ParsePrivateKey(privatePEM)
+	if err != nil {
+		t.Fatalf("ParsePrivateKey failed: %v", err)
+	}
+
+	account := &remoteAccount{key: key, status: http.StatusAccepted}
+	mux := http.NewServeMux()
+	account.server = httptest.NewServer(mux)
+	account.actorID = account.server.URL + "/users/alice"
+	t.Cleanup(account.server.Close)
+
+	mux.HandleFunc("/users/alice", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", activitypub.ContentType)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":        account.actorID,
+			"type":      "Person",
+			"inbox":     account.actorID + "/inbox",
+			"endpoints": map[string]string{"sharedInbox": account.server.URL + "/inbox"},
+			"publicKey": map[string]string{"id": account.actorID + "#main-key", "owner": account.actorID, "publicKeyPem": publicPEM},
+		})
+	})
+	receive := func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if account.verifier != nil {
+			if _, err := activitypub.Verify(r, body, account.verifier); err != nil {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+		}
+		var activity map[string]interface{}
+		_ = json.Unmarshal(body, &activity)
+		account.mu.Lock()
+		defer account.mu.Unlock()
+		account.delivered = append(account.delivered, activity)
+		w.WriteHeader(account.status)
+	}
+	mux.HandleFunc("/users/alice/inbox", receive)
+	mux.HandleFunc("/inbox", receive)
+	return account
+}
+
+// post builds an inbox request for the blog signed with the account's key
+func (a *remoteAccount) post(t *testing.T, activity map[string]interface{}, sign bool) (*http.Request, []byte) {
+	t.Helper()
+	body, _ := json.Marshal(activity)
+	req := httptest.NewRequest(http.MethodPost, "https://cassia.willow.camp/inbox", bytes.NewReader(body))
+	req.Header.Set("Content-Type", activitypub.ContentType)
+	if sign {
+		if err := activitypub.Sign(req, a.actorID+"#main-key", a.key, body); err != nil {
+			t.Fatalf("Sign failed: %v", err)
+		}
+	}
+	return req, body
+}
+
+func TestHTTPSignatures(t *testing.T) {
+	publicPEM, privatePEM, err := activitypub.GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	private, _ := activitypub.ParsePrivateKey(privatePEM)
+	public, err := activitypub.ParsePublicKey(publicPEM)
+	if err != nil {
+		t.Fatalf("ParsePublicKey failed: %v", err)
+	}
+	lookup := func(keyID string) (*rsa.PublicKey, error) { return public, nil }
+
+	signed := func(body string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "https://example.com/inbox?x=1", strings.NewReader(body))
+		if err := activitypub.Sign(req, "https://example.com/actor#main-key", private, []byte(body)); err != nil {
+			t.Fatalf("Sign failed: %v", err)
+		}
+		return req
+	}
+
+	keyID, err := activitypub.Verify(signed(`{"type":"Follow"}`), []byte(`{"type":"Follow"}`), lookup)
+	if err != nil || keyID != "https://example.com/actor#main-key" {
+		t.Fatalf("Verify = %q, %v; want the key ID", keyID, err)
+	}
+
+	if _, err := activitypub.Verify(signed(`{"type":"Follow"}`), []byte(`{"type":"Like"}`), lookup); !errors.Is(err, activitypub.ErrBadSignature) {
+		t.Errorf("Expected a changed body to fail, got %v", err)
+	}
+
+	req := signed(`{}`)
+	req.Host = "other.example"
+	if _, err := activitypub.Verify(req, []byte(`{}`), lookup); !errors.Is(err, activitypub.ErrBadSignature) {
+		t.Errorf("Expected a changed host to fail, got %v", err)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "https://example.com/inbox", strings.NewReader(`{}`))
+	req.Header.Set("Date", time.Now().Add(-24*time.Hour).UTC().Format(http.TimeFormat))
+	_ = activitypub.Sign(req, "https://example.com/actor#main-key", private, []byte(`{}`))
+	if _, err := activitypub.Verify(req, []byte(`{}`), lookup); !errors.Is(err, activitypub.ErrBadSignature) {
+		t.Errorf("Expected an old date to fail, got %v", err)
+	}
+
+	if _, err := activitypub.Verify(httptest.NewRequest(http.MethodPost, "/inbox", nil), nil, lookup); !errors.Is(err, activitypub.ErrBadSignature) {
+		t.Errorf("Expected an unsigned request to fail, got %v", err)
+	}
+}
+
+func TestActivityPubDocuments(t *testing.T) {
+	service := newActivityPubService(newMemoryActivityPub())
+	blog := activityPubBlog()
+
+	for _, resource := range []string{"acct:cassia@cassia.willow.camp", "acct:CASSIA@cassia.willow.camp", "https://cassia.willow.camp/actor"} {
+		jrd, err := service.WebFinger(blog, resource)
+		if err != nil {
+			t.Fatalf("WebFinger(%q) failed: %v", resource, err)
+		}
+		if jrd.Subject != "acct:cassia@cassia.willow.camp" || jrd.Links[0].Href != "https://cassia.willow.camp/actor" {
+			t.Errorf("Unexpected WebFinger response for %q: %+v", resource, jrd)
+		}
+	}
+	for _, resource := range []string{"acct:someone@cassia.willow.camp", "acct:cassia@willow.camp", "cassia@cassia.willow.camp"} {
+		if _, err := service.WebFinger(blog, resource); !errors.Is(err, activitypub.ErrUnknownResource) {
+			t.Errorf("Expected WebFinger(%q) to be unknown, got %v", resource, err)
+		}
+	}
+
+	actor, err := service.Actor(context.Background(), blog)
+	if err != nil {
+		t.Fatalf("Actor failed: %v", err)
+	}
+	if actor.ID != "https://cassia.willow.camp/actor" || actor.Inbox != "https://cassia.willow.camp/inbox" ||
+		actor.PreferredUsername != "cassia" || actor.Name != "Cassia's Notes" {
+		t.Errorf("Unexpected actor: %+v", actor)
+	}
+	if _, err := activitypub.ParsePublicKey(actor.PublicKey.PublicKeyPEM); err != nil {
+		t.Errorf("Expected a usable public key, got %v", err)
+	}
+	again, _ := service.Actor(context.Background(), blog)
+	if again.PublicKey.PublicKeyPEM != actor.PublicKey.PublicKeyPEM {
+		t.Error("Expected the blog to keep its key")
+	}
+
+	published := true
+	publishedAt := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	title, slug, body := "Hello", "hello", "Hi [there](#there)"
+	post := &models.Post{ID: uuid.New(), Title: &title, Slug: &slug, BodyMarkdown: &body, Published: &published, PublishedAt: &publishedAt}
+
+	outbox := service.Outbox(blog, []*models.Post{post}, 7)
+	if outbox.TotalItems != 7 || len(outbox.OrderedItems) != 1 {
+		t.Fatalf("Unexpected outbox: %+v", outbox)
+	}
+	data, _ := json.Marshal(outbox)
+	for _, want := range []string{
+		`"type":"Create"`, `"id":"https://cassia.willow.camp/hello#create"`, `"type":"Article"`, `"name":"Hello"`,
+		`"published":"2026-10-01T12:00:00Z"`, `"to":["https://www.w3.org/ns/activitystreams#Public"]`,
+		`"cc":["https://cassia.willow.camp/followers"]`, `https://cassia.willow.camp/hello#there`,
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("Expected outbox to contain %s, got %s", want, data)
+		}
+	}
+}
+
+func TestActivityPubInbox(t *testing.T) {
+	store := newMemoryActivityPub()
+	service := newActivityPubService(store)
+	blog := activityPubBlog()
+	account := newRemoteAccount(t)
+
+	actor, err := service.Actor(context.Background(), blog)
+	if err != nil {
+		t.Fatalf("Actor failed: %v", err)
+	}
+	blogKey, _ := activitypub.ParsePublicKey(actor.PublicKey.PublicKeyPEM)
+	account.verifier = func(keyID string) (*rsa.PublicKey, error) {
+		if keyID != actor.PublicKey.ID {
+			return nil, errors.New("unknown key")
+		}
+		return blogKey, nil
+	}
+
+	follow := map[string]interface{}{
+		"@context": activitypub.ActivityStreams,
+		"id":       account.actorID + "/follows/1",
+		"type":     "Follow",
+		"actor":    account.actorID,
+		"object":   actor.ID,
+	}
+
+	req, body := account.post(t, follow, false)
+	if err := service.HandleInbox(context.Background(), blog, req, body); !errors.Is(err, activitypub.ErrBadSignature) {
+		t.Fatalf("Expected an unsigned follow to be refused, got %v", err)
+	}
+	impostor := newRemoteAccount(t)
+	impostor.actorID = account.actorID
+	req, body = impostor.post(t, follow, true)
+	if err := service.HandleInbox(context.Background(), blog, req, body); !errors.Is(err, activitypub.ErrBadSignature) {
+		t.Fatalf("Expected a follow signed with another key to be refused, got %v", err)
+	}
+
+	req, body = account.post(t, follow, true)
+	if err := service.HandleInbox(context.Background(), blog, req, body); err != nil {
+		t.Fatalf("HandleInbox failed: %v", err)
+	}
+	followers, _ := store.ListFollowers(context.Background(), blog.ID)
+	if len(followers) != 1 || followers[0].ActorID != account.actorID || followers[0].DeliveryInbox() != account.server.URL+"/inbox" {
+		t.Fatalf("Expected the follower with their shared inbox, got %+v", followers)
+	}
+	if len(account.delivered) != 1 || account.delivered[0]["type"] != "Accept" {
+		t.Fatalf("Expected a signed Accept to be delivered, got %v", account.delivered)
+	}
+	if accepted := account.delivered[0]["object"].(map[string]interface{}); accepted["id"] != follow["id"] {
+		t.Errorf("Expected the Accept to carry the Follow, got %v", accepted)
+	}
+
+	other := map[string]interface{}{"type": "Follow", "actor": account.actorID, "object": "https://someone.willow.camp/actor"}
+	req, body = account.post(t, other, true)
+	if err := service.HandleInbox(context.Background(), blog, req, body); !errors.Is(err, activitypub.ErrInvalidActivity) {
+		t.Errorf("Expected a follow of another actor to be invalid, got %v", err)
+	}
+
+	undo := map[string]interface{}{"type": "Undo", "actor": account.actorID, "object": follow}
+	req, body = account.post(t, undo, true)
+	if err := service.HandleInbox(context.Background(), blog, req, body); err != nil {
+		t.Fatalf("HandleInbox(Undo) failed: %v", err)
+	}
+	if count, _ := store.CountFollowers(context.Background(), blog.ID); count != 0 {
+		t.Errorf("Expected the follower to be removed, got %d", count)
+	}
+}
+
+func TestActivityPubDeliver(t *testing.T) {
+	service := newActivityPubService(newMemoryActivityPub())
+	blog := activityPubBlog()
+	account := newRemoteAccount(t)
+	activity := []byte(`{"type":"Create"}`)
+
+	if err := service.Deliver(context.Background(), blog, account.server.URL+"/inbox", activity); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+
+	account.status = http.StatusGone
+	if err := service.Deliver(context.Background(), blog, account.server.URL+"/inbox", activity); !errors.Is(err, activitypub.ErrRejected) {
+		t.Errorf("Expected a gone inbox to reject the delivery, got %v", err)
+	}
+	account.status = http.StatusServiceUnavailable
+	err := service.Deliver(context.Background(), blog, account.server.URL+"/inbox", activity)
+	if err == nil || errors.Is(err, activitypub.ErrRejected) {
+		t.Errorf("Expected an unavailable inbox to be retried, got %v", err)
+	}
+
+	guarded := activitypub.New(newMemoryActivityPub(), nil, nil, "willow.camp")
+	if err := guarded.Deliver(context.Background(), blog, account.server.URL+"/inbox", activity); err == nil {
+		t.Error("Expected the default client to refuse loopback inboxes")
+	}
+}
+
+func TestWebFingerHandler(t *testing.T) {
+	h := handlers.New(nil, nil, "willow.camp")
+	h.SetActivityPub(newActivityPubService(newMemoryActivityPub()))
+	e := echo.New()
+
+	serve := func(resource string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/.well-known/webfinger?resource="+resource, nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.Set("blog", activityPubBlog())
+		if err := h.WebFinger(c); err != nil {
+			e.HTTPErrorHandler(err, c)
+		}
+		return rec
+	}
+
+	rec := serve("acct:cassia@cassia.willow.camp")
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "application/jrd+json") {
+		t.Fatalf("Expected a JRD, got %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(rec.Body.String(), `"href":"https://cassia.willow.camp/actor"`) {
+		t.Errorf("Expected the actor link, got %s", rec.Body.String())
+	}
+	if rec := serve("acct:nobody@cassia.willow.camp"); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for another account, got %d", rec.Code)
+	}
+}
+
+// TestPostShowVariesByAccept verifies a post's page and its ActivityPub
+// article both vary by Accept, so shared caches keep them apart
+func TestPostShowVariesByAccept(t *testing.T) {
+	pool, repos := setupTestDB(t)
+	blog := createTestBlog(t, pool, repos)
+	createTestPost(t, repos, blog, "trail-notes", time.Now())
+
+	registry, err := views.Load(views.Config{})
+	if err != nil {
+		t.Fatalf("Failed to parse templates: %v", err)
+	}
+	baseDomain := "localhost:3001"
+	e := echo.New()
+	e.Renderer = registry
+	h := handlers.New(repos, auth.New(repos.User, "test-secret", nil), baseDomain)
+	h.SetActivityPub(activitypub.New(repos.ActivityPub, repos, nil, baseDomain))
+	group := e.Group("")
+	group.Use(blogmiddleware.BlogResolver(repos.Blog, baseDomain))
+	group.GET("/:slug", h.PostShow)
+
+	for accept, contentType := range map[string]string{
+		"text/html":             "text/html",
+		activitypub.ContentType: activitypub.ContentType,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/trail-notes", nil)
+		req.Host = *blog.Subdomain + "." + baseDomain
+		req.Header.Set("Accept", accept)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), contentType) {
+			t.Errorf("Accept %s: expected %s, got %d %q", accept, contentType, rec.Code, rec.Header().Get("Content-Type"))
+		}
+		if !strings.Contains(strings.Join(rec.Header().Values("Vary"), ","), "Accept") {
+			t.Errorf("Accept %s: expected Vary: Accept, got %q", accept, rec.Header().Values("Vary"))
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package tests
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cassiascheffer/willow_camp/internal/archive"
+)
+
+// buildZip writes the given files into an in-memory zip archive
+func buildZip(t *testing.T, files map[string]string) *bytes.Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("Failed to add %s: %v", name, err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Failed to close zip: %v", err)
+	}
+	return bytes.NewReader(buf.Bytes())
+}
+
+func parseZip(t *testing.T, files map[string]string) *archive.Bundle {
+	t.Helper()
+	r := buildZip(t, files)
+	bundle, err := archive.Parse(r, r.Size())
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	return bundle
+}
+
+func findDocument(t *testing.T, bundle *archive.Bundle, path string) *archive.Document {
+	t.Helper()
+	for _, doc := range bundle.Documents {
+		if doc.Path == path {
+			return doc
+		}
+	}
+	t.Fatalf("Document %s not found", path)
+	return nil
+}
+
+func TestArchiveRoundTrip(t *testing.T) {
+	published := time.Date(2025, 3, 14, 9, 26, 0, 0, time.UTC)
+	post := &archive.Document{
+		Title:       "Hello: World",
+		Slug:        "hello-world",
+		Description: "First post",
+		Body:        "# Hello\n\n![Cat](https://me.willow.camp/media/0123456789abcdef0123456789abcdef.jpg)\n",
+		Tags:        []string{"Go", "Writing"},
+		Published:   true,
+		PublishedAt: &published,
+		Featured:    true,
+	}
+	content, err := archive.MarshalDocument(post)
+	if err != nil {
+		t.Fatalf("MarshalDocument failed: %v", err)
+	}
+
+	bundle := parseZip(t, map[string]string{
+		"blog.json":      `{"version":1,"title":"My Blog","subdomain":"me","theme":"dark"}`,
+		"tags.json":      `[{"name":"Go","slug":"go","posts":1}]`,
+		"posts/hello.md": string(content),
+		"pages/about.md": "---\ntitle: About\nslug: about\npublished: false\n---\n\nAbout me\n",
+		"media/0123456789abcdef0123456789abcdef.jpg": "not really a jpeg",
+	})
+
+	if bundle.Format != archive.FormatWillow {
+		t.Errorf("Expected willow.camp format, got %q", bundle.Format)
+	}
+	if bundle.Settings == nil || bundle.Settings.Title != "My Blog" || bundle.Settings.Theme != "dark" {
+		t.Errorf("Expected settings from blog.json, got %+v", bundle.Settings)
+	}
+	if len(bundle.Documents) != 2 {
+		t.Fatalf("Expected 2 documents, got %d", len(bundle.Documents))
+	}
+
+	got := findDocument(t, bundle, "posts/hello.md")
+	if got.Title != post.Title || got.Slug != post.Slug || got.Description != post.Description {
+		t.Errorf("Metadata did not round trip: %+v", got)
+	}
+	if got.Body != post.Body {
+		t.Errorf("Body did not round trip:\n%q\n%q", got.Body, post.Body)
+	}
+	if !got.Published || !got.Featured || got.Page {
+		t.Errorf("Flags did not round trip: %+v", got)
+	}
+	if got.PublishedAt == nil || !got.PublishedAt.Equal(published) {
+		t.Errorf("Expected date %v, got %v", published, got.PublishedAt)
+	}
+	if len(got.Tags) != 2 || got.Tags[0] != "Go" || got.Tags[1] != "Writing" {
+		t.Errorf("Expected tags [Go Writing], got %v", got.Tags)
+	}
+
+	about := findDocument(t, bundle, "pages/about.md")
+	if !about.Page || about.Published {
+		t.Errorf("Expected an unpublished page, got %+v", about)
+	}
+}
+
+func TestParseJekyllSite(t *testing.T) {
+	bundle := parseZip(t, map[string]string{
+		"site/_config.yml": "title: Old blog\n",
+		"site/_posts/2020-05-01-first-steps.md": "---\ntitle: First Steps\ncategories: travel\ntags: [hiking, Travel]\n---\n" +
+			"![view](/assets/img/view.jpg)\n{% highlight go %}\n",
+		"site/_posts/2020-06-01-hidden.markdown": "---\ntitle: Hidden\npublished: false\n---\nsecret\n",
+		"site/_drafts/idea.md":                   "---\ntitle: Idea\n---\nsomeday\n",
+		"site/about.md":                          "---\ntitle: About\npermalink: /about/\n---\nHi\n",
+		"site/README.md":                         "# readme\n",
+		"site/assets/img/view.jpg":               "jpeg",
+	})
+
+	if bundle.Format != archive.FormatJekyll {
+		t.Fatalf("Expected Jekyll format, got %q", bundle.Format)
+	}
+	if len(bundle.Documents) != 4 {
+		t.Fatalf("Expected 4 documents, got %d", len(bundle.Documents))
+	}
+
+	first := findDocument(t, bundle, "_posts/2020-05-01-first-steps.md")
+	if first.Slug != "first-steps" {
+		t.Errorf("Expected slug from filename, got %q", first.Slug)
+	}
+	if first.PublishedAt == nil || first.PublishedAt.Format("2006-01-02") != "2020-05-01" {
+		t.Errorf("Expected date from filename, got %v", first.PublishedAt)
+	}
+	if !first.Published {
+		t.Error("Expected Jekyll posts to be published by default")
+	}
+	if len(first.Tags) != 2 || first.Tags[0] != "hiking" || first.Tags[1] != "Travel" {
+		t.Errorf("Expected tags and categories merged without duplicates, got %v", first.Tags)
+	}
+
+	if findDocument(t, bundle, "_posts/2020-06-01-hidden.markdown").Published {
+		t.Error("Expected published: false to import as a draft")
+	}
+	if findDocument(t, bundle, "_drafts/idea.md").Published {
+		t.Error("Expected _drafts to import as drafts")
+	}
+	if !findDocument(t, bundle, "about.md").Page {
+		t.Error("Expected root markdown files to import as pages")
+	}
+
+	if len(bundle.Warnings) != 1 {
+		t.Errorf("Expected a warning about Liquid tags, got %v", bundle.Warnings)
+	}
+}
+
+func TestParseHugoSite(t *testing.T) {
+	bundle := parseZip(t, map[string]string{
+		"config.toml":                    "baseURL = 'https://example.com'\n",
+		"content/_index.md":              "---\ntitle: Home\n---\n",
+		"content/about.md":               "+++\ntitle = \"About\"\n+++\nAbout\n",
+		"content/posts/toml-post.md":     "+++\ntitle = \"TOML Post\"\ndate = 2021-02-03T04:05:06Z\ndraft = true\ntags = [\"a\", \"b\"]\n[params]\ncover = \"x\"\n+++\nBody\n",
+		"content/posts/trip/index.md":    "---\ntitle: Trip\ndate: 2021-07-08\nsummary: A trip\n---\n![map](map.png)\n",
+		"content/posts/trip/map.png":     "png",
+		"content/posts/custom/index.md":  "---\ntitle: Custom\nslug: my-custom-slug\n---\n",
+		"themes/ananke/layouts/index.md": "ignored",
+	})
+
+	if bundle.Format != archive.FormatHugo {
+		t.Fatalf("Expected Hugo format, got %q", bundle.Format)
+	}
+	if len(bundle.Documents) != 4 {
+		t.Fatalf("Expected 4 documents, got %d", len(bundle.Documents))
+	}
+
+	toml := findDocument(t, bundle, "content/posts/toml-post.md")
+	if toml.Title != "TOML Post" || toml.Published {
+		t.Errorf("Expected TOML front matter with draft = true, got %+v", toml)
+	}
+	if toml.PublishedAt == nil || !toml.PublishedAt.Equal(time.Date(2021, 2, 3, 4, 5, 6, 0, time.UTC)) {
+		t.Errorf("Expected TOML date, got %v", toml.PublishedAt)
+	}
+	if len(toml.Tags) != 2 {
+		t.Errorf("Expected TOML tags, got %v", toml.Tags)
+	}
+
+	trip := findDocument(t, bundle, "content/posts/trip/index.md")
+	if trip.Slug != "trip" || !trip.Published || trip.Description != "A trip" {
+		t.Errorf("Expected page bundle named after its directory, got %+v", trip)
+	}
+	if findDocument(t, bundle, "content/posts/custom/index.md").Slug != "my-custom-slug" {
+		t.Error("Expected slug front matter to win over the directory name")
+	}
+	if !findDocument(t, bundle, "content/about.md").Page {
+		t.Error("Expected top-level content to import as pages")
+	}
+}
+
+func TestParseRejectsUnknownArchives(t *testing.T) {
+	r := buildZip(t, map[string]string{"notes.txt": "hello"})
+	if _, err := archive.Parse(r, r.Size()); !errors.Is(err, archive.ErrUnknownFormat) {
+		t.Errorf("Expected ErrUnknownFormat, got %v", err)
+	}
+
+	junk := bytes.NewReader([]byte("not a zip"))
+	if _, err := archive.Parse(junk, junk.Size()); !errors.Is(err, archive.ErrInvalidArchive) {
+		t.Errorf("Expected ErrInvalidArchive, got %v", err)
+	}
+}
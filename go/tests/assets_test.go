@@ -0,0 +1,122 @@
+package tests
+
+import (
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/cassiascheffer/willow_camp/internal/assets"
+	"github.com/cassiascheffer/willow_camp/static"
+	"github.com/labstack/echo/v4"
+)
+
+func assetServer() *echo.Echo {
+	e := echo.New()
+	assets.Register(e)
+	return e
+}
+
+// requireOpenMoji skips tests that need the OpenMoji icons, which
+// `go generate ./static` copies from the Rails app's public/ directory
+func requireOpenMoji(t *testing.T) {
+	t.Helper()
+	if _, err := fs.Stat(static.FS, "openmoji-map.json"); err != nil {
+		t.Skip("OpenMoji icons not copied; run go generate ./static")
+	}
+}
+
+func getAsset(e *echo.Echo, path string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAssetURLIsFingerprinted(t *testing.T) {
+	url := assets.URL("accessibility.css")
+	if !regexp.MustCompile(`^/static/accessibility\.[0-9a-f]{10}\.css$`).MatchString(url) {
+		t.Fatalf("Expected a hashed URL, got %s", url)
+	}
+	if assets.URL("/accessibility.css") != url {
+		t.Error("Expected a leading slash to be ignored")
+	}
+	if got := assets.URL("missing.css"); got != "/static/missing.css" {
+		t.Errorf("Expected a plain URL for a missing file, got %s", got)
+	}
+
+	e := assetServer()
+	rec := getAsset(e, url)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rec.Code)
+	}
+	if rec.Header().Get("Cache-Control") != "public, max-age=31536000, immutable" {
+		t.Errorf("Expected immutable caching, got %q", rec.Header().Get("Cache-Control"))
+	}
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/css") {
+		t.Errorf("Expected text/css, got %q", rec.Header().Get("Content-Type"))
+	}
+
+	etag := rec.Header().Get("ETag")
+	req := httptest.NewRequest(http.MethodGet, url, nil)
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("Expected 304 for a matching ETag, got %d", rec.Code)
+	}
+}
+
+func TestAssetServing(t *testing.T) {
+	e := assetServer()
+
+	plain := getAsset(e, "/static/accessibility.css")
+	if plain.Code != http.StatusOK || strings.Contains(plain.Header().Get("Cache-Control"), "immutable") {
+		t.Errorf("Expected plain URLs to be served without immutable caching, got %d %q", plain.Code, plain.Header().Get("Cache-Control"))
+	}
+
+	stale := getAsset(e, "/static/accessibility.0123456789.css")
+	if stale.Code != http.StatusOK || strings.Contains(stale.Header().Get("Cache-Control"), "immutable") {
+		t.Errorf("Expected an outdated hash to get the current file without immutable caching, got %d %q", stale.Code, stale.Header().Get("Cache-Control"))
+	}
+
+	for _, path := range []string{"/static/static.go", "/static/missing.css", "/static/../go.mod", "/static/openmoji-svg-color"} {
+		if rec := getAsset(e, path); rec.Code != http.StatusNotFound {
+			t.Errorf("Expected 404 for %s, got %d", path, rec.Code)
+		}
+	}
+
+	requireOpenMoji(t)
+	for _, path := range []string{"/openmoji-svg-color/1F3D5.svg", "/openmoji-32x32-ico/1F3D5.ico", "/openmoji-apple-touch-icon-180x180/1F3D5.png", "/openmoji-map.json"} {
+		if rec := getAsset(e, path); rec.Code != http.StatusOK {
+			t.Errorf("Expected 200 for %s, got %d", path, rec.Code)
+		}
+	}
+}
+
+func TestEmojiFavicon(t *testing.T) {
+	requireOpenMoji(t)
+	favicon := assets.EmojiFavicon("🌲")
+	if !strings.HasPrefix(favicon.SVG, "/static/openmoji-svg-color/1F332.") || !strings.HasSuffix(favicon.SVG, ".svg") {
+		t.Errorf("Expected the evergreen tree SVG, got %s", favicon.SVG)
+	}
+	if !strings.HasPrefix(favicon.ICO, "/static/openmoji-32x32-ico/1F332.") {
+		t.Errorf("Expected the evergreen tree ICO, got %s", favicon.ICO)
+	}
+	if !strings.HasPrefix(favicon.AppleTouchIcon, "/static/openmoji-apple-touch-icon-180x180/1F332.") {
+		t.Errorf("Expected the evergreen tree touch icon, got %s", favicon.AppleTouchIcon)
+	}
+
+	def := assets.EmojiFavicon(assets.DefaultEmoji)
+	for _, emoji := range []string{"", "not an emoji"} {
+		if got := assets.EmojiFavicon(emoji); got != def {
+			t.Errorf("Expected %q to fall back to the default emoji, got %+v", emoji, got)
+		}
+	}
+
+	if rec := getAsset(assetServer(), def.AppleTouchIcon); rec.Code != http.StatusOK {
+		t.Errorf("Expected the favicon URL to be served, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,215 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cassiascheffer/willow_camp/internal/comments"
+	"github.com/cassiascheffer/willow_camp/internal/helpers"
+	"github.com/cassiascheffer/willow_camp/internal/models"
+	"github.com/google/uuid"
+)
+
+// memoryComments is an in-memory comments.Store
+type memoryComments struct {
+	mu       sync.Mutex
+	comments []*models.Comment
+	bans     []models.Comment
+}
+
+func (m *memoryComments) Create(ctx context.Context, comment *models.Comment) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	comment.ID = uuid.New()
+	comment.CreatedAt = time.Now()
+	m.comments = append(m.comments, comment)
+	return nil
+}
+
+func (m *memoryComments) CountRecentByIP(ctx context.Context, blogID uuid.UUID, ipHash string, since time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	count := 0
+	for _, c := range m.comments {
+		if c.BlogID == blogID && c.IPHash == ipHash && !c.CreatedAt.Before(since) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *memoryComments) IsBanned(ctx context.Context, blogID uuid.UUID, email, ipHash string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, ban := range m.bans {
+		if ban.BlogID == blogID && ((ban.AuthorEmail != "" && ban.AuthorEmail == email) || (ban.IPHash != "" && ban.IPHash == ipHash)) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func commentsFixture(enabled bool) (*models.Blog, *models.Post) {
+	published := true
+	slug := "first-hike"
+	blog := &models.Blog{ID: uuid.New(), CommentsEnabled: enabled}
+	post := &models.Post{ID: uuid.New(), BlogID: blog.ID, Slug: &slug, Published: &published}
+	return blog, post
+}
+
+func TestRenderCommentHTML(t *testing.T) {
+	html := string(helpers.RenderCommentHTML("Nice *trail*!\n\n<script>alert(1)</script>\n\n# Heading\n\n![img](https://example.com/a.png) see https://example.com/page"))
+
+	for _, want := range []string{"<em>trail</em>", `href="https://example.com/page"`, `rel="nofollow ugc"`, "Heading"} {
+		if !strings.Contains(html, want) {
+			t.Errorf("expected %q in %s", want, html)
+		}
+	}
+	for _, unwanted := range []string{"<script", "<h1", "<img"} {
+		if strings.Contains(html, unwanted) {
+			t.Errorf("did not expect %q in %s", unwanted, html)
+		}
+	}
+
+	if html := string(helpers.RenderCommentHTML("[click](javascript:alert(1))")); strings.Contains(html, "javascript:") {
+		t.Errorf("expected javascript link to be dropped, got %s", html)
+	}
+}
+
+func TestSubmitComment(t *testing.T) {
+	ctx := context.Background()
+	store := &memoryComments{}
+	service := comments.New(store, nil, nil, nil, nil, "willow.camp")
+	blog, post := commentsFixture(true)
+
+	comment, err := service.Submit(ctx, blog, post, comments.Submission{
+		Name:  " Reader ",
+		Email: "Reader@Example.com",
+		URL:   "https://reader.example",
+		Body:  "Lovely trail",
+		IP:    "203.0.113.7",
+	})
+	if err != nil {
+		t.Fatalf("Submit returned error: %v", err)
+	}
+	if comment == nil || comment.Status != models.CommentPending {
+		t.Fatalf("expected a pending comment, got %+v", comment)
+	}
+	if comment.AuthorName != "Reader" || comment.AuthorEmail != "reader@example.com" {
+		t.Errorf("expected trimmed name and lowercased email, got %q %q", comment.AuthorName, comment.AuthorEmail)
+	}
+	if comment.IPHash == "" || strings.Contains(comment.IPHash, "203.0.113.7") {
+		t.Errorf("expected the address to be hashed, got %q", comment.IPHash)
+	}
+	if comment.IPHash != comments.HashIP(blog.ID, "203.0.113.7") {
+		t.Errorf("expected hash to match HashIP")
+	}
+}
+
+func TestSubmitCommentRejectsInvalid(t *testing.T) {
+	ctx := context.Background()
+	service := comments.New(&memoryComments{}, nil, nil, nil, nil, "willow.camp")
+	blog, post := commentsFixture(true)
+
+	cases := map[string]comments.Submission{
+		"no name":       {Body: "Hi"},
+		"no body":       {Name: "Reader"},
+		"bad email":     {Name: "Reader", Body: "Hi", Email: "not an email"},
+		"bad website":   {Name: "Reader", Body: "Hi", URL: "javascript:alert(1)"},
+		"long body":     {Name: "Reader", Body: strings.Repeat("a", 5001)},
+		"relative link": {Name: "Reader", Body: "Hi", URL: "/about"},
+	}
+	for name, sub := range cases {
+		if _, err := service.Submit(ctx, blog, post, sub); !errors.Is(err, comments.ErrInvalidComment) {
+			t.Errorf("%s: expected ErrInvalidComment, got %v", name, err)
+		}
+	}
+}
+
+func TestSubmitCommentClosed(t *testing.T) {
+	ctx := context.Background()
+	service := comments.New(&memoryComments{}, nil, nil, nil, nil, "willow.camp")
+	sub := comments.Submission{Name: "Reader", Body: "Hi"}
+
+	blog, post := commentsFixture(false)
+	if _, err := service.Submit(ctx, blog, post, sub); !errors.Is(err, comments.ErrCommentsClosed) {
+		t.Errorf("expected comments to be closed when the blog has them off, got %v", err)
+	}
+
+	blog, post = commentsFixture(true)
+	draft := false
+	post.Published = &draft
+	if _, err := service.Submit(ctx, blog, post, sub); !errors.Is(err, comments.ErrCommentsClosed) {
+		t.Errorf("expected comments to be closed on drafts, got %v", err)
+	}
+
+	page := "Page"
+	post.Published = nil
+	post.Type = &page
+	if comments.Open(blog, post) {
+		t.Error("expected pages not to take comments")
+	}
+}
+
+func TestSubmitCommentDropsSpam(t *testing.T) {
+	ctx := context.Background()
+	store := &memoryComments{}
+	service := comments.New(store, nil, nil, nil, nil, "willow.camp")
+	blog, post := commentsFixture(true)
+
+	// Bots fill in the hidden honeypot field
+	comment, err := service.Submit(ctx, blog, post, comments.Submission{Name: "Bot", Body: "Buy now", Honeypot: "https://spam.example"})
+	if err != nil || comment != nil {
+		t.Errorf("expected honeypot comment to be dropped quietly, got %v, %v", comment, err)
+	}
+
+	// Banned commenters are dropped quietly too, by email or address
+	store.bans = append(store.bans,
+		models.Comment{BlogID: blog.ID, AuthorEmail: "troll@example.com"},
+		models.Comment{BlogID: blog.ID, IPHash: comments.HashIP(blog.ID, "198.51.100.1")})
+	for _, sub := range []comments.Submission{
+		{Name: "Troll", Body: "Again", Email: "TROLL@example.com", IP: "192.0.2.9"},
+		{Name: "Troll", Body: "Again", IP: "198.51.100.1"},
+	} {
+		comment, err := service.Submit(ctx, blog, post, sub)
+		if err != nil || comment != nil {
+			t.Errorf("expected banned comment to be dropped quietly, got %v, %v", comment, err)
+		}
+	}
+
+	// A ban on one blog doesn't follow the commenter to another
+	other, otherPost := commentsFixture(true)
+	if _, err := service.Submit(ctx, other, otherPost, comments.Submission{Name: "Troll", Body: "Hi", IP: "198.51.100.1"}); err != nil {
+		t.Errorf("expected comment on another blog to be accepted, got %v", err)
+	}
+
+	if len(store.comments) != 1 {
+		t.Errorf("expected only the other blog's comment to be stored, got %d", len(store.comments))
+	}
+}
+
+func TestSubmitCommentRateLimit(t *testing.T) {
+	ctx := context.Background()
+	service := comments.New(&memoryComments{}, nil, nil, nil, nil, "willow.camp")
+	blog, post := commentsFixture(true)
+	sub := comments.Submission{Name: "Reader", Body: "Hi", IP: "203.0.113.7"}
+
+	for i := 0; i < comments.RateLimit; i++ {
+		if _, err := service.Submit(ctx, blog, post, sub); err != nil {
+			t.Fatalf("comment %d: unexpected error %v", i+1, err)
+		}
+	}
+	if _, err := service.Submit(ctx, blog, post, sub); !errors.Is(err, comments.ErrRateLimited) {
+		t.Errorf("expected ErrRateLimited after %d comments, got %v", comments.RateLimit, err)
+	}
+
+	// Other readers aren't held up
+	sub.IP = "203.0.113.8"
+	if _, err := service.Submit(ctx, blog, post, sub); err != nil {
+		t.Errorf("expected another address to comment, got %v", err)
+	}
+}
@@ -0,0 +1,116 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cassiascheffer/willow_camp/internal/auth"
+	bloghandlers "github.com/cassiascheffer/willow_camp/internal/blog/handlers"
+	blogmiddleware "github.com/cassiascheffer/willow_camp/internal/blog/middleware"
+	"github.com/cassiascheffer/willow_camp/internal/helpers"
+	"github.com/cassiascheffer/willow_camp/internal/models"
+	"github.com/cassiascheffer/willow_camp/internal/views"
+	"github.com/labstack/echo/v4"
+)
+
+func TestParseArchiveYear(t *testing.T) {
+	for segment, want := range map[string]int{"2024": 2024, "1999": 1999} {
+		if year, ok := helpers.ParseArchiveYear(segment); !ok || year != want {
+			t.Errorf("ParseArchiveYear(%q) = %d, %v; want %d", segment, year, ok, want)
+		}
+	}
+	// Anything else is left to the post slugs
+	for _, segment := range []string{"", "0000", "202", "20245", "+202", "2024-1", "hello", "２０２４"} {
+		if _, ok := helpers.ParseArchiveYear(segment); ok {
+			t.Errorf("expected %q not to be an archive year", segment)
+		}
+	}
+}
+
+func TestParseArchiveMonth(t *testing.T) {
+	if month, ok := helpers.ParseArchiveMonth("05"); !ok || month != time.May {
+		t.Errorf("expected May, got %v, %v", month, ok)
+	}
+	if month, ok := helpers.ParseArchiveMonth("12"); !ok || month != time.December {
+		t.Errorf("expected December, got %v, %v", month, ok)
+	}
+	// One spelling per month, so each has a single URL
+	for _, segment := range []string{"", "5", "00", "13", "+5", "may", "005"} {
+		if _, ok := helpers.ParseArchiveMonth(segment); ok {
+			t.Errorf("expected %q not to be an archive month", segment)
+		}
+	}
+}
+
+func TestGroupArchiveMonths(t *testing.T) {
+	years := models.GroupArchiveMonths([]models.ArchiveMonth{
+		{Year: 2025, Month: time.March, Count: 2},
+		{Year: 2025, Month: time.January, Count: 1},
+		{Year: 2023, Month: time.December, Count: 4},
+	})
+
+	if len(years) != 2 {
+		t.Fatalf("expected 2 years, got %+v", years)
+	}
+	if years[0].Year != 2025 || years[0].Count != 3 || len(years[0].Months) != 2 {
+		t.Errorf("expected 2025 with 3 posts over 2 months, got %+v", years[0])
+	}
+	if years[0].Months[0].Month != time.March {
+		t.Errorf("expected months to stay newest first, got %+v", years[0].Months)
+	}
+	if years[1].Year != 2023 || years[1].Count != 4 || len(years[1].Months) != 1 {
+		t.Errorf("expected 2023 with 4 posts in 1 month, got %+v", years[1])
+	}
+
+	if years := models.GroupArchiveMonths(nil); len(years) != 0 {
+		t.Errorf("expected no years for an empty archive, got %+v", years)
+	}
+}
+
+// TestYearSlugPosts verifies a post that already has a year as its slug is
+// still shown at its URL, while other years show their date archive
+func TestYearSlugPosts(t *testing.T) {
+	pool, repos := setupTestDB(t)
+	blog := createTestBlog(t, pool, repos)
+	createTestPost(t, repos, blog, "2024", time.Date(2024, time.June, 1, 12, 0, 0, 0, time.UTC))
+	createTestPost(t, repos, blog, "spring", time.Date(2023, time.April, 2, 12, 0, 0, 0, time.UTC))
+	createTestPost(t, repos, blog, "2023", time.Time{})
+
+	registry, err := views.Load(views.Config{})
+	if err != nil {
+		t.Fatalf("Failed to parse templates: %v", err)
+	}
+	baseDomain := "localhost:3001"
+	e := echo.New()
+	e.Renderer = registry
+	blogH := bloghandlers.New(repos, auth.New(repos.User, "test-secret", nil), baseDomain)
+	group := e.Group("")
+	group.Use(blogmiddleware.BlogResolver(repos.Blog, baseDomain))
+	group.GET("/:slug", blogH.PostShow)
+
+	get := func(path string) (int, string) {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Host = *blog.Subdomain + "." + baseDomain
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec.Code, rec.Body.String()
+	}
+
+	code, body := get("/2024")
+	if code != http.StatusOK || strings.Contains(body, "Posts from 2024") {
+		t.Errorf("Expected the post with the slug 2024, got %d:\n%s", code, body)
+	}
+
+	// A draft with the slug doesn't hide the archive
+	code, body = get("/2023")
+	if code != http.StatusOK || !strings.Contains(body, "Posts from 2023") || !strings.Contains(body, `href="/spring"`) {
+		t.Errorf("Expected the 2023 archive, got %d:\n%s", code, body)
+	}
+
+	if code, _ := get("/1999"); code != http.StatusNotFound {
+		t.Errorf("Expected a year without posts to be not found, got %d", code)
+	}
+}
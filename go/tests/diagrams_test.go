@@ -0,0 +1,152 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cassiascheffer/willow_camp/internal/diagrams"
+	"github.com/cassiascheffer/willow_camp/internal/helpers"
+	"github.com/cassiascheffer/willow_camp/internal/markdown"
+)
+
+// memoryDiagrams is an in-memory diagrams.Store
+type memoryDiagrams struct {
+	mu   sync.Mutex
+	svgs map[string]string
+}
+
+func (m *memoryDiagrams) FindByHashes(ctx context.Context, hashes []string) (map[string]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	found := map[string]string{}
+	for _, hash := range hashes {
+		if svg, ok := m.svgs[hash]; ok {
+			found[hash] = svg
+		}
+	}
+	return found, nil
+}
+
+func (m *memoryDiagrams) Create(ctx context.Context, hash, svg string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.svgs == nil {
+		m.svgs = map[string]string{}
+	}
+	m.svgs[hash] = svg
+	return nil
+}
+
+const diagramPost = "# Flow\n\n```mermaid\ngraph TD\n  A-->B\n```\n\n```go\nfmt.Println(\"not a diagram\")\n```\n\nAgain:\n\n```mermaid\ngraph TD\n  A-->B\n```\n"
+
+func TestDiagramRendering(t *testing.T) {
+	ctx := context.Background()
+	renderer := &diagrams.StubRenderer{}
+	store := &memoryDiagrams{}
+	service := diagrams.New(renderer, store, nil)
+
+	if sources := markdown.DiagramSources(diagramPost); len(sources) != 2 || sources[0] != "graph TD\n  A-->B\n" {
+		t.Fatalf("Expected two mermaid sources, got %q", sources)
+	}
+
+	if err := service.Prepare(ctx, diagramPost); err != nil {
+		t.Fatalf("Failed to prepare diagrams: %v", err)
+	}
+	if renderer.Calls() != 1 {
+		t.Errorf("Expected the repeated diagram to render once, got %d renders", renderer.Calls())
+	}
+	if err := service.Prepare(ctx, diagramPost); err != nil || renderer.Calls() != 1 {
+		t.Errorf("Expected cached diagrams not to render again, got %d renders and %v", renderer.Calls(), err)
+	}
+
+	resolve, err := service.Resolver(ctx, diagramPost)
+	if err != nil {
+		t.Fatalf("Failed to load diagrams: %v", err)
+	}
+	doc, err := markdown.RenderDocument(diagramPost, markdown.Resolvers{Diagrams: resolve})
+	if err != nil {
+		t.Fatalf("Failed to render: %v", err)
+	}
+	html := string(doc.HTML)
+	hash := markdown.DiagramHash("graph TD\n  A-->B\n")
+	if strings.Count(html, `<figure class="mermaid-diagram" data-diagram="`+hash+`"><svg`) != 2 {
+		t.Errorf("Expected both diagrams inline as SVG, got %s", html)
+	}
+	if strings.Contains(html, "language-mermaid") || !strings.Contains(html, "not a diagram") {
+		t.Errorf("Expected only mermaid blocks to be replaced, got %s", html)
+	}
+
+	svg, ok, err := service.SVG(ctx, hash)
+	if err != nil || !ok || !strings.HasPrefix(svg, "<svg") {
+		t.Errorf("Expected the diagram by hash, got %q, %v, %v", svg, ok, err)
+	}
+}
+
+func TestDiagramFallback(t *testing.T) {
+	ctx := context.Background()
+	store := &memoryDiagrams{}
+	service := diagrams.New(&diagrams.StubRenderer{Err: errors.New("syntax error")}, store, nil)
+
+	if err := service.Prepare(ctx, diagramPost); err == nil {
+		t.Error("Expected a failing renderer to return an error")
+	}
+	resolve, err := service.Resolver(ctx, diagramPost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := markdown.RenderDocument(diagramPost, markdown.Resolvers{Diagrams: resolve})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(doc.HTML), "language-mermaid") {
+		t.Errorf("Expected unrendered diagrams to stay code blocks for mermaid.js, got %s", doc.HTML)
+	}
+
+	// Without a renderer, cached diagrams still resolve and nothing is queued
+	if err := diagrams.New(nil, store, nil).Prepare(ctx, diagramPost); err != nil {
+		t.Errorf("Expected no work without a renderer, got %v", err)
+	}
+}
+
+func TestValidateSVG(t *testing.T) {
+	valid := []string{
+		`<svg xmlns="http://www.w3.org/2000/svg"><text>A</text></svg>`,
+		"<?xml version=\"1.0\"?>\n<svg viewBox=\"0 0 1 1\"></svg>\n",
+	}
+	for _, svg := range valid {
+		if err := diagrams.ValidateSVG([]byte(svg)); err != nil {
+			t.Errorf("Expected %q to be valid, got %v", svg, err)
+		}
+	}
+
+	invalid := []string{
+		"",
+		`<div>not svg</div>`,
+		`<svg><script>alert(1)</script></svg>`,
+		`<svg onload="alert(1)"></svg>`,
+		`<svg><a href="javascript:alert(1)">x</a></svg>`,
+		`<svg></svg><script>alert(1)</script>`,
+	}
+	for _, svg := range invalid {
+		if err := diagrams.ValidateSVG([]byte(svg)); err == nil {
+			t.Errorf("Expected %q to be rejected", svg)
+		}
+	}
+}
+
+func TestFeedDiagramsBecomeImages(t *testing.T) {
+	hash := markdown.DiagramHash("graph TD\n")
+	content := `<p>Before</p><figure class="mermaid-diagram" data-diagram="` + hash + `"><svg><text>A label</text></svg></figure>`
+
+	got := helpers.SanitizeHTMLForFeed(content, "https", "camp.willow.camp", 443, "/post")
+	want := `<img src="https://camp.willow.camp/diagrams/` + hash + `.svg" alt="Diagram"/>`
+	if !strings.Contains(got, want) {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+	if strings.Contains(got, "A label") {
+		t.Errorf("Expected the SVG text to be dropped, got %s", got)
+	}
+}
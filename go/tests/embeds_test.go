@@ -0,0 +1,293 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cassiascheffer/willow_camp/internal/embeds"
+	"github.com/cassiascheffer/willow_camp/internal/helpers"
+	"github.com/cassiascheffer/willow_camp/internal/markdown"
+	"github.com/cassiascheffer/willow_camp/internal/models"
+)
+
+// memoryEmbeds is an in-memory embeds.Store
+type memoryEmbeds struct {
+	mu     sync.Mutex
+	embeds map[string]*models.Embed
+}
+
+func (m *memoryEmbeds) FindByURLs(ctx context.Context, urls []string) (map[string]*models.Embed, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	found := map[string]*models.Embed{}
+	for _, link := range urls {
+		if e, ok := m.embeds[link]; ok {
+			copied := *e
+			found[link] = &copied
+		}
+	}
+	return found, nil
+}
+
+func (m *memoryEmbeds) Upsert(ctx context.Context, embed *models.Embed) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.embeds == nil {
+		m.embeds = map[string]*models.Embed{}
+	}
+	copied := *embed
+	m.embeds[embed.URL] = &copied
+	return nil
+}
+
+// oEmbedFixture serves canned oEmbed responses keyed by the url parameter
+// and counts the requests for each
+type oEmbedFixture struct {
+	*httptest.Server
+	mu   sync.Mutex
+	hits map[string]int
+}
+
+func newOEmbedFixture(t *testing.T) *oEmbedFixture {
+	f := &oEmbedFixture{hits: map[string]int{}}
+	responses := map[string]map[string]string{
+		"https://video.example/watch/1": {
+			"type":  "video",
+			"title": "Trail run",
+			"html":  `<iframe src="https://video.example/embed/1" width="640" height="360" allowfullscreen></iframe><script src="https://video.example/embed.js"></script>`,
+		},
+		"https://video.example/watch/evil": {
+			"type": "rich",
+			"html": `<iframe src="https://evil.example/"></iframe><img src=x onerror="alert(1)">`,
+		},
+		"https://video.example/photo/1": {"type": "photo", "title": "Summit", "url": "https://video.example/photos/1.jpg"},
+		"https://video.example/link/1":  {"type": "link", "title": "A page worth reading"},
+	}
+
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		link := r.URL.Query().Get("url")
+		f.mu.Lock()
+		f.hits[link]++
+		f.mu.Unlock()
+
+		if r.URL.Path != "/oembed" || r.URL.Query().Get("format") != "json" {
+			http.NotFound(w, r)
+			return
+		}
+		switch link {
+		case "https://video.example/watch/slow":
+			select {
+			case <-time.After(2 * time.Second):
+			case <-r.Context().Done():
+			}
+			return
+		case "https://video.example/watch/broken":
+			http.Error(w, "upstream failed", http.StatusInternalServerError)
+			return
+		}
+		response, ok := responses[link]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(response)
+	}))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *oEmbedFixture) Hits(link string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.hits[link]
+}
+
+func newEmbedService(t *testing.T) (*embeds.Service, *oEmbedFixture, *memoryEmbeds) {
+	fixture := newOEmbedFixture(t)
+	registry := embeds.NewRegistry(
+		embeds.Provider{
+			Name:     "Fixture Video",
+			Schemes:  []string{"https://video.example/*"},
+			Endpoint: fixture.URL + "/oembed",
+			Frames:   []string{"https://video.example/embed/"},
+		},
+		embeds.Provider{Name: "Card Only", Schemes: []string{"https://cards.example/*"}},
+	)
+	store := &memoryEmbeds{}
+	service := embeds.New(registry, store, nil)
+	service.SetClient(&http.Client{Timeout: 200 * time.Millisecond})
+	return service, fixture, store
+}
+
+func TestEmbedURLs(t *testing.T) {
+	source := "Intro with https://video.example/watch/1 inline.\n\n" +
+		"https://video.example/watch/1\n\n" +
+		"{{< embed https://cards.example/page >}}\n\n" +
+		"{{< embed javascript:alert(1) >}}\n\n" +
+		"> https://video.example/quoted\n\n" +
+		"```\nhttps://video.example/code\n```\n"
+
+	urls := markdown.EmbedURLs(source)
+	want := []string{"https://video.example/watch/1", "https://cards.example/page"}
+	if strings.Join(urls, " ") != strings.Join(want, " ") {
+		t.Errorf("Expected %q, got %q", want, urls)
+	}
+}
+
+func TestEmbedsFetchAndCache(t *testing.T) {
+	ctx := context.Background()
+	service, fixture, store := newEmbedService(t)
+	source := "https://video.example/watch/1\n\nAnd again:\n\n{{< embed https://video.example/watch/1 >}}\n"
+
+	if err := service.Prepare(ctx, source); err != nil {
+		t.Fatalf("Failed to prepare embeds: %v", err)
+	}
+	if err := service.Prepare(ctx, source); err != nil {
+		t.Fatalf("Failed to prepare embeds: %v", err)
+	}
+	if hits := fixture.Hits("https://video.example/watch/1"); hits != 1 {
+		t.Errorf("Expected the provider to be asked once, got %d requests", hits)
+	}
+
+	resolve, err := service.Resolver(ctx, source)
+	if err != nil {
+		t.Fatalf("Failed to load embeds: %v", err)
+	}
+	doc, err := markdown.RenderDocument(source, markdown.Resolvers{Embeds: resolve})
+	if err != nil {
+		t.Fatalf("Failed to render: %v", err)
+	}
+	html := string(doc.HTML)
+	want := `<figure class="embed" data-embed-url="https://video.example/watch/1"><iframe src="https://video.example/embed/1" width="640" height="360" allowfullscreen=""></iframe></figure>`
+	if strings.Count(html, want) != 2 {
+		t.Errorf("Expected both forms to embed the video, got %s", html)
+	}
+	if strings.Contains(html, "<script") {
+		t.Errorf("Expected the provider's script to be removed, got %s", html)
+	}
+
+	// Stale embeds are fetched again
+	store.embeds["https://video.example/watch/1"].FetchedAt = time.Now().Add(-embeds.CacheTTL - time.Hour)
+	if err := service.Prepare(ctx, source); err != nil {
+		t.Fatalf("Failed to refresh embeds: %v", err)
+	}
+	if hits := fixture.Hits("https://video.example/watch/1"); hits != 2 {
+		t.Errorf("Expected a stale embed to be fetched again, got %d requests", hits)
+	}
+}
+
+func TestEmbedResponseTypes(t *testing.T) {
+	ctx := context.Background()
+	service, _, _ := newEmbedService(t)
+
+	cases := []struct {
+		link  string
+		html  string
+		title string
+	}{
+		{"https://video.example/photo/1", `<img src="https://video.example/photos/1.jpg" alt="Summit">`, "Summit"},
+		{"https://video.example/link/1", "", "A page worth reading"},
+		// Iframes from other hosts and event handlers are removed
+		{"https://video.example/watch/evil", `<img src="x">`, ""},
+	}
+	for _, tc := range cases {
+		embed, err := service.Fetch(ctx, tc.link)
+		if err != nil {
+			t.Fatalf("Failed to fetch %s: %v", tc.link, err)
+		}
+		if embed.HTML != tc.html || embed.Title != tc.title || embed.Provider != "Fixture Video" {
+			t.Errorf("Fetch(%s) = %q, %q, %q", tc.link, embed.HTML, embed.Title, embed.Provider)
+		}
+	}
+
+	if _, err := service.Fetch(ctx, "https://unknown.example/x"); err != embeds.ErrUnknownProvider {
+		t.Errorf("Expected ErrUnknownProvider, got %v", err)
+	}
+}
+
+func TestEmbedFallbacks(t *testing.T) {
+	ctx := context.Background()
+	service, _, _ := newEmbedService(t)
+	source := "https://video.example/watch/broken\n\n" +
+		"https://video.example/link/1\n\n" +
+		"https://unknown.example/page\n\n" +
+		"{{< embed https://unknown.example/other >}}\n\n" +
+		"https://cards.example/gist\n"
+
+	start := time.Now()
+	if err := service.Prepare(ctx, "https://video.example/watch/slow\n"); err == nil {
+		t.Error("Expected a slow provider to time out")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the request to be cut off by the timeout, took %s", elapsed)
+	}
+	if err := service.Prepare(ctx, source); err == nil || !strings.Contains(err.Error(), "500") {
+		t.Errorf("Expected the failing provider's error, got %v", err)
+	}
+
+	resolve, err := service.Resolver(ctx, source)
+	if err != nil {
+		t.Fatalf("Failed to load embeds: %v", err)
+	}
+	doc, err := markdown.RenderDocument(source, markdown.Resolvers{Embeds: resolve})
+	if err != nil {
+		t.Fatalf("Failed to render: %v", err)
+	}
+	html := string(doc.HTML)
+
+	for _, want := range []string{
+		// A provider that failed shows a card until a later save fetches it
+		`<p class="embed-card"><a href="https://video.example/watch/broken"><span class="embed-card-title">https://video.example/watch/broken</span><span class="embed-card-site">Fixture Video</span></a></p>`,
+		// Link responses become a card with their title
+		`<span class="embed-card-title">A page worth reading</span>`,
+		// Bare links no provider handles stay links
+		`<p><a href="https://unknown.example/page">https://unknown.example/page</a></p>`,
+		// Shortcodes always become a card
+		`<p class="embed-card"><a href="https://unknown.example/other"><span class="embed-card-title">https://unknown.example/other</span><span class="embed-card-site">unknown.example</span></a></p>`,
+		`<span class="embed-card-site">Card Only</span>`,
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("Expected %s in %s", want, html)
+		}
+	}
+}
+
+func TestFeedEmbedsBecomeLinks(t *testing.T) {
+	content := `<figure class="embed" data-embed-url="https://video.example/watch/1"><iframe src="https://video.example/embed/1"></iframe></figure>`
+
+	got := helpers.SanitizeHTMLForFeed(content, "https", "camp.willow.camp", 443, "/post")
+	want := `<p><a href="https://video.example/watch/1">https://video.example/watch/1</a></p>`
+	if !strings.Contains(got, want) || strings.Contains(got, "iframe") {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+}
+
+func TestDefaultEmbedProviders(t *testing.T) {
+	registry := embeds.NewRegistry(embeds.DefaultProviders...)
+	cases := map[string]string{
+		"https://www.youtube.com/watch?v=dQw4w9WgXcQ": "YouTube",
+		"https://youtu.be/dQw4w9WgXcQ":                "YouTube",
+		"https://vimeo.com/76979871":                  "Vimeo",
+		"https://mastodon.social/@Gargron/1":          "Mastodon",
+		"https://codepen.io/someone/pen/abc":          "CodePen",
+		"https://codesandbox.io/s/new":                "CodeSandbox",
+		"https://gist.github.com/someone/abc":         "GitHub Gist",
+	}
+	for link, name := range cases {
+		if p := registry.Match(link); p == nil || p.Name != name {
+			t.Errorf("Expected %s to match %s, got %+v", link, name, p)
+		}
+	}
+	for _, link := range []string{"https://www.youtube.com/", "https://evil.example/@user/1", "https://vimeo.com.evil.example/1"} {
+		if p := registry.Match(link); p != nil {
+			t.Errorf("Expected %s not to match, got %s", link, p.Name)
+		}
+	}
+}
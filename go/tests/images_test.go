@@ -0,0 +1,195 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cassiascheffer/willow_camp/internal/markdown"
+	"github.com/cassiascheffer/willow_camp/internal/media"
+	"github.com/cassiascheffer/willow_camp/internal/models"
+	"github.com/google/uuid"
+)
+
+// exifSegment builds a JPEG APP1 segment with an orientation tag and a GPS-ish marker string
+func exifSegment(orientation uint16) []byte {
+	tiff := []byte("II*\x00\x08\x00\x00\x00")
+	tiff = binary.LittleEndian.AppendUint16(tiff, 1)      // one IFD entry
+	tiff = binary.LittleEndian.AppendUint16(tiff, 0x0112) // orientation
+	tiff = binary.LittleEndian.AppendUint16(tiff, 3)      // SHORT
+	tiff = binary.LittleEndian.AppendUint32(tiff, 1)
+	tiff = binary.LittleEndian.AppendUint16(tiff, orientation)
+	tiff = append(tiff, 0, 0, 0, 0, 0, 0)
+	tiff = append(tiff, "GPS 45.5N 73.6W"...)
+
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	segment := []byte{0xFF, 0xE1}
+	segment = binary.BigEndian.AppendUint16(segment, uint16(len(payload)+2))
+	return append(segment, payload...)
+}
+
+// testJPEG encodes a w x h JPEG with an EXIF segment inserted after SOI
+func testJPEG(t *testing.T, w, h int, orientation uint16) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for x := 0; x < w; x++ {
+		for y := 0; y < h; y++ {
+			img.Set(x, y, color.RGBA{uint8(x * 10), uint8(y * 10), 100, 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatalf("Failed to encode JPEG: %v", err)
+	}
+	data := buf.Bytes()
+	return append(append(append([]byte{}, data[:2]...), exifSegment(orientation)...), data[2:]...)
+}
+
+// TestStripJPEGMetadata verifies EXIF is removed and the image still decodes
+func TestStripJPEGMetadata(t *testing.T) {
+	data := testJPEG(t, 8, 4, 1)
+
+	stripped, err := media.StripMetadata("image/jpeg", data)
+	if err != nil {
+		t.Fatalf("StripMetadata failed: %v", err)
+	}
+	if bytes.Contains(stripped, []byte("Exif")) || bytes.Contains(stripped, []byte("GPS")) {
+		t.Error("Expected EXIF data to be removed")
+	}
+
+	config, err := jpeg.DecodeConfig(bytes.NewReader(stripped))
+	if err != nil {
+		t.Fatalf("Stripped JPEG doesn't decode: %v", err)
+	}
+	if config.Width != 8 || config.Height != 4 {
+		t.Errorf("Expected 8x4, got %dx%d", config.Width, config.Height)
+	}
+}
+
+// TestStripJPEGMetadataAppliesOrientation verifies rotated photos are re-encoded upright
+func TestStripJPEGMetadataAppliesOrientation(t *testing.T) {
+	data := testJPEG(t, 8, 4, 6) // rotate 90 degrees clockwise
+
+	stripped, err := media.StripMetadata("image/jpeg", data)
+	if err != nil {
+		t.Fatalf("StripMetadata failed: %v", err)
+	}
+	if bytes.Contains(stripped, []byte("Exif")) {
+		t.Error("Expected EXIF data to be removed")
+	}
+
+	config, err := jpeg.DecodeConfig(bytes.NewReader(stripped))
+	if err != nil {
+		t.Fatalf("Stripped JPEG doesn't decode: %v", err)
+	}
+	if config.Width != 4 || config.Height != 8 {
+		t.Errorf("Expected rotated 4x8, got %dx%d", config.Width, config.Height)
+	}
+}
+
+// TestStripPNGMetadata verifies text chunks are removed from PNGs
+func TestStripPNGMetadata(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 2, 2))); err != nil {
+		t.Fatalf("Failed to encode PNG: %v", err)
+	}
+	data := buf.Bytes()
+
+	// Insert a tEXt chunk after IHDR (8 byte signature + 25 byte IHDR chunk)
+	text := []byte("Author\x00Secret Person")
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(text)))
+	chunk = append(chunk, "tEXt"...)
+	chunk = append(chunk, text...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(append([]byte("tEXt"), text...)))
+	data = append(append(append([]byte{}, data[:33]...), chunk...), data[33:]...)
+
+	stripped, err := media.StripMetadata("image/png", data)
+	if err != nil {
+		t.Fatalf("StripMetadata failed: %v", err)
+	}
+	if bytes.Contains(stripped, []byte("Secret Person")) {
+		t.Error("Expected tEXt chunk to be removed")
+	}
+	if _, err := png.Decode(bytes.NewReader(stripped)); err != nil {
+		t.Errorf("Stripped PNG doesn't decode: %v", err)
+	}
+}
+
+// TestRenderResponsiveImages verifies own media gets srcset, dimensions and lazy loading
+func TestRenderResponsiveImages(t *testing.T) {
+	resolve := func(src string) (markdown.ImageInfo, bool) {
+		if src != "/media/abc.jpg" {
+			return markdown.ImageInfo{}, false
+		}
+		return markdown.ImageInfo{
+			Width:  1200,
+			Height: 800,
+			Srcset: "/media/abc.jpg/480w.jpg 480w, /media/abc.jpg 1200w",
+		}, true
+	}
+
+	html, err := markdown.RenderWithImages("![Tent](/media/abc.jpg)\n\n![Elsewhere](https://example.com/a.jpg)", resolve)
+	if err != nil {
+		t.Fatalf("RenderWithImages failed: %v", err)
+	}
+	out := string(html)
+
+	for _, want := range []string{
+		`srcset="/media/abc.jpg/480w.jpg 480w, /media/abc.jpg 1200w"`,
+		`width="1200"`,
+		`height="800"`,
+		`loading="lazy"`,
+		`sizes="`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected output to contain %s, got %s", want, out)
+		}
+	}
+
+	external := out[strings.Index(out, "example.com"):]
+	if strings.Contains(external, "loading=") || strings.Contains(external, "srcset=") {
+		t.Errorf("Expected external image to be left alone, got %s", external)
+	}
+
+	// Without a resolver, rendering matches plain Render
+	plain, _ := markdown.Render("![Tent](/media/abc.jpg)")
+	withNil, _ := markdown.RenderWithImages("![Tent](/media/abc.jpg)", nil)
+	if plain != withNil {
+		t.Errorf("Expected nil resolver to render like Render: %s vs %s", plain, withNil)
+	}
+}
+
+// pngHeader builds the signature and IHDR chunk of a PNG claiming the given
+// dimensions, which is all image.DecodeConfig reads
+func pngHeader(width, height uint32) []byte {
+	ihdr := binary.BigEndian.AppendUint32(nil, width)
+	ihdr = binary.BigEndian.AppendUint32(ihdr, height)
+	ihdr = append(ihdr, 8, 6, 0, 0, 0) // 8-bit RGBA, no interlacing
+	data := []byte("\x89PNG\r\n\x1a\n")
+	data = binary.BigEndian.AppendUint32(data, uint32(len(ihdr)))
+	data = append(data, "IHDR"...)
+	data = append(data, ihdr...)
+	return binary.BigEndian.AppendUint32(data, crc32.ChecksumIEEE(append([]byte("IHDR"), ihdr...)))
+}
+
+// TestUploadRejectsHugeDimensions verifies images over the pixel cap are
+// refused from their header, before anything decodes them
+func TestUploadRejectsHugeDimensions(t *testing.T) {
+	service := media.New(nil, nil, newJobQueue(newMemoryJobs(), time.Second), "localhost:3001")
+
+	for _, size := range [][2]uint32{{100000, 100000}, {media.MaxPixels/1000 + 1, 1000}} {
+		_, err := service.Upload(context.Background(), &models.Blog{ID: uuid.New()}, nil, "bomb.png", bytes.NewReader(pngHeader(size[0], size[1])))
+		if !errors.Is(err, media.ErrTooLarge) {
+			t.Errorf("Expected ErrTooLarge for a %dx%d PNG, got %v", size[0], size[1], err)
+		}
+	}
+}
@@ -7,11 +7,13 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/cassiascheffer/willow_camp/internal/auth"
 	bloghandlers "github.com/cassiascheffer/willow_camp/internal/blog/handlers"
 	blogmiddleware "github.com/cassiascheffer/willow_camp/internal/blog/middleware"
 	dashboardhandlers "github.com/cassiascheffer/willow_camp/internal/dashboard/handlers"
+	"github.com/cassiascheffer/willow_camp/internal/models"
 	"github.com/cassiascheffer/willow_camp/internal/repository"
 	sharedhandlers "github.com/cassiascheffer/willow_camp/internal/shared/handlers"
 	"github.com/google/uuid"
@@ -94,6 +96,126 @@ func stringPtr(s string) *string {
 	return &s
 }
 
+// setupTestDB connects to the database for repository tests, skipping the
+// test without one
+func setupTestDB(t *testing.T) (*pgxpool.Pool, *repository.Repositories) {
+	t.Helper()
+	dbURL := os.Getenv("DATABASE_URL")
+	if dbURL == "" {
+		dbURL = "postgres://localhost/willow_camp_development?sslmode=disable"
+	}
+
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		t.Skipf("Skipping integration test - cannot connect to database: %v", err)
+	}
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		t.Skipf("Skipping integration test - cannot ping database: %v", err)
+	}
+	t.Cleanup(pool.Close)
+
+	return pool, repository.NewRepositories(pool)
+}
+
+// createTestBlog creates a user with a blog, removed with its posts and
+// their taggings when the test ends
+func createTestBlog(t *testing.T, pool *pgxpool.Pool, repos *repository.Repositories) *models.Blog {
+	t.Helper()
+	ctx := context.Background()
+	name := "test-" + uuid.NewString()[:8]
+
+	var userID uuid.UUID
+	err := pool.QueryRow(ctx, `
+		INSERT INTO users (email, encrypted_password, created_at, updated_at)
+		VALUES ($1, '', NOW(), NOW())
+		RETURNING id
+	`, name+"@example.com").Scan(&userID)
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	blog, err := repos.Blog.Create(ctx, userID, name, false)
+	if err != nil {
+		t.Fatalf("Failed to create blog: %v", err)
+	}
+
+	t.Cleanup(func() {
+		ctx := context.Background()
+		for _, query := range []string{
+			`DELETE FROM taggings WHERE taggable_type = 'Post' AND taggable_id IN (SELECT id FROM posts WHERE blog_id = $1)`,
+			`DELETE FROM posts WHERE blog_id = $1`,
+			`DELETE FROM blogs WHERE id = $1`,
+		} {
+			if _, err := pool.Exec(ctx, query, blog.ID); err != nil {
+				t.Errorf("Failed to clean up blog: %v", err)
+			}
+		}
+		if _, err := pool.Exec(ctx, `DELETE FROM users WHERE id = $1`, userID); err != nil {
+			t.Errorf("Failed to clean up user: %v", err)
+		}
+	})
+	return blog
+}
+
+// createTestPost creates a post on a blog, published at the given time
+// unless it's zero
+func createTestPost(t *testing.T, repos *repository.Repositories, blog *models.Blog, slug string, publishedAt time.Time) *models.Post {
+	t.Helper()
+	published := !publishedAt.IsZero()
+	post := &models.Post{
+		BlogID:    blog.ID,
+		AuthorID:  blog.UserID,
+		Title:     stringPtr(slug),
+		Slug:      stringPtr(slug),
+		Published: &published,
+		Type:      stringPtr("Post"),
+	}
+	if published {
+		post.PublishedAt = &publishedAt
+	}
+	if err := repos.Post.Create(context.Background(), post); err != nil {
+		t.Fatalf("Failed to create post: %v", err)
+	}
+	return post
+}
+
+// setTestPostVisibility changes who can see a test post
+func setTestPostVisibility(t *testing.T, pool *pgxpool.Pool, post *models.Post, visibility string) {
+	t.Helper()
+	if _, err := pool.Exec(context.Background(), `UPDATE posts SET visibility = $2 WHERE id = $1`, post.ID, visibility); err != nil {
+		t.Fatalf("Failed to set post visibility: %v", err)
+	}
+	post.Visibility = visibility
+}
+
+// createTestTag creates a tag with a unique name, deleted with its taggings
+// after the test
+func createTestTag(t *testing.T, pool *pgxpool.Pool, repos *repository.Repositories) *models.Tag {
+	t.Helper()
+	name := "tag-" + uuid.NewString()[:8]
+	tag, err := repos.Tag.FindOrCreateByName(context.Background(), name, name)
+	if err != nil {
+		t.Fatalf("Failed to create tag: %v", err)
+	}
+	t.Cleanup(func() {
+		ctx := context.Background()
+		_, _ = pool.Exec(ctx, `DELETE FROM taggings WHERE tag_id = $1`, tag.ID)
+		_, _ = pool.Exec(ctx, `DELETE FROM tags WHERE id = $1`, tag.ID)
+	})
+	return tag
+}
+
+// tagTestPost tags a test post
+func tagTestPost(t *testing.T, repos *repository.Repositories, post *models.Post, tags ...*models.Tag) {
+	t.Helper()
+	for _, tag := range tags {
+		if err := repos.Tag.CreateTagging(context.Background(), post.ID, tag.ID); err != nil {
+			t.Fatalf("Failed to tag post: %v", err)
+		}
+	}
+}
+
 // TestAuthenticationFlow tests login page is accessible
 func TestAuthenticationFlow(t *testing.T) {
 	app, _ := setupTestServer(t)
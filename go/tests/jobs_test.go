@@ -0,0 +1,323 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cassiascheffer/willow_camp/internal/jobs"
+	"github.com/cassiascheffer/willow_camp/internal/logging"
+	"github.com/cassiascheffer/willow_camp/internal/models"
+	"github.com/cassiascheffer/willow_camp/internal/repository"
+	"github.com/google/uuid"
+)
+
+// TestJobBackoff verifies retries back off exponentially and stay capped at an hour
+func TestJobBackoff(t *testing.T) {
+	previous := time.Duration(0)
+	for attempt := 1; attempt <= 8; attempt++ {
+		delay := jobs.Backoff(attempt)
+		if delay <= previous {
+			t.Errorf("Attempt %d: expected backoff to grow, got %v after %v", attempt, delay, previous)
+		}
+		previous = delay
+	}
+
+	if delay := jobs.Backoff(1); delay < 15*time.Second || delay > 17*time.Second {
+		t.Errorf("Expected first retry after ~15s, got %v", delay)
+	}
+
+	for _, attempt := range []int{9, 20, 100} {
+		if delay := jobs.Backoff(attempt); delay < time.Hour || delay > time.Hour+6*time.Minute {
+			t.Errorf("Attempt %d: expected backoff capped at ~1h, got %v", attempt, delay)
+		}
+	}
+}
+
+// TestPermanentJobError verifies permanent failures are detectable through wrapping
+func TestPermanentJobError(t *testing.T) {
+	base := errors.New("bad payload")
+	err := fmt.Errorf("handler: %w", jobs.Permanent(base))
+
+	if !jobs.IsPermanent(err) {
+		t.Error("Expected wrapped permanent error to be detected")
+	}
+	if !errors.Is(err, base) {
+		t.Error("Expected permanent error to unwrap to the original error")
+	}
+	if jobs.IsPermanent(base) {
+		t.Error("Expected plain error not to be permanent")
+	}
+	if jobs.Permanent(nil) != nil {
+		t.Error("Expected Permanent(nil) to be nil")
+	}
+}
+
+// memoryJobs is an in-memory jobs.Store. Like the database, it refuses writes
+// on an expired context. Every recorded outcome is sent on outcomes.
+type memoryJobs struct {
+	mu       sync.Mutex
+	jobs     []*models.Job
+	outcomes chan string
+}
+
+func newMemoryJobs() *memoryJobs {
+	return &memoryJobs{outcomes: make(chan string, 10)}
+}
+
+func (m *memoryJobs) Enqueue(ctx context.Context, job *models.Job) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job.ID = uuid.New()
+	job.Status = "pending"
+	copied := *job
+	m.jobs = append(m.jobs, &copied)
+	return nil
+}
+
+func (m *memoryJobs) ClaimNext(ctx context.Context, queue, workerID string) (*models.Job, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, job := range m.jobs {
+		if job.Queue == queue && job.Status == "pending" && !job.RunAt.After(time.Now()) {
+			job.Status = "running"
+			job.Attempts++
+			job.LockedBy = &workerID
+			copied := *job
+			return &copied, nil
+		}
+	}
+	return nil, repository.ErrJobNotFound
+}
+
+func (m *memoryJobs) finish(ctx context.Context, id uuid.UUID, outcome string, update func(job *models.Job)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	for _, job := range m.jobs {
+		if job.ID == id {
+			update(job)
+			job.LockedBy = nil
+		}
+	}
+	m.mu.Unlock()
+	m.outcomes <- outcome
+	return nil
+}
+
+func (m *memoryJobs) MarkDone(ctx context.Context, id uuid.UUID) error {
+	return m.finish(ctx, id, "done", func(job *models.Job) { job.Status = "done" })
+}
+
+func (m *memoryJobs) Reschedule(ctx context.Context, id uuid.UUID, runAt time.Time, lastError string) error {
+	return m.finish(ctx, id, "retry", func(job *models.Job) {
+		job.Status = "pending"
+		job.RunAt = runAt
+		job.LastError = &lastError
+	})
+}
+
+func (m *memoryJobs) MarkDead(ctx context.Context, id uuid.UUID, lastError string) error {
+	return m.finish(ctx, id, "dead", func(job *models.Job) {
+		job.Status = "dead"
+		job.LastError = &lastError
+	})
+}
+
+func (m *memoryJobs) ReleaseStale(ctx context.Context, staleAfter time.Duration) (int64, error) {
+	return 0, nil
+}
+
+func (m *memoryJobs) DeleteFinishedBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	return 0, nil
+}
+
+// job returns a copy of a stored job
+func (m *memoryJobs) job(id uuid.UUID) models.Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, job := range m.jobs {
+		if job.ID == id {
+			return *job
+		}
+	}
+	return models.Job{}
+}
+
+// startJobQueue runs the queue's worker until the test ends
+func startJobQueue(t *testing.T, q *jobs.Queue) {
+	t.Helper()
+	q.Start()
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := q.Shutdown(ctx); err != nil {
+			t.Errorf("Queue did not shut down: %v", err)
+		}
+	})
+}
+
+func newJobQueue(store *memoryJobs, jobTimeout time.Duration) *jobs.Queue {
+	return jobs.New(store, jobs.Config{Workers: 1, PollInterval: 10 * time.Millisecond, JobTimeout: jobTimeout}, logging.NewLogger())
+}
+
+func waitJobOutcome(t *testing.T, store *memoryJobs) string {
+	t.Helper()
+	select {
+	case outcome := <-store.outcomes:
+		return outcome
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the job to finish")
+		return ""
+	}
+}
+
+// TestJobQueueRunsDueJobs verifies workers claim jobs that are due, run them
+// with their payload and mark them done, leaving scheduled jobs alone
+func TestJobQueueRunsDueJobs(t *testing.T) {
+	store := newMemoryJobs()
+	q := newJobQueue(store, time.Minute)
+
+	var got string
+	q.Register("greet", func(ctx context.Context, job *models.Job) error {
+		var payload struct{ Name string }
+		if err := job.DecodePayload(&payload); err != nil {
+			return err
+		}
+		got = payload.Name
+		return nil
+	})
+
+	later, err := q.Enqueue(context.Background(), "greet", map[string]string{"Name": "later"}, jobs.Delay(time.Hour))
+	if err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+	now, err := q.Enqueue(context.Background(), "greet", map[string]string{"Name": "now"})
+	if err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+	startJobQueue(t, q)
+
+	if outcome := waitJobOutcome(t, store); outcome != "done" {
+		t.Fatalf("Expected the job to be done, got %q", outcome)
+	}
+	if got != "now" {
+		t.Errorf("Expected the due job to run with its payload, got %q", got)
+	}
+	if job := store.job(now.ID); job.Status != "done" || job.Attempts != 1 {
+		t.Errorf("Expected the due job done after 1 attempt, got %s after %d", job.Status, job.Attempts)
+	}
+	if job := store.job(later.ID); job.Status != "pending" || job.Attempts != 0 {
+		t.Errorf("Expected the scheduled job to wait, got %s after %d attempts", job.Status, job.Attempts)
+	}
+}
+
+// TestJobQueueRetriesFailures verifies a failed job goes back in the queue
+// with backoff and its error
+func TestJobQueueRetriesFailures(t *testing.T) {
+	store := newMemoryJobs()
+	q := newJobQueue(store, time.Minute)
+	q.Register("flaky", func(ctx context.Context, job *models.Job) error {
+		return errors.New("connection refused")
+	})
+
+	job, err := q.Enqueue(context.Background(), "flaky", nil, jobs.MaxAttempts(3))
+	if err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+	startJobQueue(t, q)
+
+	if outcome := waitJobOutcome(t, store); outcome != "retry" {
+		t.Fatalf("Expected the job to be retried, got %q", outcome)
+	}
+	saved := store.job(job.ID)
+	if saved.Status != "pending" || saved.LastError == nil || *saved.LastError != "connection refused" {
+		t.Errorf("Expected the job pending with its error, got %+v", saved)
+	}
+	if wait := time.Until(saved.RunAt); wait < 10*time.Second || wait > 17*time.Second {
+		t.Errorf("Expected the retry ~15s out, got %v", wait)
+	}
+}
+
+// TestJobQueueDeadLetters verifies jobs that run out of attempts, fail
+// permanently or have no handler are dead-lettered
+func TestJobQueueDeadLetters(t *testing.T) {
+	store := newMemoryJobs()
+	q := newJobQueue(store, time.Minute)
+	q.Register("failing", func(ctx context.Context, job *models.Job) error {
+		return errors.New("still broken")
+	})
+	q.Register("invalid", func(ctx context.Context, job *models.Job) error {
+		return jobs.Permanent(errors.New("bad payload"))
+	})
+
+	exhausted, _ := q.Enqueue(context.Background(), "failing", nil, jobs.MaxAttempts(1))
+	permanent, _ := q.Enqueue(context.Background(), "invalid", nil)
+	unknown, _ := q.Enqueue(context.Background(), "unknown", nil)
+	startJobQueue(t, q)
+
+	for i := 0; i < 3; i++ {
+		if outcome := waitJobOutcome(t, store); outcome != "dead" {
+			t.Fatalf("Expected every job to be dead-lettered, got %q", outcome)
+		}
+	}
+	for name, id := range map[string]uuid.UUID{"exhausted": exhausted.ID, "permanent": permanent.ID, "unknown": unknown.ID} {
+		if job := store.job(id); job.Status != "dead" || job.Attempts != 1 || job.LastError == nil {
+			t.Errorf("Expected the %s job dead after 1 attempt with its error, got %+v", name, job)
+		}
+	}
+}
+
+// TestJobQueueTimeout verifies a job that runs past JobTimeout is still
+// rescheduled, even though its own context has expired
+func TestJobQueueTimeout(t *testing.T) {
+	store := newMemoryJobs()
+	q := newJobQueue(store, 50*time.Millisecond)
+	q.Register("slow", func(ctx context.Context, job *models.Job) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	job, err := q.Enqueue(context.Background(), "slow", nil)
+	if err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+	startJobQueue(t, q)
+
+	if outcome := waitJobOutcome(t, store); outcome != "retry" {
+		t.Fatalf("Expected the timed out job to be retried, got %q", outcome)
+	}
+	if saved := store.job(job.ID); saved.Status != "pending" || saved.LastError == nil || !strings.Contains(*saved.LastError, "deadline exceeded") {
+		t.Errorf("Expected the job pending with the timeout error, got %+v", saved)
+	}
+}
+
+// TestJobQueueBuild verifies built jobs get the queue's defaults and options
+// but aren't stored until the caller inserts them
+func TestJobQueueBuild(t *testing.T) {
+	store := newMemoryJobs()
+	q := newJobQueue(store, time.Minute)
+	blogID := uuid.New()
+
+	job, err := q.Build("deliver", map[string]string{"To": "reader@example.com"}, jobs.ForBlog(blogID), jobs.MaxAttempts(3))
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	if job.Queue != "default" || job.Kind != "deliver" || job.MaxAttempts != 3 || job.BlogID == nil || *job.BlogID != blogID {
+		t.Errorf("Expected the queue's defaults and the options, got %+v", job)
+	}
+	if string(job.Payload) != `{"To":"reader@example.com"}` {
+		t.Errorf("Expected the payload as JSON, got %s", job.Payload)
+	}
+	if len(store.jobs) != 0 {
+		t.Errorf("Expected nothing stored, got %d jobs", len(store.jobs))
+	}
+}
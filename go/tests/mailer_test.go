@@ -0,0 +1,156 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"mime"
+	"net"
+	"net/mail"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cassiascheffer/willow_camp/internal/mailer"
+)
+
+// smtpSink is a minimal in-process SMTP server that records delivered messages
+type smtpSink struct {
+	listener net.Listener
+	mu       sync.Mutex
+	messages []string
+}
+
+func newSMTPSink(t *testing.T) *smtpSink {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to start SMTP sink: %v", err)
+	}
+
+	sink := &smtpSink{listener: listener}
+	go sink.serve()
+	t.Cleanup(func() { listener.Close() })
+
+	return sink
+}
+
+func (s *smtpSink) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *smtpSink) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	write := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	write("220 sink ready")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			write("250 sink")
+		case strings.HasPrefix(cmd, "MAIL"), strings.HasPrefix(cmd, "RCPT"), strings.HasPrefix(cmd, "RSET"), strings.HasPrefix(cmd, "NOOP"):
+			write("250 OK")
+		case cmd == "DATA":
+			write("354 end with .")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(l)
+			}
+			s.mu.Lock()
+			s.messages = append(s.messages, data.String())
+			s.mu.Unlock()
+			write("250 queued")
+		case cmd == "QUIT":
+			write("221 bye")
+			return
+		default:
+			write("502 not implemented")
+		}
+	}
+}
+
+func (s *smtpSink) Messages() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.messages...)
+}
+
+// TestSMTPMailer verifies messages reach an SMTP server with one-click unsubscribe headers
+func TestSMTPMailer(t *testing.T) {
+	sink := newSMTPSink(t)
+	host, port, _ := net.SplitHostPort(sink.listener.Addr().String())
+
+	m := mailer.NewSMTPMailer(mailer.SMTPConfig{Host: host, Port: port})
+	err := m.Send(context.Background(), &mailer.Message{
+		From:     "Test Blog <no-reply@example.com>",
+		To:       "reader@example.com",
+		Subject:  "Hello from the camp ⛺",
+		TextBody: "Plain body",
+		HTMLBody: "<p>HTML body</p>",
+		Headers: map[string]string{
+			"List-Unsubscribe":      "<https://blog.example.com/unsubscribe?token=abc>",
+			"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+		},
+	})
+	if err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	messages := sink.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("Expected 1 message, got %d", len(messages))
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(messages[0]))
+	if err != nil {
+		t.Fatalf("Failed to parse delivered message: %v", err)
+	}
+
+	if got := msg.Header.Get("List-Unsubscribe-Post"); got != "List-Unsubscribe=One-Click" {
+		t.Errorf("Expected one-click List-Unsubscribe-Post header, got %q", got)
+	}
+	if got := msg.Header.Get("List-Unsubscribe"); got != "<https://blog.example.com/unsubscribe?token=abc>" {
+		t.Errorf("Unexpected List-Unsubscribe header %q", got)
+	}
+	if !strings.HasPrefix(msg.Header.Get("Content-Type"), "multipart/alternative") {
+		t.Errorf("Expected multipart/alternative, got %q", msg.Header.Get("Content-Type"))
+	}
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || subject != "Hello from the camp ⛺" {
+		t.Errorf("Unexpected subject %q (err %v)", subject, err)
+	}
+}
+
+// TestMessageRejectsHeaderInjection verifies header values can't smuggle extra headers
+func TestMessageRejectsHeaderInjection(t *testing.T) {
+	msg := &mailer.Message{
+		From:     "no-reply@example.com",
+		To:       "reader@example.com",
+		Subject:  "Hi",
+		TextBody: "Body",
+		Headers:  map[string]string{"List-Id": "evil\r\nBcc: victim@example.com"},
+	}
+	if _, err := msg.Bytes(); err == nil {
+		t.Error("Expected error for header value containing CRLF")
+	}
+}
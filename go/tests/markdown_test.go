@@ -0,0 +1,233 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cassiascheffer/willow_camp/internal/markdown"
+	"github.com/cassiascheffer/willow_camp/internal/models"
+)
+
+const documentSource = `# Packing list
+
+Everything you need for a *weekend* in the woods.
+
+## Shelter
+
+![Tent at dusk](/media/tent.jpg)
+
+A tent and a tarp.
+
+### Stakes
+
+Bring extra.
+
+` + "```sh\necho these words are not counted\n```" + `
+
+## Food and ` + "`water`" + `
+
+Pack it in, pack it out.
+`
+
+func TestRenderDocument(t *testing.T) {
+	doc, err := markdown.RenderDocument(documentSource, markdown.Resolvers{})
+	if err != nil {
+		t.Fatalf("Failed to render: %v", err)
+	}
+
+	if !strings.Contains(string(doc.HTML), `<h2 id="shelter">Shelter</h2>`) {
+		t.Errorf("Expected heading ids in the HTML, got %s", doc.HTML)
+	}
+	if doc.FirstImage != "/media/tent.jpg" {
+		t.Errorf("Expected the first image, got %q", doc.FirstImage)
+	}
+
+	// Packing list > Shelter > Stakes, Packing list > Food and water
+	if len(doc.TOC) != 1 || doc.TOC.Len() != 4 {
+		t.Fatalf("Expected one top-level heading and four in total, got %d and %d", len(doc.TOC), doc.TOC.Len())
+	}
+	top := doc.TOC[0]
+	if top.ID != "packing-list" || len(top.Children) != 2 {
+		t.Fatalf("Expected packing-list with two sections, got %+v", top)
+	}
+	shelter, food := top.Children[0], top.Children[1]
+	if shelter.Text != "Shelter" || len(shelter.Children) != 1 || shelter.Children[0].ID != "stakes" {
+		t.Errorf("Expected Stakes under Shelter, got %+v", shelter)
+	}
+	if food.Text != "Food and water" || food.Level != 2 {
+		t.Errorf("Expected inline code to be plain text in headings, got %+v", food)
+	}
+
+	// Headings and prose count, alt text and code blocks don't
+	if doc.WordCount != 29 {
+		t.Errorf("Expected 29 words, got %d", doc.WordCount)
+	}
+	if doc.ReadingTime != 1 {
+		t.Errorf("Expected a one minute read, got %d", doc.ReadingTime)
+	}
+}
+
+func TestReadingTime(t *testing.T) {
+	if doc := markdown.Analyze(""); doc.WordCount != 0 || doc.ReadingTime != 0 {
+		t.Errorf("Expected no reading time for an empty post, got %+v", doc)
+	}
+
+	long := strings.Repeat("word ", markdown.WordsPerMinute*2+1)
+	doc := markdown.Analyze(long)
+	if doc.WordCount != markdown.WordsPerMinute*2+1 || doc.ReadingTime != 3 {
+		t.Errorf("Expected reading time to round up, got %d words and %d minutes", doc.WordCount, doc.ReadingTime)
+	}
+
+	rendered, err := markdown.RenderDocument(documentSource, markdown.Resolvers{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if analyzed := markdown.Analyze(documentSource); analyzed.WordCount != rendered.WordCount || analyzed.TOC.Len() != rendered.TOC.Len() {
+		t.Errorf("Expected Analyze to match RenderDocument, got %+v and %+v", analyzed, rendered)
+	}
+}
+
+const extensionsSource = `Costs $5 or $10 -- "cheap".
+
+Euler: $e^{i\pi} + 1 = 0$[^1]
+
+$$
+\frac{a}{b}
+$$
+
+Trail
+: A path through the woods
+
+> [!WARNING]
+> Watch for *bears*.
+
+[^1]: Mostly.
+`
+
+func TestCodeHighlighting(t *testing.T) {
+	html, err := markdown.RenderString("```go {linenos=true hl_lines=[2]}\npackage main\nfunc main() {}\n```\n")
+	if err != nil {
+		t.Fatalf("Failed to render: %v", err)
+	}
+
+	// Colors come from the blog's highlight stylesheet, not inline styles
+	if !strings.Contains(html, `<pre tabindex="0" class="chroma">`) || strings.Contains(html, "style=") {
+		t.Errorf("Expected class-based highlighting, got %s", html)
+	}
+	if !strings.Contains(html, `<span class="kd">func</span>`) {
+		t.Errorf("Expected token classes, got %s", html)
+	}
+	if !strings.Contains(html, `<span class="ln">1</span>`) || !strings.Contains(html, `<span class="line hl"><span class="ln">2</span>`) {
+		t.Errorf("Expected numbered lines with line 2 highlighted, got %s", html)
+	}
+
+	plain, err := markdown.RenderString("```go\nx := 1\n```\n")
+	if err != nil {
+		t.Fatalf("Failed to render: %v", err)
+	}
+	if strings.Contains(plain, `class="ln"`) || strings.Contains(plain, "line hl") {
+		t.Errorf("Expected no line numbers without attributes, got %s", plain)
+	}
+}
+
+func TestMarkdownOptionsOffByDefault(t *testing.T) {
+	html, err := markdown.For(models.MarkdownOptions{}).RenderString(extensionsSource)
+	if err != nil {
+		t.Fatalf("Failed to render: %v", err)
+	}
+	for _, want := range []string{`$e^{i\pi} + 1 = 0$`, "$$<br />", "-- &quot;cheap&quot;", "<p>Trail<br />\n: A path", "<blockquote>\n<p>[!WARNING]"} {
+		if !strings.Contains(html, want) {
+			t.Errorf("Expected %q unchanged without options, got %s", want, html)
+		}
+	}
+	if strings.Contains(html, "footnote") || strings.Contains(html, "<math") {
+		t.Errorf("Expected no footnotes or math without options, got %s", html)
+	}
+	if plain, _ := markdown.RenderString(extensionsSource); plain != html {
+		t.Error("Expected the package renderer to use the default options")
+	}
+}
+
+func TestMarkdownOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		opts models.MarkdownOptions
+		want []string
+	}{
+		{"footnotes", models.MarkdownOptions{Footnotes: true}, []string{`<a href="#fn:1" class="footnote-ref"`, `<li id="fn:1">`}},
+		{"definition lists", models.MarkdownOptions{DefinitionLists: true}, []string{"<dl>\n<dt>Trail</dt>\n<dd>A path through the woods</dd>\n</dl>"}},
+		{"typographer", models.MarkdownOptions{Typographer: true}, []string{"&ndash; &ldquo;cheap&rdquo;"}},
+		{"admonitions", models.MarkdownOptions{Admonitions: true}, []string{
+			`<div class="markdown-alert markdown-alert-warning">` + "\n" + `<p class="markdown-alert-title">Warning</p>` + "\n<p>Watch for <em>bears</em>.</p>\n</div>",
+		}},
+		{"math", models.MarkdownOptions{Math: true}, []string{
+			"<p>Costs $5 or $10",
+			`<msup><mi>e</mi><mrow><mi>i</mi><mi>π</mi></mrow></msup>`,
+			`<math xmlns="http://www.w3.org/1998/Math/MathML" display="block"><semantics><mfrac><mi>a</mi><mi>b</mi></mfrac>`,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			html, err := markdown.For(tt.opts).RenderString(extensionsSource)
+			if err != nil {
+				t.Fatalf("Failed to render: %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(html, want) {
+					t.Errorf("Expected %q, got %s", want, html)
+				}
+			}
+		})
+	}
+
+	if markdown.For(models.MarkdownOptions{Math: true}) != markdown.For(models.MarkdownOptions{Math: true}) {
+		t.Error("Expected renderers to be shared per options")
+	}
+}
+
+func TestTeXToMathML(t *testing.T) {
+	tests := []struct {
+		tex     string
+		display bool
+		want    string
+	}{
+		{`x_i^2`, false, `<msubsup><mi>x</mi><mi>i</mi><mn>2</mn></msubsup>`},
+		{`\sqrt[3]{8} = 2`, false, `<mroot><mn>8</mn><mn>3</mn></mroot><mo>=</mo><mn>2</mn>`},
+		{`\sum_{k=0}^n k`, true, `<munderover><mo largeop="true" movablelimits="true">∑</mo>`},
+		{`\sum_{k=0}^n k`, false, `<msubsup><mo largeop="true" movablelimits="true">∑</mo>`},
+		{`\mathbb{R}`, false, `<mi mathvariant="double-struck">R</mi>`},
+		{`\left( \frac{1}{2} \right]`, false, `<mo fence="true" stretchy="true">(</mo><mfrac><mn>1</mn><mn>2</mn></mfrac><mo fence="true" stretchy="true">]</mo>`},
+		{`\text{if } x < 1`, false, `<mtext>if </mtext><mi>x</mi><mo>&lt;</mo><mn>1</mn>`},
+		{`\begin{cases} 1 & x > 0 \\ 0 & \text{otherwise} \end{cases}`, true, `<mtable columnalign="left"><mtr><mtd><mn>1</mn></mtd>`},
+		{`a \not= b`, false, `<mo>≠</mo>`},
+	}
+	for _, tt := range tests {
+		got, err := markdown.TeXToMathML(tt.tex, tt.display)
+		if err != nil {
+			t.Errorf("Failed to convert %q: %v", tt.tex, err)
+			continue
+		}
+		if !strings.Contains(got, tt.want) {
+			t.Errorf("Expected %q to contain %s, got %s", tt.tex, tt.want, got)
+		}
+		if !strings.Contains(got, `<annotation encoding="application/x-tex">`) {
+			t.Errorf("Expected the TeX source as an annotation, got %s", got)
+		}
+	}
+
+	for _, tex := range []string{`\frac{1}`, `{x`, `x}`, `\left( x`, `\begin{pmatrix} 1 \end{bmatrix}`, `\unknown`, `\input{/etc/passwd}`, strings.Repeat(`\sqrt`, 100) + "x"} {
+		if _, err := markdown.TeXToMathML(tex, false); err == nil {
+			t.Errorf("Expected %q to fail", tex)
+		}
+	}
+}
+
+func TestMathErrorsShowSource(t *testing.T) {
+	html, err := markdown.For(models.MarkdownOptions{Math: true}).RenderString(`Try $\frac{<b>}$ here`)
+	if err != nil {
+		t.Fatalf("Failed to render: %v", err)
+	}
+	if !strings.Contains(html, `<code class="math-error" title="missing argument">\frac{&lt;b&gt;}</code>`) {
+		t.Errorf("Expected the escaped source in a math-error, got %s", html)
+	}
+}
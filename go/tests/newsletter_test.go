@@ -0,0 +1,126 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cassiascheffer/willow_camp/internal/comments"
+	"github.com/cassiascheffer/willow_camp/internal/logging"
+	"github.com/cassiascheffer/willow_camp/internal/mailer"
+	"github.com/cassiascheffer/willow_camp/internal/newsletter"
+)
+
+// confirmationsQueued counts the confirmation emails queued in store
+func confirmationsQueued(store *memoryJobs) int {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	count := 0
+	for _, job := range store.jobs {
+		if job.Kind == newsletter.JobSendConfirmation {
+			count++
+		}
+	}
+	return count
+}
+
+// TestSubscribeThrottling verifies an address gets one confirmation email per
+// cooldown, and a reader's subscribe requests are rate limited per blog
+func TestSubscribeThrottling(t *testing.T) {
+	pool, repos := setupTestDB(t)
+	ctx := context.Background()
+	blog := createTestBlog(t, pool, repos)
+	other := createTestBlog(t, pool, repos)
+	logger := logging.NewLogger()
+	store := newMemoryJobs()
+	service := newsletter.New(repos, mailer.NewLogMailer(logger), newJobQueue(store, time.Second), nil, "localhost:3001", logger)
+	reader := comments.HashIP(blog.ID, "203.0.113.7")
+
+	if err := service.Subscribe(ctx, blog, "reader@example.com", reader); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	if err := service.Subscribe(ctx, blog, "Reader@example.com", reader); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	if got := confirmationsQueued(store); got != 1 {
+		t.Errorf("expected one confirmation within the cooldown, got %d", got)
+	}
+
+	// Once the cooldown passes the address can get another
+	if _, err := pool.Exec(ctx, `UPDATE email_subscribers SET confirmation_sent_at = $2 WHERE blog_id = $1`,
+		blog.ID, time.Now().Add(-newsletter.ConfirmationCooldown-time.Minute)); err != nil {
+		t.Fatalf("Failed to age confirmation: %v", err)
+	}
+	if err := service.Subscribe(ctx, blog, "reader@example.com", reader); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	if got := confirmationsQueued(store); got != 2 {
+		t.Errorf("expected a second confirmation after the cooldown, got %d", got)
+	}
+
+	// The reader has used three of their requests
+	for i := 3; i < newsletter.SubscribeLimit; i++ {
+		if err := service.Subscribe(ctx, blog, "someone@example.com", reader); err != nil {
+			t.Fatalf("request %d: Subscribe failed: %v", i+1, err)
+		}
+	}
+	if err := service.Subscribe(ctx, blog, "victim@example.com", reader); !errors.Is(err, newsletter.ErrRateLimited) {
+		t.Errorf("expected ErrRateLimited after %d requests, got %v", newsletter.SubscribeLimit, err)
+	}
+
+	// Other readers and other blogs aren't affected
+	if err := service.Subscribe(ctx, blog, "victim@example.com", comments.HashIP(blog.ID, "198.51.100.1")); err != nil {
+		t.Errorf("expected another reader to subscribe, got %v", err)
+	}
+	if err := service.Subscribe(ctx, other, "victim@example.com", comments.HashIP(other.ID, "203.0.113.7")); err != nil {
+		t.Errorf("expected the reader to subscribe to another blog, got %v", err)
+	}
+}
+
+// TestConfirmSubscriptionExpires verifies confirmation links stop working
+// ConfirmationTTL after the email was sent
+func TestConfirmSubscriptionExpires(t *testing.T) {
+	pool, repos := setupTestDB(t)
+	ctx := context.Background()
+	blog := createTestBlog(t, pool, repos)
+	logger := logging.NewLogger()
+	service := newsletter.New(repos, mailer.NewLogMailer(logger), newJobQueue(newMemoryJobs(), time.Second), nil, "localhost:3001", logger)
+
+	subscribe := func(email string) string {
+		t.Helper()
+		if err := service.Subscribe(ctx, blog, email, comments.HashIP(blog.ID, email)); err != nil {
+			t.Fatalf("Subscribe failed: %v", err)
+		}
+		subscriber, err := repos.Subscriber.FindByEmail(ctx, blog.ID, email)
+		if err != nil {
+			t.Fatalf("FindByEmail failed: %v", err)
+		}
+		return subscriber.ConfirmationToken
+	}
+
+	fresh := subscribe("fresh@example.com")
+	if _, err := service.Confirm(ctx, blog, fresh); err != nil {
+		t.Errorf("expected a fresh link to confirm, got %v", err)
+	}
+	if _, err := service.Confirm(ctx, blog, fresh); err != nil {
+		t.Errorf("expected confirming twice to succeed, got %v", err)
+	}
+
+	stale := subscribe("stale@example.com")
+	if _, err := pool.Exec(ctx, `UPDATE email_subscribers SET confirmation_sent_at = $2 WHERE blog_id = $1 AND email = 'stale@example.com'`,
+		blog.ID, time.Now().Add(-newsletter.ConfirmationTTL-time.Hour)); err != nil {
+		t.Fatalf("Failed to age confirmation: %v", err)
+	}
+	if _, err := service.Confirm(ctx, blog, stale); !errors.Is(err, newsletter.ErrConfirmationExpired) {
+		t.Errorf("expected ErrConfirmationExpired for an old link, got %v", err)
+	}
+
+	unsent := subscribe("unsent@example.com")
+	if _, err := pool.Exec(ctx, `UPDATE email_subscribers SET confirmation_sent_at = NULL WHERE blog_id = $1 AND email = 'unsent@example.com'`, blog.ID); err != nil {
+		t.Fatalf("Failed to clear confirmation: %v", err)
+	}
+	if _, err := service.Confirm(ctx, blog, unsent); !errors.Is(err, newsletter.ErrConfirmationExpired) {
+		t.Errorf("expected ErrConfirmationExpired for a link never sent, got %v", err)
+	}
+}
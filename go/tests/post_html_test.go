@@ -0,0 +1,143 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cassiascheffer/willow_camp/internal/helpers"
+	"github.com/cassiascheffer/willow_camp/internal/markdown"
+	"github.com/cassiascheffer/willow_camp/internal/models"
+)
+
+var rawHTML = markdown.For(models.MarkdownOptions{RawHTML: true})
+
+func TestRawHTMLOffByDefault(t *testing.T) {
+	html, err := markdown.RenderString("<details><summary>More</summary>\n\nHidden\n\n</details>\n\nA <kbd>key</kbd>.\n")
+	if err != nil {
+		t.Fatalf("Failed to render: %v", err)
+	}
+	if strings.Contains(html, "<details>") || strings.Contains(html, "<kbd>") {
+		t.Errorf("Expected raw HTML to be omitted by default, got %s", html)
+	}
+}
+
+func TestRawHTMLAllowed(t *testing.T) {
+	cases := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{
+			name:   "details blocks with markdown inside",
+			source: "<details><summary>More</summary>\n\nHidden **text**\n\n</details>\n",
+			want:   []string{"<details><summary>More</summary>", "<strong>text</strong>", "</details>"},
+		},
+		{
+			name:   "inline tags",
+			source: "Press <kbd>Ctrl</kbd> and <mark class=\"note\">read</mark>.\n",
+			want:   []string{"<kbd>Ctrl</kbd>", `<mark class="note">read</mark>`},
+		},
+		{
+			name:   "youtube embeds",
+			source: `<iframe width="560" height="315" src="https://www.youtube-nocookie.com/embed/abc123" title="Trail video" allow="autoplay; encrypted-media; picture-in-picture" allowfullscreen></iframe>` + "\n",
+			want:   []string{`<iframe width="560" height="315" src="https://www.youtube-nocookie.com/embed/abc123" title="Trail video" allow="autoplay; encrypted-media; picture-in-picture" allowfullscreen="">`, "</iframe>"},
+		},
+		{
+			name:   "https video",
+			source: "<video controls src=\"https://cdn.example.com/hike.mp4\"></video>\n",
+			want:   []string{`<video controls="" src="https://cdn.example.com/hike.mp4">`},
+		},
+		{
+			name:   "links stay followable",
+			source: "<a href=\"https://example.com\">Example</a>\n",
+			want:   []string{`<a href="https://example.com">Example</a>`},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			html, err := rawHTML.RenderString(tc.source)
+			if err != nil {
+				t.Fatalf("Failed to render: %v", err)
+			}
+			for _, want := range tc.want {
+				if !strings.Contains(html, want) {
+					t.Errorf("Expected %s in %s", want, html)
+				}
+			}
+		})
+	}
+}
+
+func TestRawHTMLXSS(t *testing.T) {
+	vectors := []struct {
+		source string
+		// banned must not appear in the output, case-insensitively
+		banned []string
+	}{
+		{"<script>alert(1)</script>\n", []string{"<script", "alert(1)"}},
+		{"Inline <script>alert(1)</script> script\n", []string{"<script"}},
+		{"<img src=x onerror=alert(1)>\n", []string{"onerror"}},
+		{"<img src=\"javascript:alert(1)\">\n", []string{"javascript:"}},
+		{"<a href=\"javascript:alert(1)\">click</a>\n", []string{"javascript:"}},
+		{"<a href=\"JaVaScRiPt:alert(1)\">click</a>\n", []string{"javascript:"}},
+		{"<a href=\"&#106;avascript:alert(1)\">click</a>\n", []string{"avascript:"}},
+		{"<a href=\"data:text/html;base64,PHNjcmlwdD5hbGVydCgxKTwvc2NyaXB0Pg==\">click</a>\n", []string{"data:"}},
+		{"<svg onload=alert(1)><circle r=\"1\"/></svg>\n", []string{"<svg", "onload"}},
+		{"<div style=\"background:url(javascript:alert(1))\">x</div>\n", []string{"style=", "javascript:"}},
+		{"<style>body{display:none}</style>\n", []string{"<style", "display:none"}},
+		{"<iframe src=\"https://evil.example/\"></iframe>\n", []string{"evil.example"}},
+		{"<iframe src=\"javascript:alert(1)\"></iframe>\n", []string{"javascript:"}},
+		{"<iframe srcdoc=\"<script>alert(1)</script>\" src=\"https://www.youtube.com/embed/x\"></iframe>\n", []string{"srcdoc", "<script"}},
+		{"<iframe src=\"https://www.youtube.com.evil.example/embed/x\"></iframe>\n", []string{"evil.example"}},
+		{"<object data=\"https://evil.example/x.swf\"></object>\n", []string{"<object", "evil.example"}},
+		{"<embed src=\"https://evil.example/x.swf\">\n", []string{"<embed", "evil.example"}},
+		{"<form action=\"https://evil.example\"><input name=\"password\"></form>\n", []string{"<form", "<input"}},
+		{"<meta http-equiv=\"refresh\" content=\"0;url=https://evil.example\">\n", []string{"<meta", "evil.example"}},
+		{"<base href=\"https://evil.example/\">\n", []string{"<base", "evil.example"}},
+		{"<math><mtext><table><mglyph><style><img src=x onerror=alert(1)>\n", []string{"onerror"}},
+		{"<details open ontoggle=alert(1)>\n", []string{"ontoggle"}},
+		{"<video poster=\"javascript:alert(1)\" src=\"http://insecure.example/v.mp4\"></video>\n", []string{"javascript:", "insecure.example"}},
+		{"<p class=\"x\" onclick=\"alert(1)\">hi</p>\n", []string{"onclick"}},
+		{"<!-- <script>alert(1)</script> -->\n", []string{"<script"}},
+		{"<div\nonmouseover=\"alert(1)\">x</div>\n", []string{"onmouseover"}},
+	}
+
+	for _, v := range vectors {
+		html, err := rawHTML.RenderString(v.source)
+		if err != nil {
+			t.Fatalf("Failed to render %q: %v", v.source, err)
+		}
+		lower := strings.ToLower(html)
+		for _, banned := range v.banned {
+			if strings.Contains(lower, banned) {
+				t.Errorf("Expected %q to be removed from %q, got %s", banned, v.source, html)
+			}
+		}
+	}
+}
+
+func TestRawHTMLLeavesGeneratedMarkup(t *testing.T) {
+	r := markdown.For(models.MarkdownOptions{RawHTML: true, Math: true})
+	html, err := r.RenderString("Euler: $e^{i\\pi}$\n\n```go\nx := 1\n```\n\n<b>bold</b>\n")
+	if err != nil {
+		t.Fatalf("Failed to render: %v", err)
+	}
+	for _, want := range []string{"<math", `class="chroma"`, "<b>bold</b>"} {
+		if !strings.Contains(html, want) {
+			t.Errorf("Expected %s in %s", want, html)
+		}
+	}
+}
+
+func TestEmbedSources(t *testing.T) {
+	for _, prefix := range helpers.EmbedSources {
+		if !strings.HasPrefix(prefix, "https://") {
+			t.Errorf("Expected embed source %s to use https", prefix)
+		}
+		iframe := `<iframe src="` + prefix + `x"></iframe>`
+		if got := helpers.SanitizePostHTML(iframe); !strings.Contains(got, prefix) {
+			t.Errorf("Expected an iframe from %s to be kept, got %s", prefix, got)
+		}
+	}
+}
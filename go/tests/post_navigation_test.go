@@ -0,0 +1,161 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cassiascheffer/willow_camp/internal/models"
+	"github.com/cassiascheffer/willow_camp/internal/views"
+)
+
+func TestPostNavigationRenders(t *testing.T) {
+	registry, err := views.Load(views.Config{})
+	if err != nil {
+		t.Fatalf("Failed to parse templates: %v", err)
+	}
+
+	// The fixture has an older post, no newer one and one related post
+	var buf bytes.Buffer
+	if err := registry.Execute(&buf, "blog/post_show.html", viewFixtures()["blog/post_show.html"]); err != nil {
+		t.Fatalf("Failed to render: %v", err)
+	}
+	html := buf.String()
+
+	if !strings.Contains(html, `href="/secret-hike" class="link link-hover" rel="prev"`) {
+		t.Error("expected a link to the older post")
+	}
+	if strings.Contains(html, "Newer post") {
+		t.Error("expected no link to a newer post for the latest one")
+	}
+	if !strings.Contains(html, "Related posts") || !strings.Contains(html, `href="/first-hike"`) {
+		t.Error("expected the related posts to be listed")
+	}
+}
+
+// TestFindAdjacentPublished verifies older and newer posts follow publish
+// order, posts published at the same moment are ordered by ID, and drafts,
+// unlisted posts and other blogs' posts are skipped
+func TestFindAdjacentPublished(t *testing.T) {
+	pool, repos := setupTestDB(t)
+	ctx := context.Background()
+	blog := createTestBlog(t, pool, repos)
+	other := createTestBlog(t, pool, repos)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	first := createTestPost(t, repos, blog, "first", now.Add(-3*time.Hour))
+	second := createTestPost(t, repos, blog, "second", now.Add(-2*time.Hour))
+	tieA := createTestPost(t, repos, blog, "tie-a", now.Add(-time.Hour))
+	tieB := createTestPost(t, repos, blog, "tie-b", now.Add(-time.Hour))
+	last := createTestPost(t, repos, blog, "last", now)
+	if tieB.ID.String() < tieA.ID.String() {
+		tieA, tieB = tieB, tieA
+	}
+
+	draft := createTestPost(t, repos, blog, "draft", time.Time{})
+	unlisted := createTestPost(t, repos, blog, "unlisted", now.Add(-90*time.Minute))
+	setTestPostVisibility(t, pool, unlisted, models.VisibilityUnlisted)
+	createTestPost(t, repos, other, "elsewhere", now.Add(-150*time.Minute))
+
+	order := []*models.Post{first, second, tieA, tieB, last}
+	for i, post := range order {
+		previous, next, err := repos.Post.FindAdjacentPublished(ctx, post)
+		if err != nil {
+			t.Fatalf("FindAdjacentPublished failed: %v", err)
+		}
+		var wantPrevious, wantNext *models.Post
+		if i > 0 {
+			wantPrevious = order[i-1]
+		}
+		if i < len(order)-1 {
+			wantNext = order[i+1]
+		}
+		if !samePost(previous, wantPrevious) || !samePost(next, wantNext) {
+			t.Errorf("%s: expected %s and %s, got %s and %s", *post.Slug,
+				postSlug(wantPrevious), postSlug(wantNext), postSlug(previous), postSlug(next))
+		}
+	}
+
+	previous, next, err := repos.Post.FindAdjacentPublished(ctx, draft)
+	if err != nil || previous != nil || next != nil {
+		t.Errorf("expected no adjacent posts for a draft, got %s and %s, %v", postSlug(previous), postSlug(next), err)
+	}
+}
+
+// TestListRelated verifies related posts are ranked by how many tags they
+// share, and the post itself, drafts, unlisted posts and other blogs' posts
+// are left out
+func TestListRelated(t *testing.T) {
+	pool, repos := setupTestDB(t)
+	ctx := context.Background()
+	blog := createTestBlog(t, pool, repos)
+	other := createTestBlog(t, pool, repos)
+	hiking, camping, maps := createTestTag(t, pool, repos), createTestTag(t, pool, repos), createTestTag(t, pool, repos)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	post := createTestPost(t, repos, blog, "post", now)
+	three := createTestPost(t, repos, blog, "three", now.Add(-3*time.Hour))
+	two := createTestPost(t, repos, blog, "two", now.Add(-2*time.Hour))
+	one := createTestPost(t, repos, blog, "one", now.Add(-time.Hour))
+	untagged := createTestPost(t, repos, blog, "untagged", now)
+	draft := createTestPost(t, repos, blog, "draft", time.Time{})
+	unlisted := createTestPost(t, repos, blog, "unlisted", now)
+	setTestPostVisibility(t, pool, unlisted, models.VisibilityUnlisted)
+	elsewhere := createTestPost(t, repos, other, "elsewhere", now)
+
+	tagTestPost(t, repos, post, hiking, camping, maps)
+	tagTestPost(t, repos, three, hiking, camping, maps)
+	tagTestPost(t, repos, two, hiking, camping)
+	tagTestPost(t, repos, one, maps)
+	tagTestPost(t, repos, draft, hiking, camping, maps)
+	tagTestPost(t, repos, unlisted, hiking, camping, maps)
+	tagTestPost(t, repos, elsewhere, hiking, camping, maps)
+
+	related, err := repos.Post.ListRelated(ctx, post, 10)
+	if err != nil {
+		t.Fatalf("ListRelated failed: %v", err)
+	}
+	if len(related) != 3 || related[0].ID != three.ID || related[1].ID != two.ID || related[2].ID != one.ID {
+		t.Errorf("expected three, two and one, got %v", postSlugs(related))
+	}
+
+	limited, err := repos.Post.ListRelated(ctx, post, 2)
+	if err != nil {
+		t.Fatalf("ListRelated failed: %v", err)
+	}
+	if len(limited) != 2 || limited[0].ID != three.ID || limited[1].ID != two.ID {
+		t.Errorf("expected the limit to keep three and two, got %v", postSlugs(limited))
+	}
+
+	none, err := repos.Post.ListRelated(ctx, untagged, 10)
+	if err != nil || len(none) != 0 {
+		t.Errorf("expected no related posts for an untagged post, got %v, %v", postSlugs(none), err)
+	}
+}
+
+// samePost reports whether two posts, either of which may be nil, are the same
+func samePost(a, b *models.Post) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.ID == b.ID
+}
+
+// postSlug returns a post's slug for test messages
+func postSlug(post *models.Post) string {
+	if post == nil || post.Slug == nil {
+		return "none"
+	}
+	return *post.Slug
+}
+
+// postSlugs returns the posts' slugs for test messages
+func postSlugs(posts []*models.Post) []string {
+	slugs := make([]string, len(posts))
+	for i, post := range posts {
+		slugs[i] = postSlug(post)
+	}
+	return slugs
+}
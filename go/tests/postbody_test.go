@@ -0,0 +1,63 @@
+package tests
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/cassiascheffer/willow_camp/internal/logging"
+	"github.com/cassiascheffer/willow_camp/internal/markdown"
+	"github.com/cassiascheffer/willow_camp/internal/models"
+	"github.com/cassiascheffer/willow_camp/internal/postbody"
+	"github.com/cassiascheffer/willow_camp/internal/previews"
+	"github.com/google/uuid"
+)
+
+func TestPostBodyRender(t *testing.T) {
+	ctx := context.Background()
+	logger := logging.NewLogger()
+	blog := &models.Blog{ID: uuid.New()}
+	source := "Before the trip.\n\nhttps://trail.example/camino\n"
+
+	store := &memoryPreviews{}
+	_ = store.Upsert(ctx, &models.LinkPreview{URL: "https://trail.example/camino", Title: "Walking the Camino"})
+	services := postbody.Services{Previews: previews.New(store, nil)}
+
+	doc, err := services.Render(ctx, blog, logger, source)
+	if err != nil {
+		t.Fatalf("Failed to render: %v", err)
+	}
+	if !strings.Contains(string(doc.HTML), `<span class="link-preview-title">Walking the Camino</span>`) {
+		t.Errorf("Expected the link preview in %s", doc.HTML)
+	}
+
+	// Without services the markdown renders as it is
+	plain, err := postbody.Services{}.Render(ctx, blog, logger, source)
+	if err != nil {
+		t.Fatalf("Failed to render: %v", err)
+	}
+	want, _ := markdown.For(blog.MarkdownOptions).RenderDocument(source, markdown.Resolvers{})
+	if plain.HTML != want.HTML {
+		t.Errorf("Expected plain markdown, got %s", plain.HTML)
+	}
+}
+
+func TestPostBodyResolvers(t *testing.T) {
+	ctx := context.Background()
+	store := &memoryPreviews{}
+	for _, link := range []string{"https://trail.example/one", "https://trail.example/two"} {
+		_ = store.Upsert(ctx, &models.LinkPreview{URL: link, Title: link})
+	}
+	services := postbody.Services{Previews: previews.New(store, nil)}
+
+	// One lookup covers the links in every source, as for a feed
+	resolvers := services.Resolvers(ctx, logging.NewLogger(), "https://trail.example/one\n", "https://trail.example/two\n")
+	if resolvers.Previews == nil || resolvers.Diagrams != nil || resolvers.Embeds != nil {
+		t.Fatalf("Expected only the previews resolver, got %+v", resolvers)
+	}
+	for _, link := range []string{"https://trail.example/one", "https://trail.example/two"} {
+		if preview, ok := resolvers.Previews(link); !ok || preview.Title != link {
+			t.Errorf("Expected a preview for %s, got %+v", link, preview)
+		}
+	}
+}
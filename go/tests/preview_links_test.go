@@ -0,0 +1,58 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cassiascheffer/willow_camp/internal/access"
+	"github.com/cassiascheffer/willow_camp/internal/models"
+	"github.com/google/uuid"
+)
+
+func TestPreviewToken(t *testing.T) {
+	service := access.New("test-secret", false)
+	link := &models.PreviewLink{ID: uuid.New(), PostID: uuid.New(), ExpiresAt: time.Now().Add(7 * 24 * time.Hour)}
+
+	token, err := service.PreviewToken(link)
+	if err != nil {
+		t.Fatalf("PreviewToken failed: %v", err)
+	}
+	id, err := service.VerifyPreviewToken(token)
+	if err != nil || id != link.ID {
+		t.Errorf("expected link %s, got %s, %v", link.ID, id, err)
+	}
+
+	// Preview tokens can't sign anyone in as a member, and member tokens
+	// can't open previews
+	if _, _, err := service.VerifyMemberToken(token); !errors.Is(err, access.ErrInvalidLink) {
+		t.Errorf("expected a preview token not to work as a member token, got %v", err)
+	}
+	memberToken, _ := service.MemberToken(&models.EmailSubscriber{ID: uuid.New(), BlogID: uuid.New()})
+	if _, err := service.VerifyPreviewToken(memberToken); !errors.Is(err, access.ErrInvalidLink) {
+		t.Errorf("expected a member token not to open a preview, got %v", err)
+	}
+}
+
+func TestPreviewTokenExpires(t *testing.T) {
+	service := access.New("test-secret", false)
+	link := &models.PreviewLink{ID: uuid.New(), ExpiresAt: time.Now().Add(-time.Second)}
+	if !link.IsExpired() {
+		t.Error("expected the link to have expired")
+	}
+
+	token, err := service.PreviewToken(link)
+	if err != nil {
+		t.Fatalf("PreviewToken failed: %v", err)
+	}
+	// The expiry is signed into the token, so it holds even if the stored
+	// link were changed
+	if _, err := service.VerifyPreviewToken(token); !errors.Is(err, access.ErrInvalidLink) {
+		t.Errorf("expected an expired preview token to be rejected, got %v", err)
+	}
+
+	link.ExpiresAt = time.Now().Add(time.Hour)
+	if link.IsExpired() {
+		t.Error("expected the link to work until it expires")
+	}
+}
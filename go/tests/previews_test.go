@@ -0,0 +1,261 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cassiascheffer/willow_camp/internal/markdown"
+	"github.com/cassiascheffer/willow_camp/internal/models"
+	"github.com/cassiascheffer/willow_camp/internal/previews"
+)
+
+// memoryPreviews is an in-memory previews.Store
+type memoryPreviews struct {
+	mu       sync.Mutex
+	previews map[string]*models.LinkPreview
+}
+
+func (m *memoryPreviews) FindByURLs(ctx context.Context, urls []string) (map[string]*models.LinkPreview, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	found := map[string]*models.LinkPreview{}
+	for _, link := range urls {
+		if p, ok := m.previews[link]; ok {
+			copied := *p
+			found[link] = &copied
+		}
+	}
+	return found, nil
+}
+
+func (m *memoryPreviews) Upsert(ctx context.Context, preview *models.LinkPreview) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.previews == nil {
+		m.previews = map[string]*models.LinkPreview{}
+	}
+	copied := *preview
+	m.previews[preview.URL] = &copied
+	return nil
+}
+
+// newPreviewSite serves pages with and without Open Graph metadata, counting
+// the requests for each path
+func newPreviewSite(t *testing.T) (*httptest.Server, func(path string) int) {
+	var mu sync.Mutex
+	hits := map[string]int{}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/article", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write([]byte(`<!doctype html><html><head>
+			<title>Fallback title</title>
+			<meta property="og:title" content="Walking the  Camino &amp; back">
+			<meta property="og:description" content="Five hundred miles in thirty days.">
+			<meta property="og:image" content="/images/camino.jpg">
+			<meta property="og:site_name" content="Trail Notes">
+			</head><body><meta property="og:title" content="Ignored"></body></html>`))
+	})
+	mux.HandleFunc("/plain", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<html><head><title>Plain page</title><meta name="description" content="Just a description."></head></html>`))
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/article", http.StatusFound)
+	})
+	mux.HandleFunc("/file.pdf", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		_, _ = w.Write([]byte("%PDF-1.7"))
+	})
+	mux.HandleFunc("/huge", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte("<html><head><!--" + strings.Repeat("x", 1<<20) + `--><meta property="og:title" content="Too far down"></head></html>`))
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(2 * time.Second):
+		case <-r.Context().Done():
+		}
+	})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits[r.URL.Path]++
+		mu.Unlock()
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, func(path string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return hits[path]
+	}
+}
+
+// newPreviewService fetches with a plain client, as the test site is on a
+// loopback address the default client refuses
+func newPreviewService(t *testing.T) (*previews.Service, *httptest.Server, func(string) int, *memoryPreviews) {
+	server, hits := newPreviewSite(t)
+	store := &memoryPreviews{}
+	service := previews.New(store, nil)
+	service.SetClient(&http.Client{Timeout: 200 * time.Millisecond})
+	return service, server, hits, store
+}
+
+func TestLinkPreviewFetch(t *testing.T) {
+	ctx := context.Background()
+	service, server, _, _ := newPreviewService(t)
+
+	preview, err := service.Fetch(ctx, server.URL+"/moved")
+	if err != nil {
+		t.Fatalf("Failed to fetch preview: %v", err)
+	}
+	if preview.URL != server.URL+"/moved" {
+		t.Errorf("Expected the linked URL to be kept, got %s", preview.URL)
+	}
+	if preview.Title != "Walking the Camino & back" {
+		t.Errorf("Expected the og:title, got %q", preview.Title)
+	}
+	if preview.Description != "Five hundred miles in thirty days." || preview.SiteName != "Trail Notes" {
+		t.Errorf("Unexpected description or site: %q, %q", preview.Description, preview.SiteName)
+	}
+	if preview.ImageURL != server.URL+"/images/camino.jpg" {
+		t.Errorf("Expected the image to be made absolute, got %q", preview.ImageURL)
+	}
+
+	plain, err := service.Fetch(ctx, server.URL+"/plain")
+	if err != nil {
+		t.Fatalf("Failed to fetch preview: %v", err)
+	}
+	if plain.Title != "Plain page" || plain.Description != "Just a description." || plain.ImageURL != "" {
+		t.Errorf("Expected the title and meta description, got %+v", plain)
+	}
+
+	// Metadata past the size limit isn't read
+	huge, err := service.Fetch(ctx, server.URL+"/huge")
+	if err != nil {
+		t.Fatalf("Failed to fetch preview: %v", err)
+	}
+	if huge.Title != "" {
+		t.Errorf("Expected no title past the size limit, got %q", huge.Title)
+	}
+
+	for _, path := range []string{"/file.pdf", "/missing"} {
+		if _, err := service.Fetch(ctx, server.URL+path); !errors.Is(err, previews.ErrNoPreview) {
+			t.Errorf("Expected ErrNoPreview for %s, got %v", path, err)
+		}
+	}
+
+	start := time.Now()
+	if _, err := service.Fetch(ctx, server.URL+"/slow"); err == nil {
+		t.Error("Expected a slow page to time out")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the request to be cut off by the timeout, took %s", elapsed)
+	}
+}
+
+func TestLinkPreviewRendering(t *testing.T) {
+	ctx := context.Background()
+	service, server, hits, store := newPreviewService(t)
+	service.SetSkip(func(link string) bool { return strings.HasPrefix(link, "https://video.example/") })
+
+	source := server.URL + "/article\n\n" +
+		"See " + server.URL + "/plain inline.\n\n" +
+		server.URL + "/file.pdf\n\n" +
+		"https://video.example/watch/1\n"
+
+	if err := service.Prepare(ctx, source); err != nil {
+		t.Fatalf("Failed to prepare previews: %v", err)
+	}
+	if err := service.Prepare(ctx, source); err != nil {
+		t.Fatalf("Failed to prepare previews: %v", err)
+	}
+	if hits("/article") != 1 || hits("/file.pdf") != 1 {
+		t.Errorf("Expected each page to be fetched once, got %d and %d", hits("/article"), hits("/file.pdf"))
+	}
+	if hits("/plain") != 0 {
+		t.Error("Expected links within text not to be fetched")
+	}
+	if _, ok := store.previews["https://video.example/watch/1"]; ok {
+		t.Error("Expected skipped links not to be fetched")
+	}
+
+	resolve, err := service.Resolver(ctx, source)
+	if err != nil {
+		t.Fatalf("Failed to load previews: %v", err)
+	}
+	doc, err := markdown.RenderDocument(source, markdown.Resolvers{Previews: resolve})
+	if err != nil {
+		t.Fatalf("Failed to render: %v", err)
+	}
+	html := string(doc.HTML)
+
+	for _, want := range []string{
+		`<p class="link-preview"><a href="` + server.URL + `/article"><img class="link-preview-image" src="` + server.URL + `/images/camino.jpg" alt="" loading="lazy"> <span class="link-preview-title">Walking the Camino &amp; back</span> <span class="link-preview-description">Five hundred miles in thirty days.</span> <span class="link-preview-site">Trail Notes</span></a></p>`,
+		// Links without a preview are left alone
+		"<p>" + server.URL + "/file.pdf</p>",
+		`<p><a href="https://video.example/watch/1">https://video.example/watch/1</a></p>`,
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("Expected %s in %s", want, html)
+		}
+	}
+}
+
+func TestLinkPreviewsBlockPrivateAddresses(t *testing.T) {
+	ctx := context.Background()
+	server, hits := newPreviewSite(t)
+	store := &memoryPreviews{}
+	service := previews.New(store, nil)
+
+	port := server.URL[strings.LastIndex(server.URL, ":"):]
+	for _, link := range []string{server.URL + "/article", "http://localhost" + port + "/article"} {
+		if _, err := service.Fetch(ctx, link); !errors.Is(err, previews.ErrBlockedAddress) {
+			t.Errorf("Expected %s to be blocked, got %v", link, err)
+		}
+	}
+	if hits("/article") != 0 {
+		t.Error("Expected no request to reach a private address")
+	}
+
+	// Blocked links are cached empty rather than retried
+	if err := service.Prepare(ctx, server.URL+"/article\n"); err != nil {
+		t.Errorf("Expected blocked links not to fail the job, got %v", err)
+	}
+	if p, ok := store.previews[server.URL+"/article"]; !ok || p.Title != "" {
+		t.Errorf("Expected an empty preview to be cached, got %+v", p)
+	}
+
+	for addr, blocked := range map[string]bool{
+		"127.0.0.1":       true,
+		"10.1.2.3":        true,
+		"172.16.5.4":      true,
+		"192.168.1.1":     true,
+		"169.254.169.254": true,
+		"100.64.0.1":      true,
+		"0.0.0.0":         true,
+		"224.0.0.1":       true,
+		"::1":             true,
+		"fc00::1":         true,
+		"fe80::1":         true,
+		"::ffff:10.0.0.1": true,
+		"64:ff9b::a00:1":  true,
+		"93.184.216.34":   false,
+		"1.1.1.1":         false,
+		"2606:4700::1111": false,
+		"::ffff:8.8.8.8":  false,
+	} {
+		if got := previews.Blocked(netip.MustParseAddr(addr)); got != blocked {
+			t.Errorf("Blocked(%s) = %v, want %v", addr, got, blocked)
+		}
+	}
+}
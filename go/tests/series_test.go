@@ -0,0 +1,229 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cassiascheffer/willow_camp/internal/archive"
+	"github.com/cassiascheffer/willow_camp/internal/auth"
+	bloghandlers "github.com/cassiascheffer/willow_camp/internal/blog/handlers"
+	blogmiddleware "github.com/cassiascheffer/willow_camp/internal/blog/middleware"
+	"github.com/cassiascheffer/willow_camp/internal/models"
+	"github.com/cassiascheffer/willow_camp/internal/repository"
+	"github.com/cassiascheffer/willow_camp/internal/views"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+func TestSeriesPartFor(t *testing.T) {
+	series := &models.Series{ID: uuid.New(), Title: "Building a Cabin", Slug: "building-a-cabin"}
+	parts := []*models.Post{
+		{ID: uuid.New(), Title: strPtr("Foundations")},
+		{ID: uuid.New(), Title: strPtr("Walls")},
+		{ID: uuid.New(), Title: strPtr("Roof")},
+	}
+
+	first := models.SeriesPartFor(series, parts, parts[0].ID)
+	if first == nil || first.Part != 1 || first.Total != 3 || first.Series != series {
+		t.Fatalf("expected part 1 of 3, got %+v", first)
+	}
+	if first.Previous != nil || first.Next != parts[1] {
+		t.Errorf("expected the first part to link only to the second, got %+v", first)
+	}
+
+	middle := models.SeriesPartFor(series, parts, parts[1].ID)
+	if middle == nil || middle.Part != 2 || middle.Previous != parts[0] || middle.Next != parts[2] {
+		t.Errorf("expected part 2 between the others, got %+v", middle)
+	}
+
+	last := models.SeriesPartFor(series, parts, parts[2].ID)
+	if last == nil || last.Part != 3 || last.Previous != parts[1] || last.Next != nil {
+		t.Errorf("expected the last part to link only back, got %+v", last)
+	}
+
+	if part := models.SeriesPartFor(series, parts, uuid.New()); part != nil {
+		t.Errorf("expected no part for a post outside the series, got %+v", part)
+	}
+}
+
+func TestArchiveSeries(t *testing.T) {
+	content, err := archive.MarshalDocument(&archive.Document{
+		Title: "Walls", Slug: "walls", Published: true, Series: "Building a Cabin", SeriesPart: 2,
+	})
+	if err != nil {
+		t.Fatalf("MarshalDocument failed: %v", err)
+	}
+	if !strings.Contains(string(content), "series: Building a Cabin\nseries_part: 2\n") {
+		t.Errorf("expected the series in the front matter, got:\n%s", content)
+	}
+	standalone, _ := archive.MarshalDocument(&archive.Document{Title: "Alone", Slug: "alone", Published: true})
+	if strings.Contains(string(standalone), "series") {
+		t.Errorf("expected no series for a post outside one, got:\n%s", standalone)
+	}
+
+	bundle := parseZip(t, map[string]string{
+		"blog.json":      `{"version":1,"title":"My Blog","subdomain":"me"}`,
+		"posts/walls.md": string(content),
+		"posts/alone.md": string(standalone),
+	})
+	walls := findDocument(t, bundle, "posts/walls.md")
+	if walls.Series != "Building a Cabin" || walls.SeriesPart != 2 {
+		t.Errorf("expected the series to round trip, got %q part %d", walls.Series, walls.SeriesPart)
+	}
+	if alone := findDocument(t, bundle, "posts/alone.md"); alone.Series != "" || alone.SeriesPart != 0 {
+		t.Errorf("expected no series, got %q part %d", alone.Series, alone.SeriesPart)
+	}
+}
+
+// seriesFixture is a series whose parts were published out of order, with a
+// draft and an unlisted part after them, and a post outside the series
+type seriesFixture struct {
+	blog                                           *models.Blog
+	series                                         *models.Series
+	foundations, walls, roof, chimney, porch, shed *models.Post
+}
+
+// createTestSeries creates a blog with a series fixture
+func createTestSeries(t *testing.T) (*repository.Repositories, *seriesFixture) {
+	t.Helper()
+	pool, repos := setupTestDB(t)
+	ctx := context.Background()
+	f := &seriesFixture{blog: createTestBlog(t, pool, repos)}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	f.roof = createTestPost(t, repos, f.blog, "roof", now.Add(-3*time.Hour))
+	f.foundations = createTestPost(t, repos, f.blog, "foundations", now.Add(-2*time.Hour))
+	f.walls = createTestPost(t, repos, f.blog, "walls", now.Add(-time.Hour))
+	f.chimney = createTestPost(t, repos, f.blog, "chimney", time.Time{})
+	f.porch = createTestPost(t, repos, f.blog, "porch", now)
+	setTestPostVisibility(t, pool, f.porch, models.VisibilityUnlisted)
+	f.shed = createTestPost(t, repos, f.blog, "shed", now)
+	if _, err := pool.Exec(ctx, `UPDATE posts SET body_markdown = 'Progress so far' WHERE blog_id = $1`, f.blog.ID); err != nil {
+		t.Fatalf("Failed to set post bodies: %v", err)
+	}
+
+	series, err := repos.Series.FindOrCreateByTitle(ctx, f.blog.ID, "Building a Cabin", "building-a-cabin")
+	if err != nil {
+		t.Fatalf("FindOrCreateByTitle failed: %v", err)
+	}
+	f.series = series
+	for _, part := range []struct {
+		post     *models.Post
+		position int
+	}{{f.walls, 2}, {f.roof, 3}, {f.foundations, 1}, {f.chimney, 4}, {f.porch, 5}} {
+		if err := repos.Series.SetPostSeries(ctx, part.post.ID, series.ID, part.position); err != nil {
+			t.Fatalf("SetPostSeries failed: %v", err)
+		}
+	}
+	return repos, f
+}
+
+func TestListInSeries(t *testing.T) {
+	repos, f := createTestSeries(t)
+	ctx := context.Background()
+
+	parts, err := repos.Post.ListInSeries(ctx, f.series.ID, uuid.Nil)
+	if err != nil {
+		t.Fatalf("ListInSeries failed: %v", err)
+	}
+	if got := postSlugs(parts); strings.Join(got, ",") != "foundations,walls,roof" {
+		t.Errorf("expected the published parts by position, got %v", got)
+	}
+
+	// A draft part being previewed still finds its place
+	parts, err = repos.Post.ListInSeries(ctx, f.series.ID, f.chimney.ID)
+	if err != nil {
+		t.Fatalf("ListInSeries failed: %v", err)
+	}
+	if got := postSlugs(parts); strings.Join(got, ",") != "foundations,walls,roof,chimney" {
+		t.Errorf("expected the draft after the published parts, got %v", got)
+	}
+	part := models.SeriesPartFor(f.series, parts, f.chimney.ID)
+	if part == nil || part.Part != 4 || part.Total != 4 || part.Previous == nil || part.Previous.ID != f.roof.ID {
+		t.Errorf("expected the draft to be part 4 of 4 after the roof, got %+v", part)
+	}
+}
+
+func TestFindSeriesForPost(t *testing.T) {
+	repos, f := createTestSeries(t)
+	ctx := context.Background()
+
+	series, err := repos.Series.FindForPost(ctx, f.walls.ID)
+	if err != nil {
+		t.Fatalf("FindForPost failed: %v", err)
+	}
+	if series.ID != f.series.ID || series.Title != "Building a Cabin" || series.Position != 2 {
+		t.Errorf("expected the walls at position 2 of the series, got %+v", series)
+	}
+
+	if _, err := repos.Series.FindForPost(ctx, f.shed.ID); !errors.Is(err, repository.ErrSeriesNotFound) {
+		t.Errorf("expected ErrSeriesNotFound for a post outside a series, got %v", err)
+	}
+}
+
+func TestSeriesPages(t *testing.T) {
+	repos, f := createTestSeries(t)
+
+	registry, err := views.Load(views.Config{})
+	if err != nil {
+		t.Fatalf("Failed to parse templates: %v", err)
+	}
+	baseDomain := "localhost:3001"
+	e := echo.New()
+	e.Renderer = registry
+	blogH := bloghandlers.New(repos, auth.New(repos.User, "test-secret", nil), baseDomain)
+	group := e.Group("")
+	group.Use(blogmiddleware.BlogResolver(repos.Blog, baseDomain))
+	group.GET("/series/:series_slug", blogH.SeriesShow)
+	group.GET("/series/:series_slug/feed.atom", blogH.SeriesFeed)
+	group.GET("/:slug", blogH.PostShow)
+
+	get := func(path string) (int, string) {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Host = *f.blog.Subdomain + "." + baseDomain
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec.Code, rec.Body.String()
+	}
+
+	code, body := get("/series/building-a-cabin")
+	if code != http.StatusOK {
+		t.Fatalf("Expected the series page, got %d:\n%s", code, body)
+	}
+	foundations := strings.Index(body, `href="/foundations"`)
+	walls := strings.Index(body, `href="/walls"`)
+	roof := strings.Index(body, `href="/roof"`)
+	if foundations < 0 || walls < foundations || roof < walls || !strings.Contains(body, "Part 3:") {
+		t.Errorf("Expected the parts numbered by position, got:\n%s", body)
+	}
+	if strings.Contains(body, "/chimney") || strings.Contains(body, "/porch") || strings.Contains(body, "Part 4:") {
+		t.Errorf("Expected the draft and unlisted parts to be left out, got:\n%s", body)
+	}
+
+	code, body = get("/series/building-a-cabin/feed.atom")
+	if code != http.StatusOK {
+		t.Fatalf("Expected the series feed, got %d:\n%s", code, body)
+	}
+	roof = strings.Index(body, "<title>roof</title>")
+	walls = strings.Index(body, "<title>walls</title>")
+	foundations = strings.Index(body, "<title>foundations</title>")
+	if roof < 0 || walls < roof || foundations < walls {
+		t.Errorf("Expected the newest part first in the feed, got:\n%s", body)
+	}
+	if strings.Contains(body, "/chimney") || strings.Contains(body, "/porch") || strings.Contains(body, "/shed") {
+		t.Errorf("Expected only the published parts in the feed, got:\n%s", body)
+	}
+
+	if code, body := get("/walls"); code != http.StatusOK || !strings.Contains(body, "Part 2 of 3") {
+		t.Errorf("Expected the walls to be part 2 of 3, got %d:\n%s", code, body)
+	}
+
+	if code, _ := get("/series/missing"); code != http.StatusNotFound {
+		t.Errorf("Expected a missing series to be not found, got %d", code)
+	}
+}
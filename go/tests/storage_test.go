@@ -0,0 +1,207 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cassiascheffer/willow_camp/internal/media"
+	"github.com/cassiascheffer/willow_camp/internal/storage"
+)
+
+// fakeS3 is an in-memory stand-in for MinIO that speaks enough of the S3 API
+// (path-style PUT, GET, HEAD and DELETE on objects) for the storage backend
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	types   map[string]string
+}
+
+func newFakeS3(t *testing.T) *httptest.Server {
+	t.Helper()
+	fake := &fakeS3{objects: map[string][]byte{}, types: map[string]string{}}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") == "" {
+		http.Error(w, "unsigned request", http.StatusForbidden)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key := r.URL.Path
+	switch r.Method {
+	case http.MethodPut:
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+			if body, err = decodeAWSChunked(body); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		f.objects[key] = body
+		f.types[key] = r.Header.Get("Content-Type")
+		w.Header().Set("ETag", `"etag"`)
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet, http.MethodHead:
+		body, ok := f.objects[key]
+		if !ok {
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusNotFound)
+			if r.Method == http.MethodGet {
+				fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>NoSuchKey</Code><Message>missing</Message></Error>`)
+			}
+			return
+		}
+		w.Header().Set("Content-Type", f.types[key])
+		w.Header().Set("Content-Length", fmt.Sprint(len(body)))
+		w.Header().Set("ETag", `"etag"`)
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			w.Write(body)
+		}
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// decodeAWSChunked strips the per-chunk signatures from a streaming SigV4 upload
+func decodeAWSChunked(body []byte) ([]byte, error) {
+	var out []byte
+	for {
+		line, rest, ok := bytes.Cut(body, []byte("\r\n"))
+		if !ok {
+			return nil, errors.New("malformed chunk header")
+		}
+		sizeHex, _, _ := bytes.Cut(line, []byte(";"))
+		var size int
+		if _, err := fmt.Sscanf(string(sizeHex), "%x", &size); err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			return out, nil
+		}
+		if len(rest) < size+2 {
+			return nil, errors.New("truncated chunk")
+		}
+		out = append(out, rest[:size]...)
+		body = rest[size+2:]
+	}
+}
+
+// exerciseStorage runs the same put/open/delete checks against any backend
+func exerciseStorage(t *testing.T, store storage.Storage) {
+	t.Helper()
+	ctx := context.Background()
+	content := []byte("hello from the camp")
+
+	if err := store.Put(ctx, "blogs/1/hello.txt", bytes.NewReader(content), int64(len(content)), "text/plain"); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	r, err := store.Open(ctx, "blogs/1/hello.txt")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	got, err := io.ReadAll(r)
+	r.Close()
+	if err != nil || !bytes.Equal(got, content) {
+		t.Errorf("Expected %q, got %q (err %v)", content, got, err)
+	}
+
+	if err := store.Delete(ctx, "blogs/1/hello.txt"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := store.Open(ctx, "blogs/1/hello.txt"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound after delete, got %v", err)
+	}
+	if err := store.Delete(ctx, "blogs/1/hello.txt"); err != nil {
+		t.Errorf("Expected deleting a missing object to succeed, got %v", err)
+	}
+
+	for _, key := range []string{"../escape", "/absolute", "blogs/../../etc/passwd", ""} {
+		if err := store.Put(ctx, key, strings.NewReader("x"), 1, "text/plain"); err == nil {
+			t.Errorf("Expected key %q to be rejected", key)
+		}
+	}
+}
+
+// TestLocalStorage verifies the local-disk backend
+func TestLocalStorage(t *testing.T) {
+	store, err := storage.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocal failed: %v", err)
+	}
+	exerciseStorage(t, store)
+}
+
+// TestS3Storage verifies the S3 backend against an in-process MinIO stand-in
+func TestS3Storage(t *testing.T) {
+	server := newFakeS3(t)
+
+	store, err := storage.NewS3(storage.S3Config{
+		Endpoint:        strings.TrimPrefix(server.URL, "http://"),
+		Bucket:          "uploads",
+		AccessKeyID:     "minioadmin",
+		SecretAccessKey: "minioadmin",
+	})
+	if err != nil {
+		t.Fatalf("NewS3 failed: %v", err)
+	}
+	exerciseStorage(t, store)
+}
+
+// TestDetectUploadType verifies uploads are typed by content, not by name or client header
+func TestDetectUploadType(t *testing.T) {
+	tests := []struct {
+		name    string
+		head    []byte
+		want    string
+		wantExt string
+		wantErr bool
+	}{
+		{"png", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), "image/png", ".png", false},
+		{"jpeg", []byte("\xff\xd8\xff\xe0\x00\x10JFIF\x00"), "image/jpeg", ".jpg", false},
+		{"gif", []byte("GIF89a\x01\x00\x01\x00"), "image/gif", ".gif", false},
+		{"pdf", []byte("%PDF-1.7\n"), "application/pdf", ".pdf", false},
+		{"svg", []byte(`<svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script></svg>`), "", "", true},
+		{"html", []byte("<!DOCTYPE html><html><script>alert(1)</script>"), "", "", true},
+		{"text", []byte("just some text"), "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ext, err := media.DetectType(tt.head)
+			if tt.wantErr {
+				if !errors.Is(err, media.ErrUnsupportedType) {
+					t.Errorf("Expected ErrUnsupportedType, got %q, %v", got, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want || ext != tt.wantExt {
+				t.Errorf("Expected %s %s, got %s %s (err %v)", tt.want, tt.wantExt, got, ext, err)
+			}
+		})
+	}
+}
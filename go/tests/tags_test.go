@@ -0,0 +1,120 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cassiascheffer/willow_camp/internal/models"
+	"github.com/cassiascheffer/willow_camp/internal/repository"
+	"github.com/google/uuid"
+)
+
+// TestTagQueries verifies tag pages list and count only a blog's published
+// posts with the tag, and post lists load their tags in one query
+func TestTagQueries(t *testing.T) {
+	pool, repos := setupTestDB(t)
+	ctx := context.Background()
+	blog := createTestBlog(t, pool, repos)
+	other := createTestBlog(t, pool, repos)
+
+	suffix := uuid.NewString()[:8]
+	hikingSlug, campingSlug := "hiking-"+suffix, "camping-"+suffix
+	hiking, err := repos.Tag.FindOrCreateByName(ctx, hikingSlug, hikingSlug)
+	if err != nil {
+		t.Fatalf("Failed to create tag: %v", err)
+	}
+	camping, err := repos.Tag.FindOrCreateByName(ctx, campingSlug, campingSlug)
+	if err != nil {
+		t.Fatalf("Failed to create tag: %v", err)
+	}
+	t.Cleanup(func() {
+		_, _ = pool.Exec(context.Background(), `DELETE FROM tags WHERE id = ANY($1)`, []uuid.UUID{hiking.ID, camping.ID})
+	})
+
+	now := time.Now().UTC().Truncate(time.Second)
+	older := createTestPost(t, repos, blog, "older", now.Add(-48*time.Hour))
+	newer := createTestPost(t, repos, blog, "newer", now.Add(-24*time.Hour))
+	draft := createTestPost(t, repos, blog, "draft", time.Time{})
+	untagged := createTestPost(t, repos, blog, "untagged", now)
+	elsewhere := createTestPost(t, repos, other, "elsewhere", now)
+	tag := func(post *models.Post, tags ...*models.Tag) {
+		for _, tag := range tags {
+			if err := repos.Tag.CreateTagging(ctx, post.ID, tag.ID); err != nil {
+				t.Fatalf("Failed to tag post: %v", err)
+			}
+		}
+	}
+	tag(older, hiking, camping)
+	tag(newer, hiking)
+	tag(draft, hiking)
+	tag(elsewhere, hiking)
+
+	t.Run("ListPublishedByTagSlug", func(t *testing.T) {
+		posts, err := repos.Post.ListPublishedByTagSlug(ctx, blog.ID, hikingSlug, 10, 0)
+		if err != nil {
+			t.Fatalf("ListPublishedByTagSlug failed: %v", err)
+		}
+		if len(posts) != 2 || posts[0].ID != newer.ID || posts[1].ID != older.ID {
+			t.Fatalf("Expected the newer then the older post, got %d posts", len(posts))
+		}
+
+		page, err := repos.Post.ListPublishedByTagSlug(ctx, blog.ID, hikingSlug, 1, 1)
+		if err != nil {
+			t.Fatalf("ListPublishedByTagSlug failed: %v", err)
+		}
+		if len(page) != 1 || page[0].ID != older.ID {
+			t.Errorf("Expected the second page to hold the older post, got %d posts", len(page))
+		}
+	})
+
+	t.Run("CountPublishedByTagSlug", func(t *testing.T) {
+		for _, tc := range []struct {
+			blogID uuid.UUID
+			slug   string
+			want   int
+		}{
+			{blog.ID, hikingSlug, 2},
+			{blog.ID, campingSlug, 1},
+			{other.ID, hikingSlug, 1},
+			{blog.ID, "no-such-tag-" + suffix, 0},
+		} {
+			count, err := repos.Post.CountPublishedByTagSlug(ctx, tc.blogID, tc.slug)
+			if err != nil {
+				t.Fatalf("CountPublishedByTagSlug failed: %v", err)
+			}
+			if count != tc.want {
+				t.Errorf("Expected %d posts tagged %s, got %d", tc.want, tc.slug, count)
+			}
+		}
+	})
+
+	t.Run("FindTagsForPosts", func(t *testing.T) {
+		tags, err := repos.Tag.FindTagsForPosts(ctx, []uuid.UUID{older.ID, newer.ID, untagged.ID})
+		if err != nil {
+			t.Fatalf("FindTagsForPosts failed: %v", err)
+		}
+		if len(tags[older.ID]) != 2 || len(tags[newer.ID]) != 1 || tags[newer.ID][0].ID != hiking.ID {
+			t.Errorf("Expected each post's own tags, got %+v", tags)
+		}
+		if len(tags[untagged.ID]) != 0 {
+			t.Errorf("Expected no tags for the untagged post, got %+v", tags[untagged.ID])
+		}
+
+		empty, err := repos.Tag.FindTagsForPosts(ctx, nil)
+		if err != nil || len(empty) != 0 {
+			t.Errorf("Expected no tags for no posts, got %+v, %v", empty, err)
+		}
+	})
+
+	t.Run("FindBySlug", func(t *testing.T) {
+		found, err := repos.Tag.FindBySlug(ctx, hikingSlug)
+		if err != nil || found.ID != hiking.ID {
+			t.Errorf("Expected to find the tag, got %+v, %v", found, err)
+		}
+		if _, err := repos.Tag.FindBySlug(ctx, "no-such-tag-"+suffix); !errors.Is(err, repository.ErrTagNotFound) {
+			t.Errorf("Expected ErrTagNotFound, got %v", err)
+		}
+	})
+}
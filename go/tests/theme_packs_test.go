@@ -0,0 +1,135 @@
+package tests
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cassiascheffer/willow_camp/internal/views"
+)
+
+// writePack creates a theme pack directory with the given files
+func writePack(t *testing.T, root, name string, files map[string]string) {
+	t.Helper()
+	dir := filepath.Join(root, name)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for file, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, file), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+const packManifest = `{"label": "Test", "contract": 1}`
+
+func TestBundledThemePacks(t *testing.T) {
+	registry, err := views.Load(views.Config{})
+	if err != nil {
+		t.Fatalf("Failed to load templates: %v", err)
+	}
+	if !registry.HasPack("paper") {
+		t.Fatal("Expected the bundled paper pack to load")
+	}
+	if packs := registry.Packs(); len(packs) == 0 || packs[0].Label == "" {
+		t.Errorf("Expected packs with labels, got %+v", packs)
+	}
+
+	if views.BlogTemplate("", "index.html") != "blog/index.html" {
+		t.Error("Expected the default pack to use the blog templates")
+	}
+	for _, page := range views.BlogPages() {
+		var buf bytes.Buffer
+		if err := registry.Execute(&buf, views.BlogTemplate("paper", page), views.SampleBlogData(page)); err != nil {
+			t.Errorf("Failed to render paper %s: %v", page, err)
+		}
+	}
+}
+
+func TestThemePackValidation(t *testing.T) {
+	root := t.TempDir()
+	writePack(t, root, "minimal", map[string]string{
+		"theme.json": packManifest,
+		"index.html": `{{define "content"}}<p class="minimal">{{len .Posts}} posts</p>{{end}}`,
+	})
+	writePack(t, root, "undocumented", map[string]string{
+		"theme.json": packManifest,
+		"index.html": `{{define "content"}}{{.Secret}}{{end}}`,
+	})
+	writePack(t, root, "extra-page", map[string]string{
+		"theme.json":   packManifest,
+		"gallery.html": `{{define "content"}}{{end}}`,
+	})
+	writePack(t, root, "old", map[string]string{
+		"theme.json": `{"label": "Old", "contract": 0}`,
+		"index.html": `{{define "content"}}{{end}}`,
+	})
+	writePack(t, root, "unparsable", map[string]string{
+		"theme.json":  packManifest,
+		"layout.html": `{{if .Title}}`,
+	})
+
+	registry, err := views.Load(views.Config{PacksDir: root})
+	if err != nil {
+		t.Fatalf("Expected broken packs not to fail loading, got %v", err)
+	}
+	if !registry.HasPack("minimal") {
+		t.Error("Expected the minimal pack to load")
+	}
+	for _, name := range []string{"undocumented", "extra-page", "old", "unparsable"} {
+		if registry.HasPack(name) {
+			t.Errorf("Expected pack %s to be rejected", name)
+		}
+	}
+
+	// Pages a pack leaves out come from the default templates
+	var buf bytes.Buffer
+	if err := registry.Execute(&buf, views.BlogTemplate("minimal", "index.html"), views.SampleBlogData("index.html")); err != nil {
+		t.Fatalf("Failed to render: %v", err)
+	}
+	if !strings.Contains(buf.String(), `<p class="minimal">1 posts</p>`) || !strings.Contains(buf.String(), "skip-link") {
+		t.Errorf("Expected the pack's page in the default layout, got %s", buf.String())
+	}
+}
+
+func TestThemePackFallback(t *testing.T) {
+	root := t.TempDir()
+	// Only fails for page 99, which load-time validation doesn't exercise
+	writePack(t, root, "fragile", map[string]string{
+		"theme.json": packManifest,
+		"index.html": `{{define "content"}}<p class="fragile">{{if eq .CurrentPage 99}}{{index .Posts 5}}{{end}}</p>{{end}}`,
+	})
+	registry, err := views.Load(views.Config{PacksDir: root})
+	if err != nil {
+		t.Fatalf("Failed to load templates: %v", err)
+	}
+
+	data := views.SampleBlogData("index.html")
+	var buf bytes.Buffer
+	if err := registry.Execute(&buf, views.BlogTemplate("fragile", "index.html"), data); err != nil {
+		t.Fatalf("Failed to render: %v", err)
+	}
+	if !strings.Contains(buf.String(), `class="fragile"`) {
+		t.Error("Expected the pack to render")
+	}
+
+	data["CurrentPage"] = 99
+	buf.Reset()
+	if err := registry.Execute(&buf, views.BlogTemplate("fragile", "index.html"), data); err != nil {
+		t.Fatalf("Expected a failing pack to fall back, got %v", err)
+	}
+	if strings.Contains(buf.String(), "fragile") || !strings.Contains(buf.String(), "posts-list") {
+		t.Errorf("Expected only the default template's output, got %s", buf.String())
+	}
+
+	buf.Reset()
+	if err := registry.Execute(&buf, views.BlogTemplate("removed", "index.html"), views.SampleBlogData("index.html")); err != nil {
+		t.Fatalf("Expected an unknown pack to fall back, got %v", err)
+	}
+	if !strings.Contains(buf.String(), "posts-list") {
+		t.Error("Expected the default template for an unknown pack")
+	}
+}
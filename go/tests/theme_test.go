@@ -0,0 +1,268 @@
+package tests
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/cassiascheffer/willow_camp/internal/blog/handlers"
+	"github.com/cassiascheffer/willow_camp/internal/models"
+	sharedhandlers "github.com/cassiascheffer/willow_camp/internal/shared/handlers"
+	"github.com/cassiascheffer/willow_camp/internal/theme"
+	"github.com/labstack/echo/v4"
+)
+
+func TestSanitizeCSS(t *testing.T) {
+	cases := []struct {
+		name    string
+		input   string
+		keep    []string
+		removed []string
+	}{
+		{
+			name:  "plain rules are kept",
+			input: ".post-summary h3 { color: #333; }",
+			keep:  []string{".post-summary h3 { color: #333; }"},
+		},
+		{
+			name:    "imports are removed",
+			input:   "@import url(https://evil.example/x.css);\n@IMPORT 'other.css';\nbody { color: red; }",
+			keep:    []string{"body { color: red; }"},
+			removed: []string{"@import", "@IMPORT", "evil.example"},
+		},
+		{
+			name:    "script urls are replaced",
+			input:   `body { background: url("javascript:alert(1)"); } p { background: url(http://tracker.example/p.gif); }`,
+			removed: []string{"javascript", "tracker.example"},
+		},
+		{
+			name:  "https, same-site and data image urls are kept",
+			input: `a { background: url(https://cdn.example/a.png); } b { background: url('/media/abc.png'); } i { background: url(data:image/png;base64,AAAA); }`,
+			keep:  []string{"https://cdn.example/a.png", "/media/abc.png", "data:image/png;base64,AAAA"},
+		},
+		{
+			name:    "protocol-relative urls are replaced",
+			input:   "a { background: url(//evil.example/a.png); }",
+			removed: []string{"evil.example"},
+		},
+		{
+			name:    "escapes cannot hide keywords",
+			input:   `a { background: u\72 l(javascript:alert(1)); width: expr\65 ssion(alert(1)); }`,
+			removed: []string{"javascript", "expression"},
+		},
+		{
+			name:    "unterminated urls and image-set are broken",
+			input:   `a { background: image-set("http://evil.example/a.png" 1x); } b { background: url(http://evil.example/b.png`,
+			removed: []string{"image-set(", "url(http"},
+		},
+		{
+			name:    "script-running properties are removed",
+			input:   "a { behavior: url(x.htc); -moz-binding: url(x.xml#y); color: blue; }",
+			keep:    []string{"color: blue;"},
+			removed: []string{"behavior", "-moz-binding"},
+		},
+		{
+			name:    "style elements cannot be closed",
+			input:   `p::after { content: "</style><script>alert(1)</script>"; }`,
+			removed: []string{"<"},
+		},
+		{
+			name:    "comments are removed",
+			input:   "/* @import url(x) */ a { color: red; }",
+			keep:    []string{"a { color: red; }"},
+			removed: []string{"@import"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := theme.SanitizeCSS(tc.input)
+			for _, want := range tc.keep {
+				if !strings.Contains(got, want) {
+					t.Errorf("Expected %q to be kept, got %q", want, got)
+				}
+			}
+			for _, bad := range tc.removed {
+				if strings.Contains(got, bad) {
+					t.Errorf("Expected %q to be removed, got %q", bad, got)
+				}
+			}
+			if again := theme.SanitizeCSS(got); again != got {
+				t.Errorf("Expected sanitizing to be idempotent:\n%q\n%q", got, again)
+			}
+		})
+	}
+}
+
+func TestThemeValidate(t *testing.T) {
+	valid := models.ThemeOverrides{PrimaryColor: "#1d4ed8", AccentColor: "#fff", BodyFont: "serif", HeadingFont: "sans", ContentWidth: "wide"}
+	if err := theme.Validate(valid); err != nil {
+		t.Errorf("Expected valid overrides, got %v", err)
+	}
+	if err := theme.Validate(models.ThemeOverrides{}); err != nil {
+		t.Errorf("Expected empty overrides to be valid, got %v", err)
+	}
+	if err := theme.Validate(models.ThemeOverrides{PrimaryColor: "red; } body { display: none"}); !errors.Is(err, theme.ErrInvalidColor) {
+		t.Errorf("Expected ErrInvalidColor, got %v", err)
+	}
+	if err := theme.Validate(models.ThemeOverrides{BodyFont: "Comic Sans"}); !errors.Is(err, theme.ErrUnknownFont) {
+		t.Errorf("Expected ErrUnknownFont, got %v", err)
+	}
+	if err := theme.Validate(models.ThemeOverrides{ContentWidth: "999px"}); !errors.Is(err, theme.ErrUnknownWidth) {
+		t.Errorf("Expected ErrUnknownWidth, got %v", err)
+	}
+}
+
+func TestThemeStylesheet(t *testing.T) {
+	blog := &models.Blog{Theme: "dark"}
+	if css := theme.Stylesheet(blog); css != "" {
+		t.Errorf("Expected no stylesheet without customizations, got %q", css)
+	}
+	if theme.URL(blog) != "" {
+		t.Error("Expected no theme URL without customizations")
+	}
+
+	custom := ".post-summary { border: 1px solid; }"
+	blog.ThemeOverrides = models.ThemeOverrides{PrimaryColor: "#ffeb3b", AccentColor: "#1d4ed8", BodyFont: "serif", ContentWidth: "wide"}
+	blog.CustomCSS = &custom
+	css := theme.Stylesheet(blog)
+	for _, want := range []string{
+		"--color-primary: #ffeb3b",
+		"--color-primary-content: #000000", // dark text on yellow
+		"--color-accent-content: #ffffff",  // light text on blue
+		"font-family: ui-serif",
+		"max-width: 64rem",
+		custom,
+	} {
+		if !strings.Contains(css, want) {
+			t.Errorf("Expected stylesheet to contain %q, got:\n%s", want, css)
+		}
+	}
+
+	url := theme.URL(blog)
+	if url != "/theme.css?v="+theme.Version(css) {
+		t.Errorf("Expected a versioned URL, got %s", url)
+	}
+	blog.ThemeOverrides.ContentWidth = "narrow"
+	if theme.URL(blog) == url {
+		t.Error("Expected the URL to change with the stylesheet")
+	}
+}
+
+func TestThemeCSSHandler(t *testing.T) {
+	custom := "a { color: red; }"
+	blog := &models.Blog{Theme: "light", CustomCSS: &custom, ThemeOverrides: models.ThemeOverrides{AccentColor: "#123456"}}
+	h := handlers.New(nil, nil, "willow.camp")
+	e := echo.New()
+
+	serve := func(target, etag string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.Set("blog", blog)
+		if err := h.ThemeCSS(c); err != nil {
+			t.Fatalf("ThemeCSS failed: %v", err)
+		}
+		return rec
+	}
+
+	rec := serve(theme.URL(blog), "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rec.Code)
+	}
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/css") {
+		t.Errorf("Expected text/css, got %q", rec.Header().Get("Content-Type"))
+	}
+	if rec.Header().Get("Cache-Control") != "public, max-age=31536000, immutable" {
+		t.Errorf("Expected the versioned URL to be immutable, got %q", rec.Header().Get("Cache-Control"))
+	}
+	if !strings.Contains(rec.Body.String(), custom) {
+		t.Errorf("Expected custom CSS in the response, got %q", rec.Body.String())
+	}
+
+	if rec := serve("/theme.css", ""); strings.Contains(rec.Header().Get("Cache-Control"), "immutable") {
+		t.Error("Expected an unversioned request not to be cached as immutable")
+	}
+	if rec := serve("/theme.css", `"`+theme.Version(theme.Stylesheet(blog))+`"`); rec.Code != http.StatusNotModified {
+		t.Errorf("Expected 304 for a matching ETag, got %d", rec.Code)
+	}
+}
+
+func TestHighlightStyle(t *testing.T) {
+	for _, style := range theme.HighlightStyles {
+		if _, ok := styles.Registry[style.Key]; !ok {
+			t.Errorf("Expected %s to be a chroma style", style.Key)
+		}
+	}
+
+	cases := []struct {
+		blog models.Blog
+		want string
+	}{
+		{models.Blog{Theme: "light"}, theme.DefaultLightHighlight},
+		{models.Blog{Theme: "dracula"}, theme.DefaultDarkHighlight},
+		{models.Blog{}, theme.DefaultLightHighlight},
+		{models.Blog{Theme: "dark", HighlightStyle: "solarized-light"}, "solarized-light"},
+		{models.Blog{Theme: "dark", HighlightStyle: "removed"}, theme.DefaultDarkHighlight},
+	}
+	for _, tc := range cases {
+		if got := theme.Highlight(&tc.blog); got != tc.want {
+			t.Errorf("Highlight(%q, %q) = %q, want %q", tc.blog.Theme, tc.blog.HighlightStyle, got, tc.want)
+		}
+	}
+
+	if err := theme.ValidateHighlight("bogus"); !errors.Is(err, theme.ErrUnknownHighlight) {
+		t.Errorf("Expected an unknown style to be rejected, got %v", err)
+	}
+	if err := theme.ValidateHighlight(""); err != nil {
+		t.Errorf("Expected a blank style to follow the theme, got %v", err)
+	}
+}
+
+func TestHighlightCSSHandler(t *testing.T) {
+	blog := &models.Blog{Theme: "light"}
+	h := sharedhandlers.New(nil, nil, "willow.camp")
+	e := echo.New()
+
+	serve := func(target string) (*httptest.ResponseRecorder, error) {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("file")
+		c.SetParamValues(strings.TrimPrefix(strings.SplitN(target, "?", 2)[0], "/highlight/"))
+		return rec, h.HighlightCSS(c)
+	}
+
+	url := theme.HighlightURL(blog)
+	if !strings.HasPrefix(url, "/highlight/github.css?v=") {
+		t.Fatalf("Expected the light default's versioned URL, got %s", url)
+	}
+	rec, err := serve(url)
+	if err != nil {
+		t.Fatalf("HighlightCSS failed: %v", err)
+	}
+	if rec.Header().Get("Cache-Control") != "public, max-age=31536000, immutable" {
+		t.Errorf("Expected the versioned URL to be immutable, got %q", rec.Header().Get("Cache-Control"))
+	}
+	body := rec.Body.String()
+	for _, want := range []string{".chroma {", ".chroma .hl {", ".chroma .ln {", ".chroma .kd {", ".chroma { color: #000000; }"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected %q in the stylesheet", want)
+		}
+	}
+	if strings.Contains(body, ".bg {") {
+		t.Error("Expected the generic .bg rule to be dropped")
+	}
+
+	for _, target := range []string{"/highlight/bogus.css", "/highlight/github.txt"} {
+		if _, err := serve(target); err == nil {
+			t.Errorf("Expected %s to be not found", target)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package tests
+
+import (
+	"bytes"
+	"html/template"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cassiascheffer/willow_camp/internal/archive"
+	"github.com/cassiascheffer/willow_camp/internal/assets"
+	"github.com/cassiascheffer/willow_camp/internal/markdown"
+	"github.com/cassiascheffer/willow_camp/internal/models"
+	"github.com/cassiascheffer/willow_camp/internal/theme"
+	"github.com/cassiascheffer/willow_camp/internal/views"
+	"github.com/google/uuid"
+)
+
+func strPtr(s string) *string { return &s }
+
+// viewFixtures returns representative data for every template, keyed by registry name
+func viewFixtures() map[string]interface{} {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	published := true
+	lastError := "smtp: connection refused"
+
+	user := &models.User{ID: uuid.New(), Email: "camper@example.com", Name: strPtr("Camper")}
+	blog := &models.Blog{
+		ID:              uuid.New(),
+		UserID:          user.ID,
+		Subdomain:       strPtr("camper"),
+		Title:           strPtr("Camp Notes"),
+		MetaDescription: strPtr("Notes from camp"),
+		FaviconEmoji:    strPtr("🏕️"),
+		Theme:           "light",
+		ThemeOverrides:  models.ThemeOverrides{PrimaryColor: "#1d4ed8", BodyFont: "serif"},
+		CustomCSS:       strPtr("h1 { letter-spacing: 0.05em; }"),
+	}
+	user.Blogs = []*models.Blog{blog}
+
+	tag := models.Tag{ID: uuid.New(), Name: "Hiking", Slug: strPtr("hiking"), TaggingsCount: 2}
+	post := &models.Post{
+		ID:              uuid.New(),
+		BlogID:          blog.ID,
+		AuthorID:        user.ID,
+		Title:           strPtr("First Hike"),
+		Slug:            strPtr("first-hike"),
+		BodyMarkdown:    strPtr("# Hello"),
+		MetaDescription: strPtr("A walk in the woods"),
+		Published:       &published,
+		PublishedAt:     &now,
+		Tags:            []models.Tag{tag},
+	}
+	posts := []*models.Post{post}
+	series := &models.Series{ID: uuid.New(), BlogID: blog.ID, Title: "Trail Guide", Slug: "trail-guide"}
+	lockedPost := &models.Post{ID: uuid.New(), Title: strPtr("Secret Hike"), Slug: strPtr("secret-hike"), Published: &published, Visibility: models.VisibilityPassword}
+
+	blogPage := func(extra map[string]interface{}) map[string]interface{} {
+		data := map[string]interface{}{
+			"Blog":            blog,
+			"Title":           "Camp Notes",
+			"BlogTitle":       "Camp Notes",
+			"Favicon":         assets.EmojiFavicon(assets.DefaultEmoji),
+			"Pages":           posts,
+			"OGTitle":         "Camp Notes",
+			"OGDescription":   "Notes from camp",
+			"OGType":          "website",
+			"CurrentURL":      "https://camper.willow.camp/",
+			"ThemeStylesheet": theme.URL(blog),
+		}
+		for k, v := range extra {
+			data[k] = v
+		}
+		return data
+	}
+
+	dashboardPage := func(extra map[string]interface{}) map[string]interface{} {
+		data := map[string]interface{}{
+			"Title":      "Camp Notes",
+			"User":       user,
+			"Blog":       blog,
+			"Blogs":      user.Blogs,
+			"ActiveTab":  "posts",
+			"NavTitle":   "Camp Notes",
+			"NavPath":    "/dashboard/blogs/camper/posts",
+			"Favicon":    assets.EmojiFavicon(assets.DefaultEmoji),
+			"BaseDomain": "willow.camp",
+		}
+		for k, v := range extra {
+			data[k] = v
+		}
+		return data
+	}
+
+	applicationPage := map[string]interface{}{
+		"Title":    "willow.camp",
+		"Themes":   []string{"light", "dark"},
+		"Emojis":   []struct{ Emoji, Code string }{{Emoji: "🏕️", Code: "1F3D5"}},
+		"ShowLogo": true,
+	}
+
+	return map[string]interface{}{
+		"blog/archive_index.html": blogPage(map[string]interface{}{
+			"Years": models.GroupArchiveMonths([]models.ArchiveMonth{{Year: 2025, Month: time.June, Count: 1}, {Year: 2024, Month: time.May, Count: 3}}),
+		}),
+		"blog/archive_show.html": blogPage(map[string]interface{}{
+			"Posts": posts, "Year": 2025, "Month": time.June, "CurrentPage": 1, "TotalPages": 2,
+			"Months": []models.ArchiveMonth{{Year: 2025, Month: time.June, Count: 1}, {Year: 2025, Month: time.March, Count: 2}},
+		}),
+		"blog/index.html": blogPage(map[string]interface{}{
+			"FeaturedPosts": posts, "Posts": posts, "CurrentPage": 2, "TotalPages": 3,
+		}),
+		"blog/post_show.html": blogPage(map[string]interface{}{
+			"Post":            post,
+			"Tags":            post.Tags,
+			"RenderedContent": template.HTML("<h2 id=\"hello\">Hello</h2><h2 id=\"bye\">Bye</h2>"),
+			"TOC":             markdown.TOC{{Level: 2, ID: "hello", Text: "Hello"}, {Level: 2, ID: "bye", Text: "Bye"}},
+			"PostFooter":      template.HTML("<p>Thanks for reading</p>"),
+			"AuthorName":      "Camper",
+			"OGType":          "article",
+			"ArticleTags":     []string{"Hiking"},
+			"ThemeCSS":        template.CSS(theme.Stylesheet(blog)),
+			"SeriesPart":      &models.SeriesPart{Series: series, Part: 2, Total: 3, Previous: lockedPost, Next: post},
+			"PreviousPost":    lockedPost,
+			"NextPost":        (*models.Post)(nil),
+			"RelatedPosts":    posts,
+		}),
+		"blog/post_locked.html": blogPage(map[string]interface{}{
+			"Post": lockedPost, "Message": "That password isn't right. Please try again.", "EmailEnabled": true,
+		}),
+		"blog/series_show.html": blogPage(map[string]interface{}{"Series": series, "Posts": posts}),
+		"blog/subscribe.html": blogPage(map[string]interface{}{
+			"RSSFeedURL": "/feed.rss", "AtomFeedURL": "/feed.atom", "JSONFeedURL": "/feed.json", "EmailEnabled": true,
+		}),
+		"blog/subscribe_status.html": blogPage(map[string]interface{}{"Heading": "Check your inbox", "Message": "We sent a link."}),
+		"blog/tag_show.html": blogPage(map[string]interface{}{
+			"Posts": posts, "TagName": "Hiking", "CurrentPage": 1, "TotalPages": 2,
+		}),
+		"blog/tags_index.html":      blogPage(map[string]interface{}{"Tags": []models.Tag{tag}}),
+		"blog/unsubscribe.html":     blogPage(map[string]interface{}{"Token": "abc123"}),
+		"dashboard/index.html":      dashboardPage(map[string]interface{}{"ShowNewBlogForm": true}),
+		"dashboard/posts_list.html": dashboardPage(map[string]interface{}{"Posts": posts}),
+		"dashboard/post_form.html": dashboardPage(map[string]interface{}{
+			"Post": post, "IsEdit": true, "TagsString": "Hiking", "AllTags": []string{"Hiking"},
+			"PreviewLinks": []struct {
+				*models.PreviewLink
+				URL string
+			}{{PreviewLink: &models.PreviewLink{ID: uuid.New(), PostID: post.ID, ExpiresAt: now}, URL: "https://camper.willow.camp/preview/abc"}},
+			"PreviewLinkDays": []int{1, 7, 30},
+			"SeriesTitle":     series.Title, "SeriesPosition": 2, "AllSeries": []*models.Series{series},
+		}),
+		"dashboard/blog_settings.html": dashboardPage(map[string]interface{}{
+			"AboutPage": post, "ThemeFonts": theme.Fonts, "ThemeWidths": theme.Widths, "MaxCustomCSS": theme.MaxCustomCSSBytes,
+			"ThemePacks": []views.Pack{{Name: "paper", Label: "Paper", Description: "Serif"}},
+		}),
+		"dashboard/tags_index.html": dashboardPage(map[string]interface{}{
+			"Tags": []models.TagWithCounts{{ID: tag.ID, Name: tag.Name, Slug: tag.Slug, PublishedCount: 1, DraftCount: 1}},
+		}),
+		"dashboard/security.html": dashboardPage(map[string]interface{}{"LastViewedBlog": blog, "SuccessMessage": "Saved"}),
+		"dashboard/subscribers.html": dashboardPage(map[string]interface{}{
+			"Subscribers": []*models.EmailSubscriber{{ID: uuid.New(), BlogID: blog.ID, Email: "reader@example.com", CreatedAt: now, ConfirmedAt: &now}},
+			"ActiveCount": 1,
+		}),
+		"dashboard/jobs.html": dashboardPage(map[string]interface{}{
+			"Jobs": []*models.Job{{ID: uuid.New(), Kind: "newsletter.deliver_post", Attempts: 5, MaxAttempts: 5, LastError: &lastError, FinishedAt: &now}},
+		}),
+		"dashboard/webmentions.html": dashboardPage(map[string]interface{}{
+			"Mentions": []*models.Webmention{
+				{ID: uuid.New(), Source: "https://elsewhere.example/reply", Target: "https://sample.willow.camp/hike", Status: models.WebmentionPending, MentionType: "reply", AuthorName: "A Reader", Content: "Lovely trail", CreatedAt: now},
+				{ID: uuid.New(), Source: "https://other.example/post", Target: "https://sample.willow.camp/hike", Status: models.WebmentionApproved, MentionType: "mention", AuthorName: "other.example", CreatedAt: now},
+			},
+			"PendingCount": 1,
+		}),
+		"dashboard/comments.html": dashboardPage(map[string]interface{}{
+			"Comments": []*models.Comment{
+				{ID: uuid.New(), AuthorName: "A Reader", AuthorEmail: "reader@example.com", AuthorURL: "https://reader.example", Body: "Lovely *trail*", Status: models.CommentPending, CreatedAt: now, PostTitle: "Hike", PostSlug: "hike"},
+				{ID: uuid.New(), AuthorName: "Another Reader", Body: "Thanks", Status: models.CommentApproved, CreatedAt: now},
+			},
+			"PendingCount": 1,
+		}),
+		"dashboard/import_report.html": dashboardPage(map[string]interface{}{
+			"Report": &archive.Report{
+				DryRun:   true,
+				Format:   archive.FormatJekyll,
+				Items:    []archive.ReportItem{{Path: "_posts/2020-01-01-hike.md", Title: "Hike", Slug: "hike-1", RequestedSlug: "hike", Published: true, Tags: []string{"Hiking"}}},
+				Media:    1,
+				Settings: []string{"Title"},
+				Warnings: []string{"_posts/2020-01-01-hike.md: Liquid tags are kept as plain text"},
+			},
+		}),
+		"application/home.html":  applicationPage,
+		"application/docs.html":  applicationPage,
+		"application/terms.html": applicationPage,
+		"simple/login.html":      map[string]interface{}{"Title": "Log in", "Error": "Invalid email or password"},
+		"email/confirm.html": map[string]interface{}{
+			"BlogTitle": "Camp Notes", "BlogURL": "https://camper.willow.camp", "ConfirmURL": "https://camper.willow.camp/subscribe/confirm?token=abc",
+		},
+		"email/post.html": map[string]interface{}{
+			"BlogTitle": "Camp Notes", "BlogURL": "https://camper.willow.camp", "PostTitle": "First Hike",
+			"PostURL": "https://camper.willow.camp/first-hike", "Body": template.HTML("<p>Hello</p>"),
+			"UnsubscribeURL": "https://camper.willow.camp/unsubscribe?token=abc",
+		},
+		"email/comment.html": map[string]interface{}{
+			"BlogTitle": "Camp Notes", "PostTitle": "First Hike", "PostURL": "https://camper.willow.camp/first-hike",
+			"AuthorName": "Reader", "AuthorURL": "https://reader.example", "Body": template.HTML("<p>Great trail</p>"),
+			"ModerateURL": "https://willow.camp/dashboard/blogs/camper/comments",
+		},
+		"email/member_link.html": map[string]interface{}{
+			"BlogTitle": "Camp Notes", "BlogURL": "https://camper.willow.camp", "Minutes": 30,
+			"SignInURL": "https://camper.willow.camp/members/signin/confirm?token=abc&slug=first-hike",
+		},
+	}
+}
+
+// TestTemplatesRender parses every embedded template and executes it with
+// representative data, so a broken template fails here rather than in production
+func TestTemplatesRender(t *testing.T) {
+	registry, err := views.Load(views.Config{})
+	if err != nil {
+		t.Fatalf("Failed to parse templates: %v", err)
+	}
+
+	fixtures := viewFixtures()
+	names := registry.Names()
+	if len(names) == 0 {
+		t.Fatal("Expected templates to be registered")
+	}
+
+	for _, name := range names {
+		t.Run(name, func(t *testing.T) {
+			// Theme pack pages get the same data as the default blog pages
+			fixture := name
+			if set, page, _ := strings.Cut(name, "/"); strings.HasPrefix(set, "blog@") {
+				fixture = "blog/" + page
+			}
+			data, ok := fixtures[fixture]
+			if !ok {
+				t.Fatalf("No fixture data for %s; add one to viewFixtures", name)
+			}
+			var buf bytes.Buffer
+			if err := registry.Execute(&buf, name, data); err != nil {
+				t.Fatalf("Failed to render: %v", err)
+			}
+			if !strings.Contains(buf.String(), "<") {
+				t.Errorf("Expected HTML output, got %q", buf.String())
+			}
+		})
+	}
+
+	for name := range fixtures {
+		if err := registry.Execute(&bytes.Buffer{}, name, fixtures[name]); err != nil && strings.Contains(err.Error(), "unknown template") {
+			t.Errorf("Fixture %s does not match a template", name)
+		}
+	}
+}
+
+func TestUnknownTemplate(t *testing.T) {
+	registry, err := views.Load(views.Config{})
+	if err != nil {
+		t.Fatalf("Failed to parse templates: %v", err)
+	}
+	if err := registry.Execute(&bytes.Buffer{}, "blog/missing.html", nil); err == nil {
+		t.Error("Expected an error for a missing template")
+	}
+	if err := registry.Execute(&bytes.Buffer{}, "nope", nil); err == nil {
+		t.Error("Expected an error for a name without a set")
+	}
+}
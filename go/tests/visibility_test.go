@@ -0,0 +1,372 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cassiascheffer/willow_camp/internal/access"
+	"github.com/cassiascheffer/willow_camp/internal/archive"
+	"github.com/cassiascheffer/willow_camp/internal/comments"
+	"github.com/cassiascheffer/willow_camp/internal/models"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// accessContext returns an echo context for a request carrying the cookies
+// set on a previous response
+func accessContext(previous *httptest.ResponseRecorder) (echo.Context, *httptest.ResponseRecorder) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if previous != nil {
+		for _, cookie := range previous.Result().Cookies() {
+			req.AddCookie(cookie)
+		}
+	}
+	rec := httptest.NewRecorder()
+	return echo.New().NewContext(req, rec), rec
+}
+
+// unlockAttempt is a wrong password stored by memoryUnlockAttempts
+type unlockAttempt struct {
+	blogID    uuid.UUID
+	postID    uuid.UUID
+	ipHash    string
+	createdAt time.Time
+}
+
+// memoryUnlockAttempts is an in-memory access.AttemptStore
+type memoryUnlockAttempts struct {
+	mu       sync.Mutex
+	attempts []unlockAttempt
+}
+
+func (m *memoryUnlockAttempts) CountRecentByIP(ctx context.Context, blogID uuid.UUID, ipHash string, since time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	count := 0
+	for _, a := range m.attempts {
+		if a.blogID == blogID && a.ipHash == ipHash && !a.createdAt.Before(since) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *memoryUnlockAttempts) Record(ctx context.Context, blogID, postID uuid.UUID, ipHash string, prune time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	kept := m.attempts[:0]
+	for _, a := range m.attempts {
+		if a.blogID != blogID || !a.createdAt.Before(prune) {
+			kept = append(kept, a)
+		}
+	}
+	m.attempts = append(kept, unlockAttempt{blogID: blogID, postID: postID, ipHash: ipHash, createdAt: time.Now()})
+	return nil
+}
+
+func TestPostVisibility(t *testing.T) {
+	published := true
+	draft := false
+
+	cases := []struct {
+		visibility string
+		published  *bool
+		public     bool
+		emailed    bool
+		protected  bool
+	}{
+		{"", &published, true, true, false},
+		{models.VisibilityPublic, &published, true, true, false},
+		{models.VisibilityPublic, &draft, false, false, false},
+		{models.VisibilityUnlisted, &published, false, false, false},
+		{models.VisibilityPassword, &published, false, false, true},
+		{models.VisibilityMembers, &published, false, true, true},
+		{models.VisibilityMembers, &draft, false, false, true},
+	}
+	for _, tc := range cases {
+		post := &models.Post{Visibility: tc.visibility, Published: tc.published}
+		if post.IsPublic() != tc.public || post.IsEmailed() != tc.emailed || post.IsProtected() != tc.protected {
+			t.Errorf("%q published=%v: got public=%v emailed=%v protected=%v", tc.visibility, *tc.published,
+				post.IsPublic(), post.IsEmailed(), post.IsProtected())
+		}
+	}
+}
+
+func TestPostPassword(t *testing.T) {
+	digest, err := access.HashPassword("open sesame")
+	if err != nil {
+		t.Fatalf("HashPassword failed: %v", err)
+	}
+	if strings.Contains(digest, "open sesame") {
+		t.Fatal("expected the password to be hashed")
+	}
+
+	post := &models.Post{ID: uuid.New(), Visibility: models.VisibilityPassword, PasswordDigest: &digest}
+	if !access.CheckPassword(post, "open sesame") {
+		t.Error("expected the right password to open the post")
+	}
+	for _, wrong := range []string{"", "Open sesame", "open sesame "} {
+		if access.CheckPassword(post, wrong) {
+			t.Errorf("expected %q not to open the post", wrong)
+		}
+	}
+	if access.CheckPassword(&models.Post{}, "") {
+		t.Error("expected a post without a password not to open")
+	}
+}
+
+func TestUnlockPost(t *testing.T) {
+	service := access.New("test-secret", false)
+	digest, _ := access.HashPassword("open sesame")
+	post := &models.Post{ID: uuid.New(), Visibility: models.VisibilityPassword, PasswordDigest: &digest}
+
+	c, rec := accessContext(nil)
+	if service.Unlocked(c, post) {
+		t.Fatal("expected the post to start locked")
+	}
+	if err := service.Unlock(c, post); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+
+	c, _ = accessContext(rec)
+	if !service.Unlocked(c, post) {
+		t.Error("expected the cookie to unlock the post")
+	}
+
+	// The cookie doesn't open other posts
+	other := &models.Post{ID: uuid.New(), Visibility: models.VisibilityPassword, PasswordDigest: &digest}
+	if service.Unlocked(c, other) {
+		t.Error("expected other posts to stay locked")
+	}
+
+	// Cookies signed with another secret aren't trusted
+	c, _ = accessContext(rec)
+	if access.New("other-secret", false).Unlocked(c, post) {
+		t.Error("expected a cookie signed with another secret to be ignored")
+	}
+
+	// Changing the password locks the post again
+	changed, _ := access.HashPassword("new password")
+	post.PasswordDigest = &changed
+	c, _ = accessContext(rec)
+	if service.Unlocked(c, post) {
+		t.Error("expected a new password to lock the post again")
+	}
+}
+
+func TestAccessCookieSecure(t *testing.T) {
+	digest, _ := access.HashPassword("open sesame")
+	post := &models.Post{ID: uuid.New(), Visibility: models.VisibilityPassword, PasswordDigest: &digest}
+
+	for _, secure := range []bool{true, false} {
+		c, rec := accessContext(nil)
+		if err := access.New("test-secret", secure).Unlock(c, post); err != nil {
+			t.Fatalf("Unlock failed: %v", err)
+		}
+		cookies := rec.Result().Cookies()
+		if len(cookies) != 1 || cookies[0].Secure != secure {
+			t.Errorf("expected one cookie with Secure=%v, got %+v", secure, cookies)
+		}
+	}
+}
+
+func TestTryPassword(t *testing.T) {
+	service := access.New("test-secret", false)
+	attempts := &memoryUnlockAttempts{}
+	service.SetAttempts(attempts)
+
+	digest, _ := access.HashPassword("open sesame")
+	blogID := uuid.New()
+	post := &models.Post{ID: uuid.New(), BlogID: blogID, Visibility: models.VisibilityPassword, PasswordDigest: &digest}
+	other := &models.Post{ID: uuid.New(), BlogID: blogID, Visibility: models.VisibilityPassword, PasswordDigest: &digest}
+	ipHash := comments.HashIP(blogID, "203.0.113.7")
+	ctx := context.Background()
+
+	if err := service.TryPassword(ctx, post, ipHash, "open sesame"); err != nil {
+		t.Fatalf("expected the right password to open the post, got %v", err)
+	}
+	if len(attempts.attempts) != 0 {
+		t.Errorf("expected the right password not to be counted, got %d attempts", len(attempts.attempts))
+	}
+
+	// Wrong passwords count across the blog's posts
+	for i := 0; i < access.UnlockLimit; i++ {
+		target := post
+		if i%2 == 1 {
+			target = other
+		}
+		if err := service.TryPassword(ctx, target, ipHash, "guess"); !errors.Is(err, access.ErrWrongPassword) {
+			t.Fatalf("attempt %d: expected ErrWrongPassword, got %v", i+1, err)
+		}
+	}
+	if err := service.TryPassword(ctx, post, ipHash, "open sesame"); !errors.Is(err, access.ErrTooManyAttempts) {
+		t.Errorf("expected even the right password to be refused after %d wrong ones, got %v", access.UnlockLimit, err)
+	}
+	if len(attempts.attempts) != access.UnlockLimit {
+		t.Errorf("expected refused attempts not to be counted, got %d attempts", len(attempts.attempts))
+	}
+
+	// Other addresses and other blogs aren't affected
+	if err := service.TryPassword(ctx, post, comments.HashIP(blogID, "198.51.100.1"), "open sesame"); err != nil {
+		t.Errorf("expected another address to get in, got %v", err)
+	}
+	elsewhere := &models.Post{ID: uuid.New(), BlogID: uuid.New(), Visibility: models.VisibilityPassword, PasswordDigest: &digest}
+	if err := service.TryPassword(ctx, elsewhere, ipHash, "open sesame"); err != nil {
+		t.Errorf("expected another blog's post to open, got %v", err)
+	}
+
+	// Attempts older than the window no longer count
+	attempts.mu.Lock()
+	for i := range attempts.attempts {
+		attempts.attempts[i].createdAt = time.Now().Add(-access.UnlockWindow - time.Minute)
+	}
+	attempts.mu.Unlock()
+	if err := service.TryPassword(ctx, post, ipHash, "open sesame"); err != nil {
+		t.Errorf("expected the post to open once the window passed, got %v", err)
+	}
+
+	// Without a store passwords are checked without throttling
+	if err := access.New("test-secret", false).TryPassword(ctx, post, ipHash, "guess"); !errors.Is(err, access.ErrWrongPassword) {
+		t.Errorf("expected ErrWrongPassword without a store, got %v", err)
+	}
+}
+
+func TestSignInMember(t *testing.T) {
+	service := access.New("test-secret", false)
+	blog := &models.Blog{ID: uuid.New()}
+	subscriber := &models.EmailSubscriber{ID: uuid.New(), BlogID: blog.ID}
+
+	c, rec := accessContext(nil)
+	if _, ok := service.MemberID(c, blog); ok {
+		t.Fatal("expected no member before signing in")
+	}
+	if err := service.SignInMember(c, blog, subscriber.ID); err != nil {
+		t.Fatalf("SignInMember failed: %v", err)
+	}
+
+	c, _ = accessContext(rec)
+	if id, ok := service.MemberID(c, blog); !ok || id != subscriber.ID {
+		t.Errorf("expected member %s, got %s %v", subscriber.ID, id, ok)
+	}
+	if _, ok := service.MemberID(c, &models.Blog{ID: uuid.New()}); ok {
+		t.Error("expected membership not to carry over to another blog")
+	}
+}
+
+func TestSignedLinks(t *testing.T) {
+	service := access.New("test-secret", false)
+
+	token, err := service.SignLink("preview", "post-1", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("SignLink failed: %v", err)
+	}
+	if value, err := service.VerifyLink("preview", token); err != nil || value != "post-1" {
+		t.Errorf("expected post-1, got %q, %v", value, err)
+	}
+
+	// Links are scoped by name, and can't be changed or used once expired
+	if _, err := service.VerifyLink("member_link", token); !errors.Is(err, access.ErrInvalidLink) {
+		t.Errorf("expected a link for another use to be rejected, got %v", err)
+	}
+	if _, err := service.VerifyLink("preview", token[:len(token)-2]+"xx"); !errors.Is(err, access.ErrInvalidLink) {
+		t.Errorf("expected a changed link to be rejected, got %v", err)
+	}
+	if _, err := access.New("other-secret", false).VerifyLink("preview", token); !errors.Is(err, access.ErrInvalidLink) {
+		t.Errorf("expected a link signed with another secret to be rejected, got %v", err)
+	}
+	expired, _ := service.SignLink("preview", "post-1", time.Now().Add(-time.Minute))
+	if _, err := service.VerifyLink("preview", expired); !errors.Is(err, access.ErrInvalidLink) {
+		t.Errorf("expected an expired link to be rejected, got %v", err)
+	}
+
+	subscriber := &models.EmailSubscriber{ID: uuid.New(), BlogID: uuid.New()}
+	memberToken, err := service.MemberToken(subscriber)
+	if err != nil {
+		t.Fatalf("MemberToken failed: %v", err)
+	}
+	blogID, subscriberID, err := service.VerifyMemberToken(memberToken)
+	if err != nil || blogID != subscriber.BlogID || subscriberID != subscriber.ID {
+		t.Errorf("member token did not round trip: %s %s %v", blogID, subscriberID, err)
+	}
+}
+
+func TestArchiveVisibility(t *testing.T) {
+	unlisted, err := archive.MarshalDocument(&archive.Document{Title: "Quiet", Slug: "quiet", Published: true, Visibility: models.VisibilityUnlisted})
+	if err != nil {
+		t.Fatalf("MarshalDocument failed: %v", err)
+	}
+	public, _ := archive.MarshalDocument(&archive.Document{Title: "Loud", Slug: "loud", Published: true})
+	if strings.Contains(string(public), "visibility") {
+		t.Errorf("expected public posts to leave visibility out, got %s", public)
+	}
+
+	bundle := parseZip(t, map[string]string{
+		"blog.json":       `{"version":1,"title":"My Blog"}`,
+		"posts/quiet.md":  string(unlisted),
+		"posts/secret.md": "---\ntitle: Secret\nslug: secret\npublished: true\nvisibility: password\n---\n\nShh\n",
+		"posts/odd.md":    "---\ntitle: Odd\nslug: odd\npublished: true\nvisibility: friends\n---\n\nHi\n",
+	})
+
+	if got := findDocument(t, bundle, "posts/quiet.md"); got.Visibility != models.VisibilityUnlisted || !got.Published {
+		t.Errorf("expected a published unlisted post, got %+v", got)
+	}
+	// Passwords aren't exported, so password posts wait as drafts for a new one
+	if got := findDocument(t, bundle, "posts/secret.md"); got.Visibility != models.VisibilityPassword || got.Published {
+		t.Errorf("expected a password post imported as a draft, got %+v", got)
+	}
+	if got := findDocument(t, bundle, "posts/odd.md"); got.Visibility != "" {
+		t.Errorf("expected an unknown visibility to import as public, got %q", got.Visibility)
+	}
+	if len(bundle.Warnings) != 2 {
+		t.Errorf("expected warnings for the password post and unknown visibility, got %v", bundle.Warnings)
+	}
+}
+
+// TestUnlockAttemptRepository verifies wrong passwords are counted per blog
+// and address, and recording one prunes the blog's expired attempts
+func TestUnlockAttemptRepository(t *testing.T) {
+	pool, repos := setupTestDB(t)
+	ctx := context.Background()
+	blog := createTestBlog(t, pool, repos)
+	post := createTestPost(t, repos, blog, "locked", time.Now())
+	ipHash := comments.HashIP(blog.ID, "203.0.113.7")
+	prune := time.Now().Add(-access.UnlockWindow)
+
+	for i := 0; i < 2; i++ {
+		if err := repos.UnlockAttempt.Record(ctx, blog.ID, post.ID, ipHash, prune); err != nil {
+			t.Fatalf("Record failed: %v", err)
+		}
+	}
+	if err := repos.UnlockAttempt.Record(ctx, blog.ID, post.ID, comments.HashIP(blog.ID, "198.51.100.1"), prune); err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+
+	count, err := repos.UnlockAttempt.CountRecentByIP(ctx, blog.ID, ipHash, prune)
+	if err != nil {
+		t.Fatalf("CountRecentByIP failed: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("expected 2 attempts from the address, got %d", count)
+	}
+
+	// Attempts from before the window are pruned by the next one
+	if _, err := pool.Exec(ctx, `UPDATE unlock_attempts SET created_at = $2 WHERE blog_id = $1`, blog.ID, prune.Add(-time.Minute)); err != nil {
+		t.Fatalf("Failed to age attempts: %v", err)
+	}
+	if err := repos.UnlockAttempt.Record(ctx, blog.ID, post.ID, ipHash, prune); err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+	var total int
+	if err := pool.QueryRow(ctx, `SELECT COUNT(*) FROM unlock_attempts WHERE blog_id = $1`, blog.ID).Scan(&total); err != nil {
+		t.Fatalf("Failed to count attempts: %v", err)
+	}
+	if total != 1 {
+		t.Errorf("expected expired attempts to be pruned, got %d left", total)
+	}
+}
@@ -0,0 +1,243 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cassiascheffer/willow_camp/internal/models"
+	"github.com/cassiascheffer/willow_camp/internal/previews"
+	"github.com/cassiascheffer/willow_camp/internal/webmentions"
+)
+
+func newWebmentionService() *webmentions.Service {
+	service := webmentions.New(nil, nil, "willow.camp")
+	service.SetClient(&http.Client{Timeout: 5 * time.Second})
+	return service
+}
+
+func TestWebmentionTargetSlug(t *testing.T) {
+	subdomain := "cassia"
+	domain := "blog.example.com"
+	blog := &models.Blog{Subdomain: &subdomain, CustomDomain: &domain}
+
+	valid := map[string]string{
+		"https://cassia.willow.camp/hello":   "hello",
+		"http://blog.example.com/hello/":     "hello",
+		"https://BLOG.example.com/other-one": "other-one",
+	}
+	for target, want := range valid {
+		slug, err := webmentions.TargetSlug(blog, "willow.camp", "https://elsewhere.example/reply", target)
+		if err != nil || slug != want {
+			t.Errorf("TargetSlug(%q) = %q, %v; want %q", target, slug, err, want)
+		}
+	}
+
+	invalid := []struct{ source, target string }{
+		{"ftp://elsewhere.example/reply", "https://cassia.willow.camp/hello"},
+		{"https://elsewhere.example/reply", "mailto:cassia@example.com"},
+		{"https://cassia.willow.camp/hello", "https://cassia.willow.camp/hello"},
+		{"https://elsewhere.example/reply", "https://someone.willow.camp/hello"},
+		{"https://elsewhere.example/reply", "https://cassia.willow.camp/"},
+		{"https://elsewhere.example/reply", "https://cassia.willow.camp/tags/go"},
+	}
+	for _, tc := range invalid {
+		if _, err := webmentions.TargetSlug(blog, "willow.camp", tc.source, tc.target); !errors.Is(err, webmentions.ErrInvalidMention) {
+			t.Errorf("TargetSlug(%q, %q) = %v; want ErrInvalidMention", tc.source, tc.target, err)
+		}
+	}
+}
+
+func TestWebmentionDiscover(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	mux.HandleFunc("/header", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Link", `<https://example.com/other>; rel="me", <`+server.URL+`/endpoint>; rel="webmention"`)
+		fmt.Fprint(w, "<p>hello</p>")
+	})
+	mux.HandleFunc("/relative-header", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Link", `</endpoint?version=1>; rel=webmention`)
+	})
+	mux.HandleFunc("/link", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, `<html><head><link rel="stylesheet" href="/a.css"><link rel="webmention" href="/from-link"></head><body><a rel="webmention" href="/from-a">x</a></body></html>`)
+	})
+	mux.HandleFunc("/anchor", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<p><a rel="nofollow webmention" href="/from-a">endpoint</a></p>`)
+	})
+	mux.HandleFunc("/self", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<link rel="webmention" href="">`)
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/posts/anchor", http.StatusFound)
+	})
+	mux.HandleFunc("/posts/anchor", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<link rel="webmention" href="endpoint">`)
+	})
+	mux.HandleFunc("/none", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<p>No endpoint here</p>`)
+	})
+
+	service := newWebmentionService()
+	tests := map[string]string{
+		"/header":          server.URL + "/endpoint",
+		"/relative-header": server.URL + "/endpoint?version=1",
+		"/link":            server.URL + "/from-link",
+		"/anchor":          server.URL + "/from-a",
+		"/self":            server.URL + "/self",
+		"/moved":           server.URL + "/posts/endpoint",
+	}
+	for path, want := range tests {
+		got, err := service.Discover(context.Background(), server.URL+path)
+		if err != nil || got != want {
+			t.Errorf("Discover(%s) = %q, %v; want %q", path, got, err, want)
+		}
+	}
+
+	if _, err := service.Discover(context.Background(), server.URL+"/none"); !errors.Is(err, webmentions.ErrNoEndpoint) {
+		t.Errorf("Expected ErrNoEndpoint, got %v", err)
+	}
+}
+
+func TestWebmentionSendAll(t *testing.T) {
+	var mu sync.Mutex
+	received := map[string]string{}
+
+	mux := http.NewServeMux()
+	target := httptest.NewServer(mux)
+	defer target.Close()
+	mux.HandleFunc("/post", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<link rel="webmention" href="/webmention">`)
+	})
+	mux.HandleFunc("/plain", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<p>No endpoint</p>`)
+	})
+	mux.HandleFunc("/webmention", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		mu.Lock()
+		received[r.FormValue("target")] = r.FormValue("source")
+		mu.Unlock()
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	source := "https://cassia.willow.camp/hello"
+	content := fmt.Sprintf(`<p><a href="%[1]s/post#reply">Reply</a>, <a href="%[1]s/post">again</a>,
+		<a href="%[1]s/plain">plain</a> and <a href="/about">about</a>.</p>`, target.URL)
+
+	links := webmentions.Links(source, content)
+	if len(links) != 2 || links[0] != target.URL+"/post" || links[1] != target.URL+"/plain" {
+		t.Errorf("Expected distinct external links without fragments, got %v", links)
+	}
+
+	service := newWebmentionService()
+	if err := service.SendAll(context.Background(), source, content); err != nil {
+		t.Fatalf("SendAll failed: %v", err)
+	}
+	if len(received) != 1 || received[target.URL+"/post"] != source {
+		t.Errorf("Expected a single Webmention for the post, got %v", received)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Link", `</webmention>; rel="webmention"`)
+	}))
+	defer failing.Close()
+	err := service.SendAll(context.Background(), source, `<a href="`+failing.URL+`/post">post</a>`)
+	if err == nil || !strings.Contains(err.Error(), failing.URL+"/post") {
+		t.Errorf("Expected an error naming the failed target, got %v", err)
+	}
+}
+
+func TestWebmentionVerify(t *testing.T) {
+	target := "https://cassia.willow.camp/hello"
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	mux.HandleFunc("/reply", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, `<article class="h-entry">
+			<a class="p-author h-card" href="/about"><img class="u-photo" src="/me.jpg" alt=""><span class="p-name">Sam Reader</span></a>
+			<time class="dt-published" datetime="2026-10-18T09:30:00Z">Yesterday</time>
+			<p>In reply to <a class="u-in-reply-to" href="%s/">your post</a></p>
+			<div class="e-content"><p>Lovely   post.</p><p>Thanks!</p><script>alert(1)</script></div>
+		</article>`, target)
+	})
+	mux.HandleFunc("/plain", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, `<p>See <a href="%s#comments">this</a></p>`, target)
+	})
+	mux.HandleFunc("/text", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprintf(w, "Read %s today", target)
+	})
+	mux.HandleFunc("/unrelated", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<p><a href="https://cassia.willow.camp/other">Another post</a></p>`)
+	})
+	mux.HandleFunc("/deleted", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+	})
+
+	service := newWebmentionService()
+
+	reply := &models.Webmention{Source: server.URL + "/reply", Target: target}
+	if err := service.Verify(context.Background(), reply); err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if reply.MentionType != "reply" || reply.Verb() != "replied" {
+		t.Errorf("Expected a reply, got %q", reply.MentionType)
+	}
+	if reply.AuthorName != "Sam Reader" || reply.AuthorURL != server.URL+"/about" || reply.AuthorPhoto != server.URL+"/me.jpg" {
+		t.Errorf("Expected the h-card author, got %q %q %q", reply.AuthorName, reply.AuthorURL, reply.AuthorPhoto)
+	}
+	if reply.Content != "Lovely post. Thanks!" {
+		t.Errorf("Expected the entry content, got %q", reply.Content)
+	}
+	if reply.PublishedAt == nil || !reply.PublishedAt.Equal(time.Date(2026, 10, 18, 9, 30, 0, 0, time.UTC)) {
+		t.Errorf("Expected the published time, got %v", reply.PublishedAt)
+	}
+
+	host := strings.TrimPrefix(server.URL, "http://")
+	for _, path := range []string{"/plain", "/text"} {
+		mention := &models.Webmention{Source: server.URL + path, Target: target}
+		if err := service.Verify(context.Background(), mention); err != nil {
+			t.Fatalf("Verify(%s) failed: %v", path, err)
+		}
+		if mention.MentionType != "mention" || mention.AuthorName != host {
+			t.Errorf("Expected %s to be attributed to its host, got %q by %q", path, mention.MentionType, mention.AuthorName)
+		}
+	}
+
+	if err := service.Verify(context.Background(), &models.Webmention{Source: server.URL + "/unrelated", Target: target}); !errors.Is(err, webmentions.ErrNoLink) {
+		t.Errorf("Expected ErrNoLink, got %v", err)
+	}
+	if err := service.Verify(context.Background(), &models.Webmention{Source: server.URL + "/deleted", Target: target}); !errors.Is(err, webmentions.ErrGone) {
+		t.Errorf("Expected ErrGone, got %v", err)
+	}
+
+	guarded := webmentions.New(nil, nil, "willow.camp")
+	if err := guarded.Verify(context.Background(), &models.Webmention{Source: server.URL + "/reply", Target: target}); !errors.Is(err, previews.ErrBlockedAddress) {
+		t.Errorf("Expected the default client to refuse loopback sources, got %v", err)
+	}
+}
@@ -0,0 +1,13 @@
+// Package themes embeds the bundled blog theme packs. Each directory is a
+// pack: a theme.json manifest plus any of the blog templates (layout.html,
+// index.html, post_show.html, ...) it replaces. Templates a pack leaves out
+// come from internal/blog/templates. See internal/views/contract.go for the
+// data every template receives.
+package themes
+
+import "embed"
+
+// FS holds the bundled theme packs
+//
+//go:embed */theme.json */*.html
+var FS embed.FS